@@ -2,42 +2,76 @@ package main
 
 import (
 "context"
-"net/http"
+"errors"
 "os"
 
+"github.com/Fleexa-Graduation-Project/Backend/internal/api"
 "github.com/Fleexa-Graduation-Project/Backend/internal/api/handlers"
 "github.com/Fleexa-Graduation-Project/Backend/internal/devices"
+"github.com/Fleexa-Graduation-Project/Backend/internal/fleets"
+"github.com/Fleexa-Graduation-Project/Backend/internal/geofences"
+"github.com/Fleexa-Graduation-Project/Backend/internal/idempotency"
+"github.com/Fleexa-Graduation-Project/Backend/internal/maintenance"
+"github.com/Fleexa-Graduation-Project/Backend/internal/ratelimit"
 "github.com/Fleexa-Graduation-Project/Backend/internal/telemetry"
+"github.com/Fleexa-Graduation-Project/Backend/internal/trips"
+"github.com/Fleexa-Graduation-Project/Backend/pkg/awsreq"
+"github.com/Fleexa-Graduation-Project/Backend/pkg/audit"
 "github.com/Fleexa-Graduation-Project/Backend/pkg/db"
+appconfig "github.com/Fleexa-Graduation-Project/Backend/pkg/config"
 "github.com/Fleexa-Graduation-Project/Backend/pkg/logger"
+"github.com/Fleexa-Graduation-Project/Backend/pkg/metrics"
+"github.com/Fleexa-Graduation-Project/Backend/pkg/recovery"
 "github.com/Fleexa-Graduation-Project/Backend/internal/alerts"
 "github.com/Fleexa-Graduation-Project/Backend/internal/commands"
 "github.com/Fleexa-Graduation-Project/Backend/internal/iot"
+"github.com/Fleexa-Graduation-Project/Backend/internal/ota"
+"github.com/Fleexa-Graduation-Project/Backend/internal/provisioning"
+"github.com/Fleexa-Graduation-Project/Backend/internal/rollups"
+"github.com/Fleexa-Graduation-Project/Backend/internal/shadows"
 
-"github.com/aws/aws-lambda-go/events"
 "github.com/aws/aws-lambda-go/lambda"
-"github.com/aws/aws-sdk-go-v2/config"
+"github.com/awslabs/aws-lambda-go-api-proxy/core"
 ginadapter "github.com/awslabs/aws-lambda-go-api-proxy/gin"
-"github.com/gin-gonic/gin"
 )
 
-var ginLambda *ginadapter.GinLambda
+var (
+ginLambda   *ginadapter.GinLambda
+ginLambdaV2 *ginadapter.GinLambdaV2
+)
 
-func Handler(ctx context.Context, req events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
-// If no name is provided in the HTTP request body, throw an error
-return ginLambda.ProxyWithContext(ctx, req)
+// Handler accepts both REST API (payload v1) and HTTP API (payload v2) events,
+// both adapters wrap the same router and the response matches the incoming version
+func Handler(ctx context.Context, req core.SwitchableAPIGatewayRequest) (*core.SwitchableAPIGatewayResponse, error) {
+if v2 := req.Version2(); v2 != nil {
+res, err := ginLambdaV2.ProxyWithContext(ctx, *v2)
+return core.NewSwitchableAPIGatewayResponseV2(&res), err
+}
+if v1 := req.Version1(); v1 != nil {
+res, err := ginLambda.ProxyWithContext(ctx, *v1)
+return core.NewSwitchableAPIGatewayResponseV1(&res), err
+}
+return nil, errors.New("unsupported api gateway event")
 }
 
 func main() {
 log := logger.InitLogger()
 log.Info("starting fleexa api server...")
+metrics.SetNamespace("Fleexa/API")
+
+appCfg, err := appconfig.LoadRequired(appconfig.DeviceStateTable, appconfig.DevicesTable, appconfig.TelemetryTable, appconfig.AlertsTable, appconfig.CommandsTable, appconfig.TripsTable, appconfig.AuditTable, appconfig.BreachesTable, appconfig.ProvisioningTokensTable, appconfig.HourlyAggregatesTable, appconfig.ShadowsTable, appconfig.LatestStateTable, appconfig.DrivingEventsTable)
+if err != nil {
+	log.Error("invalid configuration", "error", err)
+	panic(err)
+}
+appCfg.LogColdStart(log, "api")
 
 if err := db.NewDynamoDBClient(context.Background()); err != nil {
 log.Error("failed to initialize dynamodb", "error", err)
 panic(err)
 }
 
-cfg, err := config.LoadDefaultConfig(context.Background())
+clients, err := awsreq.Shared(context.Background())
 if err != nil {
 log.Error("failed to load aws config for iot", "error", err)
 panic(err)
@@ -49,6 +83,12 @@ log.Error("failed to initialize StateStore", "error", err)
 panic(err)
 }
 
+deviceStore, err := devices.NewDeviceStore()
+if err != nil {
+log.Error("failed to initialize DeviceStore", "error", err)
+panic(err)
+}
+
 telemetryStore, err := telemetry.NewTelemetryStore()
 if err != nil {
 log.Error("failed to initialize TelemetryStore", "error", err)
@@ -65,39 +105,131 @@ if err != nil {
 log.Error("Failed to initialize CommandStore", "error", err)
 panic(err)
 }
-iotPublisher := iot.NewPublisher(cfg)
+iotPublisher := iot.NewPublisher(clients.IoTDataPlane())
+
+otaTargets, err := ota.LoadTargets()
+if err != nil {
+log.Error("failed to load ota targets", "error", err)
+panic(err)
+}
+
+tripStore, err := trips.NewTripStore()
+if err != nil {
+log.Error("failed to initialize TripStore", "error", err)
+panic(err)
+}
+
+fleetStats, err := fleets.NewStatsService(deviceStore, stateStore, tripStore)
+if err != nil {
+log.Error("failed to initialize fleet stats", "error", err)
+panic(err)
+}
+
+auditStore, err := audit.NewStore()
+if err != nil {
+log.Error("failed to initialize AuditStore", "error", err)
+panic(err)
+}
+
+breachStore, err := geofences.NewBreachStore()
+if err != nil {
+log.Error("failed to initialize BreachStore", "error", err)
+panic(err)
+}
+
+provisioningStore, err := provisioning.NewTokenStore()
+if err != nil {
+log.Error("failed to initialize provisioning TokenStore", "error", err)
+panic(err)
+}
+
+hourlyStore, err := rollups.NewHourlyStore()
+if err != nil {
+log.Error("failed to initialize HourlyStore", "error", err)
+panic(err)
+}
+
+shadowStore, err := shadows.NewShadowStore()
+if err != nil {
+log.Error("failed to initialize ShadowStore", "error", err)
+panic(err)
+}
+
+latestStore, err := devices.NewLatestStore()
+if err != nil {
+log.Error("failed to initialize LatestStore", "error", err)
+panic(err)
+}
+
+drivingEventStore, err := trips.NewEventStore()
+if err != nil {
+log.Error("failed to initialize driving EventStore", "error", err)
+panic(err)
+}
+
+purgeQueue, err := telemetry.NewPurgeQueue(clients.SQS())
+if err != nil {
+log.Warn("telemetry purge queue not configured, async device deletes are disabled", "error", err)
+}
+
+chartFetcher, err := iot.NewS3Client(clients.S3())
+if err != nil {
+log.Warn("charts bucket not configured, monthly charts are empty", "error", err)
+}
 
 //initializing the device holder
 deviceHandler := &handlers.DeviceHandler{
 StateStore:     stateStore,
+DeviceStore:    deviceStore,
 TelemetryStore: telemetryStore,
 AlertStore:     alertStore,
 CommandStore:   commandStore,
 IoTPublisher:   iotPublisher,
+OTATargets:     otaTargets,
+FleetStats:     fleetStats,
+AuditStore:     auditStore,
+BreachStore:    breachStore,
+ProvisioningStore: provisioningStore,
+HourlyStore:    hourlyStore,
+ShadowStore:    shadowStore,
+LatestStore:    latestStore,
+PurgeQueue:     purgeQueue,
+S3Fetcher:      chartFetcher,
+DrivingEventStore: drivingEventStore,
 }
 
-router := gin.Default()
+healthHandler := &handlers.HealthHandler{
+DeviceStore: deviceStore,
+}
+
+maintenanceSwitch, err := maintenance.NewSwitch()
+if err != nil {
+log.Error("failed to initialize maintenance switch", "error", err)
+panic(err)
+}
 
-router.GET("/ping", func(c *gin.Context) {
-c.JSON(http.StatusOK, gin.H{"message": "pong"})
-})
+idempotencyStore, err := idempotency.NewStore()
+if err != nil {
+log.Warn("idempotency table not configured, Idempotency-Key is ignored", "error", err)
+}
+
+throttleConfig, err := ratelimit.LoadAPIConfig()
+if err != nil {
+log.Error("invalid api throttle config", "error", err)
+panic(err)
+}
+throttle, err := ratelimit.NewAPIThrottle(throttleConfig)
+if err != nil {
+log.Warn("rate limits table not configured, api callers are not throttled", "error", err)
+}
 
-	//grouping routes
-	v1 := router.Group("/api/v1")
-	{
-		v1.GET("/devices", deviceHandler.GetDevices)
-		v1.GET("/alerts", deviceHandler.GetSortedAlerts)
-		v1.GET("/devices/:id", deviceHandler.GetDeviceByID)
-		v1.GET("/devices/:id/telemetry", deviceHandler.GetDeviceTelemetry)
-		v1.GET("/devices/:id/alerts", deviceHandler.GetDeviceAlerts)
-		v1.GET("/system/overview", deviceHandler.GetSystemOverview)
-		v1.POST("/devices/:id/commands", deviceHandler.SendCommand)
-	}
+router := api.NewRouter(deviceHandler, healthHandler, maintenanceSwitch, idempotencyStore, throttle)
 
 if os.Getenv("AWS_LAMBDA_FUNCTION_NAME") != "" {
 log.Info("Running as AWS Lambda...")
 ginLambda = ginadapter.New(router)
-lambda.Start(Handler)
+ginLambdaV2 = ginadapter.NewV2(router)
+lambda.Start(recovery.Wrap("api", Handler))
 } else {
 port := os.Getenv("PORT")
 if port == "" {
@@ -0,0 +1,66 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+
+	"github.com/Fleexa-Graduation-Project/Backend/internal/commands"
+	appconfig "github.com/Fleexa-Graduation-Project/Backend/pkg/config"
+	"github.com/Fleexa-Graduation-Project/Backend/pkg/db"
+	"github.com/Fleexa-Graduation-Project/Backend/pkg/logger"
+	"github.com/Fleexa-Graduation-Project/Backend/pkg/recovery"
+	"github.com/Fleexa-Graduation-Project/Backend/pkg/timeout"
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/aws/aws-lambda-go/lambda"
+)
+
+var (
+	log     *slog.Logger
+	sweeper *commands.Sweeper
+)
+
+func init() {
+	log = logger.InitLogger()
+	log.Info("command sweeper -> cold Start...")
+
+	appCfg, err := appconfig.LoadRequired(appconfig.CommandsTable)
+	if err != nil {
+		log.Error("invalid configuration", "error", err)
+		panic(err)
+	}
+	appCfg.LogColdStart(log, "command-sweeper")
+
+	if err := db.NewDynamoDBClient(context.Background()); err != nil {
+		log.Error("failed to initialize DynamoDB", "error", err)
+		panic(err)
+	}
+
+	commandStore, err := commands.NewCommandStore()
+	if err != nil {
+		panic(fmt.Errorf("failed to init command store: %w", err))
+	}
+
+	sweeper = &commands.Sweeper{Store: commandStore, Timeout: commands.AckTimeout()}
+
+	log.Info("command sweeper -> Cold Start Completed.", "ack_timeout", sweeper.Timeout.String())
+}
+
+// triggered by an EventBridge schedule, a command times out at most one interval after its timeout
+func handleSchedule(ctx context.Context, event events.CloudWatchEvent) error {
+	invocationLog := logger.WithRequestID(ctx, log)
+
+	ctx, cancel := timeout.Budget(ctx)
+	defer cancel()
+
+	err := timeout.Wrap(sweeper.Sweep(logger.NewContext(ctx, invocationLog)))
+	if errors.Is(err, timeout.ErrDependencyTimeout) {
+		invocationLog.Error("dependency call timed out", "reason", "dependency_timeout", "error", err)
+	}
+	return err
+}
+
+func main() {
+	lambda.Start(recovery.WrapEvent("command-sweeper", handleSchedule))
+}
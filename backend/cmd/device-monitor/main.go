@@ -0,0 +1,114 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+
+	"github.com/Fleexa-Graduation-Project/Backend/internal/alerts"
+	"github.com/Fleexa-Graduation-Project/Backend/internal/devices"
+	"github.com/Fleexa-Graduation-Project/Backend/internal/notifications"
+	"github.com/Fleexa-Graduation-Project/Backend/internal/rules"
+	"github.com/Fleexa-Graduation-Project/Backend/pkg/awsreq"
+	appconfig "github.com/Fleexa-Graduation-Project/Backend/pkg/config"
+	"github.com/Fleexa-Graduation-Project/Backend/pkg/db"
+	"github.com/Fleexa-Graduation-Project/Backend/pkg/logger"
+	"github.com/Fleexa-Graduation-Project/Backend/pkg/recovery"
+	"github.com/Fleexa-Graduation-Project/Backend/pkg/timeout"
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/aws/aws-lambda-go/lambda"
+)
+
+var (
+	log     *slog.Logger
+	monitor *rules.OfflineMonitor
+)
+
+func init() {
+	log = logger.InitLogger()
+	log.Info("device monitor -> cold Start...")
+
+	appCfg, err := appconfig.LoadRequired(appconfig.AlertsTable, appconfig.DeviceStateTable, appconfig.DevicesTable)
+	if err != nil {
+		log.Error("invalid configuration", "error", err)
+		panic(err)
+	}
+	appCfg.LogColdStart(log, "device-monitor")
+
+	if err := db.NewDynamoDBClient(context.Background()); err != nil {
+		log.Error("failed to initialize DynamoDB", "error", err)
+		panic(err)
+	}
+
+	alertStore, err := alerts.NewAlertStore()
+	if err != nil {
+		panic(fmt.Errorf("failed to init alert store: %w", err))
+	}
+
+	stateStore, err := devices.NewStateStore()
+	if err != nil {
+		panic(fmt.Errorf("failed to init device state store: %w", err))
+	}
+
+	deviceStore, err := devices.NewDeviceStore()
+	if err != nil {
+		panic(fmt.Errorf("failed to init device store: %w", err))
+	}
+
+	policy, err := rules.LoadOfflinePolicy()
+	if err != nil {
+		panic(err)
+	}
+
+	firebaseKeyPath := os.Getenv("FIREBASE_CREDENTIALS")
+	if firebaseKeyPath == "" {
+		firebaseKeyPath = "./firebase-adminsdk.json"
+	}
+
+	notifier, err := notifications.NewService(firebaseKeyPath)
+	if err != nil {
+		log.Error("failed to init notification service (Firebase)", "error", err)
+	}
+	if notifier != nil {
+		if notifier.Pending, err = notifications.NewPendingStore(); err != nil {
+			log.Warn("pending alerts table not configured, undeliverable pushes are only logged", "error", err)
+		}
+	}
+
+	engine := rules.NewAlertEngine(alertStore, stateStore, notifier)
+	if engine.Throttle, err = alerts.NewThrottle(); err != nil {
+		log.Warn("alert throttle table not configured, repeated alerts are not suppressed", "error", err)
+	}
+	monitor = rules.NewOfflineMonitor(engine, deviceStore, policy)
+
+	clients, err := awsreq.Shared(context.Background())
+	if err != nil {
+		log.Error("failed to load aws config", "error", err)
+		panic(err)
+	}
+	if monitor.Topic, err = rules.NewOfflineTopic(clients.SNS()); err != nil {
+		log.Warn("offline alert topic not configured, alerts are only stored and pushed", "error", err)
+	}
+
+	log.Info("device monitor -> Cold Start Completed.", "offline_threshold", policy.Default.String())
+}
+
+// triggered by an EventBridge schedule
+func handleSchedule(ctx context.Context, event events.CloudWatchEvent) error {
+	invocationLog := logger.WithRequestID(ctx, log)
+
+	ctx, cancel := timeout.Budget(ctx)
+	defer cancel()
+
+	err := timeout.Wrap(monitor.CheckOfflineDevices(logger.NewContext(ctx, invocationLog)))
+	if errors.Is(err, timeout.ErrDependencyTimeout) {
+		invocationLog.Error("dependency call timed out", "reason", "dependency_timeout", "error", err)
+	}
+	return err
+}
+
+func main() {
+	lambda.Start(recovery.WrapEvent("device-monitor", handleSchedule))
+}
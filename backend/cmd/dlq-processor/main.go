@@ -0,0 +1,84 @@
+package main
+
+import (
+	"context"
+	"log/slog"
+
+	"github.com/Fleexa-Graduation-Project/Backend/internal/ingestion"
+	"github.com/Fleexa-Graduation-Project/Backend/internal/quarantine"
+	"github.com/Fleexa-Graduation-Project/Backend/pkg/awsreq"
+	appconfig "github.com/Fleexa-Graduation-Project/Backend/pkg/config"
+	"github.com/Fleexa-Graduation-Project/Backend/pkg/logger"
+	"github.com/Fleexa-Graduation-Project/Backend/pkg/recovery"
+	"github.com/Fleexa-Graduation-Project/Backend/pkg/timeout"
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/aws/aws-lambda-go/lambda"
+)
+
+var (
+	log     *slog.Logger
+	archive *quarantine.Archive
+)
+
+func init() {
+	log = logger.InitLogger()
+	log.Info("dlq processor -> cold Start...")
+
+	appCfg, err := appconfig.LoadRequired("QUARANTINE_BUCKET")
+	if err != nil {
+		log.Error("invalid configuration", "error", err)
+		panic(err)
+	}
+	appCfg.LogColdStart(log, "dlq-processor")
+
+	clients, err := awsreq.Shared(context.Background())
+	if err != nil {
+		log.Error("failed to load aws config", "error", err)
+		panic(err)
+	}
+
+	archive, err = quarantine.NewArchive(clients.S3())
+	if err != nil {
+		panic(err)
+	}
+
+	log.Info("dlq processor -> Cold Start Completed.", "bucket", archive.Bucket)
+}
+
+// consumes the ingestion dlq, a message is only deleted from the queue once it is archived
+func handleRequest(ctx context.Context, event events.SQSEvent) (events.SQSEventResponse, error) {
+	invocationLog := logger.WithRequestID(ctx, log)
+
+	ctx, cancel := timeout.Budget(ctx)
+	defer cancel()
+
+	response := events.SQSEventResponse{BatchItemFailures: []events.SQSBatchItemFailure{}}
+
+	for _, message := range event.Records {
+		record := quarantine.NewRecord(message, ingestion.Diagnose(message))
+
+		key, err := archive.Put(ctx, record)
+		if err != nil {
+			invocationLog.Error("failed to archive dead-lettered message", "message_id", message.MessageId, "error", err)
+			response.BatchItemFailures = append(response.BatchItemFailures, events.SQSBatchItemFailure{
+				ItemIdentifier: message.MessageId,
+			})
+			continue
+		}
+
+		invocationLog.Warn("message quarantined",
+			"message_id", record.MessageID,
+			"reason", record.Reason,
+			"receive_count", record.ReceiveCount,
+			"original_timestamp", record.OriginalTimestamp,
+			"body_size", len(record.Body),
+			"key", key,
+		)
+	}
+
+	return response, nil
+}
+
+func main() {
+	lambda.Start(recovery.Wrap("dlq-processor", handleRequest))
+}
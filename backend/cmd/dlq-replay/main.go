@@ -0,0 +1,66 @@
+// dlq-replay sends quarantined messages back to the ingestion queue once the cause is fixed:
+//
+//	QUARANTINE_BUCKET=... INGESTION_QUEUE_URL=... go run ./cmd/dlq-replay -date 2024-02-20
+//	go run ./cmd/dlq-replay -prefix quarantine/dt=2024-02-20/abc
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"github.com/Fleexa-Graduation-Project/Backend/internal/quarantine"
+	"github.com/Fleexa-Graduation-Project/Backend/pkg/awsreq"
+	appconfig "github.com/Fleexa-Graduation-Project/Backend/pkg/config"
+	"github.com/Fleexa-Graduation-Project/Backend/pkg/logger"
+	"os"
+	"time"
+)
+
+func main() {
+	date := flag.String("date", "", "replay every message originally sent on this UTC day (YYYY-MM-DD)")
+	prefix := flag.String("prefix", "", "replay the objects under this key prefix instead")
+	flag.Parse()
+
+	log := logger.InitLogger()
+
+	if _, err := appconfig.LoadRequired("QUARANTINE_BUCKET", "INGESTION_QUEUE_URL"); err != nil {
+		log.Error("invalid configuration", "error", err)
+		os.Exit(1)
+	}
+
+	if *prefix == "" {
+		day, err := time.Parse(time.DateOnly, *date)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, "either -date YYYY-MM-DD or -prefix is required")
+			os.Exit(2)
+		}
+		*prefix = quarantine.DatePrefix(day)
+	}
+
+	ctx := context.Background()
+	clients, err := awsreq.Shared(ctx)
+	if err != nil {
+		log.Error("failed to load aws config", "error", err)
+		os.Exit(1)
+	}
+
+	archive, err := quarantine.NewArchive(clients.S3())
+	if err != nil {
+		log.Error("failed to init quarantine archive", "error", err)
+		os.Exit(1)
+	}
+
+	replayer, err := quarantine.NewReplayer(clients.SQS(), archive, log)
+	if err != nil {
+		log.Error("failed to init replayer", "error", err)
+		os.Exit(1)
+	}
+
+	sent, err := replayer.Replay(ctx, *prefix)
+	if err != nil {
+		log.Error("replay stopped", "prefix", *prefix, "sent", sent, "error", err)
+		os.Exit(1)
+	}
+
+	log.Info("replay complete", "prefix", *prefix, "sent", sent)
+}
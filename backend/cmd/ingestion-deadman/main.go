@@ -0,0 +1,78 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+
+	"github.com/Fleexa-Graduation-Project/Backend/internal/deadman"
+	"github.com/Fleexa-Graduation-Project/Backend/pkg/awsreq"
+	appconfig "github.com/Fleexa-Graduation-Project/Backend/pkg/config"
+	"github.com/Fleexa-Graduation-Project/Backend/pkg/db"
+	"github.com/Fleexa-Graduation-Project/Backend/pkg/logger"
+	"github.com/Fleexa-Graduation-Project/Backend/pkg/recovery"
+	"github.com/Fleexa-Graduation-Project/Backend/pkg/timeout"
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/aws/aws-lambda-go/lambda"
+)
+
+var (
+	log     *slog.Logger
+	checker *deadman.Checker
+)
+
+func init() {
+	log = logger.InitLogger()
+	log.Info("ingestion dead-man -> cold Start...")
+
+	appCfg, err := appconfig.LoadRequired(appconfig.ControlTable, "INGESTION_DEADMAN_TOPIC_ARN")
+	if err != nil {
+		log.Error("invalid configuration", "error", err)
+		panic(err)
+	}
+	appCfg.LogColdStart(log, "ingestion-deadman")
+
+	if err := db.NewDynamoDBClient(context.Background()); err != nil {
+		log.Error("failed to initialize DynamoDB", "error", err)
+		panic(err)
+	}
+
+	clients, err := awsreq.Shared(context.Background())
+	if err != nil {
+		log.Error("failed to load aws config", "error", err)
+		panic(err)
+	}
+
+	heartbeat, err := deadman.NewHeartbeat()
+	if err != nil {
+		panic(fmt.Errorf("failed to init ingestion heartbeat: %w", err))
+	}
+
+	notifier, err := deadman.NewNotifier(clients.SNS())
+	if err != nil {
+		panic(err)
+	}
+
+	checker = &deadman.Checker{Heartbeat: heartbeat, Notifier: notifier, Window: deadman.Window()}
+
+	log.Info("ingestion dead-man -> Cold Start Completed.", "window", checker.Window.String())
+}
+
+// triggered by an EventBridge schedule, well below the window so an outage is caught soon after
+func handleSchedule(ctx context.Context, event events.CloudWatchEvent) error {
+	invocationLog := logger.WithRequestID(ctx, log)
+
+	ctx, cancel := timeout.Budget(ctx)
+	defer cancel()
+
+	err := timeout.Wrap(checker.Check(logger.NewContext(ctx, invocationLog)))
+	if errors.Is(err, timeout.ErrDependencyTimeout) {
+		invocationLog.Error("dependency call timed out", "reason", "dependency_timeout", "error", err)
+	}
+	return err
+}
+
+func main() {
+	lambda.Start(recovery.WrapEvent("ingestion-deadman", handleSchedule))
+}
@@ -8,11 +8,21 @@ import (
 
 	"github.com/aws/aws-lambda-go/lambda"
 	"github.com/Fleexa-Graduation-Project/Backend/internal/alerts"
+	"github.com/Fleexa-Graduation-Project/Backend/internal/commands"
+	"github.com/Fleexa-Graduation-Project/Backend/internal/deadman"
+	"github.com/Fleexa-Graduation-Project/Backend/internal/flags"
 	"github.com/Fleexa-Graduation-Project/Backend/internal/devices"
+	"github.com/Fleexa-Graduation-Project/Backend/internal/geofences"
 	"github.com/Fleexa-Graduation-Project/Backend/internal/ingestion"
+	"github.com/Fleexa-Graduation-Project/Backend/internal/ratelimit"
+	"github.com/Fleexa-Graduation-Project/Backend/internal/realtime"
+	"github.com/Fleexa-Graduation-Project/Backend/internal/shadows"
 	"github.com/Fleexa-Graduation-Project/Backend/internal/telemetry"
+	"github.com/Fleexa-Graduation-Project/Backend/pkg/awsreq"
 	"github.com/Fleexa-Graduation-Project/Backend/pkg/db"
+	appconfig "github.com/Fleexa-Graduation-Project/Backend/pkg/config"
 	"github.com/Fleexa-Graduation-Project/Backend/pkg/logger"
+	"github.com/Fleexa-Graduation-Project/Backend/pkg/recovery"
 	"github.com/Fleexa-Graduation-Project/Backend/internal/rules"
 	"github.com/Fleexa-Graduation-Project/Backend/internal/notifications"
 )
@@ -24,20 +34,36 @@ var (
 	stateStore     *devices.StateStore
 	notifier       *notifications.Service 
 	alertEngine    *rules.AlertEngine
-
+	resourceMonitor *rules.ResourceMonitor
+	geofenceStore  *geofences.GeofenceStore
+	breachStore    *geofences.BreachStore
+	broadcaster    *realtime.Broadcaster
+	deviceStore    *devices.DeviceStore
+	rateLimiter    *ratelimit.Limiter
+	archive        *telemetry.Archive
+	shadowStore    *shadows.ShadowStore
+	latestStore    *devices.LatestStore
+	heartbeat      *deadman.Heartbeat
+	commandStore   *commands.CommandStore
+	featureFlags   *flags.Flags
 )
 
 func init() {
 	log = logger.InitLogger()
 	log.Info("lambda function-> cold Start...")
 
+	appCfg, err := appconfig.LoadRequired(appconfig.TelemetryTable, appconfig.AlertsTable, appconfig.DeviceStateTable)
+	if err != nil {
+		log.Error("invalid configuration", "error", err)
+		panic(err)
+	}
+	appCfg.LogColdStart(log, "ingestion")
+
 	if err := db.NewDynamoDBClient(context.Background()); err != nil {
 		log.Error("failed to initialize DynamoDB", "error", err)
 		panic(err)
 	}
 
-	var err error
-
 	telemetryStore, err = telemetry.NewTelemetryStore()
 	if err != nil {
 		panic(fmt.Errorf("failed to init telemetry store: %w", err))
@@ -53,6 +79,67 @@ func init() {
 		panic(fmt.Errorf("failed to init device state store: %w", err))
 	}
 
+	geofenceStore, err = geofences.NewGeofenceStore()
+	if err != nil {
+		log.Warn("geofence store not configured, geofencing disabled", "error", err)
+	}
+	if geofenceStore != nil {
+		breachStore, err = geofences.NewBreachStore()
+		if err != nil {
+			log.Warn("breach store not configured, every reading outside a fence raises an alert", "error", err)
+		}
+	}
+
+	deviceStore, err = devices.NewDeviceStore()
+	if err != nil {
+		log.Warn("device registry not configured, firmware tracking, deactivation checks and streaming disabled", "error", err)
+	}
+
+	broadcaster, err = newBroadcaster()
+	if err != nil {
+		log.Warn("websocket streaming not configured, live updates disabled", "error", err)
+	}
+
+	rateLimiter, err = newRateLimiter()
+	if err != nil {
+		log.Warn("rate limiting not configured, devices are not limited", "error", err)
+	}
+
+	shadowStore, err = shadows.NewShadowStore()
+	if err != nil {
+		log.Warn("shadow store not configured, reported shadow state is not updated", "error", err)
+	}
+
+	latestStore, err = devices.NewLatestStore()
+	if err != nil {
+		log.Warn("latest state table not configured, fleet map state disabled", "error", err)
+	}
+
+	heartbeat, err = deadman.NewHeartbeat()
+	if err != nil {
+		log.Warn("control table not configured, the ingestion dead-man heartbeat is disabled", "error", err)
+	}
+
+	commandStore, err = commands.NewCommandStore()
+	if err != nil {
+		log.Warn("commands table not configured, command acks are dropped", "error", err)
+	}
+
+	featureFlags, err = flags.NewFlags()
+	if err != nil {
+		log.Warn("control table not configured, every feature flag is off", "error", err)
+	} else {
+		// a failed first read leaves the flags off until the background refresh gets them
+		featureFlags.Load(context.Background())
+	}
+
+	if os.Getenv("TELEMETRY_ARCHIVE_ENABLED") == "true" {
+		archive, err = newArchive()
+		if err != nil {
+			log.Warn("telemetry archive not configured, archival disabled", "error", err)
+		}
+	}
+
 	log.Info("iot ingestion -> Cold Start Completed. Stores Ready.")
 
 	firebaseKeyPath := os.Getenv("FIREBASE_CREDENTIALS")
@@ -64,20 +151,108 @@ func init() {
 	if err != nil {
 		log.Error("failed to init notification service (Firebase)", "error", err)
 	}
+	if notifier != nil {
+		if notifier.Pending, err = notifications.NewPendingStore(); err != nil {
+			log.Warn("pending alerts table not configured, undeliverable pushes are only logged", "error", err)
+		}
+	}
 
 	alertEngine = rules.NewAlertEngine(alertStore, stateStore, notifier)
+	if alertEngine.Throttle, err = alerts.NewThrottle(); err != nil {
+		log.Warn("alert throttle table not configured, repeated alerts are not suppressed", "error", err)
+	}
+
+	resourcePolicy, err := rules.LoadResourcePolicy()
+	if err != nil {
+		log.Error("invalid low resource thresholds", "error", err)
+		panic(err)
+	}
+	resourceMonitor = rules.NewResourceMonitor(alertEngine, resourcePolicy)
 
 	log.Info("iot ingestion -> Cold Start Completed. Stores Ready.")
 }
 
+// streaming needs the connections table, the registry (device -> fleet) and the websocket endpoint
+func newBroadcaster() (*realtime.Broadcaster, error) {
+	if deviceStore == nil {
+		return nil, fmt.Errorf("device registry is not configured")
+	}
+
+	connectionStore, err := realtime.NewConnectionStore()
+	if err != nil {
+		return nil, err
+	}
+
+	clients, err := awsreq.Shared(context.Background())
+	if err != nil {
+		return nil, fmt.Errorf("failed to load aws config: %w", err)
+	}
+
+	return realtime.NewBroadcaster(clients.Config(), connectionStore, deviceStore)
+}
+
+func newArchive() (*telemetry.Archive, error) {
+	clients, err := awsreq.Shared(context.Background())
+	if err != nil {
+		return nil, fmt.Errorf("failed to load aws config: %w", err)
+	}
+
+	return telemetry.NewArchive(clients.S3())
+}
+
+// fleet overrides need the registry, without it every device gets the global limit
+func newRateLimiter() (*ratelimit.Limiter, error) {
+	cfg, err := ratelimit.LoadConfig()
+	if err != nil {
+		return nil, err
+	}
+
+	var registry devices.Registry
+	if deviceStore != nil {
+		registry = deviceStore
+	}
+	return ratelimit.NewLimiter(cfg, registry)
+}
+
 func main() {
+	signatureMode, err := ingestion.LoadSignatureMode()
+	if err != nil {
+		log.Error("invalid signature mode", "error", err)
+		panic(err)
+	}
+	if signatureMode != ingestion.SignatureOff && deviceStore == nil {
+		log.Error("signature verification needs the device registry", "mode", string(signatureMode))
+		panic(fmt.Errorf("INGESTION_SIGNATURE_MODE=%s needs DYNAMODB_DEVICES_TABLE", signatureMode))
+	}
+
 	service := &ingestion.Service{
-		Logger:         log,
+		Logger:         logger.Sampled(log, logger.SampleRate()),
 		TelemetryStore: telemetryStore,
 		AlertStore:     alertStore,
 		StateStore:     stateStore,
 		Engine:         alertEngine,
+		GeofenceStore:  geofenceStore,
+		BreachStore:    breachStore,
+		Broadcaster:    broadcaster,
+		RateLimiter:    rateLimiter,
+		Archive:        archive,
+		Resources:      resourceMonitor,
+		Shadows:        shadowStore,
+		LatestStore:    latestStore,
+		Heartbeat:      heartbeat,
+		CommandStore:   commandStore,
+		Flags:          featureFlags,
+
+		SeqResetThreshold: devices.SeqResetThreshold(),
+		SignatureMode:     signatureMode,
+		FailureAlertRate:  ingestion.BatchFailureAlertRate(),
+	}
+	// a nil *DeviceStore inside the interface would not compare equal to nil
+	if deviceStore != nil {
+		service.DeviceStore = deviceStore
+		service.DeviceCache = devices.NewCache(deviceStore, devices.DefaultCacheTTL)
 	}
 
-	lambda.Start(service.HandleRequest)
+	// sqs, a direct IoT rule action and EventBridge all land here, HandleEvent tells them apart
+	lambda.Start(recovery.Wrap("ingestion", service.HandleEvent))
 }
\ No newline at end of file
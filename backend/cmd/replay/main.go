@@ -0,0 +1,105 @@
+// replay writes archived raw telemetry of a fleet back to the telemetry table, oldest first:
+//
+//	TELEMETRY_ARCHIVE_BUCKET=... DYNAMODB_TABLE_NAME=... go run ./cmd/replay -fleet fleet-a -from 2024-02-01 -to 2024-02-20
+//	go run ./cmd/replay -fleet fleet-a -from 2024-02-01 -to 2024-02-20 -dry-run
+//
+// Deployed as a lambda it takes {"fleet_id": ..., "from": "YYYY-MM-DD", "to": "YYYY-MM-DD", "dry_run": true}
+// and returns the counts. Run the trip aggregator with a TRIP_LOOKBACK covering the range afterwards
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log/slog"
+	"os"
+	"time"
+
+	"github.com/Fleexa-Graduation-Project/Backend/internal/telemetry"
+	"github.com/Fleexa-Graduation-Project/Backend/pkg/awsreq"
+	appconfig "github.com/Fleexa-Graduation-Project/Backend/pkg/config"
+	"github.com/Fleexa-Graduation-Project/Backend/pkg/db"
+	"github.com/Fleexa-Graduation-Project/Backend/pkg/logger"
+	"github.com/Fleexa-Graduation-Project/Backend/pkg/recovery"
+	"github.com/aws/aws-lambda-go/lambda"
+)
+
+type ReplayRequest struct {
+	FleetID string `json:"fleet_id"`
+	From    string `json:"from"`
+	To      string `json:"to"`
+	DryRun  bool   `json:"dry_run"`
+}
+
+var (
+	log     *slog.Logger
+	archive *telemetry.Archive
+	store   *telemetry.TelemetryStore
+)
+
+func setup(ctx context.Context) error {
+	if _, err := appconfig.LoadRequired(appconfig.TelemetryTable, "TELEMETRY_ARCHIVE_BUCKET"); err != nil {
+		return err
+	}
+
+	clients, err := awsreq.Shared(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to load aws config: %w", err)
+	}
+	if archive, err = telemetry.NewArchive(clients.S3()); err != nil {
+		return err
+	}
+
+	if err := db.NewDynamoDBClient(ctx); err != nil {
+		return fmt.Errorf("failed to initialize dynamodb: %w", err)
+	}
+	store, err = telemetry.NewTelemetryStore()
+	return err
+}
+
+func run(ctx context.Context, req ReplayRequest) (telemetry.ReplayReport, error) {
+	from, fromErr := time.Parse(time.DateOnly, req.From)
+	to, toErr := time.Parse(time.DateOnly, req.To)
+	if req.FleetID == "" || fromErr != nil || toErr != nil || from.After(to) {
+		return telemetry.ReplayReport{}, fmt.Errorf("fleet_id, from and to (YYYY-MM-DD, from <= to) are required")
+	}
+
+	report, err := telemetry.Replay(ctx, archive, store, req.FleetID, from, to, req.DryRun)
+	if err != nil {
+		log.Error("replay stopped", "fleet_id", req.FleetID, "from", req.From, "to", req.To, "written", report.Written, "error", err)
+		return report, err
+	}
+
+	log.Info("replay complete", "fleet_id", req.FleetID, "from", req.From, "to", req.To, "dry_run", req.DryRun,
+		"read", report.Read, "written", report.Written, "duplicates", report.Duplicates)
+	return report, nil
+}
+
+func main() {
+	var req ReplayRequest
+	flag.StringVar(&req.FleetID, "fleet", "", "fleet whose archived telemetry is replayed")
+	flag.StringVar(&req.From, "from", "", "first utc day to replay (YYYY-MM-DD)")
+	flag.StringVar(&req.To, "to", "", "last utc day to replay (YYYY-MM-DD), defaults to -from")
+	flag.BoolVar(&req.DryRun, "dry-run", false, "only count the readings that would be replayed")
+	flag.Parse()
+
+	log = logger.InitLogger()
+
+	ctx := context.Background()
+	if err := setup(ctx); err != nil {
+		log.Error("failed to initialize replay", "error", err)
+		os.Exit(1)
+	}
+
+	if os.Getenv("AWS_LAMBDA_FUNCTION_NAME") != "" {
+		lambda.Start(recovery.Wrap("replay", run))
+		return
+	}
+
+	if req.To == "" {
+		req.To = req.From
+	}
+	if _, err := run(ctx, req); err != nil {
+		os.Exit(1)
+	}
+}
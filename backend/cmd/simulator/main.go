@@ -0,0 +1,248 @@
+// simulator drives the ingestion pipeline with synthetic vehicles, no hardware needed:
+//
+//	INGESTION_QUEUE_URL=... go run ./cmd/simulator -devices 50 -interval 5s -duration 10m -ramp 1m
+//	go run ./cmd/simulator -target iot -devices 5 -count 1000 -bad 0.05 -dup 0.02
+//	LOG_OUTPUT=stderr go run ./cmd/simulator -target stdout -devices 2 -count 10
+//
+// Each device drives between random waypoints within -radius km of -center and reports temp,
+// position, speed, ignition, battery, fuel and seq on its telemetry topic. -bad and -dup are
+// the share of messages sent malformed or as a resend of the previous reading, so validation
+// and dedup see traffic too. Point the geofences of the fleet at the center to exercise breaches
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log/slog"
+	"math/rand/v2"
+	"os"
+	"os/signal"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/Fleexa-Graduation-Project/Backend/pkg/awsreq"
+	appconfig "github.com/Fleexa-Graduation-Project/Backend/pkg/config"
+	"github.com/Fleexa-Graduation-Project/Backend/pkg/geo"
+	"github.com/Fleexa-Graduation-Project/Backend/pkg/logger"
+)
+
+type options struct {
+	target   string
+	devices  int
+	prefix   string
+	interval time.Duration
+	duration time.Duration
+	count    int64
+	ramp     time.Duration
+	bad      float64
+	dup      float64
+	center   geo.Coord
+	radius   float64
+	seed     uint64
+}
+
+// counts of one run, updated by every device
+type stats struct {
+	reserved   atomic.Int64 // messages claimed against -count
+	sent       atomic.Int64
+	failed     atomic.Int64
+	bad        atomic.Int64
+	duplicates atomic.Int64
+}
+
+func main() {
+	opts, err := parseFlags()
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(2)
+	}
+
+	log := logger.InitLogger()
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	out, err := newSender(ctx, opts.target)
+	if err != nil {
+		log.Error("failed to init sender", "target", opts.target, "error", err)
+		os.Exit(1)
+	}
+
+	if opts.duration > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, opts.duration)
+		defer cancel()
+	}
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	log.Info("simulation started", "target", opts.target, "devices", opts.devices, "interval", opts.interval.String(),
+		"duration", opts.duration.String(), "count", opts.count, "ramp", opts.ramp.String(), "bad_rate", opts.bad, "dup_rate", opts.dup)
+
+	run := &stats{}
+	started := time.Now()
+	go report(ctx, log, run, started)
+
+	var wg sync.WaitGroup
+	for i := 0; i < opts.devices; i++ {
+		rng := rand.New(rand.NewPCG(opts.seed, uint64(i)))
+		v := newVehicle(fmt.Sprintf("%s%03d", opts.prefix, i+1), rng, opts.center, opts.radius)
+
+		// ramp-up spreads the starts, without it devices start at a random point of their interval
+		delay := time.Duration(rng.Int64N(int64(opts.interval)))
+		if opts.ramp > 0 {
+			delay = opts.ramp * time.Duration(i) / time.Duration(opts.devices)
+		}
+
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			drive(ctx, cancel, opts, out, v, delay, run, log)
+		}()
+	}
+	wg.Wait()
+
+	elapsed := time.Since(started)
+	log.Info("simulation complete", "sent", run.sent.Load(), "failed", run.failed.Load(), "bad", run.bad.Load(),
+		"duplicates", run.duplicates.Load(), "elapsed", elapsed.Round(time.Millisecond).String(),
+		"per_second", float64(run.sent.Load())/elapsed.Seconds())
+	if run.failed.Load() > 0 {
+		os.Exit(1)
+	}
+}
+
+func parseFlags() (options, error) {
+	opts := options{}
+	center := flag.String("center", "30.0444,31.2357", "lat,lon the devices drive around")
+	flag.StringVar(&opts.target, "target", "sqs", "where messages go: sqs (INGESTION_QUEUE_URL), iot (the device topics) or stdout")
+	flag.IntVar(&opts.devices, "devices", 10, "number of simulated devices")
+	flag.StringVar(&opts.prefix, "id-prefix", "sim-", "device id prefix, ids are the prefix + 001, 002...")
+	flag.DurationVar(&opts.interval, "interval", 5*time.Second, "reporting interval of each device")
+	flag.DurationVar(&opts.duration, "duration", 0, "stop after this long, 0 runs until -count or ctrl-c")
+	flag.Int64Var(&opts.count, "count", 0, "stop after this many messages in total, 0 for no limit")
+	flag.DurationVar(&opts.ramp, "ramp", 0, "start the devices evenly over this long")
+	flag.Float64Var(&opts.bad, "bad", 0.01, "share of messages sent malformed, 0 to 1")
+	flag.Float64Var(&opts.dup, "dup", 0, "share of messages resending the previous reading, 0 to 1")
+	flag.Float64Var(&opts.radius, "radius", 10, "km around the center the waypoints are picked in")
+	flag.Uint64Var(&opts.seed, "seed", uint64(time.Now().UnixNano()), "random seed, the same seed replays the same paths")
+	flag.Parse()
+
+	lat, lon, ok := strings.Cut(*center, ",")
+	var latErr, lonErr error
+	opts.center.Lat, latErr = strconv.ParseFloat(strings.TrimSpace(lat), 64)
+	opts.center.Lon, lonErr = strconv.ParseFloat(strings.TrimSpace(lon), 64)
+
+	switch {
+	case !ok || latErr != nil || lonErr != nil || !geo.ValidCoord(opts.center.Lat, opts.center.Lon):
+		return opts, fmt.Errorf("-center must be lat,lon, got %q", *center)
+	case opts.target != "sqs" && opts.target != "iot" && opts.target != "stdout":
+		return opts, fmt.Errorf("-target must be sqs, iot or stdout, got %q", opts.target)
+	case opts.devices < 1:
+		return opts, fmt.Errorf("-devices must be at least 1")
+	// readings are deduplicated per device and second, faster reporting would drop real ones
+	case opts.interval < time.Second:
+		return opts, fmt.Errorf("-interval must be at least 1s")
+	case opts.duration < 0 || opts.count < 0 || opts.ramp < 0 || opts.radius <= 0:
+		return opts, fmt.Errorf("-duration, -count, -ramp must not be negative and -radius must be positive")
+	case opts.bad < 0 || opts.bad > 1 || opts.dup < 0 || opts.dup > 1:
+		return opts, fmt.Errorf("-bad and -dup must be between 0 and 1")
+	}
+	return opts, nil
+}
+
+// drive reports one device every interval until ctx ends, the last device to claim the -count
+// budget cancels the run
+func drive(ctx context.Context, cancel context.CancelFunc, opts options, out sender, v *vehicle, delay time.Duration, run *stats, log *slog.Logger) {
+	select {
+	case <-ctx.Done():
+		return
+	case <-time.After(delay):
+	}
+
+	topic := fmt.Sprintf("%s/%s/telemetry", appconfig.Topic("devices"), v.id)
+	ticker := time.NewTicker(opts.interval)
+	defer ticker.Stop()
+
+	for {
+		if opts.count > 0 {
+			claimed := run.reserved.Add(1)
+			if claimed > opts.count {
+				cancel()
+				return
+			}
+		}
+
+		now := time.Now()
+		v.advance(opts.interval)
+
+		var body []byte
+		roll := v.rng.Float64()
+		switch {
+		case roll < opts.bad:
+			var kind string
+			kind, body = v.bad(now)
+			run.bad.Add(1)
+			log.Debug("sending malformed message", "device_id", v.id, "kind", kind)
+		case roll < opts.bad+opts.dup && v.lastGood != nil:
+			body = v.lastGood
+			run.duplicates.Add(1)
+		default:
+			body = v.reading(now)
+		}
+
+		if err := out.Send(ctx, topic, body); err != nil {
+			if ctx.Err() != nil {
+				return
+			}
+			run.failed.Add(1)
+			log.Warn("failed to send message", "device_id", v.id, "error", err)
+		} else {
+			run.sent.Add(1)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// a progress line every 10 seconds for long runs
+func report(ctx context.Context, log *slog.Logger, run *stats, started time.Time) {
+	ticker := time.NewTicker(10 * time.Second)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			log.Info("simulation progress", "sent", run.sent.Load(), "failed", run.failed.Load(),
+				"per_second", float64(run.sent.Load())/time.Since(started).Seconds())
+		}
+	}
+}
+
+func newSender(ctx context.Context, target string) (sender, error) {
+	if target == "stdout" {
+		return stdoutSender{}, nil
+	}
+	if target == "sqs" {
+		if _, err := appconfig.LoadRequired("INGESTION_QUEUE_URL"); err != nil {
+			return nil, err
+		}
+	}
+
+	clients, err := awsreq.Shared(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load aws config: %w", err)
+	}
+	if target == "iot" {
+		return newIoTSender(clients.IoTDataPlane()), nil
+	}
+	return newSQSSender(clients.SQS()), nil
+}
@@ -0,0 +1,78 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strconv"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/iotdataplane"
+	"github.com/aws/aws-sdk-go-v2/service/sqs"
+
+	"github.com/Fleexa-Graduation-Project/Backend/internal/iot"
+	"github.com/Fleexa-Graduation-Project/Backend/pkg/timeout"
+)
+
+// sender delivers one raw message body, which may be deliberately malformed json
+type sender interface {
+	Send(ctx context.Context, topic string, body []byte) error
+}
+
+// sqsSender enqueues the message the way the iot rule does, {"topic": ..., "payload": ...}
+type sqsSender struct {
+	client   *sqs.Client
+	queueURL string
+}
+
+func newSQSSender(client *sqs.Client) sqsSender {
+	return sqsSender{client: client, queueURL: os.Getenv("INGESTION_QUEUE_URL")}
+}
+
+func (s sqsSender) Send(ctx context.Context, topic string, body []byte) error {
+	// spliced rather than marshaled so a malformed body stays malformed
+	message := `{"topic":` + strconv.Quote(topic) + `,"payload":` + string(body) + `}`
+
+	callCtx, cancel := timeout.Call(ctx)
+	defer cancel()
+	_, err := s.client.SendMessage(callCtx, &sqs.SendMessageInput{
+		QueueUrl:    aws.String(s.queueURL),
+		MessageBody: aws.String(message),
+	})
+	if err != nil {
+		return fmt.Errorf("failed to enqueue message for %s: %w", topic, err)
+	}
+	return nil
+}
+
+// iotSender publishes on the device topic, through the iot rule like a real device
+type iotSender struct {
+	publisher *iot.Publisher
+}
+
+func newIoTSender(client *iotdataplane.Client) iotSender {
+	return iotSender{publisher: iot.NewPublisher(client)}
+}
+
+// Publisher.Publish marshals its payload, the raw bytes go straight to the client instead
+func (s iotSender) Send(ctx context.Context, topic string, body []byte) error {
+	callCtx, cancel := timeout.Call(ctx)
+	defer cancel()
+	_, err := s.publisher.Client.Publish(callCtx, &iotdataplane.PublishInput{
+		Topic:   aws.String(topic),
+		Payload: body,
+		Qos:     1,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to publish to topic %s: %w", topic, err)
+	}
+	return nil
+}
+
+// stdoutSender prints topic and body, one message per line
+type stdoutSender struct{}
+
+func (stdoutSender) Send(_ context.Context, topic string, body []byte) error {
+	_, err := fmt.Fprintf(os.Stdout, "%s %s\n", topic, body)
+	return err
+}
@@ -0,0 +1,158 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"math/rand/v2"
+	"time"
+
+	"github.com/Fleexa-Graduation-Project/Backend/models"
+	"github.com/Fleexa-Graduation-Project/Backend/pkg/geo"
+)
+
+// one degree of latitude, the flat approximation is fine for paths of a few km
+const kmPerDegree = 111.32
+
+// vehicle is a refrigerated truck driving between random waypoints around the center, with a
+// temp sensor, a gps fix, a battery and a fuel tank. It stops at some waypoints with the ignition
+// off and refuels there once the tank runs low
+type vehicle struct {
+	id     string
+	rng    *rand.Rand
+	center geo.Coord
+	radius float64 // km
+
+	position geo.Coord
+	target   geo.Coord
+	cruise   float64 // km/h it accelerates to
+	speed    float64
+	stopLeft time.Duration
+	temp     float64
+	battery  float64
+	fuel     float64
+	seq      int64
+	lastGood []byte // the last valid envelope, resent as a duplicate
+}
+
+func newVehicle(id string, rng *rand.Rand, center geo.Coord, radius float64) *vehicle {
+	v := &vehicle{
+		id:      id,
+		rng:     rng,
+		center:  center,
+		radius:  radius,
+		temp:    2 + rng.Float64()*4,
+		battery: 60 + rng.Float64()*40,
+		fuel:    30 + rng.Float64()*70,
+	}
+	v.position = v.waypoint()
+	v.target = v.waypoint()
+	v.cruise = 40 + rng.Float64()*50
+	return v
+}
+
+// a random point within radius of the center
+func (v *vehicle) waypoint() geo.Coord {
+	distance := v.radius * math.Sqrt(v.rng.Float64())
+	angle := v.rng.Float64() * 2 * math.Pi
+	lat := v.center.Lat + distance*math.Cos(angle)/kmPerDegree
+	lon := v.center.Lon + distance*math.Sin(angle)/(kmPerDegree*math.Cos(v.center.Lat*math.Pi/180))
+	return geo.Coord{Lat: lat, Lon: lon}
+}
+
+// advance moves the vehicle dt further along its path and drifts its sensors
+func (v *vehicle) advance(dt time.Duration) {
+	v.temp = math.Max(-2, math.Min(12, v.temp+v.rng.NormFloat64()*0.2))
+	v.battery = math.Max(0, v.battery-dt.Hours()*(1+v.rng.Float64()))
+
+	if v.stopLeft > 0 {
+		v.speed = 0
+		v.stopLeft -= dt
+		if v.fuel < 20 {
+			v.fuel = 100
+		}
+		return
+	}
+
+	// accelerate toward the cruise speed, with some traffic noise
+	v.speed += (v.cruise-v.speed)*0.5 + v.rng.NormFloat64()*5
+	v.speed = math.Max(0, math.Min(130, v.speed))
+
+	step := v.speed * dt.Hours()
+	remaining := geo.DistanceKM(v.position, v.target)
+	v.fuel = math.Max(0, v.fuel-step*0.05)
+	if step < remaining {
+		fraction := step / remaining
+		v.position.Lat += (v.target.Lat - v.position.Lat) * fraction
+		v.position.Lon += (v.target.Lon - v.position.Lon) * fraction
+		return
+	}
+
+	// arrived, every fourth waypoint is a delivery stop of up to three minutes
+	v.position = v.target
+	v.target = v.waypoint()
+	v.cruise = 40 + v.rng.Float64()*50
+	if v.rng.IntN(4) == 0 {
+		v.stopLeft = time.Duration(30+v.rng.IntN(150)) * time.Second
+		v.speed = 0
+	}
+}
+
+// reading is the next telemetry envelope of the vehicle
+func (v *vehicle) reading(at time.Time) []byte {
+	v.seq++
+	envelope := models.MQTTEnvelope{
+		DeviceID:  v.id,
+		Timestamp: at.Unix(),
+		Type:      "temp-sensor",
+		Payload: map[string]interface{}{
+			"temp":     round(v.temp, 1),
+			"lat":      round(v.position.Lat, 6),
+			"lon":      round(v.position.Lon, 6),
+			"speed":    round(v.speed, 1),
+			"ignition": v.stopLeft <= 0,
+			"battery":  round(v.battery, 1),
+			"fuel":     round(v.fuel, 1),
+			"seq":      v.seq,
+		},
+	}
+	body, _ := json.Marshal(envelope)
+	v.lastGood = body
+	return body
+}
+
+// the ways a message can be broken, each one is rejected by a different check of ingestion
+var badKinds = []string{"malformed_json", "missing_timestamp", "unknown_type", "non_numeric_temp", "unknown_unit"}
+
+// bad is a message ingestion must drop, and which kind it is
+func (v *vehicle) bad(at time.Time) (string, []byte) {
+	kind := badKinds[v.rng.IntN(len(badKinds))]
+	envelope := map[string]interface{}{
+		"device_id": v.id,
+		"timestamp": at.Unix(),
+		"type":      "temp-sensor",
+		"payload":   map[string]interface{}{"temp": round(v.temp, 1)},
+	}
+	payload := envelope["payload"].(map[string]interface{})
+
+	switch kind {
+	case "malformed_json":
+		return kind, []byte(fmt.Sprintf(`{"device_id": %q, "timestamp": %d, "payload": {"temp": `, v.id, at.Unix()))
+	case "missing_timestamp":
+		delete(envelope, "timestamp")
+	case "unknown_type":
+		envelope["type"] = "flux-capacitor"
+	case "non_numeric_temp":
+		payload["temp"] = "cold"
+	case "unknown_unit":
+		payload["speed"] = 40
+		payload["speed_unit"] = "furlongs"
+	}
+	body, _ := json.Marshal(envelope)
+	return kind, body
+}
+
+func round(value float64, places int) float64 {
+	scale := math.Pow(10, float64(places))
+	return math.Round(value*scale) / scale
+}
@@ -0,0 +1,71 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+
+	"github.com/Fleexa-Graduation-Project/Backend/internal/telemetry"
+	"github.com/Fleexa-Graduation-Project/Backend/pkg/awsreq"
+	appconfig "github.com/Fleexa-Graduation-Project/Backend/pkg/config"
+	"github.com/Fleexa-Graduation-Project/Backend/pkg/db"
+	"github.com/Fleexa-Graduation-Project/Backend/pkg/logger"
+	"github.com/Fleexa-Graduation-Project/Backend/pkg/recovery"
+	"github.com/Fleexa-Graduation-Project/Backend/pkg/timeout"
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/aws/aws-lambda-go/lambda"
+)
+
+var (
+	log    *slog.Logger
+	purger *telemetry.Purger
+)
+
+func init() {
+	log = logger.InitLogger()
+	log.Info("telemetry purge -> cold Start...")
+
+	appCfg, err := appconfig.LoadRequired(appconfig.TelemetryTable, "TELEMETRY_PURGE_QUEUE_URL")
+	if err != nil {
+		log.Error("invalid configuration", "error", err)
+		panic(err)
+	}
+	appCfg.LogColdStart(log, "telemetry-purge")
+
+	if err := db.NewDynamoDBClient(context.Background()); err != nil {
+		log.Error("failed to initialize DynamoDB", "error", err)
+		panic(err)
+	}
+
+	clients, err := awsreq.Shared(context.Background())
+	if err != nil {
+		log.Error("failed to load aws config", "error", err)
+		panic(err)
+	}
+
+	telemetryStore, err := telemetry.NewTelemetryStore()
+	if err != nil {
+		panic(fmt.Errorf("failed to init telemetry store: %w", err))
+	}
+
+	queue, err := telemetry.NewPurgeQueue(clients.SQS())
+	if err != nil {
+		panic(err)
+	}
+
+	purger = &telemetry.Purger{Store: telemetryStore, Queue: queue, Chunk: telemetry.DefaultPurgeChunk}
+
+	log.Info("telemetry purge -> Cold Start Completed.")
+}
+
+// consumes the purge queue fed by DELETE /devices/:id?purge_telemetry=true&async=true
+func handleRequest(ctx context.Context, event events.SQSEvent) (events.SQSEventResponse, error) {
+	ctx, cancel := timeout.Budget(ctx)
+	defer cancel()
+
+	return purger.HandleRequest(logger.NewContext(ctx, logger.WithRequestID(ctx, log)), event)
+}
+
+func main() {
+	lambda.Start(recovery.Wrap("telemetry-purge", handleRequest))
+}
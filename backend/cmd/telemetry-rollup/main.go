@@ -0,0 +1,81 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+
+	"github.com/Fleexa-Graduation-Project/Backend/internal/devices"
+	"github.com/Fleexa-Graduation-Project/Backend/internal/rollups"
+	"github.com/Fleexa-Graduation-Project/Backend/internal/telemetry"
+	appconfig "github.com/Fleexa-Graduation-Project/Backend/pkg/config"
+	"github.com/Fleexa-Graduation-Project/Backend/pkg/db"
+	"github.com/Fleexa-Graduation-Project/Backend/pkg/logger"
+	"github.com/Fleexa-Graduation-Project/Backend/pkg/recovery"
+	"github.com/Fleexa-Graduation-Project/Backend/pkg/timeout"
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/aws/aws-lambda-go/lambda"
+)
+
+var (
+	log *slog.Logger
+	job *rollups.Job
+)
+
+func init() {
+	log = logger.InitLogger()
+	log.Info("telemetry rollup -> cold Start...")
+
+	appCfg, err := appconfig.LoadRequired(appconfig.DeviceStateTable, appconfig.DevicesTable, appconfig.TelemetryTable, appconfig.HourlyAggregatesTable)
+	if err != nil {
+		log.Error("invalid configuration", "error", err)
+		panic(err)
+	}
+	appCfg.LogColdStart(log, "telemetry-rollup")
+
+	if err := db.NewDynamoDBClient(context.Background()); err != nil {
+		log.Error("failed to initialize DynamoDB", "error", err)
+		panic(err)
+	}
+
+	stateStore, err := devices.NewStateStore()
+	if err != nil {
+		panic(fmt.Errorf("failed to init device state store: %w", err))
+	}
+
+	deviceStore, err := devices.NewDeviceStore()
+	if err != nil {
+		panic(fmt.Errorf("failed to init device store: %w", err))
+	}
+
+	telemetryStore, err := telemetry.NewTelemetryStore()
+	if err != nil {
+		panic(fmt.Errorf("failed to init telemetry store: %w", err))
+	}
+
+	hourlyStore, err := rollups.NewHourlyStore()
+	if err != nil {
+		panic(fmt.Errorf("failed to init hourly aggregate store: %w", err))
+	}
+
+	job = &rollups.Job{StateStore: stateStore, DeviceStore: deviceStore, TelemetryStore: telemetryStore, HourlyStore: hourlyStore}
+
+	log.Info("telemetry rollup -> Cold Start Completed.")
+}
+
+// triggered hourly by an EventBridge schedule, rolls up the hour before the event
+func handleSchedule(ctx context.Context, event events.CloudWatchEvent) error {
+	ctx, cancel := timeout.Budget(ctx)
+	defer cancel()
+
+	hour, err := rollups.TargetHour(event.Time, event.Detail)
+	if err != nil {
+		return err
+	}
+
+	return timeout.Wrap(job.Run(logger.NewContext(ctx, logger.WithRequestID(ctx, log)), hour))
+}
+
+func main() {
+	lambda.Start(recovery.WrapEvent("telemetry-rollup", handleSchedule))
+}
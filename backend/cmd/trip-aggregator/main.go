@@ -0,0 +1,86 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+
+	"github.com/Fleexa-Graduation-Project/Backend/internal/devices"
+	"github.com/Fleexa-Graduation-Project/Backend/internal/telemetry"
+	"github.com/Fleexa-Graduation-Project/Backend/internal/trips"
+	appconfig "github.com/Fleexa-Graduation-Project/Backend/pkg/config"
+	"github.com/Fleexa-Graduation-Project/Backend/pkg/db"
+	"github.com/Fleexa-Graduation-Project/Backend/pkg/driving"
+	"github.com/Fleexa-Graduation-Project/Backend/pkg/logger"
+	"github.com/Fleexa-Graduation-Project/Backend/pkg/recovery"
+	"github.com/Fleexa-Graduation-Project/Backend/pkg/timeout"
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/aws/aws-lambda-go/lambda"
+)
+
+var (
+	log        *slog.Logger
+	aggregator *trips.Aggregator
+)
+
+func init() {
+	log = logger.InitLogger()
+	log.Info("trip aggregator -> cold Start...")
+
+	appCfg, err := appconfig.LoadRequired(appconfig.DeviceStateTable, appconfig.TelemetryTable, appconfig.TripsTable)
+	if err != nil {
+		log.Error("invalid configuration", "error", err)
+		panic(err)
+	}
+	appCfg.LogColdStart(log, "trip-aggregator")
+
+	if err := db.NewDynamoDBClient(context.Background()); err != nil {
+		log.Error("failed to initialize DynamoDB", "error", err)
+		panic(err)
+	}
+
+	stateStore, err := devices.NewStateStore()
+	if err != nil {
+		panic(fmt.Errorf("failed to init device state store: %w", err))
+	}
+
+	telemetryStore, err := telemetry.NewTelemetryStore()
+	if err != nil {
+		panic(fmt.Errorf("failed to init telemetry store: %w", err))
+	}
+
+	tripStore, err := trips.NewTripStore()
+	if err != nil {
+		panic(fmt.Errorf("failed to init trip store: %w", err))
+	}
+
+	aggregator, err = trips.NewAggregator(stateStore, telemetryStore, tripStore)
+	if err != nil {
+		panic(err)
+	}
+
+	if aggregator.EventStore, err = trips.NewEventStore(); err != nil {
+		log.Warn("driving event store not configured, driving events are not detected", "error", err)
+	} else {
+		if aggregator.Driving, err = driving.LoadPolicy(); err != nil {
+			panic(err)
+		}
+		if aggregator.DeviceStore, err = devices.NewDeviceStore(); err != nil {
+			log.Warn("device registry not configured, driving events use the default thresholds", "error", err)
+		}
+	}
+
+	log.Info("trip aggregator -> Cold Start Completed.", "max_gap", aggregator.MaxGap.String(), "lookback", aggregator.Lookback.String())
+}
+
+// triggered by an EventBridge schedule
+func handleSchedule(ctx context.Context, event events.CloudWatchEvent) error {
+	ctx, cancel := timeout.Budget(ctx)
+	defer cancel()
+
+	return timeout.Wrap(aggregator.Run(logger.NewContext(ctx, logger.WithRequestID(ctx, log))))
+}
+
+func main() {
+	lambda.Start(recovery.WrapEvent("trip-aggregator", handleSchedule))
+}
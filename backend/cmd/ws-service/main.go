@@ -0,0 +1,53 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+
+	"github.com/Fleexa-Graduation-Project/Backend/internal/realtime"
+	appconfig "github.com/Fleexa-Graduation-Project/Backend/pkg/config"
+	"github.com/Fleexa-Graduation-Project/Backend/pkg/db"
+	"github.com/Fleexa-Graduation-Project/Backend/pkg/logger"
+	"github.com/Fleexa-Graduation-Project/Backend/pkg/recovery"
+	"github.com/aws/aws-lambda-go/lambda"
+)
+
+var (
+	log     *slog.Logger
+	handler *realtime.Handler
+)
+
+func init() {
+	log = logger.InitLogger()
+	log.Info("websocket service -> cold Start...")
+
+	appCfg, err := appconfig.LoadRequired(appconfig.ConnectionsTable)
+	if err != nil {
+		log.Error("invalid configuration", "error", err)
+		panic(err)
+	}
+	appCfg.LogColdStart(log, "ws-service")
+
+	if err := db.NewDynamoDBClient(context.Background()); err != nil {
+		log.Error("failed to initialize DynamoDB", "error", err)
+		panic(err)
+	}
+
+	connectionStore, err := realtime.NewConnectionStore()
+	if err != nil {
+		panic(fmt.Errorf("failed to init connection store: %w", err))
+	}
+
+	handler = &realtime.Handler{
+		Logger:      log,
+		Connections: connectionStore,
+	}
+
+	log.Info("websocket service -> Cold Start Completed.")
+}
+
+// $connect, $disconnect and $default routes of the websocket api
+func main() {
+	lambda.Start(recovery.Wrap("ws-service", handler.HandleRequest))
+}
@@ -4,7 +4,6 @@ package alerts
 import (
 	"context"
 	"fmt"
-	"os"
 	"time"
 	"github.com/google/uuid"
 
@@ -14,7 +13,9 @@ import (
 	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
 
 	"github.com/Fleexa-Graduation-Project/Backend/models"
+	appconfig "github.com/Fleexa-Graduation-Project/Backend/pkg/config"
 	"github.com/Fleexa-Graduation-Project/Backend/pkg/db"
+	"github.com/Fleexa-Graduation-Project/Backend/pkg/timeout"
 )
 
 type AlertStore struct {
@@ -23,7 +24,7 @@ type AlertStore struct {
 }
 
 func NewAlertStore() (*AlertStore, error) {
-	tableName := os.Getenv("DYNAMODB_ALERTS_TABLE")
+	tableName := appconfig.TableName("DYNAMODB_ALERTS_TABLE")
 	if tableName == "" {
 		return nil, fmt.Errorf("DYNAMODB_ALERTS_TABLE environment variable is not set")
 	}
@@ -56,7 +57,9 @@ func (store *AlertStore) SaveAlert(ctx context.Context, alert models.Alert) erro
 		Item:      item,
 	}
 
-	_, err = store.Client.PutItem(ctx, input)
+	callCtx, cancel := timeout.Call(ctx)
+	defer cancel()
+	_, err = store.Client.PutItem(callCtx, input)
 	if err != nil {
 		return fmt.Errorf("failed to store alert in dynamodb: %w", err)
 	}
@@ -90,7 +93,9 @@ func (store *AlertStore) GetAlertsBySeverity(ctx context.Context, severity strin
 		Limit: aws.Int32(limit),
 	}
 
-	res, err := store.Client.Query(ctx, input)
+	callCtx, cancel := timeout.Call(ctx)
+	defer cancel()
+	res, err := store.Client.Query(callCtx, input)
 	if err != nil {
 		return nil, fmt.Errorf("failed to query alerts by severity: %w", err)
 	}
@@ -121,7 +126,9 @@ func (store *AlertStore) GetAlertsByDevice(ctx context.Context, deviceID string,
         Limit:            aws.Int32(limit),
     }
 
-    res, err := store.Client.Query(ctx, input)
+    callCtx, cancel := timeout.Call(ctx)
+    defer cancel()
+    res, err := store.Client.Query(callCtx, input)
     if err != nil {
         return nil, fmt.Errorf("failed to query alerts for device %s: %w", deviceID, err)
     }
@@ -148,7 +155,9 @@ func (store *AlertStore) GetAllAlerts(ctx context.Context, since int64) ([]model
 		},
 	}
 
-	res, err := store.Client.Scan(ctx, input)
+	callCtx, cancel := timeout.Call(ctx)
+	defer cancel()
+	res, err := store.Client.Scan(callCtx, input)
 	if err != nil {
 		return nil, fmt.Errorf("failed to scan all alerts: %w", err)
 	}
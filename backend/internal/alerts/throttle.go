@@ -0,0 +1,125 @@
+package alerts
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+
+	appconfig "github.com/Fleexa-Graduation-Project/Backend/pkg/config"
+	"github.com/Fleexa-Graduation-Project/Backend/pkg/db"
+	"github.com/Fleexa-Graduation-Project/Backend/pkg/metrics"
+	"github.com/Fleexa-Graduation-Project/Backend/pkg/timeout"
+)
+
+// repeats of an alert within this long after one went out are suppressed
+const DefaultThrottleWindow = 15 * time.Minute
+
+// ThrottleWindow is ALERT_THROTTLE_WINDOW, DefaultThrottleWindow when unset or invalid
+func ThrottleWindow() time.Duration {
+	if parsed, err := time.ParseDuration(os.Getenv("ALERT_THROTTLE_WINDOW")); err == nil && parsed > 0 {
+		return parsed
+	}
+	return DefaultThrottleWindow
+}
+
+// Throttle suppresses floods of the same alert. One marker per device and alert type in
+// DYNAMODB_ALERT_THROTTLE_TABLE holds the quiet period after the last alert that went out and
+// counts the repeats held back since
+type Throttle struct {
+	Client    *dynamodb.Client
+	TableName string
+	Window    time.Duration
+}
+
+func NewThrottle() (*Throttle, error) {
+	tableName := appconfig.TableName("DYNAMODB_ALERT_THROTTLE_TABLE")
+	if tableName == "" {
+		return nil, fmt.Errorf("DYNAMODB_ALERT_THROTTLE_TABLE environment variable is not set")
+	}
+
+	if db.Client == nil {
+		return nil, fmt.Errorf("dynamodb client is not initialized")
+	}
+
+	return &Throttle{
+		Client:    db.Client,
+		TableName: tableName,
+		Window:    ThrottleWindow(),
+	}, nil
+}
+
+// Allow reports whether an alert of the type for the device goes out. It does when the last
+// one is older than Window, or was raised for another episode: the state it describes, like
+// the last_seen_at of an outage or the severity of a gas reading, so a new outage or a level
+// turning critical is never held back. suppressed is how many repeats were held back before
+// this one, for the alert to mention. A nil *Throttle allows everything
+func (throttle *Throttle) Allow(ctx context.Context, deviceID, alertType, episode string) (allowed bool, suppressed int, err error) {
+	if throttle == nil {
+		return true, 0, nil
+	}
+
+	now := time.Now()
+	quietUntil := now.Add(throttle.Window).Unix()
+	key := deviceID + "#" + alertType
+
+	callCtx, cancel := timeout.Call(ctx)
+	result, err := throttle.Client.PutItem(callCtx, &dynamodb.PutItemInput{
+		TableName: aws.String(throttle.TableName),
+		Item: map[string]types.AttributeValue{
+			"throttle_key": &types.AttributeValueMemberS{Value: key},
+			"episode":      &types.AttributeValueMemberS{Value: episode},
+			"quiet_until":  &types.AttributeValueMemberN{Value: strconv.FormatInt(quietUntil, 10)},
+			"suppressed":   &types.AttributeValueMemberN{Value: "0"},
+			// kept a window past the quiet period, the next alert still reads the count
+			"expires_at": &types.AttributeValueMemberN{Value: strconv.FormatInt(quietUntil+int64(throttle.Window.Seconds()), 10)},
+		},
+		ConditionExpression: aws.String("attribute_not_exists(throttle_key) OR quiet_until < :now OR episode <> :episode"),
+		ExpressionAttributeValues: map[string]types.AttributeValue{
+			":now":     &types.AttributeValueMemberN{Value: strconv.FormatInt(now.Unix(), 10)},
+			":episode": &types.AttributeValueMemberS{Value: episode},
+		},
+		ReturnValues: types.ReturnValueAllOld,
+	})
+	cancel()
+	if err == nil {
+		return true, intAttr(result.Attributes, "suppressed"), nil
+	}
+	if !errors.Is(db.Condition(err), db.ErrConditionFailed) {
+		return true, 0, fmt.Errorf("failed to check alert throttle for %s: %w", key, err)
+	}
+
+	metrics.Count("AlertsSuppressed", 1, map[string]string{"AlertType": alertType})
+
+	// a failed count only makes the number in the next alert low, the repeat is still held back
+	callCtx, cancel = timeout.Call(ctx)
+	defer cancel()
+	if _, err := throttle.Client.UpdateItem(callCtx, &dynamodb.UpdateItemInput{
+		TableName: aws.String(throttle.TableName),
+		Key: map[string]types.AttributeValue{
+			"throttle_key": &types.AttributeValueMemberS{Value: key},
+		},
+		UpdateExpression: aws.String("ADD suppressed :one"),
+		ExpressionAttributeValues: map[string]types.AttributeValue{
+			":one": &types.AttributeValueMemberN{Value: "1"},
+		},
+	}); err != nil {
+		return false, 0, fmt.Errorf("failed to count suppressed alert for %s: %w", key, err)
+	}
+	return false, 0, nil
+}
+
+func intAttr(item map[string]types.AttributeValue, name string) int {
+	attr, ok := item[name].(*types.AttributeValueMemberN)
+	if !ok {
+		return 0
+	}
+	value, _ := strconv.Atoi(attr.Value)
+	return value
+}
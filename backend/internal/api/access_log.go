@@ -0,0 +1,42 @@
+package api
+
+import (
+	"log/slog"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/Fleexa-Graduation-Project/Backend/pkg/logger"
+	"github.com/gin-gonic/gin"
+)
+
+// AccessLogEnabled is on unless ACCESS_LOG_ENABLED=false
+func AccessLogEnabled() bool {
+	return !strings.EqualFold(strings.TrimSpace(os.Getenv("ACCESS_LOG_ENABLED")), "false")
+}
+
+// AccessLog writes one line per request once it completed, with the route template instead of
+// the raw path so the lines aggregate per endpoint. 5xx responses are logged at error level
+func AccessLog() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		start := time.Now()
+		c.Next()
+
+		status := c.Writer.Status()
+		level := slog.LevelInfo
+		if status >= http.StatusInternalServerError {
+			level = slog.LevelError
+		}
+
+		// RequestID ran below, its logger carries request_id
+		ctx := c.Request.Context()
+		logger.FromContext(ctx).Log(ctx, level, "request completed",
+			"method", c.Request.Method,
+			"path", routeOf(c),
+			"status", status,
+			"duration_ms", time.Since(start).Milliseconds(),
+			"bytes", c.Writer.Size(),
+		)
+	}
+}
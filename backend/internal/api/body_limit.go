@@ -0,0 +1,54 @@
+package api
+
+import (
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"os"
+	"strconv"
+
+	"github.com/Fleexa-Graduation-Project/Backend/pkg/httpresp"
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	DefaultMaxBodyBytes = 256 << 10
+	rawBodyKey          = "raw_body"
+)
+
+// MaxBodyBytes is the router wide limit, MAX_REQUEST_BODY_BYTES overrides the 256KB default
+func MaxBodyBytes() int64 {
+	raw := os.Getenv("MAX_REQUEST_BODY_BYTES")
+	if raw == "" {
+		return DefaultMaxBodyBytes
+	}
+
+	limit, err := strconv.ParseInt(raw, 10, 64)
+	if err != nil || limit <= 0 {
+		slog.Warn("invalid MAX_REQUEST_BODY_BYTES, falling back to default", "value", raw, "default", DefaultMaxBodyBytes)
+		return DefaultMaxBodyBytes
+	}
+	return limit
+}
+
+// BodyLimit rejects bodies over limit bytes with 413. Used once on the router and again on a
+// route to override it, the route limit wraps the original body so it can be raised as well as lowered
+func BodyLimit(limit int64) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if c.Request.ContentLength > limit {
+			httpresp.Error(c, http.StatusRequestEntityTooLarge, fmt.Sprintf("request body exceeds %d bytes", limit))
+			return
+		}
+
+		raw, ok := c.Get(rawBodyKey)
+		if !ok {
+			raw = c.Request.Body
+			c.Set(rawBodyKey, raw)
+		}
+
+		// chunked bodies have no content length, the reader enforces the limit while decoding
+		c.Request.Body = http.MaxBytesReader(c.Writer, raw.(io.ReadCloser), limit)
+		c.Next()
+	}
+}
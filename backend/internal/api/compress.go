@@ -0,0 +1,138 @@
+package api
+
+import (
+	"bytes"
+	"compress/gzip"
+	"log/slog"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+const DefaultGzipMinBytes = 1 << 10
+
+// GzipMinBytes is the smallest body worth compressing, RESPONSE_GZIP_MIN_BYTES overrides the
+// 1KB default and 0 turns compression off
+func GzipMinBytes() int {
+	raw := os.Getenv("RESPONSE_GZIP_MIN_BYTES")
+	if raw == "" {
+		return DefaultGzipMinBytes
+	}
+
+	limit, err := strconv.Atoi(raw)
+	if err != nil || limit < 0 {
+		slog.Warn("invalid RESPONSE_GZIP_MIN_BYTES, falling back to default", "value", raw, "default", DefaultGzipMinBytes)
+		return DefaultGzipMinBytes
+	}
+	return limit
+}
+
+// Gzip compresses bodies of at least minBytes for clients that accept gzip. The response is
+// buffered and the headers, Content-Type included, are kept. Compressed bytes are never valid
+// utf-8, so the lambda adapter base64 encodes them and sets IsBase64Encoded as the gateway needs
+func Gzip(minBytes int) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if minBytes <= 0 || !acceptsGzip(c.Request.Header.Get("Accept-Encoding")) {
+			c.Next()
+			return
+		}
+
+		original := c.Writer
+		buffered := &bufferedWriter{ResponseWriter: original, status: http.StatusOK}
+		c.Writer = buffered
+		c.Next()
+		c.Writer = original
+
+		body := buffered.body.Bytes()
+		header := original.Header()
+		header.Add("Vary", "Accept-Encoding")
+		if len(body) < minBytes || header.Get("Content-Encoding") != "" || !bodyAllowed(buffered.status) {
+			original.WriteHeader(buffered.status)
+			original.Write(body)
+			return
+		}
+
+		var compressed bytes.Buffer
+		writer := gzip.NewWriter(&compressed)
+		if _, err := writer.Write(body); err != nil || writer.Close() != nil {
+			slog.Warn("failed to gzip response, sending it uncompressed", "path", c.FullPath(), "error", err)
+			original.WriteHeader(buffered.status)
+			original.Write(body)
+			return
+		}
+
+		header.Set("Content-Encoding", "gzip")
+		header.Del("Content-Length")
+		original.WriteHeader(buffered.status)
+		original.Write(compressed.Bytes())
+	}
+}
+
+// true when the header lists gzip (or *) without q=0
+func acceptsGzip(acceptEncoding string) bool {
+	for _, part := range strings.Split(acceptEncoding, ",") {
+		coding, params, _ := strings.Cut(strings.TrimSpace(part), ";")
+		coding = strings.ToLower(strings.TrimSpace(coding))
+		if coding != "gzip" && coding != "*" {
+			continue
+		}
+		if value, ok := strings.CutPrefix(strings.TrimSpace(params), "q="); ok {
+			if q, err := strconv.ParseFloat(strings.TrimSpace(value), 64); err == nil && q == 0 {
+				continue
+			}
+		}
+		return true
+	}
+	return false
+}
+
+func bodyAllowed(status int) bool {
+	return status >= http.StatusOK && status != http.StatusNoContent && status != http.StatusNotModified
+}
+
+// bufferedWriter holds the status and body until the handlers are done, so the size is known
+// before anything is sent
+type bufferedWriter struct {
+	gin.ResponseWriter
+	body    bytes.Buffer
+	status  int
+	written bool
+}
+
+func (w *bufferedWriter) WriteHeader(status int) {
+	if !w.written {
+		w.status = status
+	}
+}
+
+func (w *bufferedWriter) WriteHeaderNow() {
+	w.written = true
+}
+
+func (w *bufferedWriter) Write(data []byte) (int, error) {
+	w.written = true
+	return w.body.Write(data)
+}
+
+func (w *bufferedWriter) WriteString(s string) (int, error) {
+	w.written = true
+	return w.body.WriteString(s)
+}
+
+func (w *bufferedWriter) Status() int {
+	return w.status
+}
+
+func (w *bufferedWriter) Size() int {
+	if !w.written {
+		return -1
+	}
+	return w.body.Len()
+}
+
+func (w *bufferedWriter) Written() bool {
+	return w.written
+}
@@ -0,0 +1,99 @@
+package api
+
+import (
+	"compress/gzip"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestGzip(t *testing.T) {
+	large := strings.Repeat(`{"device_id": "truck-1"}`, 100)
+
+	tests := []struct {
+		name           string
+		minBytes       int
+		acceptEncoding string
+		status         int
+		body           string
+		encoded        bool // the handler set its own Content-Encoding
+		wantGzip       bool
+	}{
+		{name: "large body", minBytes: 1024, acceptEncoding: "gzip, deflate", status: http.StatusOK, body: large, wantGzip: true},
+		{name: "error bodies too", minBytes: 1024, acceptEncoding: "gzip", status: http.StatusBadRequest, body: large, wantGzip: true},
+		{name: "under the threshold", minBytes: 1024, acceptEncoding: "gzip", status: http.StatusOK, body: `{"ok": true}`},
+		{name: "client without gzip", minBytes: 1024, acceptEncoding: "deflate", status: http.StatusOK, body: large},
+		{name: "gzip refused with q=0", minBytes: 1024, acceptEncoding: "gzip;q=0, deflate", status: http.StatusOK, body: large},
+		{name: "wildcard", minBytes: 1024, acceptEncoding: "*", status: http.StatusOK, body: large, wantGzip: true},
+		{name: "compression off", minBytes: 0, acceptEncoding: "gzip", status: http.StatusOK, body: large},
+		{name: "already encoded", minBytes: 1024, acceptEncoding: "gzip", status: http.StatusOK, body: large, encoded: true},
+		{name: "no content", minBytes: 1, acceptEncoding: "gzip", status: http.StatusNoContent},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			router := gin.New()
+			router.Use(Gzip(test.minBytes))
+			router.GET("/", func(c *gin.Context) {
+				if test.encoded {
+					c.Header("Content-Encoding", "br")
+				}
+				c.Data(test.status, "application/json", []byte(test.body))
+			})
+
+			request := httptest.NewRequest(http.MethodGet, "/", nil)
+			request.Header.Set("Accept-Encoding", test.acceptEncoding)
+			recorder := httptest.NewRecorder()
+			router.ServeHTTP(recorder, request)
+
+			if recorder.Code != test.status {
+				t.Errorf("status = %d, want %d", recorder.Code, test.status)
+			}
+			if got := recorder.Header().Get("Content-Type"); test.body != "" && got != "application/json" {
+				t.Errorf("Content-Type = %q, want application/json", got)
+			}
+
+			body := recorder.Body.String()
+			if gzipped := recorder.Header().Get("Content-Encoding") == "gzip"; gzipped != test.wantGzip {
+				t.Fatalf("gzipped = %v, want %v", gzipped, test.wantGzip)
+			}
+			if test.wantGzip {
+				reader, err := gzip.NewReader(recorder.Body)
+				if err != nil {
+					t.Fatalf("gzip.NewReader: %v", err)
+				}
+				decompressed, err := io.ReadAll(reader)
+				if err != nil {
+					t.Fatalf("read gzip body: %v", err)
+				}
+				body = string(decompressed)
+			}
+			if body != test.body {
+				t.Errorf("body = %.40q..., want %.40q...", body, test.body)
+			}
+		})
+	}
+}
+
+func TestGzipMinBytes(t *testing.T) {
+	tests := []struct {
+		raw  string
+		want int
+	}{
+		{raw: "", want: DefaultGzipMinBytes},
+		{raw: "4096", want: 4096},
+		{raw: "0", want: 0},
+		{raw: "-1", want: DefaultGzipMinBytes},
+		{raw: "1KB", want: DefaultGzipMinBytes},
+	}
+	for _, test := range tests {
+		t.Setenv("RESPONSE_GZIP_MIN_BYTES", test.raw)
+		if got := GzipMinBytes(); got != test.want {
+			t.Errorf("GzipMinBytes() with %q = %d, want %d", test.raw, got, test.want)
+		}
+	}
+}
@@ -0,0 +1,52 @@
+package api
+
+import (
+	"net/http"
+	"os"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	corsAllowMethods = "GET, POST, PUT, PATCH, DELETE, OPTIONS"
+	corsAllowHeaders = "Authorization, Content-Type, X-Request-ID, Idempotency-Key"
+	// read by the dashboard: request ids for support, when to retry a 429/503, replayed writes
+	corsExposeHeaders = "X-Request-ID, Retry-After, Idempotent-Replayed"
+)
+
+// CORS lets the web dashboard call the api, CORS_ALLOWED_ORIGINS is a comma separated list
+// ("*" allows any origin, meant for dev). Disallowed origins just don't get the allow header
+func CORS() gin.HandlerFunc {
+	allowed := map[string]bool{}
+	for _, origin := range strings.Split(os.Getenv("CORS_ALLOWED_ORIGINS"), ",") {
+		if origin = strings.TrimSpace(origin); origin != "" {
+			allowed[origin] = true
+		}
+	}
+	allowAll := allowed["*"]
+
+	return func(c *gin.Context) {
+		origin := c.GetHeader("Origin")
+
+		if origin != "" && (allowAll || allowed[origin]) {
+			if allowAll {
+				c.Header("Access-Control-Allow-Origin", "*")
+			} else {
+				c.Header("Access-Control-Allow-Origin", origin)
+				c.Header("Vary", "Origin")
+			}
+			c.Header("Access-Control-Allow-Methods", corsAllowMethods)
+			c.Header("Access-Control-Allow-Headers", corsAllowHeaders)
+			c.Header("Access-Control-Expose-Headers", corsExposeHeaders)
+		}
+
+		// preflight requests carry no token, answer them before auth runs
+		if c.Request.Method == http.MethodOptions {
+			c.AbortWithStatus(http.StatusNoContent)
+			return
+		}
+
+		c.Next()
+	}
+}
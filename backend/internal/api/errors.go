@@ -0,0 +1,19 @@
+package api
+
+import (
+	"github.com/gin-gonic/gin"
+
+	"github.com/Fleexa-Graduation-Project/Backend/pkg/apierr"
+)
+
+// HandlerFunc is a handler that reports failures by returning an error instead of writing them
+type HandlerFunc func(*gin.Context) error
+
+// Handle adapts a HandlerFunc for the router, a returned error is rendered by apierr.Render
+func Handle(handler HandlerFunc) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if err := handler(c); err != nil {
+			apierr.Render(c, err)
+		}
+	}
+}
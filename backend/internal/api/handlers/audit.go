@@ -0,0 +1,66 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/Fleexa-Graduation-Project/Backend/internal/auth"
+	"github.com/Fleexa-Graduation-Project/Backend/pkg/apierr"
+	"github.com/Fleexa-Graduation-Project/Backend/pkg/audit"
+	"github.com/Fleexa-Graduation-Project/Backend/pkg/httpresp"
+	"github.com/Fleexa-Graduation-Project/Backend/pkg/logger"
+	"github.com/Fleexa-Graduation-Project/Backend/pkg/metrics"
+	"github.com/gin-gonic/gin"
+)
+
+// records who ran a state-changing operation and whether it worked. A failed audit write is
+// logged and counted but never changes the handler's response
+func (handler *DeviceHandler) audit(context *gin.Context, action, resource string, opErr error) {
+	ctx := context.Request.Context()
+	claims, _ := auth.FromContext(ctx)
+
+	event := audit.AuditEvent{
+		Resource:  resource,
+		Actor:     claims.UserID,
+		Role:      claims.Role,
+		Action:    action,
+		Outcome:   audit.OutcomeSuccess,
+		RequestID: context.Writer.Header().Get("X-Request-ID"),
+	}
+	if opErr != nil {
+		event.Outcome = audit.OutcomeFailure
+		event.Reason = opErr.Error()
+	}
+
+	if err := handler.AuditStore.Record(ctx, event); err != nil {
+		logger.FromContext(ctx).Error("audit write failed", "reason", "audit_failed", "action", action, "resource", resource, "actor", claims.UserID, "outcome", event.Outcome, "error", err)
+		metrics.Count("AuditWriteFailures", 1, nil)
+	}
+}
+
+// handling GET /audit?resource=device:<id>&limit= (admin only)
+func (handler *DeviceHandler) GetAuditEvents(context *gin.Context) error {
+	resource := strings.TrimSpace(context.Query("resource"))
+	if resource == "" {
+		return apierr.BadRequest("resource is required")
+	}
+
+	limit := audit.DefaultQueryLimit
+	if raw := context.Query("limit"); raw != "" {
+		parsed, err := strconv.Atoi(raw)
+		if err != nil || parsed <= 0 {
+			return apierr.BadRequest("limit must be a positive number")
+		}
+		limit = parsed
+	}
+
+	events, err := handler.AuditStore.Recent(context.Request.Context(), resource, limit)
+	if err != nil {
+		return fmt.Errorf("failed to fetch audit events of %s: %w", resource, err)
+	}
+
+	httpresp.JSON(context, http.StatusOK, gin.H{"data": events})
+	return nil
+}
@@ -0,0 +1,106 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/Fleexa-Graduation-Project/Backend/internal/auth"
+	"github.com/Fleexa-Graduation-Project/Backend/internal/devices"
+	"github.com/Fleexa-Graduation-Project/Backend/internal/telemetry"
+	"github.com/Fleexa-Graduation-Project/Backend/pkg/apierr"
+	"github.com/Fleexa-Graduation-Project/Backend/pkg/audit"
+	"github.com/Fleexa-Graduation-Project/Backend/pkg/httpresp"
+	"github.com/Fleexa-Graduation-Project/Backend/pkg/logger"
+	"github.com/gin-gonic/gin"
+)
+
+// the most readings a delete purges inside the request, more need async=true
+const syncPurgeLimit = 1000
+
+type deleteDeviceResponse struct {
+	DeviceID         string `json:"device_id"`
+	TelemetryDeleted int    `json:"telemetry_deleted"`
+	TelemetryPurge   string `json:"telemetry_purge,omitempty"` // "queued" when handed to the purge lambda
+}
+
+// handling DELETE /devices/:id?purge_telemetry=true&async=true (admin only). Removes the registry
+// record with the state, latest state and shadow of the device, and its readings when asked
+func (handler *DeviceHandler) DeleteDevice(context *gin.Context) error {
+	ctx := context.Request.Context()
+	deviceID := context.Param("id")
+	purge := context.Query("purge_telemetry") == "true"
+	async := context.Query("async") == "true"
+	if async && !purge {
+		return apierr.BadRequest("async only applies with purge_telemetry=true")
+	}
+	if async && handler.PurgeQueue == nil {
+		return apierr.New(http.StatusServiceUnavailable, "purge_unavailable", "Asynchronous telemetry purge is not configured")
+	}
+
+	device, err := handler.DeviceStore.GetDevice(ctx, deviceID)
+	if err != nil {
+		return fmt.Errorf("failed to fetch device %s for delete: %w", deviceID, err)
+	}
+	if device == nil {
+		return apierr.NotFound("Device not found")
+	}
+
+	response := deleteDeviceResponse{DeviceID: deviceID}
+	status := http.StatusOK
+	err = handler.deleteDevice(context, deviceID, purge, async, &response)
+	handler.audit(context, "device.delete", audit.Resource("device", deviceID), err)
+	if err != nil {
+		return err
+	}
+	if response.TelemetryPurge != "" {
+		status = http.StatusAccepted
+	}
+
+	logger.FromContext(ctx).Info("device deleted", "device_id", deviceID, "telemetry_deleted", response.TelemetryDeleted, "telemetry_purge", response.TelemetryPurge)
+	httpresp.JSON(context, status, response)
+	return nil
+}
+
+// the readings and secondary items go before the registry record, so a delete that fails half
+// way leaves a device that can still be found and deleted again
+func (handler *DeviceHandler) deleteDevice(context *gin.Context, deviceID string, purge, async bool, response *deleteDeviceResponse) error {
+	ctx := context.Request.Context()
+
+	switch {
+	case purge && async:
+		claims, _ := auth.FromContext(ctx)
+		if err := handler.PurgeQueue.Enqueue(ctx, telemetry.PurgeRequest{DeviceID: deviceID, RequestedBy: claims.UserID}); err != nil {
+			return err
+		}
+		response.TelemetryPurge = "queued"
+	case purge:
+		count, err := handler.TelemetryStore.CountReadings(ctx, deviceID, syncPurgeLimit+1)
+		if err != nil {
+			return err
+		}
+		if count > syncPurgeLimit {
+			return apierr.New(http.StatusConflict, "purge_too_large", fmt.Sprintf("device has more than %d readings, delete it with async=true", syncPurgeLimit))
+		}
+		if response.TelemetryDeleted, _, err = handler.TelemetryStore.DeleteReadings(ctx, deviceID, 0, ""); err != nil {
+			return err
+		}
+	}
+
+	if err := handler.StateStore.DeleteState(ctx, deviceID); err != nil {
+		return err
+	}
+	if err := handler.LatestStore.Delete(ctx, deviceID); err != nil {
+		return err
+	}
+	if err := handler.ShadowStore.Delete(ctx, deviceID); err != nil {
+		return err
+	}
+
+	err := handler.DeviceStore.DeleteDevice(ctx, deviceID)
+	if errors.Is(err, devices.ErrDeviceNotFound) {
+		// deleted by a concurrent request
+		return apierr.NotFound("Device not found")
+	}
+	return err
+}
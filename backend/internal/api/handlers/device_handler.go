@@ -1,30 +1,55 @@
 package handlers
 
 import (
-    "log/slog"
     "net/http"
     "time"
     "fmt"
+	"slices"
 	"sort"
     "context"
 	
 
     "github.com/Fleexa-Graduation-Project/Backend/internal/devices"
+    "github.com/Fleexa-Graduation-Project/Backend/internal/fleets"
+    "github.com/Fleexa-Graduation-Project/Backend/internal/geofences"
     "github.com/Fleexa-Graduation-Project/Backend/internal/telemetry"
     "github.com/Fleexa-Graduation-Project/Backend/models"
 	"github.com/Fleexa-Graduation-Project/Backend/internal/alerts"
+    "github.com/Fleexa-Graduation-Project/Backend/internal/auth"
     "github.com/Fleexa-Graduation-Project/Backend/internal/commands"
+    "github.com/Fleexa-Graduation-Project/Backend/internal/ota"
+    "github.com/Fleexa-Graduation-Project/Backend/internal/provisioning"
+    "github.com/Fleexa-Graduation-Project/Backend/internal/rollups"
+    "github.com/Fleexa-Graduation-Project/Backend/internal/shadows"
+    "github.com/Fleexa-Graduation-Project/Backend/internal/trips"
 	"github.com/Fleexa-Graduation-Project/Backend/internal/iot"
+    "github.com/Fleexa-Graduation-Project/Backend/pkg/apierr"
+    "github.com/Fleexa-Graduation-Project/Backend/pkg/audit"
+    appconfig "github.com/Fleexa-Graduation-Project/Backend/pkg/config"
+    "github.com/Fleexa-Graduation-Project/Backend/pkg/httpreq"
+    "github.com/Fleexa-Graduation-Project/Backend/pkg/httpresp"
+    "github.com/Fleexa-Graduation-Project/Backend/pkg/logger"
     "github.com/gin-gonic/gin"
 )
 
 type DeviceHandler struct {
     StateStore     *devices.StateStore
-    TelemetryStore *telemetry.TelemetryStore
+    DeviceStore    devices.Registry
+    TelemetryStore telemetry.Store
     AlertStore     *alerts.AlertStore
     CommandStore   *commands.CommandStore 
     IoTPublisher   *iot.Publisher
     S3Fetcher      *iot.S3Client
+    OTATargets     ota.Targets
+    FleetStats     *fleets.StatsService
+    AuditStore     *audit.Store
+    BreachStore    *geofences.BreachStore
+    ProvisioningStore *provisioning.TokenStore
+    HourlyStore    *rollups.HourlyStore // long range telemetry queries are served from it
+    ShadowStore    *shadows.ShadowStore
+    LatestStore    *devices.LatestStore
+    PurgeQueue     *telemetry.PurgeQueue // nil disables async telemetry purges
+    DrivingEventStore *trips.EventStore
 }
 
 type SendCommandRequest struct {
@@ -32,6 +57,11 @@ type SendCommandRequest struct {
 	Parameters map[string]interface{} `json:"parameters"`
 }
 
+type GetCommandRequest struct {
+	DeviceID  string `uri:"id" json:"-"`
+	CommandID string `uri:"command_id" json:"-"`
+}
+
 
 func addLightStatus(payload map[string]interface{}, operationalState string) {
     switch operationalState {
@@ -57,12 +87,30 @@ func addTempStats(response gin.H, data []models.Telemetry, metric, deviceID stri
 	*/
 
 
-// handling GET /devices
+// handling GET /devices (?fleet_id=&limit=&cursor= lists the registered devices of a fleet)
 func (handler *DeviceHandler) GetDevices(context *gin.Context) {
-	
+    if fleetID := context.Query("fleet_id"); fleetID != "" {
+        handler.listFleetDevices(context, fleetID)
+        return
+    }
+    // tags are looked up within a fleet, scoped callers default to their own
+    if context.Query("tag") != "" {
+        fleetID, scoped := auth.FleetFromContext(context.Request.Context())
+        if !scoped {
+            httpresp.Error(context, http.StatusBadRequest, "fleet_id is required to filter by tag")
+            return
+        }
+        handler.listFleetDevices(context, fleetID)
+        return
+    }
+
     states, err := handler.StateStore.GetAllStates(context.Request.Context())
     if err != nil {
-        context.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch device states"})
+        internalError(context, err, "Failed to fetch device states")
+        return
+    }
+    if states, err = handler.scopeStates(context.Request.Context(), states); err != nil {
+        internalError(context, err, "Failed to fetch device states")
         return
     }
     for i := range states {
@@ -71,7 +119,7 @@ func (handler *DeviceHandler) GetDevices(context *gin.Context) {
             addLightStatus(states[i].Payload, states[i].OperationalState)
         }
     }
-    context.JSON(http.StatusOK, gin.H{"data": states})
+    httpresp.JSON(context, http.StatusOK, gin.H{"data": states})
 }
 //GET /alerts (notifications for all devices)
 func (handler *DeviceHandler) GetSortedAlerts(context *gin.Context) {
@@ -80,15 +128,23 @@ func (handler *DeviceHandler) GetSortedAlerts(context *gin.Context) {
 
 	alertList, err := handler.AlertStore.GetAllAlerts(context.Request.Context(), cutoff)
 	if err != nil {
-		context.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch global alerts"})
+		internalError(context, err, "Failed to fetch global alerts")
+		return
+	}
+	allowed, err := handler.fleetDevices(context.Request.Context())
+	if err != nil {
+		internalError(context, err, "Failed to fetch global alerts")
 		return
 	}
+	if allowed != nil {
+		alertList = slices.DeleteFunc(alertList, func(alert models.Alert) bool { return !allowed[alert.DeviceID] })
+	}
 
 	sort.Slice(alertList, func(i, j int) bool {
 		return alertList[i].Timestamp > alertList[j].Timestamp
 	})
 
-	context.JSON(http.StatusOK, gin.H{"data": alertList})
+	httpresp.JSON(context, http.StatusOK, gin.H{"data": alertList})
 }
 
 // showing last 5 Recent Events with its time - the Last Activity time - warning and alerts based on unlock time
@@ -186,16 +242,38 @@ func (handler *DeviceHandler) showACStats(ctx context.Context, payload map[strin
 
 // handling GET /devices/:id
 func (handler *DeviceHandler) GetDeviceByID(context *gin.Context) {
+    log := logger.FromContext(context.Request.Context())
     deviceID := context.Param("id")
+    if !handler.deviceScoped(context, deviceID) {
+        return
+    }
 
     state, err := handler.StateStore.GetStateByID(context.Request.Context(), deviceID)
     if err != nil {
-        context.JSON(http.StatusInternalServerError, gin.H{"error": "Internal server error"})
+        internalError(context, err, "Internal server error")
         return
     }
 
     if state == nil {
-        context.JSON(http.StatusNotFound, gin.H{"error": "Device not found"})
+        // registered devices that never reported yet have no state
+        device, regErr := handler.DeviceStore.GetDevice(context.Request.Context(), deviceID)
+        if regErr != nil {
+            log.Error("failed to fetch device registration", "device_id", deviceID, "error", regErr)
+            internalError(context, regErr, "Internal server error")
+            return
+        }
+        if device == nil {
+            httpresp.Error(context, http.StatusNotFound, "Device not found")
+            return
+        }
+        httpresp.JSON(context, http.StatusOK, models.DeviceState{
+            DeviceID:         device.DeviceID,
+            Type:             device.Model,
+            Status:           "OFFLINE",
+            OperationalState: "UNKNOWN",
+            Health:           "UNKNOWN",
+            Payload:          map[string]interface{}{},
+        })
         return
     }
 
@@ -208,13 +286,13 @@ func (handler *DeviceHandler) GetDeviceByID(context *gin.Context) {
 		//get the 5 most recent events
 		recentHistory, dbErr := handler.TelemetryStore.GetTelemetryHistory(context.Request.Context(), deviceID, 5, 0)
 		if dbErr != nil {
-			slog.Warn("failed to fetch recent door history", "device_id", deviceID, "error", dbErr)
+			log.Warn("failed to fetch recent door history", "device_id", deviceID, "error", dbErr)
 		}
 		showDoorStats(state.Payload, recentHistory, now)
 		cutoff24h := now - 86400
 		history24h, dbErr := handler.TelemetryStore.GetTelemetryHistory(context.Request.Context(), deviceID, 0, cutoff24h)
 		if dbErr != nil {
-			slog.Warn("failed to fetch 24h door history", "device_id", deviceID, "error", dbErr)
+			log.Warn("failed to fetch 24h door history", "device_id", deviceID, "error", dbErr)
 		} else {
 			addDoorInsights(state.Payload, history24h, state, now)
 		}
@@ -223,7 +301,7 @@ func (handler *DeviceHandler) GetDeviceByID(context *gin.Context) {
 		now := time.Now().Unix()
 		recentHistory, dbErr := handler.TelemetryStore.GetTelemetryHistory(context.Request.Context(), deviceID, 5, 0)
 		if dbErr != nil {
-			slog.Warn("failed to fetch recent AC history", "device_id", deviceID, "error", dbErr)
+			log.Warn("failed to fetch recent AC history", "device_id", deviceID, "error", dbErr)
 		} else if len(recentHistory) > 0 {
 			state.Payload["recent_events"] = telemetry.FormatACEvents(recentHistory)
 		}
@@ -235,7 +313,7 @@ func (handler *DeviceHandler) GetDeviceByID(context *gin.Context) {
 		cutoff24h := now - 86400
 		recentHistory, dbErr := handler.TelemetryStore.GetTelemetryHistory(context.Request.Context(), deviceID, 0, cutoff24h)
 		if dbErr != nil {
-			slog.Warn("failed to fetch recent temp history", "device_id", deviceID, "error", dbErr)
+			log.Warn("failed to fetch recent temp history", "device_id", deviceID, "error", dbErr)
 		} else {
 			stats, _ := telemetry.CalculateTempState(recentHistory, "temp", now)
 			state.Payload["Min"] = stats.Min
@@ -247,30 +325,39 @@ func (handler *DeviceHandler) GetDeviceByID(context *gin.Context) {
 	if state.Type == "gas-sensor" {
 		recentHistory, dbErr := handler.TelemetryStore.GetTelemetryHistory(context.Request.Context(), deviceID, 50, 0)
 		if dbErr != nil {
-			slog.Warn("failed to fetch recent gas history", "device_id", deviceID, "error", dbErr)
+			log.Warn("failed to fetch recent gas history", "device_id", deviceID, "error", dbErr)
 		} else if len(recentHistory) > 0 {
 			state.Payload["recent_events"] = telemetry.GetGasEvents(recentHistory)
 		}
 	}
 	
 
-    context.JSON(http.StatusOK, state)
+    httpresp.JSON(context, http.StatusOK, state)
 }
 
 // handling GET /devices/:id/telemetry?period=...&metric=...
 func (handler *DeviceHandler) GetDeviceTelemetry(context *gin.Context) {
+    log := logger.FromContext(context.Request.Context())
     deviceID := context.Param("id")
+    if !handler.deviceScoped(context, deviceID) {
+        return
+    }
     period := context.DefaultQuery("period", "24h")
     metric := context.DefaultQuery("metric", "temp")
 
     now := time.Now().Unix()
     state, err := handler.StateStore.GetStateByID(context.Request.Context(), deviceID)
     if err != nil {
-        context.JSON(http.StatusInternalServerError, gin.H{"error": "Internal server error"})
+        internalError(context, err, "Internal server error")
         return
     }
     if state == nil {
-        context.JSON(http.StatusNotFound, gin.H{"error": "Device not found"})
+        httpresp.Error(context, http.StatusNotFound, "Device not found")
+        return
+    }
+
+    if wantsRangeQuery(context) {
+        handler.queryTelemetryRange(context, deviceID)
         return
     }
 
@@ -284,8 +371,8 @@ func (handler *DeviceHandler) GetDeviceTelemetry(context *gin.Context) {
         cutoff := telemetry.PeriodCutoff(now, period)
         rawData, dbErr := handler.TelemetryStore.GetTelemetryHistory(context.Request.Context(), deviceID, 0, cutoff)
         if dbErr != nil {
-            context.JSON(http.StatusInternalServerError, gin.H{"error": fmt.Sprintf("failed to fetch telemetry history: device_id=%s, period=%s, error=%v", deviceID, period, dbErr),
-            })
+            log.Error("failed to fetch telemetry history", "device_id", deviceID, "period", period, "error", dbErr)
+            internalError(context, dbErr, "Failed to fetch telemetry history")
             return
         }
 
@@ -313,7 +400,7 @@ func (handler *DeviceHandler) GetDeviceTelemetry(context *gin.Context) {
             s3Key := fmt.Sprintf("processed-charts/%s/%s.json", deviceID, currentMonth)
             s3Data, err := handler.S3Fetcher.GetMonthlyChart(context.Request.Context(), s3Key)  //download json file from s3        
             if err != nil {
-                slog.Warn("failed to fetch monthly S3 chart", "device_id", deviceID, "error", err)
+                log.Warn("failed to fetch monthly S3 chart", "device_id", deviceID, "error", err)
                 response["data"] = []telemetry.ChartPoint{} //to not cause app crash return an empty array
             } else {
                 response["data"] = s3Data // The pre-calculated array from Python!
@@ -324,28 +411,31 @@ func (handler *DeviceHandler) GetDeviceTelemetry(context *gin.Context) {
        
 }
 
-    context.JSON(http.StatusOK, response)
+    httpresp.JSON(context, http.StatusOK, response)
 }
 
 func (handler *DeviceHandler) GetDeviceAlerts(context *gin.Context) {
     deviceID := context.Param("id")
+    if !handler.deviceScoped(context, deviceID) {
+        return
+    }
 
     state, err := handler.StateStore.GetStateByID(context.Request.Context(), deviceID)
     if err != nil {
-        context.JSON(http.StatusInternalServerError, gin.H{"error": "Internal server error"})
+        internalError(context, err, "Internal server error")
         return
     }
     if state == nil {
-        context.JSON(http.StatusNotFound, gin.H{"error": "Device not found"})
+        httpresp.Error(context, http.StatusNotFound, "Device not found")
         return
     }
 
     alertList, err := handler.AlertStore.GetAlertsByDevice(context.Request.Context(), deviceID, 0)
     if err != nil {
-        context.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch alerts"})
+        internalError(context, err, "Failed to fetch alerts")
         return
     }
-    context.JSON(http.StatusOK, gin.H{"data": alertList})
+    httpresp.JSON(context, http.StatusOK, gin.H{"data": alertList})
 }
 func isHotTier(period string) bool {
     switch period {
@@ -358,6 +448,7 @@ func isHotTier(period string) bool {
 
 //handling GET /system/overview
 func (handler *DeviceHandler) GetSystemOverview(context *gin.Context) {
+	log := logger.FromContext(context.Request.Context())
 	timeFilter := context.DefaultQuery("period", "7d") // default -> 7d
 	now := time.Now().Unix()
 	cutoff := telemetry.PeriodCutoff(now, timeFilter)
@@ -365,9 +456,17 @@ func (handler *DeviceHandler) GetSystemOverview(context *gin.Context) {
 	
 	states, err := handler.StateStore.GetAllStates(context.Request.Context())
 	if err != nil {
-		context.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch device states"})
+		internalError(context, err, "Failed to fetch device states")
 		return
 	}
+	allowed, err := handler.fleetDevices(context.Request.Context())
+	if err != nil {
+		internalError(context, err, "Failed to fetch device states")
+		return
+	}
+	if allowed != nil {
+		states = slices.DeleteFunc(states, func(state models.DeviceState) bool { return !allowed[state.DeviceID] })
+	}
 
 	onlineCount := 0
 	for _, state := range states {   //count how many online devices
@@ -383,7 +482,7 @@ func (handler *DeviceHandler) GetSystemOverview(context *gin.Context) {
     //get alerts
 	alertsList, err := handler.AlertStore.GetAllAlerts(context.Request.Context(), cutoff)
 	if err != nil {
-		slog.Warn("Failed to get alerts for system overview", "error", err)
+		log.Warn("Failed to get alerts for system overview", "error", err)
 	}
 	alertsChart := telemetry.GetAlerts(alertsList, timeFilter)
 	warningMax := telemetry.GetChartMax(alertsChart["warning"])
@@ -394,9 +493,12 @@ func (handler *DeviceHandler) GetSystemOverview(context *gin.Context) {
 	}
 
     //calculate Energy Consumption
-	acData, err := handler.TelemetryStore.GetTelemetryHistory(context.Request.Context(), "ac-01", 0, cutoff)  //ac name may be changed later
-	if err != nil {
-		slog.Warn("Failed to fetch AC telemetry for energy chart", "error", err)
+	var acData []models.Telemetry
+	if allowed == nil || allowed["ac-01"] {
+		acData, err = handler.TelemetryStore.GetTelemetryHistory(context.Request.Context(), "ac-01", 0, cutoff)  //ac name may be changed later
+		if err != nil {
+			log.Warn("Failed to fetch AC telemetry for energy chart", "error", err)
+		}
 	}
 	
 	acUsage := telemetry.CalculateACUsage(acData, now, timeFilter)
@@ -404,7 +506,7 @@ func (handler *DeviceHandler) GetSystemOverview(context *gin.Context) {
 	energyMax := telemetry.GetChartMax(energyData)
 
 
-	context.JSON(http.StatusOK, gin.H{
+	httpresp.JSON(context, http.StatusOK, gin.H{
 		"system_status":  systemStatus,
 		"devices_online": fmt.Sprintf("%d / %d", onlineCount, len(states)),
 		"alerts_chart":   alertsChart,
@@ -418,43 +520,112 @@ func (handler *DeviceHandler) GetSystemOverview(context *gin.Context) {
 
 //handling POST /devices/:id/commands
 func (handler *DeviceHandler) SendCommand(context *gin.Context) {
+	log := logger.FromContext(context.Request.Context())
 	deviceID := context.Param("id")
+	if !handler.deviceScoped(context, deviceID) {
+		return
+	}
 
 	var req SendCommandRequest
-	if err := context.ShouldBindJSON(&req); err != nil {
-		context.JSON(http.StatusBadRequest, gin.H{"error": "invalid command format! action is required."})
+	if !httpreq.DecodeBody(context, &req) {
+		return
+	}
+
+	state, err := handler.StateStore.GetStateByID(context.Request.Context(), deviceID)
+	if err != nil {
+		log.Error("failed to fetch device state for command", "device_id", deviceID, "error", err)
+		internalError(context, err, "Internal server error")
+		return
+	}
+	if state == nil {
+		httpresp.Error(context, http.StatusNotFound, "Device not found")
+		return
+	}
+
+	action := commands.NormalizeAction(req.Action)
+	if err := commands.ValidateAction(state.Type, action); err != nil {
+		httpresp.Error(context, http.StatusBadRequest, fmt.Sprintf("Action %s is not supported by %s devices", action, state.Type))
+		return
+	}
+
+	// a command sent to an offline device would sit in the broker and fire whenever it reconnects
+	if devices.ConnectionStatus(state.LastSeenAt) != "ONLINE" {
+		httpresp.Error(context, http.StatusConflict, "Device is offline")
 		return
 	}
 
 	requestID := fmt.Sprintf("cmd-%d", time.Now().UnixNano())
 	mqttPayload := map[string]interface{}{
 		"request_id": requestID,
-		"action":     req.Action,
+		"action":     action,
 		"parameters": req.Parameters,
 	}
 
-	topic := fmt.Sprintf("devices/%s/command", deviceID)
-	err := handler.IoTPublisher.Publish(context.Request.Context(), topic, mqttPayload)
-	if err != nil {
-		slog.Error("failed to publish command to iot Core", "device_id", deviceID, "error", err)
-		context.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to communicate with device"})
-		return
-	}
-
 	commandRecord := models.Command{
 		RequestID:  requestID,
 		DeviceID:   deviceID,
 		Timestamp:  time.Now().Unix(),
-		Action:     req.Action,
+		Action:     action,
 		Parameters: req.Parameters,
+		Status:     commands.StatusPending,
 	}
-	
-	if storeErr := handler.CommandStore.SaveCommand(context.Request.Context(), commandRecord); storeErr != nil {
-		slog.Warn("Command sent, but failed to save history to DB", "error", storeErr)
+
+	// saved before publishing, an ack that beats the write would otherwise find no command
+	if err := handler.CommandStore.SaveCommand(context.Request.Context(), commandRecord); err != nil {
+		log.Error("failed to save command", "device_id", deviceID, "command_id", requestID, "error", err)
+		internalError(context, err, "Failed to save command")
+		return
 	}
 
-	context.JSON(http.StatusAccepted, gin.H{
+	topic := fmt.Sprintf("%s/%s/command", appconfig.Topic("devices"), deviceID)
+	err = handler.IoTPublisher.Publish(context.Request.Context(), topic, mqttPayload)
+	handler.audit(context, "command.send:"+action, audit.Resource("device", deviceID), err)
+	if err != nil {
+		log.Error("failed to publish command to iot Core", "device_id", deviceID, "command_id", requestID, "error", err)
+		if _, markErr := handler.CommandStore.MarkUndelivered(context.Request.Context(), requestID, "publish failed"); markErr != nil {
+			log.Warn("failed to mark undelivered command as failed", "command_id", requestID, "error", markErr)
+		}
+		internalError(context, err, "Failed to communicate with device")
+		return
+	}
+
+	httpresp.JSON(context, http.StatusAccepted, gin.H{
 		"message":    "Command dispatched successfully",
 		"request_id": requestID,
+		"status":     commands.StatusPending,
 	})
-}
\ No newline at end of file
+}
+
+//handling GET /devices/:id/commands/:command_id
+func (handler *DeviceHandler) GetCommand(ctx context.Context, req GetCommandRequest) (*models.Command, error) {
+	if err := handler.authorizeDevice(ctx, req.DeviceID); err != nil {
+		return nil, err
+	}
+
+	cmd, err := handler.CommandStore.GetCommand(ctx, req.CommandID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch command of device %s: %w", req.DeviceID, err)
+	}
+
+	// ids are global, don't leak another device's command through this path
+	if cmd == nil || cmd.DeviceID != req.DeviceID {
+		return nil, apierr.NotFound("Command not found")
+	}
+	return cmd, nil
+}
+
+//handling GET /devices/:id/ota
+func (handler *DeviceHandler) GetOTAStatus(context *gin.Context) error {
+	deviceID := context.Param("id")
+
+	device, err := handler.DeviceStore.GetDevice(context.Request.Context(), deviceID)
+	if err != nil {
+		return fmt.Errorf("failed to fetch device %s for ota check: %w", deviceID, err)
+	}
+	if device == nil || authorizeFleet(context.Request.Context(), device.FleetID) != nil {
+		return apierr.NotFound("Device not found")
+	}
+
+	httpresp.JSON(context, http.StatusOK, handler.OTATargets.Check(*device))
+	return nil
+}
@@ -0,0 +1,234 @@
+package handlers
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/Fleexa-Graduation-Project/Backend/internal/devices"
+	"github.com/Fleexa-Graduation-Project/Backend/models"
+	"github.com/Fleexa-Graduation-Project/Backend/pkg/apierr"
+	"github.com/Fleexa-Graduation-Project/Backend/pkg/audit"
+	"github.com/Fleexa-Graduation-Project/Backend/pkg/httpreq"
+	"github.com/Fleexa-Graduation-Project/Backend/pkg/httpresp"
+	"github.com/Fleexa-Graduation-Project/Backend/pkg/logger"
+	"github.com/gin-gonic/gin"
+)
+
+const DefaultMaxImportRows = 500
+
+// same fields as RegisterDeviceRequest without the binding tags, a bad row must not fail the
+// whole body
+type importDevice struct {
+	DeviceID string `json:"device_id"`
+	Name     string `json:"name"`
+	Model    string `json:"model"`
+	FleetID  string `json:"fleet_id"`
+
+	ReportInterval int64 `json:"report_interval_seconds"`
+}
+
+// Row is the index in the json array, or the line in the csv file (the header is line 1)
+type ImportRow struct {
+	Row      int    `json:"row"`
+	DeviceID string `json:"device_id"`
+	Status   string `json:"status"` // imported, skipped or failed
+	Error    string `json:"error,omitempty"`
+
+	Fields []apierr.FieldError `json:"fields,omitempty"` // set when the row failed validation
+}
+
+type ImportResponse struct {
+	Imported int         `json:"imported"`
+	Skipped  int         `json:"skipped"`
+	Failed   int         `json:"failed"`
+	Rows     []ImportRow `json:"rows"`
+}
+
+// MaxImportRows caps the devices of one import, DEVICE_IMPORT_MAX_ROWS overrides the default
+func MaxImportRows() int {
+	raw := os.Getenv("DEVICE_IMPORT_MAX_ROWS")
+	if raw == "" {
+		return DefaultMaxImportRows
+	}
+
+	limit, err := strconv.Atoi(raw)
+	if err != nil || limit <= 0 {
+		slog.Warn("invalid DEVICE_IMPORT_MAX_ROWS, falling back to default", "value", raw, "default", DefaultMaxImportRows)
+		return DefaultMaxImportRows
+	}
+	return limit
+}
+
+func errImportTooLarge(limit int) *apierr.APIError {
+	return apierr.New(http.StatusRequestEntityTooLarge, "too_many_rows", fmt.Sprintf("an import is limited to %d devices", limit))
+}
+
+// handling POST /devices/import, a json array of devices or a csv file (Content-Type: text/csv)
+// with a device_id,name,model,fleet_id header. Every row is reported on its own
+func (handler *DeviceHandler) ImportDevices(context *gin.Context) error {
+	limit := MaxImportRows()
+
+	var rows []importDevice
+	var lines []int
+	if context.ContentType() == "text/csv" {
+		var err error
+		if rows, lines, err = readImportCSV(context.Request.Body, limit); err != nil {
+			return err
+		}
+	} else {
+		if !httpreq.DecodeBody(context, &rows) {
+			return nil
+		}
+		for i := range rows {
+			lines = append(lines, i)
+		}
+	}
+
+	if len(rows) == 0 {
+		return apierr.BadRequest("no devices to import")
+	}
+	if len(rows) > limit {
+		return errImportTooLarge(limit)
+	}
+
+	response := ImportResponse{Rows: make([]ImportRow, len(rows))}
+	var valid []models.Device
+	var validRows []int
+	seen := map[string]bool{}
+	for i, row := range rows {
+		device, err := RegisterDeviceRequest(row).device(context.Request.Context())
+		response.Rows[i] = ImportRow{Row: lines[i], DeviceID: strings.TrimSpace(row.DeviceID)}
+
+		switch {
+		case err != nil:
+			var apiErr *apierr.APIError
+			var validationErr *apierr.ValidationError
+			switch {
+			case errors.As(err, &validationErr):
+				response.Rows[i].Error = validationErr.Message()
+				response.Rows[i].Fields = validationErr.Fields
+			case errors.As(err, &apiErr):
+				response.Rows[i].Error = apiErr.Message
+			}
+			response.Rows[i].Status = devices.ImportFailed
+		case seen[device.DeviceID]:
+			response.Rows[i].Status = devices.ImportFailed
+			response.Rows[i].Error = "device_id is repeated in the import"
+		default:
+			seen[device.DeviceID] = true
+			valid = append(valid, device)
+			validRows = append(validRows, i)
+		}
+	}
+
+	if len(valid) > 0 {
+		results, err := handler.DeviceStore.ImportDevices(context.Request.Context(), valid)
+		if err != nil {
+			return fmt.Errorf("failed to import %d devices: %w", len(valid), err)
+		}
+
+		for j, result := range results {
+			row := &response.Rows[validRows[j]]
+			row.Status = result.Status
+			if result.Err != nil {
+				logger.FromContext(context.Request.Context()).Error("failed to import device", "device_id", row.DeviceID, "row", row.Row, "error", result.Err)
+				row.Error = "Failed to register device"
+			}
+			if result.Status == devices.ImportImported {
+				handler.audit(context, "device.import", audit.Resource("device", row.DeviceID), nil)
+			}
+		}
+	}
+
+	for _, row := range response.Rows {
+		switch row.Status {
+		case devices.ImportImported:
+			response.Imported++
+		case devices.ImportSkipped:
+			response.Skipped++
+		default:
+			response.Failed++
+		}
+	}
+
+	logger.FromContext(context.Request.Context()).Info("devices imported", "imported", response.Imported, "skipped", response.Skipped, "failed", response.Failed)
+	httpresp.JSON(context, http.StatusOK, response)
+	return nil
+}
+
+// reads the csv rows and their line numbers, stops with 413 once the file has more than limit rows
+func readImportCSV(body io.Reader, limit int) ([]importDevice, []int, error) {
+	reader := csv.NewReader(body)
+	reader.TrimLeadingSpace = true
+
+	header, err := reader.Read()
+	if err != nil {
+		return nil, nil, csvError(err)
+	}
+
+	columns := map[string]int{}
+	for i, name := range header {
+		name = strings.ToLower(strings.TrimSpace(name))
+		switch name {
+		case "device_id", "name", "model", "fleet_id":
+			columns[name] = i
+		default:
+			return nil, nil, apierr.BadRequest(fmt.Sprintf("unknown csv column %q", name))
+		}
+	}
+	for _, required := range []string{"device_id", "name", "fleet_id"} {
+		if _, ok := columns[required]; !ok {
+			return nil, nil, apierr.BadRequest(fmt.Sprintf("csv header is missing %s", required))
+		}
+	}
+
+	column := func(record []string, name string) string {
+		if i, ok := columns[name]; ok {
+			return record[i]
+		}
+		return ""
+	}
+
+	var rows []importDevice
+	var lines []int
+	for {
+		record, err := reader.Read()
+		if errors.Is(err, io.EOF) {
+			return rows, lines, nil
+		}
+		if err != nil {
+			return nil, nil, csvError(err)
+		}
+		if len(rows) == limit {
+			return nil, nil, errImportTooLarge(limit)
+		}
+
+		line, _ := reader.FieldPos(0)
+		lines = append(lines, line)
+		rows = append(rows, importDevice{
+			DeviceID: column(record, "device_id"),
+			Name:     column(record, "name"),
+			Model:    column(record, "model"),
+			FleetID:  column(record, "fleet_id"),
+		})
+	}
+}
+
+func csvError(err error) error {
+	var tooLarge *http.MaxBytesError
+	switch {
+	case errors.As(err, &tooLarge):
+		return apierr.New(http.StatusRequestEntityTooLarge, "", fmt.Sprintf("request body exceeds %d bytes", tooLarge.Limit))
+	case errors.Is(err, io.EOF):
+		return apierr.BadRequest("request body is empty")
+	default:
+		return apierr.BadRequest("malformed csv: " + err.Error())
+	}
+}
@@ -0,0 +1,221 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/Fleexa-Graduation-Project/Backend/internal/devices"
+	"github.com/Fleexa-Graduation-Project/Backend/models"
+	"github.com/Fleexa-Graduation-Project/Backend/pkg/apierr"
+	"github.com/Fleexa-Graduation-Project/Backend/pkg/audit"
+	"github.com/Fleexa-Graduation-Project/Backend/pkg/db"
+	"github.com/Fleexa-Graduation-Project/Backend/pkg/httpreq"
+	"github.com/Fleexa-Graduation-Project/Backend/pkg/httpresp"
+	"github.com/Fleexa-Graduation-Project/Backend/pkg/logger"
+	"github.com/gin-gonic/gin"
+)
+
+type RegisterDeviceRequest struct {
+	DeviceID string `json:"device_id" binding:"required"`
+	Name     string `json:"name"`
+	Model    string `json:"model"`
+	FleetID  string `json:"fleet_id"`
+
+	ReportInterval int64 `json:"report_interval_seconds"` // optional, the default interval when 0
+}
+
+// the longest reporting interval a device can be configured with
+const maxReportInterval = 24 * 60 * 60
+
+// validates the request (also each row of an import) and turns it into the device to store
+func (req RegisterDeviceRequest) device(ctx context.Context) (models.Device, error) {
+	deviceID := strings.TrimSpace(req.DeviceID)
+	name := strings.TrimSpace(req.Name)
+	fleetID := strings.TrimSpace(req.FleetID)
+
+	verr := &apierr.ValidationError{}
+	if deviceID == "" {
+		verr.Add("device_id", "required")
+	}
+	if name == "" {
+		verr.Add("name", "required")
+	}
+	if fleetID == "" {
+		verr.Add("fleet_id", "required")
+	}
+	if _, known := devices.Rules[req.Model]; req.Model != "" && !known {
+		verr.Add("model", "unknown device model")
+	}
+	if req.ReportInterval < 0 || req.ReportInterval > maxReportInterval {
+		verr.Add("report_interval_seconds", fmt.Sprintf("must be between 0 and %d", maxReportInterval))
+	}
+	if err := verr.Err(); err != nil {
+		return models.Device{}, err
+	}
+
+	if err := authorizeFleet(ctx, fleetID); err != nil {
+		return models.Device{}, apierr.Forbidden("Cannot register devices in another fleet")
+	}
+
+	return models.Device{
+		DeviceID: deviceID,
+		Name:     name,
+		Model:    req.Model,
+		FleetID:  fleetID,
+
+		ReportInterval: req.ReportInterval,
+	}, nil
+}
+
+// handling POST /devices
+func (handler *DeviceHandler) RegisterDevice(context *gin.Context) error {
+	var req RegisterDeviceRequest
+	if !httpreq.DecodeBody(context, &req) {
+		return nil
+	}
+
+	device, err := req.device(context.Request.Context())
+	if err != nil {
+		return err
+	}
+
+	err = handler.DeviceStore.RegisterDevice(context.Request.Context(), device)
+	handler.audit(context, "device.register", audit.Resource("device", device.DeviceID), err)
+	if errors.Is(err, devices.ErrDeviceExists) {
+		return apierr.Conflict("Device already registered")
+	}
+	if err != nil {
+		return fmt.Errorf("failed to register device %s: %w", device.DeviceID, err)
+	}
+
+	logger.FromContext(context.Request.Context()).Info("device registered", "device_id", device.DeviceID, "fleet_id", device.FleetID)
+	httpresp.JSON(context, http.StatusCreated, device)
+	return nil
+}
+
+// handling PATCH /devices/:id, only the fields present in the body are changed
+func (handler *DeviceHandler) UpdateDevice(context *gin.Context) error {
+	deviceID := context.Param("id")
+	if err := handler.authorizeDevice(context.Request.Context(), deviceID); err != nil {
+		return err
+	}
+
+	var patch devices.DevicePatch
+	if !httpreq.DecodeBody(context, &patch) {
+		return nil
+	}
+	if patch.Empty() {
+		return apierr.BadRequest("name, fleet_id or report_interval_seconds is required")
+	}
+
+	verr := &apierr.ValidationError{}
+	if patch.Name != nil {
+		*patch.Name = strings.TrimSpace(*patch.Name)
+		if *patch.Name == "" {
+			verr.Add("name", "must not be blank")
+		}
+	}
+	if patch.FleetID != nil {
+		*patch.FleetID = strings.TrimSpace(*patch.FleetID)
+		if *patch.FleetID == "" {
+			verr.Add("fleet_id", "must not be blank")
+		}
+	}
+	if patch.ReportInterval != nil && (*patch.ReportInterval < 0 || *patch.ReportInterval > maxReportInterval) {
+		verr.Add("report_interval_seconds", fmt.Sprintf("must be between 0 and %d", maxReportInterval))
+	}
+	if err := verr.Err(); err != nil {
+		return err
+	}
+	if patch.FleetID != nil {
+		if err := authorizeFleet(context.Request.Context(), *patch.FleetID); err != nil {
+			return apierr.Forbidden("Cannot move devices to another fleet")
+		}
+	}
+
+	err := handler.DeviceStore.UpdateDevice(context.Request.Context(), deviceID, patch)
+	handler.audit(context, "device.update", audit.Resource("device", deviceID), err)
+	if errors.Is(err, devices.ErrDeviceNotFound) {
+		return apierr.NotFound("Device not found")
+	}
+	if err != nil {
+		return fmt.Errorf("failed to update device %s: %w", deviceID, err)
+	}
+
+	device, err := handler.DeviceStore.GetDevice(context.Request.Context(), deviceID)
+	if err != nil {
+		return fmt.Errorf("failed to fetch updated device %s: %w", deviceID, err)
+	}
+	if device == nil {
+		return apierr.NotFound("Device not found")
+	}
+
+	logger.FromContext(context.Request.Context()).Info("device updated", "device_id", deviceID, "fleet_id", device.FleetID)
+	httpresp.JSON(context, http.StatusOK, device)
+	return nil
+}
+
+// handling POST /fleets/:id/deactivate (admin only), used when a customer's contract ends
+func (handler *DeviceHandler) DeactivateFleet(context *gin.Context) error {
+	fleetID := strings.TrimSpace(context.Param("id"))
+	if fleetID == "" {
+		return apierr.BadRequest("fleet id is required")
+	}
+
+	updated, err := handler.DeviceStore.DeactivateFleet(context.Request.Context(), fleetID)
+	handler.audit(context, "fleet.deactivate", audit.Resource("fleet", fleetID), err)
+	if err != nil {
+		return fmt.Errorf("failed to deactivate fleet %s after %d devices: %w", fleetID, updated, err)
+	}
+
+	logger.FromContext(context.Request.Context()).Info("fleet deactivated", "fleet_id", fleetID, "devices_updated", updated)
+	httpresp.JSON(context, http.StatusOK, gin.H{"fleet_id": fleetID, "devices_updated": updated})
+	return nil
+}
+
+// paginated listing of a fleet, limit is clamped by the store. ?tag= narrows it to the
+// devices carrying the tag
+func (handler *DeviceHandler) listFleetDevices(context *gin.Context, fleetID string) {
+	if err := authorizeFleet(context.Request.Context(), fleetID); err != nil {
+		apierr.Render(context, err)
+		return
+	}
+
+	limit := devices.DefaultPageLimit
+	if raw := context.Query("limit"); raw != "" {
+		parsed, err := strconv.Atoi(raw)
+		if err != nil || parsed <= 0 {
+			httpresp.Error(context, http.StatusBadRequest, "limit must be a positive number")
+			return
+		}
+		limit = parsed
+	}
+
+	var list devices.DeviceList
+	var err error
+	if raw := context.Query("tag"); raw != "" {
+		tag, tagErr := devices.NormalizeTag(raw)
+		if tagErr != nil {
+			httpresp.Error(context, http.StatusBadRequest, tagErr.Error())
+			return
+		}
+		list, err = handler.DeviceStore.ListDevicesByTag(context.Request.Context(), fleetID, tag, limit, context.Query("cursor"))
+	} else {
+		list, err = handler.DeviceStore.ListDevices(context.Request.Context(), fleetID, limit, context.Query("cursor"))
+	}
+	if errors.Is(err, db.ErrInvalidCursor) {
+		httpresp.Error(context, http.StatusBadRequest, "Invalid cursor")
+		return
+	}
+	if err != nil {
+		logger.FromContext(context.Request.Context()).Error("failed to list fleet devices", "fleet_id", fleetID, "error", err)
+		internalError(context, err, "Failed to list devices")
+		return
+	}
+
+	httpresp.JSON(context, http.StatusOK, list)
+}
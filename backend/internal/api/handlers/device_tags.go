@@ -0,0 +1,51 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/Fleexa-Graduation-Project/Backend/internal/devices"
+	"github.com/Fleexa-Graduation-Project/Backend/models"
+	"github.com/Fleexa-Graduation-Project/Backend/pkg/apierr"
+	"github.com/Fleexa-Graduation-Project/Backend/pkg/audit"
+	"github.com/Fleexa-Graduation-Project/Backend/pkg/httpresp"
+	"github.com/gin-gonic/gin"
+)
+
+// handling PUT /devices/:id/tags/:tag, tagging a device twice is a no-op
+func (handler *DeviceHandler) AddDeviceTag(context *gin.Context) error {
+	return handler.changeTag(context, "device.tag.add", handler.DeviceStore.AddTag)
+}
+
+// handling DELETE /devices/:id/tags/:tag, removing a tag the device doesn't have is a no-op
+func (handler *DeviceHandler) RemoveDeviceTag(context *gin.Context) error {
+	return handler.changeTag(context, "device.tag.remove", handler.DeviceStore.RemoveTag)
+}
+
+type tagChange func(ctx context.Context, deviceID, tag string) (*models.Device, error)
+
+func (handler *DeviceHandler) changeTag(context *gin.Context, action string, change tagChange) error {
+	deviceID := context.Param("id")
+	if err := handler.authorizeDevice(context.Request.Context(), deviceID); err != nil {
+		return err
+	}
+
+	tag, err := devices.NormalizeTag(context.Param("tag"))
+	if err != nil {
+		return apierr.BadRequest(err.Error())
+	}
+
+	device, err := change(context.Request.Context(), deviceID, tag)
+	handler.audit(context, action+":"+tag, audit.Resource("device", deviceID), err)
+	if errors.Is(err, devices.ErrDeviceNotFound) {
+		return apierr.NotFound("Device not found")
+	}
+	if err != nil {
+		return fmt.Errorf("failed to change tag %s of device %s: %w", tag, deviceID, err)
+	}
+
+	httpresp.JSON(context, http.StatusOK, device)
+	return nil
+}
@@ -0,0 +1,41 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/Fleexa-Graduation-Project/Backend/pkg/apierr"
+	"github.com/Fleexa-Graduation-Project/Backend/pkg/httpresp"
+	"github.com/gin-gonic/gin"
+)
+
+// how far back the driving events go when since isn't given
+const defaultDrivingEventsWindow = 7 * 24 * time.Hour
+
+// handling GET /devices/:id/driving-events?since=<unix seconds>
+func (handler *DeviceHandler) GetDrivingEvents(context *gin.Context) error {
+	ctx := context.Request.Context()
+	deviceID := context.Param("id")
+	if err := handler.authorizeDevice(ctx, deviceID); err != nil {
+		return err
+	}
+
+	since := time.Now().Add(-defaultDrivingEventsWindow).Unix()
+	if raw := context.Query("since"); raw != "" {
+		parsed, err := strconv.ParseInt(raw, 10, 64)
+		if err != nil || parsed < 0 {
+			return apierr.BadRequest("since must be a unix timestamp in seconds")
+		}
+		since = parsed
+	}
+
+	events, err := handler.DrivingEventStore.ListEventsSince(ctx, deviceID, since)
+	if err != nil {
+		return fmt.Errorf("failed to fetch driving events of device %s: %w", deviceID, err)
+	}
+
+	httpresp.JSON(context, http.StatusOK, gin.H{"data": events})
+	return nil
+}
@@ -0,0 +1,29 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/Fleexa-Graduation-Project/Backend/pkg/breaker"
+	"github.com/Fleexa-Graduation-Project/Backend/pkg/httpresp"
+	"github.com/Fleexa-Graduation-Project/Backend/pkg/logger"
+	"github.com/Fleexa-Graduation-Project/Backend/pkg/timeout"
+)
+
+// failed store or iot call, a blown deadline answers 504 dependency_timeout and an open circuit
+// breaker 503 dependency_unavailable instead of a plain 500
+func internalError(context *gin.Context, err error, message string) {
+	if errors.Is(err, breaker.ErrOpen) {
+		logger.FromContext(context.Request.Context()).Error("dependency unavailable", "reason", "breaker_open", "path", context.FullPath(), "error", err)
+		httpresp.Error(context, http.StatusServiceUnavailable, "dependency_unavailable")
+		return
+	}
+	if timeout.IsTimeout(err) {
+		logger.FromContext(context.Request.Context()).Error("dependency call timed out", "reason", "dependency_timeout", "path", context.FullPath(), "error", err)
+		httpresp.Error(context, http.StatusGatewayTimeout, timeout.ErrDependencyTimeout.Error())
+		return
+	}
+	httpresp.Error(context, http.StatusInternalServerError, message)
+}
@@ -0,0 +1,30 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/Fleexa-Graduation-Project/Backend/pkg/apierr"
+	"github.com/Fleexa-Graduation-Project/Backend/pkg/httpresp"
+	"github.com/gin-gonic/gin"
+)
+
+// handling GET /fleets/:id/breaches, the devices currently outside one of their geofences
+func (handler *DeviceHandler) GetFleetBreaches(context *gin.Context) error {
+	fleetID := strings.TrimSpace(context.Param("id"))
+	if fleetID == "" {
+		return apierr.BadRequest("fleet id is required")
+	}
+	if err := authorizeFleet(context.Request.Context(), fleetID); err != nil {
+		return err
+	}
+
+	breaches, err := handler.BreachStore.ListOpen(context.Request.Context(), fleetID)
+	if err != nil {
+		return fmt.Errorf("failed to list breaches of fleet %s: %w", fleetID, err)
+	}
+
+	httpresp.JSON(context, http.StatusOK, gin.H{"data": breaches})
+	return nil
+}
@@ -0,0 +1,49 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/Fleexa-Graduation-Project/Backend/pkg/apierr"
+	"github.com/Fleexa-Graduation-Project/Backend/pkg/httpresp"
+	"github.com/gin-gonic/gin"
+)
+
+// handling GET /fleets/:id/stats (?refresh=true skips the cached value)
+func (handler *DeviceHandler) GetFleetStats(context *gin.Context) error {
+	fleetID := strings.TrimSpace(context.Param("id"))
+	if fleetID == "" {
+		return apierr.BadRequest("fleet id is required")
+	}
+	if err := authorizeFleet(context.Request.Context(), fleetID); err != nil {
+		return err
+	}
+
+	stats, err := handler.FleetStats.Get(context.Request.Context(), fleetID, context.Query("refresh") == "true")
+	if err != nil {
+		return fmt.Errorf("failed to compute stats of fleet %s: %w", fleetID, err)
+	}
+
+	httpresp.JSON(context, http.StatusOK, stats)
+	return nil
+}
+
+// handling GET /fleets/:id/current, the latest state of every device of the fleet for the map
+func (handler *DeviceHandler) GetFleetCurrent(context *gin.Context) error {
+	fleetID := strings.TrimSpace(context.Param("id"))
+	if fleetID == "" {
+		return apierr.BadRequest("fleet id is required")
+	}
+	if err := authorizeFleet(context.Request.Context(), fleetID); err != nil {
+		return err
+	}
+
+	states, err := handler.LatestStore.ListFleet(context.Request.Context(), fleetID)
+	if err != nil {
+		return fmt.Errorf("failed to fetch latest states of fleet %s: %w", fleetID, err)
+	}
+
+	httpresp.JSON(context, http.StatusOK, gin.H{"fleet_id": fleetID, "devices": states, "count": len(states)})
+	return nil
+}
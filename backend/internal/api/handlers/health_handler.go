@@ -0,0 +1,37 @@
+package handlers
+
+import (
+	"context"
+	"net/http"
+	"time"
+
+	"github.com/Fleexa-Graduation-Project/Backend/internal/devices"
+	"github.com/Fleexa-Graduation-Project/Backend/pkg/httpresp"
+	"github.com/Fleexa-Graduation-Project/Backend/pkg/logger"
+	"github.com/gin-gonic/gin"
+)
+
+// a slow dependency must not hang the lambda
+const healthCheckTimeout = 2 * time.Second
+
+type HealthHandler struct {
+	DeviceStore *devices.DeviceStore
+}
+
+// handling GET /health
+func (handler *HealthHandler) GetHealth(c *gin.Context) {
+	ctx, cancel := context.WithTimeout(c.Request.Context(), healthCheckTimeout)
+	defer cancel()
+
+	checks := map[string]string{}
+	if err := handler.DeviceStore.Ping(ctx); err != nil {
+		logger.FromContext(ctx).Warn("health check failed", "dependency", "dynamodb", "error", err)
+		checks["dynamodb"] = "unreachable"
+	}
+
+	if len(checks) > 0 {
+		httpresp.JSON(c, http.StatusServiceUnavailable, gin.H{"status": "degraded", "checks": checks})
+		return
+	}
+	httpresp.JSON(c, http.StatusOK, gin.H{"status": "ok"})
+}
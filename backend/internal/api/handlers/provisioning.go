@@ -0,0 +1,149 @@
+package handlers
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/Fleexa-Graduation-Project/Backend/internal/auth"
+	"github.com/Fleexa-Graduation-Project/Backend/internal/devices"
+	"github.com/Fleexa-Graduation-Project/Backend/internal/provisioning"
+	"github.com/Fleexa-Graduation-Project/Backend/models"
+	"github.com/Fleexa-Graduation-Project/Backend/pkg/apierr"
+	"github.com/Fleexa-Graduation-Project/Backend/pkg/audit"
+	"github.com/Fleexa-Graduation-Project/Backend/pkg/httpreq"
+	"github.com/Fleexa-Graduation-Project/Backend/pkg/httpresp"
+	"github.com/Fleexa-Graduation-Project/Backend/pkg/logger"
+	"github.com/gin-gonic/gin"
+)
+
+type CreateProvisioningTokenRequest struct {
+	FleetID    string `json:"fleet_id" binding:"required"`
+	TTLSeconds int64  `json:"ttl_seconds"` // default 15 minutes, at most 24 hours
+}
+
+type ProvisioningTokenResponse struct {
+	Token     string `json:"token"`
+	FleetID   string `json:"fleet_id"`
+	ExpiresAt int64  `json:"expires_at"`
+}
+
+type ClaimDeviceRequest struct {
+	Token    string `json:"token" binding:"required"`
+	DeviceID string `json:"device_id" binding:"required"`
+	Name     string `json:"name"`
+	Model    string `json:"model"`
+}
+
+// the only response that carries the signing secret, the device has to keep it
+type ClaimDeviceResponse struct {
+	Device        models.Device `json:"device"`
+	SigningSecret string        `json:"signing_secret"`
+}
+
+// handling POST /provisioning/tokens (admin only)
+func (handler *DeviceHandler) CreateProvisioningToken(context *gin.Context) error {
+	var req CreateProvisioningTokenRequest
+	if !httpreq.DecodeBody(context, &req) {
+		return nil
+	}
+
+	fleetID := strings.TrimSpace(req.FleetID)
+	if fleetID == "" {
+		return apierr.BadRequest("fleet_id is required")
+	}
+	ttl := provisioning.DefaultTokenTTL
+	if req.TTLSeconds != 0 {
+		ttl = time.Duration(req.TTLSeconds) * time.Second
+		if ttl <= 0 || ttl > provisioning.MaxTokenTTL {
+			return apierr.BadRequest(fmt.Sprintf("ttl_seconds must be between 1 and %d", int64(provisioning.MaxTokenTTL/time.Second)))
+		}
+	}
+
+	claims, _ := auth.FromContext(context.Request.Context())
+	plain, token, err := handler.ProvisioningStore.CreateToken(context.Request.Context(), fleetID, claims.UserID, ttl)
+	handler.audit(context, "provisioning.token.create", audit.Resource("fleet", fleetID), err)
+	if err != nil {
+		return fmt.Errorf("failed to create provisioning token for fleet %s: %w", fleetID, err)
+	}
+
+	logger.FromContext(context.Request.Context()).Info("provisioning token created", "fleet_id", fleetID, "expires_at", token.ExpiresAt)
+	httpresp.JSON(context, http.StatusCreated, ProvisioningTokenResponse{Token: plain, FleetID: token.FleetID, ExpiresAt: token.ExpiresAt})
+	return nil
+}
+
+// handling POST /provisioning/claim, called by the device itself with no jwt. The token is
+// consumed first so two devices racing for it can't both register, and given back when the
+// registration fails
+func (handler *DeviceHandler) ClaimDevice(context *gin.Context) error {
+	var req ClaimDeviceRequest
+	if !httpreq.DecodeBody(context, &req) {
+		return nil
+	}
+
+	ctx := context.Request.Context()
+	plain := strings.TrimSpace(req.Token)
+	deviceID := strings.TrimSpace(req.DeviceID)
+	verr := &apierr.ValidationError{}
+	if plain == "" {
+		verr.Add("token", "required")
+	}
+	if deviceID == "" {
+		verr.Add("device_id", "required")
+	}
+	if err := verr.Err(); err != nil {
+		return err
+	}
+
+	token, err := handler.ProvisioningStore.Consume(ctx, plain, deviceID)
+	switch {
+	case errors.Is(err, provisioning.ErrTokenUnknown):
+		return apierr.New(http.StatusBadRequest, "invalid_token", "Unknown provisioning token")
+	case errors.Is(err, provisioning.ErrTokenExpired):
+		return apierr.New(http.StatusGone, "token_expired", "Provisioning token has expired")
+	case errors.Is(err, provisioning.ErrTokenUsed):
+		return apierr.New(http.StatusGone, "token_used", "Provisioning token has already been used")
+	case err != nil:
+		return fmt.Errorf("failed to consume provisioning token: %w", err)
+	}
+
+	device, err := handler.registerClaimed(context, req, token.FleetID)
+	if err != nil {
+		if releaseErr := handler.ProvisioningStore.Release(ctx, plain, deviceID); releaseErr != nil {
+			logger.FromContext(ctx).Error("failed to release provisioning token", "device_id", deviceID, "fleet_id", token.FleetID, "error", releaseErr)
+		}
+		return err
+	}
+
+	logger.FromContext(ctx).Info("device provisioned", "device_id", device.DeviceID, "fleet_id", device.FleetID)
+	httpresp.JSON(context, http.StatusCreated, ClaimDeviceResponse{Device: device, SigningSecret: device.SigningSecret})
+	return nil
+}
+
+// registers the claiming device in the token's fleet with a fresh signing secret
+func (handler *DeviceHandler) registerClaimed(context *gin.Context, req ClaimDeviceRequest, fleetID string) (models.Device, error) {
+	device, err := RegisterDeviceRequest{DeviceID: req.DeviceID, Name: req.Name, Model: req.Model, FleetID: fleetID}.device(context.Request.Context())
+	if err != nil {
+		return models.Device{}, err
+	}
+
+	raw := make([]byte, 32)
+	if _, err := rand.Read(raw); err != nil {
+		return models.Device{}, fmt.Errorf("failed to generate signing secret: %w", err)
+	}
+	device.SigningSecret = hex.EncodeToString(raw)
+
+	err = handler.DeviceStore.RegisterDevice(context.Request.Context(), device)
+	handler.audit(context, "device.provision", audit.Resource("device", device.DeviceID), err)
+	if errors.Is(err, devices.ErrDeviceExists) {
+		return models.Device{}, apierr.Conflict("Device already registered")
+	}
+	if err != nil {
+		return models.Device{}, fmt.Errorf("failed to register device %s: %w", device.DeviceID, err)
+	}
+	return device, nil
+}
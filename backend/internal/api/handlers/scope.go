@@ -0,0 +1,90 @@
+package handlers
+
+import (
+	"context"
+	"fmt"
+	"slices"
+
+	"github.com/Fleexa-Graduation-Project/Backend/internal/auth"
+	"github.com/Fleexa-Graduation-Project/Backend/internal/devices"
+	"github.com/Fleexa-Graduation-Project/Backend/models"
+	"github.com/Fleexa-Graduation-Project/Backend/pkg/apierr"
+	"github.com/Fleexa-Graduation-Project/Backend/pkg/logger"
+	"github.com/gin-gonic/gin"
+)
+
+// another fleet's resources answer 404 like missing ones, so ids can't be probed across tenants
+var errOutOfScope = apierr.NotFound("Device not found")
+
+// authorizeDevice checks the device is registered to the caller's fleet, admins pass unchecked.
+// Devices missing from the registry belong to no fleet and are out of every scope
+func (handler *DeviceHandler) authorizeDevice(ctx context.Context, deviceID string) error {
+	fleetID, scoped := auth.FleetFromContext(ctx)
+	if !scoped {
+		return nil
+	}
+
+	device, err := handler.DeviceStore.GetDevice(ctx, deviceID)
+	if err != nil {
+		return fmt.Errorf("failed to look up fleet of device %s: %w", deviceID, err)
+	}
+	if device == nil || device.FleetID != fleetID {
+		logger.FromContext(ctx).Warn("rejected cross-fleet access", "reason", "out_of_scope", "device_id", deviceID, "fleet_id", fleetID)
+		return errOutOfScope
+	}
+	return nil
+}
+
+// authorizeFleet checks a fleet id taken from the request is the caller's own
+func authorizeFleet(ctx context.Context, requested string) error {
+	fleetID, scoped := auth.FleetFromContext(ctx)
+	if scoped && requested != fleetID {
+		logger.FromContext(ctx).Warn("rejected cross-fleet access", "reason", "out_of_scope", "requested_fleet_id", requested, "fleet_id", fleetID)
+		return apierr.NotFound("Fleet not found")
+	}
+	return nil
+}
+
+// fleetDevices is the set of device ids a scoped caller may see in the table-wide listings,
+// nil means unscoped
+func (handler *DeviceHandler) fleetDevices(ctx context.Context) (map[string]bool, error) {
+	fleetID, scoped := auth.FleetFromContext(ctx)
+	if !scoped {
+		return nil, nil
+	}
+
+	ids := map[string]bool{}
+	cursor := ""
+	for {
+		page, err := handler.DeviceStore.ListDevices(ctx, fleetID, devices.MaxPageLimit, cursor)
+		if err != nil {
+			return nil, fmt.Errorf("failed to list devices of fleet %s: %w", fleetID, err)
+		}
+		for _, device := range page.Devices {
+			ids[device.DeviceID] = true
+		}
+		if page.NextCursor == "" {
+			return ids, nil
+		}
+		cursor = page.NextCursor
+	}
+}
+
+// scopeStates drops the states of devices outside the caller's fleet
+func (handler *DeviceHandler) scopeStates(ctx context.Context, states []models.DeviceState) ([]models.DeviceState, error) {
+	allowed, err := handler.fleetDevices(ctx)
+	if err != nil || allowed == nil {
+		return states, err
+	}
+	return slices.DeleteFunc(states, func(state models.DeviceState) bool { return !allowed[state.DeviceID] }), nil
+}
+
+// deviceScoped guards the gin handlers that don't return an error, false means the response
+// was already written
+func (handler *DeviceHandler) deviceScoped(context *gin.Context, deviceID string) bool {
+	if err := handler.authorizeDevice(context.Request.Context(), deviceID); err != nil {
+		apierr.Render(context, err)
+		return false
+	}
+	return true
+}
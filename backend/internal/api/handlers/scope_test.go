@@ -0,0 +1,142 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"testing"
+
+	"github.com/Fleexa-Graduation-Project/Backend/internal/auth"
+	"github.com/Fleexa-Graduation-Project/Backend/internal/devices"
+	"github.com/Fleexa-Graduation-Project/Backend/models"
+	"github.com/Fleexa-Graduation-Project/Backend/pkg/apierr"
+)
+
+func scopedHandler(t *testing.T, fleets map[string]string) *DeviceHandler {
+	t.Helper()
+	store := devices.NewMemDeviceStore()
+	for deviceID, fleetID := range fleets {
+		if err := store.RegisterDevice(context.Background(), models.Device{DeviceID: deviceID, FleetID: fleetID}); err != nil {
+			t.Fatalf("RegisterDevice: %v", err)
+		}
+	}
+	return &DeviceHandler{DeviceStore: store}
+}
+
+// the context FleetScope leaves for the handlers, an empty fleet is an unscoped admin
+func callerContext(fleetID string) context.Context {
+	if fleetID == "" {
+		return context.Background()
+	}
+	return auth.WithFleet(context.Background(), fleetID)
+}
+
+func TestAuthorizeDevice(t *testing.T) {
+	handler := scopedHandler(t, map[string]string{"truck-a": "fleet-a", "truck-b": "fleet-b"})
+
+	tests := []struct {
+		name       string
+		fleetID    string
+		deviceID   string
+		wantStatus int // 0 is allowed
+	}{
+		{name: "own device", fleetID: "fleet-a", deviceID: "truck-a"},
+		{name: "other fleet's device looks missing", fleetID: "fleet-a", deviceID: "truck-b", wantStatus: http.StatusNotFound},
+		{name: "unregistered device", fleetID: "fleet-a", deviceID: "truck-x", wantStatus: http.StatusNotFound},
+		{name: "admin sees every fleet", deviceID: "truck-b"},
+		{name: "admin passes unregistered ids to the handler", deviceID: "truck-x"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			err := handler.authorizeDevice(callerContext(test.fleetID), test.deviceID)
+			if got := statusOf(err); got != test.wantStatus {
+				t.Errorf("authorizeDevice() = %v, want status %d", err, test.wantStatus)
+			}
+			// cross-fleet and missing devices must answer the same, ids can't be probed
+			if test.wantStatus != 0 && err != errOutOfScope {
+				t.Errorf("authorizeDevice() = %v, want errOutOfScope", err)
+			}
+		})
+	}
+}
+
+func TestAuthorizeFleet(t *testing.T) {
+	tests := []struct {
+		name       string
+		fleetID    string
+		requested  string
+		wantStatus int
+	}{
+		{name: "own fleet", fleetID: "fleet-a", requested: "fleet-a"},
+		{name: "other fleet", fleetID: "fleet-a", requested: "fleet-b", wantStatus: http.StatusNotFound},
+		{name: "no fleet asked for by a scoped caller", fleetID: "fleet-a", requested: "", wantStatus: http.StatusNotFound},
+		{name: "admin", requested: "fleet-b"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if got := statusOf(authorizeFleet(callerContext(test.fleetID), test.requested)); got != test.wantStatus {
+				t.Errorf("authorizeFleet() status = %d, want %d", got, test.wantStatus)
+			}
+		})
+	}
+}
+
+func TestScopeStates(t *testing.T) {
+	// more devices than one ListDevices page, the scope must follow the cursor
+	fleets := map[string]string{"truck-b": "fleet-b"}
+	for i := range devices.MaxPageLimit + 5 {
+		fleets[fmt.Sprintf("truck-a%03d", i)] = "fleet-a"
+	}
+	handler := scopedHandler(t, fleets)
+
+	var states []models.DeviceState
+	for deviceID := range fleets {
+		states = append(states, models.DeviceState{DeviceID: deviceID})
+	}
+	states = append(states, models.DeviceState{DeviceID: "truck-unregistered"})
+
+	tests := []struct {
+		name      string
+		fleetID   string
+		wantCount int
+	}{
+		{name: "fleet a", fleetID: "fleet-a", wantCount: devices.MaxPageLimit + 5},
+		{name: "fleet b", fleetID: "fleet-b", wantCount: 1},
+		{name: "fleet without devices", fleetID: "fleet-c", wantCount: 0},
+		{name: "admin", wantCount: len(states)},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			scoped, err := handler.scopeStates(callerContext(test.fleetID), append([]models.DeviceState(nil), states...))
+			if err != nil {
+				t.Fatalf("scopeStates() error = %v", err)
+			}
+			if len(scoped) != test.wantCount {
+				t.Fatalf("scopeStates() kept %d states, want %d", len(scoped), test.wantCount)
+			}
+			if test.fleetID == "" {
+				return
+			}
+			for _, state := range scoped {
+				if fleets[state.DeviceID] != test.fleetID {
+					t.Errorf("scopeStates() kept %s of fleet %q", state.DeviceID, fleets[state.DeviceID])
+				}
+			}
+		})
+	}
+}
+
+func statusOf(err error) int {
+	var apiErr *apierr.APIError
+	if errors.As(err, &apiErr) {
+		return apiErr.Status
+	}
+	if err != nil {
+		return http.StatusInternalServerError
+	}
+	return 0
+}
@@ -0,0 +1,150 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/Fleexa-Graduation-Project/Backend/internal/commands"
+	"github.com/Fleexa-Graduation-Project/Backend/internal/shadows"
+	"github.com/Fleexa-Graduation-Project/Backend/models"
+	"github.com/Fleexa-Graduation-Project/Backend/pkg/apierr"
+	"github.com/Fleexa-Graduation-Project/Backend/pkg/audit"
+	appconfig "github.com/Fleexa-Graduation-Project/Backend/pkg/config"
+	"github.com/Fleexa-Graduation-Project/Backend/pkg/httpreq"
+	"github.com/Fleexa-Graduation-Project/Backend/pkg/httpresp"
+	"github.com/Fleexa-Graduation-Project/Backend/pkg/logger"
+	"github.com/gin-gonic/gin"
+)
+
+// the command a device gets when its desired state changes, parameters carry the delta
+const shadowDeltaAction = "SHADOW_DELTA"
+
+type UpdateShadowRequest struct {
+	Desired map[string]interface{} `json:"desired"`
+	Version int64                  `json:"version"` // the version the change is based on, 0 skips the check
+}
+
+type shadowResponse struct {
+	shadows.Shadow
+	Delta     map[string]interface{} `json:"delta"`
+	CommandID string                 `json:"command_id,omitempty"` // set when the delta was sent to the device
+}
+
+// handling GET /devices/:id/shadow
+func (handler *DeviceHandler) GetShadow(context *gin.Context) error {
+	ctx := context.Request.Context()
+	deviceID := context.Param("id")
+	if err := handler.authorizeDevice(ctx, deviceID); err != nil {
+		return err
+	}
+
+	shadow, err := handler.ShadowStore.GetShadow(ctx, deviceID)
+	if err != nil {
+		return fmt.Errorf("failed to fetch shadow of device %s: %w", deviceID, err)
+	}
+
+	// nothing desired yet, the reported state is the last reading
+	if shadow == nil {
+		state, err := handler.StateStore.GetStateByID(ctx, deviceID)
+		if err != nil {
+			return fmt.Errorf("failed to fetch state of device %s: %w", deviceID, err)
+		}
+		if state == nil {
+			return apierr.NotFound("Device not found")
+		}
+		shadow = &shadows.Shadow{DeviceID: deviceID, Desired: map[string]interface{}{}, Reported: state.Payload}
+		if shadow.Reported == nil {
+			shadow.Reported = map[string]interface{}{}
+		}
+	}
+
+	httpresp.JSON(context, http.StatusOK, shadowResponse{Shadow: *shadow, Delta: shadow.Delta()})
+	return nil
+}
+
+// handling PATCH /devices/:id/shadow, deep merges into the desired state (null removes a key)
+// and sends what the device hasn't reported yet as a command
+func (handler *DeviceHandler) UpdateShadow(context *gin.Context) error {
+	ctx := context.Request.Context()
+	deviceID := context.Param("id")
+	if err := handler.authorizeDevice(ctx, deviceID); err != nil {
+		return err
+	}
+
+	var req UpdateShadowRequest
+	if !httpreq.DecodeBody(context, &req) {
+		return nil
+	}
+	verr := &apierr.ValidationError{}
+	if len(req.Desired) == 0 {
+		verr.Add("desired", "required")
+	}
+	if req.Version < 0 {
+		verr.Add("version", "must not be negative")
+	}
+	if err := verr.Err(); err != nil {
+		return err
+	}
+
+	device, err := handler.DeviceStore.GetDevice(ctx, deviceID)
+	if err != nil {
+		return fmt.Errorf("failed to fetch device %s for shadow update: %w", deviceID, err)
+	}
+	if device == nil {
+		return apierr.NotFound("Device not found")
+	}
+
+	shadow, err := handler.ShadowStore.UpdateDesired(ctx, deviceID, req.Desired, req.Version)
+	handler.audit(context, "device.shadow.update", audit.Resource("device", deviceID), err)
+	if errors.Is(err, shadows.ErrVersionConflict) {
+		return apierr.Conflict("Shadow was changed by another update, fetch it and retry")
+	}
+	if err != nil {
+		return fmt.Errorf("failed to update shadow of device %s: %w", deviceID, err)
+	}
+
+	response := shadowResponse{Shadow: shadow, Delta: shadow.Delta()}
+	if len(response.Delta) > 0 {
+		response.CommandID = handler.sendShadowDelta(context, shadow, response.Delta)
+	}
+
+	logger.FromContext(ctx).Info("shadow updated", "device_id", deviceID, "version", shadow.Version)
+	httpresp.JSON(context, http.StatusOK, response)
+	return nil
+}
+
+// the desired state is already stored, a failed publish is logged and the next update sends the
+// whole delta again. Returns the command id, empty when it wasn't sent
+func (handler *DeviceHandler) sendShadowDelta(context *gin.Context, shadow shadows.Shadow, delta map[string]interface{}) string {
+	ctx := context.Request.Context()
+	log := logger.FromContext(ctx)
+
+	requestID := fmt.Sprintf("cmd-%d", time.Now().UnixNano())
+	parameters := map[string]interface{}{"desired": delta, "version": shadow.Version}
+
+	topic := fmt.Sprintf("%s/%s/command", appconfig.Topic("devices"), shadow.DeviceID)
+	err := handler.IoTPublisher.Publish(ctx, topic, map[string]interface{}{
+		"request_id": requestID,
+		"action":     shadowDeltaAction,
+		"parameters": parameters,
+	})
+	if err != nil {
+		log.Warn("shadow updated, but failed to publish the delta", "device_id", shadow.DeviceID, "version", shadow.Version, "error", err)
+		return ""
+	}
+
+	commandRecord := models.Command{
+		RequestID:  requestID,
+		DeviceID:   shadow.DeviceID,
+		Timestamp:  time.Now().Unix(),
+		Action:     shadowDeltaAction,
+		Parameters: parameters,
+		Status:     commands.StatusPending,
+	}
+	if storeErr := handler.CommandStore.SaveCommand(ctx, commandRecord); storeErr != nil {
+		log.Warn("shadow delta sent, but failed to save history to DB", "error", storeErr)
+	}
+	return requestID
+}
@@ -0,0 +1,174 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"os"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/Fleexa-Graduation-Project/Backend/internal/telemetry"
+	"github.com/Fleexa-Graduation-Project/Backend/models"
+	"github.com/Fleexa-Graduation-Project/Backend/pkg/apierr"
+	"github.com/Fleexa-Graduation-Project/Backend/pkg/logger"
+	"github.com/gin-gonic/gin"
+)
+
+// keeps an export well under the 6MB a lambda response can carry through api gateway
+const DefaultMaxExportRows = 10000
+
+// MaxExportRows caps the readings of one export, TELEMETRY_EXPORT_MAX_ROWS overrides the default
+func MaxExportRows() int {
+	raw := os.Getenv("TELEMETRY_EXPORT_MAX_ROWS")
+	if raw == "" {
+		return DefaultMaxExportRows
+	}
+
+	limit, err := strconv.Atoi(raw)
+	if err != nil || limit <= 0 {
+		slog.Warn("invalid TELEMETRY_EXPORT_MAX_ROWS, falling back to default", "value", raw, "default", DefaultMaxExportRows)
+		return DefaultMaxExportRows
+	}
+	return limit
+}
+
+// handling GET /devices/:id/telemetry/export?from=&to=, the raw readings oldest first as csv.
+// from/to take unix seconds or RFC3339, the last 24h by default
+func (handler *DeviceHandler) ExportTelemetry(context *gin.Context) error {
+	ctx := context.Request.Context()
+	deviceID := context.Param("id")
+	if err := handler.authorizeDevice(ctx, deviceID); err != nil {
+		return err
+	}
+
+	from, to, err := exportRange(context)
+	if err != nil {
+		return err
+	}
+
+	device, err := handler.DeviceStore.GetDevice(ctx, deviceID)
+	if err != nil {
+		return fmt.Errorf("failed to fetch device %s for export: %w", deviceID, err)
+	}
+	if device == nil {
+		return apierr.NotFound("Device not found")
+	}
+
+	// everything is read before the first byte is written, a range over the cap still gets a
+	// proper error instead of a cut off file
+	limit := MaxExportRows()
+	var readings []models.Telemetry
+	cursor := ""
+	for {
+		page, err := handler.TelemetryStore.QueryTelemetry(ctx, deviceID, from, to, telemetry.MaxQueryLimit, cursor)
+		if err != nil {
+			return fmt.Errorf("failed to query telemetry of device %s for export: %w", deviceID, err)
+		}
+		readings = append(readings, page.Readings...)
+		if len(readings) > limit {
+			return apierr.New(http.StatusRequestEntityTooLarge, "export_too_large",
+				fmt.Sprintf("an export is limited to %d readings, narrow the from/to range", limit))
+		}
+		if page.NextCursor == "" {
+			break
+		}
+		cursor = page.NextCursor
+	}
+
+	body, err := telemetryCSV(readings)
+	if err != nil {
+		return fmt.Errorf("failed to write telemetry export of device %s: %w", deviceID, err)
+	}
+
+	filename := fmt.Sprintf("telemetry-%s-%s-%s.csv", deviceID, from.UTC().Format("20060102T150405Z"), to.UTC().Format("20060102T150405Z"))
+	context.Header("Content-Disposition", fmt.Sprintf("attachment; filename=%q", filename))
+	logger.FromContext(ctx).Info("telemetry exported", "device_id", deviceID, "from", from.Unix(), "to", to.Unix(), "rows", len(readings))
+	context.Data(http.StatusOK, "text/csv; charset=utf-8", body)
+	return nil
+}
+
+func exportRange(context *gin.Context) (time.Time, time.Time, error) {
+	to := time.Now()
+	if raw := context.Query("to"); raw != "" {
+		parsed, err := parseQueryTime(raw)
+		if err != nil {
+			return time.Time{}, time.Time{}, apierr.BadRequest("to must be unix seconds or RFC3339")
+		}
+		to = parsed
+	}
+
+	from := to.Add(-defaultQueryWindow)
+	if raw := context.Query("from"); raw != "" {
+		parsed, err := parseQueryTime(raw)
+		if err != nil {
+			return time.Time{}, time.Time{}, apierr.BadRequest("from must be unix seconds or RFC3339")
+		}
+		from = parsed
+	}
+
+	if from.After(to) {
+		return time.Time{}, time.Time{}, apierr.BadRequest("from must not be after to")
+	}
+	return from, to, nil
+}
+
+// one column per payload field seen in the range, sorted, after device_id, timestamp and type.
+// A reading without a field leaves its cell empty
+func telemetryCSV(readings []models.Telemetry) ([]byte, error) {
+	seen := map[string]bool{}
+	var fields []string
+	for _, reading := range readings {
+		for field := range reading.Payload {
+			if !seen[field] {
+				seen[field] = true
+				fields = append(fields, field)
+			}
+		}
+	}
+	sort.Strings(fields)
+
+	var buf bytes.Buffer
+	writer := csv.NewWriter(&buf)
+	if err := writer.Write(append([]string{"device_id", "timestamp", "type"}, fields...)); err != nil {
+		return nil, err
+	}
+
+	for _, reading := range readings {
+		row := make([]string, 0, 3+len(fields))
+		row = append(row, reading.DeviceID, time.Unix(reading.Timestamp, 0).UTC().Format(time.RFC3339), reading.Type)
+		for _, field := range fields {
+			row = append(row, csvValue(reading.Payload[field]))
+		}
+		if err := writer.Write(row); err != nil {
+			return nil, err
+		}
+	}
+
+	writer.Flush()
+	return buf.Bytes(), writer.Error()
+}
+
+// numbers, strings and bools as they are, nested values as json
+func csvValue(value interface{}) string {
+	switch v := value.(type) {
+	case nil:
+		return ""
+	case string:
+		return v
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	case bool:
+		return strconv.FormatBool(v)
+	default:
+		encoded, err := json.Marshal(v)
+		if err != nil {
+			return fmt.Sprint(v)
+		}
+		return string(encoded)
+	}
+}
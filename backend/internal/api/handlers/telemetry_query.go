@@ -0,0 +1,133 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/Fleexa-Graduation-Project/Backend/internal/telemetry"
+	"github.com/Fleexa-Graduation-Project/Backend/pkg/db"
+	"github.com/Fleexa-Graduation-Project/Backend/pkg/httpresp"
+	"github.com/Fleexa-Graduation-Project/Backend/pkg/logger"
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	defaultQueryWindow = 24 * time.Hour
+	// longer ranges are served from the hourly aggregates unless resolution=raw is asked for
+	hourlyQueryWindow = 48 * time.Hour
+)
+
+// from/to accept unix seconds or RFC3339
+func parseQueryTime(raw string) (time.Time, error) {
+	if seconds, err := strconv.ParseInt(raw, 10, 64); err == nil {
+		return time.Unix(seconds, 0), nil
+	}
+	return time.Parse(time.RFC3339, raw)
+}
+
+func wantsRangeQuery(context *gin.Context) bool {
+	for _, key := range []string{"from", "to", "cursor", "resolution"} {
+		if _, ok := context.GetQuery(key); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// raw is the default up to hourlyQueryWindow, hourly above it
+func queryResolution(context *gin.Context, from, to time.Time) (string, bool) {
+	switch resolution := context.Query("resolution"); resolution {
+	case "raw", "hourly":
+		return resolution, true
+	case "":
+		if to.Sub(from) > hourlyQueryWindow {
+			return "hourly", true
+		}
+		return "raw", true
+	default:
+		return "", false
+	}
+}
+
+// handling GET /devices/:id/telemetry?from=&to=&limit=&cursor=&resolution= (raw readings or
+// hourly aggregates, oldest first)
+func (handler *DeviceHandler) queryTelemetryRange(context *gin.Context, deviceID string) {
+	to := time.Now()
+	if raw := context.Query("to"); raw != "" {
+		parsed, err := parseQueryTime(raw)
+		if err != nil {
+			httpresp.Error(context, http.StatusBadRequest, "to must be unix seconds or RFC3339")
+			return
+		}
+		to = parsed
+	}
+
+	from := to.Add(-defaultQueryWindow)
+	if raw := context.Query("from"); raw != "" {
+		parsed, err := parseQueryTime(raw)
+		if err != nil {
+			httpresp.Error(context, http.StatusBadRequest, "from must be unix seconds or RFC3339")
+			return
+		}
+		from = parsed
+	}
+
+	if from.After(to) {
+		httpresp.Error(context, http.StatusBadRequest, "from must not be after to")
+		return
+	}
+
+	limit := telemetry.DefaultQueryLimit
+	if raw := context.Query("limit"); raw != "" {
+		parsed, err := strconv.Atoi(raw)
+		if err != nil || parsed <= 0 {
+			httpresp.Error(context, http.StatusBadRequest, "limit must be a positive number")
+			return
+		}
+		limit = parsed
+	}
+
+	resolution, ok := queryResolution(context, from, to)
+	if !ok {
+		httpresp.Error(context, http.StatusBadRequest, "resolution must be raw or hourly")
+		return
+	}
+	if resolution == "hourly" && handler.HourlyStore != nil {
+		handler.queryHourlyRange(context, deviceID, from, to, limit)
+		return
+	}
+
+	page, err := handler.TelemetryStore.QueryTelemetry(context.Request.Context(), deviceID, from, to, limit, context.Query("cursor"))
+	if errors.Is(err, db.ErrInvalidCursor) {
+		httpresp.Error(context, http.StatusBadRequest, "Invalid cursor")
+		return
+	}
+	if err != nil {
+		logger.FromContext(context.Request.Context()).Error("failed to query telemetry", "device_id", deviceID, "error", err)
+		internalError(context, err, "Failed to query telemetry")
+		return
+	}
+
+	httpresp.JSON(context, http.StatusOK, page)
+}
+
+func (handler *DeviceHandler) queryHourlyRange(context *gin.Context, deviceID string, from, to time.Time, limit int) {
+	if limit > telemetry.MaxQueryLimit {
+		limit = telemetry.MaxQueryLimit
+	}
+
+	page, err := handler.HourlyStore.QueryHourly(context.Request.Context(), deviceID, from, to, int32(limit), context.Query("cursor"))
+	if errors.Is(err, db.ErrInvalidCursor) {
+		httpresp.Error(context, http.StatusBadRequest, "Invalid cursor")
+		return
+	}
+	if err != nil {
+		logger.FromContext(context.Request.Context()).Error("failed to query hourly aggregates", "device_id", deviceID, "error", err)
+		internalError(context, err, "Failed to query telemetry")
+		return
+	}
+
+	httpresp.JSON(context, http.StatusOK, page)
+}
@@ -0,0 +1,134 @@
+package api
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"io"
+	"net/http"
+
+	"github.com/Fleexa-Graduation-Project/Backend/internal/auth"
+	"github.com/Fleexa-Graduation-Project/Backend/internal/idempotency"
+	"github.com/Fleexa-Graduation-Project/Backend/pkg/httpresp"
+	"github.com/Fleexa-Graduation-Project/Backend/pkg/logger"
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	idempotencyHeader = "Idempotency-Key"
+	maxIdempotencyKey = 255
+	// dynamodb items stop at 400KB, larger responses are sent but not kept for replay
+	maxStoredResponse = 350 << 10
+)
+
+// Idempotency makes retried writes safe: a POST, PUT, PATCH or DELETE carrying an
+// Idempotency-Key runs once per caller, method and path, and repeats get the stored response
+// back. A repeat while the first one still runs gets 409, the same key with another body 422.
+// Responses of 500 and above are not kept so the retry runs for real. Store errors fail open.
+// Needs the caller, so it goes after RequireAuth. A nil store disables it
+func Idempotency(store *idempotency.Store) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		requestKey := c.GetHeader(idempotencyHeader)
+		if store == nil || requestKey == "" {
+			c.Next()
+			return
+		}
+		switch c.Request.Method {
+		case http.MethodPost, http.MethodPut, http.MethodPatch, http.MethodDelete:
+		default:
+			c.Next()
+			return
+		}
+		if len(requestKey) > maxIdempotencyKey {
+			httpresp.ErrorCode(c, http.StatusBadRequest, "invalid_idempotency_key", "Idempotency-Key must be at most 255 characters")
+			return
+		}
+
+		claims, ok := auth.FromContext(c.Request.Context())
+		if !ok {
+			c.Next()
+			return
+		}
+		caller := claims.UserID
+		if caller == "" {
+			caller = claims.Subject
+		}
+
+		body, err := io.ReadAll(c.Request.Body)
+		if err != nil {
+			var maxBytesErr *http.MaxBytesError
+			if errors.As(err, &maxBytesErr) {
+				httpresp.Error(c, http.StatusRequestEntityTooLarge, "request body too large")
+				return
+			}
+			httpresp.Error(c, http.StatusBadRequest, "Failed to read request body")
+			return
+		}
+		// a route BodyLimit below wraps the raw body again, it must find the bytes read here
+		reader := io.NopCloser(bytes.NewReader(body))
+		c.Request.Body = reader
+		c.Set(rawBodyKey, reader)
+
+		sum := sha256.Sum256(body)
+		requestHash := hex.EncodeToString(sum[:])
+		key := caller + "|" + c.Request.Method + " " + c.Request.URL.Path + "|" + requestKey
+
+		ctx := c.Request.Context()
+		log := logger.FromContext(ctx)
+		existing, err := store.Begin(ctx, key, requestHash)
+		if err != nil {
+			log.Warn("idempotency store unavailable, running the request without it", "error", err)
+			c.Next()
+			return
+		}
+		if existing != nil {
+			replay(c, existing, requestHash)
+			return
+		}
+
+		original := c.Writer
+		buffered := &bufferedWriter{ResponseWriter: original, status: http.StatusOK}
+		c.Writer = buffered
+		defer func() {
+			// Recover above writes the 500, it must reach the real writer and the retry must run
+			if r := recover(); r != nil {
+				c.Writer = original
+				if err := store.Release(ctx, key); err != nil {
+					log.Warn("failed to release idempotency key", "error", err)
+				}
+				panic(r)
+			}
+		}()
+		c.Next()
+		c.Writer = original
+
+		response := buffered.body.Bytes()
+		switch {
+		case buffered.status >= http.StatusInternalServerError || len(response) > maxStoredResponse:
+			if err := store.Release(ctx, key); err != nil {
+				log.Warn("failed to release idempotency key", "error", err)
+			}
+		default:
+			if err := store.Complete(ctx, key, buffered.status, original.Header().Get("Content-Type"), response); err != nil {
+				log.Warn("failed to store idempotent response, a retry runs again once the lock expires", "error", err)
+			}
+		}
+
+		original.WriteHeader(buffered.status)
+		original.Write(response)
+	}
+}
+
+func replay(c *gin.Context, record *idempotency.Record, requestHash string) {
+	switch {
+	case record.RequestHash != requestHash:
+		httpresp.ErrorCode(c, http.StatusUnprocessableEntity, "idempotency_key_reused", "Idempotency-Key was already used with a different request body")
+	case record.Status != idempotency.StatusCompleted:
+		httpresp.ErrorCode(c, http.StatusConflict, "request_in_progress", "A request with this Idempotency-Key is still in progress")
+	default:
+		c.Header("Idempotent-Replayed", "true")
+		c.Data(record.StatusCode, record.ContentType, record.Body)
+		c.Abort()
+	}
+}
@@ -0,0 +1,86 @@
+package api
+
+import (
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/Fleexa-Graduation-Project/Backend/pkg/httpresp"
+	"github.com/gin-gonic/gin"
+)
+
+// counters of this lambda container since its cold start, kept next to the emf metrics for
+// spot checks without a metrics query. Another container has its own
+var (
+	startedAt = time.Now()
+	totals    requestCounters
+	routes    sync.Map // "METHOD route" -> *requestCounters
+)
+
+type requestCounters struct {
+	requests     atomic.Int64
+	clientErrors atomic.Int64 // 4xx
+	serverErrors atomic.Int64 // 5xx
+}
+
+func (counters *requestCounters) record(status int) {
+	counters.requests.Add(1)
+	switch {
+	case status >= http.StatusInternalServerError:
+		counters.serverErrors.Add(1)
+	case status >= http.StatusBadRequest:
+		counters.clientErrors.Add(1)
+	}
+}
+
+func (counters *requestCounters) snapshot() RouteStats {
+	return RouteStats{
+		Requests:     counters.requests.Load(),
+		ClientErrors: counters.clientErrors.Load(),
+		ServerErrors: counters.serverErrors.Load(),
+	}
+}
+
+// called by Latency, which already knows the route and the final status
+func recordRequest(route string, status int) {
+	totals.record(status)
+	counters, _ := routes.LoadOrStore(route, &requestCounters{})
+	counters.(*requestCounters).record(status)
+}
+
+type RouteStats struct {
+	Requests     int64 `json:"requests"`
+	ClientErrors int64 `json:"client_errors"`
+	ServerErrors int64 `json:"server_errors"`
+}
+
+type InstanceStats struct {
+	StartedAt     int64 `json:"started_at"`
+	UptimeSeconds int64 `json:"uptime_seconds"`
+	RouteStats
+	Routes map[string]RouteStats `json:"routes"`
+}
+
+// InternalMetricsEnabled is INTERNAL_METRICS_ENABLED=true, the route isn't registered otherwise
+func InternalMetricsEnabled() bool {
+	return strings.EqualFold(strings.TrimSpace(os.Getenv("INTERNAL_METRICS_ENABLED")), "true")
+}
+
+// handling GET /internal/metrics (admin only), the counters of the container that served it
+func InternalMetrics(c *gin.Context) {
+	stats := InstanceStats{
+		StartedAt:     startedAt.Unix(),
+		UptimeSeconds: int64(time.Since(startedAt).Seconds()),
+		RouteStats:    totals.snapshot(),
+		Routes:        map[string]RouteStats{},
+	}
+	routes.Range(func(route, counters any) bool {
+		stats.Routes[route.(string)] = counters.(*requestCounters).snapshot()
+		return true
+	})
+
+	httpresp.JSON(c, http.StatusOK, stats)
+}
@@ -0,0 +1,30 @@
+package api
+
+import (
+	"net/http"
+
+	"github.com/Fleexa-Graduation-Project/Backend/internal/maintenance"
+	"github.com/Fleexa-Graduation-Project/Backend/pkg/httpresp"
+	"github.com/gin-gonic/gin"
+)
+
+// Maintenance answers state-changing requests with 503 and Retry-After while writes are paused,
+// reads keep working. A nil switch disables it
+func Maintenance(sw *maintenance.Switch) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		switch c.Request.Method {
+		case http.MethodGet, http.MethodHead, http.MethodOptions:
+			c.Next()
+			return
+		}
+
+		if sw != nil {
+			if enabled, retryAfter := sw.Enabled(c.Request.Context()); enabled {
+				c.Header("Retry-After", maintenance.RetryAfterHeader(retryAfter))
+				httpresp.ErrorCode(c, http.StatusServiceUnavailable, "maintenance", "Writes are paused for maintenance")
+				return
+			}
+		}
+		c.Next()
+	}
+}
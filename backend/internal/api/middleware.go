@@ -0,0 +1,171 @@
+package api
+
+import (
+	"errors"
+	"log/slog"
+	"net/http"
+	"slices"
+	"strings"
+
+	"github.com/Fleexa-Graduation-Project/Backend/internal/auth"
+	"github.com/Fleexa-Graduation-Project/Backend/pkg/apierr"
+	"github.com/Fleexa-Graduation-Project/Backend/pkg/httpresp"
+	"github.com/Fleexa-Graduation-Project/Backend/pkg/logger"
+	"github.com/Fleexa-Graduation-Project/Backend/pkg/metrics"
+	"github.com/Fleexa-Graduation-Project/Backend/pkg/recovery"
+	"github.com/Fleexa-Graduation-Project/Backend/pkg/timeout"
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+const requestIDHeader = "X-Request-ID"
+
+// RequestID puts a logger carrying request_id into the request context, and request_id into
+// its attributes for slog's *Context calls
+func RequestID() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx := c.Request.Context()
+
+		requestID := logger.RequestID(ctx)
+		if requestID == "" {
+			requestID = c.GetHeader(requestIDHeader) // running locally, not behind api gateway
+		}
+		if requestID == "" {
+			requestID = uuid.NewString()
+		}
+
+		c.Header(requestIDHeader, requestID)
+		log := slog.Default().With("request_id", requestID)
+		ctx = logger.WithAttrs(ctx, "request_id", requestID)
+		c.Request = c.Request.WithContext(logger.NewContext(ctx, log))
+
+		c.Next()
+	}
+}
+
+// RequireAuth rejects requests without a valid bearer token and stores the claims in the context
+func RequireAuth() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		token, _ := strings.CutPrefix(c.GetHeader("Authorization"), "Bearer ")
+
+		claims, err := auth.ValidateJWT(strings.TrimSpace(token))
+		if err != nil {
+			message := "Invalid token"
+			switch {
+			case errors.Is(err, auth.ErrMissingToken):
+				message = "Missing bearer token"
+			case errors.Is(err, auth.ErrTokenExpired):
+				message = "Token has expired"
+			case errors.Is(err, auth.ErrTokenMalformed):
+				message = "Malformed token"
+			}
+
+			logger.FromContext(c.Request.Context()).Warn("rejected unauthenticated request", "path", c.FullPath(), "error", err)
+			httpresp.Error(c, http.StatusUnauthorized, message)
+			return
+		}
+
+		c.Request = c.Request.WithContext(auth.NewContext(c.Request.Context(), claims))
+		c.Next()
+	}
+}
+
+// Middleware wraps a HandlerFunc: it runs its code around next, or returns without calling next
+// to end the request there (a returned error is rendered like the handler's own)
+type Middleware func(next HandlerFunc) HandlerFunc
+
+// Chain is route middleware in the order it runs, the first one outermost. The router's global
+// gin middleware runs before any chain
+type Chain []Middleware
+
+// Use returns the chain with middleware added inside the existing ones, the receiver is never
+// changed so a group's chain can be extended per route
+func (chain Chain) Use(middleware ...Middleware) Chain {
+	return append(slices.Clip(chain), middleware...)
+}
+
+// Then wraps handler in the chain and adapts it for the router like Handle
+func (chain Chain) Then(handler HandlerFunc) gin.HandlerFunc {
+	for i := len(chain) - 1; i >= 0; i-- {
+		handler = chain[i](handler)
+	}
+	return Handle(handler)
+}
+
+// Gin runs a Middleware as gin middleware, for routes whose handler isn't a HandlerFunc
+func Gin(middleware Middleware) gin.HandlerFunc {
+	return Handle(middleware(func(c *gin.Context) error {
+		c.Next()
+		return nil
+	}))
+}
+
+// Role lets through callers whose token carries the role, everyone else gets 403. It must run
+// after RequireAuth
+func Role(role string) Middleware {
+	return func(next HandlerFunc) HandlerFunc {
+		return func(c *gin.Context) error {
+			claims, ok := auth.FromContext(c.Request.Context())
+			if !ok || claims.Role != role {
+				logger.FromContext(c.Request.Context()).Warn("rejected request without required role", "path", c.FullPath(), "role", role, "user_id", claims.UserID)
+				return apierr.Forbidden("Insufficient role")
+			}
+			return next(c)
+		}
+	}
+}
+
+// RequireRole is Role for gin handlers
+func RequireRole(role string) gin.HandlerFunc {
+	return Gin(Role(role))
+}
+
+// FleetScope stores the caller's fleet_id claim for the handlers to scope by. Admins are not
+// scoped, any other token without a fleet is rejected. It must run after RequireAuth
+func FleetScope() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		claims, _ := auth.FromContext(c.Request.Context())
+		if claims.Role == auth.RoleAdmin {
+			c.Next()
+			return
+		}
+
+		if claims.FleetID == "" {
+			logger.FromContext(c.Request.Context()).Warn("rejected token without fleet", "path", c.FullPath(), "user_id", claims.UserID)
+			httpresp.ErrorCode(c, http.StatusForbidden, "forbidden", "Token is not bound to a fleet")
+			return
+		}
+
+		ctx := logger.WithAttrs(c.Request.Context(), "fleet_id", claims.FleetID)
+		c.Request = c.Request.WithContext(auth.WithFleet(ctx, claims.FleetID))
+		c.Next()
+	}
+}
+
+// Deadline bounds the request by the lambda's remaining time, store calls inherit it
+func Deadline() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx, cancel := timeout.Budget(c.Request.Context())
+		defer cancel()
+
+		c.Request = c.Request.WithContext(ctx)
+		c.Next()
+	}
+}
+
+// Recover turns a handler panic into a logged stack trace, a Panics metric and a 500 envelope
+func Recover() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		defer func() {
+			if r := recover(); r != nil {
+				recovery.Handle(c.Request.Context(), "api", r)
+				metrics.Flush(c.Request.Context()) // the local server has no lambda wrapper to flush for it
+				if !c.Writer.Written() {
+					httpresp.Error(c, http.StatusInternalServerError, "Internal server error")
+				}
+				c.Abort()
+			}
+		}()
+		c.Next()
+	}
+}
@@ -0,0 +1,184 @@
+package api
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/Fleexa-Graduation-Project/Backend/internal/auth"
+	"github.com/Fleexa-Graduation-Project/Backend/pkg/apierr"
+)
+
+// records its name on the way in and out, or ends the request when it stops
+func step(name string, calls *[]string, stops bool) Middleware {
+	return func(next HandlerFunc) HandlerFunc {
+		return func(c *gin.Context) error {
+			*calls = append(*calls, name)
+			if stops {
+				return apierr.Forbidden("stopped by " + name)
+			}
+			err := next(c)
+			*calls = append(*calls, name+" done")
+			return err
+		}
+	}
+}
+
+func TestChainOrder(t *testing.T) {
+	tests := []struct {
+		name       string
+		chain      func(calls *[]string) Chain
+		wantCalls  []string
+		wantStatus int
+	}{
+		{
+			name: "outermost first",
+			chain: func(calls *[]string) Chain {
+				return Chain{step("recover", calls, false), step("request id", calls, false)}.Use(step("cors", calls, false), step("auth", calls, false))
+			},
+			wantCalls:  []string{"recover", "request id", "cors", "auth", "handler", "auth done", "cors done", "request id done", "recover done"},
+			wantStatus: http.StatusOK,
+		},
+		{
+			name: "early return skips the rest",
+			chain: func(calls *[]string) Chain {
+				return Chain{step("recover", calls, false), step("auth", calls, true), step("role", calls, false)}
+			},
+			wantCalls:  []string{"recover", "auth", "recover done"},
+			wantStatus: http.StatusForbidden,
+		},
+		{
+			name:       "empty chain runs the handler",
+			chain:      func(calls *[]string) Chain { return Chain{} },
+			wantCalls:  []string{"handler"},
+			wantStatus: http.StatusOK,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var calls []string
+			handler := test.chain(&calls).Then(func(c *gin.Context) error {
+				calls = append(calls, "handler")
+				c.Status(http.StatusOK)
+				return nil
+			})
+
+			recorder := httptest.NewRecorder()
+			c, _ := gin.CreateTestContext(recorder)
+			c.Request = httptest.NewRequest(http.MethodGet, "/", nil)
+			handler(c)
+
+			if !slices.Equal(calls, test.wantCalls) {
+				t.Errorf("calls = %v, want %v", calls, test.wantCalls)
+			}
+			if recorder.Code != test.wantStatus {
+				t.Errorf("status = %d, want %d", recorder.Code, test.wantStatus)
+			}
+		})
+	}
+}
+
+func TestChainUseKeepsReceiver(t *testing.T) {
+	var calls []string
+	base := make(Chain, 0, 4)
+	base = base.Use(step("group", &calls, false))
+
+	// both routes extend the group chain, the second must not overwrite the first's middleware
+	first := base.Use(step("first", &calls, false))
+	second := base.Use(step("second", &calls, false))
+
+	c, _ := gin.CreateTestContext(httptest.NewRecorder())
+	c.Request = httptest.NewRequest(http.MethodGet, "/", nil)
+	first.Then(func(*gin.Context) error { return nil })(c)
+
+	if want := []string{"group", "first", "first done", "group done"}; !slices.Equal(calls, want) {
+		t.Errorf("calls = %v, want %v", calls, want)
+	}
+	if len(base) != 1 || len(second) != 2 {
+		t.Errorf("len(base), len(second) = %d, %d, want 1, 2", len(base), len(second))
+	}
+}
+
+func TestRole(t *testing.T) {
+	tests := []struct {
+		name       string
+		claims     *auth.Claims
+		wantStatus int
+	}{
+		{name: "admin", claims: &auth.Claims{UserID: "u-1", Role: auth.RoleAdmin}, wantStatus: http.StatusNoContent},
+		{name: "other role", claims: &auth.Claims{UserID: "u-2", Role: "viewer"}, wantStatus: http.StatusForbidden},
+		{name: "no claims", wantStatus: http.StatusForbidden},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			router := gin.New()
+			router.Use(func(c *gin.Context) {
+				if test.claims != nil {
+					c.Request = c.Request.WithContext(auth.NewContext(c.Request.Context(), *test.claims))
+				}
+			})
+			// the same check as a chain and as gin middleware
+			router.GET("/chain", Chain{}.Use(Role(auth.RoleAdmin)).Then(func(c *gin.Context) error {
+				c.Status(http.StatusNoContent)
+				return nil
+			}))
+			router.GET("/gin", RequireRole(auth.RoleAdmin), func(c *gin.Context) { c.Status(http.StatusNoContent) })
+
+			for _, path := range []string{"/chain", "/gin"} {
+				recorder := httptest.NewRecorder()
+				router.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, path, nil))
+				if recorder.Code != test.wantStatus {
+					t.Errorf("%s status = %d, want %d", path, recorder.Code, test.wantStatus)
+				}
+			}
+		})
+	}
+}
+
+func TestFleetScope(t *testing.T) {
+	tests := []struct {
+		name       string
+		claims     auth.Claims
+		wantStatus int
+		wantFleet  string
+		wantScoped bool
+	}{
+		{name: "fleet user", claims: auth.Claims{UserID: "u-1", FleetID: "fleet-a"}, wantStatus: http.StatusNoContent, wantFleet: "fleet-a", wantScoped: true},
+		{name: "admin is unscoped", claims: auth.Claims{UserID: "u-2", Role: auth.RoleAdmin, FleetID: "fleet-a"}, wantStatus: http.StatusNoContent},
+		{name: "token without a fleet", claims: auth.Claims{UserID: "u-3"}, wantStatus: http.StatusForbidden},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var fleetID string
+			var scoped, reached bool
+
+			router := gin.New()
+			router.Use(func(c *gin.Context) {
+				c.Request = c.Request.WithContext(auth.NewContext(c.Request.Context(), test.claims))
+			}, FleetScope())
+			router.GET("/", func(c *gin.Context) {
+				reached = true
+				fleetID, scoped = auth.FleetFromContext(c.Request.Context())
+				c.Status(http.StatusNoContent)
+			})
+
+			recorder := httptest.NewRecorder()
+			router.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/", nil))
+			if recorder.Code != test.wantStatus {
+				t.Fatalf("status = %d, want %d", recorder.Code, test.wantStatus)
+			}
+			if reached != (test.wantStatus == http.StatusNoContent) {
+				t.Fatalf("handler reached = %v", reached)
+			}
+			if fleetID != test.wantFleet || scoped != test.wantScoped {
+				t.Errorf("FleetFromContext() = %q, %v, want %q, %v", fleetID, scoped, test.wantFleet, test.wantScoped)
+			}
+		})
+	}
+}
@@ -0,0 +1,102 @@
+package api
+
+import (
+	"net/http"
+
+	"github.com/Fleexa-Graduation-Project/Backend/internal/api/handlers"
+	"github.com/Fleexa-Graduation-Project/Backend/internal/auth"
+	"github.com/Fleexa-Graduation-Project/Backend/internal/idempotency"
+	"github.com/Fleexa-Graduation-Project/Backend/internal/maintenance"
+	"github.com/Fleexa-Graduation-Project/Backend/internal/ratelimit"
+	"github.com/Fleexa-Graduation-Project/Backend/pkg/httpresp"
+	"github.com/gin-gonic/gin"
+)
+
+// globalMiddleware runs on every route, outermost first. gin runs them in this order and a
+// middleware that aborts (401, 413, 503...) skips everything after it:
+//
+//  1. AccessLog: one structured line per request with its final status, panics included.
+//     Left out with ACCESS_LOG_ENABLED=false
+//  2. Latency: times everything below, so it must stay above Recover to see panics as 500s
+//  3. Gzip: buffers the response, above Recover so the 500 of a panic is flushed too
+//  4. Recover: a panic anywhere below becomes a 500 envelope
+//  5. RequestID: every later log line carries request_id
+//  6. Deadline: store calls below inherit the lambda deadline
+//  7. CORS: preflights are answered before auth, errors still carry the allow headers
+//  8. BodyLimit: router wide default, routes override it with their own BodyLimit
+//
+// Route groups add theirs after these (maintenance, auth, throttle, fleet scope, idempotency on
+// /api/v1), and single routes after the group's (a tighter BodyLimit, then the route's Chain:
+// Role for the admin routes, outermost first, around the handler). Throttle
+// runs per route, right after RequireAuth so it buckets by the claims it stored, and on the
+// routes without auth by api key or source ip
+func globalMiddleware() []gin.HandlerFunc {
+	middleware := []gin.HandlerFunc{}
+	if AccessLogEnabled() {
+		middleware = append(middleware, AccessLog())
+	}
+	return append(middleware, Latency(), Gzip(GzipMinBytes()), Recover(), RequestID(), Deadline(), CORS(), BodyLimit(MaxBodyBytes()))
+}
+
+// NewRouter builds the gin engine with every api route registered, a nil maintenance switch never
+// pauses writes, a nil idempotency store ignores Idempotency-Key and a nil throttle never limits
+func NewRouter(deviceHandler *handlers.DeviceHandler, healthHandler *handlers.HealthHandler, maintenanceSwitch *maintenance.Switch, idempotencyStore *idempotency.Store, throttle *ratelimit.APIThrottle) *gin.Engine {
+	// gin.Default's recovery writes a plain text 500, ours logs the stack and keeps the json envelope
+	router := gin.New()
+	router.Use(globalMiddleware()...)
+
+	// answer with 405 instead of 404 when the path exists under another method
+	router.HandleMethodNotAllowed = true
+
+	router.NoRoute(func(c *gin.Context) {
+		httpresp.Error(c, http.StatusNotFound, "Route not found")
+	})
+	router.NoMethod(func(c *gin.Context) {
+		httpresp.Error(c, http.StatusMethodNotAllowed, "Method not allowed")
+	})
+
+	router.GET("/ping", Throttle(throttle), func(c *gin.Context) {
+		httpresp.JSON(c, http.StatusOK, gin.H{"message": "pong"})
+	})
+	router.GET("/health", Throttle(throttle), healthHandler.GetHealth)
+
+	admin := Chain{}.Use(Role(auth.RoleAdmin))
+
+	//grouping routes
+	v1 := router.Group("/api/v1", Maintenance(maintenanceSwitch), RequireAuth(), Throttle(throttle), FleetScope(), Idempotency(idempotencyStore))
+	{
+		v1.GET("/devices", deviceHandler.GetDevices)
+		v1.POST("/devices", BodyLimit(4<<10), Handle(deviceHandler.RegisterDevice))
+		v1.POST("/devices/import", Handle(deviceHandler.ImportDevices))
+		v1.GET("/alerts", deviceHandler.GetSortedAlerts)
+		v1.GET("/devices/:id", deviceHandler.GetDeviceByID)
+		v1.PATCH("/devices/:id", BodyLimit(4<<10), Handle(deviceHandler.UpdateDevice))
+		v1.DELETE("/devices/:id", admin.Then(deviceHandler.DeleteDevice))
+		v1.PUT("/devices/:id/tags/:tag", Handle(deviceHandler.AddDeviceTag))
+		v1.DELETE("/devices/:id/tags/:tag", Handle(deviceHandler.RemoveDeviceTag))
+		v1.GET("/devices/:id/telemetry", deviceHandler.GetDeviceTelemetry)
+		v1.GET("/devices/:id/telemetry/export", Handle(deviceHandler.ExportTelemetry))
+		v1.GET("/devices/:id/alerts", deviceHandler.GetDeviceAlerts)
+		v1.GET("/devices/:id/driving-events", Handle(deviceHandler.GetDrivingEvents))
+		v1.GET("/devices/:id/ota", Handle(deviceHandler.GetOTAStatus))
+		v1.GET("/system/overview", deviceHandler.GetSystemOverview)
+		v1.POST("/devices/:id/commands", BodyLimit(16<<10), deviceHandler.SendCommand)
+		v1.GET("/devices/:id/commands/:command_id", Typed(deviceHandler.GetCommand))
+		v1.GET("/devices/:id/shadow", Handle(deviceHandler.GetShadow))
+		v1.PATCH("/devices/:id/shadow", BodyLimit(16<<10), Handle(deviceHandler.UpdateShadow))
+		v1.GET("/fleets/:id/stats", Handle(deviceHandler.GetFleetStats))
+		v1.GET("/fleets/:id/current", Handle(deviceHandler.GetFleetCurrent))
+		v1.GET("/fleets/:id/breaches", Handle(deviceHandler.GetFleetBreaches))
+		v1.POST("/fleets/:id/deactivate", admin.Then(deviceHandler.DeactivateFleet))
+		v1.GET("/audit", admin.Then(deviceHandler.GetAuditEvents))
+		v1.POST("/provisioning/tokens", BodyLimit(4<<10), admin.Then(deviceHandler.CreateProvisioningToken))
+		if InternalMetricsEnabled() {
+			v1.GET("/internal/metrics", RequireRole(auth.RoleAdmin), InternalMetrics)
+		}
+	}
+
+	// devices claim their token before they have any credentials, so this one skips auth
+	router.POST("/api/v1/provisioning/claim", Throttle(throttle), Maintenance(maintenanceSwitch), BodyLimit(4<<10), Handle(deviceHandler.ClaimDevice))
+
+	return router
+}
@@ -0,0 +1,87 @@
+package api
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"math"
+	"net"
+	"net/http"
+	"strconv"
+
+	"github.com/awslabs/aws-lambda-go-api-proxy/core"
+	"github.com/gin-gonic/gin"
+
+	"github.com/Fleexa-Graduation-Project/Backend/internal/auth"
+	"github.com/Fleexa-Graduation-Project/Backend/internal/ratelimit"
+	"github.com/Fleexa-Graduation-Project/Backend/pkg/httpresp"
+	"github.com/Fleexa-Graduation-Project/Backend/pkg/logger"
+	"github.com/Fleexa-Graduation-Project/Backend/pkg/metrics"
+)
+
+// Throttle rate limits every caller with a token bucket and answers 429 with Retry-After once it
+// is empty. Behind RequireAuth the caller is the user of the claims it stored, otherwise (and for
+// tokens without a subject) an api key api gateway checked, then the source ip api gateway saw;
+// the headers a client sets itself are never trusted. The plan is the one of the caller key, then
+// of the token's fleet, then the default. Throttle errors and timeouts let the request through.
+// A nil throttle disables it
+func Throttle(throttle *ratelimit.APIThrottle) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if throttle == nil {
+			c.Next()
+			return
+		}
+
+		ctx := c.Request.Context()
+		log := logger.FromContext(ctx)
+		caller, planKeys := throttleCaller(c)
+		allowed, retryAfter, err := throttle.Take(ctx, caller, throttle.Config.PlanFor(planKeys...))
+		if err != nil {
+			log.Warn("api throttle unavailable, request let through", "caller", caller, "error", err)
+		}
+		if !allowed {
+			log.Warn("request throttled", "reason", "throttled", "caller", caller, "path", c.FullPath())
+			metrics.Count("RequestsThrottled", 1, nil)
+			c.Header("Retry-After", strconv.Itoa(int(math.Max(1, math.Ceil(retryAfter.Seconds())))))
+			httpresp.ErrorCode(c, http.StatusTooManyRequests, "rate_limited", "Too many requests, retry later")
+			return
+		}
+		c.Next()
+	}
+}
+
+// the bucket of the request and the keys its plan is looked up by, most specific first
+func throttleCaller(c *gin.Context) (string, []string) {
+	if claims, ok := auth.FromContext(c.Request.Context()); ok {
+		user := claims.UserID
+		if user == "" {
+			user = claims.Subject
+		}
+		if user != "" {
+			caller := "user:" + user
+			if claims.FleetID == "" {
+				return caller, []string{caller}
+			}
+			return caller, []string{caller, "fleet:" + claims.FleetID}
+		}
+	}
+
+	// api keys are secrets, the bucket and the logs only ever see their hash
+	if gateway, ok := core.GetAPIGatewayContextFromContext(c.Request.Context()); ok && gateway.Identity.APIKey != "" {
+		caller := "key:" + keyHash(gateway.Identity.APIKey)
+		return caller, []string{caller}
+	}
+
+	// the adapters set RemoteAddr to the gateway's source ip, locally it is host:port
+	ip := c.Request.RemoteAddr
+	if host, _, err := net.SplitHostPort(ip); err == nil {
+		ip = host
+	}
+	caller := "ip:" + ip
+	return caller, []string{caller}
+}
+
+// first 16 hex digits of the key's sha256, what API_THROTTLE_PLANS keys a "key:" plan by
+func keyHash(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(sum[:])[:16]
+}
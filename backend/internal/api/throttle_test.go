@@ -0,0 +1,120 @@
+package api
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/awslabs/aws-lambda-go-api-proxy/core"
+	"github.com/gin-gonic/gin"
+	"github.com/golang-jwt/jwt/v5"
+
+	"github.com/Fleexa-Graduation-Project/Backend/internal/auth"
+)
+
+func TestThrottleCaller(t *testing.T) {
+	const apiKey = "live-key-0123456789"
+
+	tests := []struct {
+		name         string
+		claims       *auth.Claims
+		apiKey       string
+		remoteAddr   string
+		wantCaller   string
+		wantPlanKeys []string
+	}{
+		{
+			name:         "user with a fleet",
+			claims:       &auth.Claims{UserID: "u-1", FleetID: "fleet-a"},
+			remoteAddr:   "10.0.0.1:5000",
+			wantCaller:   "user:u-1",
+			wantPlanKeys: []string{"user:u-1", "fleet:fleet-a"},
+		},
+		{
+			name:         "subject when there is no user id",
+			claims:       &auth.Claims{RegisteredClaims: jwt.RegisteredClaims{Subject: "svc-1"}},
+			remoteAddr:   "10.0.0.1:5000",
+			wantCaller:   "user:svc-1",
+			wantPlanKeys: []string{"user:svc-1"},
+		},
+		{
+			name:         "claims without a subject fall back to the source ip",
+			claims:       &auth.Claims{FleetID: "fleet-a"},
+			remoteAddr:   "10.0.0.1:5000",
+			wantCaller:   "ip:10.0.0.1",
+			wantPlanKeys: []string{"ip:10.0.0.1"},
+		},
+		{
+			name:         "api key is hashed",
+			apiKey:       apiKey,
+			remoteAddr:   "10.0.0.1:5000",
+			wantCaller:   "key:" + keyHash(apiKey),
+			wantPlanKeys: []string{"key:" + keyHash(apiKey)},
+		},
+		{
+			name:         "source ip from the gateway",
+			remoteAddr:   "203.0.113.7",
+			wantCaller:   "ip:203.0.113.7",
+			wantPlanKeys: []string{"ip:203.0.113.7"},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			request := httptest.NewRequest(http.MethodGet, "/api/v1/devices", nil)
+			request.RemoteAddr = test.remoteAddr
+			// a forged header must never pick the bucket
+			request.Header.Set("X-Forwarded-For", "198.51.100.1")
+			if test.apiKey != "" {
+				// how the lambda adapter hands the gateway's request context to gin
+				var accessor core.RequestAccessor
+				proxied, err := accessor.EventToRequestWithContext(context.Background(), events.APIGatewayProxyRequest{
+					HTTPMethod:     http.MethodGet,
+					Path:           "/api/v1/devices",
+					RequestContext: events.APIGatewayProxyRequestContext{Identity: events.APIGatewayRequestIdentity{APIKey: test.apiKey}},
+				})
+				if err != nil {
+					t.Fatalf("EventToRequestWithContext: %v", err)
+				}
+				request = request.WithContext(proxied.Context())
+			}
+			ctx := request.Context()
+			if test.claims != nil {
+				ctx = auth.NewContext(ctx, *test.claims)
+			}
+
+			c, _ := gin.CreateTestContext(httptest.NewRecorder())
+			c.Request = request.WithContext(ctx)
+
+			caller, planKeys := throttleCaller(c)
+			if caller != test.wantCaller {
+				t.Errorf("caller = %q, want %q", caller, test.wantCaller)
+			}
+			if !slices.Equal(planKeys, test.wantPlanKeys) {
+				t.Errorf("plan keys = %v, want %v", planKeys, test.wantPlanKeys)
+			}
+			if strings.Contains(caller, apiKey) {
+				t.Errorf("caller %q leaks the api key", caller)
+			}
+		})
+	}
+}
+
+func TestKeyHash(t *testing.T) {
+	tests := []struct {
+		key  string
+		want string
+	}{
+		{key: "", want: "e3b0c44298fc1c14"},
+		{key: "abc", want: "ba7816bf8f01cfea"},
+	}
+	for _, test := range tests {
+		if got := keyHash(test.key); got != test.want {
+			t.Errorf("keyHash(%q) = %q, want %q", test.key, got, test.want)
+		}
+	}
+}
@@ -0,0 +1,70 @@
+package api
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/binding"
+
+	"github.com/Fleexa-Graduation-Project/Backend/pkg/apierr"
+	"github.com/Fleexa-Graduation-Project/Backend/pkg/httpreq"
+	"github.com/Fleexa-Graduation-Project/Backend/pkg/httpresp"
+)
+
+// TypedFunc is a handler that never sees gin: it gets the decoded request and returns the
+// response body. In is a struct, its fields tagged `uri:"id"` are path params, `form:"limit"`
+// query params and the json ones the body. Tag the path and query fields `json:"-"` so a body
+// can't set them
+type TypedFunc[In, Out any] func(ctx context.Context, in In) (Out, error)
+
+// StatusCoder lets a response pick its status, responses that don't answer 200
+type StatusCoder interface {
+	StatusCode() int
+}
+
+// Typed adapts a TypedFunc for the router. Path and query params are bound first, then a
+// non-empty body is decoded strictly and the binding tags of the whole struct run: a param that
+// doesn't parse is a 400, failed tags a 422 listing the fields. Out is written as json, an error
+// is rendered by apierr.Render. Handlers that need the raw request keep using Handle
+func Typed[In, Out any](handler TypedFunc[In, Out]) gin.HandlerFunc {
+	return Handle(func(c *gin.Context) error {
+		var in In
+		if err := bindParams(c, &in); err != nil {
+			return err
+		}
+		if c.Request.ContentLength != 0 {
+			if !httpreq.DecodeBody(c, &in) {
+				return nil
+			}
+		} else if err := httpreq.Validate(&in); err != nil {
+			return err
+		}
+
+		out, err := handler(c.Request.Context(), in)
+		if err != nil {
+			return err
+		}
+
+		status := http.StatusOK
+		if coder, ok := any(out).(StatusCoder); ok {
+			status = coder.StatusCode()
+		}
+		httpresp.JSON(c, status, out)
+		return nil
+	})
+}
+
+func bindParams(c *gin.Context, target any) error {
+	path := make(map[string][]string, len(c.Params))
+	for _, param := range c.Params {
+		path[param.Key] = []string{param.Value}
+	}
+	if err := binding.MapFormWithTag(target, path, "uri"); err != nil {
+		return apierr.BadRequest("invalid path parameter").Wrap(err)
+	}
+	if err := binding.MapFormWithTag(target, c.Request.URL.Query(), "form"); err != nil {
+		return apierr.BadRequest("invalid query parameter").Wrap(err)
+	}
+	return nil
+}
@@ -0,0 +1,34 @@
+package auth
+
+import "context"
+
+// RoleAdmin is the role claim of operators allowed to run fleet-wide actions
+const RoleAdmin = "admin"
+
+type (
+	claimsKey struct{}
+	fleetKey  struct{}
+)
+
+// NewContext stores the caller claims for downstream handlers
+func NewContext(ctx context.Context, claims Claims) context.Context {
+	return context.WithValue(ctx, claimsKey{}, claims)
+}
+
+// FromContext returns the claims set by the auth middleware
+func FromContext(ctx context.Context) (Claims, bool) {
+	claims, ok := ctx.Value(claimsKey{}).(Claims)
+	return claims, ok
+}
+
+// WithFleet limits the request to the caller's fleet
+func WithFleet(ctx context.Context, fleetID string) context.Context {
+	return context.WithValue(ctx, fleetKey{}, fleetID)
+}
+
+// FleetFromContext returns the fleet every store query must be scoped to, ok is false for
+// admins, who see all fleets
+func FleetFromContext(ctx context.Context) (string, bool) {
+	fleetID, ok := ctx.Value(fleetKey{}).(string)
+	return fleetID, ok
+}
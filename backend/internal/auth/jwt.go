@@ -0,0 +1,108 @@
+package auth
+
+import (
+	"crypto/rsa"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+var (
+	ErrMissingToken   = errors.New("missing bearer token")
+	ErrTokenExpired   = errors.New("token has expired")
+	ErrTokenMalformed = errors.New("malformed token")
+	ErrTokenInvalid   = errors.New("invalid token")
+)
+
+// Claims carried by the tokens issued to app users
+type Claims struct {
+	UserID  string `json:"user_id"`
+	Role    string `json:"role"`
+	FleetID string `json:"fleet_id"`
+	jwt.RegisteredClaims
+}
+
+var (
+	hmacSecret []byte
+	rsaPublic  *rsa.PublicKey
+	keysErr    error
+	keysOnce   sync.Once
+)
+
+// keys are read once per cold start, JWT_SECRET for HS256 and JWT_PUBLIC_KEY (PEM) for RS256
+func loadKeys() error {
+	keysOnce.Do(func() {
+		if secret := os.Getenv("JWT_SECRET"); secret != "" {
+			hmacSecret = []byte(secret)
+		}
+
+		if pem := os.Getenv("JWT_PUBLIC_KEY"); pem != "" {
+			key, err := jwt.ParseRSAPublicKeyFromPEM([]byte(strings.ReplaceAll(pem, `\n`, "\n")))
+			if err != nil {
+				keysErr = fmt.Errorf("failed to parse JWT_PUBLIC_KEY: %w", err)
+				return
+			}
+			rsaPublic = key
+		}
+
+		if hmacSecret == nil && rsaPublic == nil {
+			keysErr = fmt.Errorf("neither JWT_SECRET nor JWT_PUBLIC_KEY environment variable is set")
+		}
+	})
+
+	return keysErr
+}
+
+// ValidateJWT verifies the signature, exp and nbf of a token and returns its claims
+func ValidateJWT(token string) (Claims, error) {
+	var claims Claims
+
+	if token == "" {
+		return claims, ErrMissingToken
+	}
+	if err := loadKeys(); err != nil {
+		return claims, err
+	}
+
+	_, err := jwt.ParseWithClaims(token, &claims, keyFor, jwt.WithValidMethods([]string{"HS256", "RS256"}))
+	switch {
+	case err == nil:
+	case errors.Is(err, jwt.ErrTokenExpired):
+		return Claims{}, ErrTokenExpired
+	case errors.Is(err, jwt.ErrTokenMalformed):
+		return Claims{}, ErrTokenMalformed
+	default:
+		return Claims{}, fmt.Errorf("%w: %v", ErrTokenInvalid, err)
+	}
+
+	if claims.UserID == "" {
+		claims.UserID = claims.Subject
+	}
+	if claims.UserID == "" {
+		return Claims{}, fmt.Errorf("%w: missing user id", ErrTokenInvalid)
+	}
+
+	return claims, nil
+}
+
+// picking the verification key based on the token signing method
+func keyFor(token *jwt.Token) (interface{}, error) {
+	switch token.Method.(type) {
+	case *jwt.SigningMethodHMAC:
+		if hmacSecret == nil {
+			return nil, fmt.Errorf("HS256 tokens are not accepted")
+		}
+		return hmacSecret, nil
+	case *jwt.SigningMethodRSA:
+		if rsaPublic == nil {
+			return nil, fmt.Errorf("RS256 tokens are not accepted")
+		}
+		return rsaPublic, nil
+	default:
+		return nil, fmt.Errorf("unexpected signing method %s", token.Header["alg"])
+	}
+}
@@ -0,0 +1,33 @@
+package commands
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+)
+
+var ErrUnknownAction = errors.New("unknown command action")
+
+// actions each device type's firmware handles, anything else is rejected before it reaches iot core
+var AllowedActions = map[string][]string{
+	"ac-actuator":   {"SET_STATE", "SET_AC_MODE", "SET_AC_TEMP", "SET_FAN_SPEED", "SET_CURTAIN", "OPEN_CURTAIN", "CLOSE_CURTAIN"},
+	"door-actuator": {"LOCK", "UNLOCK", "EMERGENCY_UNLOCK", "CLEAR_JAM", "RESET_BATTERY"},
+	"door-sensor":   {"FORCE_CLOSE", "RESET_INTRUSION"},
+	"temp-sensor":   {"SET_TARGET_TEMP", "SET_MODE", "RESET"},
+	"light-sensor":  {"CALIBRATE"},
+	"gas-sensor":    {"RESET", "SILENCE_ALARM"},
+}
+
+// NormalizeAction upper-cases the action the way the device firmware compares it
+func NormalizeAction(action string) string {
+	return strings.ToUpper(strings.TrimSpace(action))
+}
+
+// ValidateAction checks the action against the allowlist of the device type
+func ValidateAction(deviceType string, action string) error {
+	if !slices.Contains(AllowedActions[deviceType], NormalizeAction(action)) {
+		return fmt.Errorf("%w: %s is not supported by %s", ErrUnknownAction, NormalizeAction(action), deviceType)
+	}
+	return nil
+}
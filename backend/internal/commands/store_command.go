@@ -2,16 +2,35 @@ package commands
 
 import (
 	"context"
+	"errors"
 	"fmt"
-	"os"
 	"time"
 
 	"github.com/aws/aws-sdk-go-v2/aws"
 	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/attributevalue"
 	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
 
 	"github.com/Fleexa-Graduation-Project/Backend/models"
+	appconfig "github.com/Fleexa-Graduation-Project/Backend/pkg/config"
 	"github.com/Fleexa-Graduation-Project/Backend/pkg/db"
+	"github.com/Fleexa-Graduation-Project/Backend/pkg/timeout"
+)
+
+const (
+	StatusPending  = "PENDING"
+	StatusAcked    = "ACKED"
+	StatusFailed   = "FAILED"
+	StatusTimedOut = "TIMED_OUT"
+
+	// sparse index of the commands still waiting for their ack, keyed device_id + pending_since
+	pendingIndex = "PendingIndex"
+)
+
+var (
+	ErrCommandNotFound = errors.New("command not found")
+	// the command was acked, failed or timed out already, a late or repeated ack changes nothing
+	ErrNotPending = errors.New("command is no longer pending")
 )
 
 type CommandStore struct {
@@ -20,7 +39,7 @@ type CommandStore struct {
 }
 
 func NewCommandStore() (*CommandStore, error) {
-	tableName := os.Getenv("DYNAMODB_COMMANDS_TABLE")
+	tableName := appconfig.TableName("DYNAMODB_COMMANDS_TABLE")
 	if tableName == "" {
 		return nil, fmt.Errorf("DYNAMODB_COMMANDS_TABLE environment variable is not set")
 	}
@@ -44,6 +63,14 @@ func (store *CommandStore) SaveCommand(ctx context.Context, cmd models.Command)
 		cmd.Timestamp = time.Now().Unix()
 	}
 
+	if cmd.SentAtMs == 0 {
+		cmd.SentAtMs = time.Now().UnixMilli()
+	}
+
+	if cmd.Status == StatusPending {
+		cmd.PendingSince = cmd.Timestamp
+	}
+
 	item, err := attributevalue.MarshalMap(cmd)
 	if err != nil {
 		return fmt.Errorf("failed to marshal command: %w", err)
@@ -54,10 +81,162 @@ func (store *CommandStore) SaveCommand(ctx context.Context, cmd models.Command)
 		Item:      item,
 	}
 
-	_, err = store.Client.PutItem(ctx, input)
+	callCtx, cancel := timeout.Call(ctx)
+	defer cancel()
+	_, err = store.Client.PutItem(callCtx, input)
 	if err != nil {
 		return fmt.Errorf("failed to store command in dynamodb: %w", err)
 	}
 
 	return nil
 }
+
+// returns nil when the command doesn't exist
+func (store *CommandStore) GetCommand(ctx context.Context, requestID string) (*models.Command, error) {
+	input := &dynamodb.GetItemInput{
+		TableName: aws.String(store.TableName),
+		Key: map[string]types.AttributeValue{
+			"request_id": &types.AttributeValueMemberS{Value: requestID},
+		},
+	}
+
+	callCtx, cancel := timeout.Call(ctx)
+	defer cancel()
+	result, err := store.Client.GetItem(callCtx, input)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get command %s: %w", requestID, err)
+	}
+
+	if result.Item == nil {
+		return nil, nil
+	}
+
+	var cmd models.Command
+	if err := attributevalue.UnmarshalMap(result.Item, &cmd); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal command %s: %w", requestID, err)
+	}
+
+	return &cmd, nil
+}
+
+// Acknowledge records the device's answer to a pending command: StatusAcked or StatusFailed,
+// when it came in and the round trip since the command was sent. ErrCommandNotFound when the
+// command doesn't exist or belongs to another device, ErrNotPending when it was already settled
+func (store *CommandStore) Acknowledge(ctx context.Context, requestID, deviceID, status, reason string, at time.Time) (*models.Command, error) {
+	cmd, err := store.GetCommand(ctx, requestID)
+	if err != nil {
+		return nil, err
+	}
+	if cmd == nil || cmd.DeviceID != deviceID {
+		return nil, ErrCommandNotFound
+	}
+
+	// commands stored before sent_at_ms existed only have the second they were sent
+	roundTrip := at.UnixMilli() - cmd.SentAtMs
+	if cmd.SentAtMs == 0 {
+		roundTrip = at.UnixMilli() - cmd.Timestamp*1000
+	}
+
+	update := "SET #status = :status, acked_at = :at, round_trip_ms = :rtt"
+	values := map[string]types.AttributeValue{
+		":status":  &types.AttributeValueMemberS{Value: status},
+		":pending": &types.AttributeValueMemberS{Value: StatusPending},
+		":at":      &types.AttributeValueMemberN{Value: fmt.Sprint(at.Unix())},
+		":rtt":     &types.AttributeValueMemberN{Value: fmt.Sprint(max(roundTrip, 0))},
+	}
+	names := map[string]string{"#status": "status"}
+	if reason != "" {
+		update += ", #error = :error"
+		values[":error"] = &types.AttributeValueMemberS{Value: reason}
+		names["#error"] = "error"
+	}
+
+	return store.settle(ctx, requestID, update+" REMOVE pending_since", names, values)
+}
+
+// TimeOut marks a command the device never answered as StatusTimedOut, ErrNotPending when the
+// ack came in first
+func (store *CommandStore) TimeOut(ctx context.Context, requestID string, at time.Time) (*models.Command, error) {
+	return store.settle(ctx, requestID,
+		"SET #status = :status, timed_out_at = :at REMOVE pending_since",
+		map[string]string{"#status": "status"},
+		map[string]types.AttributeValue{
+			":status":  &types.AttributeValueMemberS{Value: StatusTimedOut},
+			":pending": &types.AttributeValueMemberS{Value: StatusPending},
+			":at":      &types.AttributeValueMemberN{Value: fmt.Sprint(at.Unix())},
+		})
+}
+
+// MarkUndelivered marks a pending command StatusFailed when it never reached the broker, so
+// neither a late ack nor the sweeper touches it again
+func (store *CommandStore) MarkUndelivered(ctx context.Context, requestID, reason string) (*models.Command, error) {
+	return store.settle(ctx, requestID,
+		"SET #status = :status, #error = :error REMOVE pending_since",
+		map[string]string{"#status": "status", "#error": "error"},
+		map[string]types.AttributeValue{
+			":status":  &types.AttributeValueMemberS{Value: StatusFailed},
+			":pending": &types.AttributeValueMemberS{Value: StatusPending},
+			":error":   &types.AttributeValueMemberS{Value: reason},
+		})
+}
+
+// moves a pending command to its final status, only one of the ack and the sweeper wins
+func (store *CommandStore) settle(ctx context.Context, requestID, update string, names map[string]string, values map[string]types.AttributeValue) (*models.Command, error) {
+	callCtx, cancel := timeout.Call(ctx)
+	defer cancel()
+	result, err := store.Client.UpdateItem(callCtx, &dynamodb.UpdateItemInput{
+		TableName: aws.String(store.TableName),
+		Key: map[string]types.AttributeValue{
+			"request_id": &types.AttributeValueMemberS{Value: requestID},
+		},
+		UpdateExpression:          aws.String(update),
+		ConditionExpression:       aws.String("#status = :pending"),
+		ExpressionAttributeNames:  names,
+		ExpressionAttributeValues: values,
+		ReturnValues:              types.ReturnValueAllNew,
+	})
+	if errors.Is(db.Condition(err), db.ErrConditionFailed) {
+		return nil, ErrNotPending
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to update status of command %s: %w", requestID, err)
+	}
+
+	var cmd models.Command
+	if err := attributevalue.UnmarshalMap(result.Attributes, &cmd); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal command %s: %w", requestID, err)
+	}
+	return &cmd, nil
+}
+
+// ListPendingBefore returns the commands still pending that were sent before the given unix
+// time. The index only holds pending commands, so the scan stays as small as the backlog
+func (store *CommandStore) ListPendingBefore(ctx context.Context, before int64) ([]models.Command, error) {
+	input := &dynamodb.ScanInput{
+		TableName:        aws.String(store.TableName),
+		IndexName:        aws.String(pendingIndex),
+		FilterExpression: aws.String("pending_since < :before"),
+		ExpressionAttributeValues: map[string]types.AttributeValue{
+			":before": &types.AttributeValueMemberN{Value: fmt.Sprint(before)},
+		},
+	}
+
+	pending := []models.Command{}
+	paginator := dynamodb.NewScanPaginator(store.Client, input)
+	for paginator.HasMorePages() {
+		callCtx, cancel := timeout.Call(ctx)
+		page, err := paginator.NextPage(callCtx)
+		cancel()
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan pending commands: %w", err)
+		}
+
+		var items []models.Command
+		if err = attributevalue.UnmarshalListOfMaps(page.Items, &items); err != nil {
+			return nil, fmt.Errorf("failed to unmarshal pending commands: %w", err)
+		}
+		pending = append(pending, items...)
+	}
+
+	return pending, nil
+}
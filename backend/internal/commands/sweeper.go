@@ -0,0 +1,57 @@
+package commands
+
+import (
+	"context"
+	"errors"
+	"os"
+	"time"
+
+	"github.com/Fleexa-Graduation-Project/Backend/pkg/logger"
+)
+
+// how long a device has to acknowledge a command before the sweeper gives up on it
+const DefaultAckTimeout = 2 * time.Minute
+
+// AckTimeout is COMMAND_ACK_TIMEOUT, DefaultAckTimeout when unset or invalid
+func AckTimeout() time.Duration {
+	if parsed, err := time.ParseDuration(os.Getenv("COMMAND_ACK_TIMEOUT")); err == nil && parsed > 0 {
+		return parsed
+	}
+	return DefaultAckTimeout
+}
+
+// Sweeper runs on a schedule and marks the commands left unanswered for longer than Timeout as
+// StatusTimedOut
+type Sweeper struct {
+	Store   *CommandStore
+	Timeout time.Duration
+}
+
+func (sweeper *Sweeper) Sweep(ctx context.Context) error {
+	log := logger.FromContext(ctx)
+	now := time.Now()
+
+	overdue, err := sweeper.Store.ListPendingBefore(ctx, now.Add(-sweeper.Timeout).Unix())
+	if err != nil {
+		return err
+	}
+
+	timedOut, failed := 0, 0
+	for _, cmd := range overdue {
+		_, err := sweeper.Store.TimeOut(ctx, cmd.RequestID, now)
+		if errors.Is(err, ErrNotPending) {
+			continue // the ack came in between the scan and the update
+		}
+		if err != nil {
+			// the next run picks it up again
+			log.Warn("failed to time out command", "command_id", cmd.RequestID, "device_id", cmd.DeviceID, "error", err)
+			failed++
+			continue
+		}
+		log.Info("command timed out", "reason", "command_timed_out", "command_id", cmd.RequestID, "device_id", cmd.DeviceID, "action", cmd.Action)
+		timedOut++
+	}
+
+	log.Info("command sweep complete", "overdue", len(overdue), "timed_out", timedOut, "failed", failed, "timeout", sweeper.Timeout.String())
+	return nil
+}
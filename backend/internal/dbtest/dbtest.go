@@ -0,0 +1,102 @@
+//go:build integration
+
+// Package dbtest runs store tests against a real DynamoDB, the dynamodb-local container of
+// scripts/dynamodb-local.sh. The tests are built with -tags integration and skip themselves when
+// DYNAMODB_ENDPOINT is unset or doesn't answer:
+//
+//	./scripts/dynamodb-local.sh up
+//	DYNAMODB_ENDPOINT=http://localhost:8000 go test -tags integration ./...
+//	./scripts/dynamodb-local.sh down
+package dbtest
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+
+	"github.com/Fleexa-Graduation-Project/Backend/pkg/db"
+)
+
+// dynamodb-local takes a few seconds to start, a test run that can't reach it in this long skips
+const reachTimeout = 3 * time.Second
+
+// the characters a table name may hold
+var tableNameUnsafe = regexp.MustCompile(`[^A-Za-z0-9_.-]`)
+
+// Client points db.Client at DYNAMODB_ENDPOINT, the way the lambdas build it, and returns it.
+// The test is skipped when the endpoint is unset or unreachable
+func Client(t *testing.T) *dynamodb.Client {
+	t.Helper()
+	endpoint := os.Getenv("DYNAMODB_ENDPOINT")
+	if endpoint == "" {
+		t.Skip("DYNAMODB_ENDPOINT is not set, start dynamodb-local with scripts/dynamodb-local.sh up")
+	}
+
+	// dynamodb-local accepts any credentials, the sdk still wants some
+	for name, value := range map[string]string{"AWS_ACCESS_KEY_ID": "local", "AWS_SECRET_ACCESS_KEY": "local", "AWS_REGION": "us-east-1"} {
+		if os.Getenv(name) == "" {
+			t.Setenv(name, value)
+		}
+	}
+	// the tables are created under their bare names
+	t.Setenv("RESOURCE_PREFIX", "")
+
+	ctx := context.Background()
+	if err := db.NewDynamoDBClient(ctx); err != nil {
+		t.Fatalf("NewDynamoDBClient: %v", err)
+	}
+
+	pingCtx, cancel := context.WithTimeout(ctx, reachTimeout)
+	defer cancel()
+	if _, err := db.Client.ListTables(pingCtx, &dynamodb.ListTablesInput{Limit: aws.Int32(1)}); err != nil {
+		t.Skipf("dynamodb-local is not reachable at %s: %v", endpoint, err)
+	}
+	return db.Client
+}
+
+// CreateTable creates the table under a name of its own for this test, sets envName to it for
+// the store constructors and deletes the table when the test ends
+func CreateTable(t *testing.T, client *dynamodb.Client, envName string, input dynamodb.CreateTableInput) string {
+	t.Helper()
+	ctx := context.Background()
+
+	name := fmt.Sprintf("%s_%s_%d", envName, tableNameUnsafe.ReplaceAllString(t.Name(), "_"), time.Now().UnixNano())
+	input.TableName = aws.String(name)
+	input.BillingMode = types.BillingModePayPerRequest
+	if _, err := client.CreateTable(ctx, &input); err != nil {
+		t.Fatalf("create table %s: %v", name, err)
+	}
+	t.Cleanup(func() {
+		if _, err := client.DeleteTable(context.Background(), &dynamodb.DeleteTableInput{TableName: aws.String(name)}); err != nil {
+			t.Errorf("delete table %s: %v", name, err)
+		}
+	})
+
+	waiter := dynamodb.NewTableExistsWaiter(client)
+	if err := waiter.Wait(ctx, &dynamodb.DescribeTableInput{TableName: aws.String(name)}, 30*time.Second); err != nil {
+		t.Fatalf("wait for table %s: %v", name, err)
+	}
+
+	t.Setenv(envName, name)
+	return name
+}
+
+func Attribute(name string, attributeType types.ScalarAttributeType) types.AttributeDefinition {
+	return types.AttributeDefinition{AttributeName: aws.String(name), AttributeType: attributeType}
+}
+
+// Key is the key schema of a hash key, and of a range key when rangeKey is not empty
+func Key(hashKey, rangeKey string) []types.KeySchemaElement {
+	schema := []types.KeySchemaElement{{AttributeName: aws.String(hashKey), KeyType: types.KeyTypeHash}}
+	if rangeKey != "" {
+		schema = append(schema, types.KeySchemaElement{AttributeName: aws.String(rangeKey), KeyType: types.KeyTypeRange})
+	}
+	return schema
+}
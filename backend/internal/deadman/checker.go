@@ -0,0 +1,111 @@
+package deadman
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/sns"
+
+	"github.com/Fleexa-Graduation-Project/Backend/pkg/logger"
+	"github.com/Fleexa-Graduation-Project/Backend/pkg/timeout"
+)
+
+// how long the whole fleet may stay silent before the pipeline is considered down
+const DefaultWindow = 15 * time.Minute
+
+// Window is INGESTION_DEADMAN_WINDOW, DefaultWindow when unset or invalid
+func Window() time.Duration {
+	if parsed, err := time.ParseDuration(os.Getenv("INGESTION_DEADMAN_WINDOW")); err == nil && parsed > 0 {
+		return parsed
+	}
+	return DefaultWindow
+}
+
+// Notifier publishes the outage alerts to the sns topic in INGESTION_DEADMAN_TOPIC_ARN
+type Notifier struct {
+	Client   *sns.Client
+	TopicARN string
+}
+
+func NewNotifier(client *sns.Client) (*Notifier, error) {
+	topicARN := os.Getenv("INGESTION_DEADMAN_TOPIC_ARN")
+	if topicARN == "" {
+		return nil, fmt.Errorf("INGESTION_DEADMAN_TOPIC_ARN environment variable is not set")
+	}
+
+	return &Notifier{
+		Client:   client,
+		TopicARN: topicARN,
+	}, nil
+}
+
+func (notifier *Notifier) Publish(ctx context.Context, subject, message string) error {
+	callCtx, cancel := timeout.Call(ctx)
+	defer cancel()
+	_, err := notifier.Client.Publish(callCtx, &sns.PublishInput{
+		TopicArn: aws.String(notifier.TopicARN),
+		Subject:  aws.String(subject),
+		Message:  aws.String(message),
+	})
+	if err != nil {
+		return fmt.Errorf("failed to publish to %s: %w", notifier.TopicARN, err)
+	}
+	return nil
+}
+
+// Checker runs on a schedule and alerts once per outage when no message was processed anywhere
+// in the fleet for longer than Window, and once more when messages flow again
+type Checker struct {
+	Heartbeat *Heartbeat
+	Notifier  *Notifier
+	Window    time.Duration
+}
+
+func (checker *Checker) Check(ctx context.Context) error {
+	log := logger.FromContext(ctx)
+
+	state, err := checker.Heartbeat.Read(ctx)
+	if err != nil {
+		return err
+	}
+	if state == nil {
+		// a pipeline that never processed anything has no outage to report yet
+		log.Warn("no ingestion heartbeat recorded yet")
+		return nil
+	}
+
+	last := time.Unix(state.LastProcessedAt, 0)
+	silence := time.Since(last)
+
+	if silence <= checker.Window {
+		if state.AlertedFor == 0 || state.AlertedFor == state.LastProcessedAt {
+			return nil
+		}
+		cleared, err := checker.Heartbeat.ClearAlert(ctx, state.AlertedFor)
+		if err != nil || !cleared {
+			return err
+		}
+		down := last.Sub(time.Unix(state.AlertedFor, 0)).Round(time.Second)
+		log.Info("ingestion pipeline recovered", "last_processed_at", state.LastProcessedAt, "outage", down.String())
+		return checker.Notifier.Publish(ctx, "Fleexa ingestion recovered",
+			fmt.Sprintf("Telemetry is being processed again as of %s, after about %s without any message.", last.UTC().Format(time.RFC3339), down))
+	}
+
+	if state.AlertedFor == state.LastProcessedAt {
+		return nil // already alerted for this outage
+	}
+	// marked before publishing, two overlapping runs must not both alert. A failed publish is
+	// lost, the error still fails the invocation so the lambda error alarm sees it
+	marked, err := checker.Heartbeat.MarkAlerted(ctx, state.LastProcessedAt)
+	if err != nil || !marked {
+		return err
+	}
+	log.Error("no telemetry processed fleet wide", "reason", "ingestion_silent", "last_processed_at", state.LastProcessedAt,
+		"silence", silence.Round(time.Second).String(), "window", checker.Window.String())
+	return checker.Notifier.Publish(ctx, "Fleexa ingestion stopped",
+		fmt.Sprintf("No telemetry message was processed anywhere in the fleet since %s (%s ago, window %s).",
+			last.UTC().Format(time.RFC3339), silence.Round(time.Second), checker.Window))
+}
@@ -0,0 +1,177 @@
+package deadman
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/attributevalue"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+
+	appconfig "github.com/Fleexa-Graduation-Project/Backend/pkg/config"
+	"github.com/Fleexa-Graduation-Project/Backend/pkg/db"
+	"github.com/Fleexa-Graduation-Project/Backend/pkg/timeout"
+)
+
+const (
+	heartbeatKey = "ingestion_heartbeat"
+	// each container writes the heartbeat at most this often, a busy fleet must not turn the
+	// one item into a hot key
+	DefaultBeatInterval = time.Minute
+)
+
+// control item in DYNAMODB_CONTROL_TABLE, keyed control_key = "ingestion_heartbeat"
+type State struct {
+	LastProcessedAt int64 `dynamodbav:"last_processed_at"`
+	// the LastProcessedAt the current outage was alerted for, 0 when there is none
+	AlertedFor int64 `dynamodbav:"alerted_for,omitempty"`
+}
+
+// Heartbeat records when the ingestion pipeline last processed a message, fleet wide
+type Heartbeat struct {
+	Client    *dynamodb.Client
+	TableName string
+	Every     time.Duration
+
+	mu     sync.Mutex
+	beatAt time.Time
+}
+
+func NewHeartbeat() (*Heartbeat, error) {
+	tableName := appconfig.TableName("DYNAMODB_CONTROL_TABLE")
+	if tableName == "" {
+		return nil, fmt.Errorf("DYNAMODB_CONTROL_TABLE environment variable is not set")
+	}
+
+	if db.Client == nil {
+		return nil, fmt.Errorf("dynamodb client is not initialized")
+	}
+
+	return &Heartbeat{
+		Client:    db.Client,
+		TableName: tableName,
+		Every:     BeatInterval(),
+	}, nil
+}
+
+// BeatInterval is INGESTION_HEARTBEAT_INTERVAL, DefaultBeatInterval when unset or invalid
+func BeatInterval() time.Duration {
+	if parsed, err := time.ParseDuration(os.Getenv("INGESTION_HEARTBEAT_INTERVAL")); err == nil && parsed > 0 {
+		return parsed
+	}
+	return DefaultBeatInterval
+}
+
+// Beat records now as the time a message was last processed, skipped when this container wrote
+// it less than Every ago. The timestamp only moves forward, a container with a late clock can't
+// move it back
+func (hb *Heartbeat) Beat(ctx context.Context) error {
+	hb.mu.Lock()
+	if time.Since(hb.beatAt) < hb.Every {
+		hb.mu.Unlock()
+		return nil
+	}
+	// set before the write, a failing table is retried after Every and not on every record
+	hb.beatAt = time.Now()
+	hb.mu.Unlock()
+
+	now := fmt.Sprint(time.Now().Unix())
+	callCtx, cancel := timeout.Call(ctx)
+	defer cancel()
+	_, err := hb.Client.UpdateItem(callCtx, &dynamodb.UpdateItemInput{
+		TableName:           aws.String(hb.TableName),
+		Key:                 hb.key(),
+		UpdateExpression:    aws.String("SET last_processed_at = :now"),
+		ConditionExpression: aws.String("attribute_not_exists(last_processed_at) OR last_processed_at < :now"),
+		ExpressionAttributeValues: map[string]types.AttributeValue{
+			":now": &types.AttributeValueMemberN{Value: now},
+		},
+	})
+	if errors.Is(db.Condition(err), db.ErrConditionFailed) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to write ingestion heartbeat: %w", err)
+	}
+	return nil
+}
+
+// Read returns nil when no message was ever processed
+func (hb *Heartbeat) Read(ctx context.Context) (*State, error) {
+	callCtx, cancel := timeout.Call(ctx)
+	defer cancel()
+	result, err := hb.Client.GetItem(callCtx, &dynamodb.GetItemInput{
+		TableName:      aws.String(hb.TableName),
+		Key:            hb.key(),
+		ConsistentRead: aws.Bool(true),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to get control item %s: %w", heartbeatKey, err)
+	}
+	if result.Item == nil {
+		return nil, nil
+	}
+
+	var state State
+	if err = attributevalue.UnmarshalMap(result.Item, &state); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal control item %s: %w", heartbeatKey, err)
+	}
+	return &state, nil
+}
+
+// MarkAlerted records the outage starting after lastProcessedAt as alerted. False when it already
+// was, or a message came in since and there is no outage anymore
+func (hb *Heartbeat) MarkAlerted(ctx context.Context, lastProcessedAt int64) (bool, error) {
+	callCtx, cancel := timeout.Call(ctx)
+	defer cancel()
+	_, err := hb.Client.UpdateItem(callCtx, &dynamodb.UpdateItemInput{
+		TableName:           aws.String(hb.TableName),
+		Key:                 hb.key(),
+		UpdateExpression:    aws.String("SET alerted_for = :last"),
+		ConditionExpression: aws.String("last_processed_at = :last AND (attribute_not_exists(alerted_for) OR alerted_for <> :last)"),
+		ExpressionAttributeValues: map[string]types.AttributeValue{
+			":last": &types.AttributeValueMemberN{Value: fmt.Sprint(lastProcessedAt)},
+		},
+	})
+	if errors.Is(db.Condition(err), db.ErrConditionFailed) {
+		return false, nil
+	}
+	if err != nil {
+		return false, fmt.Errorf("failed to mark ingestion outage as alerted: %w", err)
+	}
+	return true, nil
+}
+
+// ClearAlert forgets the alerted outage once messages flow again. False when another checker
+// cleared it first
+func (hb *Heartbeat) ClearAlert(ctx context.Context, alertedFor int64) (bool, error) {
+	callCtx, cancel := timeout.Call(ctx)
+	defer cancel()
+	_, err := hb.Client.UpdateItem(callCtx, &dynamodb.UpdateItemInput{
+		TableName:           aws.String(hb.TableName),
+		Key:                 hb.key(),
+		UpdateExpression:    aws.String("REMOVE alerted_for"),
+		ConditionExpression: aws.String("alerted_for = :alerted"),
+		ExpressionAttributeValues: map[string]types.AttributeValue{
+			":alerted": &types.AttributeValueMemberN{Value: fmt.Sprint(alertedFor)},
+		},
+	})
+	if errors.Is(db.Condition(err), db.ErrConditionFailed) {
+		return false, nil
+	}
+	if err != nil {
+		return false, fmt.Errorf("failed to clear ingestion outage alert: %w", err)
+	}
+	return true, nil
+}
+
+func (hb *Heartbeat) key() map[string]types.AttributeValue {
+	return map[string]types.AttributeValue{
+		"control_key": &types.AttributeValueMemberS{Value: heartbeatKey},
+	}
+}
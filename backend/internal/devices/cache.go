@@ -0,0 +1,51 @@
+package devices
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/Fleexa-Graduation-Project/Backend/models"
+)
+
+// registry entries change rarely (onboarding, deactivation), a few minutes of staleness is fine
+const DefaultCacheTTL = 5 * time.Minute
+
+type cacheEntry struct {
+	device    *models.Device
+	expiresAt time.Time
+}
+
+// Cache is a read-through cache of registry lookups kept per lambda container, so ingestion
+// doesn't read the registry for every message. Unregistered devices are cached too
+type Cache struct {
+	Registry Registry
+	TTL      time.Duration
+
+	mu      sync.Mutex
+	entries map[string]cacheEntry
+}
+
+func NewCache(registry Registry, ttl time.Duration) *Cache {
+	return &Cache{Registry: registry, TTL: ttl, entries: map[string]cacheEntry{}}
+}
+
+// GetDevice has the Registry semantics, nil means not registered. Errors are not cached
+func (cache *Cache) GetDevice(ctx context.Context, deviceID string) (*models.Device, error) {
+	cache.mu.Lock()
+	entry, ok := cache.entries[deviceID]
+	cache.mu.Unlock()
+	if ok && time.Now().Before(entry.expiresAt) {
+		return entry.device, nil
+	}
+
+	device, err := cache.Registry.GetDevice(ctx, deviceID)
+	if err != nil {
+		return nil, err
+	}
+
+	cache.mu.Lock()
+	cache.entries[deviceID] = cacheEntry{device: device, expiresAt: time.Now().Add(cache.TTL)}
+	cache.mu.Unlock()
+	return device, nil
+}
@@ -0,0 +1,113 @@
+package devices
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/attributevalue"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+
+	"github.com/Fleexa-Graduation-Project/Backend/models"
+	"github.com/Fleexa-Graduation-Project/Backend/pkg/timeout"
+)
+
+const (
+	StatusDeactivated = "deactivated"
+
+	batchWriteLimit   = 25 // BatchWriteItem hard limit
+	batchWriteRetries = 3
+)
+
+// DeactivateFleet flags every device of the fleet as deactivated and returns how many changed,
+// devices that already are deactivated are skipped
+func (store *DeviceStore) DeactivateFleet(ctx context.Context, fleetID string) (int, error) {
+	var pending []types.WriteRequest
+
+	cursor := ""
+	for {
+		page, err := store.ListDevices(ctx, fleetID, MaxPageLimit, cursor)
+		if err != nil {
+			return 0, err
+		}
+
+		for _, device := range page.Devices {
+			if device.Status == StatusDeactivated {
+				continue
+			}
+			device.Status = StatusDeactivated
+
+			item, err := attributevalue.MarshalMap(device)
+			if err != nil {
+				return 0, fmt.Errorf("failed to marshal device %s: %w", device.DeviceID, err)
+			}
+			pending = append(pending, types.WriteRequest{PutRequest: &types.PutRequest{Item: item}})
+		}
+
+		if page.NextCursor == "" {
+			break
+		}
+		cursor = page.NextCursor
+	}
+
+	updated := 0
+	for start := 0; start < len(pending); start += batchWriteLimit {
+		end := min(start+batchWriteLimit, len(pending))
+		if err := store.writeBatch(ctx, pending[start:end]); err != nil {
+			return updated, fmt.Errorf("failed to deactivate devices of fleet %s: %w", fleetID, err)
+		}
+		updated += end - start
+	}
+
+	return updated, nil
+}
+
+// one BatchWriteItem chunk, UnprocessedItems are resent with backoff
+func (store *DeviceStore) writeBatch(ctx context.Context, requests []types.WriteRequest) error {
+	for attempt := 0; attempt <= batchWriteRetries; attempt++ {
+		if attempt > 0 {
+			select {
+			case <-ctx.Done():
+				return ctx.Err()
+			case <-time.After(time.Duration(1<<uint(attempt-1)) * 100 * time.Millisecond):
+			}
+		}
+
+		callCtx, cancel := timeout.Call(ctx)
+		output, err := store.Client.BatchWriteItem(callCtx, &dynamodb.BatchWriteItemInput{
+			RequestItems: map[string][]types.WriteRequest{store.TableName: requests},
+		})
+		cancel()
+		if err != nil {
+			return fmt.Errorf("batch write failed: %w", err)
+		}
+
+		requests = output.UnprocessedItems[store.TableName]
+		if len(requests) == 0 {
+			return nil
+		}
+	}
+
+	return fmt.Errorf("batch write: %d items still unprocessed after %d retries", len(requests), batchWriteRetries)
+}
+
+func (store *MemDeviceStore) DeactivateFleet(ctx context.Context, fleetID string) (int, error) {
+	store.mu.Lock()
+	defer store.mu.Unlock()
+
+	updated := 0
+	for id, device := range store.devices {
+		if device.FleetID == fleetID && device.Status != StatusDeactivated {
+			device.Status = StatusDeactivated
+			store.devices[id] = device
+			updated++
+		}
+	}
+	return updated, nil
+}
+
+// IsDeactivated reports whether the registry marks the device as deactivated
+func IsDeactivated(device *models.Device) bool {
+	return device != nil && device.Status == StatusDeactivated
+}
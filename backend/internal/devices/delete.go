@@ -0,0 +1,78 @@
+package devices
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+
+	"github.com/Fleexa-Graduation-Project/Backend/pkg/timeout"
+)
+
+// DeleteDevice removes the registry record, ErrDeviceNotFound for an unregistered id
+func (store *DeviceStore) DeleteDevice(ctx context.Context, deviceID string) error {
+	callCtx, cancel := timeout.Call(ctx)
+	defer cancel()
+	_, err := store.Client.DeleteItem(callCtx, &dynamodb.DeleteItemInput{
+		TableName: aws.String(store.TableName),
+		Key: map[string]types.AttributeValue{
+			"device_id": &types.AttributeValueMemberS{Value: deviceID},
+		},
+		ConditionExpression: aws.String("attribute_exists(device_id)"),
+	})
+	if err != nil {
+		var conditionErr *types.ConditionalCheckFailedException
+		if errors.As(err, &conditionErr) {
+			return fmt.Errorf("%w: %s", ErrDeviceNotFound, deviceID)
+		}
+		return fmt.Errorf("failed to delete device %s: %w", deviceID, err)
+	}
+
+	return nil
+}
+
+func (store *MemDeviceStore) DeleteDevice(ctx context.Context, deviceID string) error {
+	store.mu.Lock()
+	defer store.mu.Unlock()
+
+	if _, ok := store.devices[deviceID]; !ok {
+		return fmt.Errorf("%w: %s", ErrDeviceNotFound, deviceID)
+	}
+	delete(store.devices, deviceID)
+	return nil
+}
+
+// DeleteState removes the state item of a device, a device without one is not an error
+func (s *StateStore) DeleteState(ctx context.Context, deviceID string) error {
+	callCtx, cancel := timeout.Call(ctx)
+	defer cancel()
+	_, err := s.Client.DeleteItem(callCtx, &dynamodb.DeleteItemInput{
+		TableName: aws.String(s.TableName),
+		Key: map[string]types.AttributeValue{
+			"device_id": &types.AttributeValueMemberS{Value: deviceID},
+		},
+	})
+	if err != nil {
+		return fmt.Errorf("failed to delete state of device %s: %w", deviceID, err)
+	}
+	return nil
+}
+
+// Delete removes the latest state item of a device, a device without one is not an error
+func (store *LatestStore) Delete(ctx context.Context, deviceID string) error {
+	callCtx, cancel := timeout.Call(ctx)
+	defer cancel()
+	_, err := store.Client.DeleteItem(callCtx, &dynamodb.DeleteItemInput{
+		TableName: aws.String(store.TableName),
+		Key: map[string]types.AttributeValue{
+			"device_id": &types.AttributeValueMemberS{Value: deviceID},
+		},
+	})
+	if err != nil {
+		return fmt.Errorf("failed to delete latest state of device %s: %w", deviceID, err)
+	}
+	return nil
+}
@@ -0,0 +1,137 @@
+package devices
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/attributevalue"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+
+	"github.com/Fleexa-Graduation-Project/Backend/models"
+	"github.com/Fleexa-Graduation-Project/Backend/pkg/timeout"
+)
+
+const (
+	ImportImported = "imported"
+	ImportSkipped  = "skipped" // the id is already registered, the stored device is kept
+	ImportFailed   = "failed"
+
+	batchGetLimit = 100 // BatchGetItem hard limit
+)
+
+// outcome of one device of an import, Err is set when Status is ImportFailed
+type ImportResult struct {
+	Status string
+	Err    error
+}
+
+// ImportDevices registers many devices at once, one result per input device in the same order.
+// BatchWriteItem has no conditions, so the ids are looked up first and the registered ones are
+// skipped. The ids must be unique. The error is only set when the lookup itself fails
+func (store *DeviceStore) ImportDevices(ctx context.Context, devices []models.Device) ([]ImportResult, error) {
+	ids := make([]string, 0, len(devices))
+	for _, device := range devices {
+		ids = append(ids, device.DeviceID)
+	}
+	existing, err := store.registeredIDs(ctx, ids)
+	if err != nil {
+		return nil, err
+	}
+
+	results := make([]ImportResult, len(devices))
+	var pending []int // indexes of the devices to write
+	var requests []types.WriteRequest
+	now := time.Now().Unix()
+	for i, device := range devices {
+		if existing[device.DeviceID] {
+			results[i] = ImportResult{Status: ImportSkipped}
+			continue
+		}
+		if device.CreatedAt == 0 {
+			device.CreatedAt = now
+		}
+
+		item, err := attributevalue.MarshalMap(device)
+		if err != nil {
+			results[i] = ImportResult{Status: ImportFailed, Err: fmt.Errorf("failed to marshal device: %w", err)}
+			continue
+		}
+		pending = append(pending, i)
+		requests = append(requests, types.WriteRequest{PutRequest: &types.PutRequest{Item: item}})
+	}
+
+	// a failed chunk fails only its own devices, some of them may be written anyway and are
+	// skipped when the import is run again
+	for start := 0; start < len(requests); start += batchWriteLimit {
+		end := min(start+batchWriteLimit, len(requests))
+		err := store.writeBatch(ctx, requests[start:end])
+		for _, i := range pending[start:end] {
+			if err != nil {
+				results[i] = ImportResult{Status: ImportFailed, Err: err}
+			} else {
+				results[i] = ImportResult{Status: ImportImported}
+			}
+		}
+	}
+
+	return results, nil
+}
+
+// the ids that are already in the registry, looked up with BatchGetItem
+func (store *DeviceStore) registeredIDs(ctx context.Context, ids []string) (map[string]bool, error) {
+	registered := map[string]bool{}
+	for start := 0; start < len(ids); start += batchGetLimit {
+		keys := make([]map[string]types.AttributeValue, 0, batchGetLimit)
+		for _, id := range ids[start:min(start+batchGetLimit, len(ids))] {
+			keys = append(keys, map[string]types.AttributeValue{
+				"device_id": &types.AttributeValueMemberS{Value: id},
+			})
+		}
+
+		request := map[string]types.KeysAndAttributes{
+			store.TableName: {Keys: keys, ProjectionExpression: aws.String("device_id")},
+		}
+		for attempt := 0; len(request) > 0; attempt++ {
+			if attempt > batchWriteRetries {
+				return nil, fmt.Errorf("failed to look up devices: keys still unprocessed after %d retries", batchWriteRetries)
+			}
+			if attempt > 0 {
+				select {
+				case <-ctx.Done():
+					return nil, ctx.Err()
+				case <-time.After(time.Duration(1<<uint(attempt-1)) * 100 * time.Millisecond):
+				}
+			}
+
+			callCtx, cancel := timeout.Call(ctx)
+			output, err := store.Client.BatchGetItem(callCtx, &dynamodb.BatchGetItemInput{RequestItems: request})
+			cancel()
+			if err != nil {
+				return nil, fmt.Errorf("failed to look up devices: %w", err)
+			}
+
+			for _, item := range output.Responses[store.TableName] {
+				if id, ok := item["device_id"].(*types.AttributeValueMemberS); ok {
+					registered[id.Value] = true
+				}
+			}
+			request = output.UnprocessedKeys
+		}
+	}
+	return registered, nil
+}
+
+func (store *MemDeviceStore) ImportDevices(ctx context.Context, devices []models.Device) ([]ImportResult, error) {
+	results := make([]ImportResult, len(devices))
+	for i, device := range devices {
+		if err := store.RegisterDevice(ctx, device); err != nil {
+			results[i] = ImportResult{Status: ImportSkipped}
+			continue
+		}
+		results[i] = ImportResult{Status: ImportImported}
+	}
+	return results, nil
+}
@@ -0,0 +1,29 @@
+package devices
+
+import (
+	"context"
+
+	"github.com/Fleexa-Graduation-Project/Backend/models"
+)
+
+// Registry is what handlers need from the device registry, DeviceStore (dynamodb) and
+// MemDeviceStore (tests, local runs) both implement it
+type Registry interface {
+	RegisterDevice(ctx context.Context, device models.Device) error
+	GetDevice(ctx context.Context, deviceID string) (*models.Device, error)
+	ListDevices(ctx context.Context, fleetID string, limit int, cursor string) (DeviceList, error)
+	UpdateFirmwareVersion(ctx context.Context, deviceID string, version string) error
+	DeactivateFleet(ctx context.Context, fleetID string) (int, error)
+	UpdateDevice(ctx context.Context, deviceID string, patch DevicePatch) error
+	ImportDevices(ctx context.Context, devices []models.Device) ([]ImportResult, error)
+	ListDevicesByTag(ctx context.Context, fleetID, tag string, limit int, cursor string) (DeviceList, error)
+	AddTag(ctx context.Context, deviceID, tag string) (*models.Device, error)
+	RemoveTag(ctx context.Context, deviceID, tag string) (*models.Device, error)
+	// DeleteDevice returns ErrDeviceNotFound for an unregistered id
+	DeleteDevice(ctx context.Context, deviceID string) error
+}
+
+var (
+	_ Registry = (*DeviceStore)(nil)
+	_ Registry = (*MemDeviceStore)(nil)
+)
@@ -0,0 +1,102 @@
+package devices
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+
+	"github.com/Fleexa-Graduation-Project/Backend/pkg/db"
+	"github.com/Fleexa-Graduation-Project/Backend/pkg/timeout"
+)
+
+// a backward jump larger than this is a rebooted device whose counter restarted, not a retransmit
+const DefaultSeqResetThreshold = 1000
+
+// SequenceResult is the outcome of AdvanceSequence, LastSeq is the value stored before the call
+// (0 for a device's first reading)
+type SequenceResult struct {
+	Accepted bool
+	Reset    bool
+	LastSeq  int64
+}
+
+// SEQ_RESET_THRESHOLD overrides the default
+func SeqResetThreshold() int64 {
+	if raw := os.Getenv("SEQ_RESET_THRESHOLD"); raw != "" {
+		if value, err := strconv.ParseInt(raw, 10, 64); err == nil && value > 0 {
+			return value
+		}
+	}
+	return DefaultSeqResetThreshold
+}
+
+// AdvanceSequence moves the highest seen sequence number of a device to seq in one conditional
+// update. seq is accepted when it is not behind the stored one, or when it is so far behind that
+// the counter must have been reset. An equal seq is accepted so a reading whose save failed can
+// be retried, exact duplicates are caught by the telemetry dedup key
+func (s *StateStore) AdvanceSequence(ctx context.Context, deviceID string, seq int64, resetThreshold int64) (SequenceResult, error) {
+	input := &dynamodb.UpdateItemInput{
+		TableName: aws.String(s.TableName),
+		Key: map[string]types.AttributeValue{
+			"device_id": &types.AttributeValueMemberS{Value: deviceID},
+		},
+		UpdateExpression:    aws.String("SET last_seq = :seq"),
+		ConditionExpression: aws.String("attribute_not_exists(last_seq) OR last_seq <= :seq OR last_seq > :reset"),
+		ExpressionAttributeValues: map[string]types.AttributeValue{
+			":seq":   &types.AttributeValueMemberN{Value: strconv.FormatInt(seq, 10)},
+			":reset": &types.AttributeValueMemberN{Value: strconv.FormatInt(seq+resetThreshold, 10)},
+		},
+		ReturnValues:                        types.ReturnValueUpdatedOld,
+		ReturnValuesOnConditionCheckFailure: types.ReturnValuesOnConditionCheckFailureAllOld,
+	}
+
+	callCtx, cancel := timeout.Call(ctx)
+	defer cancel()
+	output, err := s.Client.UpdateItem(callCtx, input)
+	if err != nil {
+		var conditionErr *db.ConditionError
+		if errors.As(db.Condition(err), &conditionErr) {
+			return SequenceResult{LastSeq: numberAttr(conditionErr.Item, "last_seq")}, nil
+		}
+		return SequenceResult{}, fmt.Errorf("failed to advance sequence of device %s: %w", deviceID, err)
+	}
+
+	last := numberAttr(output.Attributes, "last_seq")
+	return SequenceResult{Accepted: true, Reset: last > seq, LastSeq: last}, nil
+}
+
+// LastSequence is the highest sequence number stored for the device, 0 when it has none. The
+// read is consistent, so it sees the AdvanceSequence of a batch that finished just before
+func (s *StateStore) LastSequence(ctx context.Context, deviceID string) (int64, error) {
+	input := &dynamodb.GetItemInput{
+		TableName: aws.String(s.TableName),
+		Key: map[string]types.AttributeValue{
+			"device_id": &types.AttributeValueMemberS{Value: deviceID},
+		},
+		ProjectionExpression: aws.String("last_seq"),
+		ConsistentRead:       aws.Bool(true),
+	}
+
+	callCtx, cancel := timeout.Call(ctx)
+	defer cancel()
+	output, err := s.Client.GetItem(callCtx, input)
+	if err != nil {
+		return 0, fmt.Errorf("failed to read sequence of device %s: %w", deviceID, err)
+	}
+	return numberAttr(output.Item, "last_seq"), nil
+}
+
+func numberAttr(item map[string]types.AttributeValue, name string) int64 {
+	attr, ok := item[name].(*types.AttributeValueMemberN)
+	if !ok {
+		return 0
+	}
+	value, _ := strconv.ParseInt(attr.Value, 10, 64)
+	return value
+}
@@ -0,0 +1,211 @@
+package devices
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/attributevalue"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+
+	"github.com/Fleexa-Graduation-Project/Backend/models"
+	appconfig "github.com/Fleexa-Graduation-Project/Backend/pkg/config"
+	"github.com/Fleexa-Graduation-Project/Backend/pkg/db"
+	"github.com/Fleexa-Graduation-Project/Backend/pkg/timeout"
+)
+
+var ErrDeviceExists = errors.New("device already registered")
+
+const (
+	fleetIndex       = "FleetIndex" // GSI: fleet_id (HASH), created_at (RANGE)
+	DefaultPageLimit = 50
+	MaxPageLimit     = 100
+)
+
+// one page of registered devices, NextCursor is empty on the last page
+type DeviceList struct {
+	Devices    []models.Device `json:"data"`
+	NextCursor string          `json:"next_cursor,omitempty"`
+}
+
+type DeviceStore struct {
+	Client    *dynamodb.Client
+	TableName string
+}
+
+func NewDeviceStore() (*DeviceStore, error) {
+	tableName := appconfig.TableName("DYNAMODB_DEVICES_TABLE")
+	if tableName == "" {
+		return nil, fmt.Errorf("DYNAMODB_DEVICES_TABLE environment variable is not set")
+	}
+
+	if db.Client == nil {
+		return nil, fmt.Errorf("dynamodb client is not initialized")
+	}
+
+	return &DeviceStore{
+		Client:    db.Client,
+		TableName: tableName,
+	}, nil
+}
+
+// writes a new device, returns ErrDeviceExists if the id is already taken
+func (store *DeviceStore) RegisterDevice(ctx context.Context, device models.Device) error {
+	if device.CreatedAt == 0 {
+		device.CreatedAt = time.Now().Unix()
+	}
+
+	item, err := attributevalue.MarshalMap(device)
+	if err != nil {
+		return fmt.Errorf("failed to marshal device: %w", err)
+	}
+
+	input := &dynamodb.PutItemInput{
+		TableName:           aws.String(store.TableName),
+		Item:                item,
+		ConditionExpression: aws.String("attribute_not_exists(device_id)"),
+	}
+
+	callCtx, cancel := timeout.Call(ctx)
+	defer cancel()
+	_, err = store.Client.PutItem(callCtx, input)
+	if err != nil {
+		var conditionErr *types.ConditionalCheckFailedException
+		if errors.As(err, &conditionErr) {
+			return fmt.Errorf("%w: %s", ErrDeviceExists, device.DeviceID)
+		}
+		return fmt.Errorf("failed to register device in dynamodb: %w", err)
+	}
+
+	return nil
+}
+
+// returns nil when the device was never registered
+func (store *DeviceStore) GetDevice(ctx context.Context, deviceID string) (*models.Device, error) {
+	input := &dynamodb.GetItemInput{
+		TableName: aws.String(store.TableName),
+		Key: map[string]types.AttributeValue{
+			"device_id": &types.AttributeValueMemberS{Value: deviceID},
+		},
+	}
+
+	callCtx, cancel := timeout.Call(ctx)
+	defer cancel()
+	result, err := store.Client.GetItem(callCtx, input)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get device %s: %w", deviceID, err)
+	}
+
+	if result.Item == nil {
+		return nil, nil
+	}
+
+	var device models.Device
+	if err = attributevalue.UnmarshalMap(result.Item, &device); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal device %s: %w", deviceID, err)
+	}
+
+	return &device, nil
+}
+
+// records the firmware a device reports, unregistered devices and unchanged versions are a no-op
+func (store *DeviceStore) UpdateFirmwareVersion(ctx context.Context, deviceID string, version string) error {
+	input := &dynamodb.UpdateItemInput{
+		TableName: aws.String(store.TableName),
+		Key: map[string]types.AttributeValue{
+			"device_id": &types.AttributeValueMemberS{Value: deviceID},
+		},
+		UpdateExpression:    aws.String("SET firmware_version = :version"),
+		ConditionExpression: aws.String("attribute_exists(device_id) AND (attribute_not_exists(firmware_version) OR firmware_version <> :version)"),
+		ExpressionAttributeValues: map[string]types.AttributeValue{
+			":version": &types.AttributeValueMemberS{Value: version},
+		},
+	}
+
+	callCtx, cancel := timeout.Call(ctx)
+	defer cancel()
+	_, err := store.Client.UpdateItem(callCtx, input)
+	if err != nil {
+		var conditionErr *types.ConditionalCheckFailedException
+		if errors.As(err, &conditionErr) {
+			return nil
+		}
+		return fmt.Errorf("failed to update firmware version of device %s: %w", deviceID, err)
+	}
+
+	return nil
+}
+
+// pages through the devices of a fleet using the FleetIndex GSI
+func (store *DeviceStore) ListDevices(ctx context.Context, fleetID string, limit int, cursor string) (DeviceList, error) {
+	return store.listDevices(ctx, fleetID, "", limit, cursor)
+}
+
+// ListDevicesByTag pages through the devices of a fleet carrying the tag. The tag is a filter
+// applied after limit, so a page can come back short (even empty) with a next cursor
+func (store *DeviceStore) ListDevicesByTag(ctx context.Context, fleetID, tag string, limit int, cursor string) (DeviceList, error) {
+	return store.listDevices(ctx, fleetID, tag, limit, cursor)
+}
+
+func (store *DeviceStore) listDevices(ctx context.Context, fleetID, tag string, limit int, cursor string) (DeviceList, error) {
+	if limit <= 0 {
+		limit = DefaultPageLimit
+	}
+	if limit > MaxPageLimit {
+		limit = MaxPageLimit
+	}
+
+	startKey, err := db.DecodeCursor(cursor)
+	if err != nil {
+		return DeviceList{}, err
+	}
+
+	input := &dynamodb.QueryInput{
+		TableName:              aws.String(store.TableName),
+		IndexName:              aws.String(fleetIndex),
+		KeyConditionExpression: aws.String("fleet_id = :fleet"),
+		ExpressionAttributeValues: map[string]types.AttributeValue{
+			":fleet": &types.AttributeValueMemberS{Value: fleetID},
+		},
+		Limit:             aws.Int32(int32(limit)),
+		ExclusiveStartKey: startKey,
+	}
+	if tag != "" {
+		input.FilterExpression = aws.String("contains(tags, :tag)")
+		input.ExpressionAttributeValues[":tag"] = &types.AttributeValueMemberS{Value: tag}
+	}
+
+	callCtx, cancel := timeout.Call(ctx)
+	defer cancel()
+	result, err := store.Client.Query(callCtx, input)
+	if err != nil {
+		return DeviceList{}, fmt.Errorf("failed to query devices for fleet %s: %w", fleetID, err)
+	}
+
+	list := DeviceList{Devices: []models.Device{}}
+	if err = attributevalue.UnmarshalListOfMaps(result.Items, &list.Devices); err != nil {
+		return DeviceList{}, fmt.Errorf("failed to unmarshal devices for fleet %s: %w", fleetID, err)
+	}
+
+	if list.NextCursor, err = db.EncodeCursor(result.LastEvaluatedKey); err != nil {
+		return DeviceList{}, err
+	}
+
+	return list, nil
+}
+
+// Ping is a cheap reachability check used by the health endpoint
+func (store *DeviceStore) Ping(ctx context.Context) error {
+	callCtx, cancel := timeout.Call(ctx)
+	defer cancel()
+	_, err := store.Client.DescribeTable(callCtx, &dynamodb.DescribeTableInput{
+		TableName: aws.String(store.TableName),
+	})
+	if err != nil {
+		return fmt.Errorf("failed to describe table %s: %w", store.TableName, err)
+	}
+	return nil
+}
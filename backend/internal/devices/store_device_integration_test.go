@@ -0,0 +1,141 @@
+//go:build integration
+
+package devices
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+
+	"github.com/Fleexa-Graduation-Project/Backend/internal/dbtest"
+	"github.com/Fleexa-Graduation-Project/Backend/models"
+)
+
+// the registry table of scripts/dynamodb-local.sh: device_id (HASH) and the FleetIndex GSI on
+// fleet_id (HASH) + created_at (RANGE)
+func integrationStore(t *testing.T) *DeviceStore {
+	t.Helper()
+	client := dbtest.Client(t)
+	dbtest.CreateTable(t, client, "DYNAMODB_DEVICES_TABLE", dynamodb.CreateTableInput{
+		AttributeDefinitions: []types.AttributeDefinition{
+			dbtest.Attribute("device_id", types.ScalarAttributeTypeS),
+			dbtest.Attribute("fleet_id", types.ScalarAttributeTypeS),
+			dbtest.Attribute("created_at", types.ScalarAttributeTypeN),
+		},
+		KeySchema: dbtest.Key("device_id", ""),
+		GlobalSecondaryIndexes: []types.GlobalSecondaryIndex{{
+			IndexName:  aws.String(fleetIndex),
+			KeySchema:  dbtest.Key("fleet_id", "created_at"),
+			Projection: &types.Projection{ProjectionType: types.ProjectionTypeAll},
+		}},
+	})
+
+	store, err := NewDeviceStore()
+	if err != nil {
+		t.Fatalf("NewDeviceStore: %v", err)
+	}
+	return store
+}
+
+func TestIntegrationRegisterAndGetDevice(t *testing.T) {
+	store := integrationStore(t)
+	ctx := context.Background()
+
+	device := models.Device{DeviceID: "truck-1", Name: "Truck 1", Model: "temp-sensor", FleetID: "fleet-a", Tags: []string{"refrigerated"}}
+	if err := store.RegisterDevice(ctx, device); err != nil {
+		t.Fatalf("RegisterDevice: %v", err)
+	}
+	if err := store.RegisterDevice(ctx, device); !errors.Is(err, ErrDeviceExists) {
+		t.Fatalf("RegisterDevice of a taken id = %v, want ErrDeviceExists", err)
+	}
+
+	got, err := store.GetDevice(ctx, "truck-1")
+	if err != nil {
+		t.Fatalf("GetDevice: %v", err)
+	}
+	if got == nil || got.FleetID != "fleet-a" || got.Model != "temp-sensor" || got.CreatedAt == 0 || len(got.Tags) != 1 {
+		t.Fatalf("GetDevice() = %+v, want the registered device with created_at set", got)
+	}
+
+	if err := store.UpdateFirmwareVersion(ctx, "truck-1", "1.4.0"); err != nil {
+		t.Fatalf("UpdateFirmwareVersion: %v", err)
+	}
+	// unchanged versions and unregistered devices are no-ops, not errors
+	if err := store.UpdateFirmwareVersion(ctx, "truck-1", "1.4.0"); err != nil {
+		t.Fatalf("UpdateFirmwareVersion unchanged: %v", err)
+	}
+	if err := store.UpdateFirmwareVersion(ctx, "truck-x", "1.4.0"); err != nil {
+		t.Fatalf("UpdateFirmwareVersion unregistered: %v", err)
+	}
+	if got, err = store.GetDevice(ctx, "truck-1"); err != nil || got.FirmwareVersion != "1.4.0" {
+		t.Errorf("GetDevice() after firmware update = %+v, %v", got, err)
+	}
+	// the no-op update must not have created the unregistered device either
+	if missing, err := store.GetDevice(ctx, "truck-x"); err != nil || missing != nil {
+		t.Errorf("GetDevice(unregistered) = %+v, %v, want nil, nil", missing, err)
+	}
+}
+
+func TestIntegrationListDevices(t *testing.T) {
+	store := integrationStore(t)
+	ctx := context.Background()
+
+	for i := range 5 {
+		device := models.Device{DeviceID: fmt.Sprintf("truck-a%d", i), FleetID: "fleet-a", CreatedAt: int64(1700000000 + i)}
+		if err := store.RegisterDevice(ctx, device); err != nil {
+			t.Fatalf("RegisterDevice: %v", err)
+		}
+	}
+	if err := store.RegisterDevice(ctx, models.Device{DeviceID: "truck-b0", FleetID: "fleet-b"}); err != nil {
+		t.Fatalf("RegisterDevice: %v", err)
+	}
+
+	tests := []struct {
+		name      string
+		fleetID   string
+		limit     int
+		wantIDs   []string
+		wantPages int
+	}{
+		{name: "pages through the fleet", fleetID: "fleet-a", limit: 2, wantIDs: []string{"truck-a0", "truck-a1", "truck-a2", "truck-a3", "truck-a4"}, wantPages: 3},
+		{name: "one page", fleetID: "fleet-b", limit: 10, wantIDs: []string{"truck-b0"}, wantPages: 1},
+		{name: "fleet without devices", fleetID: "fleet-c", limit: 10, wantPages: 1},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var ids []string
+			cursor := ""
+			pages := 0
+			for {
+				pages++
+				if pages > 10 {
+					t.Fatal("ListDevices never returned the last page")
+				}
+				list, err := store.ListDevices(ctx, test.fleetID, test.limit, cursor)
+				if err != nil {
+					t.Fatalf("ListDevices: %v", err)
+				}
+				for _, device := range list.Devices {
+					ids = append(ids, device.DeviceID)
+				}
+				if list.NextCursor == "" {
+					break
+				}
+				cursor = list.NextCursor
+			}
+
+			if pages != test.wantPages {
+				t.Errorf("pages = %d, want %d", pages, test.wantPages)
+			}
+			if fmt.Sprint(ids) != fmt.Sprint(test.wantIDs) {
+				t.Errorf("devices = %v, want %v in created_at order", ids, test.wantIDs)
+			}
+		})
+	}
+}
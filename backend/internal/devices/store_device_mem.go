@@ -0,0 +1,138 @@
+package devices
+
+import (
+	"cmp"
+	"context"
+	"fmt"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/attributevalue"
+
+	"github.com/Fleexa-Graduation-Project/Backend/models"
+	"github.com/Fleexa-Graduation-Project/Backend/pkg/db"
+)
+
+// MemDeviceStore is an in-memory Registry with the same conflict and paging semantics as DeviceStore
+type MemDeviceStore struct {
+	mu      sync.RWMutex
+	devices map[string]models.Device
+}
+
+func NewMemDeviceStore() *MemDeviceStore {
+	return &MemDeviceStore{devices: map[string]models.Device{}}
+}
+
+// same key attributes the FleetIndex returns as LastEvaluatedKey
+type memCursorKey struct {
+	DeviceID  string `dynamodbav:"device_id"`
+	FleetID   string `dynamodbav:"fleet_id"`
+	CreatedAt int64  `dynamodbav:"created_at"`
+}
+
+func (store *MemDeviceStore) RegisterDevice(ctx context.Context, device models.Device) error {
+	store.mu.Lock()
+	defer store.mu.Unlock()
+
+	if _, exists := store.devices[device.DeviceID]; exists {
+		return fmt.Errorf("%w: %s", ErrDeviceExists, device.DeviceID)
+	}
+	if device.CreatedAt == 0 {
+		device.CreatedAt = time.Now().Unix()
+	}
+
+	store.devices[device.DeviceID] = device
+	return nil
+}
+
+func (store *MemDeviceStore) GetDevice(ctx context.Context, deviceID string) (*models.Device, error) {
+	store.mu.RLock()
+	defer store.mu.RUnlock()
+
+	device, ok := store.devices[deviceID]
+	if !ok {
+		return nil, nil
+	}
+	return &device, nil
+}
+
+func (store *MemDeviceStore) UpdateFirmwareVersion(ctx context.Context, deviceID string, version string) error {
+	store.mu.Lock()
+	defer store.mu.Unlock()
+
+	if device, ok := store.devices[deviceID]; ok {
+		device.FirmwareVersion = version
+		store.devices[deviceID] = device
+	}
+	return nil
+}
+
+func (store *MemDeviceStore) ListDevices(ctx context.Context, fleetID string, limit int, cursor string) (DeviceList, error) {
+	return store.listDevices(fleetID, "", limit, cursor)
+}
+
+func (store *MemDeviceStore) ListDevicesByTag(ctx context.Context, fleetID, tag string, limit int, cursor string) (DeviceList, error) {
+	return store.listDevices(fleetID, tag, limit, cursor)
+}
+
+func (store *MemDeviceStore) listDevices(fleetID, tag string, limit int, cursor string) (DeviceList, error) {
+	if limit <= 0 {
+		limit = DefaultPageLimit
+	}
+	if limit > MaxPageLimit {
+		limit = MaxPageLimit
+	}
+
+	startKey, err := db.DecodeCursor(cursor)
+	if err != nil {
+		return DeviceList{}, err
+	}
+	var after memCursorKey
+	if startKey != nil {
+		if err := attributevalue.UnmarshalMap(startKey, &after); err != nil || after.DeviceID == "" {
+			return DeviceList{}, db.ErrInvalidCursor
+		}
+	}
+
+	store.mu.RLock()
+	fleet := make([]models.Device, 0)
+	for _, device := range store.devices {
+		if device.FleetID == fleetID && (tag == "" || slices.Contains(device.Tags, tag)) {
+			fleet = append(fleet, device)
+		}
+	}
+	store.mu.RUnlock()
+
+	// index order: created_at, ties broken by id
+	slices.SortFunc(fleet, func(a, b models.Device) int {
+		return cmp.Or(cmp.Compare(a.CreatedAt, b.CreatedAt), cmp.Compare(a.DeviceID, b.DeviceID))
+	})
+
+	start := 0
+	if startKey != nil {
+		start = len(fleet)
+		for i, device := range fleet {
+			if cmp.Or(cmp.Compare(device.CreatedAt, after.CreatedAt), cmp.Compare(device.DeviceID, after.DeviceID)) > 0 {
+				start = i
+				break
+			}
+		}
+	}
+
+	end := min(start+limit, len(fleet))
+	list := DeviceList{Devices: fleet[start:end]}
+
+	if end < len(fleet) {
+		last := fleet[end-1]
+		key, err := attributevalue.MarshalMap(memCursorKey{DeviceID: last.DeviceID, FleetID: last.FleetID, CreatedAt: last.CreatedAt})
+		if err != nil {
+			return DeviceList{}, fmt.Errorf("failed to marshal cursor key: %w", err)
+		}
+		if list.NextCursor, err = db.EncodeCursor(key); err != nil {
+			return DeviceList{}, err
+		}
+	}
+
+	return list, nil
+}
@@ -0,0 +1,130 @@
+package devices
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+
+	"github.com/Fleexa-Graduation-Project/Backend/models"
+	appconfig "github.com/Fleexa-Graduation-Project/Backend/pkg/config"
+	"github.com/Fleexa-Graduation-Project/Backend/pkg/db"
+	"github.com/Fleexa-Graduation-Project/Backend/pkg/geo"
+	"github.com/Fleexa-Graduation-Project/Backend/pkg/timeout"
+)
+
+const latestFleetIndex = "FleetIndex"
+
+// the reading fields kept for the map, a field the reading doesn't carry keeps its last value
+var latestFields = []string{"speed", "battery", "fuel"}
+
+// LatestState is the newest position, speed, battery and fuel of a device, one item per device.
+// Online isn't stored, it is derived from Timestamp when read
+type LatestState struct {
+	DeviceID  string   `json:"device_id" dynamodbav:"device_id"`
+	FleetID   string   `json:"fleet_id" dynamodbav:"fleet_id"`
+	Type      string   `json:"type" dynamodbav:"type"`
+	Timestamp int64    `json:"timestamp" dynamodbav:"timestamp"`
+	Lat       *float64 `json:"lat,omitempty" dynamodbav:"lat,omitempty"`
+	Lon       *float64 `json:"lon,omitempty" dynamodbav:"lon,omitempty"`
+	Speed     *float64 `json:"speed,omitempty" dynamodbav:"speed,omitempty"` // km/h
+	Battery   *float64 `json:"battery,omitempty" dynamodbav:"battery,omitempty"`
+	Fuel      *float64 `json:"fuel,omitempty" dynamodbav:"fuel,omitempty"`
+	Online    bool     `json:"online" dynamodbav:"-"`
+}
+
+// keyed device_id (HASH), FleetIndex (fleet_id, device_id) lists a fleet in one query
+type LatestStore struct {
+	Client    *dynamodb.Client
+	TableName string
+}
+
+func NewLatestStore() (*LatestStore, error) {
+	tableName := appconfig.TableName("DYNAMODB_LATEST_STATE_TABLE")
+	if tableName == "" {
+		return nil, fmt.Errorf("DYNAMODB_LATEST_STATE_TABLE is not set")
+	}
+
+	if db.Client == nil {
+		return nil, fmt.Errorf("dynamodb client not initialized")
+	}
+
+	return &LatestStore{
+		Client:    db.Client,
+		TableName: tableName,
+	}, nil
+}
+
+// UpdateFromTelemetry advances the latest state of the device to the reading, only if it is newer
+// than the stored one so a late or redelivered reading can't move it back. fleetID is written
+// each time, a device moved to another fleet follows on its next reading. False when stale
+func (store *LatestStore) UpdateFromTelemetry(ctx context.Context, fleetID string, tel models.Telemetry) (bool, error) {
+	update := "SET fleet_id = :fleet, #type = :type, #ts = :ts, updated_at = :now"
+	values := map[string]types.AttributeValue{
+		":fleet": &types.AttributeValueMemberS{Value: fleetID},
+		":type":  &types.AttributeValueMemberS{Value: tel.Type},
+		":ts":    &types.AttributeValueMemberN{Value: fmt.Sprint(tel.Timestamp)},
+		":now":   &types.AttributeValueMemberN{Value: fmt.Sprint(time.Now().Unix())},
+	}
+
+	lat, latOK := tel.Payload["lat"].(float64)
+	lon, lonOK := tel.Payload["lon"].(float64)
+	if latOK && lonOK && geo.ValidCoord(lat, lon) {
+		update += ", lat = :lat, lon = :lon"
+		values[":lat"] = &types.AttributeValueMemberN{Value: fmt.Sprint(lat)}
+		values[":lon"] = &types.AttributeValueMemberN{Value: fmt.Sprint(lon)}
+	}
+	for _, field := range latestFields {
+		if value, ok := tel.Payload[field].(float64); ok {
+			update += fmt.Sprintf(", %s = :%s", field, field)
+			values[":"+field] = &types.AttributeValueMemberN{Value: fmt.Sprint(value)}
+		}
+	}
+
+	callCtx, cancel := timeout.Call(ctx)
+	defer cancel()
+	_, err := store.Client.UpdateItem(callCtx, &dynamodb.UpdateItemInput{
+		TableName:                 aws.String(store.TableName),
+		Key:                       map[string]types.AttributeValue{"device_id": &types.AttributeValueMemberS{Value: tel.DeviceID}},
+		UpdateExpression:          aws.String(update),
+		ConditionExpression:       aws.String("attribute_not_exists(#ts) OR #ts < :ts"),
+		ExpressionAttributeNames:  map[string]string{"#type": "type", "#ts": "timestamp"},
+		ExpressionAttributeValues: values,
+	})
+	var conditionErr *types.ConditionalCheckFailedException
+	if errors.As(err, &conditionErr) {
+		return false, nil
+	}
+	if err != nil {
+		return false, fmt.Errorf("failed to update latest state of device %s: %w", tel.DeviceID, err)
+	}
+	return true, nil
+}
+
+// ListFleet returns the latest state of every device of the fleet that has reported, read from
+// the FleetIndex gsi
+func (store *LatestStore) ListFleet(ctx context.Context, fleetID string) ([]LatestState, error) {
+	input := &dynamodb.QueryInput{
+		TableName:              aws.String(store.TableName),
+		IndexName:              aws.String(latestFleetIndex),
+		KeyConditionExpression: aws.String("fleet_id = :fleet"),
+		ExpressionAttributeValues: map[string]types.AttributeValue{
+			":fleet": &types.AttributeValueMemberS{Value: fleetID},
+		},
+	}
+
+	states := []LatestState{}
+	for state, err := range db.QueryAll[LatestState](ctx, store.Client, input) {
+		if err != nil {
+			return nil, fmt.Errorf("failed to query latest states of fleet %s: %w", fleetID, err)
+		}
+		state.Online = ConnectionStatus(state.Timestamp) == "ONLINE"
+		states = append(states, state)
+	}
+
+	return states, nil
+}
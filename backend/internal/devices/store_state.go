@@ -2,8 +2,8 @@ package devices
 
 import (
 	"context"
+	"errors"
 	"fmt"
-	"os"
 	"time"
 
 	"github.com/aws/aws-sdk-go-v2/aws"
@@ -11,7 +11,9 @@ import (
 	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
 
 	"github.com/Fleexa-Graduation-Project/Backend/models"
+	appconfig "github.com/Fleexa-Graduation-Project/Backend/pkg/config"
 	"github.com/Fleexa-Graduation-Project/Backend/pkg/db"
+	"github.com/Fleexa-Graduation-Project/Backend/pkg/timeout"
 	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/attributevalue"
 )
 
@@ -25,7 +27,7 @@ type StateStore struct {
 }
 
 func NewStateStore() (*StateStore, error) {
-	tableName := os.Getenv("DYNAMODB_DEVICE_STATE_TABLE")
+	tableName := appconfig.TableName("DYNAMODB_DEVICE_STATE_TABLE")
 	if tableName == "" {
 		return nil, fmt.Errorf("DYNAMODB_DEVICE_STATE_TABLE is not set")
 	}
@@ -70,6 +72,7 @@ func (s *StateStore) UpdateFromTelemetry(ctx context.Context,tel models.Telemetr
 				payload = :payload,
 				last_seen_at = :last_seen,
 				updated_at = :updated_at
+			REMOVE offline_alerted_at
 		`),
 		ExpressionAttributeNames: map[string]string{
 			"#type":   "type",
@@ -86,7 +89,9 @@ func (s *StateStore) UpdateFromTelemetry(ctx context.Context,tel models.Telemetr
 		},
 	}
 
-	_, err = s.Client.UpdateItem(ctx, input)
+	callCtx, cancel := timeout.Call(ctx)
+	defer cancel()
+	_, err = s.Client.UpdateItem(callCtx, input)
 	if err != nil {
 		return fmt.Errorf("failed to update device state: %w", err)
 	}
@@ -123,7 +128,7 @@ func (s *StateStore) UpdateHeartbeat(ctx context.Context,deviceID string,) error
             "attribute_not_exists(last_seen_at) OR last_seen_at <= :last_seen",
         ),
         UpdateExpression: aws.String(
-            "SET #status = :status, last_seen_at = :last_seen",
+            "SET #status = :status, last_seen_at = :last_seen REMOVE offline_alerted_at",
         ),
         ExpressionAttributeNames: map[string]string{
             "#status": "status",
@@ -134,10 +139,115 @@ func (s *StateStore) UpdateHeartbeat(ctx context.Context,deviceID string,) error
         },
     }
 
-    _, err := s.Client.UpdateItem(ctx, input)
+    callCtx, cancel := timeout.Call(ctx)
+    defer cancel()
+    _, err := s.Client.UpdateItem(callCtx, input)
+    // a reading stamped slightly ahead already moved last_seen_at past now, nothing to update
+    var conditionErr *types.ConditionalCheckFailedException
+    if errors.As(err, &conditionErr) {
+        return nil
+    }
     return err
 }
 
+// flags a device as alerted offline so the monitor doesn't alert it again on every run,
+// returns false when it was already flagged or reported again since lastSeenAt
+func (s *StateStore) MarkOfflineAlerted(ctx context.Context, deviceID string, lastSeenAt int64) (bool, error) {
+	input := &dynamodb.UpdateItemInput{
+		TableName: aws.String(s.TableName),
+		Key: map[string]types.AttributeValue{
+			"device_id": &types.AttributeValueMemberS{Value: deviceID},
+		},
+		ConditionExpression: aws.String("attribute_not_exists(offline_alerted_at) AND last_seen_at = :last_seen"),
+		UpdateExpression:    aws.String("SET #status = :status, offline_alerted_at = :now"),
+		ExpressionAttributeNames: map[string]string{
+			"#status": "status",
+		},
+		ExpressionAttributeValues: map[string]types.AttributeValue{
+			":status":    &types.AttributeValueMemberS{Value: "OFFLINE"},
+			":last_seen": &types.AttributeValueMemberN{Value: fmt.Sprint(lastSeenAt)},
+			":now":       &types.AttributeValueMemberN{Value: fmt.Sprint(time.Now().Unix())},
+		},
+	}
+
+	callCtx, cancel := timeout.Call(ctx)
+	defer cancel()
+	_, err := s.Client.UpdateItem(callCtx, input)
+	if err != nil {
+		var conditionErr *types.ConditionalCheckFailedException
+		if errors.As(err, &conditionErr) {
+			return false, nil
+		}
+		return false, fmt.Errorf("failed to flag device %s offline: %w", deviceID, err)
+	}
+
+	return true, nil
+}
+
+// MarkLowResourceAlerted flags that the low alert of metric (battery, fuel) fired, so it isn't
+// raised again while the value stays low. Returns false when the flag was already set
+func (s *StateStore) MarkLowResourceAlerted(ctx context.Context, deviceID, metric string) (bool, error) {
+	input := &dynamodb.UpdateItemInput{
+		TableName: aws.String(s.TableName),
+		Key: map[string]types.AttributeValue{
+			"device_id": &types.AttributeValueMemberS{Value: deviceID},
+		},
+		ConditionExpression: aws.String("attribute_not_exists(#flag)"),
+		UpdateExpression:    aws.String("SET #flag = :now"),
+		ExpressionAttributeNames: map[string]string{
+			"#flag": lowResourceFlag(metric),
+		},
+		ExpressionAttributeValues: map[string]types.AttributeValue{
+			":now": &types.AttributeValueMemberN{Value: fmt.Sprint(time.Now().Unix())},
+		},
+	}
+
+	callCtx, cancel := timeout.Call(ctx)
+	defer cancel()
+	_, err := s.Client.UpdateItem(callCtx, input)
+	if err != nil {
+		var conditionErr *types.ConditionalCheckFailedException
+		if errors.As(err, &conditionErr) {
+			return false, nil
+		}
+		return false, fmt.Errorf("failed to flag low %s on device %s: %w", metric, deviceID, err)
+	}
+	return true, nil
+}
+
+// ClearLowResourceAlert re-arms the low alert of metric once the value recovered, returns
+// false when it wasn't flagged
+func (s *StateStore) ClearLowResourceAlert(ctx context.Context, deviceID, metric string) (bool, error) {
+	input := &dynamodb.UpdateItemInput{
+		TableName: aws.String(s.TableName),
+		Key: map[string]types.AttributeValue{
+			"device_id": &types.AttributeValueMemberS{Value: deviceID},
+		},
+		ConditionExpression: aws.String("attribute_exists(#flag)"),
+		UpdateExpression:    aws.String("REMOVE #flag"),
+		ExpressionAttributeNames: map[string]string{
+			"#flag": lowResourceFlag(metric),
+		},
+	}
+
+	callCtx, cancel := timeout.Call(ctx)
+	defer cancel()
+	_, err := s.Client.UpdateItem(callCtx, input)
+	if err != nil {
+		var conditionErr *types.ConditionalCheckFailedException
+		if errors.As(err, &conditionErr) {
+			return false, nil
+		}
+		return false, fmt.Errorf("failed to clear low %s on device %s: %w", metric, deviceID, err)
+	}
+	return true, nil
+}
+
+// low_battery_alerted_at, low_fuel_alerted_at
+func lowResourceFlag(metric string) string {
+	return "low_" + metric + "_alerted_at"
+}
+
 func ConnectionStatus(lastSeenAt int64) string {
 	if time.Since(time.Unix(lastSeenAt, 0)) > OfflineLimit {
 		return "OFFLINE"
@@ -158,7 +268,9 @@ func (store *StateStore) GetAllStates(ctx context.Context) ([]models.DeviceState
 			ExclusiveStartKey: lastEvaluatedKey, // Start where the last page left off
 		}
 
-		result, err := store.Client.Scan(ctx, input)  // works as select * w/o WHERE clause
+		callCtx, cancel := timeout.Call(ctx) // per page, not per scan
+		result, err := store.Client.Scan(callCtx, input)  // works as select * w/o WHERE clause
+		cancel()
 		if err != nil {
 			return nil, fmt.Errorf("failed to scan device states: %w", err)
 		}
@@ -192,7 +304,9 @@ func (s *StateStore) GetStateByID(ctx context.Context, deviceID string) (*models
 		},
 	}
 
-	result, err := s.Client.GetItem(ctx, input)
+	callCtx, cancel := timeout.Call(ctx)
+	defer cancel()
+	result, err := s.Client.GetItem(callCtx, input)
 	if err != nil {
 		return nil, fmt.Errorf("failed to get device: %w", err)
 	}
@@ -210,4 +324,30 @@ func (s *StateStore) GetStateByID(ctx context.Context, deviceID string) (*models
 	}
 
 	return &state, nil
-}
\ No newline at end of file
+}
+// SetConnectivityScore stores the connectivity score of a device, a device whose state was deleted
+// meanwhile is skipped
+func (s *StateStore) SetConnectivityScore(ctx context.Context, deviceID string, score int) error {
+	callCtx, cancel := timeout.Call(ctx)
+	defer cancel()
+	_, err := s.Client.UpdateItem(callCtx, &dynamodb.UpdateItemInput{
+		TableName: aws.String(s.TableName),
+		Key: map[string]types.AttributeValue{
+			"device_id": &types.AttributeValueMemberS{Value: deviceID},
+		},
+		ConditionExpression: aws.String("attribute_exists(device_id)"),
+		UpdateExpression:    aws.String("SET connectivity_score = :score, connectivity_scored_at = :now"),
+		ExpressionAttributeValues: map[string]types.AttributeValue{
+			":score": &types.AttributeValueMemberN{Value: fmt.Sprint(score)},
+			":now":   &types.AttributeValueMemberN{Value: fmt.Sprint(time.Now().Unix())},
+		},
+	})
+	var conditionErr *types.ConditionalCheckFailedException
+	if errors.As(err, &conditionErr) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to store connectivity score of device %s: %w", deviceID, err)
+	}
+	return nil
+}
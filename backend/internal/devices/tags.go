@@ -0,0 +1,112 @@
+package devices
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"regexp"
+	"slices"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/attributevalue"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+
+	"github.com/Fleexa-Graduation-Project/Backend/models"
+	"github.com/Fleexa-Graduation-Project/Backend/pkg/timeout"
+)
+
+const MaxTagLength = 64
+
+var ErrInvalidTag = errors.New("invalid tag")
+
+// a plain label ("refrigerated") or key:value ("region:west"), lowercase letters, digits, '-', '_' and '.'
+var tagPattern = regexp.MustCompile(`^[a-z0-9][a-z0-9_.-]*(:[a-z0-9][a-z0-9_.-]*)?$`)
+
+// NormalizeTag lowercases the tag and checks its length and charset
+func NormalizeTag(tag string) (string, error) {
+	tag = strings.ToLower(strings.TrimSpace(tag))
+	if tag == "" || len(tag) > MaxTagLength {
+		return "", fmt.Errorf("%w: must be 1 to %d characters", ErrInvalidTag, MaxTagLength)
+	}
+	if !tagPattern.MatchString(tag) {
+		return "", fmt.Errorf("%w: use a label or key:value of lowercase letters, digits, '-', '_' and '.'", ErrInvalidTag)
+	}
+	return tag, nil
+}
+
+// AddTag adds the tag to the device's string set and returns the updated device. Adding a tag
+// that is already set changes nothing, an unregistered id returns ErrDeviceNotFound
+func (store *DeviceStore) AddTag(ctx context.Context, deviceID, tag string) (*models.Device, error) {
+	return store.updateTags(ctx, deviceID, "ADD", tag)
+}
+
+// RemoveTag deletes the tag, removing one the device doesn't have changes nothing
+func (store *DeviceStore) RemoveTag(ctx context.Context, deviceID, tag string) (*models.Device, error) {
+	return store.updateTags(ctx, deviceID, "DELETE", tag)
+}
+
+// ADD and DELETE on a string set are idempotent, so a retried request is harmless
+func (store *DeviceStore) updateTags(ctx context.Context, deviceID, action, tag string) (*models.Device, error) {
+	input := &dynamodb.UpdateItemInput{
+		TableName: aws.String(store.TableName),
+		Key: map[string]types.AttributeValue{
+			"device_id": &types.AttributeValueMemberS{Value: deviceID},
+		},
+		UpdateExpression:    aws.String(action + " tags :tag"),
+		ConditionExpression: aws.String("attribute_exists(device_id)"),
+		ExpressionAttributeValues: map[string]types.AttributeValue{
+			":tag": &types.AttributeValueMemberSS{Value: []string{tag}},
+		},
+		ReturnValues: types.ReturnValueAllNew,
+	}
+
+	callCtx, cancel := timeout.Call(ctx)
+	defer cancel()
+	result, err := store.Client.UpdateItem(callCtx, input)
+	if err != nil {
+		var conditionErr *types.ConditionalCheckFailedException
+		if errors.As(err, &conditionErr) {
+			return nil, fmt.Errorf("%w: %s", ErrDeviceNotFound, deviceID)
+		}
+		return nil, fmt.Errorf("failed to update tags of device %s: %w", deviceID, err)
+	}
+
+	var device models.Device
+	if err = attributevalue.UnmarshalMap(result.Attributes, &device); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal device %s: %w", deviceID, err)
+	}
+	return &device, nil
+}
+
+func (store *MemDeviceStore) AddTag(ctx context.Context, deviceID, tag string) (*models.Device, error) {
+	return store.updateTags(deviceID, func(tags []string) []string {
+		if slices.Contains(tags, tag) {
+			return tags
+		}
+		return append(tags, tag)
+	})
+}
+
+func (store *MemDeviceStore) RemoveTag(ctx context.Context, deviceID, tag string) (*models.Device, error) {
+	return store.updateTags(deviceID, func(tags []string) []string {
+		return slices.DeleteFunc(tags, func(t string) bool { return t == tag })
+	})
+}
+
+func (store *MemDeviceStore) updateTags(deviceID string, update func([]string) []string) (*models.Device, error) {
+	store.mu.Lock()
+	defer store.mu.Unlock()
+
+	device, ok := store.devices[deviceID]
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrDeviceNotFound, deviceID)
+	}
+	device.Tags = update(slices.Clone(device.Tags))
+	if len(device.Tags) == 0 {
+		device.Tags = nil // an emptied string set is removed in dynamodb too
+	}
+	store.devices[deviceID] = device
+	return &device, nil
+}
@@ -0,0 +1,100 @@
+package devices
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+
+	"github.com/Fleexa-Graduation-Project/Backend/pkg/timeout"
+)
+
+var ErrDeviceNotFound = errors.New("device not found")
+
+// DevicePatch is a partial update of the registry metadata, nil fields are left unchanged
+type DevicePatch struct {
+	Name    *string `json:"name"`
+	FleetID *string `json:"fleet_id"`
+
+	ReportInterval *int64 `json:"report_interval_seconds"` // 0 goes back to the default
+}
+
+func (patch DevicePatch) Empty() bool {
+	return patch.Name == nil && patch.FleetID == nil && patch.ReportInterval == nil
+}
+
+// UpdateDevice applies the patch, returns ErrDeviceNotFound for an unregistered id. DynamoDB
+// moves the item to its new FleetIndex partition itself when fleet_id changes
+func (store *DeviceStore) UpdateDevice(ctx context.Context, deviceID string, patch DevicePatch) error {
+	if patch.Empty() {
+		return nil
+	}
+
+	var sets []string
+	names := map[string]string{}
+	values := map[string]types.AttributeValue{}
+	if patch.Name != nil {
+		sets = append(sets, "#name = :name") // name is a reserved word
+		names["#name"] = "name"
+		values[":name"] = &types.AttributeValueMemberS{Value: *patch.Name}
+	}
+	if patch.FleetID != nil {
+		sets = append(sets, "fleet_id = :fleet")
+		values[":fleet"] = &types.AttributeValueMemberS{Value: *patch.FleetID}
+	}
+	if patch.ReportInterval != nil {
+		sets = append(sets, "report_interval_seconds = :interval")
+		values[":interval"] = &types.AttributeValueMemberN{Value: fmt.Sprint(*patch.ReportInterval)}
+	}
+
+	input := &dynamodb.UpdateItemInput{
+		TableName: aws.String(store.TableName),
+		Key: map[string]types.AttributeValue{
+			"device_id": &types.AttributeValueMemberS{Value: deviceID},
+		},
+		UpdateExpression:          aws.String("SET " + strings.Join(sets, ", ")),
+		ConditionExpression:       aws.String("attribute_exists(device_id)"),
+		ExpressionAttributeValues: values,
+	}
+	if len(names) > 0 {
+		input.ExpressionAttributeNames = names
+	}
+
+	callCtx, cancel := timeout.Call(ctx)
+	defer cancel()
+	_, err := store.Client.UpdateItem(callCtx, input)
+	if err != nil {
+		var conditionErr *types.ConditionalCheckFailedException
+		if errors.As(err, &conditionErr) {
+			return fmt.Errorf("%w: %s", ErrDeviceNotFound, deviceID)
+		}
+		return fmt.Errorf("failed to update device %s: %w", deviceID, err)
+	}
+
+	return nil
+}
+
+func (store *MemDeviceStore) UpdateDevice(ctx context.Context, deviceID string, patch DevicePatch) error {
+	store.mu.Lock()
+	defer store.mu.Unlock()
+
+	device, ok := store.devices[deviceID]
+	if !ok {
+		return fmt.Errorf("%w: %s", ErrDeviceNotFound, deviceID)
+	}
+	if patch.Name != nil {
+		device.Name = *patch.Name
+	}
+	if patch.FleetID != nil {
+		device.FleetID = *patch.FleetID
+	}
+	if patch.ReportInterval != nil {
+		device.ReportInterval = *patch.ReportInterval
+	}
+	store.devices[deviceID] = device
+	return nil
+}
@@ -0,0 +1,163 @@
+package flags
+
+import (
+	"context"
+	"fmt"
+	"hash/fnv"
+	"log/slog"
+	"os"
+	"slices"
+	"sync/atomic"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/attributevalue"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+
+	appconfig "github.com/Fleexa-Graduation-Project/Backend/pkg/config"
+	"github.com/Fleexa-Graduation-Project/Backend/pkg/db"
+	"github.com/Fleexa-Graduation-Project/Backend/pkg/timeout"
+)
+
+const (
+	controlKey = "feature_flags"
+	// the flags are re-read at most this often per container, a change takes effect within it
+	DefaultRefresh = 30 * time.Second
+)
+
+// Flag is one entry of the feature_flags control item. A fleet gets the flag when it is
+// enabled for everyone, listed in Fleets, or falls in the Percent share of fleets
+type Flag struct {
+	Enabled bool     `dynamodbav:"enabled"`
+	Fleets  []string `dynamodbav:"fleets,omitempty"`
+	// 0-100, a fleet's place is a stable hash of flag and fleet, raising it only adds fleets
+	Percent int `dynamodbav:"percent,omitempty"`
+}
+
+// control item in DYNAMODB_CONTROL_TABLE, keyed control_key = "feature_flags"
+type controlItem struct {
+	Flags map[string]Flag `dynamodbav:"flags"`
+}
+
+// Flags gates code paths per fleet for a gradual rollout. Evaluation only reads the in-memory
+// copy, a stale copy is refreshed in the background and the old one serves until then
+type Flags struct {
+	Client    *dynamodb.Client
+	TableName string
+	Refresh   time.Duration
+
+	flags      atomic.Pointer[map[string]Flag]
+	loadedAt   atomic.Int64 // unix nanos of the last refresh attempt
+	refreshing atomic.Bool
+}
+
+func NewFlags() (*Flags, error) {
+	tableName := appconfig.TableName("DYNAMODB_CONTROL_TABLE")
+	if tableName == "" {
+		return nil, fmt.Errorf("DYNAMODB_CONTROL_TABLE environment variable is not set")
+	}
+
+	if db.Client == nil {
+		return nil, fmt.Errorf("dynamodb client is not initialized")
+	}
+
+	return &Flags{
+		Client:    db.Client,
+		TableName: tableName,
+		Refresh:   RefreshInterval(),
+	}, nil
+}
+
+// RefreshInterval is FEATURE_FLAGS_REFRESH, DefaultRefresh when unset or invalid
+func RefreshInterval() time.Duration {
+	if parsed, err := time.ParseDuration(os.Getenv("FEATURE_FLAGS_REFRESH")); err == nil && parsed > 0 {
+		return parsed
+	}
+	return DefaultRefresh
+}
+
+// IsEnabled reports whether flag is on for the fleet. Unknown flags, flags not loaded yet and a
+// nil *Flags are off. Never waits on dynamodb
+func (f *Flags) IsEnabled(ctx context.Context, flag string, fleetID string) bool {
+	if f == nil {
+		return false
+	}
+	if time.Since(time.Unix(0, f.loadedAt.Load())) >= f.Refresh && f.refreshing.CompareAndSwap(false, true) {
+		go func() {
+			defer f.refreshing.Store(false)
+			// the refresh outlives the message that noticed the stale copy
+			f.Load(context.WithoutCancel(ctx))
+		}()
+	}
+
+	current := f.flags.Load()
+	if current == nil {
+		return false
+	}
+	rule, ok := (*current)[flag]
+	if !ok {
+		return false
+	}
+	return rule.enabledFor(flag, fleetID)
+}
+
+func (rule Flag) enabledFor(flag, fleetID string) bool {
+	if rule.Enabled {
+		return true
+	}
+	if fleetID == "" {
+		return false
+	}
+	if slices.Contains(rule.Fleets, fleetID) {
+		return true
+	}
+	return rule.Percent > 0 && bucket(flag, fleetID) < rule.Percent
+}
+
+// the fleet's place in 0-99 for the flag, different flags pick different fleets first
+func bucket(flag, fleetID string) int {
+	hash := fnv.New32a()
+	hash.Write([]byte(flag + "/" + fleetID))
+	return int(hash.Sum32() % 100)
+}
+
+// Load reads the flags now, meant for init so the first messages see them. A failed read keeps
+// the flags loaded before and is tried again after Refresh
+func (f *Flags) Load(ctx context.Context) error {
+	f.loadedAt.Store(time.Now().UnixNano())
+
+	item, err := f.read(ctx)
+	if err != nil {
+		slog.Warn("failed to read feature flags, keeping the last ones", "error", err)
+		return err
+	}
+	f.flags.Store(&item.Flags)
+	return nil
+}
+
+func (f *Flags) read(ctx context.Context) (controlItem, error) {
+	callCtx, cancel := timeout.Call(ctx)
+	defer cancel()
+	result, err := f.Client.GetItem(callCtx, &dynamodb.GetItemInput{
+		TableName: aws.String(f.TableName),
+		Key: map[string]types.AttributeValue{
+			"control_key": &types.AttributeValueMemberS{Value: controlKey},
+		},
+	})
+	if err != nil {
+		return controlItem{}, fmt.Errorf("failed to get control item %s: %w", controlKey, err)
+	}
+
+	item := controlItem{Flags: map[string]Flag{}}
+	if result.Item == nil {
+		return item, nil // no item, every flag is off
+	}
+	if err = attributevalue.UnmarshalMap(result.Item, &item); err != nil {
+		return controlItem{}, fmt.Errorf("failed to unmarshal control item %s: %w", controlKey, err)
+	}
+	if item.Flags == nil {
+		item.Flags = map[string]Flag{}
+	}
+	return item, nil
+}
@@ -0,0 +1,143 @@
+package fleets
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/Fleexa-Graduation-Project/Backend/internal/devices"
+	"github.com/Fleexa-Graduation-Project/Backend/internal/trips"
+	"github.com/Fleexa-Graduation-Project/Backend/models"
+)
+
+// the summary card is refreshed by the dashboard every minute or so, a few minutes of staleness is fine
+const DefaultStatsTTL = 5 * time.Minute
+
+// Stats is the fleet summary card, ComputedAt tells the client how old a cached value is
+type Stats struct {
+	FleetID         string  `json:"fleet_id"`
+	TotalDevices    int     `json:"total_devices"`
+	OnlineDevices   int     `json:"online_devices"`
+	DistanceTodayKM float64 `json:"distance_today_km"`
+	AvgSpeedKPH     float64 `json:"avg_speed_kph"`
+	ComputedAt      int64   `json:"computed_at"`
+}
+
+// StatsService computes fleet stats and keeps them per lambda container for TTL, computing walks
+// every device of the fleet so it is too expensive to run on each dashboard refresh
+type StatsService struct {
+	Registry   devices.Registry
+	StateStore *devices.StateStore
+	TripStore  *trips.TripStore
+	TTL        time.Duration
+
+	mu    sync.Mutex
+	cache map[string]Stats
+}
+
+// FLEET_STATS_TTL overrides the default
+func NewStatsService(registry devices.Registry, stateStore *devices.StateStore, tripStore *trips.TripStore) (*StatsService, error) {
+	service := &StatsService{
+		Registry:   registry,
+		StateStore: stateStore,
+		TripStore:  tripStore,
+		TTL:        DefaultStatsTTL,
+		cache:      map[string]Stats{},
+	}
+
+	if raw := os.Getenv("FLEET_STATS_TTL"); raw != "" {
+		ttl, err := time.ParseDuration(raw)
+		if err != nil || ttl < 0 {
+			return nil, fmt.Errorf("invalid FLEET_STATS_TTL %q", raw)
+		}
+		service.TTL = ttl
+	}
+
+	return service, nil
+}
+
+// Get serves the cached stats while they are fresh, refresh forces a recomputation
+func (service *StatsService) Get(ctx context.Context, fleetID string, refresh bool) (Stats, error) {
+	if !refresh {
+		service.mu.Lock()
+		cached, ok := service.cache[fleetID]
+		service.mu.Unlock()
+		if ok && time.Since(time.Unix(cached.ComputedAt, 0)) < service.TTL {
+			return cached, nil
+		}
+	}
+
+	stats, err := service.compute(ctx, fleetID, time.Now())
+	if err != nil {
+		return Stats{}, err
+	}
+
+	service.mu.Lock()
+	service.cache[fleetID] = stats
+	service.mu.Unlock()
+	return stats, nil
+}
+
+// distance and speed come from the trips that started since midnight UTC, the aggregator runs
+// hourly so the trip in progress is not counted yet
+func (service *StatsService) compute(ctx context.Context, fleetID string, now time.Time) (Stats, error) {
+	fleetDevices, err := service.listFleet(ctx, fleetID)
+	if err != nil {
+		return Stats{}, err
+	}
+
+	stats := Stats{FleetID: fleetID, TotalDevices: len(fleetDevices), ComputedAt: now.Unix()}
+	if len(fleetDevices) == 0 {
+		return stats, nil
+	}
+
+	// one scan is cheaper than a read per device for any fleet worth a summary card
+	states, err := service.StateStore.GetAllStates(ctx)
+	if err != nil {
+		return Stats{}, fmt.Errorf("failed to fetch device states for fleet %s: %w", fleetID, err)
+	}
+	lastSeen := make(map[string]int64, len(states))
+	for _, state := range states {
+		lastSeen[state.DeviceID] = state.LastSeenAt
+	}
+
+	midnight := now.UTC().Truncate(24 * time.Hour).Unix()
+	var drivingSeconds int64
+	for _, device := range fleetDevices {
+		if seen, ok := lastSeen[device.DeviceID]; ok && devices.ConnectionStatus(seen) == "ONLINE" {
+			stats.OnlineDevices++
+		}
+
+		today, err := service.TripStore.ListTripsSince(ctx, device.DeviceID, midnight)
+		if err != nil {
+			return Stats{}, fmt.Errorf("failed to fetch trips for fleet %s: %w", fleetID, err)
+		}
+		for _, t := range today {
+			stats.DistanceTodayKM += t.DistanceKM
+			drivingSeconds += t.EndTime - t.StartTime
+		}
+	}
+
+	if drivingSeconds > 0 {
+		stats.AvgSpeedKPH = stats.DistanceTodayKM / (float64(drivingSeconds) / 3600)
+	}
+	return stats, nil
+}
+
+func (service *StatsService) listFleet(ctx context.Context, fleetID string) ([]models.Device, error) {
+	var all []models.Device
+	cursor := ""
+	for {
+		page, err := service.Registry.ListDevices(ctx, fleetID, devices.MaxPageLimit, cursor)
+		if err != nil {
+			return nil, fmt.Errorf("failed to list devices of fleet %s: %w", fleetID, err)
+		}
+		all = append(all, page.Devices...)
+		if page.NextCursor == "" {
+			return all, nil
+		}
+		cursor = page.NextCursor
+	}
+}
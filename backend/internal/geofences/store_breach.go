@@ -0,0 +1,130 @@
+package geofences
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/attributevalue"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+
+	appconfig "github.com/Fleexa-Graduation-Project/Backend/pkg/config"
+	"github.com/Fleexa-Graduation-Project/Backend/pkg/db"
+	"github.com/Fleexa-Graduation-Project/Backend/pkg/geo"
+	"github.com/Fleexa-Graduation-Project/Backend/pkg/timeout"
+)
+
+const breachFleetIndex = "FleetIndex" // GSI: fleet_id (HASH), started_at (RANGE)
+
+// a device currently outside one of its fences, the item is deleted when it re-enters
+type Breach struct {
+	DeviceID     string    `json:"device_id" dynamodbav:"device_id"`
+	GeofenceID   string    `json:"geofence_id" dynamodbav:"geofence_id"`
+	FleetID      string    `json:"fleet_id" dynamodbav:"fleet_id"`
+	StartedAt    int64     `json:"started_at" dynamodbav:"started_at"`
+	LastPosition geo.Coord `json:"last_position" dynamodbav:"last_position"`
+	UpdatedAt    int64     `json:"updated_at" dynamodbav:"updated_at"`
+}
+
+type BreachStore struct {
+	Client    *dynamodb.Client
+	TableName string
+}
+
+func NewBreachStore() (*BreachStore, error) {
+	tableName := appconfig.TableName("DYNAMODB_BREACHES_TABLE")
+	if tableName == "" {
+		return nil, fmt.Errorf("DYNAMODB_BREACHES_TABLE environment variable is not set")
+	}
+
+	if db.Client == nil {
+		return nil, fmt.Errorf("dynamodb client is not initialized")
+	}
+
+	return &BreachStore{
+		Client:    db.Client,
+		TableName: tableName,
+	}, nil
+}
+
+// the open breaches of a device keyed by geofence id, empty when it is inside all of its fences
+func (store *BreachStore) OpenForDevice(ctx context.Context, deviceID string) (map[string]Breach, error) {
+	input := &dynamodb.QueryInput{
+		TableName:              aws.String(store.TableName),
+		KeyConditionExpression: aws.String("device_id = :id"),
+		ExpressionAttributeValues: map[string]types.AttributeValue{
+			":id": &types.AttributeValueMemberS{Value: deviceID},
+		},
+	}
+
+	callCtx, cancel := timeout.Call(ctx)
+	defer cancel()
+	result, err := store.Client.Query(callCtx, input)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query breaches for device %s: %w", deviceID, err)
+	}
+
+	var breaches []Breach
+	if err = attributevalue.UnmarshalListOfMaps(result.Items, &breaches); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal breaches for device %s: %w", deviceID, err)
+	}
+
+	open := make(map[string]Breach, len(breaches))
+	for _, breach := range breaches {
+		open[breach.GeofenceID] = breach
+	}
+	return open, nil
+}
+
+// opens a breach or moves the last position of an open one, callers keep StartedAt of the open breach
+func (store *BreachStore) PutBreach(ctx context.Context, breach Breach) error {
+	item, err := attributevalue.MarshalMap(breach)
+	if err != nil {
+		return fmt.Errorf("failed to marshal breach: %w", err)
+	}
+
+	callCtx, cancel := timeout.Call(ctx)
+	defer cancel()
+	_, err = store.Client.PutItem(callCtx, &dynamodb.PutItemInput{
+		TableName: aws.String(store.TableName),
+		Item:      item,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to save breach of device %s from geofence %s: %w", breach.DeviceID, breach.GeofenceID, err)
+	}
+	return nil
+}
+
+// closes the breach once the device is back inside, closing one that isn't open is a no-op
+func (store *BreachStore) CloseBreach(ctx context.Context, deviceID, geofenceID string) error {
+	callCtx, cancel := timeout.Call(ctx)
+	defer cancel()
+	_, err := store.Client.DeleteItem(callCtx, &dynamodb.DeleteItemInput{
+		TableName: aws.String(store.TableName),
+		Key: map[string]types.AttributeValue{
+			"device_id":   &types.AttributeValueMemberS{Value: deviceID},
+			"geofence_id": &types.AttributeValueMemberS{Value: geofenceID},
+		},
+	})
+	if err != nil {
+		return fmt.Errorf("failed to close breach of device %s from geofence %s: %w", deviceID, geofenceID, err)
+	}
+	return nil
+}
+
+// every open breach of a fleet, oldest first, using the FleetIndex GSI
+func (store *BreachStore) ListOpen(ctx context.Context, fleetID string) ([]Breach, error) {
+	breaches, err := db.Collect(db.QueryAll[Breach](ctx, store.Client, &dynamodb.QueryInput{
+		TableName:              aws.String(store.TableName),
+		IndexName:              aws.String(breachFleetIndex),
+		KeyConditionExpression: aws.String("fleet_id = :fleet"),
+		ExpressionAttributeValues: map[string]types.AttributeValue{
+			":fleet": &types.AttributeValueMemberS{Value: fleetID},
+		},
+	}))
+	if err != nil {
+		return nil, fmt.Errorf("failed to query breaches for fleet %s: %w", fleetID, err)
+	}
+	return breaches, nil
+}
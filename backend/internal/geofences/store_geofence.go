@@ -0,0 +1,73 @@
+package geofences
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/attributevalue"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+
+	appconfig "github.com/Fleexa-Graduation-Project/Backend/pkg/config"
+	"github.com/Fleexa-Graduation-Project/Backend/pkg/db"
+	"github.com/Fleexa-Graduation-Project/Backend/pkg/geo"
+	"github.com/Fleexa-Graduation-Project/Backend/pkg/timeout"
+)
+
+// one item per (device_id, geofence_id) assignment
+type geofenceRecord struct {
+	DeviceID   string      `dynamodbav:"device_id"`
+	GeofenceID string      `dynamodbav:"geofence_id"`
+	Polygon    []geo.Coord `dynamodbav:"polygon"`
+}
+
+type GeofenceStore struct {
+	Client    *dynamodb.Client
+	TableName string
+}
+
+func NewGeofenceStore() (*GeofenceStore, error) {
+	tableName := appconfig.TableName("DYNAMODB_GEOFENCES_TABLE")
+	if tableName == "" {
+		return nil, fmt.Errorf("DYNAMODB_GEOFENCES_TABLE environment variable is not set")
+	}
+
+	if db.Client == nil {
+		return nil, fmt.Errorf("dynamodb client is not initialized")
+	}
+
+	return &GeofenceStore{
+		Client:    db.Client,
+		TableName: tableName,
+	}, nil
+}
+
+// returns the fences assigned to a device, empty when it has none
+func (store *GeofenceStore) GetGeofencesForDevice(ctx context.Context, deviceID string) ([]geo.Geofence, error) {
+	input := &dynamodb.QueryInput{
+		TableName:              aws.String(store.TableName),
+		KeyConditionExpression: aws.String("device_id = :id"),
+		ExpressionAttributeValues: map[string]types.AttributeValue{
+			":id": &types.AttributeValueMemberS{Value: deviceID},
+		},
+	}
+
+	callCtx, cancel := timeout.Call(ctx)
+	defer cancel()
+	result, err := store.Client.Query(callCtx, input)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query geofences for device %s: %w", deviceID, err)
+	}
+
+	var records []geofenceRecord
+	if err = attributevalue.UnmarshalListOfMaps(result.Items, &records); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal geofences for device %s: %w", deviceID, err)
+	}
+
+	fences := make([]geo.Geofence, 0, len(records))
+	for _, record := range records {
+		fences = append(fences, geo.Geofence{ID: record.GeofenceID, Polygon: record.Polygon})
+	}
+	return fences, nil
+}
@@ -0,0 +1,172 @@
+package idempotency
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/attributevalue"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+
+	appconfig "github.com/Fleexa-Graduation-Project/Backend/pkg/config"
+	"github.com/Fleexa-Graduation-Project/Backend/pkg/db"
+	"github.com/Fleexa-Graduation-Project/Backend/pkg/timeout"
+)
+
+const (
+	StatusInProgress = "in_progress"
+	StatusCompleted  = "completed"
+
+	// how long a stored response is replayed for
+	DefaultTTL = 24 * time.Hour
+	// an in progress key whose request died with its lambda is free again after this, api gateway
+	// gives up on a request after 29s so nothing still runs under an older lock
+	DefaultLockTimeout = 30 * time.Second
+)
+
+// Record is one key of one caller on one route, with the response once the request completed
+type Record struct {
+	Key         string `dynamodbav:"idempotency_key"`
+	RequestHash string `dynamodbav:"request_hash"`
+	Status      string `dynamodbav:"status"`
+	StatusCode  int    `dynamodbav:"status_code,omitempty"`
+	ContentType string `dynamodbav:"content_type,omitempty"`
+	Body        []byte `dynamodbav:"body,omitempty"`
+	LockedUntil int64  `dynamodbav:"locked_until,omitempty"`
+	CreatedAt   int64  `dynamodbav:"created_at"`
+	ExpiresAt   int64  `dynamodbav:"expires_at"` // ttl
+}
+
+// keyed idempotency_key (HASH), expires_at is the ttl attribute
+type Store struct {
+	Client      *dynamodb.Client
+	TableName   string
+	TTL         time.Duration
+	LockTimeout time.Duration
+}
+
+func NewStore() (*Store, error) {
+	tableName := appconfig.TableName("DYNAMODB_IDEMPOTENCY_TABLE")
+	if tableName == "" {
+		return nil, fmt.Errorf("DYNAMODB_IDEMPOTENCY_TABLE environment variable is not set")
+	}
+
+	if db.Client == nil {
+		return nil, fmt.Errorf("dynamodb client is not initialized")
+	}
+
+	return &Store{
+		Client:      db.Client,
+		TableName:   tableName,
+		TTL:         TTL(),
+		LockTimeout: DefaultLockTimeout,
+	}, nil
+}
+
+// TTL is IDEMPOTENCY_TTL, DefaultTTL when unset or invalid
+func TTL() time.Duration {
+	if parsed, err := time.ParseDuration(os.Getenv("IDEMPOTENCY_TTL")); err == nil && parsed > 0 {
+		return parsed
+	}
+	return DefaultTTL
+}
+
+// Begin claims key for a request. It returns nil when the caller now owns the key and must run
+// the request, or the record that holds it: completed with the response to replay, or still in
+// progress. Expired records and stale locks are taken over
+func (store *Store) Begin(ctx context.Context, key, requestHash string) (*Record, error) {
+	now := time.Now()
+	record := Record{
+		Key:         key,
+		RequestHash: requestHash,
+		Status:      StatusInProgress,
+		LockedUntil: now.Add(store.LockTimeout).Unix(),
+		CreatedAt:   now.Unix(),
+		ExpiresAt:   now.Add(store.TTL).Unix(),
+	}
+	item, err := attributevalue.MarshalMap(record)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal idempotency record: %w", err)
+	}
+
+	callCtx, cancel := timeout.Call(ctx)
+	_, err = store.Client.PutItem(callCtx, &dynamodb.PutItemInput{
+		TableName: aws.String(store.TableName),
+		Item:      item,
+		// ttl deletion lags by up to days, an expired record must not replay
+		ConditionExpression:      aws.String("attribute_not_exists(idempotency_key) OR expires_at < :now OR (#status = :progress AND locked_until < :now)"),
+		ExpressionAttributeNames: map[string]string{"#status": "status"},
+		ExpressionAttributeValues: map[string]types.AttributeValue{
+			":now":      &types.AttributeValueMemberN{Value: fmt.Sprint(now.Unix())},
+			":progress": &types.AttributeValueMemberS{Value: StatusInProgress},
+		},
+		// the record holding the key comes back with the failure, no second read needed
+		ReturnValuesOnConditionCheckFailure: types.ReturnValuesOnConditionCheckFailureAllOld,
+	})
+	cancel()
+	err = db.Condition(err)
+	if !errors.Is(err, db.ErrConditionFailed) {
+		if err != nil {
+			return nil, fmt.Errorf("failed to claim idempotency key: %w", err)
+		}
+		return nil, nil
+	}
+
+	var existing Record
+	if _, err := db.CurrentItem(err, &existing); err != nil {
+		return nil, err
+	}
+	return &existing, nil
+}
+
+// Complete stores the response of the request that owns key
+func (store *Store) Complete(ctx context.Context, key string, statusCode int, contentType string, body []byte) error {
+	values := map[string]types.AttributeValue{
+		":completed": &types.AttributeValueMemberS{Value: StatusCompleted},
+		":code":      &types.AttributeValueMemberN{Value: fmt.Sprint(statusCode)},
+		":type":      &types.AttributeValueMemberS{Value: contentType},
+		":body":      &types.AttributeValueMemberB{Value: body},
+	}
+	update := "SET #status = :completed, status_code = :code, content_type = :type, body = :body REMOVE locked_until"
+	if len(body) == 0 {
+		// binary attributes can't be empty
+		update = "SET #status = :completed, status_code = :code, content_type = :type REMOVE locked_until, body"
+		delete(values, ":body")
+	}
+
+	callCtx, cancel := timeout.Call(ctx)
+	defer cancel()
+	_, err := store.Client.UpdateItem(callCtx, &dynamodb.UpdateItemInput{
+		TableName:                 aws.String(store.TableName),
+		Key:                       store.key(key),
+		UpdateExpression:          aws.String(update),
+		ExpressionAttributeNames:  map[string]string{"#status": "status"},
+		ExpressionAttributeValues: values,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to store idempotent response: %w", err)
+	}
+	return nil
+}
+
+// Release frees key after a request that should be retried for real, a 5xx for example
+func (store *Store) Release(ctx context.Context, key string) error {
+	callCtx, cancel := timeout.Call(ctx)
+	defer cancel()
+	_, err := store.Client.DeleteItem(callCtx, &dynamodb.DeleteItemInput{
+		TableName: aws.String(store.TableName),
+		Key:       store.key(key),
+	})
+	if err != nil {
+		return fmt.Errorf("failed to release idempotency key: %w", err)
+	}
+	return nil
+}
+
+func (store *Store) key(key string) map[string]types.AttributeValue {
+	return map[string]types.AttributeValue{"idempotency_key": &types.AttributeValueMemberS{Value: key}}
+}
@@ -0,0 +1,113 @@
+package ingestion
+
+import (
+	"errors"
+	"log/slog"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/Fleexa-Graduation-Project/Backend/pkg/breaker"
+	"github.com/Fleexa-Graduation-Project/Backend/pkg/db"
+	"github.com/Fleexa-Graduation-Project/Backend/pkg/logger"
+	"github.com/Fleexa-Graduation-Project/Backend/pkg/metrics"
+	"github.com/Fleexa-Graduation-Project/Backend/pkg/recovery"
+	"github.com/Fleexa-Graduation-Project/Backend/pkg/timeout"
+)
+
+const DefaultBatchFailureAlertRate = 0.5
+
+// BatchFailureAlertRate is the share of failed records above which the batch summary is logged
+// at error level, INGESTION_BATCH_FAILURE_ALERT_RATE (0 to 1) overrides the default of half
+func BatchFailureAlertRate() float64 {
+	raw := os.Getenv("INGESTION_BATCH_FAILURE_ALERT_RATE")
+	if raw == "" {
+		return DefaultBatchFailureAlertRate
+	}
+
+	rate, err := strconv.ParseFloat(raw, 64)
+	if err != nil || rate < 0 || rate > 1 {
+		slog.Warn("invalid INGESTION_BATCH_FAILURE_ALERT_RATE, falling back to default", "value", raw, "default", DefaultBatchFailureAlertRate)
+		return DefaultBatchFailureAlertRate
+	}
+	return rate
+}
+
+// the reason a record is sent back to sqs, a small fixed set so it can be a metric dimension
+func failureReason(err error) string {
+	switch {
+	case errors.Is(err, timeout.ErrDependencyTimeout):
+		return "dependency_timeout"
+	case errors.Is(err, breaker.ErrOpen):
+		return "breaker_open"
+	case db.IsThrottled(err):
+		return "throttled"
+	case errors.Is(err, recovery.ErrPanic):
+		return "panic"
+	default:
+		return "processing_error"
+	}
+}
+
+// health of one sqs batch, complements the per-message retries of the SQSEventResponse
+type batchSummary struct {
+	records int
+	failed  int
+	reasons map[string]int
+}
+
+func (summary *batchSummary) fail(err error) {
+	if summary.reasons == nil {
+		summary.reasons = map[string]int{}
+	}
+	summary.failed++
+	summary.reasons[failureReason(err)]++
+}
+
+// the most frequent failure reason, ties go to the first name alphabetically so it is stable
+func (summary *batchSummary) dominantReason() string {
+	dominant := ""
+	for reason, count := range summary.reasons {
+		if dominant == "" || count > summary.reasons[dominant] || count == summary.reasons[dominant] && reason < dominant {
+			dominant = reason
+		}
+	}
+	return dominant
+}
+
+// emits the batch metrics and logs the one summary line of the invocation, at error level when
+// the failure rate is over alertRate so it reaches the alarms
+func (summary *batchSummary) report(log *slog.Logger, duration time.Duration, alertRate float64) {
+	succeeded := summary.records - summary.failed
+	metrics.Count("BatchSize", float64(summary.records), nil)
+	metrics.Count("BatchSucceeded", float64(succeeded), nil)
+	metrics.Count("BatchFailed", float64(summary.failed), nil)
+
+	rate := 0.0
+	if summary.records > 0 {
+		rate = float64(summary.failed) / float64(summary.records)
+	}
+
+	args := []any{
+		"execution_time", duration.Milliseconds(),
+		"records", summary.records,
+		"succeeded", succeeded,
+		"failed", summary.failed,
+		"failure_rate", rate,
+	}
+	if summary.failed == 0 {
+		// one line per invocation, kept whatever LOG_SAMPLE_RATE drops of the per message lines
+		logger.Unsampled(log).Info("lambda execution complete", args...)
+		return
+	}
+
+	dominant := summary.dominantReason()
+	metrics.Count("BatchFailureReason", 1, map[string]string{"Reason": dominant})
+	args = append(args, "dominant_failure_reason", dominant, "failure_reasons", summary.reasons)
+
+	if rate > alertRate {
+		log.Error("lambda execution complete", append(args, "reason", "batch_failure_rate")...)
+		return
+	}
+	log.Warn("lambda execution complete", args...)
+}
@@ -0,0 +1,59 @@
+package ingestion
+
+import (
+	"context"
+	"time"
+
+	"github.com/Fleexa-Graduation-Project/Backend/internal/geofences"
+	"github.com/Fleexa-Graduation-Project/Backend/pkg/geo"
+)
+
+// keeps the open breaches in step with the position and returns the fences the device just left,
+// only those raise an alert. When the breaches can't be read every fence is returned, so an
+// alert is never lost (it may repeat)
+func (service *Service) trackBreaches(ctx context.Context, deviceID string, position geo.Coord, fences []geo.Geofence) []geo.Geofence {
+	open, err := service.BreachStore.OpenForDevice(ctx, deviceID)
+	if err != nil {
+		service.Logger.Warn("failed to load open breaches", "device_id", deviceID, "error", err)
+		return fences
+	}
+
+	now := time.Now().Unix()
+	left := []geo.Geofence{}
+	for _, fence := range fences {
+		breach, isOpen := open[fence.ID]
+		delete(open, fence.ID)
+
+		if fence.Contains(position) {
+			if isOpen {
+				service.closeBreach(ctx, deviceID, fence.ID)
+			}
+			continue
+		}
+
+		if !isOpen {
+			breach = geofences.Breach{DeviceID: deviceID, GeofenceID: fence.ID, StartedAt: now}
+			left = append(left, fence)
+		}
+		breach.FleetID = service.fleetOf(ctx, deviceID)
+		breach.LastPosition = position
+		breach.UpdatedAt = now
+		if err := service.BreachStore.PutBreach(ctx, breach); err != nil {
+			service.Logger.Warn("failed to save geofence breach", "device_id", deviceID, "geofence_id", fence.ID, "error", err)
+		}
+	}
+
+	// the fence was unassigned while the device was outside it
+	for geofenceID := range open {
+		service.closeBreach(ctx, deviceID, geofenceID)
+	}
+	return left
+}
+
+func (service *Service) closeBreach(ctx context.Context, deviceID, geofenceID string) {
+	if err := service.BreachStore.CloseBreach(ctx, deviceID, geofenceID); err != nil {
+		service.Logger.Warn("failed to close geofence breach", "device_id", deviceID, "geofence_id", geofenceID, "error", err)
+		return
+	}
+	service.Logger.Info("geofence breach closed", "device_id", deviceID, "geofence_id", geofenceID)
+}
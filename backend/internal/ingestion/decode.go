@@ -0,0 +1,203 @@
+package ingestion
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-lambda-go/events"
+
+	"github.com/Fleexa-Graduation-Project/Backend/internal/validation"
+	payloadcodec "github.com/Fleexa-Graduation-Project/Backend/pkg/telemetry"
+)
+
+const (
+	defaultMaxDecompressedBytes = 256 * 1024
+	// how much of a malformed body is logged, enough to recognize it
+	malformedPrefixBytes = 64
+)
+
+var (
+	ErrPayloadTooLarge = errors.New("decompressed payload too large")
+	// ErrMalformedJSON is a body that isn't json at all, usually a transmission cut short, as
+	// opposed to valid json that fails validation
+	ErrMalformedJSON = errors.New("malformed json")
+)
+
+// MalformedJSONError says where the json broke, Offset is -1 when the decoder didn't say
+type MalformedJSONError struct {
+	Offset    int64
+	Truncated bool // the body ended in the middle of a value
+	Err       error
+}
+
+func (e *MalformedJSONError) Error() string {
+	if e.Truncated {
+		return fmt.Sprintf("%v: truncated at byte %d", ErrMalformedJSON, e.Offset)
+	}
+	return fmt.Sprintf("%v at byte %d: %v", ErrMalformedJSON, e.Offset, e.Err)
+}
+
+func (e *MalformedJSONError) Is(target error) bool { return target == ErrMalformedJSON }
+
+func (e *MalformedJSONError) Unwrap() error { return e.Err }
+
+var gzipMagic = []byte{0x1f, 0x8b}
+
+// MAX_DECOMPRESSED_BYTES caps how much a gzip body may expand to (decompression bombs)
+func maxDecompressedBytes() int64 {
+	if raw := os.Getenv("MAX_DECOMPRESSED_BYTES"); raw != "" {
+		if limit, err := strconv.ParseInt(raw, 10, 64); err == nil && limit > 0 {
+			return limit
+		}
+	}
+	return defaultMaxDecompressedBytes
+}
+
+// decodeBody returns the plain json body of a record, constrained devices gzip their payloads.
+// SQS bodies are text so compressed ones arrive base64 encoded with a Content-Encoding attribute
+func decodeBody(record events.SQSMessage) ([]byte, error) {
+	body := []byte(record.Body)
+
+	if strings.EqualFold(stringAttribute(record, "Content-Encoding"), "gzip") {
+		decoded, err := base64.StdEncoding.DecodeString(record.Body)
+		if err != nil {
+			return nil, fmt.Errorf("gzip body is not valid base64: %w", err)
+		}
+		body = decoded
+	}
+
+	if !bytes.HasPrefix(body, gzipMagic) {
+		return body, nil
+	}
+
+	return gunzip(body, maxDecompressedBytes())
+}
+
+func gunzip(compressed []byte, limit int64) ([]byte, error) {
+	reader, err := gzip.NewReader(bytes.NewReader(compressed))
+	if err != nil {
+		return nil, fmt.Errorf("invalid gzip payload: %w", err)
+	}
+	defer reader.Close()
+
+	// read one byte past the limit to tell "exactly at the limit" from "too large"
+	plain, err := io.ReadAll(io.LimitReader(reader, limit+1))
+	if err != nil {
+		return nil, fmt.Errorf("failed to decompress payload: %w", err)
+	}
+	if int64(len(plain)) > limit {
+		return nil, fmt.Errorf("%w: limit is %d bytes", ErrPayloadTooLarge, limit)
+	}
+
+	return plain, nil
+}
+
+// the value of a string message attribute, empty when the record doesn't have it
+func stringAttribute(record events.SQSMessage, name string) string {
+	if attr, ok := record.MessageAttributes[name]; ok && attr.StringValue != nil {
+		return *attr.StringValue
+	}
+	return ""
+}
+
+// sentAt is when sqs received the record, the send time of every delivery of it, now when the
+// record has no SentTimestamp
+func sentAt(record events.SQSMessage) time.Time {
+	if ms, err := strconv.ParseInt(record.Attributes["SentTimestamp"], 10, 64); err == nil && ms > 0 {
+		return time.UnixMilli(ms)
+	}
+	return time.Now()
+}
+
+// decodePayload converts a protobuf payload to the json envelope the validators take. The
+// encoding is the event's marker, else contentType (the Content-Type attribute of the sqs
+// record), json when neither is set. A protobuf payload is a base64 string in the event json
+func decodePayload(event RuleEvent, contentType string) (RuleEvent, error) {
+	marker := event.Encoding
+	if marker == "" {
+		marker = contentType
+	}
+	encoding, err := payloadcodec.ParseEncoding(marker)
+	if err != nil || encoding == payloadcodec.EncodingJSON {
+		return event, err
+	}
+
+	var encoded string
+	if err := json.Unmarshal(event.Payload, &encoded); err != nil {
+		return event, fmt.Errorf("%s payload must be a base64 string", encoding)
+	}
+	raw, err := base64.StdEncoding.DecodeString(encoded)
+	if err != nil {
+		return event, fmt.Errorf("%s payload is not valid base64: %w", encoding, err)
+	}
+
+	decoded, err := payloadcodec.Decode(encoding, raw)
+	if err != nil {
+		return event, err
+	}
+	event.Payload = decoded
+	return event, nil
+}
+
+// parseEvent decodes the rule event of a plain body. Broken json is a *MalformedJSONError, json
+// of the wrong shape (an array, a string topic...) is returned as the decoder's error
+func parseEvent(body []byte) (RuleEvent, error) {
+	var event RuleEvent
+	err := json.Unmarshal(body, &event)
+	if err == nil {
+		return event, nil
+	}
+
+	var syntaxErr *json.SyntaxError
+	if errors.As(err, &syntaxErr) {
+		end := int64(len(bytes.TrimRight(body, " \t\r\n")))
+		return event, &MalformedJSONError{Offset: syntaxErr.Offset, Truncated: syntaxErr.Offset >= end, Err: err}
+	}
+	if !json.Valid(body) {
+		return event, &MalformedJSONError{Offset: -1, Err: err}
+	}
+	return event, err
+}
+
+// the start of a body for the logs, capped so a large garbled body isn't dumped whole
+func payloadPrefix(body []byte) string {
+	if len(body) <= malformedPrefixBytes {
+		return string(body)
+	}
+	return string(body[:malformedPrefixBytes]) + "..."
+}
+
+// Diagnose re-runs decoding and validation on a dead-lettered record to say why it was rejected.
+// Records that decode and validate fine failed in persistence instead
+func Diagnose(record events.SQSMessage) string {
+	body, err := decodeBody(record)
+	if err != nil {
+		return fmt.Sprintf("decode_failed: %v", err)
+	}
+
+	event, err := parseEvent(body)
+	if errors.Is(err, ErrMalformedJSON) {
+		return fmt.Sprintf("malformed_json: %v", err)
+	}
+	if err != nil {
+		return fmt.Sprintf("validation_failed: %v", err)
+	}
+	if event, err = decodePayload(event, stringAttribute(record, "Content-Type")); err != nil {
+		return fmt.Sprintf("validation_failed: %v", err)
+	}
+
+	if _, _, _, _, err := validation.ValidateMessageAt(event.message(), event.receivedAt(sentAt(record))); err != nil {
+		return fmt.Sprintf("validation_failed: %v", err)
+	}
+
+	return fmt.Sprintf("processing_failed: exhausted %s receives", record.Attributes["ApproximateReceiveCount"])
+}
@@ -0,0 +1,92 @@
+package ingestion
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"slices"
+
+	"github.com/aws/aws-lambda-go/events"
+
+	"github.com/Fleexa-Graduation-Project/Backend/pkg/logger"
+)
+
+var ErrUnknownEvent = errors.New("unknown ingestion event")
+
+// the fields the event types are told apart by, only decoded far enough to look at them
+type eventShape struct {
+	Records []struct {
+		EventSource string `json:"eventSource"`
+	} `json:"Records"`
+	DetailType string          `json:"detail-type"`
+	Detail     json.RawMessage `json:"detail"`
+	Topic      *string         `json:"topic"`
+}
+
+// every record comes from sqs, a batch from another source (s3, kinesis) is not ours
+func (shape eventShape) fromSQS() bool {
+	for _, record := range shape.Records {
+		if record.EventSource != "aws:sqs" {
+			return false
+		}
+	}
+	return len(shape.Records) > 0
+}
+
+// HandleEvent is the lambda entry point for every trigger: an sqs batch, an IoT rule lambda
+// action, or an EventBridge event whose detail is the rule output. The event type is detected
+// from its characteristic fields, an unknown shape is logged and returned as an error
+func (s *Service) HandleEvent(ctx context.Context, raw json.RawMessage) (any, error) {
+	var shape eventShape
+	if err := json.Unmarshal(raw, &shape); err != nil {
+		return nil, s.unknownEvent(ctx, raw, fmt.Errorf("%w: %v", ErrUnknownEvent, err))
+	}
+
+	switch {
+	case shape.fromSQS():
+		var event events.SQSEvent
+		if err := json.Unmarshal(raw, &event); err != nil {
+			return nil, fmt.Errorf("failed to unmarshal sqs event: %w", err)
+		}
+		return s.HandleRequest(ctx, event)
+
+	case len(shape.Records) > 0:
+		return nil, s.unknownEvent(ctx, raw, fmt.Errorf("%w: records from %q", ErrUnknownEvent, shape.Records[0].EventSource))
+
+	case shape.DetailType != "" && len(shape.Detail) > 0:
+		var event events.EventBridgeEvent
+		if err := json.Unmarshal(raw, &event); err != nil {
+			return nil, fmt.Errorf("failed to unmarshal eventbridge event: %w", err)
+		}
+		var rule RuleEvent
+		if err := json.Unmarshal(event.Detail, &rule); err != nil || rule.Topic == "" {
+			return nil, s.unknownEvent(ctx, raw, fmt.Errorf("%w: eventbridge detail %q is not an iot rule message", ErrUnknownEvent, event.DetailType))
+		}
+		return nil, s.HandleRuleEvent(ctx, rule)
+
+	case shape.Topic != nil:
+		var rule RuleEvent
+		if err := json.Unmarshal(raw, &rule); err != nil {
+			return nil, fmt.Errorf("failed to unmarshal iot rule event: %w", err)
+		}
+		return nil, s.HandleRuleEvent(ctx, rule)
+	}
+
+	return nil, s.unknownEvent(ctx, raw, ErrUnknownEvent)
+}
+
+// logs the top-level keys of the event, not its body, payloads can be large
+func (s *Service) unknownEvent(ctx context.Context, raw json.RawMessage, err error) error {
+	var fields map[string]json.RawMessage
+	keys := []string{}
+	if json.Unmarshal(raw, &fields) == nil {
+		for key := range fields {
+			keys = append(keys, key)
+		}
+		slices.Sort(keys)
+	}
+
+	logger.WithRequestID(ctx, s.Logger).Error("unknown ingestion event dropped", "reason", "unknown_event", "keys", keys, "bytes", len(raw), "error", err)
+	return err
+}
@@ -1,61 +1,301 @@
 package ingestion
 
 import (
+	"cmp"
 	"context"
 	"errors"
 	"time"
 	"fmt"
 	"log/slog"
+	"slices"
+	"strings"
 
 	"github.com/Fleexa-Graduation-Project/Backend/internal/alerts"
+	"github.com/Fleexa-Graduation-Project/Backend/internal/commands"
+	"github.com/Fleexa-Graduation-Project/Backend/internal/deadman"
+	"github.com/Fleexa-Graduation-Project/Backend/internal/flags"
 	"github.com/Fleexa-Graduation-Project/Backend/internal/devices"
+	"github.com/Fleexa-Graduation-Project/Backend/internal/geofences"
+	"github.com/Fleexa-Graduation-Project/Backend/internal/ratelimit"
 	"github.com/Fleexa-Graduation-Project/Backend/internal/telemetry"
 	"github.com/Fleexa-Graduation-Project/Backend/internal/validation"
 	"github.com/Fleexa-Graduation-Project/Backend/models"
+	"github.com/Fleexa-Graduation-Project/Backend/internal/realtime"
 	"github.com/Fleexa-Graduation-Project/Backend/internal/rules"
+	"github.com/Fleexa-Graduation-Project/Backend/internal/shadows"
+	"github.com/Fleexa-Graduation-Project/Backend/pkg/db"
+	"github.com/Fleexa-Graduation-Project/Backend/pkg/logger"
+	"github.com/Fleexa-Graduation-Project/Backend/pkg/metrics"
+	"github.com/Fleexa-Graduation-Project/Backend/pkg/recovery"
+	"github.com/Fleexa-Graduation-Project/Backend/pkg/timeout"
+	"github.com/aws/aws-lambda-go/events"
+)
+
+// feature flags gating ingestion paths still rolling out, see internal/flags
+const (
+	// gas-sensor samples of gateway messages run the gas rule like a gas sensor's own telemetry
+	FlagGatewayGasAlerts = "gateway_gas_alerts"
 )
 
 type Service struct {
 	Logger         *slog.Logger
-	TelemetryStore *telemetry.TelemetryStore
+	TelemetryStore telemetry.Store
 	AlertStore     *alerts.AlertStore
 	StateStore     *devices.StateStore
 	Engine         *rules.AlertEngine
+	GeofenceStore  *geofences.GeofenceStore // optional, nil disables geofencing
+	BreachStore    *geofences.BreachStore   // optional, nil alerts on every reading outside a fence
+	Broadcaster    *realtime.Broadcaster    // optional, nil disables websocket streaming
+	DeviceStore    devices.Registry         // optional, nil disables firmware version tracking
+	DeviceCache    *devices.Cache           // optional, cached registry lookups for the deactivated check and archive partitions
+	RateLimiter    *ratelimit.Limiter       // optional, nil disables per-device rate limiting
+	Archive        *telemetry.Archive       // optional, nil disables s3 archival of raw readings
+	Resources      *rules.ResourceMonitor   // optional, nil disables low battery / fuel alerts
+	Shadows        *shadows.ShadowStore     // optional, nil leaves the reported state of shadows alone
+	LatestStore    *devices.LatestStore     // optional, nil disables the per fleet latest state
+	Heartbeat      *deadman.Heartbeat       // optional, nil disables the fleet wide dead-man heartbeat
+	CommandStore   *commands.CommandStore   // optional, nil drops command acks
+	Flags          *flags.Flags             // optional, nil turns every feature flag off
+
+	SeqResetThreshold int64         // 0 means devices.DefaultSeqResetThreshold
+	SignatureMode     SignatureMode // empty means SignatureOff, verifying needs DeviceCache
+	FailureAlertRate  float64       // failed share of a batch logged at error level, 0 means any failure
+
+	pending map[string][]models.Telemetry // readings stored in this batch, waiting for the archive
+	writes  *writeQueue                   // readings of this batch waiting for the telemetry write
 }
 
-func (s *Service) HandleRequest(ctx context.Context, event map[string]interface{}) (err error) {
+// HandleRequest processes an SQS batch (max 10 records), only the failed message ids are
+// reported back so lambda retries those and not the whole batch (needs ReportBatchItemFailures).
+// The readings of all records are stored with one batch write, a record fails when one of its
+// readings could not be stored
+func (s *Service) HandleRequest(ctx context.Context, event events.SQSEvent) (events.SQSEventResponse, error) {
 	start := time.Now()  //start when the rwuest enters the handler
+	log := logger.WithRequestID(ctx, s.Logger)
+
+	// stop before lambda kills us so the failures below still get reported
+	ctx, cancel := timeout.Budget(ctx)
+	defer cancel()
+
+	response := events.SQSEventResponse{BatchItemFailures: []events.SQSBatchItemFailure{}}
+
+	invocation := *s
+	invocation.Logger = log
+	invocation.writes = &writeQueue{}
+	if s.Archive != nil {
+		invocation.pending = map[string][]models.Telemetry{}
+	}
+
+	summary := batchSummary{records: len(event.Records)}
+	fail := func(messageID string, err error) {
+		err = timeout.Wrap(err)
+		summary.fail(err)
+		if errors.Is(err, timeout.ErrDependencyTimeout) {
+			log.Error("dependency call timed out", "reason", "dependency_timeout", "message_id", messageID, "error", err)
+		} else {
+			log.Error("failed to process sqs record", "message_id", messageID, "error", err)
+		}
+		response.BatchItemFailures = append(response.BatchItemFailures, events.SQSBatchItemFailure{
+			ItemIdentifier: messageID,
+		})
+	}
+
+	// the message id of every queued write
+	var owners []string
+	for _, record := range event.Records {
+		queued := invocation.writes.len()
+		if err := invocation.handleRecord(ctx, log.With("message_id", record.MessageId), record); err != nil {
+			invocation.writes.writes = invocation.writes.writes[:queued]
+			fail(record.MessageId, err)
+			continue
+		}
+		for range invocation.writes.len() - queued {
+			owners = append(owners, record.MessageId)
+		}
+	}
+
+	failedRecords := map[string]bool{}
+	for i, err := range invocation.flushWrites(ctx, invocation.writes.writes) {
+		if err != nil && !failedRecords[owners[i]] {
+			failedRecords[owners[i]] = true
+			fail(owners[i], err)
+		}
+	}
+
+	invocation.archivePending(ctx)
+	if summary.failed < summary.records {
+		invocation.beat(ctx)
+	}
+
+	summary.report(log, time.Since(start), s.FailureAlertRate)
+	return response, nil
+}
+
+// tells the dead-man checker the pipeline is alive, a failed write only costs a late beat
+func (s *Service) beat(ctx context.Context) {
+	if s.Heartbeat == nil {
+		return
+	}
+	if err := s.Heartbeat.Beat(ctx); err != nil {
+		s.Logger.Warn("failed to write ingestion heartbeat", "error", err)
+	}
+}
+
+// a panic in one record must not take the rest of the batch down with it
+func (s *Service) handleRecord(ctx context.Context, log *slog.Logger, record events.SQSMessage) (err error) {
 	defer func() {
 		if r := recover(); r != nil {
-			s.Logger.Error("CRITICAL: lambda panic recovered", "panic", r)
-			err = fmt.Errorf("internal server error!!")
+			err = recovery.Handle(logger.NewContext(ctx, log), "ingestion", r)
 		}
+	}()
 
-		duration := time.Since(start)
-		s.Logger.Info("lambda execution complete",
-			"execution_time", duration.Milliseconds(),
-			"execution_time", duration.String(),
-			"success", err == nil,
-		)
-	}() 
+	body, err := decodeBody(record)
+	if err != nil {
+		log.Warn("failed to decode message body", "reason", "validation_failed", "error", err)
+		return nil
+	}
+
+	event, err := parseEvent(body)
+	var malformed *MalformedJSONError
+	if errors.As(err, &malformed) {
+		// garbled in transit, not a device sending the wrong fields
+		log.Warn("malformed message json", "reason", "malformed_json", "offset", malformed.Offset, "truncated", malformed.Truncated,
+			"bytes", len(body), "prefix", payloadPrefix(body), "error", malformed.Err)
+		metrics.Count("MalformedJSON", 1, nil)
+		return nil
+	}
+	if err != nil {
+		log.Warn("invalid message envelope", "reason", "validation_failed", "error", err)
+		return nil
+	}
+	if event, err = decodePayload(event, stringAttribute(record, "Content-Type")); err != nil {
+		log.Warn("failed to decode message payload", "reason", "validation_failed", "encoding", event.Encoding, "error", err)
+		return nil
+	}
+
+	// copy of the service whose logger carries the request and message ids
+	invocation := *s
+	invocation.Logger = log
+
+	return invocation.handleMessage(ctx, event.message(), event.receivedAt(sentAt(record)))
+}
 
+// handles a single IoT rule message: {"topic": "devices/{id}/{type}", "payload": {...}}, received
+// is when it reached AWS
+func (s *Service) handleMessage(ctx context.Context, event map[string]interface{}, received time.Time) error {
 	//validating the message
-	deviceID, messageType, envelope, isBatch, err := validation.ValidateMessage(event)
+	deviceID, messageType, envelope, isBatch, err := validation.ValidateMessageAt(event, received)
 	if err != nil {
+		// invalid messages are dropped, retrying them would only store the same garbage
 		s.logValidationError(err, envelope.DeviceID)
+		metrics.Count("ValidationFailures", 1, s.metricDims(ctx, envelope.DeviceID))
+		return nil
+	}
+
+	ctx = logger.WithAttrs(ctx, "device_id", deviceID)
+
+	if ok, err := s.verified(ctx, deviceID, event); !ok {
 		return err
 	}
-	
+
+	if envelope.ClockSkew {
+		s.reportClockSkew(deviceID, envelope.DeviceTimestamp, envelope.Timestamp)
+	}
+
+	if messageType == "telemetry" && (s.deactivated(ctx, deviceID) || !s.allow(ctx, deviceID)) {
+		return nil
+	}
+	if (messageType == "heartbeat" || messageType == "ack") && s.deactivated(ctx, deviceID) {
+		return nil
+	}
+
+	queued := s.writes.len()
 	switch messageType {
 	case "telemetry":
-		return s.handleTelemetry(ctx, deviceID, envelope, isBatch)
+		err = s.handleTelemetry(ctx, deviceID, envelope, isBatch)
 
 	case "alerts":
-		return s.handleAlert(ctx, deviceID, envelope)
+		err = s.handleAlert(ctx, deviceID, envelope)
+
+	case "heartbeat":
+		err = s.handleHeartbeat(ctx, deviceID)
+
+	case "ack":
+		err = s.handleAck(ctx, deviceID, envelope)
 
 	default:
-		return fmt.Errorf("unknown message type: %s", messageType)
+		err = fmt.Errorf("unknown message type: %s", messageType)
+	}
+
+	// queued telemetry is counted once it is stored, see pendingWrite.finish
+	if err == nil && s.writes.len() == queued {
+		s.countProcessed(ctx, deviceID, messageType)
+	}
+	return err
+}
+
+func (s *Service) countProcessed(ctx context.Context, deviceID, messageType string) {
+	metrics.Count("MessagesProcessed", 1, s.metricDims(ctx, deviceID))
+	switch messageType {
+	case "telemetry":
+		metrics.Count("TelemetryProcessed", 1, s.metricDims(ctx, deviceID))
+	case "heartbeat":
+		metrics.Count("HeartbeatsProcessed", 1, s.metricDims(ctx, deviceID))
+	}
+}
+
+// telemetry from devices of an ended contract is dropped, a failed lookup lets the message through
+func (s *Service) deactivated(ctx context.Context, deviceID string) bool {
+	if s.DeviceCache == nil {
+		return false
+	}
+
+	device, err := s.DeviceCache.GetDevice(ctx, deviceID)
+	if err != nil {
+		s.Logger.Warn("failed to look up device status", "device_id", deviceID, "error", err)
+		return false
+	}
+	if devices.IsDeactivated(device) {
+		s.Logger.Info("telemetry from deactivated device dropped", "reason", "device_deactivated", "device_id", deviceID, "fleet_id", device.FleetID)
+		return true
+	}
+	return false
+}
+
+// telemetry over the device's limit is dropped, alerts are never limited
+func (s *Service) allow(ctx context.Context, deviceID string) bool {
+	if s.RateLimiter == nil {
+		return true
+	}
+
+	allowed, err := s.RateLimiter.Allow(ctx, deviceID)
+	if err != nil {
+		s.Logger.Warn("rate limit check failed, letting message through", "device_id", deviceID, "error", err)
+	}
+	if !allowed {
+		s.Logger.Warn("device over its rate limit, message dropped", "reason", "rate_limited", "device_id", deviceID)
+		metrics.Count("RateLimited", 1, s.metricDims(ctx, deviceID))
+	}
+	return allowed
+}
+
+// counted per device so the hardware with broken clocks can be found and fixed
+func (s *Service) reportClockSkew(deviceID string, deviceTimestamp, receivedAt int64) {
+	s.Logger.Warn("implausible device timestamp, stored under receive time", "reason", "clock_skew", "device_id", deviceID, "device_timestamp", deviceTimestamp, "received_at", receivedAt)
+	metrics.Count("ClockSkewReadings", 1, map[string]string{"DeviceId": deviceID})
+}
+
+// metrics are split by the device's fleet from the cached registry, devices it doesn't know (or
+// a missing registry) count under "unknown"
+func (s *Service) metricDims(ctx context.Context, deviceID string) map[string]string {
+	fleetID := "unknown"
+	if s.DeviceCache != nil && deviceID != "" {
+		deviceID, _, _ = strings.Cut(deviceID, "#")
+		if device, err := s.DeviceCache.GetDevice(ctx, deviceID); err == nil && device != nil && device.FleetID != "" {
+			fleetID = device.FleetID
+		}
 	}
+	return map[string]string{"FleetID": fleetID}
 }
 
 func (service *Service) handleTelemetry(ctx context.Context, deviceID string, envelope models.MQTTEnvelope, isBatch bool) error {
@@ -69,71 +309,416 @@ func (service *Service) handleTelemetry(ctx context.Context, deviceID string, en
 		service.Logger.Info("processing batch telemetry", "device_id", deviceID, "count", len(items))
 
 		var telemetryList []models.Telemetry
-		var latestReading models.Telemetry
+		receivedAt := envelope.ReceivedAt
 
-		for _, itemRaw := range items {
+		for index, itemRaw := range items {
 			itemMap, ok := itemRaw.(map[string]interface{})
 			if !ok {
-				service.Logger.Warn("skipping invalid item in batch", "device_id", deviceID)
+				service.Logger.Warn("skipping invalid item in batch", "device_id", deviceID, "index", index)
 				continue
 			}
 
 			//validating individual item structure
-			if err := validation.ValidatePayload(envelope.Type, itemMap); err != nil {
-				service.Logger.Warn("skipping malformed payload in batch", "error", err)
+			if err := validation.NormalizeUnits(itemMap); err != nil {
+				service.Logger.Warn("skipping item with unknown unit in batch", "reason", "validation_failed", "device_id", deviceID, "index", index, "error", err)
 				continue
 			}
-
-			timestamp := envelope.Timestamp
-			if itemTs, ok := itemMap["ts"].(float64); ok {
-				timestamp = int64(itemTs)
+			if err := validation.ValidatePayload(envelope.Type, itemMap); err != nil {
+				service.Logger.Warn("skipping malformed payload in batch", "reason", "validation_failed", "device_id", deviceID, "index", index, "error", err)
+				continue
 			}
 
 			t := models.Telemetry{
-				DeviceID:  deviceID,
-				Timestamp: timestamp,
-				Type:      envelope.Type,
-				Payload:   itemMap,
+				DeviceID:        deviceID,
+				Timestamp:       envelope.Timestamp,
+				Type:            envelope.Type,
+				Payload:         itemMap,
+				DeviceTimestamp: envelope.DeviceTimestamp,
+				ClockSkew:       envelope.ClockSkew,
+			}
+			if itemTs, ok := itemMap["ts"].(float64); ok {
+				if corrected, skewed := validation.CorrectClock(int64(itemTs), receivedAt); skewed {
+					t.Timestamp, t.DeviceTimestamp, t.ClockSkew = corrected, int64(itemTs), true
+					service.reportClockSkew(deviceID, int64(itemTs), corrected)
+				} else {
+					t.Timestamp, t.DeviceTimestamp, t.ClockSkew = corrected, 0, false
+				}
 			}
 
 			telemetryList = append(telemetryList, t)
+		}
 
-			//select the latest timestamp
-			if latestReading.Timestamp == 0 || t.Timestamp > latestReading.Timestamp {
-				latestReading = t
-			}
+		telemetryList, highestSeq, hasSeq := service.dropStaleItems(ctx, deviceID, telemetryList)
+		if len(telemetryList) == 0 {
+			return nil
 		}
 
-		if len(telemetryList) > 0 {
-			if err := service.TelemetryStore.SaveTelemetryBatch(ctx, telemetryList); err != nil {
-				service.Logger.Error("failed to save batch telemetry", "error", err)
-				return err
+		service.queueWrite(deviceID, telemetryList, func(ctx context.Context, stored []models.Telemetry) error {
+			if hasSeq {
+				service.recordSequence(ctx, deviceID, highestSeq)
+			}
+			if len(stored) == 0 {
+				return nil
 			}
 
+			//select the latest timestamp
+			latestReading := stored[0]
+			for _, t := range stored {
+				if t.Timestamp > latestReading.Timestamp {
+					latestReading = t
+				}
+			}
+
+			service.collect(stored...)
+			service.checkGeofences(ctx, deviceID, latestReading.Payload)
+			service.checkResources(ctx, deviceID, latestReading.Payload)
+			service.reportShadow(ctx, deviceID, latestReading.Payload)
+			service.updateLatest(ctx, latestReading)
+			service.trackFirmware(ctx, deviceID, latestReading.Payload)
+			service.broadcast(ctx, latestReading)
 			return service.StateStore.UpdateFromTelemetry(ctx, latestReading)
+		})
+		return nil
+	}
+
+	readings, err := validation.Readings(envelope, envelope.ReceivedAt)
+	var samplesErr *validation.SamplesError
+	if errors.As(err, &samplesErr) {
+		for _, element := range samplesErr.Elements {
+			service.Logger.Warn("skipping invalid sensor sample", "reason", "validation_failed", "device_id", deviceID, "index", element.Index, "error", element.Err)
 		}
+	} else if err != nil {
+		service.logValidationError(err, deviceID)
 		return nil
 	}
+	if validation.IsMultiSensor(envelope.Payload) {
+		return service.handleSensors(ctx, deviceID, envelope, readings)
+	}
+	data := readings[0]
 
-	data := models.Telemetry{
-		DeviceID:  envelope.DeviceID,
-		Timestamp: envelope.Timestamp,
-		Type:      envelope.Type,
-		Payload:   envelope.Payload,
+	if seq, ok := seqOf(data.Payload); ok && !service.advanceSequence(ctx, deviceID, seq).Accepted {
+		return nil
 	}
 
 	service.Logger.Info("saving single telemetry", "device_id", deviceID)
 
-	if envelope.Type == "gas-sensor" {
-		service.Engine.HandleGas(ctx, envelope.DeviceID, envelope.Payload)
+	service.queueWrite(deviceID, []models.Telemetry{data}, func(ctx context.Context, stored []models.Telemetry) error {
+		if len(stored) == 0 {
+			return nil
+		}
+
+		if envelope.Type == "gas-sensor" {
+			service.Engine.HandleGas(ctx, envelope.DeviceID, envelope.Payload)
+		}
+
+		service.collect(data)
+		service.checkGeofences(ctx, deviceID, data.Payload)
+		service.checkResources(ctx, deviceID, data.Payload)
+		service.reportShadow(ctx, deviceID, data.Payload)
+		service.updateLatest(ctx, data)
+		service.trackFirmware(ctx, deviceID, data.Payload)
+		service.broadcast(ctx, data)
+		return service.StateStore.UpdateFromTelemetry(ctx, data)
+	})
+	return nil
+}
+
+// a gateway message: the sensor samples go to the table with the rest of the batch, each under
+// its own device id, the gateway itself is tracked (state, position, shadow, firmware) on the
+// fields sent next to the samples. A redelivery, all of its samples already stored, is dropped
+func (service *Service) handleSensors(ctx context.Context, deviceID string, envelope models.MQTTEnvelope, readings []models.Telemetry) error {
+	service.Logger.Info("processing gateway telemetry", "device_id", deviceID, "samples", len(readings))
+
+	gateway := models.Telemetry{
+		DeviceID:        deviceID,
+		Timestamp:       envelope.Timestamp,
+		Type:            envelope.Type,
+		Payload:         map[string]interface{}{},
+		DeviceTimestamp: envelope.DeviceTimestamp,
+		ClockSkew:       envelope.ClockSkew,
+	}
+	for key, value := range envelope.Payload {
+		if key != "sensors" {
+			gateway.Payload[key] = value
+		}
+	}
+
+	service.queueWrite(deviceID, readings, func(ctx context.Context, stored []models.Telemetry) error {
+		if len(readings) > 0 && len(stored) == 0 {
+			return nil
+		}
+		service.collect(stored...)
+		service.gatewayGasAlerts(ctx, deviceID, stored)
+
+		service.checkGeofences(ctx, deviceID, gateway.Payload)
+		service.checkResources(ctx, deviceID, gateway.Payload)
+		service.reportShadow(ctx, deviceID, gateway.Payload)
+		service.updateLatest(ctx, gateway)
+		service.trackFirmware(ctx, deviceID, gateway.Payload)
+		service.broadcast(ctx, gateway)
+		return service.StateStore.UpdateFromTelemetry(ctx, gateway)
+	})
+	return nil
+}
+
+func (service *Service) gatewayGasAlerts(ctx context.Context, gatewayID string, readings []models.Telemetry) {
+	if !service.Flags.IsEnabled(ctx, FlagGatewayGasAlerts, service.fleetOf(ctx, gatewayID)) {
+		return
+	}
+	for _, reading := range readings {
+		if reading.Type == "gas-sensor" {
+			service.Engine.HandleGas(ctx, reading.DeviceID, reading.Payload)
+		}
+	}
+}
+
+func seqOf(payload map[string]interface{}) (int64, bool) {
+	seq, ok := payload["seq"].(float64)
+	return int64(seq), ok
+}
+
+func (service *Service) seqResetThreshold() int64 {
+	if service.SeqResetThreshold <= 0 {
+		return devices.DefaultSeqResetThreshold
+	}
+	return service.SeqResetThreshold
+}
+
+// moves the device's highest seen seq forward, a failed check lets the reading through
+// rather than dropping good data
+func (service *Service) advanceSequence(ctx context.Context, deviceID string, seq int64) devices.SequenceResult {
+	result, err := service.StateStore.AdvanceSequence(ctx, deviceID, seq, service.seqResetThreshold())
+	if err != nil {
+		service.Logger.Warn("sequence check failed, accepting reading", "device_id", deviceID, "seq", seq, "error", err)
+		return devices.SequenceResult{Accepted: true}
+	}
+
+	switch {
+	case !result.Accepted:
+		service.Logger.Warn("stale telemetry dropped", "reason", "stale_sequence", "device_id", deviceID, "seq", seq, "last_seq", result.LastSeq)
+	case result.Reset:
+		service.Logger.Info("device sequence reset", "device_id", deviceID, "seq", seq, "last_seq", result.LastSeq)
+	}
+	return result
+}
+
+// dropStaleItems drops the items of a batch behind the device's stored seq and returns the
+// batch's highest seq. The stored seq is only read here, recordSequence moves it once the items
+// are written, so a batch whose write fails is checked against the same seq when sqs redelivers
+// it. Items without a seq are always kept
+func (service *Service) dropStaleItems(ctx context.Context, deviceID string, items []models.Telemetry) ([]models.Telemetry, int64, bool) {
+	var highest int64
+	found := false
+	for _, item := range items {
+		if seq, ok := seqOf(item.Payload); ok && (!found || seq > highest) {
+			highest, found = seq, true
+		}
+	}
+	if !found {
+		return items, 0, false
+	}
+
+	last, err := service.StateStore.LastSequence(ctx, deviceID)
+	if err != nil {
+		service.Logger.Warn("sequence check failed, accepting batch", "device_id", deviceID, "seq", highest, "error", err)
+		return items, highest, true
+	}
+	if last == 0 || last > highest+service.seqResetThreshold() {
+		if last != 0 {
+			service.Logger.Info("device sequence reset", "device_id", deviceID, "seq", highest, "last_seq", last)
+		}
+		return items, highest, true
+	}
+
+	// an equal seq is kept like in AdvanceSequence, exact duplicates are caught by the dedup key
+	kept := items[:0]
+	for _, item := range items {
+		seq, ok := seqOf(item.Payload)
+		if ok && seq < last {
+			service.Logger.Warn("stale telemetry dropped", "reason", "stale_sequence", "device_id", deviceID, "seq", seq, "last_seq", last)
+			continue
+		}
+		kept = append(kept, item)
+	}
+	return kept, highest, true
+}
+
+// recordSequence stores the highest seq of a written batch. A batch of the device that got
+// further in the meantime fails the condition, which leaves its higher seq in place
+func (service *Service) recordSequence(ctx context.Context, deviceID string, seq int64) {
+	if _, err := service.StateStore.AdvanceSequence(ctx, deviceID, seq, service.seqResetThreshold()); err != nil {
+		service.Logger.Warn("failed to record batch sequence", "device_id", deviceID, "seq", seq, "error", err)
+	}
+}
+
+// queues stored readings for the archive, a no-op when archival is off
+func (service *Service) collect(readings ...models.Telemetry) {
+	if service.pending == nil {
+		return
+	}
+	for _, reading := range readings {
+		service.pending[reading.DeviceID] = append(service.pending[reading.DeviceID], reading)
+	}
+}
+
+// one object per device and day for the whole sqs batch. The readings are already in dynamodb,
+// so a failed write is logged and the batch still succeeds
+func (service *Service) archivePending(ctx context.Context) {
+	for deviceID, readings := range service.pending {
+		slices.SortFunc(readings, func(a, b models.Telemetry) int {
+			return cmp.Compare(a.Timestamp, b.Timestamp)
+		})
+
+		if err := service.Archive.Put(ctx, service.fleetOf(ctx, deviceID), deviceID, readings); err != nil {
+			service.Logger.Warn("failed to archive telemetry", "device_id", deviceID, "readings", len(readings), "error", err)
+		}
+	}
+}
+
+// archive partitions need the fleet, devices missing from the registry go under "unassigned".
+// Gateway sensor samples are in the fleet of their gateway
+func (service *Service) fleetOf(ctx context.Context, deviceID string) string {
+	if service.DeviceCache == nil {
+		return telemetry.UnassignedFleet
+	}
+	deviceID, _, _ = strings.Cut(deviceID, "#")
+
+	device, err := service.DeviceCache.GetDevice(ctx, deviceID)
+	if err != nil || device == nil || device.FleetID == "" {
+		return telemetry.UnassignedFleet
+	}
+	return device.FleetID
+}
+
+// devices include firmware_version in their readings, the registry keeps the last one for ota checks
+func (service *Service) trackFirmware(ctx context.Context, deviceID string, payload map[string]interface{}) {
+	version, ok := payload["firmware_version"].(string)
+	if service.DeviceStore == nil || !ok || version == "" {
+		return
+	}
+
+	if err := service.DeviceStore.UpdateFirmwareVersion(ctx, deviceID, version); err != nil {
+		service.Logger.Warn("failed to record firmware version", "device_id", deviceID, "firmware_version", version, "error", err)
+	}
+}
+
+// live updates are best effort, the reading is already stored so a failed push isn't retried
+func (service *Service) broadcast(ctx context.Context, data models.Telemetry) {
+	if service.Broadcaster == nil {
+		return
+	}
+
+	if err := service.Broadcaster.BroadcastTelemetry(logger.NewContext(ctx, service.Logger), data); err != nil {
+		service.Logger.Warn("failed to stream telemetry", "device_id", data.DeviceID, "error", err)
+	}
+}
+
+// geofencing is skipped when the store isn't configured or the reading has no gps fix
+func (service *Service) checkGeofences(ctx context.Context, deviceID string, payload map[string]interface{}) {
+	if service.GeofenceStore == nil {
+		return
+	}
+
+	position, ok := rules.GPSFromPayload(payload)
+	if !ok {
+		return
+	}
+
+	fences, err := service.GeofenceStore.GetGeofencesForDevice(ctx, deviceID)
+	if err != nil {
+		service.Logger.Warn("failed to load geofences", "device_id", deviceID, "error", err)
+		return
+	}
+
+	if service.BreachStore != nil {
+		fences = service.trackBreaches(ctx, deviceID, position, fences)
+	}
+	service.Engine.HandleGeofences(ctx, deviceID, position, fences)
+}
+
+// thresholds are per fleet, the registry lookup is only made for readings carrying the metrics
+func (service *Service) checkResources(ctx context.Context, deviceID string, payload map[string]interface{}) {
+	if service.Resources == nil {
+		return
+	}
+
+	for _, metric := range rules.ResourceMetrics {
+		if _, ok := payload[metric].(float64); ok {
+			service.Resources.HandleReading(ctx, deviceID, service.fleetOf(ctx, deviceID), payload)
+			return
+		}
 	}
+}
+
+// the reading is merged into the reported state of the device's shadow, bookkeeping fields aside.
+// A failure is only logged, the reading itself is stored
+func (service *Service) reportShadow(ctx context.Context, deviceID string, payload map[string]interface{}) {
+	if service.Shadows == nil {
+		return
+	}
+
+	reported := make(map[string]interface{}, len(payload))
+	for key, value := range payload {
+		if key != "seq" && key != "ts" {
+			reported[key] = value
+		}
+	}
+
+	if _, err := service.Shadows.UpdateReported(ctx, deviceID, reported); err != nil {
+		service.Logger.Warn("failed to update reported shadow state", "device_id", deviceID, "error", err)
+	}
+}
+
+// keeps the map view of the fleet current, a reading older than the stored one is skipped
+func (service *Service) updateLatest(ctx context.Context, data models.Telemetry) {
+	if service.LatestStore == nil {
+		return
+	}
+
+	if _, err := service.LatestStore.UpdateFromTelemetry(ctx, service.fleetOf(ctx, data.DeviceID), data); err != nil {
+		service.Logger.Warn("failed to update latest device state", "device_id", data.DeviceID, "error", err, "throttled", db.IsThrottled(err))
+	}
+}
 
-	if err := service.TelemetryStore.SaveTelemetry(ctx, data); err != nil {
-		service.Logger.Error("failed to save telemetry", "error", err)
+// heartbeats only refresh last_seen_at (which also clears the offline flag), nothing is stored
+func (service *Service) handleHeartbeat(ctx context.Context, deviceID string) error {
+	if err := service.StateStore.UpdateHeartbeat(ctx, deviceID); err != nil {
+		service.Logger.Error("failed to record heartbeat", "device_id", deviceID, "error", err, "throttled", db.IsThrottled(err))
 		return err
 	}
+	return nil
+}
 
-	return service.StateStore.UpdateFromTelemetry(ctx, data)
+// settles the pending command the device answered, and like a heartbeat marks the device seen.
+// Acks for unknown or already settled commands are dropped, a retry would not change them
+func (service *Service) handleAck(ctx context.Context, deviceID string, envelope models.MQTTEnvelope) error {
+	ack, err := validation.ParseAck(envelope.Payload)
+	if err != nil {
+		service.logValidationError(err, deviceID)
+		return nil
+	}
+
+	if service.CommandStore == nil {
+		service.Logger.Warn("command store not configured, ack dropped", "device_id", deviceID, "command_id", ack.CommandID)
+	} else {
+		status := commands.StatusAcked
+		if ack.Result == validation.AckFailed {
+			status = commands.StatusFailed
+		}
+		cmd, err := service.CommandStore.Acknowledge(ctx, ack.CommandID, deviceID, status, ack.Error, time.Now())
+		switch {
+		case errors.Is(err, commands.ErrCommandNotFound), errors.Is(err, commands.ErrNotPending):
+			service.Logger.Info("ack for unknown or settled command dropped", "reason", "ack_dropped", "device_id", deviceID, "command_id", ack.CommandID, "error", err)
+		case err != nil:
+			service.Logger.Error("failed to record command ack", "device_id", deviceID, "command_id", ack.CommandID, "error", err, "throttled", db.IsThrottled(err))
+			return err
+		default:
+			service.Logger.Info("command acknowledged", "device_id", deviceID, "command_id", cmd.RequestID, "action", cmd.Action, "status", cmd.Status, "round_trip_ms", cmd.RoundTripMs)
+			metrics.Timing("CommandRoundTrip", time.Duration(cmd.RoundTripMs)*time.Millisecond, map[string]string{"Status": cmd.Status})
+		}
+	}
+
+	return service.StateStore.UpdateHeartbeat(ctx, deviceID)
 }
 
 func (service *Service) handleAlert(ctx context.Context, deviceID string, envelope models.MQTTEnvelope) error {
@@ -157,14 +742,21 @@ func (service *Service) handleAlert(ctx context.Context, deviceID string, envelo
 }
 
 func (service *Service) logValidationError(err error, deviceID string) {
+	log := service.Logger.With("reason", "validation_failed")
+
+	var verr *validation.ValidationError
+	if errors.As(err, &verr) {
+		log = log.With("fields", verr.Fields)
+	}
+
 	switch {
 	case errors.Is(err, validation.ErrInvalidEvent), errors.Is(err, validation.ErrInvalidEnvelope):
-		service.Logger.Warn("invalid message envelope", "error", err)
+		log.Warn("invalid message envelope", "device_id", deviceID, "error", err)
 	case errors.Is(err, validation.ErrInvalidPayload):
-		service.Logger.Warn("invalid payload", "device_id", deviceID, "error", err)
+		log.Warn("invalid payload", "device_id", deviceID, "error", err)
 	case errors.Is(err, validation.ErrInvalidTopic):
-		service.Logger.Warn("invalid topic", "error", err)
+		log.Warn("invalid topic", "error", err)
 	default:
-		service.Logger.Error("unexpected validation error", "error", err)
+		log.Error("unexpected validation error", "error", err)
 	}
 }
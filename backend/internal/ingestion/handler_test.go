@@ -0,0 +1,37 @@
+package ingestion
+
+import (
+	"context"
+	"maps"
+	"testing"
+	"time"
+
+	"github.com/Fleexa-Graduation-Project/Backend/internal/devices"
+	"github.com/Fleexa-Graduation-Project/Backend/models"
+)
+
+func TestMetricDims(t *testing.T) {
+	registry := stubRegistry{device: &models.Device{DeviceID: "truck-1", FleetID: "fleet-a"}}
+
+	tests := []struct {
+		name     string
+		cache    *devices.Cache
+		deviceID string
+		want     map[string]string
+	}{
+		{name: "registered device", cache: devices.NewCache(registry, time.Minute), deviceID: "truck-1", want: map[string]string{"FleetID": "fleet-a"}},
+		{name: "gateway sensor uses its gateway", cache: devices.NewCache(registry, time.Minute), deviceID: "truck-1#gas-2", want: map[string]string{"FleetID": "fleet-a"}},
+		{name: "unregistered device", cache: devices.NewCache(registry, time.Minute), deviceID: "truck-9", want: map[string]string{"FleetID": "unknown"}},
+		{name: "no device id", cache: devices.NewCache(registry, time.Minute), want: map[string]string{"FleetID": "unknown"}},
+		{name: "no registry", deviceID: "truck-1", want: map[string]string{"FleetID": "unknown"}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			service := &Service{DeviceCache: test.cache}
+			if got := service.metricDims(context.Background(), test.deviceID); !maps.Equal(got, test.want) {
+				t.Errorf("metricDims() = %v, want %v", got, test.want)
+			}
+		})
+	}
+}
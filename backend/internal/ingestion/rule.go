@@ -0,0 +1,75 @@
+package ingestion
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"time"
+
+	"github.com/Fleexa-Graduation-Project/Backend/models"
+	"github.com/Fleexa-Graduation-Project/Backend/pkg/logger"
+	"github.com/Fleexa-Graduation-Project/Backend/pkg/timeout"
+)
+
+// RuleEvent is the output of the IoT rule `SELECT topic() AS topic, * AS payload`, the same
+// json arrives as an sqs body or, with a lambda rule action, as the invocation event itself
+type RuleEvent struct {
+	Topic   string          `json:"topic"`
+	Payload json.RawMessage `json:"payload"`
+	// "protobuf" when the rule forwards a binary payload as a base64 string, empty for json
+	Encoding string `json:"encoding,omitempty"`
+	// epoch milliseconds from the rule's `timestamp() AS received_at`, 0 when the rule doesn't select it
+	ReceivedAt int64 `json:"received_at,omitempty"`
+}
+
+// receivedAt is when the rule saw the message, or fallback from a rule without received_at
+func (event RuleEvent) receivedAt(fallback time.Time) time.Time {
+	if event.ReceivedAt > 0 {
+		return time.UnixMilli(event.ReceivedAt)
+	}
+	return fallback
+}
+
+// the shape validation.ValidateMessage takes, a missing payload stays missing
+func (event RuleEvent) message() map[string]interface{} {
+	message := map[string]interface{}{"topic": event.Topic}
+	if len(event.Payload) > 0 {
+		message["payload"] = event.Payload
+	}
+	return message
+}
+
+// HandleRuleEvent processes one message delivered straight from an IoT rule lambda action,
+// returning an error lets lambda's async retries redeliver it
+func (s *Service) HandleRuleEvent(ctx context.Context, event RuleEvent) error {
+	log := logger.WithRequestID(ctx, s.Logger)
+
+	ctx, cancel := timeout.Budget(ctx)
+	defer cancel()
+
+	invocation := *s
+	invocation.Logger = log
+	invocation.writes = &writeQueue{}
+	if s.Archive != nil {
+		invocation.pending = map[string][]models.Telemetry{}
+	}
+
+	event, err := decodePayload(event, "")
+	if err != nil {
+		log.Warn("failed to decode message payload", "reason", "validation_failed", "topic", event.Topic, "encoding", event.Encoding, "error", err)
+		return nil
+	}
+
+	err = invocation.handleMessage(ctx, event.message(), event.receivedAt(time.Now()))
+	if err == nil {
+		err = errors.Join(invocation.flushWrites(ctx, invocation.writes.writes)...)
+	}
+	err = timeout.Wrap(err)
+	invocation.archivePending(ctx)
+	if err != nil {
+		log.Error("failed to process iot rule event", "topic", event.Topic, "error", err)
+		return err
+	}
+	invocation.beat(ctx)
+	return nil
+}
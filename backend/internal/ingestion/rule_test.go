@@ -0,0 +1,157 @@
+package ingestion
+
+import (
+	"errors"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-lambda-go/events"
+
+	"github.com/Fleexa-Graduation-Project/Backend/internal/validation"
+)
+
+func TestParseEvent(t *testing.T) {
+	tests := []struct {
+		name          string
+		body          string
+		wantTopic     string
+		wantPayload   string
+		wantMalformed bool
+		wantTruncated bool
+		wantErr       bool
+	}{
+		{
+			name:        "rule output",
+			body:        `{"topic": "devices/truck-1/telemetry", "payload": {"device_id": "truck-1"}}`,
+			wantTopic:   "devices/truck-1/telemetry",
+			wantPayload: `{"device_id": "truck-1"}`,
+		},
+		{name: "no payload", body: `{"topic": "devices/truck-1/heartbeat"}`, wantTopic: "devices/truck-1/heartbeat"},
+		{name: "cut short", body: `{"topic": "devices/truck-1/telemetry", "payload": {"temp": 4`, wantMalformed: true, wantTruncated: true},
+		{name: "garbled", body: `{"topic": "devices/truck-1/telemetry", "payload": {"temp": 4x}}`, wantMalformed: true},
+		{name: "wrong shape", body: `{"topic": 42}`, wantErr: true},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			event, err := parseEvent([]byte(test.body))
+
+			var malformed *MalformedJSONError
+			if test.wantMalformed {
+				if !errors.As(err, &malformed) || !errors.Is(err, ErrMalformedJSON) {
+					t.Fatalf("parseEvent() error = %v, want a MalformedJSONError", err)
+				}
+				if malformed.Truncated != test.wantTruncated {
+					t.Errorf("Truncated = %v, want %v", malformed.Truncated, test.wantTruncated)
+				}
+				return
+			}
+			if test.wantErr {
+				if err == nil || errors.As(err, &malformed) {
+					t.Fatalf("parseEvent() error = %v, want a shape error that isn't malformed json", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("parseEvent() error = %v", err)
+			}
+			if event.Topic != test.wantTopic || string(event.Payload) != test.wantPayload {
+				t.Errorf("parseEvent() = %q %s, want %q %s", event.Topic, event.Payload, test.wantTopic, test.wantPayload)
+			}
+		})
+	}
+}
+
+func TestRuleEventMessage(t *testing.T) {
+	now := time.Now().Unix()
+	payload := `{"device_id": "truck-1", "timestamp": ` + strconv.FormatInt(now, 10) + `, "type": "temp-sensor", "payload": {"temp": 4.5}}`
+
+	tests := []struct {
+		name       string
+		event      RuleEvent
+		wantDevice string
+		wantType   string
+		wantErr    bool
+	}{
+		{
+			name:       "validates like the sqs body",
+			event:      RuleEvent{Topic: "devices/truck-1/telemetry", Payload: []byte(payload)},
+			wantDevice: "truck-1",
+			wantType:   "telemetry",
+		},
+		{name: "missing payload stays missing", event: RuleEvent{Topic: "devices/truck-1/telemetry"}, wantErr: true},
+		{name: "topic of another device", event: RuleEvent{Topic: "devices/truck-2/telemetry", Payload: []byte(payload)}, wantErr: true},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			message := test.event.message()
+			if _, ok := message["payload"]; ok != (len(test.event.Payload) > 0) {
+				t.Fatalf("message() payload present = %v, want %v", ok, len(test.event.Payload) > 0)
+			}
+
+			deviceID, messageType, _, _, err := validation.ValidateMessage(message)
+			if test.wantErr {
+				if err == nil {
+					t.Fatalf("ValidateMessage() = %s %s, want an error", deviceID, messageType)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("ValidateMessage() error = %v", err)
+			}
+			if deviceID != test.wantDevice || messageType != test.wantType {
+				t.Errorf("ValidateMessage() = %s %s, want %s %s", deviceID, messageType, test.wantDevice, test.wantType)
+			}
+		})
+	}
+}
+
+func TestReceivedAt(t *testing.T) {
+	sent := time.UnixMilli(1700000000123)
+	ruleTime := time.UnixMilli(1700000000045)
+	record := func(attributes map[string]string) events.SQSMessage {
+		return events.SQSMessage{Attributes: attributes}
+	}
+
+	tests := []struct {
+		name   string
+		event  RuleEvent
+		record events.SQSMessage
+		want   time.Time
+	}{
+		{name: "rule time", event: RuleEvent{ReceivedAt: ruleTime.UnixMilli()}, record: record(map[string]string{"SentTimestamp": "1700000000123"}), want: ruleTime},
+		{name: "sqs send time without it", record: record(map[string]string{"SentTimestamp": "1700000000123"}), want: sent},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if got := test.event.receivedAt(sentAt(test.record)); !got.Equal(test.want) {
+				t.Errorf("receivedAt() = %s, want %s", got, test.want)
+			}
+		})
+	}
+
+	t.Run("now without either", func(t *testing.T) {
+		before := time.Now()
+		if got := (RuleEvent{}).receivedAt(sentAt(record(nil))); got.Before(before) {
+			t.Errorf("receivedAt() = %s, want now", got)
+		}
+	})
+}
+
+// a skewed reading is stored under the rule time and not the time it is processed, so every
+// redelivery gets the same timestamp and the dedup key catches it
+func TestSkewedReadingUsesRuleTime(t *testing.T) {
+	payload := `{"device_id": "truck-1", "timestamp": 42, "type": "temp-sensor", "payload": {"temp": 4.5}}`
+	event := RuleEvent{Topic: "devices/truck-1/telemetry", Payload: []byte(payload), ReceivedAt: 1700000000123}
+
+	_, _, envelope, _, err := validation.ValidateMessageAt(event.message(), event.receivedAt(time.Now()))
+	if err != nil {
+		t.Fatalf("ValidateMessageAt() error = %v", err)
+	}
+	if !envelope.ClockSkew || envelope.DeviceTimestamp != 42 || envelope.Timestamp != 1700000000 {
+		t.Errorf("envelope = %+v, want a skewed reading stored under the rule time", envelope)
+	}
+}
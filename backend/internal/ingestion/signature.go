@@ -0,0 +1,141 @@
+package ingestion
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+
+	"github.com/Fleexa-Graduation-Project/Backend/pkg/metrics"
+)
+
+// SignatureMode is how ingestion treats messages whose signature doesn't verify
+type SignatureMode string
+
+const (
+	SignatureOff     SignatureMode = "off"     // no verification
+	SignatureAudit   SignatureMode = "audit"   // failures are logged and counted, the message is kept (rollout)
+	SignatureEnforce SignatureMode = "enforce" // failures are dropped
+)
+
+const signatureField = "signature"
+
+// LoadSignatureMode reads INGESTION_SIGNATURE_MODE, verification is off by default
+func LoadSignatureMode() (SignatureMode, error) {
+	switch mode := SignatureMode(os.Getenv("INGESTION_SIGNATURE_MODE")); mode {
+	case "":
+		return SignatureOff, nil
+	case SignatureOff, SignatureAudit, SignatureEnforce:
+		return mode, nil
+	default:
+		return "", fmt.Errorf("invalid INGESTION_SIGNATURE_MODE %q", mode)
+	}
+}
+
+// Sign is the hex HMAC-SHA256 a device puts in the envelope's "signature" field. It is computed
+// over the envelope without that field, encoded as compact json with sorted keys (what
+// encoding/json produces), the IoT rule re-serializes the payload so the raw bytes can't be signed
+func Sign(secret string, envelope map[string]interface{}) (string, error) {
+	unsigned := make(map[string]interface{}, len(envelope))
+	for key, value := range envelope {
+		if key != signatureField {
+			unsigned[key] = value
+		}
+	}
+
+	body, err := json.Marshal(unsigned)
+	if err != nil {
+		return "", fmt.Errorf("failed to encode envelope for signing: %w", err)
+	}
+
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(body)
+	return hex.EncodeToString(mac.Sum(nil)), nil
+}
+
+// verified checks the signature against the device's signing secret from the registry (cached
+// per container). Unsigned messages and devices without a secret fail like a mismatch, in audit
+// mode every failure is let through. A failed secret lookup says nothing about the message, in
+// enforce mode it is returned so the record is retried instead of dropped
+func (s *Service) verified(ctx context.Context, deviceID string, event map[string]interface{}) (bool, error) {
+	if s.SignatureMode == "" || s.SignatureMode == SignatureOff {
+		return true, nil
+	}
+
+	reason, err := s.checkSignature(ctx, deviceID, event)
+	if err != nil {
+		s.Logger.Warn("failed to look up signing secret", "device_id", deviceID, "mode", string(s.SignatureMode), "error", err)
+		if s.SignatureMode == SignatureAudit {
+			return true, nil
+		}
+		return false, err
+	}
+	if reason == "" {
+		return true, nil
+	}
+
+	s.Logger.Warn("message signature not verified", "reason", "signature_invalid", "detail", reason, "device_id", deviceID, "mode", string(s.SignatureMode))
+	metrics.Count("SignatureInvalid", 1, s.metricDims(ctx, deviceID))
+	return s.SignatureMode == SignatureAudit, nil
+}
+
+// returns why the signature failed, empty when it verified. The error is a failed registry
+// lookup, the signature could not be checked at all
+func (s *Service) checkSignature(ctx context.Context, deviceID string, event map[string]interface{}) (string, error) {
+	payload, ok := signedPayload(event["payload"])
+	if !ok {
+		return "payload is not an object", nil
+	}
+	signature, _ := payload[signatureField].(string)
+	if signature == "" {
+		return "unsigned", nil
+	}
+
+	if s.DeviceCache == nil {
+		return "no device registry", nil
+	}
+	device, err := s.DeviceCache.GetDevice(ctx, deviceID)
+	if err != nil {
+		return "", fmt.Errorf("failed to look up signing secret of %s: %w", deviceID, err)
+	}
+	if device == nil || device.SigningSecret == "" {
+		return "no signing secret", nil
+	}
+
+	expected, err := Sign(device.SigningSecret, payload)
+	if err != nil {
+		return err.Error(), nil
+	}
+	got, err := hex.DecodeString(signature)
+	if err != nil {
+		return "signature is not hex", nil
+	}
+	want, _ := hex.DecodeString(expected)
+	if !hmac.Equal(got, want) {
+		return "mismatch", nil
+	}
+	return "", nil
+}
+
+// the rule message carries the payload as raw json, numbers are kept as written so re-encoding
+// them for the HMAC gives the bytes the device signed
+func signedPayload(raw interface{}) (map[string]interface{}, bool) {
+	switch payload := raw.(type) {
+	case map[string]interface{}:
+		return payload, true
+	case json.RawMessage:
+		var decoded map[string]interface{}
+		decoder := json.NewDecoder(bytes.NewReader(payload))
+		decoder.UseNumber()
+		if err := decoder.Decode(&decoded); err != nil || decoded == nil {
+			return nil, false
+		}
+		return decoded, true
+	default:
+		return nil, false
+	}
+}
@@ -0,0 +1,172 @@
+package ingestion
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"log/slog"
+	"testing"
+	"time"
+
+	"github.com/Fleexa-Graduation-Project/Backend/internal/devices"
+	"github.com/Fleexa-Graduation-Project/Backend/models"
+)
+
+// registry serving one device, or failing every lookup with err. The other calls aren't used by
+// these tests
+type stubRegistry struct {
+	devices.Registry
+	device *models.Device
+	err    error
+}
+
+func (registry stubRegistry) GetDevice(ctx context.Context, deviceID string) (*models.Device, error) {
+	if registry.err != nil {
+		return nil, registry.err
+	}
+	if registry.device == nil || registry.device.DeviceID != deviceID {
+		return nil, nil
+	}
+	return registry.device, nil
+}
+
+func signedEvent(t *testing.T, secret string, envelope map[string]interface{}, tamper func(map[string]interface{})) map[string]interface{} {
+	t.Helper()
+	if secret != "" {
+		signature, err := Sign(secret, envelope)
+		if err != nil {
+			t.Fatalf("Sign: %v", err)
+		}
+		envelope[signatureField] = signature
+	}
+	if tamper != nil {
+		tamper(envelope)
+	}
+	payload, err := json.Marshal(envelope)
+	if err != nil {
+		t.Fatalf("encode payload: %v", err)
+	}
+	// the shape the sqs and lambda paths hand to handleMessage
+	return RuleEvent{Topic: "devices/truck-1/telemetry", Payload: payload}.message()
+}
+
+func TestVerified(t *testing.T) {
+	const secret = "s3cret"
+	envelope := func() map[string]interface{} {
+		return map[string]interface{}{
+			"device_id": "truck-1",
+			"type":      "gps",
+			"timestamp": 1700000000,
+			"payload":   map[string]interface{}{"lat": 30.0444, "lng": 31.2357, "speed": 62.5},
+		}
+	}
+
+	tests := []struct {
+		name   string
+		secret string
+		tamper func(map[string]interface{})
+		mode   SignatureMode
+		// the registry lookup fails
+		lookupErr error
+		want      bool
+		wantErr   bool
+	}{
+		{name: "valid signature enforced", secret: secret, mode: SignatureEnforce, want: true},
+		{name: "valid signature audited", secret: secret, mode: SignatureAudit, want: true},
+		{name: "wrong secret enforced", secret: "other", mode: SignatureEnforce, want: false},
+		{
+			name:   "tampered payload enforced",
+			secret: secret,
+			tamper: func(envelope map[string]interface{}) {
+				envelope["payload"].(map[string]interface{})["speed"] = 120
+			},
+			mode: SignatureEnforce,
+			want: false,
+		},
+		{
+			name:   "tampered payload audited",
+			secret: secret,
+			tamper: func(envelope map[string]interface{}) {
+				envelope["payload"].(map[string]interface{})["speed"] = 120
+			},
+			mode: SignatureAudit,
+			want: true,
+		},
+		{name: "missing signature enforced", mode: SignatureEnforce, want: false},
+		{name: "missing signature audited", mode: SignatureAudit, want: true},
+		{name: "verification off", mode: SignatureOff, want: true},
+		{name: "failed lookup enforced is retried", secret: secret, mode: SignatureEnforce, lookupErr: errors.New("throttled"), wantErr: true},
+		{name: "failed lookup audited", secret: secret, mode: SignatureAudit, lookupErr: errors.New("throttled"), want: true},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			service := &Service{
+				Logger:        slog.New(slog.NewTextHandler(io.Discard, nil)),
+				DeviceCache:   devices.NewCache(stubRegistry{device: &models.Device{DeviceID: "truck-1", SigningSecret: secret}, err: test.lookupErr}, time.Minute),
+				SignatureMode: test.mode,
+			}
+			event := signedEvent(t, test.secret, envelope(), test.tamper)
+
+			got, err := service.verified(context.Background(), "truck-1", event)
+			if (err != nil) != test.wantErr {
+				t.Fatalf("verified() error = %v, wantErr %v", err, test.wantErr)
+			}
+			if got != test.want {
+				reason, _ := service.checkSignature(context.Background(), "truck-1", event)
+				t.Errorf("verified() = %v, want %v (reason %q)", got, test.want, reason)
+			}
+		})
+	}
+}
+
+func TestCheckSignatureReasons(t *testing.T) {
+	const secret = "s3cret"
+	envelope := map[string]interface{}{"device_id": "truck-1", "type": "door", "payload": map[string]interface{}{"open": true}}
+
+	tests := []struct {
+		name   string
+		device *models.Device
+		event  map[string]interface{}
+		want   string
+	}{
+		{name: "verified", device: &models.Device{DeviceID: "truck-1", SigningSecret: secret}, event: signedEvent(t, secret, copyMap(envelope), nil), want: ""},
+		{name: "unsigned", device: &models.Device{DeviceID: "truck-1", SigningSecret: secret}, event: signedEvent(t, "", copyMap(envelope), nil), want: "unsigned"},
+		{name: "unregistered device", event: signedEvent(t, secret, copyMap(envelope), nil), want: "no signing secret"},
+		{name: "device without secret", device: &models.Device{DeviceID: "truck-1"}, event: signedEvent(t, secret, copyMap(envelope), nil), want: "no signing secret"},
+		{
+			name:   "signature is not hex",
+			device: &models.Device{DeviceID: "truck-1", SigningSecret: secret},
+			event:  signedEvent(t, "", copyMap(envelope), func(envelope map[string]interface{}) { envelope[signatureField] = "zz" }),
+			want:   "signature is not hex",
+		},
+		{
+			name:   "payload is not an object",
+			device: &models.Device{DeviceID: "truck-1", SigningSecret: secret},
+			event:  RuleEvent{Topic: "devices/truck-1/door", Payload: json.RawMessage(`[1,2]`)}.message(),
+			want:   "payload is not an object",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			service := &Service{
+				Logger:        slog.New(slog.NewTextHandler(io.Discard, nil)),
+				DeviceCache:   devices.NewCache(stubRegistry{device: test.device}, time.Minute),
+				SignatureMode: SignatureEnforce,
+			}
+			if got, err := service.checkSignature(context.Background(), "truck-1", test.event); err != nil || got != test.want {
+				t.Errorf("checkSignature() = %q, %v, want %q", got, err, test.want)
+			}
+		})
+	}
+}
+
+func copyMap(source map[string]interface{}) map[string]interface{} {
+	copied := make(map[string]interface{}, len(source))
+	for key, value := range source {
+		copied[key] = value
+	}
+	return copied
+}
@@ -0,0 +1,116 @@
+package ingestion
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/Fleexa-Graduation-Project/Backend/internal/telemetry"
+	"github.com/Fleexa-Graduation-Project/Backend/models"
+	"github.com/Fleexa-Graduation-Project/Backend/pkg/db"
+	"github.com/Fleexa-Graduation-Project/Backend/pkg/logger"
+	"github.com/Fleexa-Graduation-Project/Backend/pkg/recovery"
+)
+
+// writeQueue collects the readings of every record of an invocation, they are stored with one
+// SaveTelemetryBatch call and the records are finished afterwards
+type writeQueue struct {
+	writes []*pendingWrite
+}
+
+// the readings of one message and what to run once they are stored. then gets the readings that
+// were not duplicates, nothing at all when the message was a redelivery
+type pendingWrite struct {
+	service  *Service
+	deviceID string
+	readings []models.Telemetry
+	then     func(ctx context.Context, stored []models.Telemetry) error
+}
+
+func (queue *writeQueue) len() int {
+	if queue == nil {
+		return 0
+	}
+	return len(queue.writes)
+}
+
+// queueWrite holds the readings back for the invocation's batch write
+func (service *Service) queueWrite(deviceID string, readings []models.Telemetry, then func(ctx context.Context, stored []models.Telemetry) error) {
+	service.writes.writes = append(service.writes.writes, &pendingWrite{service: service, deviceID: deviceID, readings: readings, then: then})
+}
+
+// flushWrites stores the readings of writes in one batch and finishes each write, the returned
+// errors are per write. A write with a reading that could not be stored fails as a whole and is
+// not finished, the retry of its record writes it again and the dedup markers skip what is stored
+func (service *Service) flushWrites(ctx context.Context, writes []*pendingWrite) []error {
+	errs := make([]error, len(writes))
+	var all []models.Telemetry
+	for _, write := range writes {
+		all = append(all, write.readings...)
+	}
+	if len(all) == 0 {
+		for i, write := range writes {
+			errs[i] = write.finish(ctx, nil)
+		}
+		return errs
+	}
+
+	duplicates, err := service.TelemetryStore.SaveTelemetryBatch(ctx, all)
+	failed := map[int]bool{}
+	if err != nil {
+		var batchErr *telemetry.BatchError
+		if errors.As(err, &batchErr) {
+			for _, index := range batchErr.Failed {
+				failed[index] = true
+			}
+		} else {
+			for index := range all {
+				failed[index] = true
+			}
+		}
+		service.Logger.Error("failed to save telemetry", "error", err, "failed", len(failed), "readings", len(all), "throttled", db.IsThrottled(err))
+	}
+	duplicate := map[int]bool{}
+	for _, index := range duplicates {
+		duplicate[index] = true
+	}
+
+	offset := 0
+	for i, write := range writes {
+		var stored []models.Telemetry
+		for j, reading := range write.readings {
+			index := offset + j
+			switch {
+			case failed[index]:
+				errs[i] = fmt.Errorf("reading %d of %s not stored: %w", j, write.deviceID, err)
+			case duplicate[index]:
+				// sqs redelivered a reading we already have, ack it without running the rules again
+				write.service.Logger.Info("duplicate telemetry dropped", "reason", "duplicate_dropped", "device_id", reading.DeviceID, "dedup_key", telemetry.DedupKey(reading))
+			default:
+				stored = append(stored, reading)
+			}
+		}
+		offset += len(write.readings)
+
+		if errs[i] == nil {
+			errs[i] = write.finish(ctx, stored)
+		}
+	}
+	return errs
+}
+
+// runs then and counts the message, a panic fails only this write
+func (write *pendingWrite) finish(ctx context.Context, stored []models.Telemetry) (err error) {
+	log := write.service.Logger
+	defer func() {
+		if r := recover(); r != nil {
+			err = recovery.Handle(logger.NewContext(ctx, log), "ingestion", r)
+		}
+	}()
+
+	if err := write.then(ctx, stored); err != nil {
+		return err
+	}
+	write.service.countProcessed(ctx, write.deviceID, "telemetry")
+	return nil
+}
@@ -0,0 +1,103 @@
+package ingestion
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log/slog"
+	"slices"
+	"testing"
+
+	"github.com/Fleexa-Graduation-Project/Backend/internal/telemetry"
+	"github.com/Fleexa-Graduation-Project/Backend/models"
+)
+
+// mem store counting batch writes, the readings of failDevice are reported as failed
+type batchStore struct {
+	*telemetry.MemTelemetryStore
+	failDevice string
+	calls      int
+}
+
+func (store *batchStore) SaveTelemetryBatch(ctx context.Context, dataList []models.Telemetry) ([]int, error) {
+	store.calls++
+	var keep []models.Telemetry
+	var keepIndexes, failed []int
+	for i, data := range dataList {
+		if data.DeviceID == store.failDevice {
+			failed = append(failed, i)
+			continue
+		}
+		keep = append(keep, data)
+		keepIndexes = append(keepIndexes, i)
+	}
+
+	duplicates, err := store.MemTelemetryStore.SaveTelemetryBatch(ctx, keep)
+	for i, index := range duplicates {
+		duplicates[i] = keepIndexes[index]
+	}
+	if err == nil && len(failed) > 0 {
+		err = &telemetry.BatchError{Failed: failed, Err: errors.New("throttled")}
+	}
+	return duplicates, err
+}
+
+func reading(deviceID string, seq float64) models.Telemetry {
+	return models.Telemetry{DeviceID: deviceID, Timestamp: 1700000000 + int64(seq), Type: "temp-sensor", Payload: map[string]interface{}{"temp": 4.5, "seq": seq}}
+}
+
+func TestFlushWrites(t *testing.T) {
+	store := &batchStore{MemTelemetryStore: telemetry.NewMemTelemetryStore(), failDevice: "truck-3"}
+	if _, err := store.MemTelemetryStore.SaveTelemetryBatch(context.Background(), []models.Telemetry{reading("truck-2", 1)}); err != nil {
+		t.Fatalf("SaveTelemetryBatch: %v", err)
+	}
+	service := &Service{Logger: slog.New(slog.NewTextHandler(io.Discard, nil)), TelemetryStore: store}
+
+	finished := map[string][]float64{}
+	then := func(deviceID string) func(context.Context, []models.Telemetry) error {
+		return func(ctx context.Context, stored []models.Telemetry) error {
+			finished[deviceID] = []float64{}
+			for _, data := range stored {
+				finished[deviceID] = append(finished[deviceID], data.Payload["seq"].(float64))
+			}
+			if deviceID == "truck-4" {
+				panic("rule blew up")
+			}
+			return nil
+		}
+	}
+
+	service.writes = &writeQueue{}
+	service.queueWrite("truck-1", []models.Telemetry{reading("truck-1", 1), reading("truck-1", 2)}, then("truck-1"))
+	service.queueWrite("truck-2", []models.Telemetry{reading("truck-2", 1)}, then("truck-2"))
+	service.queueWrite("truck-3", []models.Telemetry{reading("truck-3", 1)}, then("truck-3"))
+	service.queueWrite("truck-4", []models.Telemetry{reading("truck-4", 1)}, then("truck-4"))
+	errs := service.flushWrites(context.Background(), service.writes.writes)
+
+	if store.calls != 1 {
+		t.Errorf("SaveTelemetryBatch calls = %d, want one for the whole batch", store.calls)
+	}
+	tests := []struct {
+		name         string
+		deviceID     string
+		index        int
+		wantErr      bool
+		wantFinished []float64 // nil when then must not run
+	}{
+		{name: "stored", deviceID: "truck-1", index: 0, wantFinished: []float64{1, 2}},
+		{name: "redelivery finishes without readings", deviceID: "truck-2", index: 1, wantFinished: []float64{}},
+		{name: "failed reading fails its write", deviceID: "truck-3", index: 2, wantErr: true},
+		{name: "panic fails only its write", deviceID: "truck-4", index: 3, wantErr: true, wantFinished: []float64{1}},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if (errs[test.index] != nil) != test.wantErr {
+				t.Errorf("error = %v, wantErr %v", errs[test.index], test.wantErr)
+			}
+			got, ran := finished[test.deviceID]
+			if ran != (test.wantFinished != nil) || !slices.Equal(got, test.wantFinished) {
+				t.Errorf("then ran = %v with %v, want %v", ran, got, test.wantFinished)
+			}
+		})
+	}
+}
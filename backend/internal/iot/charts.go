@@ -0,0 +1,66 @@
+package iot
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+
+	"github.com/Fleexa-Graduation-Project/Backend/pkg/timeout"
+)
+
+// charts are a few KB of points, anything bigger is not one of ours
+const maxChartBytes = 1 << 20
+
+// S3Client reads the monthly charts the processing job writes to CHARTS_BUCKET
+type S3Client struct {
+	Client *s3.Client
+	Bucket string
+}
+
+func NewS3Client(client *s3.Client) (*S3Client, error) {
+	bucket := os.Getenv("CHARTS_BUCKET")
+	if bucket == "" {
+		return nil, fmt.Errorf("CHARTS_BUCKET environment variable is not set")
+	}
+
+	return &S3Client{
+		Client: client,
+		Bucket: bucket,
+	}, nil
+}
+
+// GetMonthlyChart returns the chart json stored under key as it is. A nil client returns an
+// error, the caller answers with an empty chart
+func (client *S3Client) GetMonthlyChart(ctx context.Context, key string) (json.RawMessage, error) {
+	if client == nil {
+		return nil, fmt.Errorf("charts bucket is not configured")
+	}
+
+	callCtx, cancel := timeout.Call(ctx)
+	defer cancel()
+	output, err := client.Client.GetObject(callCtx, &s3.GetObjectInput{
+		Bucket: aws.String(client.Bucket),
+		Key:    aws.String(key),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to get chart %s: %w", key, err)
+	}
+	defer output.Body.Close()
+
+	body, err := io.ReadAll(io.LimitReader(output.Body, maxChartBytes+1))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read chart %s: %w", key, err)
+	}
+	if len(body) > maxChartBytes {
+		return nil, fmt.Errorf("chart %s is larger than %d bytes", key, maxChartBytes)
+	}
+	if !json.Valid(body) {
+		return nil, fmt.Errorf("chart %s is not valid json", key)
+	}
+	return body, nil
+}
@@ -7,15 +7,17 @@ import (
 
 	"github.com/aws/aws-sdk-go-v2/aws"
 	"github.com/aws/aws-sdk-go-v2/service/iotdataplane"
+
+	"github.com/Fleexa-Graduation-Project/Backend/pkg/timeout"
 )
 
 type Publisher struct {
 	Client *iotdataplane.Client
 }
 
-func NewPublisher(cfg aws.Config) *Publisher {
+func NewPublisher(client *iotdataplane.Client) *Publisher {
 	return &Publisher{
-		Client: iotdataplane.NewFromConfig(cfg),
+		Client: client,
 	}
 }
 
@@ -25,7 +27,9 @@ func (publisher *Publisher) Publish(ctx context.Context, topic string, payload i
 		return fmt.Errorf("failed to marshal payload for IoT: %w", err)
 	}
 
-	_, err = publisher.Client.Publish(ctx, &iotdataplane.PublishInput{
+	callCtx, cancel := timeout.Call(ctx)
+	defer cancel()
+	_, err = publisher.Client.Publish(callCtx, &iotdataplane.PublishInput{
 		Topic:   aws.String(topic),
 		Payload: payloadData,
 		Qos:     1, 
@@ -0,0 +1,130 @@
+package maintenance
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/attributevalue"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+
+	appconfig "github.com/Fleexa-Graduation-Project/Backend/pkg/config"
+	"github.com/Fleexa-Graduation-Project/Backend/pkg/db"
+	"github.com/Fleexa-Graduation-Project/Backend/pkg/timeout"
+)
+
+const (
+	controlKey        = "maintenance"
+	DefaultRetryAfter = 5 * time.Minute
+	// the control item is re-read at most this often per container, flipping it takes effect within it
+	DefaultRecheck = 15 * time.Second
+)
+
+// control item in DYNAMODB_CONTROL_TABLE, keyed control_key = "maintenance"
+type controlItem struct {
+	Enabled           bool  `dynamodbav:"enabled"`
+	RetryAfterSeconds int64 `dynamodbav:"retry_after_seconds"`
+}
+
+// Switch tells whether writes are paused. MAINTENANCE_MODE=true pauses them for the lifetime of the
+// deployment, the control item can be flipped live
+type Switch struct {
+	Client     *dynamodb.Client // nil when there is no control table
+	TableName  string
+	Static     bool
+	RetryAfter time.Duration
+	Recheck    time.Duration
+
+	mu        sync.Mutex
+	enabled   bool
+	checkedAt time.Time
+}
+
+func NewSwitch() (*Switch, error) {
+	sw := &Switch{
+		Static:     os.Getenv("MAINTENANCE_MODE") == "true",
+		RetryAfter: DefaultRetryAfter,
+		Recheck:    DefaultRecheck,
+	}
+
+	if tableName := appconfig.TableName("DYNAMODB_CONTROL_TABLE"); tableName != "" {
+		if db.Client == nil {
+			return nil, fmt.Errorf("dynamodb client is not initialized")
+		}
+		sw.Client = db.Client
+		sw.TableName = tableName
+	}
+
+	if sw.Static {
+		slog.Warn("maintenance mode entered", "source", "MAINTENANCE_MODE")
+	}
+	return sw, nil
+}
+
+// Enabled returns whether writes are paused and how long clients should wait. A control item
+// that can't be read keeps the last known state
+func (sw *Switch) Enabled(ctx context.Context) (bool, time.Duration) {
+	if sw.Static || sw.Client == nil {
+		return sw.Static, sw.RetryAfter
+	}
+
+	sw.mu.Lock()
+	defer sw.mu.Unlock()
+	if time.Since(sw.checkedAt) < sw.Recheck {
+		return sw.enabled, sw.RetryAfter
+	}
+
+	item, err := sw.read(ctx)
+	sw.checkedAt = time.Now()
+	if err != nil {
+		slog.Warn("failed to read maintenance control item, keeping last state", "enabled", sw.enabled, "error", err)
+		return sw.enabled, sw.RetryAfter
+	}
+
+	if item.Enabled != sw.enabled {
+		if item.Enabled {
+			slog.Warn("maintenance mode entered", "source", "control_item")
+		} else {
+			slog.Warn("maintenance mode exited", "source", "control_item")
+		}
+	}
+	sw.enabled = item.Enabled
+	if item.RetryAfterSeconds > 0 {
+		sw.RetryAfter = time.Duration(item.RetryAfterSeconds) * time.Second
+	}
+	return sw.enabled, sw.RetryAfter
+}
+
+func (sw *Switch) read(ctx context.Context) (controlItem, error) {
+	callCtx, cancel := timeout.Call(ctx)
+	defer cancel()
+	result, err := sw.Client.GetItem(callCtx, &dynamodb.GetItemInput{
+		TableName: aws.String(sw.TableName),
+		Key: map[string]types.AttributeValue{
+			"control_key": &types.AttributeValueMemberS{Value: controlKey},
+		},
+	})
+	if err != nil {
+		return controlItem{}, fmt.Errorf("failed to get control item %s: %w", controlKey, err)
+	}
+
+	var item controlItem
+	if result.Item == nil {
+		return item, nil // no item, not in maintenance
+	}
+	if err = attributevalue.UnmarshalMap(result.Item, &item); err != nil {
+		return controlItem{}, fmt.Errorf("failed to unmarshal control item %s: %w", controlKey, err)
+	}
+	return item, nil
+}
+
+// RetryAfterHeader is the Retry-After value in whole seconds
+func RetryAfterHeader(retryAfter time.Duration) string {
+	return strconv.Itoa(int(retryAfter.Seconds()))
+}
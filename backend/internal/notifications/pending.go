@@ -0,0 +1,80 @@
+package notifications
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/attributevalue"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/google/uuid"
+
+	appconfig "github.com/Fleexa-Graduation-Project/Backend/pkg/config"
+	"github.com/Fleexa-Graduation-Project/Backend/pkg/db"
+	"github.com/Fleexa-Graduation-Project/Backend/pkg/timeout"
+)
+
+const pendingTTL = 7 * 24 * time.Hour // an alert nobody redelivered in a week is stale
+
+// PendingAlert is a push that could not be delivered, kept for a later redelivery
+type PendingAlert struct {
+	AlertID   string `json:"alert_id" dynamodbav:"alert_id"`
+	DeviceID  string `json:"device_id" dynamodbav:"device_id"`
+	Title     string `json:"title" dynamodbav:"title"`
+	Body      string `json:"body" dynamodbav:"body"`
+	LastError string `json:"last_error" dynamodbav:"last_error"`
+	CreatedAt int64  `json:"created_at" dynamodbav:"created_at"`
+	ExpiresAt int64  `json:"expires_at" dynamodbav:"expires_at"`
+}
+
+type PendingStore struct {
+	Client    *dynamodb.Client
+	TableName string
+}
+
+func NewPendingStore() (*PendingStore, error) {
+	tableName := appconfig.TableName("DYNAMODB_PENDING_ALERTS_TABLE")
+	if tableName == "" {
+		return nil, fmt.Errorf("DYNAMODB_PENDING_ALERTS_TABLE environment variable is not set")
+	}
+
+	if db.Client == nil {
+		return nil, fmt.Errorf("dynamodb client is not initialized")
+	}
+
+	return &PendingStore{
+		Client:    db.Client,
+		TableName: tableName,
+	}, nil
+}
+
+func (store *PendingStore) SavePending(ctx context.Context, alert PendingAlert) error {
+	if alert.AlertID == "" {
+		alert.AlertID = uuid.NewString()
+	}
+	now := time.Now()
+	if alert.CreatedAt == 0 {
+		alert.CreatedAt = now.Unix()
+	}
+	if alert.ExpiresAt == 0 {
+		alert.ExpiresAt = now.Add(pendingTTL).Unix()
+	}
+
+	item, err := attributevalue.MarshalMap(alert)
+	if err != nil {
+		return fmt.Errorf("failed to marshal pending alert: %w", err)
+	}
+
+	callCtx, cancel := timeout.Call(ctx)
+	defer cancel()
+	_, err = store.Client.PutItem(callCtx, &dynamodb.PutItemInput{
+		TableName: aws.String(store.TableName),
+		Item:      item,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to store pending alert for device %s: %w", alert.DeviceID, err)
+	}
+
+	return nil
+}
@@ -4,15 +4,26 @@ import (
 	"context"
 	"fmt"
 	"log/slog"
+	"time"
 
 	firebase "firebase.google.com/go/v4"
 	"firebase.google.com/go/v4/messaging"
 	"google.golang.org/api/option"
+
+	"github.com/Fleexa-Graduation-Project/Backend/pkg/breaker"
+	"github.com/Fleexa-Graduation-Project/Backend/pkg/metrics"
+	"github.com/Fleexa-Graduation-Project/Backend/pkg/timeout"
+)
+
+const (
+	publishAttempts = 3 // first try plus two retries
+	publishBackoff  = 100 * time.Millisecond
 )
 
 //handles pushing messages via FCM
 type Service struct {
 	fcmClient *messaging.Client
+	Pending   *PendingStore // optional, undeliverable alerts are parked here for redelivery
 }
 
 func NewService(credentialsFile string) (*Service, error) {
@@ -32,25 +43,82 @@ func NewService(credentialsFile string) (*Service, error) {
 	}, nil
 }
 
+// errors worth another attempt, anything else (bad topic, credentials) fails the same way again
+func isTransient(err error) bool {
+	return timeout.IsTimeout(err) ||
+		messaging.IsUnavailable(err) ||
+		messaging.IsInternal(err) ||
+		messaging.IsMessageRateExceeded(err) ||
+		messaging.IsQuotaExceeded(err)
+}
+
 //sending a message to a specific device topic
-func (s *Service) SendPushNotification(deviceID string, title string, body string) {
+func (s *Service) SendPushNotification(ctx context.Context, deviceID string, title string, body string) {
+	if err := s.PublishAlert(ctx, deviceID, title, body); err != nil {
+		slog.Error("Failed to send push notification", "error", err, "device_id", deviceID)
+	}
+}
+
+// PublishAlert sends the push, retrying transient failures. When it still fails the alert is
+// parked in the pending table and only an error saving it is returned, a broken push channel
+// must not fail the ingestion record that raised the alert
+func (s *Service) PublishAlert(ctx context.Context, deviceID string, title string, body string) error {
 	// For now, we will send to an FCM topic based on the device ID.
 	// The Flutter app will subscribe to this topic (e.g., "door-actuator-01") to receive alerts.
-	topic := deviceID
-
 	message := &messaging.Message{
 		Notification: &messaging.Notification{
 			Title: title,
 			Body:  body,
 		},
-		Topic: topic,
+		Topic: deviceID,
 	}
 
-	response, err := s.fcmClient.Send(context.Background(), message)
-	if err != nil {
-		slog.Error("Failed to send push notification", "error", err, "device_id", deviceID)
-		return
+	var err error
+	for attempt := 1; ; attempt++ {
+		var response string
+		if response, err = s.send(ctx, message); err == nil {
+			slog.Info("Successfully sent push notification", "response", response, "device_id", deviceID)
+			return nil
+		}
+		if attempt == publishAttempts || !isTransient(err) || !wait(ctx, time.Duration(attempt)*publishBackoff) {
+			break
+		}
 	}
 
-	slog.Info("Successfully sent push notification", "response", response, "device_id", deviceID)
-}
\ No newline at end of file
+	metrics.Count("AlertPublishFailures", 1, map[string]string{"Channel": "fcm"})
+	if timeout.IsTimeout(err) {
+		slog.Error("Push notification timed out", "reason", "dependency_timeout", "error", err, "device_id", deviceID)
+	} else {
+		slog.Error("Push notification failed", "error", err, "device_id", deviceID)
+	}
+
+	if s.Pending == nil {
+		return nil
+	}
+	return s.Pending.SavePending(ctx, PendingAlert{DeviceID: deviceID, Title: title, Body: body, LastError: err.Error()})
+}
+
+// false when the context ends first
+func wait(ctx context.Context, delay time.Duration) bool {
+	select {
+	case <-ctx.Done():
+		return false
+	case <-time.After(delay):
+		return true
+	}
+}
+
+// behind the "fcm" breaker, while fcm is down the alerts go straight to the pending table
+// instead of each waiting out its retries
+func (s *Service) send(ctx context.Context, message *messaging.Message) (string, error) {
+	fcm := breaker.For("fcm")
+	if err := fcm.Allow(); err != nil {
+		return "", err
+	}
+
+	callCtx, cancel := timeout.Call(ctx)
+	defer cancel()
+	response, err := s.fcmClient.Send(callCtx, message)
+	fcm.Record(err != nil && isTransient(err))
+	return response, err
+}
@@ -0,0 +1,91 @@
+package ota
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+
+	"github.com/Fleexa-Graduation-Project/Backend/models"
+	"github.com/Fleexa-Graduation-Project/Backend/pkg/semver"
+)
+
+const (
+	StatusUpToDate        = "up_to_date"
+	StatusUpdateAvailable = "update_available"
+	StatusUnknown         = "unknown"   // the device reported no or an unparseable version
+	StatusNoTarget        = "no_target" // no firmware configured for the model
+)
+
+// firmware every device of a model should run
+type Target struct {
+	Version string `json:"version"`
+	URL     string `json:"url"`
+}
+
+// Targets by device model
+type Targets map[string]Target
+
+// OTA_TARGETS='{"temp-sensor":{"version":"1.10.0","url":"https://..."}}', unset means no targets
+func LoadTargets() (Targets, error) {
+	targets := Targets{}
+
+	raw := os.Getenv("OTA_TARGETS")
+	if raw == "" {
+		return targets, nil
+	}
+	if err := json.Unmarshal([]byte(raw), &targets); err != nil {
+		return nil, fmt.Errorf("invalid OTA_TARGETS: %w", err)
+	}
+
+	for model, target := range targets {
+		if _, err := semver.Parse(target.Version); err != nil {
+			return nil, fmt.Errorf("invalid OTA_TARGETS version for %s: %w", model, err)
+		}
+	}
+
+	return targets, nil
+}
+
+type Eligibility struct {
+	DeviceID        string `json:"device_id"`
+	Model           string `json:"model"`
+	CurrentVersion  string `json:"current_version"`
+	TargetVersion   string `json:"target_version,omitempty"`
+	UpdateAvailable bool   `json:"update_available"`
+	DownloadURL     string `json:"download_url,omitempty"`
+	Status          string `json:"status"`
+}
+
+// Check compares the device's last reported firmware with its model's target
+func (targets Targets) Check(device models.Device) Eligibility {
+	result := Eligibility{
+		DeviceID:       device.DeviceID,
+		Model:          device.Model,
+		CurrentVersion: device.FirmwareVersion,
+	}
+
+	target, ok := targets[device.Model]
+	if !ok {
+		result.Status = StatusNoTarget
+		return result
+	}
+	result.TargetVersion = target.Version
+
+	current, err := semver.Parse(device.FirmwareVersion)
+	if err != nil {
+		result.Status = StatusUnknown
+		return result
+	}
+
+	// validated in LoadTargets
+	wanted, _ := semver.Parse(target.Version)
+	if semver.Compare(current, wanted) < 0 {
+		result.UpdateAvailable = true
+		result.DownloadURL = target.URL
+		result.Status = StatusUpdateAvailable
+		return result
+	}
+
+	result.Status = StatusUpToDate
+	return result
+}
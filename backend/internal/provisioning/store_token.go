@@ -0,0 +1,186 @@
+package provisioning
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/attributevalue"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+
+	appconfig "github.com/Fleexa-Graduation-Project/Backend/pkg/config"
+	"github.com/Fleexa-Graduation-Project/Backend/pkg/db"
+	"github.com/Fleexa-Graduation-Project/Backend/pkg/timeout"
+)
+
+const (
+	DefaultTokenTTL = 15 * time.Minute
+	MaxTokenTTL     = 24 * time.Hour
+)
+
+var (
+	ErrTokenUnknown = errors.New("unknown provisioning token")
+	ErrTokenExpired = errors.New("provisioning token expired")
+	ErrTokenUsed    = errors.New("provisioning token already used")
+)
+
+// a single-use token a device trades for its registration. Only the sha256 of the token is
+// stored, a leaked table doesn't leak claimable tokens. ExpiresAt is the table's ttl attribute,
+// dynamodb deletes expired tokens within a few days so the claim checks the time itself
+type Token struct {
+	TokenHash string `json:"-" dynamodbav:"token_hash"`
+	FleetID   string `json:"fleet_id" dynamodbav:"fleet_id"`
+	CreatedBy string `json:"created_by,omitempty" dynamodbav:"created_by,omitempty"`
+	CreatedAt int64  `json:"created_at" dynamodbav:"created_at"`
+	ExpiresAt int64  `json:"expires_at" dynamodbav:"expires_at"`
+	UsedAt    int64  `json:"used_at,omitempty" dynamodbav:"used_at,omitempty"`
+	DeviceID  string `json:"device_id,omitempty" dynamodbav:"device_id,omitempty"` // the device that claimed it
+}
+
+type TokenStore struct {
+	Client    *dynamodb.Client
+	TableName string
+}
+
+func NewTokenStore() (*TokenStore, error) {
+	tableName := appconfig.TableName("DYNAMODB_PROVISIONING_TOKENS_TABLE")
+	if tableName == "" {
+		return nil, fmt.Errorf("DYNAMODB_PROVISIONING_TOKENS_TABLE environment variable is not set")
+	}
+
+	if db.Client == nil {
+		return nil, fmt.Errorf("dynamodb client is not initialized")
+	}
+
+	return &TokenStore{
+		Client:    db.Client,
+		TableName: tableName,
+	}, nil
+}
+
+func hashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// CreateToken mints a token for fleetID valid for ttl. The plain token is only returned here
+func (store *TokenStore) CreateToken(ctx context.Context, fleetID, createdBy string, ttl time.Duration) (string, Token, error) {
+	raw := make([]byte, 32)
+	if _, err := rand.Read(raw); err != nil {
+		return "", Token{}, fmt.Errorf("failed to generate provisioning token: %w", err)
+	}
+	plain := hex.EncodeToString(raw)
+
+	now := time.Now()
+	token := Token{
+		TokenHash: hashToken(plain),
+		FleetID:   fleetID,
+		CreatedBy: createdBy,
+		CreatedAt: now.Unix(),
+		ExpiresAt: now.Add(ttl).Unix(),
+	}
+
+	item, err := attributevalue.MarshalMap(token)
+	if err != nil {
+		return "", Token{}, fmt.Errorf("failed to marshal provisioning token: %w", err)
+	}
+
+	callCtx, cancel := timeout.Call(ctx)
+	defer cancel()
+	_, err = store.Client.PutItem(callCtx, &dynamodb.PutItemInput{
+		TableName:           aws.String(store.TableName),
+		Item:                item,
+		ConditionExpression: aws.String("attribute_not_exists(token_hash)"),
+	})
+	if err != nil {
+		return "", Token{}, fmt.Errorf("failed to save provisioning token: %w", err)
+	}
+	return plain, token, nil
+}
+
+// Consume marks the token used by deviceID and returns it. The write is conditional on the
+// token being unused and unexpired, so of two concurrent claims only one succeeds. A failed
+// condition returns the old item, which tells unknown, expired and used apart in one call
+func (store *TokenStore) Consume(ctx context.Context, plain, deviceID string) (*Token, error) {
+	now := time.Now().Unix()
+	input := &dynamodb.UpdateItemInput{
+		TableName: aws.String(store.TableName),
+		Key: map[string]types.AttributeValue{
+			"token_hash": &types.AttributeValueMemberS{Value: hashToken(plain)},
+		},
+		UpdateExpression:    aws.String("SET used_at = :now, device_id = :device"),
+		ConditionExpression: aws.String("attribute_exists(token_hash) AND attribute_not_exists(used_at) AND expires_at > :now"),
+		ExpressionAttributeValues: map[string]types.AttributeValue{
+			":now":    &types.AttributeValueMemberN{Value: strconv.FormatInt(now, 10)},
+			":device": &types.AttributeValueMemberS{Value: deviceID},
+		},
+		ReturnValues:                        types.ReturnValueAllNew,
+		ReturnValuesOnConditionCheckFailure: types.ReturnValuesOnConditionCheckFailureAllOld,
+	}
+
+	callCtx, cancel := timeout.Call(ctx)
+	defer cancel()
+	result, err := store.Client.UpdateItem(callCtx, input)
+	if err != nil {
+		var conditionErr *db.ConditionError
+		if errors.As(db.Condition(err), &conditionErr) {
+			return nil, consumeFailure(conditionErr.Item, now)
+		}
+		return nil, fmt.Errorf("failed to consume provisioning token: %w", err)
+	}
+
+	var token Token
+	if err := attributevalue.UnmarshalMap(result.Attributes, &token); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal provisioning token: %w", err)
+	}
+	return &token, nil
+}
+
+// why the consume condition failed, from the item as it was
+func consumeFailure(item map[string]types.AttributeValue, now int64) error {
+	if len(item) == 0 {
+		return ErrTokenUnknown
+	}
+
+	var token Token
+	if err := attributevalue.UnmarshalMap(item, &token); err != nil {
+		return fmt.Errorf("failed to unmarshal provisioning token: %w", err)
+	}
+	if token.UsedAt != 0 {
+		return ErrTokenUsed
+	}
+	if token.ExpiresAt <= now {
+		return ErrTokenExpired
+	}
+	return ErrTokenUsed
+}
+
+// Release makes a consumed token claimable again, for when the registration after Consume
+// fails. Only the claim of deviceID is undone
+func (store *TokenStore) Release(ctx context.Context, plain, deviceID string) error {
+	input := &dynamodb.UpdateItemInput{
+		TableName: aws.String(store.TableName),
+		Key: map[string]types.AttributeValue{
+			"token_hash": &types.AttributeValueMemberS{Value: hashToken(plain)},
+		},
+		UpdateExpression:    aws.String("REMOVE used_at, device_id"),
+		ConditionExpression: aws.String("device_id = :device"),
+		ExpressionAttributeValues: map[string]types.AttributeValue{
+			":device": &types.AttributeValueMemberS{Value: deviceID},
+		},
+	}
+
+	callCtx, cancel := timeout.Call(ctx)
+	defer cancel()
+	if _, err := store.Client.UpdateItem(callCtx, input); err != nil {
+		return fmt.Errorf("failed to release provisioning token: %w", err)
+	}
+	return nil
+}
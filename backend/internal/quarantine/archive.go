@@ -0,0 +1,160 @@
+package quarantine
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+
+	"github.com/Fleexa-Graduation-Project/Backend/pkg/timeout"
+)
+
+const keyPrefix = "quarantine"
+
+// Record is one dead-lettered message as stored in the bucket, Body is kept exactly
+// as sqs delivered it (still base64/gzip when the device compressed it) so it can be replayed
+type Record struct {
+	MessageID         string            `json:"message_id"`
+	Body              string            `json:"body"`
+	Attributes        map[string]string `json:"attributes,omitempty"` // message attributes, e.g. Content-Encoding
+	Reason            string            `json:"reason"`
+	ReceiveCount      int               `json:"receive_count"`
+	OriginalTimestamp int64             `json:"original_timestamp"` // ms, when the message was first sent
+	ArchivedAt        int64             `json:"archived_at"`
+}
+
+// NewRecord captures a dlq message, string message attributes are kept for replay
+func NewRecord(message events.SQSMessage, reason string) Record {
+	record := Record{
+		MessageID:  message.MessageId,
+		Body:       message.Body,
+		Reason:     reason,
+		ArchivedAt: time.Now().UnixMilli(),
+	}
+
+	record.ReceiveCount, _ = strconv.Atoi(message.Attributes["ApproximateReceiveCount"])
+	record.OriginalTimestamp, _ = strconv.ParseInt(message.Attributes["SentTimestamp"], 10, 64)
+	if record.OriginalTimestamp == 0 {
+		record.OriginalTimestamp = record.ArchivedAt
+	}
+
+	for name, attr := range message.MessageAttributes {
+		if attr.StringValue != nil {
+			if record.Attributes == nil {
+				record.Attributes = map[string]string{}
+			}
+			record.Attributes[name] = *attr.StringValue
+		}
+	}
+
+	return record
+}
+
+type Archive struct {
+	Client *s3.Client
+	Bucket string
+}
+
+func NewArchive(client *s3.Client) (*Archive, error) {
+	bucket := os.Getenv("QUARANTINE_BUCKET")
+	if bucket == "" {
+		return nil, fmt.Errorf("QUARANTINE_BUCKET environment variable is not set")
+	}
+
+	return &Archive{
+		Client: client,
+		Bucket: bucket,
+	}, nil
+}
+
+// DatePrefix is the partition holding the messages originally sent on that day (UTC)
+func DatePrefix(day time.Time) string {
+	return fmt.Sprintf("%s/dt=%s/", keyPrefix, day.UTC().Format(time.DateOnly))
+}
+
+// quarantine/dt=2024-02-20/<message id>.json, partitioned by when the device sent it
+func objectKey(record Record) string {
+	return DatePrefix(time.UnixMilli(record.OriginalTimestamp)) + record.MessageID + ".json"
+}
+
+// Put stores the record and returns its key, a redelivered dlq message overwrites the same object
+func (archive *Archive) Put(ctx context.Context, record Record) (string, error) {
+	body, err := json.Marshal(record)
+	if err != nil {
+		return "", fmt.Errorf("failed to marshal quarantine record: %w", err)
+	}
+
+	key := objectKey(record)
+
+	callCtx, cancel := timeout.Call(ctx)
+	defer cancel()
+	_, err = archive.Client.PutObject(callCtx, &s3.PutObjectInput{
+		Bucket:      aws.String(archive.Bucket),
+		Key:         aws.String(key),
+		Body:        bytes.NewReader(body),
+		ContentType: aws.String("application/json"),
+	})
+	if err != nil {
+		return "", fmt.Errorf("failed to archive message %s: %w", record.MessageID, err)
+	}
+
+	return key, nil
+}
+
+// List returns every key under prefix
+func (archive *Archive) List(ctx context.Context, prefix string) ([]string, error) {
+	var keys []string
+
+	paginator := s3.NewListObjectsV2Paginator(archive.Client, &s3.ListObjectsV2Input{
+		Bucket: aws.String(archive.Bucket),
+		Prefix: aws.String(prefix),
+	})
+	for paginator.HasMorePages() {
+		callCtx, cancel := timeout.Call(ctx)
+		page, err := paginator.NextPage(callCtx)
+		cancel()
+		if err != nil {
+			return nil, fmt.Errorf("failed to list quarantine objects under %s: %w", prefix, err)
+		}
+
+		for _, object := range page.Contents {
+			keys = append(keys, aws.ToString(object.Key))
+		}
+	}
+
+	return keys, nil
+}
+
+func (archive *Archive) Get(ctx context.Context, key string) (Record, error) {
+	callCtx, cancel := timeout.Call(ctx)
+	defer cancel()
+
+	output, err := archive.Client.GetObject(callCtx, &s3.GetObjectInput{
+		Bucket: aws.String(archive.Bucket),
+		Key:    aws.String(key),
+	})
+	if err != nil {
+		return Record{}, fmt.Errorf("failed to get quarantine object %s: %w", key, err)
+	}
+	defer output.Body.Close()
+
+	body, err := io.ReadAll(output.Body)
+	if err != nil {
+		return Record{}, fmt.Errorf("failed to read quarantine object %s: %w", key, err)
+	}
+
+	var record Record
+	if err := json.Unmarshal(body, &record); err != nil {
+		return Record{}, fmt.Errorf("failed to unmarshal quarantine object %s: %w", key, err)
+	}
+
+	return record, nil
+}
@@ -0,0 +1,81 @@
+package quarantine
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"os"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/sqs"
+	"github.com/aws/aws-sdk-go-v2/service/sqs/types"
+
+	"github.com/Fleexa-Graduation-Project/Backend/pkg/timeout"
+)
+
+// Replayer sends archived messages back to the ingestion queue, body and attributes unchanged
+type Replayer struct {
+	Archive  *Archive
+	Client   *sqs.Client
+	QueueURL string
+	Logger   *slog.Logger
+}
+
+func NewReplayer(client *sqs.Client, archive *Archive, logger *slog.Logger) (*Replayer, error) {
+	queueURL := os.Getenv("INGESTION_QUEUE_URL")
+	if queueURL == "" {
+		return nil, fmt.Errorf("INGESTION_QUEUE_URL environment variable is not set")
+	}
+
+	return &Replayer{
+		Archive:  archive,
+		Client:   client,
+		QueueURL: queueURL,
+		Logger:   logger,
+	}, nil
+}
+
+// Replay re-enqueues every record under prefix and returns how many were sent.
+// Archived objects are left in place, replaying twice is safe since ingestion drops duplicates
+func (replayer *Replayer) Replay(ctx context.Context, prefix string) (int, error) {
+	keys, err := replayer.Archive.List(ctx, prefix)
+	if err != nil {
+		return 0, err
+	}
+
+	sent := 0
+	for _, key := range keys {
+		record, err := replayer.Archive.Get(ctx, key)
+		if err != nil {
+			return sent, err
+		}
+
+		if err := replayer.send(ctx, record); err != nil {
+			return sent, fmt.Errorf("failed to replay %s: %w", key, err)
+		}
+
+		replayer.Logger.Info("replayed quarantined message", "key", key, "message_id", record.MessageID, "reason", record.Reason)
+		sent++
+	}
+
+	return sent, nil
+}
+
+func (replayer *Replayer) send(ctx context.Context, record Record) error {
+	attributes := make(map[string]types.MessageAttributeValue, len(record.Attributes))
+	for name, value := range record.Attributes {
+		attributes[name] = types.MessageAttributeValue{
+			DataType:    aws.String("String"),
+			StringValue: aws.String(value),
+		}
+	}
+
+	callCtx, cancel := timeout.Call(ctx)
+	defer cancel()
+	_, err := replayer.Client.SendMessage(callCtx, &sqs.SendMessageInput{
+		QueueUrl:          aws.String(replayer.QueueURL),
+		MessageBody:       aws.String(record.Body),
+		MessageAttributes: attributes,
+	})
+	return err
+}
@@ -0,0 +1,285 @@
+package ratelimit
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/attributevalue"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+
+	appconfig "github.com/Fleexa-Graduation-Project/Backend/pkg/config"
+	"github.com/Fleexa-Graduation-Project/Backend/pkg/db"
+	"github.com/Fleexa-Graduation-Project/Backend/pkg/timeout"
+)
+
+const (
+	DefaultAPIRate  = 10 // requests per second a caller sustains
+	DefaultAPIBurst = 20
+	// the throttle is in front of every request, it gets far less time than a normal store call
+	DefaultAPITimeout = 250 * time.Millisecond
+
+	// containers racing for one bucket re-read it this many times before the request is throttled
+	takeAttempts = 4
+)
+
+// Plan is a token bucket: Burst requests at once, refilled at Rate per second. A Rate of 0
+// disables throttling for the callers on it
+type Plan struct {
+	Rate  float64 `json:"rate"`
+	Burst int     `json:"burst"`
+}
+
+// APIConfig is the default plan plus plans per caller key ("user:u-1", "fleet:fleet-a", "key:...", "ip:...")
+type APIConfig struct {
+	Default Plan
+	Plans   map[string]Plan
+	Timeout time.Duration
+}
+
+// API_THROTTLE_RATE, API_THROTTLE_BURST, API_THROTTLE_TIMEOUT and
+// API_THROTTLE_PLANS='{"fleet:fleet-a":{"rate":50,"burst":100}}' override the defaults
+func LoadAPIConfig() (APIConfig, error) {
+	cfg := APIConfig{Default: Plan{Rate: DefaultAPIRate, Burst: DefaultAPIBurst}, Plans: map[string]Plan{}, Timeout: DefaultAPITimeout}
+
+	if raw := os.Getenv("API_THROTTLE_RATE"); raw != "" {
+		rate, err := strconv.ParseFloat(raw, 64)
+		if err != nil || rate < 0 {
+			return APIConfig{}, fmt.Errorf("invalid API_THROTTLE_RATE %q", raw)
+		}
+		cfg.Default.Rate = rate
+	}
+
+	if raw := os.Getenv("API_THROTTLE_BURST"); raw != "" {
+		burst, err := strconv.Atoi(raw)
+		if err != nil || burst < 1 {
+			return APIConfig{}, fmt.Errorf("invalid API_THROTTLE_BURST %q", raw)
+		}
+		cfg.Default.Burst = burst
+	}
+
+	if raw := os.Getenv("API_THROTTLE_TIMEOUT"); raw != "" {
+		limit, err := time.ParseDuration(raw)
+		if err != nil || limit <= 0 {
+			return APIConfig{}, fmt.Errorf("invalid API_THROTTLE_TIMEOUT %q", raw)
+		}
+		cfg.Timeout = limit
+	}
+
+	if raw := os.Getenv("API_THROTTLE_PLANS"); raw != "" {
+		if err := json.Unmarshal([]byte(raw), &cfg.Plans); err != nil {
+			return APIConfig{}, fmt.Errorf("invalid API_THROTTLE_PLANS: %w", err)
+		}
+		for key, plan := range cfg.Plans {
+			if plan.Rate < 0 || (plan.Rate > 0 && plan.Burst < 1) {
+				return APIConfig{}, fmt.Errorf("invalid API_THROTTLE_PLANS: %s needs a rate of 0 or more and a burst of at least 1", key)
+			}
+		}
+	}
+
+	return cfg, nil
+}
+
+// PlanFor is the plan of the first key that has one, the default otherwise
+func (cfg APIConfig) PlanFor(keys ...string) Plan {
+	for _, key := range keys {
+		if plan, ok := cfg.Plans[key]; ok {
+			return plan
+		}
+	}
+	return cfg.Default
+}
+
+// one bucket per caller in DYNAMODB_RATE_LIMITS_TABLE, keyed bucket_key = "api#" + caller
+type bucketState struct {
+	Tokens     float64 `dynamodbav:"tokens"`
+	RefilledAt int64   `dynamodbav:"refilled_at"` // unix ms
+}
+
+// APIThrottle keeps a token bucket per api caller, shared by all lambda containers. A caller
+// that ran dry is remembered by the container until its next token is due, so a client
+// hammering the api costs no dynamodb calls while it waits
+type APIThrottle struct {
+	Client    *dynamodb.Client
+	TableName string
+	Config    APIConfig
+
+	mu      sync.Mutex
+	blocked map[string]time.Time // caller -> when its next token is due
+}
+
+func NewAPIThrottle(cfg APIConfig) (*APIThrottle, error) {
+	tableName := appconfig.TableName("DYNAMODB_RATE_LIMITS_TABLE")
+	if tableName == "" {
+		return nil, fmt.Errorf("DYNAMODB_RATE_LIMITS_TABLE environment variable is not set")
+	}
+
+	if db.Client == nil {
+		return nil, fmt.Errorf("dynamodb client is not initialized")
+	}
+
+	return &APIThrottle{
+		Client:    db.Client,
+		TableName: tableName,
+		Config:    cfg,
+		blocked:   map[string]time.Time{},
+	}, nil
+}
+
+// Take spends a token of the caller's bucket. When it is empty, retryAfter is how long until
+// the next token. The dynamodb calls get Config.Timeout in total, on any error it fails open and
+// returns the error for logging
+func (throttle *APIThrottle) Take(ctx context.Context, caller string, plan Plan) (allowed bool, retryAfter time.Duration, err error) {
+	if plan.Rate == 0 {
+		return true, 0, nil
+	}
+
+	now := time.Now()
+	if due, ok := throttle.blockedUntil(caller); ok && now.Before(due) {
+		return false, due.Sub(now), nil
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, throttle.Config.Timeout)
+	defer cancel()
+
+	// containers racing for the same bucket, the loser reads it again. A bucket still contended
+	// after every attempt is being drained faster than one token at a time, so it is throttled
+	// instead of admitting requests no write accounted for
+	for attempt := 0; attempt < takeAttempts; attempt++ {
+		allowed, retryAfter, err = throttle.take(ctx, caller, plan, time.Now())
+		if !errors.Is(err, db.ErrConditionFailed) {
+			break
+		}
+	}
+	if errors.Is(err, db.ErrConditionFailed) {
+		return false, time.Duration(float64(time.Second) / plan.Rate), nil
+	}
+	if err != nil {
+		return true, 0, err
+	}
+	if !allowed {
+		throttle.block(caller, now.Add(retryAfter))
+	}
+	return allowed, retryAfter, nil
+}
+
+func (throttle *APIThrottle) take(ctx context.Context, caller string, plan Plan, now time.Time) (bool, time.Duration, error) {
+	stored, err := readBucket(ctx, throttle.Client, throttle.TableName, "api#"+caller, plan, now)
+	if err != nil {
+		return true, 0, fmt.Errorf("failed to read api throttle of %s: %w", caller, err)
+	}
+
+	if stored.state.Tokens < 1 {
+		return false, time.Duration((1 - stored.state.Tokens) / plan.Rate * float64(time.Second)), nil
+	}
+
+	if err := writeBucket(ctx, throttle.Client, throttle.TableName, stored, stored.state.Tokens-1, plan, now); err != nil {
+		if errors.Is(err, db.ErrConditionFailed) {
+			return true, 0, err
+		}
+		return true, 0, fmt.Errorf("failed to update api throttle of %s: %w", caller, err)
+	}
+	return true, 0, nil
+}
+
+// a bucket item as it was read, its tokens refilled to the time of the read. item is nil while
+// the bucket doesn't exist, a full bucket then
+type storedBucket struct {
+	key   map[string]types.AttributeValue
+	item  map[string]types.AttributeValue
+	state bucketState
+}
+
+func readBucket(ctx context.Context, client *dynamodb.Client, tableName, bucketKey string, plan Plan, now time.Time) (storedBucket, error) {
+	stored := storedBucket{
+		key:   map[string]types.AttributeValue{"bucket_key": &types.AttributeValueMemberS{Value: bucketKey}},
+		state: bucketState{Tokens: float64(plan.Burst), RefilledAt: now.UnixMilli()},
+	}
+
+	callCtx, cancel := timeout.Call(ctx)
+	defer cancel()
+	result, err := client.GetItem(callCtx, &dynamodb.GetItemInput{
+		TableName:      aws.String(tableName),
+		Key:            stored.key,
+		ConsistentRead: aws.Bool(true),
+	})
+	if err != nil {
+		return stored, err
+	}
+	if result.Item == nil {
+		return stored, nil
+	}
+
+	if err := attributevalue.UnmarshalMap(result.Item, &stored.state); err != nil {
+		return stored, fmt.Errorf("failed to unmarshal bucket: %w", err)
+	}
+	stored.item = result.Item
+	stored.state.Tokens = refilled(stored.state, plan, now)
+	return stored, nil
+}
+
+// stores tokens as the bucket's level at now. The update only lands on the exact item it was
+// computed from, any write in between fails it with db.ErrConditionFailed
+func writeBucket(ctx context.Context, client *dynamodb.Client, tableName string, stored storedBucket, tokens float64, plan Plan, now time.Time) error {
+	condition := "attribute_not_exists(bucket_key)"
+	values := map[string]types.AttributeValue{}
+	if stored.item != nil {
+		condition = "tokens = :previous_tokens AND refilled_at = :previous_refill"
+		values[":previous_tokens"] = stored.item["tokens"]
+		values[":previous_refill"] = stored.item["refilled_at"]
+	}
+
+	// the item is gone once a full bucket would have refilled, plus a minute of slack
+	refill := time.Duration(float64(plan.Burst) / plan.Rate * float64(time.Second))
+	values[":tokens"] = &types.AttributeValueMemberN{Value: strconv.FormatFloat(tokens, 'f', -1, 64)}
+	values[":refilled_at"] = &types.AttributeValueMemberN{Value: strconv.FormatInt(now.UnixMilli(), 10)}
+	values[":expires_at"] = &types.AttributeValueMemberN{Value: strconv.FormatInt(now.Add(refill+time.Minute).Unix(), 10)}
+
+	callCtx, cancel := timeout.Call(ctx)
+	defer cancel()
+	_, err := client.UpdateItem(callCtx, &dynamodb.UpdateItemInput{
+		TableName:                 aws.String(tableName),
+		Key:                       stored.key,
+		UpdateExpression:          aws.String("SET tokens = :tokens, refilled_at = :refilled_at, expires_at = :expires_at"),
+		ConditionExpression:       aws.String(condition),
+		ExpressionAttributeValues: values,
+	})
+	if errors.Is(db.Condition(err), db.ErrConditionFailed) {
+		return db.ErrConditionFailed
+	}
+	return err
+}
+
+// the tokens of the bucket at now, never more than the plan's burst
+func refilled(state bucketState, plan Plan, now time.Time) float64 {
+	elapsed := now.Sub(time.UnixMilli(state.RefilledAt)).Seconds()
+	return math.Min(float64(plan.Burst), state.Tokens+math.Max(elapsed, 0)*plan.Rate)
+}
+
+func (throttle *APIThrottle) blockedUntil(caller string) (time.Time, bool) {
+	throttle.mu.Lock()
+	defer throttle.mu.Unlock()
+	due, ok := throttle.blocked[caller]
+	return due, ok
+}
+
+// callers whose token is due by now are dropped here so the map only holds the blocked ones
+func (throttle *APIThrottle) block(caller string, due time.Time) {
+	throttle.mu.Lock()
+	defer throttle.mu.Unlock()
+	now := time.Now()
+	for id, until := range throttle.blocked {
+		if !now.Before(until) {
+			delete(throttle.blocked, id)
+		}
+	}
+	throttle.blocked[caller] = due
+}
@@ -0,0 +1,282 @@
+package ratelimit
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+
+	"github.com/Fleexa-Graduation-Project/Backend/internal/devices"
+	appconfig "github.com/Fleexa-Graduation-Project/Backend/pkg/config"
+	"github.com/Fleexa-Graduation-Project/Backend/pkg/db"
+)
+
+const (
+	DefaultLimit  = 120 // bucket capacity, refilled over one window; a healthy device reports a few times a minute
+	DefaultWindow = time.Minute
+	// how often a container settles a device's local bucket with the shared one
+	DefaultSyncInterval = 5 * time.Second
+
+	fleetCacheTTL = 10 * time.Minute // fleet membership rarely changes, don't look it up per message
+)
+
+// Config is the global limit plus per-fleet overrides, an override of 0 disables limiting for
+// that fleet. A limit is a token bucket holding Limit messages, refilled by Limit per Window
+type Config struct {
+	Limit        int
+	Window       time.Duration
+	FleetLimits  map[string]int
+	SyncInterval time.Duration
+}
+
+// RATE_LIMIT_PER_WINDOW, RATE_LIMIT_WINDOW, RATE_LIMIT_SYNC_INTERVAL and
+// RATE_LIMIT_FLEET_OVERRIDES='{"fleet-a":600}' override the defaults
+func LoadConfig() (Config, error) {
+	cfg := Config{Limit: DefaultLimit, Window: DefaultWindow, FleetLimits: map[string]int{}, SyncInterval: DefaultSyncInterval}
+
+	if raw := os.Getenv("RATE_LIMIT_PER_WINDOW"); raw != "" {
+		limit, err := strconv.Atoi(raw)
+		if err != nil || limit < 0 {
+			return Config{}, fmt.Errorf("invalid RATE_LIMIT_PER_WINDOW %q", raw)
+		}
+		cfg.Limit = limit
+	}
+
+	if raw := os.Getenv("RATE_LIMIT_WINDOW"); raw != "" {
+		window, err := time.ParseDuration(raw)
+		if err != nil || window < time.Second {
+			return Config{}, fmt.Errorf("invalid RATE_LIMIT_WINDOW %q", raw)
+		}
+		cfg.Window = window
+	}
+
+	if raw := os.Getenv("RATE_LIMIT_SYNC_INTERVAL"); raw != "" {
+		interval, err := time.ParseDuration(raw)
+		if err != nil || interval <= 0 {
+			return Config{}, fmt.Errorf("invalid RATE_LIMIT_SYNC_INTERVAL %q", raw)
+		}
+		cfg.SyncInterval = interval
+	}
+
+	if raw := os.Getenv("RATE_LIMIT_FLEET_OVERRIDES"); raw != "" {
+		if err := json.Unmarshal([]byte(raw), &cfg.FleetLimits); err != nil {
+			return Config{}, fmt.Errorf("invalid RATE_LIMIT_FLEET_OVERRIDES: %w", err)
+		}
+		for fleetID, limit := range cfg.FleetLimits {
+			if limit < 0 {
+				return Config{}, fmt.Errorf("invalid RATE_LIMIT_FLEET_OVERRIDES: %s needs a limit of 0 or more", fleetID)
+			}
+		}
+	}
+
+	return cfg, nil
+}
+
+// the bucket of a limit, 0 is never limited
+func (cfg Config) plan(limit int) Plan {
+	return Plan{Rate: float64(limit) / cfg.Window.Seconds(), Burst: limit}
+}
+
+type fleetEntry struct {
+	fleetID   string
+	expiresAt time.Time
+}
+
+// a container's view of a device's bucket, the messages it let through since the last sync
+// are not in the shared bucket yet
+type deviceBucket struct {
+	tokens     float64
+	refilledAt time.Time
+	spent      float64
+	syncedAt   time.Time // zero until the shared bucket was read
+	seenAt     time.Time
+}
+
+func (bucket *deviceBucket) take(plan Plan, now time.Time) bool {
+	if elapsed := now.Sub(bucket.refilledAt).Seconds(); elapsed > 0 {
+		bucket.tokens = math.Min(float64(plan.Burst), bucket.tokens+elapsed*plan.Rate)
+		bucket.refilledAt = now
+	}
+	bucket.seenAt = now
+	if bucket.tokens < 1 {
+		return false
+	}
+	bucket.tokens--
+	bucket.spent++
+	return true
+}
+
+func (bucket *deviceBucket) dueForSync(interval time.Duration, now time.Time) bool {
+	return bucket.syncedAt.IsZero() || now.Sub(bucket.syncedAt) >= interval
+}
+
+// shared is the shared bucket at now after the sync took accounted tokens from it, what was
+// spent here while the sync ran is still owed
+func (bucket *deviceBucket) synced(shared, accounted float64, now time.Time) {
+	bucket.spent = math.Max(bucket.spent-accounted, 0)
+	bucket.tokens = math.Max(shared-bucket.spent, 0)
+	bucket.refilledAt = now
+	bucket.syncedAt = now
+}
+
+// Limiter is a token bucket per device. Each container spends from its own copy of the bucket
+// without calling dynamodb, and every Config.SyncInterval (and on the first message of a device)
+// it settles what it spent with the bucket in dynamodb that all containers share. Containers can
+// together overspend a bucket by what each of them lets through in one interval
+type Limiter struct {
+	Client    *dynamodb.Client
+	TableName string
+	Config    Config
+	Registry  devices.Registry // optional, needed for fleet overrides
+
+	mu      sync.Mutex
+	buckets map[string]*deviceBucket
+	sweptAt time.Time
+	fleets  map[string]fleetEntry
+}
+
+func NewLimiter(cfg Config, registry devices.Registry) (*Limiter, error) {
+	tableName := appconfig.TableName("DYNAMODB_RATE_LIMITS_TABLE")
+	if tableName == "" {
+		return nil, fmt.Errorf("DYNAMODB_RATE_LIMITS_TABLE environment variable is not set")
+	}
+
+	if db.Client == nil {
+		return nil, fmt.Errorf("dynamodb client is not initialized")
+	}
+
+	if cfg.SyncInterval <= 0 {
+		cfg.SyncInterval = DefaultSyncInterval
+	}
+
+	return &Limiter{
+		Client:    db.Client,
+		TableName: tableName,
+		Config:    cfg,
+		Registry:  registry,
+		buckets:   map[string]*deviceBucket{},
+		fleets:    map[string]fleetEntry{},
+	}, nil
+}
+
+// Allow spends a token of the device's bucket and reports whether the message may go through.
+// When the sync with dynamodb fails the container's own bucket still limits the device, the
+// error is returned for logging and the sync retried an interval later
+func (limiter *Limiter) Allow(ctx context.Context, deviceID string) (bool, error) {
+	limit := limiter.limitFor(ctx, deviceID)
+	if limit == 0 {
+		return true, nil
+	}
+	plan := limiter.Config.plan(limit)
+	now := time.Now()
+
+	limiter.mu.Lock()
+	bucket := limiter.bucket(deviceID, plan, now)
+	due := bucket.dueForSync(limiter.Config.SyncInterval, now)
+	spent := bucket.spent
+	if due {
+		// other messages of the device keep spending locally while this one syncs
+		bucket.syncedAt = now
+	}
+	limiter.mu.Unlock()
+
+	var err error
+	if due {
+		var shared float64
+		shared, err = limiter.sync(ctx, deviceID, plan, spent, now)
+		if err == nil {
+			limiter.mu.Lock()
+			bucket.synced(shared, spent, now)
+			limiter.mu.Unlock()
+		}
+	}
+
+	limiter.mu.Lock()
+	defer limiter.mu.Unlock()
+	return bucket.take(plan, now), err
+}
+
+// the device's local bucket, full when the container hasn't seen it yet. Buckets idle for a
+// window are dropped, by then they refilled and the shared bucket holds nothing they owe
+func (limiter *Limiter) bucket(deviceID string, plan Plan, now time.Time) *deviceBucket {
+	if now.Sub(limiter.sweptAt) >= limiter.Config.Window {
+		for id, bucket := range limiter.buckets {
+			if now.Sub(bucket.seenAt) >= limiter.Config.Window {
+				delete(limiter.buckets, id)
+			}
+		}
+		limiter.sweptAt = now
+	}
+
+	bucket, ok := limiter.buckets[deviceID]
+	if !ok {
+		bucket = &deviceBucket{tokens: float64(plan.Burst), refilledAt: now, seenAt: now}
+		limiter.buckets[deviceID] = bucket
+	}
+	return bucket
+}
+
+// takes spent tokens from the shared bucket of the device and returns what is left in it,
+// containers racing for the bucket re-read it
+func (limiter *Limiter) sync(ctx context.Context, deviceID string, plan Plan, spent float64, now time.Time) (float64, error) {
+	for attempt := 0; attempt < takeAttempts; attempt++ {
+		stored, err := readBucket(ctx, limiter.Client, limiter.TableName, "device#"+deviceID, plan, now)
+		if err != nil {
+			return 0, fmt.Errorf("failed to read rate limit of device %s: %w", deviceID, err)
+		}
+
+		left := math.Max(stored.state.Tokens-spent, 0)
+		err = writeBucket(ctx, limiter.Client, limiter.TableName, stored, left, plan, now)
+		if errors.Is(err, db.ErrConditionFailed) {
+			continue
+		}
+		if err != nil {
+			return 0, fmt.Errorf("failed to update rate limit of device %s: %w", deviceID, err)
+		}
+		return left, nil
+	}
+	return 0, fmt.Errorf("rate limit of device %s is contended: %w", deviceID, db.ErrConditionFailed)
+}
+
+func (limiter *Limiter) limitFor(ctx context.Context, deviceID string) int {
+	if len(limiter.Config.FleetLimits) == 0 || limiter.Registry == nil {
+		return limiter.Config.Limit
+	}
+
+	if limit, ok := limiter.Config.FleetLimits[limiter.fleetOf(ctx, deviceID)]; ok {
+		return limit
+	}
+	return limiter.Config.Limit
+}
+
+// cached fleet lookup, unregistered devices and lookup errors fall back to the global limit
+func (limiter *Limiter) fleetOf(ctx context.Context, deviceID string) string {
+	limiter.mu.Lock()
+	entry, ok := limiter.fleets[deviceID]
+	limiter.mu.Unlock()
+	if ok && time.Now().Before(entry.expiresAt) {
+		return entry.fleetID
+	}
+
+	device, err := limiter.Registry.GetDevice(ctx, deviceID)
+	if err != nil {
+		return ""
+	}
+
+	entry = fleetEntry{expiresAt: time.Now().Add(fleetCacheTTL)}
+	if device != nil {
+		entry.fleetID = device.FleetID
+	}
+
+	limiter.mu.Lock()
+	limiter.fleets[deviceID] = entry
+	limiter.mu.Unlock()
+	return entry.fleetID
+}
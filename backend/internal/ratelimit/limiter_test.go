@@ -0,0 +1,215 @@
+package ratelimit
+
+import (
+	"math"
+	"testing"
+	"time"
+)
+
+func TestDeviceBucketTake(t *testing.T) {
+	start := time.Unix(1700000000, 0)
+	// 60 messages a minute, one token a second
+	plan := Config{Window: time.Minute}.plan(60)
+
+	tests := []struct {
+		name      string
+		tokens    float64
+		sends     []time.Duration // offsets from start
+		wantAllow []bool
+	}{
+		{
+			name:      "burst up to capacity",
+			tokens:    3,
+			sends:     []time.Duration{0, 0, 0, 0},
+			wantAllow: []bool{true, true, true, false},
+		},
+		{
+			name:      "refills at the plan rate",
+			tokens:    1,
+			sends:     []time.Duration{0, 500 * time.Millisecond, time.Second, 1500 * time.Millisecond, 3 * time.Second},
+			wantAllow: []bool{true, false, true, false, true},
+		},
+		{
+			name:      "a quiet device refills to capacity, never past it",
+			tokens:    0,
+			sends:     repeat(time.Hour, 61),
+			wantAllow: append(repeatBool(true, 60), false),
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			bucket := &deviceBucket{tokens: test.tokens, refilledAt: start}
+			for i, offset := range test.sends {
+				if got := bucket.take(plan, start.Add(offset)); got != test.wantAllow[i] {
+					t.Fatalf("send %d at +%s allowed = %v, want %v", i, offset, got, test.wantAllow[i])
+				}
+			}
+		})
+	}
+}
+
+func TestDeviceBucketSynced(t *testing.T) {
+	now := time.Unix(1700000000, 0)
+
+	tests := []struct {
+		name       string
+		spent      float64
+		accounted  float64
+		shared     float64
+		wantTokens float64
+		wantSpent  float64
+	}{
+		{name: "everything accounted", spent: 5, accounted: 5, shared: 40, wantTokens: 40, wantSpent: 0},
+		{name: "spent while the sync ran is still owed", spent: 7, accounted: 5, shared: 40, wantTokens: 38, wantSpent: 2},
+		{name: "other containers drained the bucket", spent: 3, accounted: 1, shared: 0, wantTokens: 0, wantSpent: 2},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			bucket := &deviceBucket{tokens: 100, spent: test.spent}
+			bucket.synced(test.shared, test.accounted, now)
+			if bucket.tokens != test.wantTokens || bucket.spent != test.wantSpent {
+				t.Errorf("tokens, spent = %v, %v, want %v, %v", bucket.tokens, bucket.spent, test.wantTokens, test.wantSpent)
+			}
+			if !bucket.syncedAt.Equal(now) || !bucket.refilledAt.Equal(now) {
+				t.Errorf("synced at %v, refilled at %v, want both %v", bucket.syncedAt, bucket.refilledAt, now)
+			}
+		})
+	}
+}
+
+func TestDeviceBucketDueForSync(t *testing.T) {
+	now := time.Unix(1700000000, 0)
+
+	tests := []struct {
+		name     string
+		syncedAt time.Time
+		want     bool
+	}{
+		{name: "never synced", want: true},
+		{name: "synced within the interval", syncedAt: now.Add(-time.Second), want: false},
+		{name: "interval elapsed", syncedAt: now.Add(-5 * time.Second), want: true},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			bucket := &deviceBucket{syncedAt: test.syncedAt}
+			if got := bucket.dueForSync(5*time.Second, now); got != test.want {
+				t.Errorf("dueForSync() = %v, want %v", got, test.want)
+			}
+		})
+	}
+}
+
+func TestLimiterBucketEviction(t *testing.T) {
+	now := time.Unix(1700000000, 0)
+	limiter := &Limiter{Config: Config{Window: time.Minute}, buckets: map[string]*deviceBucket{}}
+	plan := limiter.Config.plan(10)
+
+	limiter.bucket("idle", plan, now)
+	limiter.bucket("busy", plan, now).take(plan, now.Add(50*time.Second))
+
+	limiter.bucket("new", plan, now.Add(70*time.Second))
+	if _, ok := limiter.buckets["idle"]; ok {
+		t.Error("idle bucket kept past its window")
+	}
+	if _, ok := limiter.buckets["busy"]; !ok {
+		t.Error("busy bucket dropped")
+	}
+	if got := limiter.buckets["new"].tokens; got != 10 {
+		t.Errorf("new bucket has %v tokens, want a full bucket of 10", got)
+	}
+}
+
+func TestLoadConfig(t *testing.T) {
+	tests := []struct {
+		name    string
+		env     map[string]string
+		want    Config
+		wantErr bool
+	}{
+		{
+			name: "defaults",
+			want: Config{Limit: DefaultLimit, Window: DefaultWindow, FleetLimits: map[string]int{}, SyncInterval: DefaultSyncInterval},
+		},
+		{
+			name: "overrides",
+			env: map[string]string{
+				"RATE_LIMIT_PER_WINDOW":      "30",
+				"RATE_LIMIT_WINDOW":          "10s",
+				"RATE_LIMIT_SYNC_INTERVAL":   "1s",
+				"RATE_LIMIT_FLEET_OVERRIDES": `{"fleet-a": 600, "fleet-b": 0}`,
+			},
+			want: Config{Limit: 30, Window: 10 * time.Second, FleetLimits: map[string]int{"fleet-a": 600, "fleet-b": 0}, SyncInterval: time.Second},
+		},
+		{name: "negative limit", env: map[string]string{"RATE_LIMIT_PER_WINDOW": "-1"}, wantErr: true},
+		{name: "window under a second", env: map[string]string{"RATE_LIMIT_WINDOW": "500ms"}, wantErr: true},
+		{name: "zero sync interval", env: map[string]string{"RATE_LIMIT_SYNC_INTERVAL": "0s"}, wantErr: true},
+		{name: "negative fleet override", env: map[string]string{"RATE_LIMIT_FLEET_OVERRIDES": `{"fleet-a": -5}`}, wantErr: true},
+		{name: "fleet overrides not json", env: map[string]string{"RATE_LIMIT_FLEET_OVERRIDES": "fleet-a=5"}, wantErr: true},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			for _, name := range []string{"RATE_LIMIT_PER_WINDOW", "RATE_LIMIT_WINDOW", "RATE_LIMIT_SYNC_INTERVAL", "RATE_LIMIT_FLEET_OVERRIDES"} {
+				t.Setenv(name, test.env[name])
+			}
+
+			got, err := LoadConfig()
+			if (err != nil) != test.wantErr {
+				t.Fatalf("LoadConfig() error = %v, wantErr %v", err, test.wantErr)
+			}
+			if test.wantErr {
+				return
+			}
+			if got.Limit != test.want.Limit || got.Window != test.want.Window || got.SyncInterval != test.want.SyncInterval || len(got.FleetLimits) != len(test.want.FleetLimits) {
+				t.Fatalf("LoadConfig() = %+v, want %+v", got, test.want)
+			}
+			for fleetID, limit := range test.want.FleetLimits {
+				if got.FleetLimits[fleetID] != limit {
+					t.Errorf("fleet %s limit = %d, want %d", fleetID, got.FleetLimits[fleetID], limit)
+				}
+			}
+		})
+	}
+}
+
+func TestRefilled(t *testing.T) {
+	now := time.Unix(1700000000, 0)
+	plan := Plan{Rate: 2, Burst: 10}
+
+	tests := []struct {
+		name  string
+		state bucketState
+		want  float64
+	}{
+		{name: "refills by rate", state: bucketState{Tokens: 1, RefilledAt: now.Add(-2 * time.Second).UnixMilli()}, want: 5},
+		{name: "capped at burst", state: bucketState{Tokens: 8, RefilledAt: now.Add(-time.Minute).UnixMilli()}, want: 10},
+		{name: "clock behind the item refills nothing", state: bucketState{Tokens: 3, RefilledAt: now.Add(time.Second).UnixMilli()}, want: 3},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if got := refilled(test.state, plan, now); math.Abs(got-test.want) > 1e-9 {
+				t.Errorf("refilled() = %v, want %v", got, test.want)
+			}
+		})
+	}
+}
+
+func repeat(offset time.Duration, n int) []time.Duration {
+	offsets := make([]time.Duration, n)
+	for i := range offsets {
+		offsets[i] = offset
+	}
+	return offsets
+}
+
+func repeatBool(value bool, n int) []bool {
+	values := make([]bool, n)
+	for i := range values {
+		values[i] = value
+	}
+	return values
+}
@@ -0,0 +1,100 @@
+package realtime
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/apigatewaymanagementapi"
+	"github.com/aws/aws-sdk-go-v2/service/apigatewaymanagementapi/types"
+
+	"github.com/Fleexa-Graduation-Project/Backend/internal/devices"
+	"github.com/Fleexa-Graduation-Project/Backend/models"
+	"github.com/Fleexa-Graduation-Project/Backend/pkg/logger"
+	"github.com/Fleexa-Graduation-Project/Backend/pkg/timeout"
+)
+
+// what connected dashboards receive for every stored reading of their fleet
+type telemetryUpdate struct {
+	Type    string           `json:"type"`
+	FleetID string           `json:"fleet_id"`
+	Data    models.Telemetry `json:"data"`
+}
+
+// Broadcaster pushes new telemetry to the websocket clients subscribed to the device's fleet
+type Broadcaster struct {
+	Client      *apigatewaymanagementapi.Client
+	Connections *ConnectionStore
+	Devices     devices.Registry
+}
+
+// NewBroadcaster posts through the websocket stage url in WEBSOCKET_ENDPOINT
+// (https://{api-id}.execute-api.{region}.amazonaws.com/{stage})
+func NewBroadcaster(cfg aws.Config, connections *ConnectionStore, registry devices.Registry) (*Broadcaster, error) {
+	endpoint := os.Getenv("WEBSOCKET_ENDPOINT")
+	if endpoint == "" {
+		return nil, fmt.Errorf("WEBSOCKET_ENDPOINT environment variable is not set")
+	}
+
+	client := apigatewaymanagementapi.NewFromConfig(cfg, func(o *apigatewaymanagementapi.Options) {
+		o.BaseEndpoint = aws.String(endpoint)
+	})
+
+	return &Broadcaster{
+		Client:      client,
+		Connections: connections,
+		Devices:     registry,
+	}, nil
+}
+
+// BroadcastTelemetry fans the reading out, connections that are gone are pruned and
+// a failed post to one client doesn't stop the others
+func (broadcaster *Broadcaster) BroadcastTelemetry(ctx context.Context, data models.Telemetry) error {
+	log := logger.FromContext(ctx)
+
+	device, err := broadcaster.Devices.GetDevice(ctx, data.DeviceID)
+	if err != nil {
+		return fmt.Errorf("failed to resolve fleet for device %s: %w", data.DeviceID, err)
+	}
+	if device == nil || device.FleetID == "" {
+		return nil // unregistered devices belong to no fleet, nobody is subscribed
+	}
+
+	connections, err := broadcaster.Connections.GetConnectionsByFleet(ctx, device.FleetID)
+	if err != nil {
+		return err
+	}
+	if len(connections) == 0 {
+		return nil
+	}
+
+	message, err := json.Marshal(telemetryUpdate{Type: "telemetry", FleetID: device.FleetID, Data: data})
+	if err != nil {
+		return fmt.Errorf("failed to marshal telemetry update: %w", err)
+	}
+
+	for _, connection := range connections {
+		callCtx, cancel := timeout.Call(ctx)
+		_, err := broadcaster.Client.PostToConnection(callCtx, &apigatewaymanagementapi.PostToConnectionInput{
+			ConnectionId: aws.String(connection.ConnectionID),
+			Data:         message,
+		})
+		cancel()
+
+		var gone *types.GoneException
+		switch {
+		case err == nil:
+		case errors.As(err, &gone):
+			if deleteErr := broadcaster.Connections.DeleteConnection(ctx, connection.ConnectionID); deleteErr != nil {
+				log.Warn("failed to prune stale connection", "connection_id", connection.ConnectionID, "error", deleteErr)
+			}
+		default:
+			log.Warn("failed to post telemetry to connection", "connection_id", connection.ConnectionID, "fleet_id", device.FleetID, "error", err)
+		}
+	}
+
+	return nil
+}
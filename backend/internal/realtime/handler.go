@@ -0,0 +1,106 @@
+package realtime
+
+import (
+	"context"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"strings"
+
+	"github.com/aws/aws-lambda-go/events"
+
+	"github.com/Fleexa-Graduation-Project/Backend/internal/auth"
+	"github.com/Fleexa-Graduation-Project/Backend/pkg/logger"
+)
+
+// message clients send on the $default route
+type clientMessage struct {
+	Action  string `json:"action"`
+	FleetID string `json:"fleet_id"`
+}
+
+// Handler serves the websocket api routes, the dashboard connects with
+// wss://.../?fleet_id=home-01&token=<jwt> since browsers can't set headers on websockets
+type Handler struct {
+	Logger      *slog.Logger
+	Connections *ConnectionStore
+}
+
+func (handler *Handler) HandleRequest(ctx context.Context, request events.APIGatewayWebsocketProxyRequest) (events.APIGatewayProxyResponse, error) {
+	connectionID := request.RequestContext.ConnectionID
+	log := logger.WithRequestID(ctx, handler.Logger).With("connection_id", connectionID, "route", request.RequestContext.RouteKey)
+
+	switch request.RequestContext.RouteKey {
+	case "$connect":
+		return handler.connect(ctx, log, request)
+
+	case "$disconnect":
+		if err := handler.Connections.DeleteConnection(ctx, connectionID); err != nil {
+			log.Error("failed to remove connection", "error", err)
+			return respond(http.StatusInternalServerError), nil
+		}
+		log.Info("websocket disconnected")
+		return respond(http.StatusOK), nil
+
+	default:
+		return handler.message(ctx, log, request)
+	}
+}
+
+// a rejected $connect never gets a connection, so the status code is all the client sees
+func (handler *Handler) connect(ctx context.Context, log *slog.Logger, request events.APIGatewayWebsocketProxyRequest) (events.APIGatewayProxyResponse, error) {
+	claims, err := auth.ValidateJWT(strings.TrimPrefix(request.QueryStringParameters["token"], "Bearer "))
+	if err != nil {
+		log.Warn("websocket connect rejected", "error", err)
+		return respond(http.StatusUnauthorized), nil
+	}
+
+	fleetID := strings.TrimSpace(request.QueryStringParameters["fleet_id"])
+	if fleetID == "" {
+		log.Warn("websocket connect without fleet_id")
+		return respond(http.StatusBadRequest), nil
+	}
+
+	err = handler.Connections.SaveConnection(ctx, Connection{
+		ConnectionID: request.RequestContext.ConnectionID,
+		FleetID:      fleetID,
+		UserID:       claims.UserID,
+	})
+	if err != nil {
+		log.Error("failed to store connection", "fleet_id", fleetID, "error", err)
+		return respond(http.StatusInternalServerError), nil
+	}
+
+	log.Info("websocket connected", "fleet_id", fleetID, "user_id", claims.UserID)
+	return respond(http.StatusOK), nil
+}
+
+// {"action":"subscribe","fleet_id":"..."} moves the connection to another fleet, anything else is ignored
+func (handler *Handler) message(ctx context.Context, log *slog.Logger, request events.APIGatewayWebsocketProxyRequest) (events.APIGatewayProxyResponse, error) {
+	var msg clientMessage
+	if err := json.Unmarshal([]byte(request.Body), &msg); err != nil || msg.Action != "subscribe" {
+		log.Debug("ignoring websocket message", "body_size", len(request.Body))
+		return respond(http.StatusOK), nil
+	}
+
+	fleetID := strings.TrimSpace(msg.FleetID)
+	if fleetID == "" {
+		return respond(http.StatusBadRequest), nil
+	}
+
+	err := handler.Connections.SaveConnection(ctx, Connection{
+		ConnectionID: request.RequestContext.ConnectionID,
+		FleetID:      fleetID,
+	})
+	if err != nil {
+		log.Error("failed to update subscription", "fleet_id", fleetID, "error", err)
+		return respond(http.StatusInternalServerError), nil
+	}
+
+	log.Info("websocket subscribed", "fleet_id", fleetID)
+	return respond(http.StatusOK), nil
+}
+
+func respond(statusCode int) events.APIGatewayProxyResponse {
+	return events.APIGatewayProxyResponse{StatusCode: statusCode}
+}
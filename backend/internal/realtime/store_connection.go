@@ -0,0 +1,112 @@
+package realtime
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/attributevalue"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+
+	appconfig "github.com/Fleexa-Graduation-Project/Backend/pkg/config"
+	"github.com/Fleexa-Graduation-Project/Backend/pkg/db"
+	"github.com/Fleexa-Graduation-Project/Backend/pkg/timeout"
+)
+
+const (
+	fleetIndex = "FleetIndex"
+	// api gateway drops websocket connections after 2h anyway, the ttl only cleans up missed $disconnects
+	connectionTTL = 2 * time.Hour
+)
+
+// one item per open websocket connection, subscribed to a single fleet
+type Connection struct {
+	ConnectionID string `dynamodbav:"connection_id"`
+	FleetID      string `dynamodbav:"fleet_id"`
+	UserID       string `dynamodbav:"user_id,omitempty"`
+	ConnectedAt  int64  `dynamodbav:"connected_at"`
+	ExpiresAt    int64  `dynamodbav:"expires_at"`
+}
+
+type ConnectionStore struct {
+	Client    *dynamodb.Client
+	TableName string
+}
+
+func NewConnectionStore() (*ConnectionStore, error) {
+	tableName := appconfig.TableName("DYNAMODB_CONNECTIONS_TABLE")
+	if tableName == "" {
+		return nil, fmt.Errorf("DYNAMODB_CONNECTIONS_TABLE environment variable is not set")
+	}
+
+	if db.Client == nil {
+		return nil, fmt.Errorf("dynamodb client is not initialized")
+	}
+
+	return &ConnectionStore{
+		Client:    db.Client,
+		TableName: tableName,
+	}, nil
+}
+
+// saving again with another fleet moves the subscription
+func (store *ConnectionStore) SaveConnection(ctx context.Context, connection Connection) error {
+	if connection.ConnectedAt == 0 {
+		connection.ConnectedAt = time.Now().Unix()
+	}
+	connection.ExpiresAt = time.Now().Add(connectionTTL).Unix()
+
+	item, err := attributevalue.MarshalMap(connection)
+	if err != nil {
+		return fmt.Errorf("failed to marshal connection: %w", err)
+	}
+
+	callCtx, cancel := timeout.Call(ctx)
+	defer cancel()
+	_, err = store.Client.PutItem(callCtx, &dynamodb.PutItemInput{
+		TableName: aws.String(store.TableName),
+		Item:      item,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to save connection %s: %w", connection.ConnectionID, err)
+	}
+
+	return nil
+}
+
+func (store *ConnectionStore) DeleteConnection(ctx context.Context, connectionID string) error {
+	callCtx, cancel := timeout.Call(ctx)
+	defer cancel()
+	_, err := store.Client.DeleteItem(callCtx, &dynamodb.DeleteItemInput{
+		TableName: aws.String(store.TableName),
+		Key: map[string]types.AttributeValue{
+			"connection_id": &types.AttributeValueMemberS{Value: connectionID},
+		},
+	})
+	if err != nil {
+		return fmt.Errorf("failed to delete connection %s: %w", connectionID, err)
+	}
+
+	return nil
+}
+
+// every connection subscribed to the fleet, read from the FleetIndex gsi
+func (store *ConnectionStore) GetConnectionsByFleet(ctx context.Context, fleetID string) ([]Connection, error) {
+	input := &dynamodb.QueryInput{
+		TableName:              aws.String(store.TableName),
+		IndexName:              aws.String(fleetIndex),
+		KeyConditionExpression: aws.String("fleet_id = :fleet"),
+		ExpressionAttributeValues: map[string]types.AttributeValue{
+			":fleet": &types.AttributeValueMemberS{Value: fleetID},
+		},
+	}
+
+	connections, err := db.Collect(db.QueryAll[Connection](ctx, store.Client, input))
+	if err != nil {
+		return nil, fmt.Errorf("failed to query connections for fleet %s: %w", fleetID, err)
+	}
+
+	return connections, nil
+}
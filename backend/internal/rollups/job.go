@@ -0,0 +1,147 @@
+package rollups
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/Fleexa-Graduation-Project/Backend/internal/devices"
+	"github.com/Fleexa-Graduation-Project/Backend/internal/telemetry"
+	"github.com/Fleexa-Graduation-Project/Backend/pkg/connectivity"
+	"github.com/Fleexa-Graduation-Project/Backend/pkg/logger"
+	"github.com/Fleexa-Graduation-Project/Backend/pkg/rollup"
+)
+
+type Job struct {
+	StateStore     *devices.StateStore
+	DeviceStore    devices.Registry // reporting intervals for the connectivity score
+	TelemetryStore telemetry.Store
+	HourlyStore    *HourlyStore
+}
+
+// TargetHour is the hour a scheduled run rolls up: the one before the event time, or the
+// {"hour": "2024-02-20T13:00:00Z"} of the event detail to re-run a past hour
+func TargetHour(eventTime time.Time, detail json.RawMessage) (time.Time, error) {
+	var override struct {
+		Hour string `json:"hour"`
+	}
+	if len(detail) > 0 {
+		if err := json.Unmarshal(detail, &override); err != nil {
+			return time.Time{}, fmt.Errorf("invalid rollup event detail: %w", err)
+		}
+	}
+	if override.Hour != "" {
+		hour, err := time.Parse(time.RFC3339, override.Hour)
+		if err != nil {
+			return time.Time{}, fmt.Errorf("invalid rollup hour %q: %w", override.Hour, err)
+		}
+		return rollup.HourStart(hour), nil
+	}
+
+	if eventTime.IsZero() {
+		eventTime = time.Now()
+	}
+	return rollup.HourStart(eventTime).Add(-time.Hour), nil
+}
+
+// Run writes the hourly aggregate of every device that sent readings in the hour. Devices without
+// readings get no item. The connectivity score of each device is refreshed over the window
+// ending with the hour. A device that fails is logged and the others are still rolled up, the
+// run then returns an error so the schedule retries it, which overwrites the same items
+func (job *Job) Run(ctx context.Context, hour time.Time) error {
+	log := logger.FromContext(ctx)
+	hour = rollup.HourStart(hour)
+
+	states, err := job.StateStore.GetAllStates(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to list devices for rollup: %w", err)
+	}
+
+	written, empty, failed := 0, 0, 0
+	for _, state := range states {
+		if err := job.score(ctx, state.DeviceID, hour.Add(time.Hour)); err != nil {
+			log.Error("failed to score device connectivity", "device_id", state.DeviceID, "hour", hour.Unix(), "error", err)
+			failed++
+			continue
+		}
+
+		readings, err := job.readings(ctx, state.DeviceID, hour)
+		if err != nil {
+			log.Error("failed to fetch telemetry for rollup", "device_id", state.DeviceID, "hour", hour.Unix(), "error", err)
+			failed++
+			continue
+		}
+
+		hourly, ok := rollup.Aggregate(state.DeviceID, hour, readings)
+		if !ok {
+			empty++
+			continue
+		}
+		if err := job.HourlyStore.SaveHourly(ctx, hourly); err != nil {
+			log.Error("failed to save hourly aggregate", "device_id", state.DeviceID, "hour", hour.Unix(), "error", err)
+			failed++
+			continue
+		}
+		written++
+	}
+
+	log.Info("telemetry rollup complete", "hour", hour.Unix(), "devices", len(states), "written", written, "empty", empty, "failed", failed)
+	if failed > 0 {
+		return fmt.Errorf("telemetry rollup failed for %d of %d devices", failed, len(states))
+	}
+	return nil
+}
+
+// scores the window ending at end. A device registered within the window is scored from its
+// registration, one registered less than an interval ago isn't scored yet
+func (job *Job) score(ctx context.Context, deviceID string, end time.Time) error {
+	device, err := job.DeviceStore.GetDevice(ctx, deviceID)
+	if err != nil || device == nil {
+		return err
+	}
+
+	start := end.Add(-connectivity.Window())
+	if registered := time.Unix(device.CreatedAt, 0); registered.After(start) {
+		start = registered
+	}
+
+	readings, err := job.readingsBetween(ctx, deviceID, start, end.Add(-time.Second))
+	if err != nil {
+		return err
+	}
+	timestamps := make([]int64, 0, len(readings))
+	for _, reading := range readings {
+		timestamps = append(timestamps, reading.Timestamp)
+	}
+
+	score, ok := connectivity.Score(timestamps, connectivity.Interval(device.ReportInterval), start, end)
+	if !ok {
+		return nil
+	}
+	return job.StateStore.SetConnectivityScore(ctx, deviceID, score)
+}
+
+// every reading of the hour
+func (job *Job) readings(ctx context.Context, deviceID string, hour time.Time) ([]rollup.Reading, error) {
+	return job.readingsBetween(ctx, deviceID, hour, hour.Add(time.Hour-time.Second))
+}
+
+// every reading from from to to, both inclusive, following the query cursor
+func (job *Job) readingsBetween(ctx context.Context, deviceID string, from, to time.Time) ([]rollup.Reading, error) {
+	var readings []rollup.Reading
+	cursor := ""
+	for {
+		page, err := job.TelemetryStore.QueryTelemetry(ctx, deviceID, from, to, telemetry.MaxQueryLimit, cursor)
+		if err != nil {
+			return nil, err
+		}
+		for _, reading := range page.Readings {
+			readings = append(readings, rollup.Reading{Timestamp: reading.Timestamp, Payload: reading.Payload})
+		}
+		if page.NextCursor == "" {
+			return readings, nil
+		}
+		cursor = page.NextCursor
+	}
+}
@@ -0,0 +1,108 @@
+package rollups
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/attributevalue"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+
+	appconfig "github.com/Fleexa-Graduation-Project/Backend/pkg/config"
+	"github.com/Fleexa-Graduation-Project/Backend/pkg/db"
+	"github.com/Fleexa-Graduation-Project/Backend/pkg/rollup"
+	"github.com/Fleexa-Graduation-Project/Backend/pkg/timeout"
+)
+
+// one item per device and hour, keyed device_id (HASH) + hour_start (RANGE)
+type HourlyStore struct {
+	Client    *dynamodb.Client
+	TableName string
+}
+
+// one page of hourly aggregates in chronological order, NextCursor is empty on the last page
+type HourlyPage struct {
+	Resolution string          `json:"resolution"`
+	Aggregates []rollup.Hourly `json:"data"`
+	NextCursor string          `json:"next_cursor,omitempty"`
+}
+
+func NewHourlyStore() (*HourlyStore, error) {
+	tableName := appconfig.TableName("DYNAMODB_HOURLY_AGGREGATES_TABLE")
+	if tableName == "" {
+		return nil, fmt.Errorf("DYNAMODB_HOURLY_AGGREGATES_TABLE environment variable is not set")
+	}
+
+	if db.Client == nil {
+		return nil, fmt.Errorf("dynamodb client is not initialized")
+	}
+
+	return &HourlyStore{
+		Client:    db.Client,
+		TableName: tableName,
+	}, nil
+}
+
+// SaveHourly overwrites the aggregate of the same device and hour, so re-running an hour is safe
+func (store *HourlyStore) SaveHourly(ctx context.Context, hourly rollup.Hourly) error {
+	item, err := attributevalue.MarshalMap(hourly)
+	if err != nil {
+		return fmt.Errorf("failed to marshal hourly aggregate: %w", err)
+	}
+
+	err = db.Retry(ctx, db.DefaultRetryPolicy, func() error {
+		callCtx, cancel := timeout.Call(ctx)
+		defer cancel()
+		_, putErr := store.Client.PutItem(callCtx, &dynamodb.PutItemInput{
+			TableName: aws.String(store.TableName),
+			Item:      item,
+		}, db.SingleAttempt)
+		return putErr
+	})
+	if err != nil {
+		return fmt.Errorf("failed to store hourly aggregate for device %s: %w", hourly.DeviceID, err)
+	}
+
+	return nil
+}
+
+// QueryHourly returns the aggregates of the hours starting in from..to, oldest first
+func (store *HourlyStore) QueryHourly(ctx context.Context, deviceID string, from, to time.Time, limit int32, cursor string) (HourlyPage, error) {
+	startKey, err := db.DecodeCursor(cursor)
+	if err != nil {
+		return HourlyPage{}, err
+	}
+
+	input := &dynamodb.QueryInput{
+		TableName:              aws.String(store.TableName),
+		KeyConditionExpression: aws.String("device_id = :id AND hour_start BETWEEN :from AND :to"),
+		ExpressionAttributeValues: map[string]types.AttributeValue{
+			":id":   &types.AttributeValueMemberS{Value: deviceID},
+			":from": &types.AttributeValueMemberN{Value: fmt.Sprint(rollup.HourStart(from).Unix())},
+			":to":   &types.AttributeValueMemberN{Value: fmt.Sprint(to.Unix())},
+		},
+		ScanIndexForward:  aws.Bool(true),
+		Limit:             aws.Int32(limit),
+		ExclusiveStartKey: startKey,
+	}
+
+	callCtx, cancel := timeout.Call(ctx)
+	defer cancel()
+	result, err := store.Client.Query(callCtx, input)
+	if err != nil {
+		return HourlyPage{}, fmt.Errorf("failed to query hourly aggregates for device %s: %w", deviceID, err)
+	}
+
+	page := HourlyPage{Resolution: "hourly", Aggregates: []rollup.Hourly{}}
+	if err = attributevalue.UnmarshalListOfMaps(result.Items, &page.Aggregates); err != nil {
+		return HourlyPage{}, fmt.Errorf("failed to unmarshal hourly aggregates for device %s: %w", deviceID, err)
+	}
+
+	if page.NextCursor, err = db.EncodeCursor(result.LastEvaluatedKey); err != nil {
+		return HourlyPage{}, err
+	}
+
+	return page, nil
+}
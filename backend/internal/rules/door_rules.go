@@ -78,22 +78,27 @@ func (engine *AlertEngine) CheckDoorTimeouts(ctx context.Context) {
 }
 
 func (engine *AlertEngine) triggerDoorAlert(ctx context.Context, deviceID string, deviceType string, severity string, description string) {
+	allowed, suppressed := engine.allow(ctx, deviceID, deviceType, severity)
+	if !allowed {
+		return
+	}
+	payload := map[string]interface{}{}
+	description = withSuppressed(description, payload, suppressed)
+	payload["description"] = description
 
 	err := engine.alertStore.SaveAlert(ctx, models.Alert{
 		DeviceID:  deviceID,
 		Type:      deviceType, 
 		Severity:  severity,
 		Timestamp: time.Now().Unix(),
-		Payload: map[string]interface{}{
-			"description": description,
-		},
+		Payload:   payload,
 	})
 
 	if err == nil {
 		slog.Warn("Door Security Event Logged", "device_id", deviceID, "severity", severity)
 		
 		// send notification to app
-		engine.notifier.SendPushNotification(deviceID, severity, description)
+		engine.notifier.SendPushNotification(ctx, deviceID, severity, description)
 	} else {
 		slog.Error("failed to save door alert to db", "error", err)
 	}
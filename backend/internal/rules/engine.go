@@ -1,6 +1,10 @@
 package rules
 
 import (
+	"context"
+	"fmt"
+	"log/slog"
+
 	"github.com/Fleexa-Graduation-Project/Backend/internal/alerts"
 	"github.com/Fleexa-Graduation-Project/Backend/internal/devices"
 	"github.com/Fleexa-Graduation-Project/Backend/internal/notifications"
@@ -10,6 +14,8 @@ type AlertEngine struct {
 	alertStore *alerts.AlertStore
 	stateStore *devices.StateStore 
 	notifier   *notifications.Service 
+
+	Throttle *alerts.Throttle // optional, nil raises every alert
 }
 
 func NewAlertEngine(alertStore *alerts.AlertStore, stateStore *devices.StateStore, notifier *notifications.Service) *AlertEngine {
@@ -20,3 +26,24 @@ func NewAlertEngine(alertStore *alerts.AlertStore, stateStore *devices.StateStor
 	}
 }
 
+// allow asks the throttle whether the alert goes out, a throttle that can't be read lets it
+// through. suppressed is how many repeats were held back before this one
+func (engine *AlertEngine) allow(ctx context.Context, deviceID, alertType, episode string) (bool, int) {
+	allowed, suppressed, err := engine.Throttle.Allow(ctx, deviceID, alertType, episode)
+	if err != nil {
+		slog.Warn("alert throttle unavailable", "device_id", deviceID, "alert_type", alertType, "error", err)
+	}
+	if !allowed {
+		slog.Info("alert suppressed", "reason", "alert_throttled", "device_id", deviceID, "alert_type", alertType, "episode", episode)
+	}
+	return allowed, suppressed
+}
+
+// the repeats held back since the last alert are folded into the next one
+func withSuppressed(description string, payload map[string]interface{}, suppressed int) string {
+	if suppressed == 0 {
+		return description
+	}
+	payload["suppressed_count"] = suppressed
+	return fmt.Sprintf("%s (%d similar alerts suppressed)", description, suppressed)
+}
@@ -30,16 +30,22 @@ func (engine *AlertEngine) HandleGas(ctx context.Context, deviceID string, paylo
 			description = "Gas spike detected"
 		}
 
+		// every reading over the limit would alert, a rise to CRITICAL still goes out at once
+		allowed, suppressed := engine.allow(ctx, deviceID, "gas-sensor", severity)
+		if !allowed {
+			return
+		}
+		payload := map[string]interface{}{"gas_level": ppmLevel}
+		description = withSuppressed(description, payload, suppressed)
+		payload["description"] = description
+
 		// save the alert to db with context
 		err := engine.alertStore.SaveAlert(ctx, models.Alert{
 			DeviceID:  deviceID,
 			Type:      "gas-sensor",
 			Severity:  severity,
 			Timestamp: time.Now().Unix(),
-			Payload: map[string]interface{}{
-				"description": description,
-				"gas_level":   ppmLevel,
-			},
+			Payload:   payload,
 		})
 
 		if err != nil {
@@ -47,7 +53,7 @@ func (engine *AlertEngine) HandleGas(ctx context.Context, deviceID string, paylo
 		} else {
 			slog.Error("gas alert triggered!", "device_id", deviceID, "severity", severity)
 			// send push notification
-			engine.notifier.SendPushNotification(deviceID, "Gas Alert", description)
+			engine.notifier.SendPushNotification(ctx, deviceID, "Gas Alert", description)
 		}
 	}
 }
\ No newline at end of file
@@ -0,0 +1,62 @@
+package rules
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/Fleexa-Graduation-Project/Backend/models"
+	"github.com/Fleexa-Graduation-Project/Backend/pkg/geo"
+)
+
+// extracting a gps fix from the payload, ok is false when the reading carries none
+func GPSFromPayload(payload map[string]interface{}) (geo.Coord, bool) {
+	lat, latOK := payload["lat"].(float64)
+	lon, lonOK := payload["lon"].(float64)
+	if !latOK || !lonOK {
+		return geo.Coord{}, false
+	}
+	if !geo.ValidCoord(lat, lon) {
+		return geo.Coord{}, false
+	}
+	return geo.Coord{Lat: lat, Lon: lon}, true
+}
+
+// raises a geofence_breach alert for every keep-in fence the position falls outside of
+func (engine *AlertEngine) HandleGeofences(ctx context.Context, deviceID string, position geo.Coord, fences []geo.Geofence) {
+	for _, fence := range fences {
+		if fence.Contains(position) {
+			continue
+		}
+
+		allowed, suppressed := engine.allow(ctx, deviceID, "geofence_breach", fence.ID)
+		if !allowed {
+			continue
+		}
+
+		payload := map[string]interface{}{
+			"geofence_id": fence.ID,
+			"lat":         position.Lat,
+			"lon":         position.Lon,
+		}
+		description := withSuppressed(fmt.Sprintf("Device left geofence %s", fence.ID), payload, suppressed)
+		payload["description"] = description
+		err := engine.alertStore.SaveAlert(ctx, models.Alert{
+			DeviceID:  deviceID,
+			Type:      "geofence_breach",
+			Severity:  "WARNING",
+			Timestamp: time.Now().Unix(),
+			Payload:   payload,
+		})
+		if err != nil {
+			slog.Error("failed to save geofence alert to db", "device_id", deviceID, "geofence_id", fence.ID, "error", err)
+			continue
+		}
+
+		slog.Warn("geofence breach", "device_id", deviceID, "geofence_id", fence.ID)
+		if engine.notifier != nil {
+			engine.notifier.SendPushNotification(ctx, deviceID, "Geofence breach", description)
+		}
+	}
+}
@@ -0,0 +1,234 @@
+package rules
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/sns"
+	snstypes "github.com/aws/aws-sdk-go-v2/service/sns/types"
+
+	"github.com/Fleexa-Graduation-Project/Backend/internal/devices"
+	"github.com/Fleexa-Graduation-Project/Backend/models"
+	"github.com/Fleexa-Graduation-Project/Backend/pkg/timeout"
+)
+
+const DefaultOfflineAfter = 10 * time.Minute
+
+// how long a device may stay silent before the offline alert, per fleet with a global default
+type OfflinePolicy struct {
+	Default time.Duration
+	Fleets  map[string]time.Duration
+}
+
+func (policy OfflinePolicy) thresholdFor(fleetID string) time.Duration {
+	if threshold, ok := policy.Fleets[fleetID]; ok {
+		return threshold
+	}
+	return policy.Default
+}
+
+// OFFLINE_THRESHOLD="10m" sets the default, OFFLINE_THRESHOLD_FLEETS="home-01=5m,farm-02=1h" the overrides
+func LoadOfflinePolicy() (OfflinePolicy, error) {
+	policy := OfflinePolicy{Default: DefaultOfflineAfter, Fleets: map[string]time.Duration{}}
+
+	if raw := os.Getenv("OFFLINE_THRESHOLD"); raw != "" {
+		threshold, err := time.ParseDuration(raw)
+		if err != nil || threshold <= 0 {
+			return policy, fmt.Errorf("invalid OFFLINE_THRESHOLD %q", raw)
+		}
+		policy.Default = threshold
+	}
+
+	for _, pair := range strings.Split(os.Getenv("OFFLINE_THRESHOLD_FLEETS"), ",") {
+		if pair = strings.TrimSpace(pair); pair == "" {
+			continue
+		}
+		fleetID, raw, ok := strings.Cut(pair, "=")
+		threshold, err := time.ParseDuration(strings.TrimSpace(raw))
+		if !ok || err != nil || threshold <= 0 {
+			return policy, fmt.Errorf("invalid OFFLINE_THRESHOLD_FLEETS entry %q", pair)
+		}
+		policy.Fleets[strings.TrimSpace(fleetID)] = threshold
+	}
+
+	return policy, nil
+}
+
+// OfflineTopic publishes device_offline alerts to the sns topic in OFFLINE_ALERT_TOPIC_ARN
+type OfflineTopic struct {
+	Client   *sns.Client
+	TopicARN string
+}
+
+func NewOfflineTopic(client *sns.Client) (*OfflineTopic, error) {
+	topicARN := os.Getenv("OFFLINE_ALERT_TOPIC_ARN")
+	if topicARN == "" {
+		return nil, fmt.Errorf("OFFLINE_ALERT_TOPIC_ARN environment variable is not set")
+	}
+
+	return &OfflineTopic{
+		Client:   client,
+		TopicARN: topicARN,
+	}, nil
+}
+
+// the json message subscribers get, fleet_id is also a message attribute for subscription filters
+type offlineMessage struct {
+	Type       string `json:"type"`
+	DeviceID   string `json:"device_id"`
+	FleetID    string `json:"fleet_id"`
+	LastSeenAt int64  `json:"last_seen_at"` // epoch seconds
+	LastSeen   string `json:"last_seen"`    // the same time in RFC 3339
+}
+
+func (topic *OfflineTopic) Publish(ctx context.Context, deviceID, fleetID string, lastSeenAt int64) error {
+	message, err := json.Marshal(offlineMessage{
+		Type:       "device_offline",
+		DeviceID:   deviceID,
+		FleetID:    fleetID,
+		LastSeenAt: lastSeenAt,
+		LastSeen:   time.Unix(lastSeenAt, 0).UTC().Format(time.RFC3339),
+	})
+	if err != nil {
+		return fmt.Errorf("failed to encode offline alert: %w", err)
+	}
+
+	input := &sns.PublishInput{
+		TopicArn: aws.String(topic.TopicARN),
+		Subject:  aws.String("Device offline"),
+		Message:  aws.String(string(message)),
+	}
+	if fleetID != "" {
+		input.MessageAttributes = map[string]snstypes.MessageAttributeValue{
+			"fleet_id": {DataType: aws.String("String"), StringValue: aws.String(fleetID)},
+		}
+	}
+
+	callCtx, cancel := timeout.Call(ctx)
+	defer cancel()
+	if _, err := topic.Client.Publish(callCtx, input); err != nil {
+		return fmt.Errorf("failed to publish to %s: %w", topic.TopicARN, err)
+	}
+	return nil
+}
+
+type OfflineMonitor struct {
+	engine      *AlertEngine
+	deviceStore devices.Registry
+	policy      OfflinePolicy
+
+	Topic *OfflineTopic // optional, nil only stores and pushes the alerts
+}
+
+func NewOfflineMonitor(engine *AlertEngine, deviceStore devices.Registry, policy OfflinePolicy) *OfflineMonitor {
+	return &OfflineMonitor{
+		engine:      engine,
+		deviceStore: deviceStore,
+		policy:      policy,
+	}
+}
+
+// EventBridge cron: alerts once for every device silent for longer than its fleet threshold,
+// the flag is cleared by the next telemetry or heartbeat
+func (monitor *OfflineMonitor) CheckOfflineDevices(ctx context.Context) error {
+	states, err := monitor.engine.stateStore.GetAllStates(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to fetch states for offline check: %w", err)
+	}
+
+	now := time.Now()
+	alerted := 0
+
+	for _, state := range states {
+		if state.OfflineAlertedAt != 0 || state.LastSeenAt == 0 {
+			continue
+		}
+
+		// cheap pre-check with the smallest possible threshold before looking the fleet up
+		silentFor := now.Sub(time.Unix(state.LastSeenAt, 0))
+		if silentFor < monitor.policy.minThreshold() {
+			continue
+		}
+
+		fleetID := ""
+		device, err := monitor.deviceStore.GetDevice(ctx, state.DeviceID)
+		if err != nil {
+			slog.Warn("failed to look up device fleet", "device_id", state.DeviceID, "error", err)
+		} else if device != nil {
+			fleetID = device.FleetID
+		}
+
+		if silentFor < monitor.policy.thresholdFor(fleetID) {
+			continue
+		}
+
+		flagged, err := monitor.engine.stateStore.MarkOfflineAlerted(ctx, state.DeviceID, state.LastSeenAt)
+		if err != nil {
+			slog.Error("failed to flag device offline", "device_id", state.DeviceID, "error", err)
+			continue
+		}
+		if !flagged {
+			continue // reported again or another run got there first
+		}
+
+		monitor.triggerOfflineAlert(ctx, state, fleetID)
+		alerted++
+	}
+
+	slog.Info("offline check complete", "devices", len(states), "alerted", alerted)
+	return nil
+}
+
+func (policy OfflinePolicy) minThreshold() time.Duration {
+	min := policy.Default
+	for _, threshold := range policy.Fleets {
+		if threshold < min {
+			min = threshold
+		}
+	}
+	return min
+}
+
+func (monitor *OfflineMonitor) triggerOfflineAlert(ctx context.Context, state models.DeviceState, fleetID string) {
+	// each outage is its own episode, a device that came back and dropped again alerts again
+	allowed, suppressed := monitor.engine.allow(ctx, state.DeviceID, "device_offline", fmt.Sprint(state.LastSeenAt))
+	if !allowed {
+		return
+	}
+
+	payload := map[string]interface{}{
+		"fleet_id":     fleetID,
+		"last_seen_at": state.LastSeenAt,
+	}
+	description := withSuppressed(fmt.Sprintf("Device stopped reporting, last seen %s", time.Unix(state.LastSeenAt, 0).UTC().Format(time.RFC3339)), payload, suppressed)
+	payload["description"] = description
+
+	err := monitor.engine.alertStore.SaveAlert(ctx, models.Alert{
+		DeviceID:  state.DeviceID,
+		Type:      "device_offline",
+		Severity:  "WARNING",
+		Timestamp: time.Now().Unix(),
+		Payload:   payload,
+	})
+	if err != nil {
+		slog.Error("failed to save offline alert to db", "device_id", state.DeviceID, "error", err)
+		return
+	}
+
+	slog.Warn("device offline alert", "device_id", state.DeviceID, "fleet_id", fleetID, "last_seen_at", state.LastSeenAt)
+	if monitor.Topic != nil {
+		// the alert is stored and the device flagged, a failed publish is not retried next run
+		if err := monitor.Topic.Publish(ctx, state.DeviceID, fleetID, state.LastSeenAt); err != nil {
+			slog.Error("failed to publish offline alert", "device_id", state.DeviceID, "error", err)
+		}
+	}
+	if monitor.engine.notifier != nil {
+		monitor.engine.notifier.SendPushNotification(ctx, state.DeviceID, "Device offline", description)
+	}
+}
@@ -0,0 +1,186 @@
+package rules
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"os"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/Fleexa-Graduation-Project/Backend/models"
+)
+
+// the payload fields watched for low levels, in percent
+var ResourceMetrics = []string{"battery", "fuel"}
+
+// Low raises the alert when a reading drops below it, Reset re-arms the alert once the value is
+// back at or above it. The gap between the two keeps a value hovering around Low from alerting
+// on every reading
+type ResourceThreshold struct {
+	Low   float64 `json:"low"`
+	Reset float64 `json:"reset"`
+}
+
+// thresholds per metric, per fleet with a global default. A metric missing from both isn't checked
+type ResourcePolicy struct {
+	Default map[string]ResourceThreshold
+	Fleets  map[string]map[string]ResourceThreshold
+}
+
+func DefaultResourcePolicy() ResourcePolicy {
+	return ResourcePolicy{
+		Default: map[string]ResourceThreshold{
+			"battery": {Low: 20, Reset: 30},
+			"fuel":    {Low: 15, Reset: 25},
+		},
+		Fleets: map[string]map[string]ResourceThreshold{},
+	}
+}
+
+func (policy ResourcePolicy) thresholdFor(fleetID, metric string) (ResourceThreshold, bool) {
+	if threshold, ok := policy.Fleets[fleetID][metric]; ok {
+		return threshold, true
+	}
+	threshold, ok := policy.Default[metric]
+	return threshold, ok
+}
+
+// LOW_RESOURCE_THRESHOLDS='{"battery":{"low":20,"reset":30}}' replaces the default of a metric,
+// LOW_RESOURCE_FLEET_THRESHOLDS='{"fleet-a":{"fuel":{"low":10,"reset":20}}}' sets fleet overrides
+func LoadResourcePolicy() (ResourcePolicy, error) {
+	policy := DefaultResourcePolicy()
+
+	if raw := os.Getenv("LOW_RESOURCE_THRESHOLDS"); raw != "" {
+		var defaults map[string]ResourceThreshold
+		if err := json.Unmarshal([]byte(raw), &defaults); err != nil {
+			return policy, fmt.Errorf("invalid LOW_RESOURCE_THRESHOLDS: %w", err)
+		}
+		for metric, threshold := range defaults {
+			if err := validThreshold(metric, threshold); err != nil {
+				return policy, fmt.Errorf("invalid LOW_RESOURCE_THRESHOLDS: %w", err)
+			}
+			policy.Default[metric] = threshold
+		}
+	}
+
+	if raw := os.Getenv("LOW_RESOURCE_FLEET_THRESHOLDS"); raw != "" {
+		if err := json.Unmarshal([]byte(raw), &policy.Fleets); err != nil {
+			return policy, fmt.Errorf("invalid LOW_RESOURCE_FLEET_THRESHOLDS: %w", err)
+		}
+		for fleetID, thresholds := range policy.Fleets {
+			for metric, threshold := range thresholds {
+				if err := validThreshold(metric, threshold); err != nil {
+					return policy, fmt.Errorf("invalid LOW_RESOURCE_FLEET_THRESHOLDS for %s: %w", fleetID, err)
+				}
+			}
+		}
+	}
+
+	return policy, nil
+}
+
+func validThreshold(metric string, threshold ResourceThreshold) error {
+	if !slices.Contains(ResourceMetrics, metric) {
+		return fmt.Errorf("unknown metric %q", metric)
+	}
+	if threshold.Reset <= threshold.Low {
+		return fmt.Errorf("%s reset %v must be above low %v", metric, threshold.Reset, threshold.Low)
+	}
+	return nil
+}
+
+type ResourceMonitor struct {
+	engine *AlertEngine
+	policy ResourcePolicy
+
+	// device_id#metric -> alert fired, as last seen by this container. Saves the conditional
+	// write on every healthy reading once a device is known not to be flagged
+	flagged sync.Map
+}
+
+func NewResourceMonitor(engine *AlertEngine, policy ResourcePolicy) *ResourceMonitor {
+	return &ResourceMonitor{engine: engine, policy: policy}
+}
+
+// HandleReading raises one low_resource alert when battery or fuel drops below the fleet's
+// threshold, and re-arms it after the value recovers above the reset threshold. Readings
+// without the metrics are skipped
+func (monitor *ResourceMonitor) HandleReading(ctx context.Context, deviceID, fleetID string, payload map[string]interface{}) {
+	for _, metric := range ResourceMetrics {
+		value, ok := payload[metric].(float64)
+		if !ok {
+			continue
+		}
+		threshold, ok := monitor.policy.thresholdFor(fleetID, metric)
+		if !ok {
+			continue
+		}
+
+		key := deviceID + "#" + metric
+		switch {
+		case value < threshold.Low:
+			if known, ok := monitor.flagged.Load(key); ok && known.(bool) {
+				continue
+			}
+			fired, err := monitor.engine.stateStore.MarkLowResourceAlerted(ctx, deviceID, metric)
+			if err != nil {
+				slog.Error("failed to flag low resource", "device_id", deviceID, "metric", metric, "error", err)
+				continue
+			}
+			monitor.flagged.Store(key, true)
+			if fired {
+				monitor.triggerLowResourceAlert(ctx, deviceID, fleetID, metric, value, threshold)
+			}
+
+		case value >= threshold.Reset:
+			if known, ok := monitor.flagged.Load(key); ok && !known.(bool) {
+				continue
+			}
+			cleared, err := monitor.engine.stateStore.ClearLowResourceAlert(ctx, deviceID, metric)
+			if err != nil {
+				slog.Warn("failed to clear low resource flag", "device_id", deviceID, "metric", metric, "error", err)
+				continue
+			}
+			monitor.flagged.Store(key, false)
+			if cleared {
+				slog.Info("low resource recovered", "device_id", deviceID, "metric", metric, "value", value, "reset", threshold.Reset)
+			}
+		}
+	}
+}
+
+func (monitor *ResourceMonitor) triggerLowResourceAlert(ctx context.Context, deviceID, fleetID, metric string, value float64, threshold ResourceThreshold) {
+	allowed, suppressed := monitor.engine.allow(ctx, deviceID, "low_resource", metric)
+	if !allowed {
+		return
+	}
+
+	payload := map[string]interface{}{
+		"fleet_id":  fleetID,
+		"metric":    metric,
+		"value":     value,
+		"threshold": threshold.Low,
+	}
+	description := withSuppressed(fmt.Sprintf("Low %s: %.0f%% (threshold %.0f%%)", metric, value, threshold.Low), payload, suppressed)
+	payload["description"] = description
+
+	err := monitor.engine.alertStore.SaveAlert(ctx, models.Alert{
+		DeviceID:  deviceID,
+		Type:      "low_resource",
+		Severity:  "WARNING",
+		Timestamp: time.Now().Unix(),
+		Payload:   payload,
+	})
+	if err != nil {
+		slog.Error("failed to save low resource alert to db", "device_id", deviceID, "metric", metric, "error", err)
+		return
+	}
+
+	slog.Warn("low resource alert", "device_id", deviceID, "fleet_id", fleetID, "metric", metric, "value", value, "threshold", threshold.Low)
+	if monitor.engine.notifier != nil {
+		monitor.engine.notifier.SendPushNotification(ctx, deviceID, "Low "+metric, description)
+	}
+}
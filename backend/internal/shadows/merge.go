@@ -0,0 +1,57 @@
+package shadows
+
+import "reflect"
+
+// Merge deep merges patch into state and returns the result, state is not modified. Nested
+// objects are merged key by key, a null value removes the key, any other value replaces it
+func Merge(state, patch map[string]interface{}) map[string]interface{} {
+	merged := make(map[string]interface{}, len(state)+len(patch))
+	for key, value := range state {
+		merged[key] = value
+	}
+
+	for key, value := range patch {
+		if value == nil {
+			delete(merged, key)
+			continue
+		}
+
+		nestedPatch, patchIsObject := value.(map[string]interface{})
+		nestedState, stateIsObject := merged[key].(map[string]interface{})
+		switch {
+		case patchIsObject && stateIsObject:
+			merged[key] = Merge(nestedState, nestedPatch)
+		case patchIsObject:
+			// nulls inside a new object have nothing to remove
+			merged[key] = Merge(nil, nestedPatch)
+		default:
+			merged[key] = value
+		}
+	}
+
+	return merged
+}
+
+// Delta is the part of desired the device hasn't reported yet: every key whose desired value
+// differs from the reported one, nested objects compared key by key. Keys only in reported are
+// not part of it
+func Delta(desired, reported map[string]interface{}) map[string]interface{} {
+	delta := map[string]interface{}{}
+	for key, want := range desired {
+		have, ok := reported[key]
+
+		wantObject, wantIsObject := want.(map[string]interface{})
+		haveObject, haveIsObject := have.(map[string]interface{})
+		if ok && wantIsObject && haveIsObject {
+			if nested := Delta(wantObject, haveObject); len(nested) > 0 {
+				delta[key] = nested
+			}
+			continue
+		}
+
+		if !ok || !reflect.DeepEqual(want, have) {
+			delta[key] = want
+		}
+	}
+	return delta
+}
@@ -0,0 +1,184 @@
+package shadows
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/attributevalue"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+
+	appconfig "github.com/Fleexa-Graduation-Project/Backend/pkg/config"
+	"github.com/Fleexa-Graduation-Project/Backend/pkg/db"
+	"github.com/Fleexa-Graduation-Project/Backend/pkg/timeout"
+)
+
+// a reported update that lost the race to another writer is retried this many times
+const maxReportAttempts = 3
+
+// ErrVersionConflict is returned when the shadow changed since the version the caller read
+var ErrVersionConflict = errors.New("shadow version conflict")
+
+// the state the operators want a device in (desired) next to the last state it sent (reported).
+// Version goes up by one on every write, each write is conditional on the version it read
+type Shadow struct {
+	DeviceID  string                 `json:"device_id" dynamodbav:"device_id"`
+	Desired   map[string]interface{} `json:"desired" dynamodbav:"desired"`
+	Reported  map[string]interface{} `json:"reported" dynamodbav:"reported"`
+	Version   int64                  `json:"version" dynamodbav:"version"`
+	UpdatedAt int64                  `json:"updated_at" dynamodbav:"updated_at"`
+}
+
+func (shadow Shadow) Delta() map[string]interface{} {
+	return Delta(shadow.Desired, shadow.Reported)
+}
+
+// one item per device, keyed device_id (HASH)
+type ShadowStore struct {
+	Client    *dynamodb.Client
+	TableName string
+}
+
+func NewShadowStore() (*ShadowStore, error) {
+	tableName := appconfig.TableName("DYNAMODB_SHADOWS_TABLE")
+	if tableName == "" {
+		return nil, fmt.Errorf("DYNAMODB_SHADOWS_TABLE environment variable is not set")
+	}
+
+	if db.Client == nil {
+		return nil, fmt.Errorf("dynamodb client is not initialized")
+	}
+
+	return &ShadowStore{
+		Client:    db.Client,
+		TableName: tableName,
+	}, nil
+}
+
+// GetShadow returns nil when the device has no shadow yet
+func (store *ShadowStore) GetShadow(ctx context.Context, deviceID string) (*Shadow, error) {
+	callCtx, cancel := timeout.Call(ctx)
+	defer cancel()
+	result, err := store.Client.GetItem(callCtx, &dynamodb.GetItemInput{
+		TableName:      aws.String(store.TableName),
+		Key:            map[string]types.AttributeValue{"device_id": &types.AttributeValueMemberS{Value: deviceID}},
+		ConsistentRead: aws.Bool(true),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to get shadow of device %s: %w", deviceID, err)
+	}
+	if result.Item == nil {
+		return nil, nil
+	}
+
+	var shadow Shadow
+	if err := attributevalue.UnmarshalMap(result.Item, &shadow); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal shadow of device %s: %w", deviceID, err)
+	}
+	if shadow.Desired == nil {
+		shadow.Desired = map[string]interface{}{}
+	}
+	if shadow.Reported == nil {
+		shadow.Reported = map[string]interface{}{}
+	}
+	return &shadow, nil
+}
+
+// UpdateDesired deep merges patch into the desired state. expectedVersion is the version the
+// caller based the patch on, ErrVersionConflict when another write came first. 0 skips the
+// check against the caller, the write is still conditional on the version read here
+func (store *ShadowStore) UpdateDesired(ctx context.Context, deviceID string, patch map[string]interface{}, expectedVersion int64) (Shadow, error) {
+	current, err := store.GetShadow(ctx, deviceID)
+	if err != nil {
+		return Shadow{}, err
+	}
+	if current == nil {
+		current = &Shadow{DeviceID: deviceID, Desired: map[string]interface{}{}, Reported: map[string]interface{}{}}
+	}
+	if expectedVersion > 0 && expectedVersion != current.Version {
+		return Shadow{}, fmt.Errorf("%w: device %s is at version %d, not %d", ErrVersionConflict, deviceID, current.Version, expectedVersion)
+	}
+
+	next := *current
+	next.Desired = Merge(current.Desired, patch)
+	if err := store.put(ctx, &next, current.Version); err != nil {
+		return Shadow{}, err
+	}
+	return next, nil
+}
+
+// UpdateReported deep merges what the device sent into the reported state of its shadow.
+// Devices without a shadow are skipped, as are updates that change nothing, false then
+func (store *ShadowStore) UpdateReported(ctx context.Context, deviceID string, patch map[string]interface{}) (bool, error) {
+	for attempt := 1; ; attempt++ {
+		current, err := store.GetShadow(ctx, deviceID)
+		if err != nil || current == nil {
+			return false, err
+		}
+
+		next := *current
+		next.Reported = Merge(current.Reported, patch)
+		if reflect.DeepEqual(next.Reported, current.Reported) {
+			return false, nil
+		}
+
+		err = store.put(ctx, &next, current.Version)
+		if errors.Is(err, ErrVersionConflict) && attempt < maxReportAttempts {
+			continue
+		}
+		return err == nil, err
+	}
+}
+
+// writes shadow as version expected+1, only if the stored one is still at expected
+func (store *ShadowStore) put(ctx context.Context, shadow *Shadow, expected int64) error {
+	shadow.Version = expected + 1
+	shadow.UpdatedAt = time.Now().Unix()
+
+	item, err := attributevalue.MarshalMap(shadow)
+	if err != nil {
+		return fmt.Errorf("failed to marshal shadow: %w", err)
+	}
+
+	input := &dynamodb.PutItemInput{
+		TableName:           aws.String(store.TableName),
+		Item:                item,
+		ConditionExpression: aws.String("attribute_not_exists(device_id)"),
+	}
+	if expected > 0 {
+		input.ConditionExpression = aws.String("#version = :expected")
+		input.ExpressionAttributeNames = map[string]string{"#version": "version"}
+		input.ExpressionAttributeValues = map[string]types.AttributeValue{
+			":expected": &types.AttributeValueMemberN{Value: fmt.Sprint(expected)},
+		}
+	}
+
+	callCtx, cancel := timeout.Call(ctx)
+	defer cancel()
+	if _, err := store.Client.PutItem(callCtx, input); err != nil {
+		var conditionErr *types.ConditionalCheckFailedException
+		if errors.As(err, &conditionErr) {
+			return fmt.Errorf("%w: device %s changed since version %d", ErrVersionConflict, shadow.DeviceID, expected)
+		}
+		return fmt.Errorf("failed to store shadow of device %s: %w", shadow.DeviceID, err)
+	}
+	return nil
+}
+
+// Delete removes the shadow of a device, a device without one is not an error
+func (store *ShadowStore) Delete(ctx context.Context, deviceID string) error {
+	callCtx, cancel := timeout.Call(ctx)
+	defer cancel()
+	_, err := store.Client.DeleteItem(callCtx, &dynamodb.DeleteItemInput{
+		TableName: aws.String(store.TableName),
+		Key:       map[string]types.AttributeValue{"device_id": &types.AttributeValueMemberS{Value: deviceID}},
+	})
+	if err != nil {
+		return fmt.Errorf("failed to delete shadow of device %s: %w", deviceID, err)
+	}
+	return nil
+}
@@ -0,0 +1,170 @@
+package telemetry
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+
+	"github.com/Fleexa-Graduation-Project/Backend/models"
+	"github.com/Fleexa-Graduation-Project/Backend/pkg/timeout"
+)
+
+const (
+	archivePrefix   = "raw-telemetry"
+	UnassignedFleet = "unassigned" // devices missing from the registry
+)
+
+// Archive keeps a copy of the stored readings in s3 as newline-delimited json for athena,
+// dynamodb only holds them for the dedup ttl
+type Archive struct {
+	Client *s3.Client
+	Bucket string
+}
+
+func NewArchive(client *s3.Client) (*Archive, error) {
+	bucket := os.Getenv("TELEMETRY_ARCHIVE_BUCKET")
+	if bucket == "" {
+		return nil, fmt.Errorf("TELEMETRY_ARCHIVE_BUCKET environment variable is not set")
+	}
+
+	return &Archive{
+		Client: client,
+		Bucket: bucket,
+	}, nil
+}
+
+// raw-telemetry/<fleet>/<device>/2024/02/20/<first ts>-<last ts>.ndjson, writing the same
+// readings again overwrites the object instead of adding a copy
+func archiveKey(fleetID, deviceID string, readings []models.Telemetry) string {
+	first, last := readings[0].Timestamp, readings[len(readings)-1].Timestamp
+	day := time.Unix(first, 0).UTC()
+	return fmt.Sprintf("%s/%s/%s/%s/%d-%d.ndjson", archivePrefix, fleetID, deviceID, day.Format("2006/01/02"), first, last)
+}
+
+// Put writes the readings of one device, one object per utc day they span. Readings must be
+// in chronological order
+func (archive *Archive) Put(ctx context.Context, fleetID, deviceID string, readings []models.Telemetry) error {
+	if fleetID == "" {
+		fleetID = UnassignedFleet
+	}
+
+	for start := 0; start < len(readings); {
+		day := time.Unix(readings[start].Timestamp, 0).UTC().Format(time.DateOnly)
+		end := start + 1
+		for end < len(readings) && time.Unix(readings[end].Timestamp, 0).UTC().Format(time.DateOnly) == day {
+			end++
+		}
+
+		if err := archive.putObject(ctx, fleetID, deviceID, readings[start:end]); err != nil {
+			return err
+		}
+		start = end
+	}
+	return nil
+}
+
+func (archive *Archive) putObject(ctx context.Context, fleetID, deviceID string, readings []models.Telemetry) error {
+	var body bytes.Buffer
+	encoder := json.NewEncoder(&body) // Encode ends every reading with a newline
+	for _, reading := range readings {
+		if err := encoder.Encode(reading); err != nil {
+			return fmt.Errorf("failed to marshal reading of device %s: %w", deviceID, err)
+		}
+	}
+
+	key := archiveKey(fleetID, deviceID, readings)
+
+	callCtx, cancel := timeout.Call(ctx)
+	defer cancel()
+	_, err := archive.Client.PutObject(callCtx, &s3.PutObjectInput{
+		Bucket:      aws.String(archive.Bucket),
+		Key:         aws.String(key),
+		Body:        bytes.NewReader(body.Bytes()),
+		ContentType: aws.String("application/x-ndjson"),
+	})
+	if err != nil {
+		return fmt.Errorf("failed to archive telemetry to %s: %w", key, err)
+	}
+	return nil
+}
+
+// Read returns the archived readings of a fleet from the utc days from through to (inclusive)
+func (archive *Archive) Read(ctx context.Context, fleetID string, from, to time.Time) ([]models.Telemetry, error) {
+	prefix := fmt.Sprintf("%s/%s/", archivePrefix, fleetID)
+	first, last := from.UTC().Format("2006/01/02"), to.UTC().Format("2006/01/02")
+
+	var readings []models.Telemetry
+	paginator := s3.NewListObjectsV2Paginator(archive.Client, &s3.ListObjectsV2Input{
+		Bucket: aws.String(archive.Bucket),
+		Prefix: aws.String(prefix),
+	})
+	for paginator.HasMorePages() {
+		callCtx, cancel := timeout.Call(ctx)
+		page, err := paginator.NextPage(callCtx)
+		cancel()
+		if err != nil {
+			return nil, fmt.Errorf("failed to list archived telemetry under %s: %w", prefix, err)
+		}
+
+		for _, object := range page.Contents {
+			key := aws.ToString(object.Key)
+			if day, ok := archiveDay(key); !ok || day < first || day > last {
+				continue
+			}
+
+			objectReadings, err := archive.readObject(ctx, key)
+			if err != nil {
+				return nil, err
+			}
+			readings = append(readings, objectReadings...)
+		}
+	}
+
+	return readings, nil
+}
+
+// <prefix>/<fleet>/<device>/YYYY/MM/DD/<file>, the zero padded date compares as a string
+func archiveDay(key string) (string, bool) {
+	parts := strings.Split(key, "/")
+	if len(parts) != 7 {
+		return "", false
+	}
+	return strings.Join(parts[3:6], "/"), true
+}
+
+func (archive *Archive) readObject(ctx context.Context, key string) ([]models.Telemetry, error) {
+	callCtx, cancel := timeout.Call(ctx)
+	defer cancel()
+
+	output, err := archive.Client.GetObject(callCtx, &s3.GetObjectInput{
+		Bucket: aws.String(archive.Bucket),
+		Key:    aws.String(key),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to get archived telemetry %s: %w", key, err)
+	}
+	defer output.Body.Close()
+
+	var readings []models.Telemetry
+	decoder := json.NewDecoder(output.Body)
+	for {
+		var reading models.Telemetry
+		err := decoder.Decode(&reading)
+		if errors.Is(err, io.EOF) {
+			return readings, nil
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to decode archived telemetry %s: %w", key, err)
+		}
+		readings = append(readings, reading)
+	}
+}
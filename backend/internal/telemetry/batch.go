@@ -0,0 +1,267 @@
+package telemetry
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/attributevalue"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+
+	"github.com/Fleexa-Graduation-Project/Backend/models"
+	"github.com/Fleexa-Graduation-Project/Backend/pkg/db"
+	"github.com/Fleexa-Graduation-Project/Backend/pkg/timeout"
+)
+
+// BatchError lists the readings a batch write could not store, Failed holds indexes into the
+// slice it was given so the caller can map them back to their sqs records
+type BatchError struct {
+	Failed []int
+	Err    error
+}
+
+func (e *BatchError) Error() string {
+	return fmt.Sprintf("batch write: %d items failed: %v", len(e.Failed), e.Err)
+}
+
+func (e *BatchError) Unwrap() error {
+	return e.Err
+}
+
+// BatchPutTelemetry writes the readings with BatchWriteItem in chunks of 25 and retries the
+// unprocessed ones with backoff. Readings sharing a key (device_id + timestamp) are collapsed to
+// the last one, BatchWriteItem rejects a request with duplicate keys. A partial failure returns
+// a *BatchError, duplicates of a failed reading are reported as failed too. BatchWriteItem takes
+// no conditions, so readings are not deduplicated against the table, see SaveTelemetryBatch
+func (store *TelemetryStore) BatchPutTelemetry(ctx context.Context, items []models.Telemetry) error {
+	if len(items) == 0 {
+		return nil
+	}
+
+	defaultExpiry := store.expiresAt(time.Now())
+
+	// unique key -> indexes of the input readings it stands for, the last reading wins
+	indexes := map[string][]int{}
+	requests := map[string]types.WriteRequest{}
+	var order []string
+	for i, data := range items {
+		if data.ExpiresAt == 0 {
+			data.ExpiresAt = defaultExpiry
+		}
+
+		item, err := attributevalue.MarshalMap(data)
+		if err != nil {
+			return fmt.Errorf("failed to marshal batch item: %w", err)
+		}
+		item[store.DedupAttribute] = &types.AttributeValueMemberS{Value: DedupKey(data)}
+
+		key := itemKey(item)
+		if _, seen := indexes[key]; !seen {
+			order = append(order, key)
+		}
+		indexes[key] = append(indexes[key], i)
+		requests[key] = types.WriteRequest{PutRequest: &types.PutRequest{Item: item}}
+	}
+
+	batchErr := &BatchError{}
+	for start := 0; start < len(order); start += dynamoBatchLimit {
+		end := min(start+dynamoBatchLimit, len(order))
+
+		chunk := make([]types.WriteRequest, 0, end-start)
+		for _, key := range order[start:end] {
+			chunk = append(chunk, requests[key])
+		}
+
+		unprocessed, err := store.writeChunk(ctx, chunk)
+		for _, request := range unprocessed {
+			batchErr.Failed = append(batchErr.Failed, indexes[itemKey(request.PutRequest.Item)]...)
+		}
+		if err != nil {
+			batchErr.Err = err
+		}
+	}
+
+	if len(batchErr.Failed) > 0 {
+		return batchErr
+	}
+	return nil
+}
+
+// writeChunk returns the requests that were still unprocessed when it gave up
+func (store *TelemetryStore) writeChunk(ctx context.Context, requests []types.WriteRequest) ([]types.WriteRequest, error) {
+	pending := requests
+
+	for attempt := 0; attempt <= maxRetries; attempt++ {
+		if attempt > 0 {
+			backoff := time.Duration(1<<uint(attempt-1)) * 100 * time.Millisecond
+			select {
+			case <-ctx.Done():
+				return pending, ctx.Err()
+			case <-time.After(backoff):
+			}
+		}
+
+		input := &dynamodb.BatchWriteItemInput{
+			RequestItems: map[string][]types.WriteRequest{
+				store.TableName: pending,
+			},
+		}
+
+		var output *dynamodb.BatchWriteItemOutput
+		err := db.Retry(ctx, store.Retry, func() error {
+			var callErr error
+			callCtx, cancel := timeout.Call(ctx)
+			defer cancel()
+			output, callErr = store.Client.BatchWriteItem(callCtx, input, db.SingleAttempt)
+			return callErr
+		})
+		if err != nil {
+			return pending, fmt.Errorf("batch write attempt %d failed: %w", attempt+1, err)
+		}
+
+		pending = output.UnprocessedItems[store.TableName]
+		if len(pending) == 0 {
+			return nil, nil
+		}
+	}
+
+	return pending, fmt.Errorf("%d items still unprocessed after %d retries", len(pending), maxRetries)
+}
+
+// the table key, device_id (HASH) + timestamp (RANGE)
+func itemKey(item map[string]types.AttributeValue) string {
+	var deviceID, timestamp string
+	if v, ok := item["device_id"].(*types.AttributeValueMemberS); ok {
+		deviceID = v.Value
+	}
+	if v, ok := item["timestamp"].(*types.AttributeValueMemberN); ok {
+		timestamp = v.Value
+	}
+	return deviceID + "#" + timestamp
+}
+
+// a reading of SaveTelemetryBatch with its dedup marker, indexes are the readings of the input
+// it stands for
+type transactUnit struct {
+	indexes []int
+	key     string
+	item    map[string]types.AttributeValue
+}
+
+// SaveTelemetryBatch stores the readings with their dedup markers like SaveTelemetry, in
+// transactions of up to 50 readings. A reading whose dedup key is already stored, or repeats one
+// before it in dataList, is skipped and its index returned in duplicates. Readings sharing a
+// table key are collapsed to the last one as in BatchPutTelemetry. A transaction that fails
+// returns a *BatchError with its readings, the others are still written
+func (store *TelemetryStore) SaveTelemetryBatch(ctx context.Context, dataList []models.Telemetry) (duplicates []int, err error) {
+	now := time.Now()
+	defaultExpiry := store.expiresAt(now)
+
+	claimed := map[string]bool{}
+	units := map[string]*transactUnit{}
+	var order []string
+	for i, data := range dataList {
+		key := DedupKey(data)
+		if claimed[key] {
+			duplicates = append(duplicates, i)
+			continue
+		}
+		claimed[key] = true
+
+		if data.ExpiresAt == 0 {
+			data.ExpiresAt = defaultExpiry
+		}
+		item, err := attributevalue.MarshalMap(data)
+		if err != nil {
+			return nil, fmt.Errorf("failed to marshal batch item: %w", err)
+		}
+		item[store.DedupAttribute] = &types.AttributeValueMemberS{Value: key}
+
+		tableKey := itemKey(item)
+		unit, seen := units[tableKey]
+		if !seen {
+			unit = &transactUnit{}
+			units[tableKey] = unit
+			order = append(order, tableKey)
+		}
+		unit.indexes = append(unit.indexes, i)
+		unit.key, unit.item = key, item
+	}
+
+	batchErr := &BatchError{}
+	for start := 0; start < len(order); start += transactLimit {
+		chunk := make([]*transactUnit, 0, transactLimit)
+		for _, tableKey := range order[start:min(start+transactLimit, len(order))] {
+			chunk = append(chunk, units[tableKey])
+		}
+
+		dropped, err := store.writeTransaction(ctx, chunk, now)
+		for _, unit := range chunk {
+			switch {
+			case dropped[unit]:
+				duplicates = append(duplicates, unit.indexes...)
+			case err != nil:
+				batchErr.Failed = append(batchErr.Failed, unit.indexes...)
+			}
+		}
+		if err != nil {
+			batchErr.Err = err
+		}
+	}
+
+	if len(batchErr.Failed) > 0 {
+		return duplicates, batchErr
+	}
+	return duplicates, nil
+}
+
+// writeTransaction writes the units in one transaction. The ones whose marker already exists
+// cancel it, they are returned as dropped and the transaction is retried without them
+func (store *TelemetryStore) writeTransaction(ctx context.Context, units []*transactUnit, now time.Time) (map[*transactUnit]bool, error) {
+	dropped := map[*transactUnit]bool{}
+
+	for len(units) > 0 {
+		actions := make([]types.TransactWriteItem, 0, 2*len(units))
+		for _, unit := range units {
+			actions = append(actions,
+				store.dedupMarker(unit.key, now),
+				types.TransactWriteItem{Put: &types.Put{TableName: aws.String(store.TableName), Item: unit.item}},
+			)
+		}
+
+		err := db.Retry(ctx, store.Retry, func() error {
+			callCtx, cancel := timeout.Call(ctx)
+			defer cancel()
+			_, writeErr := store.Client.TransactWriteItems(callCtx, &dynamodb.TransactWriteItemsInput{TransactItems: actions}, db.SingleAttempt)
+			return writeErr
+		})
+		if err == nil {
+			return dropped, nil
+		}
+
+		// reasons come in the order of the actions, "None" for the ones that were fine
+		codes := cancelledBy(err)
+		if len(codes) != len(actions) {
+			return dropped, fmt.Errorf("failed to store telemetry transaction: %w", err)
+		}
+		rest := units[:0:0]
+		for i, unit := range units {
+			marker, reading := codes[2*i], codes[2*i+1]
+			switch {
+			case marker == "ConditionalCheckFailed":
+				dropped[unit] = true
+			case (marker == "None" || marker == "") && (reading == "None" || reading == ""):
+				rest = append(rest, unit)
+			default:
+				return dropped, fmt.Errorf("failed to store telemetry transaction: %w", err)
+			}
+		}
+		if len(rest) == len(units) {
+			return dropped, fmt.Errorf("failed to store telemetry transaction: %w", err)
+		}
+		units = rest
+	}
+	return dropped, nil
+}
@@ -0,0 +1,340 @@
+package telemetry
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"sync"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+
+	"github.com/Fleexa-Graduation-Project/Backend/models"
+	"github.com/Fleexa-Graduation-Project/Backend/pkg/db"
+)
+
+// fakeBatchWriter answers BatchWriteItem like dynamodb does, handing the first call's items
+// back as unprocessed when unprocessedOnce is set, or rejecting every call when failing is.
+// TransactWriteItems are checked against the dedup markers it has stored
+type fakeBatchWriter struct {
+	unprocessedOnce bool
+	failing         bool
+
+	mu           sync.Mutex
+	calls        []int // put requests per call
+	transactions []int // actions per TransactWriteItems call
+	markers      map[string]bool
+}
+
+type batchWriteBody struct {
+	RequestItems map[string][]json.RawMessage
+}
+
+type transactBody struct {
+	TransactItems []struct {
+		Put struct {
+			Item                map[string]map[string]json.RawMessage
+			ConditionExpression string
+		}
+	}
+}
+
+func (fake *fakeBatchWriter) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Header.Get("X-Amz-Target") == "DynamoDB_20120810.TransactWriteItems" {
+		fake.transact(w, r)
+		return
+	}
+
+	var body batchWriteBody
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	fake.mu.Lock()
+	first := len(fake.calls) == 0
+	for _, requests := range body.RequestItems {
+		fake.calls = append(fake.calls, len(requests))
+	}
+	fake.mu.Unlock()
+
+	w.Header().Set("Content-Type", "application/x-amz-json-1.0")
+	if fake.failing {
+		w.WriteHeader(http.StatusBadRequest)
+		fmt.Fprint(w, `{"__type": "com.amazon.coral.validate#ValidationException", "message": "rejected"}`)
+		return
+	}
+	if fake.unprocessedOnce && first {
+		json.NewEncoder(w).Encode(map[string]interface{}{"UnprocessedItems": body.RequestItems})
+		return
+	}
+	fmt.Fprint(w, `{"UnprocessedItems": {}}`)
+}
+
+// an existing marker cancels the whole transaction, nothing of it is stored
+func (fake *fakeBatchWriter) transact(w http.ResponseWriter, r *http.Request) {
+	var body transactBody
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	fake.mu.Lock()
+	defer fake.mu.Unlock()
+	fake.transactions = append(fake.transactions, len(body.TransactItems))
+	if fake.markers == nil {
+		fake.markers = map[string]bool{}
+	}
+
+	w.Header().Set("Content-Type", "application/x-amz-json-1.0")
+	if fake.failing {
+		w.WriteHeader(http.StatusBadRequest)
+		fmt.Fprint(w, `{"__type": "com.amazon.coral.validate#ValidationException", "message": "rejected"}`)
+		return
+	}
+
+	reasons := make([]map[string]string, len(body.TransactItems))
+	cancelled := false
+	for i, action := range body.TransactItems {
+		reasons[i] = map[string]string{"Code": "None"}
+		if action.Put.ConditionExpression != "" && fake.markers[string(action.Put.Item["device_id"]["S"])] {
+			reasons[i] = map[string]string{"Code": "ConditionalCheckFailed"}
+			cancelled = true
+		}
+	}
+	if cancelled {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"__type":              "com.amazonaws.dynamodb.v20120810#TransactionCanceledException",
+			"message":             "Transaction cancelled",
+			"CancellationReasons": reasons,
+		})
+		return
+	}
+
+	for _, action := range body.TransactItems {
+		if action.Put.ConditionExpression != "" {
+			fake.markers[string(action.Put.Item["device_id"]["S"])] = true
+		}
+	}
+	fmt.Fprint(w, `{}`)
+}
+
+func fakeStore(tb testing.TB, fake *fakeBatchWriter) *TelemetryStore {
+	tb.Helper()
+	server := httptest.NewServer(fake)
+	tb.Cleanup(server.Close)
+
+	client := dynamodb.New(dynamodb.Options{
+		Region:       "us-east-1",
+		BaseEndpoint: aws.String(server.URL),
+		Credentials:  aws.AnonymousCredentials{},
+		Retryer:      aws.NopRetryer{},
+	})
+	return &TelemetryStore{
+		Client:         client,
+		TableName:      "telemetry",
+		Retry:          db.RetryPolicy{MaxAttempts: 1},
+		DedupAttribute: DefaultDedupAttribute,
+		Retention:      DefaultRetention,
+	}
+}
+
+// n readings of truck-1 a second apart, every dupEvery-th one repeating the reading before it
+func readings(n, dupEvery int) []models.Telemetry {
+	items := make([]models.Telemetry, 0, n)
+	timestamp := int64(1700000000)
+	for i := range n {
+		if dupEvery == 0 || i == 0 || i%dupEvery != 0 {
+			timestamp++
+		}
+		items = append(items, models.Telemetry{
+			DeviceID:  "truck-1",
+			Timestamp: timestamp,
+			Type:      "temp-sensor",
+			Payload:   map[string]interface{}{"temp": 4.5, "seq": float64(i)},
+		})
+	}
+	return items
+}
+
+func TestBatchPutTelemetry(t *testing.T) {
+	tests := []struct {
+		name       string
+		fake       *fakeBatchWriter
+		items      []models.Telemetry
+		wantCalls  []int
+		wantFailed []int
+	}{
+		{name: "nothing to write", fake: &fakeBatchWriter{}},
+		{name: "chunks of 25", fake: &fakeBatchWriter{}, items: readings(60, 0), wantCalls: []int{25, 25, 10}},
+		// 30 readings, i = 3, 6 .. 27 redeliver the one before them: 21 unique keys
+		{name: "duplicate keys collapse in the batch", fake: &fakeBatchWriter{}, items: readings(30, 3), wantCalls: []int{21}},
+		{name: "unprocessed items are retried", fake: &fakeBatchWriter{unprocessedOnce: true}, items: readings(5, 0), wantCalls: []int{5, 5}},
+		{
+			name:       "every copy of a failed reading is reported",
+			fake:       &fakeBatchWriter{failing: true},
+			items:      readings(4, 2),
+			wantCalls:  []int{3},
+			wantFailed: []int{0, 1, 2, 3},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			store := fakeStore(t, test.fake)
+
+			err := store.BatchPutTelemetry(context.Background(), test.items)
+			if !slices.Equal(test.fake.calls, test.wantCalls) {
+				t.Errorf("BatchWriteItem calls = %v, want %v", test.fake.calls, test.wantCalls)
+			}
+
+			var batchErr *BatchError
+			if test.wantFailed == nil {
+				if err != nil {
+					t.Fatalf("BatchPutTelemetry() error = %v", err)
+				}
+				return
+			}
+			if !errors.As(err, &batchErr) {
+				t.Fatalf("BatchPutTelemetry() error = %v, want a *BatchError", err)
+			}
+			failed := slices.Sorted(slices.Values(batchErr.Failed))
+			if !slices.Equal(failed, test.wantFailed) {
+				t.Errorf("Failed = %v, want %v", failed, test.wantFailed)
+			}
+		})
+	}
+}
+
+func TestSaveTelemetryBatch(t *testing.T) {
+	tests := []struct {
+		name             string
+		fake             *fakeBatchWriter
+		stored           []models.Telemetry // written by an earlier batch
+		items            []models.Telemetry
+		wantTransactions []int
+		wantDuplicates   []int
+		wantFailed       []int
+	}{
+		{name: "nothing to write", fake: &fakeBatchWriter{}},
+		{name: "transactions of 50 readings", fake: &fakeBatchWriter{}, items: readings(60, 0), wantTransactions: []int{100, 20}},
+		// 6 readings, i = 2 and 4 repeat the timestamp before them under their own seq
+		{name: "shared table keys collapse", fake: &fakeBatchWriter{}, items: readings(6, 2), wantTransactions: []int{8}},
+		{
+			name:             "repeated dedup key in the batch",
+			fake:             &fakeBatchWriter{},
+			items:            append(readings(3, 0), readings(1, 0)...),
+			wantTransactions: []int{6},
+			wantDuplicates:   []int{3},
+		},
+		{
+			// the first transaction is cancelled by the stored markers, the retry writes the rest
+			name:             "redelivered readings are dropped",
+			fake:             &fakeBatchWriter{},
+			stored:           readings(2, 0),
+			items:            readings(5, 0),
+			wantTransactions: []int{4, 10, 6},
+			wantDuplicates:   []int{0, 1},
+		},
+		{
+			name:             "failed transaction",
+			fake:             &fakeBatchWriter{failing: true},
+			items:            readings(3, 0),
+			wantTransactions: []int{6},
+			wantFailed:       []int{0, 1, 2},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			store := fakeStore(t, test.fake)
+			if len(test.stored) > 0 {
+				if _, err := store.SaveTelemetryBatch(context.Background(), test.stored); err != nil {
+					t.Fatalf("SaveTelemetryBatch() of the stored readings error = %v", err)
+				}
+			}
+
+			duplicates, err := store.SaveTelemetryBatch(context.Background(), test.items)
+			if !slices.Equal(test.fake.transactions, test.wantTransactions) {
+				t.Errorf("TransactWriteItems actions = %v, want %v", test.fake.transactions, test.wantTransactions)
+			}
+			if !slices.Equal(slices.Sorted(slices.Values(duplicates)), test.wantDuplicates) {
+				t.Errorf("duplicates = %v, want %v", duplicates, test.wantDuplicates)
+			}
+
+			var batchErr *BatchError
+			if test.wantFailed == nil {
+				if err != nil {
+					t.Fatalf("SaveTelemetryBatch() error = %v", err)
+				}
+				return
+			}
+			if !errors.As(err, &batchErr) {
+				t.Fatalf("SaveTelemetryBatch() error = %v, want a *BatchError", err)
+			}
+			if failed := slices.Sorted(slices.Values(batchErr.Failed)); !slices.Equal(failed, test.wantFailed) {
+				t.Errorf("Failed = %v, want %v", failed, test.wantFailed)
+			}
+		})
+	}
+}
+
+// the sqs batch sizes ingestion sees, with and without redeliveries in the batch
+func BenchmarkBatchPutTelemetry(b *testing.B) {
+	for _, size := range []int{10, 100, 1000} {
+		for _, dupEvery := range []int{0, 4} {
+			b.Run(fmt.Sprintf("readings=%d/dup_every=%d", size, dupEvery), func(b *testing.B) {
+				store := fakeStore(b, &fakeBatchWriter{})
+				items := readings(size, dupEvery)
+
+				b.ReportAllocs()
+				for b.Loop() {
+					if err := store.BatchPutTelemetry(context.Background(), items); err != nil {
+						b.Fatal(err)
+					}
+				}
+			})
+		}
+	}
+}
+
+// the dedup path of an sqs batch, one SaveTelemetry per reading against one SaveTelemetryBatch
+func BenchmarkSaveTelemetry(b *testing.B) {
+	for _, size := range []int{10, 100} {
+		fake := &fakeBatchWriter{}
+		store := fakeStore(b, fake)
+		items := readings(size, 0)
+		forget := func() {
+			fake.mu.Lock()
+			fake.markers = nil
+			fake.mu.Unlock()
+		}
+
+		b.Run(fmt.Sprintf("readings=%d/single", size), func(b *testing.B) {
+			b.ReportAllocs()
+			for b.Loop() {
+				forget()
+				for _, item := range items {
+					if err := store.SaveTelemetry(context.Background(), item); err != nil {
+						b.Fatal(err)
+					}
+				}
+			}
+		})
+		b.Run(fmt.Sprintf("readings=%d/batch", size), func(b *testing.B) {
+			b.ReportAllocs()
+			for b.Loop() {
+				forget()
+				if _, err := store.SaveTelemetryBatch(context.Background(), items); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,207 @@
+package telemetry
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"github.com/aws/aws-sdk-go-v2/service/sqs"
+
+	"github.com/Fleexa-Graduation-Project/Backend/pkg/db"
+	"github.com/Fleexa-Graduation-Project/Backend/pkg/logger"
+	"github.com/Fleexa-Graduation-Project/Backend/pkg/timeout"
+)
+
+// readings one purge message deletes before handing the rest to a new message, keeps a single
+// invocation well inside the lambda timeout
+const DefaultPurgeChunk = 5000
+
+// CountReadings counts the readings of a device, stopping at upTo
+func (store *TelemetryStore) CountReadings(ctx context.Context, deviceID string, upTo int) (int, error) {
+	callCtx, cancel := timeout.Call(ctx)
+	defer cancel()
+	result, err := store.Client.Query(callCtx, &dynamodb.QueryInput{
+		TableName:              aws.String(store.TableName),
+		KeyConditionExpression: aws.String("device_id = :id"),
+		ExpressionAttributeValues: map[string]types.AttributeValue{
+			":id": &types.AttributeValueMemberS{Value: deviceID},
+		},
+		Select: types.SelectCount,
+		Limit:  aws.Int32(int32(upTo)),
+	})
+	if err != nil {
+		return 0, fmt.Errorf("failed to count telemetry of device %s: %w", deviceID, err)
+	}
+	return int(result.Count), nil
+}
+
+// DeleteReadings deletes up to limit readings of a device after cursor, oldest first, and returns
+// the LastEvaluatedKey to continue from as a cursor, empty when none are left. limit 0 deletes
+// all of them
+func (store *TelemetryStore) DeleteReadings(ctx context.Context, deviceID string, limit int, cursor string) (int, string, error) {
+	startKey, err := db.DecodeCursor(cursor)
+	if err != nil {
+		return 0, "", err
+	}
+
+	input := &dynamodb.QueryInput{
+		TableName:                aws.String(store.TableName),
+		KeyConditionExpression:   aws.String("device_id = :id"),
+		ProjectionExpression:     aws.String("device_id, #ts"),
+		ExpressionAttributeNames: map[string]string{"#ts": "timestamp"},
+		ExpressionAttributeValues: map[string]types.AttributeValue{
+			":id": &types.AttributeValueMemberS{Value: deviceID},
+		},
+		ExclusiveStartKey: startKey,
+	}
+
+	deleted := 0
+	for {
+		pageSize := MaxQueryLimit
+		if limit > 0 {
+			pageSize = min(pageSize, limit-deleted)
+		}
+		input.Limit = aws.Int32(int32(pageSize))
+
+		callCtx, cancel := timeout.Call(ctx)
+		result, err := store.Client.Query(callCtx, input)
+		cancel()
+		if err != nil {
+			return deleted, "", fmt.Errorf("failed to query telemetry of device %s for purge: %w", deviceID, err)
+		}
+
+		for start := 0; start < len(result.Items); start += dynamoBatchLimit {
+			end := min(start+dynamoBatchLimit, len(result.Items))
+			chunk := make([]types.WriteRequest, 0, end-start)
+			for _, key := range result.Items[start:end] {
+				chunk = append(chunk, types.WriteRequest{DeleteRequest: &types.DeleteRequest{Key: key}})
+			}
+
+			unprocessed, err := store.writeChunk(ctx, chunk)
+			deleted += len(chunk) - len(unprocessed)
+			if err != nil {
+				return deleted, "", fmt.Errorf("failed to purge telemetry of device %s: %w", deviceID, err)
+			}
+		}
+
+		if len(result.LastEvaluatedKey) == 0 {
+			return deleted, "", nil
+		}
+		if limit > 0 && deleted >= limit {
+			// the deleted key still works as the start of the next query
+			next, err := db.EncodeCursor(result.LastEvaluatedKey)
+			return deleted, next, err
+		}
+		input.ExclusiveStartKey = result.LastEvaluatedKey
+	}
+}
+
+// PurgeRequest asks the purge lambda to delete every reading of a device. A purge handed on to
+// a new message carries where it stopped and how many readings it deleted so far
+type PurgeRequest struct {
+	DeviceID    string `json:"device_id"`
+	RequestedBy string `json:"requested_by,omitempty"`
+	Cursor      string `json:"cursor,omitempty"`
+	Deleted     int    `json:"deleted,omitempty"`
+}
+
+// PurgeQueue hands telemetry purges too large for a request to the purge lambda
+type PurgeQueue struct {
+	Client   *sqs.Client
+	QueueURL string
+}
+
+func NewPurgeQueue(client *sqs.Client) (*PurgeQueue, error) {
+	queueURL := os.Getenv("TELEMETRY_PURGE_QUEUE_URL")
+	if queueURL == "" {
+		return nil, fmt.Errorf("TELEMETRY_PURGE_QUEUE_URL environment variable is not set")
+	}
+
+	return &PurgeQueue{
+		Client:   client,
+		QueueURL: queueURL,
+	}, nil
+}
+
+func (queue *PurgeQueue) Enqueue(ctx context.Context, request PurgeRequest) error {
+	body, err := json.Marshal(request)
+	if err != nil {
+		return fmt.Errorf("failed to marshal purge request: %w", err)
+	}
+
+	callCtx, cancel := timeout.Call(ctx)
+	defer cancel()
+	_, err = queue.Client.SendMessage(callCtx, &sqs.SendMessageInput{
+		QueueUrl:    aws.String(queue.QueueURL),
+		MessageBody: aws.String(string(body)),
+	})
+	if err != nil {
+		return fmt.Errorf("failed to enqueue telemetry purge of device %s: %w", request.DeviceID, err)
+	}
+	return nil
+}
+
+// Purger is the consumer of the purge queue. Each message deletes up to Chunk readings, and stops
+// early when the lambda deadline is near (timeout.RunningLow). A device with readings left is
+// re-enqueued with the cursor, so the purge continues in the next invocation
+type Purger struct {
+	Store Store
+	Queue *PurgeQueue
+	Chunk int
+}
+
+func (purger *Purger) HandleRequest(ctx context.Context, event events.SQSEvent) (events.SQSEventResponse, error) {
+	log := logger.FromContext(ctx)
+	response := events.SQSEventResponse{BatchItemFailures: []events.SQSBatchItemFailure{}}
+
+	for _, record := range event.Records {
+		var request PurgeRequest
+		if err := json.Unmarshal([]byte(record.Body), &request); err != nil || request.DeviceID == "" {
+			// retrying can't fix it
+			log.Warn("invalid purge request dropped", "reason", "validation_failed", "message_id", record.MessageId, "error", err)
+			continue
+		}
+
+		if err := purger.purge(ctx, request); err != nil {
+			log.Error("telemetry purge failed", "device_id", request.DeviceID, "message_id", record.MessageId, "error", err)
+			response.BatchItemFailures = append(response.BatchItemFailures, events.SQSBatchItemFailure{ItemIdentifier: record.MessageId})
+		}
+	}
+
+	return response, nil
+}
+
+func (purger *Purger) purge(ctx context.Context, request PurgeRequest) error {
+	log := logger.FromContext(ctx)
+
+	deleted, cursor := 0, request.Cursor
+	for {
+		step := MaxQueryLimit
+		if purger.Chunk > 0 {
+			step = min(step, purger.Chunk-deleted)
+		}
+		n, next, err := purger.Store.DeleteReadings(ctx, request.DeviceID, step, cursor)
+		deleted += n
+		if err != nil {
+			// the message is retried from its own cursor, readings deleted since are simply gone
+			log.Warn("telemetry purge stopped", "device_id", request.DeviceID, "deleted", deleted, "error", err)
+			return err
+		}
+		cursor = next
+		if cursor == "" {
+			log.Info("telemetry purged", "device_id", request.DeviceID, "deleted", deleted, "total_deleted", request.Deleted+deleted, "requested_by", request.RequestedBy)
+			return nil
+		}
+
+		if low := timeout.RunningLow(ctx); low || (purger.Chunk > 0 && deleted >= purger.Chunk) {
+			request.Cursor, request.Deleted = cursor, request.Deleted+deleted
+			log.Info("telemetry purge continues in a new message", "device_id", request.DeviceID, "deleted", deleted, "total_deleted", request.Deleted, "deadline_near", low, "requested_by", request.RequestedBy)
+			return purger.Queue.Enqueue(ctx, request)
+		}
+	}
+}
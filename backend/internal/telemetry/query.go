@@ -0,0 +1,79 @@
+package telemetry
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/attributevalue"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+
+	"github.com/Fleexa-Graduation-Project/Backend/models"
+	"github.com/Fleexa-Graduation-Project/Backend/pkg/db"
+	"github.com/Fleexa-Graduation-Project/Backend/pkg/timeout"
+)
+
+const (
+	DefaultQueryLimit = 100
+	MaxQueryLimit     = 1000
+)
+
+// one page of readings in chronological order, NextCursor is empty on the last page
+type TelemetryPage struct {
+	Readings   []models.Telemetry `json:"data"`
+	NextCursor string             `json:"next_cursor,omitempty"`
+}
+
+func clampQueryLimit(limit int) int {
+	if limit <= 0 {
+		return DefaultQueryLimit
+	}
+	if limit > MaxQueryLimit {
+		return MaxQueryLimit
+	}
+	return limit
+}
+
+// QueryTelemetry returns the readings of a device with from <= timestamp <= to, oldest first
+func (store *TelemetryStore) QueryTelemetry(ctx context.Context, deviceID string, from, to time.Time, limit int, cursor string) (TelemetryPage, error) {
+	startKey, err := db.DecodeCursor(cursor)
+	if err != nil {
+		return TelemetryPage{}, err
+	}
+
+	input := &dynamodb.QueryInput{
+		TableName:              aws.String(store.TableName),
+		KeyConditionExpression: aws.String("device_id = :id AND #ts BETWEEN :from AND :to"),
+		ExpressionAttributeNames: map[string]string{
+			"#ts": "timestamp",
+		},
+		ExpressionAttributeValues: map[string]types.AttributeValue{
+			":id":   &types.AttributeValueMemberS{Value: deviceID},
+			":from": &types.AttributeValueMemberN{Value: fmt.Sprint(from.Unix())},
+			":to":   &types.AttributeValueMemberN{Value: fmt.Sprint(to.Unix())},
+		},
+		ScanIndexForward:  aws.Bool(true),
+		Limit:             aws.Int32(int32(clampQueryLimit(limit))),
+		ExclusiveStartKey: startKey,
+	}
+
+	callCtx, cancel := timeout.Call(ctx)
+	defer cancel()
+	result, err := store.Client.Query(callCtx, input)
+	if err != nil {
+		return TelemetryPage{}, fmt.Errorf("failed to query telemetry for device %s: %w", deviceID, err)
+	}
+
+	page := TelemetryPage{Readings: []models.Telemetry{}}
+	if err = attributevalue.UnmarshalListOfMaps(result.Items, &page.Readings); err != nil {
+		return TelemetryPage{}, fmt.Errorf("failed to unmarshal telemetry for device %s: %w", deviceID, err)
+	}
+
+	if page.NextCursor, err = db.EncodeCursor(result.LastEvaluatedKey); err != nil {
+		return TelemetryPage{}, err
+	}
+
+	return page, nil
+}
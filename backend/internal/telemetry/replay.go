@@ -0,0 +1,50 @@
+package telemetry
+
+import (
+	"cmp"
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"time"
+
+	"github.com/Fleexa-Graduation-Project/Backend/models"
+)
+
+// ReplayReport counts what a replay did, Written stays 0 on a dry run
+type ReplayReport struct {
+	Read       int `json:"read"`
+	Written    int `json:"written"`
+	Duplicates int `json:"duplicates"`
+}
+
+// Replay writes archived readings back to the store oldest first, e.g. for readings past their ttl
+// before trips are re-aggregated. The conditional put drops readings still in the table, so
+// replaying a range twice is safe. The rules don't run, replayed readings never re-alert
+func Replay(ctx context.Context, archive *Archive, store Store, fleetID string, from, to time.Time, dryRun bool) (ReplayReport, error) {
+	readings, err := archive.Read(ctx, fleetID, from, to)
+	if err != nil {
+		return ReplayReport{}, err
+	}
+	slices.SortStableFunc(readings, func(a, b models.Telemetry) int { return cmp.Compare(a.Timestamp, b.Timestamp) })
+
+	report := ReplayReport{Read: len(readings)}
+	if dryRun {
+		return report, nil
+	}
+
+	for _, reading := range readings {
+		reading.ExpiresAt = 0 // the archived expiry may have passed, keep it for a fresh ttl
+		err := store.SaveTelemetry(ctx, reading)
+		switch {
+		case errors.Is(err, ErrDuplicate):
+			report.Duplicates++
+		case err != nil:
+			return report, fmt.Errorf("failed to replay reading of device %s at %d: %w", reading.DeviceID, reading.Timestamp, err)
+		default:
+			report.Written++
+		}
+	}
+
+	return report, nil
+}
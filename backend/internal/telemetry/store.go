@@ -0,0 +1,31 @@
+package telemetry
+
+import (
+	"context"
+	"time"
+
+	"github.com/Fleexa-Graduation-Project/Backend/models"
+)
+
+// Store is what the ingestion service and handlers need from telemetry storage,
+// TelemetryStore (dynamodb) and MemTelemetryStore both implement it
+type Store interface {
+	// SaveTelemetry returns ErrDuplicate when the reading was already stored
+	SaveTelemetry(ctx context.Context, data models.Telemetry) error
+	// SaveTelemetryBatch returns the indexes of the readings dropped as duplicates, and a
+	// *BatchError naming the readings that could not be written
+	SaveTelemetryBatch(ctx context.Context, dataList []models.Telemetry) (duplicates []int, err error)
+	// BatchPutTelemetry returns a *BatchError naming the readings that could not be written
+	BatchPutTelemetry(ctx context.Context, items []models.Telemetry) error
+	GetTelemetryHistory(ctx context.Context, deviceID string, limit int32, since int64) ([]models.Telemetry, error)
+	QueryTelemetry(ctx context.Context, deviceID string, from, to time.Time, limit int, cursor string) (TelemetryPage, error)
+	CountReadings(ctx context.Context, deviceID string, upTo int) (int, error)
+	// DeleteReadings deletes up to limit readings (0 for all) after cursor, oldest first, and returns
+	// the cursor to continue from, empty when none are left
+	DeleteReadings(ctx context.Context, deviceID string, limit int, cursor string) (int, string, error)
+}
+
+var (
+	_ Store = (*TelemetryStore)(nil)
+	_ Store = (*MemTelemetryStore)(nil)
+)
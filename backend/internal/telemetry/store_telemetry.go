@@ -2,12 +2,15 @@ package telemetry
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"os"
 	"time"
 
 	"github.com/Fleexa-Graduation-Project/Backend/models"
+	appconfig "github.com/Fleexa-Graduation-Project/Backend/pkg/config"
 	"github.com/Fleexa-Graduation-Project/Backend/pkg/db"
+	"github.com/Fleexa-Graduation-Project/Backend/pkg/timeout"
 	"github.com/aws/aws-sdk-go-v2/aws"
     
 	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/attributevalue"
@@ -17,134 +20,193 @@ import (
 
 const (
 	dynamoBatchLimit = 25 // DynamoDB BatchWriteItem hard limit
+	transactLimit    = 50 // readings per TransactWriteItems, each with its marker makes the 100 action limit
 	maxRetries       = 3  // Retries for unprocessed items
+
+	DefaultDedupAttribute = "dedup_key"
+	DefaultDedupTTL       = 4 * 24 * time.Hour  // sqs' default message retention, no redelivery comes later
+	DefaultRetention      = 30 * 24 * time.Hour // raw readings are in the s3 archive after that
+
+	// dedup markers share the telemetry table under their own partition, "dedup#" + the dedup key
+	dedupMarkerPrefix = "dedup#"
 )
 
+// ErrDuplicate is returned when a reading with the same dedup key was already stored
+var ErrDuplicate = errors.New("duplicate telemetry")
+
 type TelemetryStore struct {
 	Client    *dynamodb.Client
 	TableName string
-}
-
-// NewTelemetryStore initializes the store using the shared db.Client
-func NewTelemetryStore() (*TelemetryStore, error) {
-	tableName := os.Getenv("DYNAMODB_TABLE_NAME")
-	if tableName == "" {
-		return nil, fmt.Errorf("DYNAMODB_TABLE_NAME environment variable is not set")
-	}
+	Retry     db.RetryPolicy
 
-	// We use the global 'db.Client' we created in pkg/db/client.go
-	return &TelemetryStore{
-		Client:    db.Client,
-		TableName: tableName,
-	}, nil
+	// DedupTTL is how long a dedup marker is kept, the window in which a redelivery is dropped.
+	// Retention is how long a reading is kept before DynamoDB's ttl deletes it. 0 keeps either forever
+	DedupAttribute string
+	DedupTTL       time.Duration
+	Retention      time.Duration
 }
-//write to db
-func (store *TelemetryStore) SaveTelemetry(ctx context.Context, data models.Telemetry) error {
-	if data.ExpiresAt == 0 {
-		data.ExpiresAt = time.Now().Add(7 * 24 * time.Hour).Unix()
-	}
 
-	item, err := attributevalue.MarshalMap(data)
+type StoreOption func(*TelemetryStore)
 
-	if err != nil {
-		return fmt.Errorf("failed to marshal telemetry data: %v", err)
+// WithRetryPolicy overrides how throttled writes are retried
+func WithRetryPolicy(maxAttempts int, baseDelay time.Duration) StoreOption {
+	return func(store *TelemetryStore) {
+		store.Retry = db.RetryPolicy{MaxAttempts: maxAttempts, BaseDelay: baseDelay}
 	}
+}
 
-	input := &dynamodb.PutItemInput{
-		TableName: aws.String(store.TableName),
-		Item:      item,
+// WithDedup overrides the attribute holding the dedup key
+func WithDedup(attribute string) StoreOption {
+	return func(store *TelemetryStore) {
+		store.DedupAttribute = attribute
 	}
+}
 
-	_, err = store.Client.PutItem(ctx, input)
-	if err != nil {
-		return fmt.Errorf("failed to store data into DynamoDB: %v", err)
+// WithDedupTTL overrides how long dedup markers are kept, 0 disables their ttl
+func WithDedupTTL(ttl time.Duration) StoreOption {
+	return func(store *TelemetryStore) {
+		store.DedupTTL = ttl
 	}
-
-	return nil
 }
 
-// storing multiple telemetry records in a single DynamoDB call(max 25)
-func (store *TelemetryStore) SaveTelemetryBatch(ctx context.Context, dataList []models.Telemetry) error {
-	if len(dataList) == 0 {
-		return nil
+// WithRetention overrides how long readings are kept, 0 disables the ttl
+func WithRetention(retention time.Duration) StoreOption {
+	return func(store *TelemetryStore) {
+		store.Retention = retention
 	}
+}
 
-	defaultExpiry := time.Now().Add(7 * 24 * time.Hour).Unix()
+// NewTelemetryStore initializes the store using the shared db.Client. TELEMETRY_RETENTION,
+// TELEMETRY_DEDUP_TTL ("0" keeps readings or markers forever) and TELEMETRY_DEDUP_ATTRIBUTE
+// override the defaults
+func NewTelemetryStore(opts ...StoreOption) (*TelemetryStore, error) {
+	tableName := appconfig.TableName("DYNAMODB_TABLE_NAME")
+	if tableName == "" {
+		return nil, fmt.Errorf("DYNAMODB_TABLE_NAME environment variable is not set")
+	}
 
-	for i := 0; i < len(dataList); i += dynamoBatchLimit {
+	// We use the global 'db.Client' we created in pkg/db/client.go
+	store := &TelemetryStore{
+		Client:         db.Client,
+		TableName:      tableName,
+		Retry:          db.DefaultRetryPolicy,
+		DedupAttribute: DefaultDedupAttribute,
+		DedupTTL:       DefaultDedupTTL,
+		Retention:      DefaultRetention,
+	}
 
-		end := i + dynamoBatchLimit
-		if end > len(dataList) {
-			end = len(dataList)
+	if attribute := os.Getenv("TELEMETRY_DEDUP_ATTRIBUTE"); attribute != "" {
+		store.DedupAttribute = attribute
+	}
+	for name, target := range map[string]*time.Duration{"TELEMETRY_DEDUP_TTL": &store.DedupTTL, "TELEMETRY_RETENTION": &store.Retention} {
+		raw := os.Getenv(name)
+		if raw == "" {
+			continue
+		}
+		duration, err := time.ParseDuration(raw)
+		if err != nil || duration < 0 {
+			return nil, fmt.Errorf("invalid %s %q", name, raw)
 		}
+		*target = duration
+	}
 
-		chunk := dataList[i:end]
+	for _, opt := range opts {
+		opt(store)
+	}
 
-		var writeRequests []types.WriteRequest
+	return store, nil
+}
 
-		for _, data := range chunk {
-			if data.ExpiresAt == 0 {
-				data.ExpiresAt = defaultExpiry
-			}
+// expiresAt is the ttl attribute of a reading ingested at ingestedAt, 0 (no ttl) when
+// retention is disabled
+func (store *TelemetryStore) expiresAt(ingestedAt time.Time) int64 {
+	if store.Retention <= 0 {
+		return 0
+	}
+	return ingestedAt.Add(store.Retention).Unix()
+}
 
-			item, err := attributevalue.MarshalMap(data)
-			if err != nil {
-				return fmt.Errorf("failed to marshal batch item: %w", err)
-			}
+// DedupKey identifies a reading across redeliveries: the device id plus the
+// device reported sequence number, or the reading timestamp when there is none
+func DedupKey(data models.Telemetry) string {
+	if seq, ok := data.Payload["seq"].(float64); ok {
+		return fmt.Sprintf("%s#%d", data.DeviceID, int64(seq))
+	}
+	return fmt.Sprintf("%s#%d", data.DeviceID, data.Timestamp)
+}
+// dedupMarker is the item claiming a dedup key. It is keyed by the dedup key alone, so a
+// redelivery is caught whatever timestamp its reading ends up under
+func (store *TelemetryStore) dedupMarker(key string, now time.Time) types.TransactWriteItem {
+	marker := map[string]types.AttributeValue{
+		"device_id":          &types.AttributeValueMemberS{Value: dedupMarkerPrefix + key},
+		"timestamp":          &types.AttributeValueMemberN{Value: "0"},
+		store.DedupAttribute: &types.AttributeValueMemberS{Value: key},
+	}
+	if store.DedupTTL > 0 {
+		marker["expires_at"] = &types.AttributeValueMemberN{Value: fmt.Sprint(now.Add(store.DedupTTL).Unix())}
+	}
 
-			writeRequests = append(writeRequests, types.WriteRequest{
-				PutRequest: &types.PutRequest{
-					Item: item,
-				},
-			})
-		}
+	return types.TransactWriteItem{Put: &types.Put{
+		TableName:                aws.String(store.TableName),
+		Item:                     marker,
+		ConditionExpression:      aws.String("attribute_not_exists(#dedup)"),
+		ExpressionAttributeNames: map[string]string{"#dedup": store.DedupAttribute},
+	}}
+}
 
-		//use retry logic
-		if err := store.writeBatchWithRetry(ctx, writeRequests); err != nil {
-			return err
-		}
+// cancelledBy returns the cancellation reason code of every action of a cancelled transaction,
+// nil when err is no TransactionCanceledException
+func cancelledBy(err error) []string {
+	var cancelErr *types.TransactionCanceledException
+	if !errors.As(err, &cancelErr) {
+		return nil
 	}
-
-	return nil
+	codes := make([]string, len(cancelErr.CancellationReasons))
+	for i, reason := range cancelErr.CancellationReasons {
+		codes[i] = aws.ToString(reason.Code)
+	}
+	return codes
 }
 
-func (store *TelemetryStore) writeBatchWithRetry(ctx context.Context, requests []types.WriteRequest) error {
-	pending := requests
-
-	for attempt := 0; attempt <= maxRetries; attempt++ {
-		if attempt > 0 {
-			backoff := time.Duration(1<<uint(attempt-1)) * 100 * time.Millisecond
-			select {
-			case <-ctx.Done():
-				return ctx.Err()
-			case <-time.After(backoff):
-			}
-		}
+// writes the reading together with its dedup marker in one transaction, a redelivered reading
+// fails the marker's condition and returns ErrDuplicate
+func (store *TelemetryStore) SaveTelemetry(ctx context.Context, data models.Telemetry) error {
+	now := time.Now()
+	if data.ExpiresAt == 0 {
+		data.ExpiresAt = store.expiresAt(now)
+	}
 
-		input := &dynamodb.BatchWriteItemInput{
-			RequestItems: map[string][]types.WriteRequest{
-				store.TableName: pending,
-			},
-		}
+	item, err := attributevalue.MarshalMap(data)
 
-		output, err := store.Client.BatchWriteItem(ctx, input)
-		if err != nil {
-			return fmt.Errorf("batch write attempt %d failed: %w", attempt+1, err)
-		}
+	if err != nil {
+		return fmt.Errorf("failed to marshal telemetry data: %w", err)
+	}
+	key := DedupKey(data)
+	item[store.DedupAttribute] = &types.AttributeValueMemberS{Value: key}
+
+	input := &dynamodb.TransactWriteItemsInput{
+		TransactItems: []types.TransactWriteItem{
+			store.dedupMarker(key, now),
+			{Put: &types.Put{TableName: aws.String(store.TableName), Item: item}},
+		},
+	}
 
-		// Check for unprocessed items
-		unprocessed := output.UnprocessedItems[store.TableName]
-		if len(unprocessed) == 0 {
-			return nil
+	err = db.Retry(ctx, store.Retry, func() error {
+		callCtx, cancel := timeout.Call(ctx)
+		defer cancel()
+		_, writeErr := store.Client.TransactWriteItems(callCtx, input, db.SingleAttempt)
+		return writeErr
+	})
+	if err != nil {
+		if codes := cancelledBy(err); len(codes) > 0 && codes[0] == "ConditionalCheckFailed" {
+			return fmt.Errorf("%w: %s", ErrDuplicate, key)
 		}
-
-		pending = unprocessed
+		return fmt.Errorf("failed to store data into DynamoDB: %w", err)
 	}
 
-	return fmt.Errorf("batch write: %d items still unprocessed after %d retries", len(pending), maxRetries)
+	return nil
 }
 
-
 //get recent readings for a device.
 func (store *TelemetryStore) GetTelemetryHistory(ctx context.Context, deviceID string, limit int32, since int64) ([]models.Telemetry, error) {
     keyCondition := "device_id = :id"
@@ -169,7 +231,9 @@ func (store *TelemetryStore) GetTelemetryHistory(ctx context.Context, deviceID s
         input.Limit = aws.Int32(limit)
     }
 
-    result, err := store.Client.Query(ctx, input)
+    callCtx, cancel := timeout.Call(ctx)
+    defer cancel()
+    result, err := store.Client.Query(callCtx, input)
     if err != nil {
         return nil, fmt.Errorf("failed to query telemetry history for device %s: %w", deviceID, err)
     }
@@ -0,0 +1,135 @@
+//go:build integration
+
+package telemetry
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+
+	"github.com/Fleexa-Graduation-Project/Backend/internal/dbtest"
+	"github.com/Fleexa-Graduation-Project/Backend/models"
+)
+
+// the telemetry table of scripts/dynamodb-local.sh: device_id (HASH) + timestamp (RANGE)
+func integrationStore(t *testing.T) *TelemetryStore {
+	t.Helper()
+	client := dbtest.Client(t)
+	dbtest.CreateTable(t, client, "DYNAMODB_TABLE_NAME", dynamodb.CreateTableInput{
+		AttributeDefinitions: []types.AttributeDefinition{
+			dbtest.Attribute("device_id", types.ScalarAttributeTypeS),
+			dbtest.Attribute("timestamp", types.ScalarAttributeTypeN),
+		},
+		KeySchema: dbtest.Key("device_id", "timestamp"),
+	})
+
+	store, err := NewTelemetryStore()
+	if err != nil {
+		t.Fatalf("NewTelemetryStore: %v", err)
+	}
+	return store
+}
+
+func TestIntegrationSaveTelemetry(t *testing.T) {
+	store := integrationStore(t)
+	ctx := context.Background()
+	now := time.Now().Unix()
+
+	reading := models.Telemetry{DeviceID: "truck-1", Timestamp: now - 60, Type: "temp-sensor", Payload: map[string]interface{}{"temp": 4.5, "seq": 1.0}}
+	if err := store.SaveTelemetry(ctx, reading); err != nil {
+		t.Fatalf("SaveTelemetry: %v", err)
+	}
+	if err := store.SaveTelemetry(ctx, reading); !errors.Is(err, ErrDuplicate) {
+		t.Fatalf("SaveTelemetry of a redelivery = %v, want ErrDuplicate", err)
+	}
+	// the same seq under another timestamp, as a skewed reading stored under its receive time
+	moved := reading
+	moved.Timestamp = now - 30
+	if err := store.SaveTelemetry(ctx, moved); !errors.Is(err, ErrDuplicate) {
+		t.Fatalf("SaveTelemetry of a redelivery under another timestamp = %v, want ErrDuplicate", err)
+	}
+	later := models.Telemetry{DeviceID: "truck-1", Timestamp: now, Type: "temp-sensor", Payload: map[string]interface{}{"temp": 5.0, "seq": 2.0}}
+	if err := store.SaveTelemetry(ctx, later); err != nil {
+		t.Fatalf("SaveTelemetry: %v", err)
+	}
+
+	history, err := store.GetTelemetryHistory(ctx, "truck-1", 10, 0)
+	if err != nil {
+		t.Fatalf("GetTelemetryHistory: %v", err)
+	}
+	if len(history) != 2 || history[0].Timestamp != now || history[1].Timestamp != now-60 {
+		t.Fatalf("GetTelemetryHistory() = %+v, want both readings newest first", history)
+	}
+	if history[1].Payload["temp"] != 4.5 {
+		t.Errorf("payload = %v, want temp 4.5", history[1].Payload)
+	}
+	if want := now + int64(DefaultRetention/time.Second); history[0].ExpiresAt < want || history[0].ExpiresAt > want+60 {
+		t.Errorf("expires_at = %d, want about %d, now + retention", history[0].ExpiresAt, want)
+	}
+
+	since, err := store.GetTelemetryHistory(ctx, "truck-1", 10, now)
+	if err != nil {
+		t.Fatalf("GetTelemetryHistory since: %v", err)
+	}
+	if len(since) != 1 || since[0].Timestamp != now {
+		t.Errorf("GetTelemetryHistory(since now) = %+v, want the later reading", since)
+	}
+
+	other, err := store.GetTelemetryHistory(ctx, "truck-2", 10, 0)
+	if err != nil || len(other) != 0 {
+		t.Errorf("GetTelemetryHistory(truck-2) = %+v, %v, want no readings", other, err)
+	}
+}
+
+func TestIntegrationBatchPutTelemetry(t *testing.T) {
+	store := integrationStore(t)
+	ctx := context.Background()
+
+	// 60 readings over two chunks, i = 3, 6 .. 57 redeliver the one before them
+	items := readings(60, 3)
+	unique := map[int64]bool{}
+	for _, item := range items {
+		unique[item.Timestamp] = true
+	}
+	if err := store.BatchPutTelemetry(ctx, items); err != nil {
+		t.Fatalf("BatchPutTelemetry: %v", err)
+	}
+
+	from, to := time.Unix(items[0].Timestamp, 0), time.Unix(items[len(items)-1].Timestamp, 0)
+	var stored []models.Telemetry
+	cursor := ""
+	for pages := 0; ; pages++ {
+		if pages > len(items) {
+			t.Fatal("QueryTelemetry never returned the last page")
+		}
+		page, err := store.QueryTelemetry(ctx, "truck-1", from, to, 7, cursor)
+		if err != nil {
+			t.Fatalf("QueryTelemetry: %v", err)
+		}
+		stored = append(stored, page.Readings...)
+		if page.NextCursor == "" {
+			break
+		}
+		cursor = page.NextCursor
+	}
+
+	if len(stored) != len(unique) {
+		t.Fatalf("stored %d readings, want the %d unique keys", len(stored), len(unique))
+	}
+	for i := 1; i < len(stored); i++ {
+		if stored[i].Timestamp <= stored[i-1].Timestamp {
+			t.Fatalf("readings out of order at %d: %d after %d", i, stored[i].Timestamp, stored[i-1].Timestamp)
+		}
+	}
+	// the last copy of a key is the one written
+	redelivered := items[57]
+	for _, reading := range stored {
+		if reading.Timestamp == redelivered.Timestamp && reading.Payload["seq"] != redelivered.Payload["seq"] {
+			t.Errorf("reading at %d has seq %v, want the redelivery's %v", reading.Timestamp, reading.Payload["seq"], redelivered.Payload["seq"])
+		}
+	}
+}
@@ -0,0 +1,199 @@
+package telemetry
+
+import (
+	"cmp"
+	"context"
+	"fmt"
+	"math"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+
+	"github.com/Fleexa-Graduation-Project/Backend/models"
+	"github.com/Fleexa-Graduation-Project/Backend/pkg/db"
+)
+
+// MemTelemetryStore keeps readings in memory keyed like the real table (device_id + timestamp)
+type MemTelemetryStore struct {
+	mu       sync.RWMutex
+	readings map[string]map[int64]models.Telemetry
+	seen     map[string]bool
+}
+
+func NewMemTelemetryStore() *MemTelemetryStore {
+	return &MemTelemetryStore{
+		readings: map[string]map[int64]models.Telemetry{},
+		seen:     map[string]bool{},
+	}
+}
+
+func (store *MemTelemetryStore) SaveTelemetry(ctx context.Context, data models.Telemetry) error {
+	store.mu.Lock()
+	defer store.mu.Unlock()
+
+	key := DedupKey(data)
+	if store.seen[key] {
+		return fmt.Errorf("%w: %s", ErrDuplicate, key)
+	}
+	store.put(data)
+	return nil
+}
+
+// dedup checked like SaveTelemetry, a repeat within dataList is a duplicate as well
+func (store *MemTelemetryStore) SaveTelemetryBatch(ctx context.Context, dataList []models.Telemetry) ([]int, error) {
+	store.mu.Lock()
+	defer store.mu.Unlock()
+
+	var duplicates []int
+	for i, data := range dataList {
+		if store.seen[DedupKey(data)] {
+			duplicates = append(duplicates, i)
+			continue
+		}
+		store.put(data)
+	}
+	return duplicates, nil
+}
+
+// no dedup check, same as the batch write. The map is keyed like the table, so duplicate keys
+// collapse to the last reading on their own
+func (store *MemTelemetryStore) BatchPutTelemetry(ctx context.Context, items []models.Telemetry) error {
+	store.mu.Lock()
+	defer store.mu.Unlock()
+
+	for _, data := range items {
+		store.put(data)
+	}
+	return nil
+}
+
+func (store *MemTelemetryStore) put(data models.Telemetry) {
+	if store.readings[data.DeviceID] == nil {
+		store.readings[data.DeviceID] = map[int64]models.Telemetry{}
+	}
+	store.readings[data.DeviceID][data.Timestamp] = data
+	store.seen[DedupKey(data)] = true
+}
+
+// newest first, as the dynamodb query with ScanIndexForward=false
+func (store *MemTelemetryStore) GetTelemetryHistory(ctx context.Context, deviceID string, limit int32, since int64) ([]models.Telemetry, error) {
+	store.mu.RLock()
+	history := make([]models.Telemetry, 0, len(store.readings[deviceID]))
+	for ts, data := range store.readings[deviceID] {
+		if since > 0 && ts < since {
+			continue
+		}
+		history = append(history, data)
+	}
+	store.mu.RUnlock()
+
+	slices.SortFunc(history, func(a, b models.Telemetry) int {
+		return cmp.Compare(b.Timestamp, a.Timestamp)
+	})
+
+	if limit > 0 && int(limit) < len(history) {
+		history = history[:limit]
+	}
+	return history, nil
+}
+
+// oldest first, the cursor has the same shape as the dynamodb LastEvaluatedKey
+func (store *MemTelemetryStore) QueryTelemetry(ctx context.Context, deviceID string, from, to time.Time, limit int, cursor string) (TelemetryPage, error) {
+	startKey, err := db.DecodeCursor(cursor)
+	if err != nil {
+		return TelemetryPage{}, err
+	}
+
+	after := from.Unix() - 1
+	if startKey != nil {
+		ts, ok := startKey["timestamp"].(*types.AttributeValueMemberN)
+		if !ok {
+			return TelemetryPage{}, db.ErrInvalidCursor
+		}
+		if _, err := fmt.Sscan(ts.Value, &after); err != nil {
+			return TelemetryPage{}, db.ErrInvalidCursor
+		}
+	}
+
+	store.mu.RLock()
+	readings := []models.Telemetry{}
+	for ts, data := range store.readings[deviceID] {
+		if ts > after && ts <= to.Unix() {
+			readings = append(readings, data)
+		}
+	}
+	store.mu.RUnlock()
+
+	slices.SortFunc(readings, func(a, b models.Telemetry) int {
+		return cmp.Compare(a.Timestamp, b.Timestamp)
+	})
+
+	page := TelemetryPage{Readings: readings}
+	if limit = clampQueryLimit(limit); len(readings) > limit {
+		page.Readings = readings[:limit]
+		last := page.Readings[limit-1]
+		page.NextCursor, err = db.EncodeCursor(map[string]types.AttributeValue{
+			"device_id": &types.AttributeValueMemberS{Value: deviceID},
+			"timestamp": &types.AttributeValueMemberN{Value: fmt.Sprint(last.Timestamp)},
+		})
+	}
+	return page, err
+}
+
+func (store *MemTelemetryStore) CountReadings(ctx context.Context, deviceID string, upTo int) (int, error) {
+	store.mu.RLock()
+	defer store.mu.RUnlock()
+	return min(len(store.readings[deviceID]), upTo), nil
+}
+
+// oldest first, as the dynamodb purge, with the same cursor shape
+func (store *MemTelemetryStore) DeleteReadings(ctx context.Context, deviceID string, limit int, cursor string) (int, string, error) {
+	startKey, err := db.DecodeCursor(cursor)
+	if err != nil {
+		return 0, "", err
+	}
+	after := int64(math.MinInt64)
+	if startKey != nil {
+		ts, ok := startKey["timestamp"].(*types.AttributeValueMemberN)
+		if !ok {
+			return 0, "", db.ErrInvalidCursor
+		}
+		if _, err := fmt.Sscan(ts.Value, &after); err != nil {
+			return 0, "", db.ErrInvalidCursor
+		}
+	}
+
+	store.mu.Lock()
+	defer store.mu.Unlock()
+
+	timestamps := make([]int64, 0, len(store.readings[deviceID]))
+	for ts := range store.readings[deviceID] {
+		if ts > after {
+			timestamps = append(timestamps, ts)
+		}
+	}
+	slices.Sort(timestamps)
+	more := limit > 0 && limit < len(timestamps)
+	if more {
+		timestamps = timestamps[:limit]
+	}
+
+	for _, ts := range timestamps {
+		delete(store.seen, DedupKey(store.readings[deviceID][ts]))
+		delete(store.readings[deviceID], ts)
+	}
+	if len(store.readings[deviceID]) == 0 {
+		delete(store.readings, deviceID)
+	}
+	if !more {
+		return len(timestamps), "", nil
+	}
+
+	next, err := db.EncodeCursor(map[string]types.AttributeValue{
+		"device_id": &types.AttributeValueMemberS{Value: deviceID},
+		"timestamp": &types.AttributeValueMemberN{Value: fmt.Sprint(timestamps[len(timestamps)-1])},
+	})
+	return len(timestamps), next, err
+}
@@ -0,0 +1,98 @@
+package telemetry
+
+import (
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/attributevalue"
+
+	"github.com/Fleexa-Graduation-Project/Backend/models"
+)
+
+func TestStoreRetention(t *testing.T) {
+	ingestedAt := time.Unix(1700000000, 0)
+
+	tests := []struct {
+		name          string
+		env           map[string]string
+		opts          []StoreOption
+		wantRetention time.Duration
+		wantDedupTTL  time.Duration
+		wantErr       bool
+	}{
+		{name: "default", wantRetention: DefaultRetention, wantDedupTTL: DefaultDedupTTL},
+		{name: "from the env", env: map[string]string{"TELEMETRY_RETENTION": "168h"}, wantRetention: 7 * 24 * time.Hour, wantDedupTTL: DefaultDedupTTL},
+		{
+			name:          "dedup ttl apart from the retention",
+			env:           map[string]string{"TELEMETRY_DEDUP_TTL": "48h", "TELEMETRY_RETENTION": "72h"},
+			wantRetention: 72 * time.Hour,
+			wantDedupTTL:  48 * time.Hour,
+		},
+		{name: "zero keeps readings forever", env: map[string]string{"TELEMETRY_RETENTION": "0"}, wantRetention: 0, wantDedupTTL: DefaultDedupTTL},
+		{name: "zero keeps markers forever", env: map[string]string{"TELEMETRY_DEDUP_TTL": "0"}, wantRetention: DefaultRetention, wantDedupTTL: 0},
+		{
+			name:          "option wins over the env",
+			env:           map[string]string{"TELEMETRY_RETENTION": "72h", "TELEMETRY_DEDUP_TTL": "48h"},
+			opts:          []StoreOption{WithRetention(time.Hour), WithDedupTTL(time.Minute)},
+			wantRetention: time.Hour,
+			wantDedupTTL:  time.Minute,
+		},
+		{name: "negative", env: map[string]string{"TELEMETRY_RETENTION": "-1h"}, wantErr: true},
+		{name: "not a duration", env: map[string]string{"TELEMETRY_DEDUP_TTL": "30d"}, wantErr: true},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			t.Setenv("DYNAMODB_TABLE_NAME", "telemetry")
+			for _, name := range []string{"TELEMETRY_RETENTION", "TELEMETRY_DEDUP_TTL"} {
+				t.Setenv(name, test.env[name])
+			}
+
+			store, err := NewTelemetryStore(test.opts...)
+			if (err != nil) != test.wantErr {
+				t.Fatalf("NewTelemetryStore() error = %v, wantErr %v", err, test.wantErr)
+			}
+			if test.wantErr {
+				return
+			}
+			if store.Retention != test.wantRetention {
+				t.Fatalf("Retention = %s, want %s", store.Retention, test.wantRetention)
+			}
+			if store.DedupTTL != test.wantDedupTTL {
+				t.Errorf("DedupTTL = %s, want %s", store.DedupTTL, test.wantDedupTTL)
+			}
+
+			want := int64(0)
+			if test.wantRetention > 0 {
+				want = ingestedAt.Add(test.wantRetention).Unix()
+			}
+			if got := store.expiresAt(ingestedAt); got != want {
+				t.Errorf("expiresAt() = %d, want %d", got, want)
+			}
+		})
+	}
+}
+
+func TestExpiresAtOmittedWithoutRetention(t *testing.T) {
+	tests := []struct {
+		name      string
+		expiresAt int64
+		wantTTL   bool
+	}{
+		{name: "with retention", expiresAt: 1700086400, wantTTL: true},
+		{name: "retention disabled", expiresAt: 0, wantTTL: false},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			item, err := attributevalue.MarshalMap(models.Telemetry{DeviceID: "truck-1", Timestamp: 1700000000, ExpiresAt: test.expiresAt})
+			if err != nil {
+				t.Fatalf("MarshalMap: %v", err)
+			}
+			// readings kept forever carry no ttl attribute at all, not a zero one
+			if _, ok := item["expires_at"]; ok != test.wantTTL {
+				t.Errorf("expires_at written = %v, want %v", ok, test.wantTTL)
+			}
+		})
+	}
+}
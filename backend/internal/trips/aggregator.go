@@ -0,0 +1,146 @@
+package trips
+
+import (
+	"cmp"
+	"context"
+	"fmt"
+	"os"
+	"slices"
+	"time"
+
+	"github.com/Fleexa-Graduation-Project/Backend/internal/devices"
+	"github.com/Fleexa-Graduation-Project/Backend/internal/telemetry"
+	"github.com/Fleexa-Graduation-Project/Backend/models"
+	"github.com/Fleexa-Graduation-Project/Backend/pkg/driving"
+	"github.com/Fleexa-Graduation-Project/Backend/pkg/logger"
+	"github.com/Fleexa-Graduation-Project/Backend/pkg/trip"
+)
+
+// scheduled hourly, the window is much longer so a trip is seen whole in at least one run
+const DefaultLookback = 24 * time.Hour
+
+type Aggregator struct {
+	StateStore     *devices.StateStore
+	TelemetryStore telemetry.Store
+	TripStore      *TripStore
+	MaxGap         time.Duration
+	Lookback       time.Duration
+
+	// driving events are detected when EventStore is set, DeviceStore gives the fleet of each
+	// device for its thresholds
+	EventStore  *EventStore
+	DeviceStore devices.Registry
+	Driving     driving.Policy
+}
+
+// TRIP_MAX_GAP and TRIP_LOOKBACK override the defaults
+func NewAggregator(stateStore *devices.StateStore, telemetryStore telemetry.Store, tripStore *TripStore) (*Aggregator, error) {
+	aggregator := &Aggregator{
+		StateStore:     stateStore,
+		TelemetryStore: telemetryStore,
+		TripStore:      tripStore,
+		MaxGap:         trip.DefaultMaxGap,
+		Lookback:       DefaultLookback,
+	}
+
+	for name, target := range map[string]*time.Duration{"TRIP_MAX_GAP": &aggregator.MaxGap, "TRIP_LOOKBACK": &aggregator.Lookback} {
+		if raw := os.Getenv(name); raw != "" {
+			value, err := time.ParseDuration(raw)
+			if err != nil || value <= 0 {
+				return nil, fmt.Errorf("invalid %s %q", name, raw)
+			}
+			*target = value
+		}
+	}
+
+	return aggregator, nil
+}
+
+// Run rebuilds the trips and driving events of every device in the lookback window. A trip still
+// in progress is only written once its device has been silent for longer than the max gap
+func (aggregator *Aggregator) Run(ctx context.Context) error {
+	log := logger.FromContext(ctx)
+	now := time.Now()
+
+	states, err := aggregator.StateStore.GetAllStates(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to list devices for trip aggregation: %w", err)
+	}
+
+	written, detected := 0, 0
+	for _, state := range states {
+		history, err := aggregator.TelemetryStore.GetTelemetryHistory(ctx, state.DeviceID, 0, now.Add(-aggregator.Lookback).Unix())
+		if err != nil {
+			log.Error("failed to fetch telemetry for trips", "device_id", state.DeviceID, "error", err)
+			continue
+		}
+		points := toPoints(history)
+
+		for _, t := range aggregator.build(state.DeviceID, points, now) {
+			if err := aggregator.TripStore.SaveTrip(ctx, t); err != nil {
+				return err
+			}
+			written++
+		}
+
+		if aggregator.EventStore != nil {
+			count, err := aggregator.detect(ctx, state.DeviceID, points)
+			if err != nil {
+				return err
+			}
+			detected += count
+		}
+	}
+
+	log.Info("trip aggregation complete", "devices", len(states), "trips", written, "driving_events", detected)
+	return nil
+}
+
+// the readings as points, oldest first (the store returns newest first)
+func toPoints(history []models.Telemetry) []trip.Point {
+	slices.SortFunc(history, func(a, b models.Telemetry) int { return cmp.Compare(a.Timestamp, b.Timestamp) })
+
+	points := make([]trip.Point, 0, len(history))
+	for _, reading := range history {
+		points = append(points, trip.PointFromPayload(reading.Timestamp, reading.Payload))
+	}
+	return points
+}
+
+// saves the driving events of the device with its fleet's thresholds, unregistered devices use
+// the default ones
+func (aggregator *Aggregator) detect(ctx context.Context, deviceID string, points []trip.Point) (int, error) {
+	thresholds := aggregator.Driving.Default
+	if aggregator.DeviceStore != nil {
+		device, err := aggregator.DeviceStore.GetDevice(ctx, deviceID)
+		if err != nil {
+			return 0, fmt.Errorf("failed to fetch device %s for driving events: %w", deviceID, err)
+		}
+		if device != nil {
+			thresholds = aggregator.Driving.For(device.FleetID)
+		}
+	}
+
+	events := driving.Detect(deviceID, points, thresholds)
+	for _, event := range events {
+		if err := aggregator.EventStore.SaveEvent(ctx, event); err != nil {
+			return 0, err
+		}
+	}
+	return len(events), nil
+}
+
+func (aggregator *Aggregator) build(deviceID string, points []trip.Point, now time.Time) []trip.Trip {
+	builder := trip.NewSessionBuilder(deviceID, aggregator.MaxGap)
+	for _, point := range points {
+		// sensors without gps or ignition never make trips
+		if point.HasPosition || point.Ignition != nil {
+			builder.Add(point)
+		}
+	}
+
+	if open := builder.Open(); open != nil && now.Sub(time.Unix(open.EndTime, 0)) > aggregator.MaxGap {
+		return builder.Flush()
+	}
+	return builder.Trips()
+}
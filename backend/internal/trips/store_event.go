@@ -0,0 +1,82 @@
+package trips
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/attributevalue"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+
+	appconfig "github.com/Fleexa-Graduation-Project/Backend/pkg/config"
+	"github.com/Fleexa-Graduation-Project/Backend/pkg/db"
+	"github.com/Fleexa-Graduation-Project/Backend/pkg/driving"
+	"github.com/Fleexa-Graduation-Project/Backend/pkg/timeout"
+)
+
+// one item per driving event, keyed device_id (HASH) + event_id (RANGE, "<timestamp>#<type>")
+type EventStore struct {
+	Client    *dynamodb.Client
+	TableName string
+}
+
+func NewEventStore() (*EventStore, error) {
+	tableName := appconfig.TableName("DYNAMODB_DRIVING_EVENTS_TABLE")
+	if tableName == "" {
+		return nil, fmt.Errorf("DYNAMODB_DRIVING_EVENTS_TABLE environment variable is not set")
+	}
+
+	if db.Client == nil {
+		return nil, fmt.Errorf("dynamodb client is not initialized")
+	}
+
+	return &EventStore{
+		Client:    db.Client,
+		TableName: tableName,
+	}, nil
+}
+
+// SaveEvent overwrites an event with the same timestamp and type, so re-detecting a window is
+// safe and an idle period still going is updated with its new length
+func (store *EventStore) SaveEvent(ctx context.Context, event driving.Event) error {
+	item, err := attributevalue.MarshalMap(event)
+	if err != nil {
+		return fmt.Errorf("failed to marshal driving event: %w", err)
+	}
+
+	err = db.Retry(ctx, db.DefaultRetryPolicy, func() error {
+		callCtx, cancel := timeout.Call(ctx)
+		defer cancel()
+		_, putErr := store.Client.PutItem(callCtx, &dynamodb.PutItemInput{
+			TableName: aws.String(store.TableName),
+			Item:      item,
+		}, db.SingleAttempt)
+		return putErr
+	})
+	if err != nil {
+		return fmt.Errorf("failed to store driving event for device %s: %w", event.DeviceID, err)
+	}
+
+	return nil
+}
+
+// ListEventsSince returns the driving events of a device at or after since, oldest first. The
+// sort key compares as a string, which orders like the timestamps while they have 10 digits
+func (store *EventStore) ListEventsSince(ctx context.Context, deviceID string, since int64) ([]driving.Event, error) {
+	input := &dynamodb.QueryInput{
+		TableName:              aws.String(store.TableName),
+		KeyConditionExpression: aws.String("device_id = :id AND event_id >= :since"),
+		ExpressionAttributeValues: map[string]types.AttributeValue{
+			":id":    &types.AttributeValueMemberS{Value: deviceID},
+			":since": &types.AttributeValueMemberS{Value: fmt.Sprint(since)},
+		},
+	}
+
+	events, err := db.Collect(db.QueryAll[driving.Event](ctx, store.Client, input))
+	if err != nil {
+		return nil, fmt.Errorf("failed to query driving events for device %s: %w", deviceID, err)
+	}
+
+	return events, nil
+}
@@ -0,0 +1,80 @@
+package trips
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/attributevalue"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+
+	appconfig "github.com/Fleexa-Graduation-Project/Backend/pkg/config"
+	"github.com/Fleexa-Graduation-Project/Backend/pkg/db"
+	"github.com/Fleexa-Graduation-Project/Backend/pkg/timeout"
+	"github.com/Fleexa-Graduation-Project/Backend/pkg/trip"
+)
+
+// one item per trip, keyed device_id (HASH) + start_time (RANGE)
+type TripStore struct {
+	Client    *dynamodb.Client
+	TableName string
+}
+
+func NewTripStore() (*TripStore, error) {
+	tableName := appconfig.TableName("DYNAMODB_TRIPS_TABLE")
+	if tableName == "" {
+		return nil, fmt.Errorf("DYNAMODB_TRIPS_TABLE environment variable is not set")
+	}
+
+	if db.Client == nil {
+		return nil, fmt.Errorf("dynamodb client is not initialized")
+	}
+
+	return &TripStore{
+		Client:    db.Client,
+		TableName: tableName,
+	}, nil
+}
+
+// SaveTrip overwrites a trip with the same start, so re-aggregating a window is safe
+func (store *TripStore) SaveTrip(ctx context.Context, t trip.Trip) error {
+	item, err := attributevalue.MarshalMap(t)
+	if err != nil {
+		return fmt.Errorf("failed to marshal trip: %w", err)
+	}
+
+	err = db.Retry(ctx, db.DefaultRetryPolicy, func() error {
+		callCtx, cancel := timeout.Call(ctx)
+		defer cancel()
+		_, putErr := store.Client.PutItem(callCtx, &dynamodb.PutItemInput{
+			TableName: aws.String(store.TableName),
+			Item:      item,
+		}, db.SingleAttempt)
+		return putErr
+	})
+	if err != nil {
+		return fmt.Errorf("failed to store trip for device %s: %w", t.DeviceID, err)
+	}
+
+	return nil
+}
+
+// ListTripsSince returns the trips of a device that started at or after since
+func (store *TripStore) ListTripsSince(ctx context.Context, deviceID string, since int64) ([]trip.Trip, error) {
+	input := &dynamodb.QueryInput{
+		TableName:              aws.String(store.TableName),
+		KeyConditionExpression: aws.String("device_id = :id AND start_time >= :since"),
+		ExpressionAttributeValues: map[string]types.AttributeValue{
+			":id":    &types.AttributeValueMemberS{Value: deviceID},
+			":since": &types.AttributeValueMemberN{Value: fmt.Sprint(since)},
+		},
+	}
+
+	trips, err := db.Collect(db.QueryAll[trip.Trip](ctx, store.Client, input))
+	if err != nil {
+		return nil, fmt.Errorf("failed to query trips for device %s: %w", deviceID, err)
+	}
+
+	return trips, nil
+}
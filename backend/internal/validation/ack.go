@@ -0,0 +1,46 @@
+package validation
+
+import (
+	"fmt"
+	"strings"
+)
+
+// results a device reports for a command it received
+const (
+	AckSuccess = "success"
+	AckFailed  = "failed"
+
+	maxAckError = 256
+)
+
+// Ack is a device's answer to a command, sent as telemetry:
+// {"command_id": "cmd-...", "result": "success" | "failed", "error": "..."}
+type Ack struct {
+	CommandID string
+	Result    string
+	Error     string // optional, why a failed command failed
+}
+
+// IsCommandAck reports a telemetry payload that answers a command rather than carrying readings
+func IsCommandAck(payload map[string]interface{}) bool {
+	_, ok := payload["command_id"]
+	return ok
+}
+
+func ParseAck(payload map[string]interface{}) (Ack, error) {
+	commandID, _ := payload["command_id"].(string)
+	if strings.TrimSpace(commandID) == "" {
+		return Ack{}, fmt.Errorf("%w: command_id must be a non-empty string", ErrInvalidPayload)
+	}
+
+	result, _ := payload["result"].(string)
+	if result != AckSuccess && result != AckFailed {
+		return Ack{}, fmt.Errorf("%w: result must be %q or %q", ErrInvalidPayload, AckSuccess, AckFailed)
+	}
+
+	reason, _ := payload["error"].(string)
+	if len(reason) > maxAckError {
+		reason = reason[:maxAckError]
+	}
+	return Ack{CommandID: commandID, Result: result, Error: reason}, nil
+}
@@ -0,0 +1,41 @@
+package validation
+
+import (
+	"os"
+	"sync"
+	"time"
+)
+
+// ClockWindow is how far a device timestamp may be from the receive time and still be trusted
+type ClockWindow struct {
+	MaxFuture time.Duration
+	MaxAge    time.Duration
+}
+
+var (
+	clockWindow     = ClockWindow{MaxFuture: MaxFutureSkew, MaxAge: MaxTimestampAge}
+	clockWindowOnce sync.Once
+)
+
+// CLOCK_SKEW_MAX_FUTURE and CLOCK_SKEW_MAX_AGE override the window, read once per cold start.
+// pkg/config rejects malformed values before the first message, so they are not checked twice
+func loadClockWindow() ClockWindow {
+	clockWindowOnce.Do(func() {
+		for name, target := range map[string]*time.Duration{"CLOCK_SKEW_MAX_FUTURE": &clockWindow.MaxFuture, "CLOCK_SKEW_MAX_AGE": &clockWindow.MaxAge} {
+			if value, err := time.ParseDuration(os.Getenv(name)); err == nil && value > 0 {
+				*target = value
+			}
+		}
+	})
+	return clockWindow
+}
+
+// CorrectClock returns the timestamp to store a reading under. An implausible device timestamp
+// is replaced by the receive time so charts stay in order, skewed reports whether it was
+func CorrectClock(deviceTimestamp int64, received time.Time) (timestamp int64, skewed bool) {
+	window := loadClockWindow()
+	if deviceTimestamp > received.Add(window.MaxFuture).Unix() || deviceTimestamp < received.Add(-window.MaxAge).Unix() {
+		return received.Unix(), true
+	}
+	return deviceTimestamp, false
+}
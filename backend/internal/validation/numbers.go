@@ -0,0 +1,91 @@
+package validation
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+)
+
+// counters that outgrow float64's 53 bit mantissa (a float64 only holds integers up to 2^53
+// exactly), they are decoded as int64 instead
+var CounterFields = map[string]bool{
+	"odometer":       true,
+	"uptime_seconds": true,
+}
+
+// decodeMetrics decodes a payload with UseNumber. Counter fields become int64, every other
+// number float64 like plain json.Unmarshal, so the rest of the pipeline is unchanged
+func decodeMetrics(raw json.RawMessage) (map[string]interface{}, error) {
+	if len(raw) == 0 {
+		return nil, nil
+	}
+
+	decoder := json.NewDecoder(bytes.NewReader(raw))
+	decoder.UseNumber()
+
+	var metrics map[string]interface{}
+	if err := decoder.Decode(&metrics); err != nil {
+		return nil, fmt.Errorf("%w: payload unmarshal failed", ErrInvalidEnvelope)
+	}
+
+	for key, value := range metrics {
+		converted, err := convertNumbers(key, value)
+		if err != nil {
+			return nil, err
+		}
+		metrics[key] = converted
+	}
+	return metrics, nil
+}
+
+// walks nested objects and lists too, batch items carry their metrics one level down
+func convertNumbers(key string, value interface{}) (interface{}, error) {
+	switch v := value.(type) {
+	case json.Number:
+		if CounterFields[key] {
+			return parseCounter(key, v)
+		}
+		f, err := v.Float64()
+		if err != nil {
+			return nil, fmt.Errorf("%w: %s is out of range", ErrInvalidPayload, key)
+		}
+		return f, nil
+
+	case map[string]interface{}:
+		for nestedKey, nested := range v {
+			converted, err := convertNumbers(nestedKey, nested)
+			if err != nil {
+				return nil, err
+			}
+			v[nestedKey] = converted
+		}
+		return v, nil
+
+	case []interface{}:
+		for i, element := range v {
+			converted, err := convertNumbers(key, element)
+			if err != nil {
+				return nil, err
+			}
+			v[i] = converted
+		}
+		return v, nil
+	}
+	return value, nil
+}
+
+func parseCounter(key string, number json.Number) (int64, error) {
+	counter, err := strconv.ParseInt(number.String(), 10, 64)
+	if errors.Is(err, strconv.ErrRange) {
+		return 0, fmt.Errorf("%w: %s overflows int64", ErrInvalidPayload, key)
+	}
+	if err != nil {
+		return 0, fmt.Errorf("%w: %s must be an integer", ErrInvalidPayload, key)
+	}
+	if counter < 0 {
+		return 0, fmt.Errorf("%w: %s must not be negative", ErrInvalidPayload, key)
+	}
+	return counter, nil
+}
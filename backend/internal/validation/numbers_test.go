@@ -0,0 +1,58 @@
+package validation
+
+import (
+	"encoding/json"
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestDecodeMetrics(t *testing.T) {
+	tests := []struct {
+		name    string
+		raw     string
+		want    map[string]interface{}
+		wantErr error
+	}{
+		{name: "empty payload", raw: "", want: nil},
+		{
+			name: "plain numbers stay float64",
+			raw:  `{"temp": 4.5, "speed": 60}`,
+			want: map[string]interface{}{"temp": 4.5, "speed": 60.0},
+		},
+		{
+			// 2^53 + 1 is the first integer a float64 can't hold
+			name: "counters keep every digit",
+			raw:  `{"odometer": 9007199254740993, "uptime_seconds": 12}`,
+			want: map[string]interface{}{"odometer": int64(9007199254740993), "uptime_seconds": int64(12)},
+		},
+		{
+			name: "counters in batch items",
+			raw:  `{"items": [{"odometer": 9007199254740993, "speed": 10}]}`,
+			want: map[string]interface{}{"items": []interface{}{map[string]interface{}{"odometer": int64(9007199254740993), "speed": 10.0}}},
+		},
+		{name: "counter overflows int64", raw: `{"odometer": 9223372036854775808}`, wantErr: ErrInvalidPayload},
+		{name: "fractional counter", raw: `{"odometer": 12.5}`, wantErr: ErrInvalidPayload},
+		{name: "negative counter", raw: `{"uptime_seconds": -1}`, wantErr: ErrInvalidPayload},
+		{name: "float out of range", raw: `{"temp": 1e400}`, wantErr: ErrInvalidPayload},
+		{name: "not an object", raw: `[1, 2]`, wantErr: ErrInvalidEnvelope},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got, err := decodeMetrics(json.RawMessage(test.raw))
+			if test.wantErr != nil {
+				if !errors.Is(err, test.wantErr) {
+					t.Fatalf("decodeMetrics() error = %v, want %v", err, test.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("decodeMetrics() error = %v", err)
+			}
+			if !reflect.DeepEqual(got, test.want) {
+				t.Errorf("decodeMetrics() = %#v, want %#v", got, test.want)
+			}
+		})
+	}
+}
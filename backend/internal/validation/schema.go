@@ -0,0 +1,107 @@
+package validation
+
+import (
+	"encoding/json"
+	"fmt"
+	"log/slog"
+
+	"github.com/Fleexa-Graduation-Project/Backend/models"
+)
+
+// envelope shapes sent by the firmware generations in the field
+const (
+	SchemaV1             = 1 // {"device_id", "timestamp" (seconds), "type", "payload"}
+	SchemaV2             = 2 // {"device_id", "type", "sent_at_ms", "metrics"}
+	CurrentSchemaVersion = SchemaV2
+)
+
+type envelopeDecoder func(raw []byte, env *models.MQTTEnvelope) error
+
+var envelopeDecoders = map[int]envelopeDecoder{
+	SchemaV1: decodeV1,
+	SchemaV2: decodeV2,
+}
+
+// picks the decoder from schema_version, missing or unknown versions fall back to v1 so
+// older firmware keeps working during a rollout
+func decodeVersioned(raw []byte, env *models.MQTTEnvelope) error {
+	var header struct {
+		SchemaVersion interface{} `json:"schema_version"`
+		DeviceID      string      `json:"device_id"`
+	}
+	if err := json.Unmarshal(raw, &header); err != nil {
+		return fmt.Errorf("%w: payload unmarshal failed", ErrInvalidEnvelope)
+	}
+
+	version := SchemaV1
+	switch v := header.SchemaVersion.(type) {
+	case nil:
+		slog.Warn("telemetry without schema_version is deprecated, decoding as v1",
+			"device_id", header.DeviceID, "current_schema_version", CurrentSchemaVersion)
+	case float64:
+		if _, known := envelopeDecoders[int(v)]; known && v == float64(int(v)) {
+			version = int(v)
+		} else {
+			slog.Warn("unsupported telemetry schema_version, decoding as v1",
+				"device_id", header.DeviceID, "schema_version", v, "current_schema_version", CurrentSchemaVersion)
+		}
+	default:
+		slog.Warn("schema_version is not a number, decoding as v1",
+			"device_id", header.DeviceID, "schema_version", v)
+	}
+
+	if err := envelopeDecoders[version](raw, env); err != nil {
+		return err
+	}
+	env.SchemaVersion = version
+	return nil
+}
+
+func decodeV1(raw []byte, env *models.MQTTEnvelope) error {
+	var v1 struct {
+		DeviceID  string          `json:"device_id"`
+		Timestamp int64           `json:"timestamp"`
+		Type      string          `json:"type"`
+		Payload   json.RawMessage `json:"payload"`
+	}
+	if err := json.Unmarshal(raw, &v1); err != nil {
+		return fmt.Errorf("%w: payload unmarshal failed", ErrInvalidEnvelope)
+	}
+	payload, err := decodeMetrics(v1.Payload)
+	if err != nil {
+		return err
+	}
+
+	*env = models.MQTTEnvelope{
+		DeviceID:  v1.DeviceID,
+		Timestamp: v1.Timestamp,
+		Type:      v1.Type,
+		Payload:   payload,
+	}
+	return nil
+}
+
+// v2 firmware reports milliseconds and renamed payload to metrics
+func decodeV2(raw []byte, env *models.MQTTEnvelope) error {
+	var v2 struct {
+		DeviceID string          `json:"device_id"`
+		Type     string          `json:"type"`
+		SentAtMS int64           `json:"sent_at_ms"`
+		Metrics  json.RawMessage `json:"metrics"`
+	}
+	if err := json.Unmarshal(raw, &v2); err != nil {
+		return fmt.Errorf("%w: v2 payload unmarshal failed", ErrInvalidEnvelope)
+	}
+	metrics, err := decodeMetrics(v2.Metrics)
+	if err != nil {
+		return err
+	}
+
+	*env = models.MQTTEnvelope{
+		DeviceID:  v2.DeviceID,
+		Timestamp: v2.SentAtMS / 1000,
+		Type:      v2.Type,
+		Payload:   metrics,
+	}
+	return nil
+}
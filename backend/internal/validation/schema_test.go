@@ -0,0 +1,73 @@
+package validation
+
+import (
+	"errors"
+	"maps"
+	"testing"
+
+	"github.com/Fleexa-Graduation-Project/Backend/models"
+)
+
+func TestDecodeVersioned(t *testing.T) {
+	tests := []struct {
+		name    string
+		raw     string
+		want    models.MQTTEnvelope
+		wantErr error
+	}{
+		{
+			name: "v1",
+			raw:  `{"schema_version": 1, "device_id": "truck-1", "timestamp": 1700000000, "type": "gps", "payload": {"speed": 60}}`,
+			want: models.MQTTEnvelope{DeviceID: "truck-1", Timestamp: 1700000000, Type: "gps", Payload: map[string]interface{}{"speed": 60.0}, SchemaVersion: SchemaV1},
+		},
+		{
+			name: "v2 reports milliseconds and metrics",
+			raw:  `{"schema_version": 2, "device_id": "truck-1", "sent_at_ms": 1700000000123, "type": "gps", "metrics": {"speed": 60}}`,
+			want: models.MQTTEnvelope{DeviceID: "truck-1", Timestamp: 1700000000, Type: "gps", Payload: map[string]interface{}{"speed": 60.0}, SchemaVersion: SchemaV2},
+		},
+		{
+			name: "missing version decodes as v1",
+			raw:  `{"device_id": "truck-1", "timestamp": 1700000000, "type": "temp", "payload": {"temp": 4}}`,
+			want: models.MQTTEnvelope{DeviceID: "truck-1", Timestamp: 1700000000, Type: "temp", Payload: map[string]interface{}{"temp": 4.0}, SchemaVersion: SchemaV1},
+		},
+		{
+			name: "unknown version decodes as v1",
+			raw:  `{"schema_version": 9, "device_id": "truck-1", "timestamp": 1700000000, "type": "temp", "payload": {"temp": 4}}`,
+			want: models.MQTTEnvelope{DeviceID: "truck-1", Timestamp: 1700000000, Type: "temp", Payload: map[string]interface{}{"temp": 4.0}, SchemaVersion: SchemaV1},
+		},
+		{
+			name: "fractional version decodes as v1",
+			raw:  `{"schema_version": 1.5, "device_id": "truck-1", "timestamp": 1700000000, "type": "temp", "payload": {"temp": 4}}`,
+			want: models.MQTTEnvelope{DeviceID: "truck-1", Timestamp: 1700000000, Type: "temp", Payload: map[string]interface{}{"temp": 4.0}, SchemaVersion: SchemaV1},
+		},
+		{
+			name: "version as a string decodes as v1",
+			raw:  `{"schema_version": "2", "device_id": "truck-1", "timestamp": 1700000000, "type": "temp", "payload": {"temp": 4}}`,
+			want: models.MQTTEnvelope{DeviceID: "truck-1", Timestamp: 1700000000, Type: "temp", Payload: map[string]interface{}{"temp": 4.0}, SchemaVersion: SchemaV1},
+		},
+		{name: "not json", raw: `{"device_id": `, wantErr: ErrInvalidEnvelope},
+		{name: "v2 with a metrics list", raw: `{"schema_version": 2, "device_id": "truck-1", "metrics": [1, 2]}`, wantErr: ErrInvalidEnvelope},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var got models.MQTTEnvelope
+			err := decodeVersioned([]byte(test.raw), &got)
+			if test.wantErr != nil {
+				if !errors.Is(err, test.wantErr) {
+					t.Fatalf("decodeVersioned() error = %v, want %v", err, test.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("decodeVersioned() error = %v", err)
+			}
+			if got.DeviceID != test.want.DeviceID || got.Timestamp != test.want.Timestamp || got.Type != test.want.Type || got.SchemaVersion != test.want.SchemaVersion {
+				t.Errorf("decodeVersioned() = %+v, want %+v", got, test.want)
+			}
+			if !maps.Equal(got.Payload, test.want.Payload) {
+				t.Errorf("payload = %v, want %v", got.Payload, test.want.Payload)
+			}
+		})
+	}
+}
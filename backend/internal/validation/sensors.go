@@ -0,0 +1,143 @@
+package validation
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/Fleexa-Graduation-Project/Backend/models"
+)
+
+// the most samples one gateway message may carry
+const MaxSensorSamples = 25
+
+// ElementError is one element of a list in the payload that failed validation
+type ElementError struct {
+	Index int
+	Err   error
+}
+
+// SamplesError lists every failed element of payload.sensors, it matches ErrInvalidPayload with
+// errors.Is
+type SamplesError struct {
+	Elements []ElementError
+}
+
+func (e *SamplesError) Error() string {
+	parts := make([]string, 0, len(e.Elements))
+	for _, element := range e.Elements {
+		parts = append(parts, fmt.Sprintf("sensors[%d]: %v", element.Index, element.Err))
+	}
+	return strings.Join(parts, "; ")
+}
+
+func (e *SamplesError) Unwrap() error {
+	return ErrInvalidPayload
+}
+
+// IsMultiSensor reports a gateway message carrying samples of several attached sensors in
+// payload.sensors, each is validated on its own by Readings
+func IsMultiSensor(payload map[string]interface{}) bool {
+	_, ok := payload["sensors"].([]interface{})
+	return ok
+}
+
+// SensorDeviceID is the device id a sensor sample of a gateway is stored under. MQTT topics
+// can't carry '#', so it never collides with a real device id
+func SensorDeviceID(gatewayID, sensorID string) string {
+	return gatewayID + "#" + sensorID
+}
+
+// Readings fans a validated telemetry message out into the readings it carries. A single
+// reading is the message itself. A gateway message gives one reading per element of
+// payload.sensors ({"sensor_id": ..., "type": ..., "ts": ..., metrics}), stored under
+// SensorDeviceID; type defaults to the envelope's and ts to its timestamp. Elements that fail
+// validation are left out and reported by index in a *SamplesError next to the valid readings
+func Readings(envelope models.MQTTEnvelope, received time.Time) ([]models.Telemetry, error) {
+	single := models.Telemetry{
+		DeviceID:        envelope.DeviceID,
+		Timestamp:       envelope.Timestamp,
+		Type:            envelope.Type,
+		Payload:         envelope.Payload,
+		DeviceTimestamp: envelope.DeviceTimestamp,
+		ClockSkew:       envelope.ClockSkew,
+	}
+	if !IsMultiSensor(envelope.Payload) {
+		return []models.Telemetry{single}, nil
+	}
+
+	samples := envelope.Payload["sensors"].([]interface{})
+	if len(samples) == 0 {
+		return nil, fmt.Errorf("%w: sensors must not be empty", ErrInvalidPayload)
+	}
+	if len(samples) > MaxSensorSamples {
+		return nil, fmt.Errorf("%w: at most %d sensor samples per message", ErrInvalidPayload, MaxSensorSamples)
+	}
+
+	readings := make([]models.Telemetry, 0, len(samples))
+	failed := &SamplesError{}
+	seen := map[string]bool{}
+	for i, raw := range samples {
+		reading, err := sensorReading(single, raw, received)
+		if err == nil && seen[reading.SensorID] {
+			err = fmt.Errorf("%w: duplicate sensor_id %q", ErrInvalidPayload, reading.SensorID)
+		}
+		if err != nil {
+			failed.Elements = append(failed.Elements, ElementError{Index: i, Err: err})
+			continue
+		}
+		seen[reading.SensorID] = true
+		readings = append(readings, reading)
+	}
+
+	if len(failed.Elements) > 0 {
+		return readings, failed
+	}
+	return readings, nil
+}
+
+func sensorReading(gateway models.Telemetry, raw interface{}, received time.Time) (models.Telemetry, error) {
+	sample, ok := raw.(map[string]interface{})
+	if !ok {
+		return models.Telemetry{}, fmt.Errorf("%w: sample must be an object", ErrInvalidPayload)
+	}
+
+	sensorID, _ := sample["sensor_id"].(string)
+	if strings.TrimSpace(sensorID) == "" || strings.ContainsAny(sensorID, "#/") {
+		return models.Telemetry{}, fmt.Errorf("%w: sensor_id must be a non-empty string without '#' or '/'", ErrInvalidPayload)
+	}
+
+	deviceType := gateway.Type
+	if rawType, present := sample["type"]; present {
+		if deviceType, ok = rawType.(string); !ok || deviceType == "" {
+			return models.Telemetry{}, fmt.Errorf("%w: type must be a non-empty string", ErrInvalidPayload)
+		}
+	}
+
+	payload := make(map[string]interface{}, len(sample))
+	for key, value := range sample {
+		if key != "sensor_id" && key != "type" {
+			payload[key] = value
+		}
+	}
+	if err := NormalizeUnits(payload); err != nil {
+		return models.Telemetry{}, err
+	}
+	if err := ValidatePayload(deviceType, payload); err != nil {
+		return models.Telemetry{}, err
+	}
+
+	reading := gateway
+	reading.DeviceID = SensorDeviceID(gateway.DeviceID, sensorID)
+	reading.SensorID = sensorID
+	reading.Type = deviceType
+	reading.Payload = payload
+	if ts, ok := payload["ts"].(float64); ok {
+		corrected, skewed := CorrectClock(int64(ts), received)
+		reading.Timestamp, reading.DeviceTimestamp, reading.ClockSkew = corrected, 0, skewed
+		if skewed {
+			reading.DeviceTimestamp = int64(ts)
+		}
+	}
+	return reading, nil
+}
@@ -9,6 +9,8 @@ import (
 
 	"github.com/Fleexa-Graduation-Project/Backend/internal/devices"
 	"github.com/Fleexa-Graduation-Project/Backend/models"
+	appconfig "github.com/Fleexa-Graduation-Project/Backend/pkg/config"
+	"github.com/Fleexa-Graduation-Project/Backend/pkg/units"
 )
 
 var (
@@ -18,8 +20,47 @@ var (
 	ErrInvalidPayload  = errors.New("invalid payload")
 )
 
-//validating incoming messages
-func ValidateMessage(event map[string]interface{}) (
+const (
+	// default plausibility window of device timestamps
+	MaxFutureSkew   = 5 * time.Minute // device clocks drift a bit ahead
+	MaxTimestampAge = 24 * time.Hour
+)
+
+// FieldError is a single envelope field that failed validation
+type FieldError struct {
+	Field  string `json:"field"`
+	Reason string `json:"reason"`
+}
+
+// ValidationError lists every envelope field that failed, it matches ErrInvalidEnvelope with errors.Is
+type ValidationError struct {
+	Fields []FieldError
+}
+
+func (e *ValidationError) add(field, reason string) {
+	e.Fields = append(e.Fields, FieldError{Field: field, Reason: reason})
+}
+
+func (e *ValidationError) Error() string {
+	parts := make([]string, 0, len(e.Fields))
+	for _, f := range e.Fields {
+		parts = append(parts, f.Field+": "+f.Reason)
+	}
+	return fmt.Sprintf("%v: %s", ErrInvalidEnvelope, strings.Join(parts, ", "))
+}
+
+func (e *ValidationError) Unwrap() error {
+	return ErrInvalidEnvelope
+}
+
+// ValidateMessage validates a message received now, see ValidateMessageAt
+func ValidateMessage(event map[string]interface{}) (string, string, models.MQTTEnvelope, bool, error) {
+	return ValidateMessageAt(event, time.Now())
+}
+
+// validating incoming messages. received is when the message reached AWS, a skewed reading is
+// stored under it, so it has to be the same on every redelivery of the message
+func ValidateMessageAt(event map[string]interface{}, received time.Time) (
 	deviceID string,
 	messageType string,
 	envelope models.MQTTEnvelope,
@@ -34,7 +75,7 @@ func ValidateMessage(event map[string]interface{}) (
 	}
 
 	// validating topic
-	deviceID, messageType, err = validateTopic(topic)
+	deviceID, messageType, err = ParseTopic(topic)
 	if err != nil {
 		return "", "", envelope, false, err
 	}
@@ -44,6 +85,25 @@ func ValidateMessage(event map[string]interface{}) (
 		return "", "", envelope, false, err
 	}
 
+	// a device with a bad clock is stored under the receive time, not rejected
+	envelope.ReceivedAt = received
+	if envelope.Timestamp != 0 {
+		if corrected, skewed := CorrectClock(envelope.Timestamp, received); skewed {
+			envelope.DeviceTimestamp = envelope.Timestamp
+			envelope.Timestamp = corrected
+			envelope.ClockSkew = true
+		}
+	}
+
+	// firmware that can only publish on the telemetry topic flags heartbeats in the payload
+	if messageType == "telemetry" && IsHeartbeat(envelope.Payload) {
+		messageType = "heartbeat"
+	}
+	// command acks come in on the telemetry topic as well
+	if messageType == "telemetry" && IsCommandAck(envelope.Payload) {
+		messageType = "ack"
+	}
+
 	// CHECK FOR BATCH: Look for "items" key in the payload
 	if messageType == "telemetry" {
 	if items, ok := envelope.Payload["items"].([]interface{}); ok && len(items) > 0 {
@@ -52,13 +112,23 @@ func ValidateMessage(event map[string]interface{}) (
 }
 
 	// validating envelope fields
-	if err := validateEnvelope(envelope, deviceID); err != nil {
+	if err := validateEnvelope(envelope, deviceID, messageType != "heartbeat"); err != nil {
 		return "", "", envelope, false, err
 	}
 
 	// validating payload structure
 	// If it is a batch, we SKIP deep validation here (we will do it in the loop later)
-	if !isBatch {
+	// gateway samples are validated one by one in Readings, so a bad one names its index
+	fanOut := messageType == "telemetry" && IsMultiSensor(envelope.Payload)
+	if messageType == "ack" {
+		if _, err := ParseAck(envelope.Payload); err != nil {
+			return "", "", envelope, false, err
+		}
+	}
+	if !isBatch && !fanOut && messageType != "heartbeat" && messageType != "ack" {
+		if err := NormalizeUnits(envelope.Payload); err != nil {
+			return "", "", envelope, false, err
+		}
 		if err := ValidatePayload(envelope.Type, envelope.Payload); err != nil {
 			return "", "", envelope, false, err
 		}
@@ -90,21 +160,21 @@ func validateEvent(event map[string]interface{}) (string, interface{}, error) {
 	return topic, payload, nil
 }
 
-func validateTopic(topic string) (string, string, error) {
+// ParseTopic splits devices/{id}/{type}, under RESOURCE_PREFIX, into the device id and the message type (telemetry, alerts or heartbeat)
+func ParseTopic(topic string) (deviceID string, messageType string, err error) {
 	parts := strings.Split(topic, "/")
 	if len(parts) != 3 {
 		return "", "", fmt.Errorf("%w: expected devices/{id}/{type}", ErrInvalidTopic)
 	}
-	if parts[0] != "devices" {
+	if parts[0] != appconfig.Topic("devices") {
 		return "", "", fmt.Errorf("%w: invalid topic root", ErrInvalidTopic)
 	}
-	deviceID := parts[1]
-	messageType := parts[2]
+	deviceID, messageType = parts[1], parts[2]
 	if deviceID == "" {
 		return "", "", fmt.Errorf("%w: empty device id", ErrInvalidTopic)
 	}
 	switch messageType {
-	case "telemetry", "alerts":
+	case "telemetry", "alerts", "heartbeat":
 		return deviceID, messageType, nil
 	default:
 		return "", "", fmt.Errorf("%w: unsupported message type", ErrInvalidTopic)
@@ -119,32 +189,43 @@ func decodeEnvelope(payloadRaw interface{}, env *models.MQTTEnvelope) error {
 	if len(bytes) > 32*1024 {
 		return fmt.Errorf("%w: payload too large", ErrInvalidPayload)
 	}
-	if err := json.Unmarshal(bytes, env); err != nil {
-		return fmt.Errorf("%w: payload unmarshal failed", ErrInvalidEnvelope)
-	}
-	return nil
+	return decodeVersioned(bytes, env)
 }
 
-func validateEnvelope(env models.MQTTEnvelope, topicDeviceID string) error {
+// heartbeats carry no metrics, requirePayload is false for them
+func validateEnvelope(env models.MQTTEnvelope, topicDeviceID string, requirePayload bool) error {
+	verr := &ValidationError{}
+
 	if env.DeviceID == "" {
-		return fmt.Errorf("%w: missing device_id", ErrInvalidEnvelope)
-	}
-	if env.DeviceID != topicDeviceID {
-		return fmt.Errorf("%w: device_id mismatch", ErrInvalidEnvelope)
+		verr.add("device_id", "required")
+	} else if env.DeviceID != topicDeviceID {
+		verr.add("device_id", "does not match topic")
 	}
-	now := time.Now().Unix()
-	if env.Timestamp > now+60 {
-		return fmt.Errorf("%w: timestamp in the future", ErrInvalidEnvelope)
+
+	// skewed clocks are corrected before this, see CorrectClock
+	if env.Timestamp == 0 {
+		verr.add("timestamp", "required")
 	}
+
 	if env.Type == "" {
-		return fmt.Errorf("%w: missing device type", ErrInvalidEnvelope)
+		verr.add("type", "required")
+	}
+	if requirePayload && len(env.Payload) == 0 {
+		verr.add("payload", "at least one metric is required")
 	}
-	if len(env.Payload) == 0 {
-		return fmt.Errorf("%w: empty payload", ErrInvalidEnvelope)
+
+	if len(verr.Fields) > 0 {
+		return verr
 	}
 	return nil
 }
 
+// IsHeartbeat reports a telemetry payload that only says the device is alive: {"heartbeat": true}
+func IsHeartbeat(payload map[string]interface{}) bool {
+	heartbeat, _ := payload["heartbeat"].(bool)
+	return heartbeat
+}
+
 func ValidatePayload(deviceType string, payload map[string]interface{}) error {
 	rules, ok := devices.Rules[deviceType]
 	if !ok {
@@ -161,4 +242,37 @@ func ValidatePayload(deviceType string, payload map[string]interface{}) error {
 		}
 	}
 	return nil
-}
\ No newline at end of file
+}
+
+// metrics whose unit depends on the firmware region, converted in place to km/h and Celsius
+var unitConversions = map[string]struct {
+	unitKey string
+	convert func(value float64, unit string) (float64, error)
+}{
+	"temp":  {unitKey: "temp_unit", convert: units.TempToCelsius},
+	"speed": {unitKey: "speed_unit", convert: units.SpeedToKPH},
+}
+
+// NormalizeUnits rewrites the payload to canonical units and drops the unit keys
+func NormalizeUnits(payload map[string]interface{}) error {
+	for metric, conversion := range unitConversions {
+		unitRaw, hasUnit := payload[conversion.unitKey]
+		if !hasUnit {
+			continue
+		}
+		unit, ok := unitRaw.(string)
+		if !ok {
+			return fmt.Errorf("%w: %s must be a string", ErrInvalidPayload, conversion.unitKey)
+		}
+
+		if value, ok := payload[metric].(float64); ok {
+			converted, err := conversion.convert(value, unit)
+			if err != nil {
+				return fmt.Errorf("%w: %v", ErrInvalidPayload, err)
+			}
+			payload[metric] = converted
+		}
+		delete(payload, conversion.unitKey)
+	}
+	return nil
+}
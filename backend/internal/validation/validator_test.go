@@ -0,0 +1,102 @@
+package validation
+
+import (
+	"errors"
+	"maps"
+	"testing"
+)
+
+func TestNormalizeUnits(t *testing.T) {
+	tests := []struct {
+		name    string
+		payload map[string]interface{}
+		want    map[string]interface{}
+		wantErr bool
+	}{
+		{
+			name:    "canonical units stay",
+			payload: map[string]interface{}{"temp": 4.0, "speed": 60.0},
+			want:    map[string]interface{}{"temp": 4.0, "speed": 60.0},
+		},
+		{
+			name:    "converted and unit keys dropped",
+			payload: map[string]interface{}{"temp": 212.0, "temp_unit": "F", "speed": 10.0, "speed_unit": "m/s"},
+			want:    map[string]interface{}{"temp": 100.0, "speed": 36.0},
+		},
+		{
+			name:    "unit without a value is dropped",
+			payload: map[string]interface{}{"speed_unit": "mph", "fuel_level": 40.0},
+			want:    map[string]interface{}{"fuel_level": 40.0},
+		},
+		{
+			name:    "unknown unit",
+			payload: map[string]interface{}{"temp": 500.0, "temp_unit": "rankine"},
+			wantErr: true,
+		},
+		{
+			name:    "unit is not a string",
+			payload: map[string]interface{}{"speed": 10.0, "speed_unit": 3.6},
+			wantErr: true,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			err := NormalizeUnits(test.payload)
+			if test.wantErr {
+				if !errors.Is(err, ErrInvalidPayload) {
+					t.Fatalf("NormalizeUnits() error = %v, want ErrInvalidPayload", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("NormalizeUnits() error = %v", err)
+			}
+			if !maps.Equal(test.payload, test.want) {
+				t.Errorf("payload = %v, want %v", test.payload, test.want)
+			}
+		})
+	}
+}
+
+func TestParseTopic(t *testing.T) {
+	tests := []struct {
+		name        string
+		prefix      string
+		topic       string
+		wantDevice  string
+		wantType    string
+		wantInvalid bool
+	}{
+		{name: "telemetry", topic: "devices/truck-1/telemetry", wantDevice: "truck-1", wantType: "telemetry"},
+		{name: "alerts", topic: "devices/truck-1/alerts", wantDevice: "truck-1", wantType: "alerts"},
+		{name: "heartbeat", topic: "devices/truck-1/heartbeat", wantDevice: "truck-1", wantType: "heartbeat"},
+		{name: "stage prefix", prefix: "staging-", topic: "staging-devices/truck-1/telemetry", wantDevice: "truck-1", wantType: "telemetry"},
+		{name: "unprefixed root on a stage", prefix: "staging-", topic: "devices/truck-1/telemetry", wantInvalid: true},
+		{name: "other root", topic: "fleets/truck-1/telemetry", wantInvalid: true},
+		{name: "empty device id", topic: "devices//telemetry", wantInvalid: true},
+		{name: "unsupported type", topic: "devices/truck-1/commands", wantInvalid: true},
+		{name: "too many levels", topic: "devices/truck-1/telemetry/extra", wantInvalid: true},
+		{name: "too few levels", topic: "devices/truck-1", wantInvalid: true},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			t.Setenv("RESOURCE_PREFIX", test.prefix)
+
+			deviceID, messageType, err := ParseTopic(test.topic)
+			if test.wantInvalid {
+				if !errors.Is(err, ErrInvalidTopic) {
+					t.Fatalf("ParseTopic(%q) error = %v, want ErrInvalidTopic", test.topic, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("ParseTopic(%q) error = %v", test.topic, err)
+			}
+			if deviceID != test.wantDevice || messageType != test.wantType {
+				t.Errorf("ParseTopic(%q) = %s %s, want %s %s", test.topic, deviceID, messageType, test.wantDevice, test.wantType)
+			}
+		})
+	}
+}
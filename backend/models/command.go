@@ -6,5 +6,13 @@ type Command struct {
 	Timestamp int64                  `json:"timestamp" dynamodbav:"timestamp"`
 	Action    string                 `json:"action" dynamodbav:"action"`
 	Parameters map[string]interface{} `json:"parameters" dynamodbav:"parameters"`
+	Status    string                 `json:"status" dynamodbav:"status"` // PENDING until the device acknowledges it
 	ExpiresAt int64                  `json:"expires_at" dynamodbav:"expires_at"`
+
+	SentAtMs     int64  `json:"sent_at_ms,omitempty" dynamodbav:"sent_at_ms,omitempty"`
+	AckedAt      int64  `json:"acked_at,omitempty" dynamodbav:"acked_at,omitempty"` // the device reported ACKED or FAILED
+	TimedOutAt   int64  `json:"timed_out_at,omitempty" dynamodbav:"timed_out_at,omitempty"`
+	RoundTripMs  int64  `json:"round_trip_ms,omitempty" dynamodbav:"round_trip_ms,omitempty"`
+	Error        string `json:"error,omitempty" dynamodbav:"error,omitempty"` // reason a FAILED device gave
+	PendingSince int64  `json:"-" dynamodbav:"pending_since,omitempty"`      // only set while PENDING, keys the sparse PendingIndex
 }
@@ -0,0 +1,17 @@
+package models
+
+// registered device, written once at onboarding (live readings live in DeviceState)
+type Device struct {
+	DeviceID  string `json:"device_id" dynamodbav:"device_id"`
+	Name      string `json:"name" dynamodbav:"name"`
+	Model     string `json:"model" dynamodbav:"model"` // device type: temp-sensor, door-actuator etc.
+	FleetID   string `json:"fleet_id" dynamodbav:"fleet_id"`
+	CreatedAt int64  `json:"created_at" dynamodbav:"created_at"`
+
+	FirmwareVersion string   `json:"firmware_version,omitempty" dynamodbav:"firmware_version,omitempty"` // last reported in telemetry
+	Status          string   `json:"status,omitempty" dynamodbav:"status,omitempty"`                     // "deactivated" once the fleet's contract ended
+	SigningSecret   string   `json:"-" dynamodbav:"signing_secret,omitempty"`                            // hmac key of signed payloads, never returned by the api
+	Tags            []string `json:"tags,omitempty" dynamodbav:"tags,stringset,omitempty"`               // operator labels like "refrigerated" or "region:west"
+
+	ReportInterval int64 `json:"report_interval_seconds,omitempty" dynamodbav:"report_interval_seconds,omitempty"` // how often it is expected to report, 0 uses the default
+}
@@ -9,4 +9,8 @@ type DeviceState struct {
 	Payload          map[string]interface{} `json:"payload" dynamodbav:"payload"` // Raw sensor data (temp, gas_level)
 	LastSeenAt       int64                  `json:"last_seen_at" dynamodbav:"last_seen_at"`
 	LastUpdated      int64                  `json:"-" dynamodbav:"updated_at"` 
+	OfflineAlertedAt int64                  `json:"-" dynamodbav:"offline_alerted_at,omitempty"` // set once the offline alert fired
+	LowBatteryAlertedAt int64               `json:"-" dynamodbav:"low_battery_alerted_at,omitempty"` // set while the low battery alert is fired, cleared on recovery
+	LowFuelAlertedAt    int64               `json:"-" dynamodbav:"low_fuel_alerted_at,omitempty"`
+	ConnectivityScore   *int                `json:"connectivity_score,omitempty" dynamodbav:"connectivity_score,omitempty"` // 0-100, share of expected reports received, set by the hourly rollup
 }
\ No newline at end of file
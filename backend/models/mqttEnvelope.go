@@ -1,9 +1,20 @@
 package models
 
+import "time"
+
 // incoming message structure
 type MQTTEnvelope struct {
 	DeviceID  string                 `json:"device_id"`
 	Timestamp int64                  `json:"timestamp"`
 	Type      string                 `json:"type"`
 	Payload   map[string]interface{} `json:"payload"`
+
+	SchemaVersion int `json:"schema_version,omitempty"` // set by the decoder that parsed the message
+
+	// set when the device clock was implausible and Timestamp was replaced by the receive time
+	DeviceTimestamp int64 `json:"-"`
+	ClockSkew       bool  `json:"-"`
+
+	// when the message reached AWS, the time device clocks are checked against
+	ReceivedAt time.Time `json:"-"`
 }
\ No newline at end of file
@@ -5,7 +5,14 @@ type Telemetry struct {
 	Timestamp int64            `json:"timestamp" dynamodbav:"timestamp"`
 	Type      string           `json:"type" dynamodbav:"type"`      
 	Payload   map[string]interface{} `json:"payload" dynamodbav:"payload"`
-	ExpiresAt int64            `json:"expires_at" dynamodbav:"expires_at"` 
+	ExpiresAt int64            `json:"expires_at" dynamodbav:"expires_at,omitempty"` // no ttl when retention is disabled
+
+	// Timestamp is the receive time when the device clock was off, the reported time is kept here
+	DeviceTimestamp int64 `json:"device_timestamp,omitempty" dynamodbav:"device_timestamp,omitempty"`
+	ClockSkew       bool  `json:"clock_skew,omitempty" dynamodbav:"clock_skew,omitempty"`
+
+	// set on the readings a gateway fans out, DeviceID is then "<gateway>#<sensor>"
+	SensorID string `json:"sensor_id,omitempty" dynamodbav:"sensor_id,omitempty"`
 }
 
 
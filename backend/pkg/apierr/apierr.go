@@ -0,0 +1,57 @@
+package apierr
+
+import (
+	"fmt"
+	"net/http"
+)
+
+// APIError is an error a handler returns to pick the response, Message is shown to the client
+// and Err (optional) is only logged
+type APIError struct {
+	Status  int
+	Code    string // machine readable, e.g. "not_found"
+	Message string
+	Err     error
+}
+
+func (e *APIError) Error() string {
+	if e.Err != nil {
+		return fmt.Sprintf("%s: %s: %v", e.Code, e.Message, e.Err)
+	}
+	return e.Code + ": " + e.Message
+}
+
+func (e *APIError) Unwrap() error {
+	return e.Err
+}
+
+// Wrap keeps the underlying error for the logs
+func (e *APIError) Wrap(err error) *APIError {
+	copied := *e
+	copied.Err = err
+	return &copied
+}
+
+func New(status int, code, message string) *APIError {
+	return &APIError{Status: status, Code: code, Message: message}
+}
+
+func BadRequest(message string) *APIError {
+	return New(http.StatusBadRequest, "bad_request", message)
+}
+
+func Unauthorized(message string) *APIError {
+	return New(http.StatusUnauthorized, "unauthorized", message)
+}
+
+func Forbidden(message string) *APIError {
+	return New(http.StatusForbidden, "forbidden", message)
+}
+
+func NotFound(message string) *APIError {
+	return New(http.StatusNotFound, "not_found", message)
+}
+
+func Conflict(message string) *APIError {
+	return New(http.StatusConflict, "conflict", message)
+}
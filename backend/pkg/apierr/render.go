@@ -0,0 +1,41 @@
+package apierr
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/Fleexa-Graduation-Project/Backend/pkg/breaker"
+	"github.com/Fleexa-Graduation-Project/Backend/pkg/httpresp"
+	"github.com/Fleexa-Graduation-Project/Backend/pkg/logger"
+	"github.com/Fleexa-Graduation-Project/Backend/pkg/timeout"
+)
+
+// Render writes err as the json error envelope. A ValidationError is a 422 listing its fields,
+// an APIError keeps its status, code and message, a blown deadline is a 504, an open circuit
+// breaker a 503 and anything else is a generic 500 whose detail only goes to the logs
+func Render(c *gin.Context, err error) {
+	log := logger.FromContext(c.Request.Context())
+
+	var apiErr *APIError
+	var validationErr *ValidationError
+	switch {
+	case errors.As(err, &validationErr):
+		httpresp.ErrorFields(c, http.StatusUnprocessableEntity, "validation", validationErr.Message(), validationErr.Fields)
+	case errors.As(err, &apiErr):
+		if apiErr.Err != nil {
+			log.Warn("request failed", "path", c.FullPath(), "status", apiErr.Status, "code", apiErr.Code, "error", apiErr.Err)
+		}
+		httpresp.ErrorCode(c, apiErr.Status, apiErr.Code, apiErr.Message)
+	case timeout.IsTimeout(err):
+		log.Error("dependency call timed out", "reason", "dependency_timeout", "path", c.FullPath(), "error", err)
+		httpresp.ErrorCode(c, http.StatusGatewayTimeout, "dependency_timeout", timeout.ErrDependencyTimeout.Error())
+	case errors.Is(err, breaker.ErrOpen):
+		log.Error("dependency unavailable", "reason", "breaker_open", "path", c.FullPath(), "error", err)
+		httpresp.ErrorCode(c, http.StatusServiceUnavailable, "dependency_unavailable", "dependency_unavailable")
+	default:
+		log.Error("request failed", "path", c.FullPath(), "error", err)
+		httpresp.ErrorCode(c, http.StatusInternalServerError, "internal_error", "Internal server error")
+	}
+}
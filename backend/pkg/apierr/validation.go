@@ -0,0 +1,40 @@
+package apierr
+
+import "strings"
+
+// FieldError is one request field that failed validation, Reason is short ("required")
+type FieldError struct {
+	Field  string `json:"field"`
+	Reason string `json:"reason"`
+}
+
+// ValidationError collects every bad field of a request so they are all reported at once.
+// Render answers 422 with {"error": {"code": "validation", "fields": [...]}}
+type ValidationError struct {
+	Fields []FieldError
+}
+
+func (e *ValidationError) Add(field, reason string) {
+	e.Fields = append(e.Fields, FieldError{Field: field, Reason: reason})
+}
+
+// Err is nil when no field was added, so the collector can be returned as is
+func (e *ValidationError) Err() error {
+	if len(e.Fields) == 0 {
+		return nil
+	}
+	return e
+}
+
+func (e *ValidationError) Error() string {
+	return "validation: " + e.Message()
+}
+
+// Message lists the fields in one line, "name: required, fleet_id: required"
+func (e *ValidationError) Message() string {
+	parts := make([]string, 0, len(e.Fields))
+	for _, field := range e.Fields {
+		parts = append(parts, field.Field+": "+field.Reason)
+	}
+	return strings.Join(parts, ", ")
+}
@@ -0,0 +1,129 @@
+package audit
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/attributevalue"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"github.com/google/uuid"
+
+	appconfig "github.com/Fleexa-Graduation-Project/Backend/pkg/config"
+	"github.com/Fleexa-Graduation-Project/Backend/pkg/db"
+	"github.com/Fleexa-Graduation-Project/Backend/pkg/timeout"
+)
+
+const (
+	OutcomeSuccess = "success"
+	OutcomeFailure = "failure"
+
+	DefaultQueryLimit = 50
+	MaxQueryLimit     = 200
+)
+
+// AuditEvent is one state-changing operation, Resource is "<kind>:<id>" (e.g. device:gas-sensor-01)
+type AuditEvent struct {
+	Resource  string `json:"resource" dynamodbav:"resource"`
+	EventKey  string `json:"-" dynamodbav:"event_key"`
+	Timestamp int64  `json:"timestamp" dynamodbav:"timestamp"` // unix milliseconds
+	Actor     string `json:"actor" dynamodbav:"actor"`
+	Role      string `json:"role,omitempty" dynamodbav:"role,omitempty"`
+	Action    string `json:"action" dynamodbav:"action"`
+	Outcome   string `json:"outcome" dynamodbav:"outcome"`
+	Reason    string `json:"reason,omitempty" dynamodbav:"reason,omitempty"`
+	RequestID string `json:"request_id,omitempty" dynamodbav:"request_id,omitempty"`
+}
+
+// Resource builds the "<kind>:<id>" key events are grouped by
+func Resource(kind, id string) string {
+	return kind + ":" + id
+}
+
+// append-only audit trail keyed resource (HASH) + event_key (RANGE), event_key is the zero padded
+// millisecond timestamp plus a random suffix so it sorts by time and never overwrites an event
+type Store struct {
+	Client    *dynamodb.Client
+	TableName string
+}
+
+func NewStore() (*Store, error) {
+	tableName := appconfig.TableName("DYNAMODB_AUDIT_TABLE")
+	if tableName == "" {
+		return nil, fmt.Errorf("DYNAMODB_AUDIT_TABLE environment variable is not set")
+	}
+
+	if db.Client == nil {
+		return nil, fmt.Errorf("dynamodb client is not initialized")
+	}
+
+	return &Store{
+		Client:    db.Client,
+		TableName: tableName,
+	}, nil
+}
+
+// Record appends the event, the timestamp defaults to now
+func (store *Store) Record(ctx context.Context, event AuditEvent) error {
+	if event.Timestamp == 0 {
+		event.Timestamp = time.Now().UnixMilli()
+	}
+	event.EventKey = fmt.Sprintf("%013d#%s", event.Timestamp, uuid.NewString()[:8])
+
+	item, err := attributevalue.MarshalMap(event)
+	if err != nil {
+		return fmt.Errorf("failed to marshal audit event: %w", err)
+	}
+
+	callCtx, cancel := timeout.Call(ctx)
+	defer cancel()
+	_, err = store.Client.PutItem(callCtx, &dynamodb.PutItemInput{
+		TableName:           aws.String(store.TableName),
+		Item:                item,
+		ConditionExpression: aws.String("attribute_not_exists(event_key)"),
+	})
+	if err != nil {
+		return fmt.Errorf("failed to record audit event %s on %s: %w", event.Action, event.Resource, err)
+	}
+
+	return nil
+}
+
+// Recent returns the latest events of a resource, newest first
+func (store *Store) Recent(ctx context.Context, resource string, limit int) ([]AuditEvent, error) {
+	if limit <= 0 {
+		limit = DefaultQueryLimit
+	}
+	if limit > MaxQueryLimit {
+		limit = MaxQueryLimit
+	}
+
+	input := &dynamodb.QueryInput{
+		TableName:              aws.String(store.TableName),
+		KeyConditionExpression: aws.String("#resource = :resource"),
+		ExpressionAttributeNames: map[string]string{
+			"#resource": "resource",
+		},
+		ExpressionAttributeValues: map[string]types.AttributeValue{
+			":resource": &types.AttributeValueMemberS{Value: resource},
+		},
+		ScanIndexForward: aws.Bool(false),
+		Limit:            aws.Int32(int32(limit)),
+	}
+
+	callCtx, cancel := timeout.Call(ctx)
+	defer cancel()
+	result, err := store.Client.Query(callCtx, input)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query audit events of %s: %w", resource, err)
+	}
+
+	events := []AuditEvent{}
+	if err = attributevalue.UnmarshalListOfMaps(result.Items, &events); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal audit events of %s: %w", resource, err)
+	}
+
+	return events, nil
+}
@@ -0,0 +1,78 @@
+package awsreq
+
+import (
+	"context"
+	"errors"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	awsmiddleware "github.com/aws/aws-sdk-go-v2/aws/middleware"
+	awshttp "github.com/aws/aws-sdk-go-v2/aws/transport/http"
+	"github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/smithy-go/middleware"
+)
+
+// CallError is a failed sdk call with what a support case needs, the message is the sdk one
+type CallError struct {
+	Service   string
+	Operation string
+	RequestID string
+	Err       error
+}
+
+func (e *CallError) Error() string {
+	return e.Err.Error()
+}
+
+func (e *CallError) Unwrap() error {
+	return e.Err
+}
+
+// LoadConfig is config.LoadDefaultConfig with the request id capture and the circuit breaker on
+// every client built from it
+func LoadConfig(ctx context.Context) (aws.Config, error) {
+	cfg, err := config.LoadDefaultConfig(ctx)
+	if err != nil {
+		return aws.Config{}, err
+	}
+	cfg.APIOptions = append(cfg.APIOptions, Capture, Breaker)
+	return cfg, nil
+}
+
+// Capture wraps the error of a failed call in a *CallError. It sits first in the initialize step
+// so it sees the final error after the sdk retries
+func Capture(stack *middleware.Stack) error {
+	return stack.Initialize.Add(middleware.InitializeMiddlewareFunc("CaptureRequestID",
+		func(ctx context.Context, in middleware.InitializeInput, next middleware.InitializeHandler) (middleware.InitializeOutput, middleware.Metadata, error) {
+			out, metadata, err := next.HandleInitialize(ctx, in)
+			if err == nil {
+				return out, metadata, nil
+			}
+
+			requestID, _ := awsmiddleware.GetRequestIDMetadata(metadata)
+			var responseErr *awshttp.ResponseError
+			if requestID == "" && errors.As(err, &responseErr) {
+				requestID = responseErr.ServiceRequestID()
+			}
+
+			return out, metadata, &CallError{
+				Service:   awsmiddleware.GetServiceID(ctx),
+				Operation: awsmiddleware.GetOperationName(ctx),
+				RequestID: requestID,
+				Err:       err,
+			}
+		}), middleware.Before)
+}
+
+// LogAttrs are the log attributes of the call, the logger adds them to records carrying the error
+func (e *CallError) LogAttrs() []any {
+	return []any{"aws_request_id", e.RequestID, "aws_service", e.Service, "aws_operation", e.Operation}
+}
+
+// Attrs are the log attributes of the aws call behind err, none when err didn't come from one
+func Attrs(err error) []any {
+	var callErr *CallError
+	if !errors.As(err, &callErr) {
+		return nil
+	}
+	return callErr.LogAttrs()
+}
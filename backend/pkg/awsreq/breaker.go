@@ -0,0 +1,121 @@
+package awsreq
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"reflect"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	awsmiddleware "github.com/aws/aws-sdk-go-v2/aws/middleware"
+	awshttp "github.com/aws/aws-sdk-go-v2/aws/transport/http"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/smithy-go"
+	"github.com/aws/smithy-go/middleware"
+
+	"github.com/Fleexa-Graduation-Project/Backend/pkg/breaker"
+)
+
+// error codes that mean the service is overloaded, sent with a 400 by dynamodb
+var throttlingCodes = map[string]bool{
+	"ThrottlingException":                    true,
+	"ProvisionedThroughputExceededException": true,
+	"RequestLimitExceeded":                   true,
+	"TooManyRequestsException":               true,
+}
+
+// Breaker puts every call behind the circuit breaker of its service (DynamoDB, S3, IoT Data
+// Plane...), an open breaker fails the call with breaker.ErrOpen before anything is sent.
+// DynamoDB capacity is per table, so its calls get a breaker per table ("DynamoDB/telemetry"),
+// one throttled table doesn't fail the calls to the others.
+// It sits after Capture in the initialize step, so it sees the error after the sdk retries
+func Breaker(stack *middleware.Stack) error {
+	return stack.Initialize.Add(middleware.InitializeMiddlewareFunc("CircuitBreaker",
+		func(ctx context.Context, in middleware.InitializeInput, next middleware.InitializeHandler) (middleware.InitializeOutput, middleware.Metadata, error) {
+			name := awsmiddleware.GetServiceID(ctx)
+			if table := tableOf(in.Parameters); table != "" {
+				name += "/" + table
+			}
+			b := breaker.For(name)
+			if err := b.Allow(); err != nil {
+				return middleware.InitializeOutput{}, middleware.Metadata{}, err
+			}
+
+			out, metadata, err := next.HandleInitialize(ctx, in)
+			// the caller gave up, that says nothing about the service
+			if !errors.Is(err, context.Canceled) {
+				b.Record(isServiceFailure(err))
+			}
+			return out, metadata, err
+		}), middleware.After)
+}
+
+// tableOf is the one table a call goes to, empty for calls without a table or spanning several
+func tableOf(params interface{}) string {
+	tables := map[string]bool{}
+	switch input := params.(type) {
+	case *dynamodb.BatchWriteItemInput:
+		for table := range input.RequestItems {
+			tables[table] = true
+		}
+	case *dynamodb.BatchGetItemInput:
+		for table := range input.RequestItems {
+			tables[table] = true
+		}
+	case *dynamodb.TransactWriteItemsInput:
+		for _, item := range input.TransactItems {
+			switch {
+			case item.Put != nil:
+				tables[aws.ToString(item.Put.TableName)] = true
+			case item.Update != nil:
+				tables[aws.ToString(item.Update.TableName)] = true
+			case item.Delete != nil:
+				tables[aws.ToString(item.Delete.TableName)] = true
+			case item.ConditionCheck != nil:
+				tables[aws.ToString(item.ConditionCheck.TableName)] = true
+			}
+		}
+	default:
+		// the single table inputs (GetItem, PutItem, Query...) all have a TableName
+		value := reflect.ValueOf(params)
+		if value.Kind() != reflect.Pointer || value.IsNil() || value.Elem().Kind() != reflect.Struct {
+			return ""
+		}
+		field := value.Elem().FieldByName("TableName")
+		if !field.IsValid() {
+			return ""
+		}
+		name, _ := field.Interface().(*string)
+		return aws.ToString(name)
+	}
+
+	if len(tables) != 1 {
+		return ""
+	}
+	for table := range tables {
+		return table
+	}
+	return ""
+}
+
+// only errors that point at the service count, a 4xx (failed condition, missing resource) is a
+// working service rejecting the request
+func isServiceFailure(err error) bool {
+	if err == nil {
+		return false
+	}
+
+	var apiErr smithy.APIError
+	if errors.As(err, &apiErr) && throttlingCodes[apiErr.ErrorCode()] {
+		return true
+	}
+
+	var responseErr *awshttp.ResponseError
+	if errors.As(err, &responseErr) {
+		status := responseErr.HTTPStatusCode()
+		return status >= http.StatusInternalServerError || status == http.StatusTooManyRequests
+	}
+
+	// no response at all: timeout, connection refused, dns
+	return true
+}
@@ -0,0 +1,43 @@
+package awsreq
+
+import (
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+)
+
+func TestTableOf(t *testing.T) {
+	put := func(table string) types.TransactWriteItem {
+		return types.TransactWriteItem{Put: &types.Put{TableName: aws.String(table)}}
+	}
+
+	tests := []struct {
+		name   string
+		params interface{}
+		want   string
+	}{
+		{name: "single table call", params: &dynamodb.PutItemInput{TableName: aws.String("telemetry")}, want: "telemetry"},
+		{name: "query", params: &dynamodb.QueryInput{TableName: aws.String("alerts")}, want: "alerts"},
+		{
+			name:   "batch write to one table",
+			params: &dynamodb.BatchWriteItemInput{RequestItems: map[string][]types.WriteRequest{"telemetry": nil}},
+			want:   "telemetry",
+		},
+		{name: "transaction on one table", params: &dynamodb.TransactWriteItemsInput{TransactItems: []types.TransactWriteItem{put("telemetry"), put("telemetry")}}, want: "telemetry"},
+		{name: "transaction over two tables", params: &dynamodb.TransactWriteItemsInput{TransactItems: []types.TransactWriteItem{put("telemetry"), put("devices")}}},
+		{name: "call without a table", params: &dynamodb.ListTablesInput{}},
+		{name: "other service", params: &s3.PutObjectInput{Bucket: aws.String("archive")}},
+		{name: "no input", params: nil},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if got := tableOf(test.params); got != test.want {
+				t.Errorf("tableOf() = %q, want %q", got, test.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,97 @@
+package awsreq
+
+import (
+	"context"
+	"os"
+	"sync"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/iotdataplane"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/sns"
+	"github.com/aws/aws-sdk-go-v2/service/sqs"
+)
+
+// built on the first get, later and concurrent gets share it
+type lazy[T any] struct {
+	once  sync.Once
+	value T
+}
+
+func (l *lazy[T]) get(build func() T) T {
+	l.once.Do(func() { l.value = build() })
+	return l.value
+}
+
+// Clients hands out one sdk client per service, each built from the config the first time it is
+// asked for, so a lambda only pays for the clients its invocations use. Safe for concurrent use
+type Clients struct {
+	cfg aws.Config
+
+	dynamodb     lazy[*dynamodb.Client]
+	s3           lazy[*s3.Client]
+	sqs          lazy[*sqs.Client]
+	sns          lazy[*sns.Client]
+	iotdataplane lazy[*iotdataplane.Client]
+}
+
+func NewClients(cfg aws.Config) *Clients {
+	return &Clients{cfg: cfg}
+}
+
+var sharedClients struct {
+	mu      sync.Mutex
+	clients *Clients
+}
+
+// Shared is the Clients of the process over Config, the same set for every caller and warm
+// invocation. A failed config load is not kept, like Config
+func Shared(ctx context.Context) (*Clients, error) {
+	sharedClients.mu.Lock()
+	defer sharedClients.mu.Unlock()
+
+	if sharedClients.clients != nil {
+		return sharedClients.clients, nil
+	}
+
+	cfg, err := Config(ctx)
+	if err != nil {
+		return nil, err
+	}
+	sharedClients.clients = NewClients(cfg)
+	return sharedClients.clients, nil
+}
+
+// Config is the config the clients are built from, for clients that need their own options
+func (clients *Clients) Config() aws.Config {
+	return clients.cfg
+}
+
+// DynamoDB points at DYNAMODB_ENDPOINT when it is set, dynamodb-local for local runs
+func (clients *Clients) DynamoDB() *dynamodb.Client {
+	return clients.dynamodb.get(func() *dynamodb.Client {
+		endpoint := os.Getenv("DYNAMODB_ENDPOINT")
+		return dynamodb.NewFromConfig(clients.cfg, func(o *dynamodb.Options) {
+			if endpoint != "" {
+				o.BaseEndpoint = aws.String(endpoint)
+			}
+		})
+	})
+}
+
+func (clients *Clients) S3() *s3.Client {
+	return clients.s3.get(func() *s3.Client { return s3.NewFromConfig(clients.cfg) })
+}
+
+func (clients *Clients) SQS() *sqs.Client {
+	return clients.sqs.get(func() *sqs.Client { return sqs.NewFromConfig(clients.cfg) })
+}
+
+func (clients *Clients) SNS() *sns.Client {
+	return clients.sns.get(func() *sns.Client { return sns.NewFromConfig(clients.cfg) })
+}
+
+func (clients *Clients) IoTDataPlane() *iotdataplane.Client {
+	return clients.iotdataplane.get(func() *iotdataplane.Client { return iotdataplane.NewFromConfig(clients.cfg) })
+}
@@ -0,0 +1,45 @@
+package awsreq
+
+import (
+	"sync"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+)
+
+func TestClientsBuiltOnce(t *testing.T) {
+	clients := NewClients(aws.Config{Region: "eu-west-1"})
+
+	tests := []struct {
+		name string
+		get  func() any
+	}{
+		{name: "dynamodb", get: func() any { return clients.DynamoDB() }},
+		{name: "s3", get: func() any { return clients.S3() }},
+		{name: "sqs", get: func() any { return clients.SQS() }},
+		{name: "sns", get: func() any { return clients.SNS() }},
+		{name: "iot data plane", get: func() any { return clients.IoTDataPlane() }},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			// concurrent first calls must all get the one client
+			got := make([]any, 8)
+			var wg sync.WaitGroup
+			for i := range got {
+				wg.Add(1)
+				go func() {
+					defer wg.Done()
+					got[i] = test.get()
+				}()
+			}
+			wg.Wait()
+
+			for i := range got {
+				if got[i] == nil || got[i] != got[0] {
+					t.Fatalf("call %d got %p, want the client of call 0 %p", i, got[i], got[0])
+				}
+			}
+		})
+	}
+}
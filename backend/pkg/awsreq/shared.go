@@ -0,0 +1,34 @@
+package awsreq
+
+import (
+	"context"
+	"sync"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+)
+
+var shared struct {
+	mu     sync.Mutex
+	loaded bool
+	cfg    aws.Config
+}
+
+// Config is LoadConfig run once per process. Loading reads the environment and the shared
+// config files (about 5ms, against microseconds for building a client from it), every client
+// of a lambda shares the result and warm invocations reuse it. Safe for concurrent use, a failed
+// load is not kept so the next caller tries again
+func Config(ctx context.Context) (aws.Config, error) {
+	shared.mu.Lock()
+	defer shared.mu.Unlock()
+
+	if shared.loaded {
+		return shared.cfg, nil
+	}
+
+	cfg, err := LoadConfig(ctx)
+	if err != nil {
+		return aws.Config{}, err
+	}
+	shared.cfg, shared.loaded = cfg, true
+	return cfg, nil
+}
@@ -0,0 +1,193 @@
+package breaker
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/Fleexa-Graduation-Project/Backend/pkg/metrics"
+)
+
+const (
+	DefaultFailureThreshold = 5
+	DefaultCooldown         = 30 * time.Second
+)
+
+// ErrOpen is returned without calling the dependency while its breaker is open
+var ErrOpen = errors.New("circuit breaker open")
+
+type State int
+
+const (
+	Closed   State = iota // calls go through, failures are counted
+	Open                  // calls fail fast until the cooldown is over
+	HalfOpen              // one probe call decides between closed and open
+)
+
+func (s State) String() string {
+	switch s {
+	case Open:
+		return "open"
+	case HalfOpen:
+		return "half-open"
+	default:
+		return "closed"
+	}
+}
+
+// Config is shared by every dependency, a FailureThreshold of 0 disables the breakers
+type Config struct {
+	FailureThreshold int
+	Cooldown         time.Duration
+}
+
+// LoadConfig reads BREAKER_FAILURE_THRESHOLD (consecutive failures that open a breaker) and
+// BREAKER_COOLDOWN, invalid values fall back to the defaults
+func LoadConfig() Config {
+	cfg := Config{FailureThreshold: DefaultFailureThreshold, Cooldown: DefaultCooldown}
+
+	if raw := os.Getenv("BREAKER_FAILURE_THRESHOLD"); raw != "" {
+		if threshold, err := strconv.Atoi(raw); err == nil && threshold >= 0 {
+			cfg.FailureThreshold = threshold
+		} else {
+			slog.Warn("invalid BREAKER_FAILURE_THRESHOLD, falling back to default", "value", raw, "default", DefaultFailureThreshold)
+		}
+	}
+	if raw := os.Getenv("BREAKER_COOLDOWN"); raw != "" {
+		if cooldown, err := time.ParseDuration(raw); err == nil && cooldown > 0 {
+			cfg.Cooldown = cooldown
+		} else {
+			slog.Warn("invalid BREAKER_COOLDOWN, falling back to default", "value", raw, "default", DefaultCooldown)
+		}
+	}
+	return cfg
+}
+
+// Breaker guards one dependency. It lives as long as the lambda container, so an outage seen
+// by one invocation makes the next ones fail fast too
+type Breaker struct {
+	Name string
+	cfg  Config
+
+	mu          sync.Mutex
+	state       State
+	failures    int
+	openedAt    time.Time
+	probeSentAt time.Time // zero when no half-open probe is in flight
+}
+
+func New(name string, cfg Config) *Breaker {
+	return &Breaker{Name: name, cfg: cfg}
+}
+
+var (
+	registryMu sync.Mutex
+	registry   = map[string]*Breaker{}
+	loadOnce   sync.Once
+	shared     Config
+)
+
+// For returns the breaker of a dependency ("DynamoDB", "fcm"...), created on first use with
+// LoadConfig. Each dependency has its own, an outage of one doesn't trip the others
+func For(name string) *Breaker {
+	loadOnce.Do(func() { shared = LoadConfig() })
+
+	registryMu.Lock()
+	defer registryMu.Unlock()
+	if b, ok := registry[name]; ok {
+		return b
+	}
+	b := New(name, shared)
+	registry[name] = b
+	return b
+}
+
+// Allow returns ErrOpen when the call must not be made. An allowed call is followed by Record,
+// unless its outcome says nothing about the dependency (the caller cancelled)
+func (b *Breaker) Allow() error {
+	if b.cfg.FailureThreshold <= 0 {
+		return nil
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	now := time.Now()
+	switch b.state {
+	case Open:
+		if now.Sub(b.openedAt) < b.cfg.Cooldown {
+			return b.reject()
+		}
+		b.transition(HalfOpen)
+		b.probeSentAt = now
+		return nil
+
+	case HalfOpen:
+		// a probe that never reported back (panic, lost context) doesn't block forever
+		if !b.probeSentAt.IsZero() && now.Sub(b.probeSentAt) < b.cfg.Cooldown {
+			return b.reject()
+		}
+		b.probeSentAt = now
+		return nil
+	}
+	return nil
+}
+
+// Record reports how an allowed call went. failed is for dependency failures only (timeouts,
+// 5xx, throttling), a rejected request (not found, failed condition) means the dependency is up
+func (b *Breaker) Record(failed bool) {
+	if b.cfg.FailureThreshold <= 0 {
+		return
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if !failed {
+		b.failures = 0
+		if b.state != Closed {
+			b.transition(Closed)
+		}
+		return
+	}
+
+	b.failures++
+	if b.state == HalfOpen || b.failures >= b.cfg.FailureThreshold {
+		b.openedAt = time.Now()
+		if b.state != Open {
+			b.transition(Open)
+		}
+	}
+}
+
+// State is the current state, for logs and health checks
+func (b *Breaker) State() State {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.state
+}
+
+// called with mu held
+func (b *Breaker) transition(to State) {
+	from := b.state
+	b.state = to
+	b.probeSentAt = time.Time{}
+
+	dims := map[string]string{"Dependency": b.Name, "State": to.String()}
+	metrics.Count("CircuitBreakerTransitions", 1, dims)
+	if to == Open {
+		slog.Error("circuit breaker opened", "reason", "breaker_open", "dependency", b.Name, "from", from.String(), "consecutive_failures", b.failures, "cooldown", b.cfg.Cooldown.String())
+	} else {
+		slog.Warn("circuit breaker state changed", "dependency", b.Name, "from", from.String(), "to", to.String())
+	}
+}
+
+// called with mu held
+func (b *Breaker) reject() error {
+	metrics.Count("CircuitBreakerRejected", 1, map[string]string{"Dependency": b.Name})
+	return fmt.Errorf("%w: %s", ErrOpen, b.Name)
+}
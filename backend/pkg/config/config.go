@@ -0,0 +1,193 @@
+package config
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Fleexa-Graduation-Project/Backend/pkg/timeout"
+)
+
+// env names of the tables, stores still read their own variable, these are for Require
+const (
+	TelemetryTable          = "DYNAMODB_TABLE_NAME"
+	DeviceStateTable        = "DYNAMODB_DEVICE_STATE_TABLE"
+	DevicesTable            = "DYNAMODB_DEVICES_TABLE"
+	AlertsTable             = "DYNAMODB_ALERTS_TABLE"
+	CommandsTable           = "DYNAMODB_COMMANDS_TABLE"
+	GeofencesTable          = "DYNAMODB_GEOFENCES_TABLE"
+	ConnectionsTable        = "DYNAMODB_CONNECTIONS_TABLE"
+	TripsTable              = "DYNAMODB_TRIPS_TABLE"
+	RateLimitsTable         = "DYNAMODB_RATE_LIMITS_TABLE"
+	PendingAlertsTable      = "DYNAMODB_PENDING_ALERTS_TABLE"
+	AuditTable              = "DYNAMODB_AUDIT_TABLE"
+	ControlTable            = "DYNAMODB_CONTROL_TABLE"
+	BreachesTable           = "DYNAMODB_BREACHES_TABLE"
+	ProvisioningTokensTable = "DYNAMODB_PROVISIONING_TOKENS_TABLE"
+	HourlyAggregatesTable   = "DYNAMODB_HOURLY_AGGREGATES_TABLE"
+	ShadowsTable            = "DYNAMODB_SHADOWS_TABLE"
+	LatestStateTable        = "DYNAMODB_LATEST_STATE_TABLE"
+	DrivingEventsTable      = "DYNAMODB_DRIVING_EVENTS_TABLE"
+	IdempotencyTable        = "DYNAMODB_IDEMPOTENCY_TABLE"
+	AlertThrottleTable      = "DYNAMODB_ALERT_THROTTLE_TABLE"
+)
+
+type Config struct {
+	Tables map[string]string // env name -> table with RESOURCE_PREFIX, only the ones that are set
+
+	LogLevel       string
+	CallTimeout    time.Duration
+	DeadlineMargin time.Duration
+	CORSOrigins    []string
+
+	QuarantineBucket    string
+	ArchiveBucket       string
+	ArchiveEnabled      bool
+	IngestionQueueURL   string
+	WebsocketEndpoint   string
+	FirebaseCredentials string
+}
+
+var tableNames = []string{
+	TelemetryTable, DeviceStateTable, DevicesTable, AlertsTable, CommandsTable,
+	GeofencesTable, ConnectionsTable, TripsTable, RateLimitsTable, PendingAlertsTable, AuditTable, ControlTable, BreachesTable, ProvisioningTokensTable,
+	HourlyAggregatesTable, ShadowsTable, LatestStateTable, DrivingEventsTable, IdempotencyTable, AlertThrottleTable,
+}
+
+// optional settings that are parsed where they are used, Load only checks their format
+var (
+	durationVars            = []string{"RATE_LIMIT_WINDOW", "RATE_LIMIT_SYNC_INTERVAL", "OFFLINE_THRESHOLD", "TRIP_MAX_GAP", "TRIP_LOOKBACK", "FLEET_STATS_TTL", "CLOCK_SKEW_MAX_FUTURE", "CLOCK_SKEW_MAX_AGE", "BREAKER_COOLDOWN", "REPORT_INTERVAL_DEFAULT", "CONNECTIVITY_WINDOW", "INGESTION_HEARTBEAT_INTERVAL", "INGESTION_DEADMAN_WINDOW", "IDEMPOTENCY_TTL", "COMMAND_ACK_TIMEOUT", "FEATURE_FLAGS_REFRESH", "DEADLINE_YIELD_MARGIN", "ALERT_THROTTLE_WINDOW", "API_THROTTLE_TIMEOUT"}
+	nonNegativeDurationVars = []string{"TELEMETRY_RETENTION", "TELEMETRY_DEDUP_TTL"} // "0" disables
+	intVars                 = []string{"MAX_REQUEST_BODY_BYTES", "MAX_DECOMPRESSED_BYTES", "RATE_LIMIT_PER_WINDOW", "SEQ_RESET_THRESHOLD", "DEVICE_IMPORT_MAX_ROWS", "BREAKER_FAILURE_THRESHOLD", "RESPONSE_GZIP_MIN_BYTES", "TELEMETRY_EXPORT_MAX_ROWS", "API_THROTTLE_BURST"}
+	rateVars                = []string{"INGESTION_BATCH_FAILURE_ALERT_RATE", "LOG_SAMPLE_RATE"} // between 0 and 1
+	jsonVars                = []string{"OTA_TARGETS", "RATE_LIMIT_FLEET_OVERRIDES", "LOW_RESOURCE_THRESHOLDS", "LOW_RESOURCE_FLEET_THRESHOLDS", "DRIVING_THRESHOLDS", "DRIVING_FLEET_THRESHOLDS", "API_THROTTLE_PLANS"}
+)
+
+// Load reads the environment, applies defaults and returns every invalid value in one error,
+// so a misconfigured deployment shows all of its problems at the first cold start
+func Load() (*Config, error) {
+	var errs []error
+
+	cfg := &Config{
+		Tables:              map[string]string{},
+		LogLevel:            strings.ToLower(strings.TrimSpace(os.Getenv("LOG_LEVEL"))),
+		QuarantineBucket:    os.Getenv("QUARANTINE_BUCKET"),
+		ArchiveBucket:       os.Getenv("TELEMETRY_ARCHIVE_BUCKET"),
+		ArchiveEnabled:      os.Getenv("TELEMETRY_ARCHIVE_ENABLED") == "true",
+		IngestionQueueURL:   os.Getenv("INGESTION_QUEUE_URL"),
+		WebsocketEndpoint:   os.Getenv("WEBSOCKET_ENDPOINT"),
+		FirebaseCredentials: os.Getenv("FIREBASE_CREDENTIALS"),
+	}
+
+	if err := validateResourcePrefix(ResourcePrefix()); err != nil {
+		errs = append(errs, err)
+	}
+	for _, name := range tableNames {
+		if table := TableName(name); table != "" {
+			cfg.Tables[name] = table
+		}
+	}
+
+	switch cfg.LogLevel {
+	case "":
+		cfg.LogLevel = "info"
+	case "debug", "info", "warn", "warning", "error":
+	default:
+		errs = append(errs, fmt.Errorf("LOG_LEVEL: unknown level %q", cfg.LogLevel))
+	}
+
+	var err error
+	if cfg.CallTimeout, err = duration("AWS_CALL_TIMEOUT", timeout.DefaultCallTimeout); err != nil {
+		errs = append(errs, err)
+	}
+	if cfg.DeadlineMargin, err = duration("HANDLER_DEADLINE_MARGIN", timeout.DefaultMargin); err != nil {
+		errs = append(errs, err)
+	}
+
+	for _, origin := range strings.Split(os.Getenv("CORS_ALLOWED_ORIGINS"), ",") {
+		if origin = strings.TrimSpace(origin); origin != "" {
+			cfg.CORSOrigins = append(cfg.CORSOrigins, origin)
+		}
+	}
+
+	if cfg.ArchiveEnabled && cfg.ArchiveBucket == "" {
+		errs = append(errs, errors.New("TELEMETRY_ARCHIVE_BUCKET: required when TELEMETRY_ARCHIVE_ENABLED=true"))
+	}
+
+	for _, name := range durationVars {
+		if _, err := duration(name, 0); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	for _, name := range nonNegativeDurationVars {
+		if raw := os.Getenv(name); raw != "" {
+			if value, err := time.ParseDuration(raw); err != nil || value < 0 {
+				errs = append(errs, fmt.Errorf("%s: %q is not a non-negative duration", name, raw))
+			}
+		}
+	}
+	for _, name := range intVars {
+		if raw := os.Getenv(name); raw != "" {
+			if value, err := strconv.ParseInt(raw, 10, 64); err != nil || value < 0 {
+				errs = append(errs, fmt.Errorf("%s: %q is not a non-negative number", name, raw))
+			}
+		}
+	}
+	for _, name := range rateVars {
+		if raw := os.Getenv(name); raw != "" {
+			if value, err := strconv.ParseFloat(raw, 64); err != nil || value < 0 || value > 1 {
+				errs = append(errs, fmt.Errorf("%s: %q is not a rate between 0 and 1", name, raw))
+			}
+		}
+	}
+	for _, name := range jsonVars {
+		if raw := os.Getenv(name); raw != "" && !json.Valid([]byte(raw)) {
+			errs = append(errs, fmt.Errorf("%s: not valid json", name))
+		}
+	}
+
+	if len(errs) > 0 {
+		return nil, fmt.Errorf("invalid configuration: %w", errors.Join(errs...))
+	}
+	return cfg, nil
+}
+
+// Require lists every variable a lambda can't start without that is unset, in one error
+func (cfg *Config) Require(names ...string) error {
+	var missing []string
+	for _, name := range names {
+		if os.Getenv(name) == "" {
+			missing = append(missing, name)
+		}
+	}
+	if len(missing) > 0 {
+		return fmt.Errorf("missing required environment variables: %s", strings.Join(missing, ", "))
+	}
+	return nil
+}
+
+// LoadRequired is Load plus Require, both kinds of problems end up in the same error
+func LoadRequired(names ...string) (*Config, error) {
+	cfg, loadErr := Load()
+	requireErr := (&Config{}).Require(names...)
+	if err := errors.Join(loadErr, requireErr); err != nil {
+		return nil, err
+	}
+	return cfg, nil
+}
+
+func duration(name string, fallback time.Duration) (time.Duration, error) {
+	raw := os.Getenv(name)
+	if raw == "" {
+		return fallback, nil
+	}
+	value, err := time.ParseDuration(raw)
+	if err != nil || value <= 0 {
+		return 0, fmt.Errorf("%s: %q is not a positive duration", name, raw)
+	}
+	return value, nil
+}
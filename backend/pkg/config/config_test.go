@@ -0,0 +1,143 @@
+package config
+
+import (
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Fleexa-Graduation-Project/Backend/pkg/timeout"
+)
+
+func TestLoad(t *testing.T) {
+	tests := []struct {
+		name         string
+		env          map[string]string
+		check        func(t *testing.T, cfg *Config)
+		wantProblems []string // every one of them shows up in the one error
+	}{
+		{
+			name: "defaults",
+			check: func(t *testing.T, cfg *Config) {
+				if cfg.LogLevel != "info" || cfg.CallTimeout != timeout.DefaultCallTimeout || cfg.DeadlineMargin != timeout.DefaultMargin {
+					t.Errorf("Load() = %+v, want the default level and timeouts", cfg)
+				}
+			},
+		},
+		{
+			name: "tables and origins",
+			env: map[string]string{
+				"RESOURCE_PREFIX":      "staging-",
+				TelemetryTable:         "telemetry",
+				DevicesTable:           "devices",
+				"LOG_LEVEL":            " WARN ",
+				"AWS_CALL_TIMEOUT":     "3s",
+				"CORS_ALLOWED_ORIGINS": "https://a.example, ,https://b.example",
+			},
+			check: func(t *testing.T, cfg *Config) {
+				if len(cfg.Tables) != 2 || cfg.Tables[TelemetryTable] != "staging-telemetry" || cfg.Tables[DevicesTable] != "staging-devices" {
+					t.Errorf("Tables = %v, want the two prefixed tables", cfg.Tables)
+				}
+				if cfg.LogLevel != "warn" || cfg.CallTimeout != 3*time.Second {
+					t.Errorf("LogLevel, CallTimeout = %q, %s, want warn, 3s", cfg.LogLevel, cfg.CallTimeout)
+				}
+				if want := []string{"https://a.example", "https://b.example"}; !slices.Equal(cfg.CORSOrigins, want) {
+					t.Errorf("CORSOrigins = %v, want %v", cfg.CORSOrigins, want)
+				}
+			},
+		},
+		{
+			name: "zero disables retention",
+			env:  map[string]string{"TELEMETRY_RETENTION": "0", "LOG_SAMPLE_RATE": "1"},
+		},
+		{
+			name: "every problem at once",
+			env: map[string]string{
+				"RESOURCE_PREFIX":           "bad prefix/",
+				"LOG_LEVEL":                 "loud",
+				"AWS_CALL_TIMEOUT":          "0s",
+				"TELEMETRY_ARCHIVE_ENABLED": "true",
+				"OFFLINE_THRESHOLD":         "soon",
+				"TELEMETRY_RETENTION":       "-1h",
+				"MAX_REQUEST_BODY_BYTES":    "-5",
+				"LOG_SAMPLE_RATE":           "1.5",
+				"OTA_TARGETS":               "{",
+			},
+			wantProblems: []string{"RESOURCE_PREFIX", "LOG_LEVEL", "AWS_CALL_TIMEOUT", "TELEMETRY_ARCHIVE_BUCKET", "OFFLINE_THRESHOLD", "TELEMETRY_RETENTION", "MAX_REQUEST_BODY_BYTES", "LOG_SAMPLE_RATE", "OTA_TARGETS"},
+		},
+		{
+			name:         "prefix too long",
+			env:          map[string]string{"RESOURCE_PREFIX": strings.Repeat("a", maxResourcePrefix+1)},
+			wantProblems: []string{"RESOURCE_PREFIX"},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			for name, value := range test.env {
+				t.Setenv(name, value)
+			}
+
+			cfg, err := Load()
+			if len(test.wantProblems) > 0 {
+				if err == nil {
+					t.Fatal("Load() error = nil, want invalid configuration")
+				}
+				for _, problem := range test.wantProblems {
+					if !strings.Contains(err.Error(), problem+":") {
+						t.Errorf("Load() error = %v, missing %s", err, problem)
+					}
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Load() error = %v", err)
+			}
+			if test.check != nil {
+				test.check(t, cfg)
+			}
+		})
+	}
+}
+
+func TestLoadRequired(t *testing.T) {
+	tests := []struct {
+		name         string
+		env          map[string]string
+		wantProblems []string
+	}{
+		{name: "all set", env: map[string]string{DevicesTable: "devices", AlertsTable: "alerts"}},
+		{name: "missing tables listed together", wantProblems: []string{DevicesTable + ", " + AlertsTable}},
+		{
+			name:         "missing and invalid in one error",
+			env:          map[string]string{DevicesTable: "devices", "LOG_LEVEL": "loud"},
+			wantProblems: []string{AlertsTable, "LOG_LEVEL"},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			t.Setenv(DevicesTable, "")
+			t.Setenv(AlertsTable, "")
+			for name, value := range test.env {
+				t.Setenv(name, value)
+			}
+
+			_, err := LoadRequired(DevicesTable, AlertsTable)
+			if len(test.wantProblems) == 0 {
+				if err != nil {
+					t.Fatalf("LoadRequired() error = %v", err)
+				}
+				return
+			}
+			if err == nil {
+				t.Fatal("LoadRequired() error = nil")
+			}
+			for _, problem := range test.wantProblems {
+				if !strings.Contains(err.Error(), problem) {
+					t.Errorf("LoadRequired() error = %v, missing %s", err, problem)
+				}
+			}
+		})
+	}
+}
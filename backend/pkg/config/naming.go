@@ -0,0 +1,44 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"regexp"
+)
+
+// long enough for "staging-" style prefixes, short enough to leave table names their 255 chars
+const maxResourcePrefix = 32
+
+// the characters both dynamodb table names and mqtt topic levels accept
+var resourcePrefixPattern = regexp.MustCompile(`^[A-Za-z0-9_.-]*$`)
+
+// ResourcePrefix is RESOURCE_PREFIX, prepended to every table name and mqtt topic so several
+// environments can share one account. Empty (local, dev) leaves the names as they are
+func ResourcePrefix() string {
+	return os.Getenv("RESOURCE_PREFIX")
+}
+
+func validateResourcePrefix(prefix string) error {
+	if len(prefix) > maxResourcePrefix {
+		return fmt.Errorf("RESOURCE_PREFIX: %q is longer than %d characters", prefix, maxResourcePrefix)
+	}
+	if !resourcePrefixPattern.MatchString(prefix) {
+		return fmt.Errorf("RESOURCE_PREFIX: %q may only contain letters, digits, '_', '.' and '-'", prefix)
+	}
+	return nil
+}
+
+// TableName is the table named by the env variable with the prefix, empty when it is unset
+func TableName(envName string) string {
+	table := os.Getenv(envName)
+	if table == "" {
+		return ""
+	}
+	return ResourcePrefix() + table
+}
+
+// Topic is an mqtt topic with the prefix, devices of one environment publish and subscribe
+// under Topic("devices") and never see another environment's messages
+func Topic(topic string) string {
+	return ResourcePrefix() + topic
+}
@@ -0,0 +1,59 @@
+package config
+
+import (
+	"log/slog"
+	"os"
+	"runtime"
+	"runtime/debug"
+)
+
+// settings only reported as set or unset, their values never reach the logs
+var secretVars = []string{"JWT_SECRET", "JWT_PUBLIC_KEY", "FIREBASE_CREDENTIALS"}
+
+// LogColdStart logs one record of what a cold start is running with: the service, the go and
+// build versions, the lambda function and region, and the resolved config. Meant to be called
+// once in init, right after the config is loaded
+func (cfg *Config) LogColdStart(log *slog.Logger, service string) {
+	secrets := make([]any, 0, len(secretVars))
+	for _, name := range secretVars {
+		secrets = append(secrets, slog.Bool(name, os.Getenv(name) != ""))
+	}
+
+	log.Info("cold start",
+		"service", service,
+		"go_version", runtime.Version(),
+		"revision", buildRevision(),
+		"region", os.Getenv("AWS_REGION"),
+		"function_name", os.Getenv("AWS_LAMBDA_FUNCTION_NAME"),
+		"function_version", os.Getenv("AWS_LAMBDA_FUNCTION_VERSION"),
+		slog.Group("config",
+			"resource_prefix", ResourcePrefix(),
+			"tables", cfg.Tables,
+			"topic_root", Topic("devices"),
+			"log_level", cfg.LogLevel,
+			"call_timeout", cfg.CallTimeout.String(),
+			"deadline_margin", cfg.DeadlineMargin.String(),
+			"cors_origins", cfg.CORSOrigins,
+			"quarantine_bucket", cfg.QuarantineBucket,
+			"archive_bucket", cfg.ArchiveBucket,
+			"archive_enabled", cfg.ArchiveEnabled,
+			"ingestion_queue_url", cfg.IngestionQueueURL,
+			"websocket_endpoint", cfg.WebsocketEndpoint,
+		),
+		slog.Group("secrets_set", secrets...),
+	)
+}
+
+// the vcs revision go build stamps into the binary, empty when built outside a checkout
+func buildRevision() string {
+	info, ok := debug.ReadBuildInfo()
+	if !ok {
+		return ""
+	}
+	for _, setting := range info.Settings {
+		if setting.Key == "vcs.revision" {
+			return setting.Value
+		}
+	}
+	return ""
+}
@@ -0,0 +1,57 @@
+package connectivity
+
+import (
+	"os"
+	"time"
+)
+
+const (
+	// devices without a configured interval are expected to report this often
+	DefaultReportInterval = time.Minute
+	// the rolling window the score is computed over
+	DefaultWindow = 24 * time.Hour
+)
+
+// Interval is the reporting interval of a device, configured in seconds on the registry record.
+// 0 falls back to REPORT_INTERVAL_DEFAULT, then to DefaultReportInterval
+func Interval(configuredSeconds int64) time.Duration {
+	if configuredSeconds > 0 {
+		return time.Duration(configuredSeconds) * time.Second
+	}
+	if parsed, err := time.ParseDuration(os.Getenv("REPORT_INTERVAL_DEFAULT")); err == nil && parsed > 0 {
+		return parsed
+	}
+	return DefaultReportInterval
+}
+
+// Window is CONNECTIVITY_WINDOW, DefaultWindow when unset or invalid
+func Window() time.Duration {
+	if parsed, err := time.ParseDuration(os.Getenv("CONNECTIVITY_WINDOW")); err == nil && parsed > 0 {
+		return parsed
+	}
+	return DefaultWindow
+}
+
+// Score is the share of expected reports the device actually sent between from and to, 0 to
+// 100. The window is split into slots of one interval and each slot with at least one report
+// counts once, so extra reports can't make up for a gap elsewhere. Unix second timestamps
+// outside the window are ignored. ok is false when the window is shorter than one interval
+func Score(timestamps []int64, interval time.Duration, from, to time.Time) (int, bool) {
+	step := int64(interval / time.Second)
+	start, end := from.Unix(), to.Unix()
+	if step <= 0 || end-start < step {
+		return 0, false
+	}
+
+	slots := (end - start) / step
+	covered := make(map[int64]bool, slots)
+	for _, ts := range timestamps {
+		if ts < start || ts >= end {
+			continue
+		}
+		// the partial slot at the end counts towards the last full one
+		covered[min((ts-start)/step, slots-1)] = true
+	}
+
+	return int((int64(len(covered))*100 + slots/2) / slots), true
+}
@@ -0,0 +1,89 @@
+package connectivity
+
+import (
+	"testing"
+	"time"
+)
+
+func TestScore(t *testing.T) {
+	from := time.Unix(1700000000, 0)
+	start := from.Unix()
+	every := func(step, n int64) []int64 {
+		timestamps := make([]int64, n)
+		for i := range timestamps {
+			timestamps[i] = start + int64(i)*step
+		}
+		return timestamps
+	}
+
+	tests := []struct {
+		name       string
+		timestamps []int64
+		interval   time.Duration
+		window     time.Duration
+		want       int
+		wantOK     bool
+	}{
+		{name: "every slot", timestamps: every(60, 10), interval: time.Minute, window: 10 * time.Minute, want: 100, wantOK: true},
+		{name: "half the slots", timestamps: every(120, 5), interval: time.Minute, window: 10 * time.Minute, want: 50, wantOK: true},
+		{name: "a burst only fills one slot", timestamps: every(1, 30), interval: time.Minute, window: 10 * time.Minute, want: 10, wantOK: true},
+		{name: "rounds down", timestamps: every(60, 1), interval: time.Minute, window: 3 * time.Minute, want: 33, wantOK: true},
+		{name: "rounds up", timestamps: every(60, 2), interval: time.Minute, window: 3 * time.Minute, want: 67, wantOK: true},
+		{name: "reports outside the window are ignored", timestamps: []int64{start - 1, start + 600, start + 900}, interval: time.Minute, window: 10 * time.Minute, want: 0, wantOK: true},
+		{name: "the partial slot counts towards the last one", timestamps: []int64{start + 610}, interval: time.Minute, window: 10*time.Minute + 30*time.Second, want: 10, wantOK: true},
+		{name: "silent device", interval: time.Minute, window: time.Hour, want: 0, wantOK: true},
+		{name: "window shorter than the interval", timestamps: every(1, 10), interval: time.Minute, window: 30 * time.Second},
+		{name: "no interval", timestamps: every(1, 10), window: time.Hour},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got, ok := Score(test.timestamps, test.interval, from, from.Add(test.window))
+			if got != test.want || ok != test.wantOK {
+				t.Errorf("Score() = %d, %v, want %d, %v", got, ok, test.want, test.wantOK)
+			}
+		})
+	}
+}
+
+func TestInterval(t *testing.T) {
+	tests := []struct {
+		name       string
+		configured int64
+		env        string
+		want       time.Duration
+	}{
+		{name: "configured on the device", configured: 30, env: "5m", want: 30 * time.Second},
+		{name: "env default", env: "5m", want: 5 * time.Minute},
+		{name: "invalid env", env: "often", want: DefaultReportInterval},
+		{name: "negative env", env: "-1m", want: DefaultReportInterval},
+		{name: "nothing set", want: DefaultReportInterval},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			t.Setenv("REPORT_INTERVAL_DEFAULT", test.env)
+			if got := Interval(test.configured); got != test.want {
+				t.Errorf("Interval(%d) = %s, want %s", test.configured, got, test.want)
+			}
+		})
+	}
+}
+
+func TestWindow(t *testing.T) {
+	tests := []struct {
+		env  string
+		want time.Duration
+	}{
+		{env: "", want: DefaultWindow},
+		{env: "6h", want: 6 * time.Hour},
+		{env: "0s", want: DefaultWindow},
+		{env: "a day", want: DefaultWindow},
+	}
+	for _, test := range tests {
+		t.Setenv("CONNECTIVITY_WINDOW", test.env)
+		if got := Window(); got != test.want {
+			t.Errorf("Window() with %q = %s, want %s", test.env, got, test.want)
+		}
+	}
+}
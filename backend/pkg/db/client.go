@@ -6,31 +6,33 @@ import (
 	"log"
 	"sync"
 
-	"github.com/aws/aws-sdk-go-v2/config"
 	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
-)
 
+	"github.com/Fleexa-Graduation-Project/Backend/pkg/awsreq"
+)
 
 var (
 	Client *dynamodb.Client
-	once     sync.Once //logs to aws only once
+	mu     sync.Mutex // first caller builds the client, concurrent ones wait for it
 )
 
-// new client initializes the connection to db
+// new client initializes the connection to db, the dynamodb client of awsreq.Shared so every
+// store of the lambda uses the one client. Calls after a successful one are no-ops, a failed one
+// can be retried
 func NewDynamoDBClient(ctx context.Context) error {
-	var initErr error
+	mu.Lock()
+	defer mu.Unlock()
+	if Client != nil {
+		return nil
+	}
 
-	once.Do(func() {
-		cfg, err := config.LoadDefaultConfig(ctx)
-		if err != nil {
-			initErr = fmt.Errorf("unable to load SDK config: %v", err)
-			return
-		}
+	clients, err := awsreq.Shared(ctx)
+	if err != nil {
+		return fmt.Errorf("unable to load SDK config: %v", err)
+	}
 
-		//Create the Client
-			Client= dynamodb.NewFromConfig(cfg)
-		log.Println("DynamoDB Connection Established")
-	})
-	
-	return initErr
-}
\ No newline at end of file
+	// DYNAMODB_ENDPOINT points it at dynamodb-local for local runs
+	Client = clients.DynamoDB()
+	log.Println("DynamoDB Connection Established")
+	return nil
+}
@@ -0,0 +1,52 @@
+package db
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/attributevalue"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+)
+
+// ErrConditionFailed is a conditional write that found the item in another state than it
+// required. What that means (already exists, version conflict, already used) is up to the caller
+var ErrConditionFailed = errors.New("conditional check failed")
+
+// ConditionError is the error Condition returns, it matches ErrConditionFailed with errors.Is.
+// Item is the item as it was, when the write asked for it with
+// ReturnValuesOnConditionCheckFailure ALL_OLD. It is empty when there was no item
+type ConditionError struct {
+	Item map[string]types.AttributeValue
+	Err  error
+}
+
+func (e *ConditionError) Error() string {
+	return fmt.Sprintf("%v: %v", ErrConditionFailed, e.Err)
+}
+
+func (e *ConditionError) Is(target error) bool { return target == ErrConditionFailed }
+
+func (e *ConditionError) Unwrap() error { return e.Err }
+
+// Condition classifies the error of a conditional write: a failed condition becomes a
+// *ConditionError, nil and every other error are returned as they are
+func Condition(err error) error {
+	var conditionErr *types.ConditionalCheckFailedException
+	if errors.As(err, &conditionErr) {
+		return &ConditionError{Item: conditionErr.Item, Err: err}
+	}
+	return err
+}
+
+// CurrentItem unmarshals the item a failed condition returned into out. False when err is no
+// condition failure or there was no item
+func CurrentItem(err error, out interface{}) (bool, error) {
+	var conditionErr *ConditionError
+	if !errors.As(Condition(err), &conditionErr) || len(conditionErr.Item) == 0 {
+		return false, nil
+	}
+	if err := attributevalue.UnmarshalMap(conditionErr.Item, out); err != nil {
+		return false, fmt.Errorf("failed to unmarshal current item: %w", err)
+	}
+	return true, nil
+}
@@ -0,0 +1,73 @@
+package db
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+)
+
+type deviceItem struct {
+	DeviceID string `dynamodbav:"device_id"`
+	Version  int    `dynamodbav:"version"`
+}
+
+func TestCondition(t *testing.T) {
+	current := map[string]types.AttributeValue{
+		"device_id": &types.AttributeValueMemberS{Value: "truck-1"},
+		"version":   &types.AttributeValueMemberN{Value: "3"},
+	}
+
+	tests := []struct {
+		name       string
+		err        error
+		wantFailed bool
+		wantItem   *deviceItem
+	}{
+		{name: "condition failed with the old item", err: &types.ConditionalCheckFailedException{Item: current}, wantFailed: true, wantItem: &deviceItem{DeviceID: "truck-1", Version: 3}},
+		{name: "condition failed without an item", err: &types.ConditionalCheckFailedException{}, wantFailed: true},
+		{name: "wrapped by the sdk", err: fmt.Errorf("operation error DynamoDB: PutItem: %w", &types.ConditionalCheckFailedException{}), wantFailed: true},
+		{name: "other error", err: &types.ProvisionedThroughputExceededException{}},
+		{name: "no error"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			err := Condition(test.err)
+			if failed := errors.Is(err, ErrConditionFailed); failed != test.wantFailed {
+				t.Fatalf("errors.Is(Condition(), ErrConditionFailed) = %v, want %v", failed, test.wantFailed)
+			}
+			if !test.wantFailed && err != test.err {
+				t.Errorf("Condition() = %v, want the error unchanged", err)
+			}
+			// the sdk error stays reachable for logging
+			var sdkErr *types.ConditionalCheckFailedException
+			if test.wantFailed && !errors.As(err, &sdkErr) {
+				t.Errorf("Condition() = %v, lost the sdk error", err)
+			}
+
+			var item deviceItem
+			found, err := CurrentItem(test.err, &item)
+			if err != nil {
+				t.Fatalf("CurrentItem() error = %v", err)
+			}
+			if found != (test.wantItem != nil) {
+				t.Fatalf("CurrentItem() found = %v, want %v", found, test.wantItem != nil)
+			}
+			if test.wantItem != nil && item != *test.wantItem {
+				t.Errorf("CurrentItem() = %+v, want %+v", item, *test.wantItem)
+			}
+		})
+	}
+}
+
+func TestCurrentItemUnmarshalError(t *testing.T) {
+	err := &types.ConditionalCheckFailedException{Item: map[string]types.AttributeValue{
+		"version": &types.AttributeValueMemberS{Value: "three"},
+	}}
+	var item deviceItem
+	if found, unmarshalErr := CurrentItem(err, &item); found || unmarshalErr == nil {
+		t.Errorf("CurrentItem() = %v, %v, want an unmarshal error", found, unmarshalErr)
+	}
+}
@@ -0,0 +1,56 @@
+package db
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/attributevalue"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+)
+
+var ErrInvalidCursor = errors.New("invalid pagination cursor")
+
+// EncodeCursor turns a LastEvaluatedKey into an opaque url safe string, empty means no more pages
+func EncodeCursor(key map[string]types.AttributeValue) (string, error) {
+	if len(key) == 0 {
+		return "", nil
+	}
+
+	var plain map[string]interface{}
+	if err := attributevalue.UnmarshalMap(key, &plain); err != nil {
+		return "", fmt.Errorf("failed to unmarshal last evaluated key: %w", err)
+	}
+
+	raw, err := json.Marshal(plain)
+	if err != nil {
+		return "", fmt.Errorf("failed to encode cursor: %w", err)
+	}
+
+	return base64.RawURLEncoding.EncodeToString(raw), nil
+}
+
+// DecodeCursor is the inverse of EncodeCursor, any garbage returns ErrInvalidCursor
+func DecodeCursor(cursor string) (map[string]types.AttributeValue, error) {
+	if cursor == "" {
+		return nil, nil
+	}
+
+	raw, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return nil, ErrInvalidCursor
+	}
+
+	var plain map[string]interface{}
+	if err := json.Unmarshal(raw, &plain); err != nil || len(plain) == 0 {
+		return nil, ErrInvalidCursor
+	}
+
+	key, err := attributevalue.MarshalMap(plain)
+	if err != nil {
+		return nil, ErrInvalidCursor
+	}
+
+	return key, nil
+}
@@ -0,0 +1,19 @@
+package db
+
+import (
+	"errors"
+
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+)
+
+// IsThrottled reports whether a wrapped dynamodb error was caused by capacity throttling,
+// these are transient and safe to retry
+func IsThrottled(err error) bool {
+	var throughputErr *types.ProvisionedThroughputExceededException
+	var requestLimitErr *types.RequestLimitExceeded
+	var throttlingErr *types.ThrottlingException
+
+	return errors.As(err, &throughputErr) ||
+		errors.As(err, &requestLimitErr) ||
+		errors.As(err, &throttlingErr)
+}
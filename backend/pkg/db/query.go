@@ -0,0 +1,64 @@
+package db
+
+import (
+	"context"
+	"fmt"
+	"iter"
+
+	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/attributevalue"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+
+	"github.com/Fleexa-Graduation-Project/Backend/pkg/timeout"
+)
+
+// QueryAll runs the query page by page, following LastEvaluatedKey until it comes back empty,
+// and yields every item unmarshalled into T. Each page gets its own call timeout and a
+// cancelled ctx stops the walk before the next page. The first error is yielded once with a
+// zero T and ends the sequence. The input's ExclusiveStartKey is moved along as pages are read
+func QueryAll[T any](ctx context.Context, client dynamodb.QueryAPIClient, input *dynamodb.QueryInput) iter.Seq2[T, error] {
+	return func(yield func(T, error) bool) {
+		var zero T
+		for {
+			if err := ctx.Err(); err != nil {
+				yield(zero, err)
+				return
+			}
+
+			callCtx, cancel := timeout.Call(ctx)
+			result, err := client.Query(callCtx, input)
+			cancel()
+			if err != nil {
+				yield(zero, err)
+				return
+			}
+
+			for _, raw := range result.Items {
+				var item T
+				if err := attributevalue.UnmarshalMap(raw, &item); err != nil {
+					yield(zero, fmt.Errorf("failed to unmarshal item: %w", err))
+					return
+				}
+				if !yield(item, nil) {
+					return
+				}
+			}
+
+			if len(result.LastEvaluatedKey) == 0 {
+				return
+			}
+			input.ExclusiveStartKey = result.LastEvaluatedKey
+		}
+	}
+}
+
+// Collect gathers a QueryAll sequence into a slice, empty and not nil when there are no items
+func Collect[T any](seq iter.Seq2[T, error]) ([]T, error) {
+	items := []T{}
+	for item, err := range seq {
+		if err != nil {
+			return nil, err
+		}
+		items = append(items, item)
+	}
+	return items, nil
+}
@@ -0,0 +1,156 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"maps"
+	"strconv"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+)
+
+// serves fixed pages, the start key of each call picks the page
+type pagedClient struct {
+	pages      [][]map[string]types.AttributeValue
+	failOnPage int // 1-based, 0 never fails
+	calls      int
+	startKeys  []map[string]types.AttributeValue
+}
+
+func (client *pagedClient) Query(ctx context.Context, input *dynamodb.QueryInput, _ ...func(*dynamodb.Options)) (*dynamodb.QueryOutput, error) {
+	client.calls++
+	client.startKeys = append(client.startKeys, maps.Clone(input.ExclusiveStartKey))
+
+	page := 0
+	if key, ok := input.ExclusiveStartKey["page"].(*types.AttributeValueMemberN); ok {
+		page, _ = strconv.Atoi(key.Value)
+	}
+	if client.failOnPage == page+1 {
+		return nil, errors.New("throttled")
+	}
+
+	output := &dynamodb.QueryOutput{Items: client.pages[page]}
+	if page+1 < len(client.pages) {
+		output.LastEvaluatedKey = map[string]types.AttributeValue{"page": &types.AttributeValueMemberN{Value: strconv.Itoa(page + 1)}}
+	}
+	return output, nil
+}
+
+func items(versions ...int) []map[string]types.AttributeValue {
+	page := make([]map[string]types.AttributeValue, 0, len(versions))
+	for _, version := range versions {
+		page = append(page, map[string]types.AttributeValue{
+			"device_id": &types.AttributeValueMemberS{Value: "truck-1"},
+			"version":   &types.AttributeValueMemberN{Value: strconv.Itoa(version)},
+		})
+	}
+	return page
+}
+
+func TestQueryAll(t *testing.T) {
+	tests := []struct {
+		name         string
+		pages        [][]map[string]types.AttributeValue
+		failOnPage   int
+		want         []int
+		wantErr      bool
+		wantCalls    int
+		wantLastPage string // ExclusiveStartKey of the input once the walk is over
+	}{
+		{name: "single page", pages: [][]map[string]types.AttributeValue{items(1, 2)}, want: []int{1, 2}, wantCalls: 1},
+		{
+			name:         "follows LastEvaluatedKey",
+			pages:        [][]map[string]types.AttributeValue{items(1, 2), items(), items(3)},
+			want:         []int{1, 2, 3},
+			wantCalls:    3,
+			wantLastPage: "2",
+		},
+		{name: "no items", pages: [][]map[string]types.AttributeValue{items()}, want: []int{}, wantCalls: 1},
+		{name: "error on a later page", pages: [][]map[string]types.AttributeValue{items(1), items(2)}, failOnPage: 2, wantErr: true, wantCalls: 2, wantLastPage: "1"},
+		{
+			name:      "item that doesn't unmarshal",
+			pages:     [][]map[string]types.AttributeValue{{{"version": &types.AttributeValueMemberS{Value: "three"}}}},
+			wantErr:   true,
+			wantCalls: 1,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			client := &pagedClient{pages: test.pages, failOnPage: test.failOnPage}
+			input := &dynamodb.QueryInput{}
+
+			got, err := Collect(QueryAll[deviceItem](context.Background(), client, input))
+			if (err != nil) != test.wantErr {
+				t.Fatalf("Collect() error = %v, wantErr %v", err, test.wantErr)
+			}
+			if client.calls != test.wantCalls {
+				t.Errorf("Query called %d times, want %d", client.calls, test.wantCalls)
+			}
+			if client.startKeys[0] != nil {
+				t.Errorf("first page started at %v, want the beginning", client.startKeys[0])
+			}
+			lastPage, _ := input.ExclusiveStartKey["page"].(*types.AttributeValueMemberN)
+			if (lastPage == nil && test.wantLastPage != "") || (lastPage != nil && lastPage.Value != test.wantLastPage) {
+				t.Errorf("ExclusiveStartKey = %v, want page %q", input.ExclusiveStartKey, test.wantLastPage)
+			}
+			if test.wantErr {
+				if got != nil {
+					t.Errorf("Collect() = %v, want nil with the error", got)
+				}
+				return
+			}
+
+			versions := []int{}
+			for _, item := range got {
+				versions = append(versions, item.Version)
+			}
+			if len(versions) != len(test.want) {
+				t.Fatalf("versions = %v, want %v", versions, test.want)
+			}
+			for i := range versions {
+				if versions[i] != test.want[i] {
+					t.Errorf("versions = %v, want %v", versions, test.want)
+				}
+			}
+		})
+	}
+}
+
+func TestQueryAllStops(t *testing.T) {
+	pages := [][]map[string]types.AttributeValue{items(1, 2), items(3)}
+
+	t.Run("caller breaks out", func(t *testing.T) {
+		client := &pagedClient{pages: pages}
+		for item, err := range QueryAll[deviceItem](context.Background(), client, &dynamodb.QueryInput{}) {
+			if err != nil || item.Version != 1 {
+				t.Fatalf("first item = %+v, %v", item, err)
+			}
+			break
+		}
+		if client.calls != 1 {
+			t.Errorf("Query called %d times after break, want 1", client.calls)
+		}
+	})
+
+	t.Run("cancelled context", func(t *testing.T) {
+		client := &pagedClient{pages: pages}
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		var errs []error
+		for item, err := range QueryAll[deviceItem](ctx, client, &dynamodb.QueryInput{}) {
+			if err != nil {
+				errs = append(errs, err)
+				continue
+			}
+			if item.Version == 2 {
+				cancel()
+			}
+		}
+		if client.calls != 1 || len(errs) != 1 || !errors.Is(errs[0], context.Canceled) {
+			t.Errorf("calls = %d, errors = %v, want one page and context.Canceled once", client.calls, errs)
+		}
+	})
+}
@@ -0,0 +1,73 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"math/rand/v2"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/smithy-go"
+)
+
+// RetryPolicy controls how many times a transient dynamodb error is retried
+type RetryPolicy struct {
+	MaxAttempts int           // total attempts including the first one
+	BaseDelay   time.Duration // doubled after every attempt
+}
+
+var DefaultRetryPolicy = RetryPolicy{MaxAttempts: 3, BaseDelay: 100 * time.Millisecond}
+
+// error codes worth another attempt, anything else (ValidationException etc.) fails fast
+var retryableCodes = map[string]bool{
+	"ProvisionedThroughputExceededException": true,
+	"RequestLimitExceeded":                   true,
+	"ThrottlingException":                    true,
+	"InternalServerError":                    true,
+	"ServiceUnavailable":                     true,
+}
+
+// IsRetryable reports whether err is a throttling or transient server error
+func IsRetryable(err error) bool {
+	if IsThrottled(err) {
+		return true
+	}
+
+	var apiErr smithy.APIError
+	if errors.As(err, &apiErr) {
+		return retryableCodes[apiErr.ErrorCode()]
+	}
+	return false
+}
+
+// SingleAttempt is the call option for the calls made inside Retry, it turns the sdk's own
+// retries off so a policy of 3 attempts is 3 requests and not up to 3 sdk attempts each
+func SingleAttempt(options *dynamodb.Options) {
+	options.Retryer = aws.NopRetryer{}
+}
+
+// Retry runs op until it succeeds, fails with a non retryable error or runs out of attempts,
+// sleeping an exponential backoff with full jitter in between. The calls op makes should pass
+// SingleAttempt
+func Retry(ctx context.Context, policy RetryPolicy, op func() error) error {
+	if policy.MaxAttempts < 1 {
+		policy.MaxAttempts = 1
+	}
+
+	for attempt := 1; ; attempt++ {
+		err := op()
+		if err == nil || attempt >= policy.MaxAttempts || !IsRetryable(err) {
+			return err
+		}
+
+		backoff := policy.BaseDelay << (attempt - 1)
+		delay := time.Duration(rand.Int64N(int64(backoff) + 1))
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(delay):
+		}
+	}
+}
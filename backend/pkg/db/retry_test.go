@@ -0,0 +1,69 @@
+package db
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/aws/retry"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+)
+
+// every request is throttled, the client keeps the sdk's standard retryer without its backoff
+func throttledClient(t *testing.T, requests *atomic.Int32) *dynamodb.Client {
+	t.Helper()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+		w.Header().Set("Content-Type", "application/x-amz-json-1.0")
+		w.WriteHeader(http.StatusBadRequest)
+		fmt.Fprint(w, `{"__type": "com.amazonaws.dynamodb.v20120810#ProvisionedThroughputExceededException", "message": "slow down"}`)
+	}))
+	t.Cleanup(server.Close)
+
+	return dynamodb.New(dynamodb.Options{
+		Region:       "us-east-1",
+		BaseEndpoint: aws.String(server.URL),
+		Credentials:  aws.AnonymousCredentials{},
+		Retryer: retry.NewStandard(func(options *retry.StandardOptions) {
+			options.Backoff = retry.BackoffDelayerFunc(func(int, error) (time.Duration, error) { return 0, nil })
+		}),
+	})
+}
+
+func TestRetrySingleAttempt(t *testing.T) {
+	tests := []struct {
+		name         string
+		options      []func(*dynamodb.Options)
+		wantRequests int32
+	}{
+		{name: "sdk retries on top of the policy", wantRequests: 6},
+		{name: "single attempt per retry", options: []func(*dynamodb.Options){SingleAttempt}, wantRequests: 2},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var requests atomic.Int32
+			client := throttledClient(t, &requests)
+
+			err := Retry(context.Background(), RetryPolicy{MaxAttempts: 2, BaseDelay: time.Millisecond}, func() error {
+				_, err := client.GetItem(context.Background(), &dynamodb.GetItemInput{
+					TableName: aws.String("telemetry"),
+					Key:       map[string]types.AttributeValue{"device_id": &types.AttributeValueMemberS{Value: "truck-1"}},
+				}, test.options...)
+				return err
+			})
+			if !IsThrottled(err) {
+				t.Fatalf("Retry() error = %v, want the throttling error", err)
+			}
+			if got := requests.Load(); got != test.wantRequests {
+				t.Errorf("requests = %d, want %d", got, test.wantRequests)
+			}
+		})
+	}
+}
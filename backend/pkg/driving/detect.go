@@ -0,0 +1,102 @@
+package driving
+
+import (
+	"cmp"
+	"fmt"
+	"slices"
+	"time"
+
+	"github.com/Fleexa-Graduation-Project/Backend/pkg/trip"
+)
+
+const (
+	EventHarshBraking      = "harsh_braking"
+	EventRapidAcceleration = "rapid_acceleration"
+	EventProlongedIdle     = "prolonged_idle"
+)
+
+// Event is one driving event. Magnitude is the speed change in km/h per second for braking (as
+// a positive number) and acceleration, and the idle duration in seconds for idling. Timestamp
+// is the reading that showed the change, or the start of the idle period
+type Event struct {
+	DeviceID  string  `json:"device_id" dynamodbav:"device_id"`
+	EventID   string  `json:"-" dynamodbav:"event_id"` // timestamp#type, the sort key
+	Type      string  `json:"type" dynamodbav:"type"`
+	Timestamp int64   `json:"timestamp" dynamodbav:"timestamp"`
+	Magnitude float64 `json:"magnitude" dynamodbav:"magnitude"`
+}
+
+func newEvent(deviceID, eventType string, timestamp int64, magnitude float64) Event {
+	return Event{
+		DeviceID:  deviceID,
+		EventID:   fmt.Sprintf("%d#%s", timestamp, eventType),
+		Type:      eventType,
+		Timestamp: timestamp,
+		Magnitude: magnitude,
+	}
+}
+
+// Detect returns the driving events in one device's points, oldest first. Braking and
+// acceleration compare the speed of consecutive points that both report it. Idling is the
+// ignition on while the speed stays under the idle speed, points without ignition don't idle. A
+// gap longer than the trip max gap ends the idle period, one still going at the last point is
+// reported with its length so far
+func Detect(deviceID string, points []trip.Point, thresholds Thresholds) []Event {
+	var events []Event
+	var previous *trip.Point
+	var idleStart, idleEnd int64
+	idling := false
+
+	closeIdle := func() {
+		if idling && thresholds.IdleSeconds > 0 && idleEnd-idleStart >= thresholds.IdleSeconds {
+			events = append(events, newEvent(deviceID, EventProlongedIdle, idleStart, float64(idleEnd-idleStart)))
+		}
+		idling = false
+	}
+
+	for i := range points {
+		point := points[i]
+		if !point.HasSpeed {
+			continue
+		}
+		if previous != nil && point.Timestamp <= previous.Timestamp {
+			continue // out of order or duplicate
+		}
+
+		if previous != nil && time.Duration(point.Timestamp-previous.Timestamp)*time.Second > trip.DefaultMaxGap {
+			closeIdle()
+			previous = nil
+		}
+
+		if previous != nil {
+			rate := (point.SpeedKPH - previous.SpeedKPH) / float64(point.Timestamp-previous.Timestamp)
+			switch {
+			case thresholds.DecelKPHPerSec > 0 && -rate >= thresholds.DecelKPHPerSec:
+				events = append(events, newEvent(deviceID, EventHarshBraking, point.Timestamp, -rate))
+			case thresholds.AccelKPHPerSec > 0 && rate >= thresholds.AccelKPHPerSec:
+				events = append(events, newEvent(deviceID, EventRapidAcceleration, point.Timestamp, rate))
+			}
+		}
+
+		engineOn := point.Ignition != nil && *point.Ignition
+		if engineOn && point.SpeedKPH < trip.DefaultIdleSpeedKPH {
+			if !idling {
+				idling, idleStart = true, point.Timestamp
+			}
+			idleEnd = point.Timestamp
+		} else {
+			if idling && point.Timestamp > idleEnd {
+				// the vehicle idled until this reading
+				idleEnd = point.Timestamp
+			}
+			closeIdle()
+		}
+
+		previous = &points[i]
+	}
+	closeIdle()
+
+	// idle events are added when their period ends
+	slices.SortStableFunc(events, func(a, b Event) int { return cmp.Compare(a.Timestamp, b.Timestamp) })
+	return events
+}
@@ -0,0 +1,100 @@
+package driving
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/Fleexa-Graduation-Project/Backend/pkg/trip"
+)
+
+func TestDetect(t *testing.T) {
+	const start = 1700000000
+	on, off := true, false
+	at := func(offset int64, speed float64, ignition *bool) trip.Point {
+		return trip.Point{Timestamp: start + offset, SpeedKPH: speed, HasSpeed: true, Ignition: ignition}
+	}
+
+	tests := []struct {
+		name       string
+		points     []trip.Point
+		thresholds Thresholds
+		want       []Event
+	}{
+		{
+			name:   "harsh braking",
+			points: []trip.Point{at(0, 80, &on), at(2, 50, &on)},
+			want:   []Event{newEvent("truck-1", EventHarshBraking, start+2, 15)},
+		},
+		{
+			name:   "rapid acceleration",
+			points: []trip.Point{at(0, 10, &on), at(2, 40, &on)},
+			want:   []Event{newEvent("truck-1", EventRapidAcceleration, start+2, 15)},
+		},
+		{
+			name:   "normal driving",
+			points: []trip.Point{at(0, 50, &on), at(2, 60, &on), at(4, 45, &on)},
+		},
+		{
+			name:       "a zero threshold turns braking off",
+			points:     []trip.Point{at(0, 80, &on), at(2, 50, &on)},
+			thresholds: Thresholds{AccelKPHPerSec: 12, IdleSeconds: 300},
+		},
+		{
+			name:   "idle until the vehicle moves off",
+			points: []trip.Point{at(0, 0, &on), at(120, 1, &on), at(310, 0, &on), at(320, 20, &on)},
+			want:   []Event{newEvent("truck-1", EventProlongedIdle, start, 320)},
+		},
+		{
+			name:   "short idle",
+			points: []trip.Point{at(0, 0, &on), at(100, 0, &on), at(110, 20, &on)},
+		},
+		{
+			name:   "idle still going at the last point",
+			points: []trip.Point{at(0, 0, &on), at(200, 0, &on), at(400, 0, &on)},
+			want:   []Event{newEvent("truck-1", EventProlongedIdle, start, 400)},
+		},
+		{
+			name:   "parked with the engine off is not idling",
+			points: []trip.Point{at(0, 0, &off), at(400, 0, &off), at(800, 0, nil)},
+		},
+		{
+			name: "idle events sorted by their start",
+			points: []trip.Point{
+				at(0, 0, &on), at(300, 0, &on), at(302, 30, &on),
+			},
+			want: []Event{
+				newEvent("truck-1", EventProlongedIdle, start, 302),
+				newEvent("truck-1", EventRapidAcceleration, start+302, 15),
+			},
+		},
+		{
+			name: "a gap ends the idle period and isn't a speed change",
+			points: []trip.Point{
+				at(0, 0, &on), at(200, 0, &on), at(900, 100, &on), at(1500, 0, &on),
+			},
+		},
+		{
+			name: "points without speed, duplicates and out of order are skipped",
+			points: []trip.Point{
+				at(0, 80, &on),
+				{Timestamp: start + 1, HasPosition: true},
+				at(0, 0, &on),
+				at(2, 50, &on),
+				at(1, 90, &on),
+			},
+			want: []Event{newEvent("truck-1", EventHarshBraking, start+2, 15)},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			thresholds := test.thresholds
+			if thresholds == (Thresholds{}) {
+				thresholds = DefaultThresholds()
+			}
+			if got := Detect("truck-1", test.points, thresholds); !reflect.DeepEqual(got, test.want) {
+				t.Errorf("Detect() = %+v, want %+v", got, test.want)
+			}
+		})
+	}
+}
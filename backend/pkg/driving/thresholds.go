@@ -0,0 +1,71 @@
+package driving
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+)
+
+// Thresholds that make a driving event. 0 turns the event type off
+type Thresholds struct {
+	AccelKPHPerSec float64 `json:"accel_kph_per_s"` // speed gain per second
+	DecelKPHPerSec float64 `json:"decel_kph_per_s"` // speed loss per second
+	IdleSeconds    int64   `json:"idle_seconds"`    // idling at least this long
+}
+
+// about 0.35g and 0.4g, the usual telematics cut-offs for rapid acceleration and harsh braking
+func DefaultThresholds() Thresholds {
+	return Thresholds{AccelKPHPerSec: 12, DecelKPHPerSec: 14, IdleSeconds: 300}
+}
+
+// thresholds per fleet with a global default
+type Policy struct {
+	Default Thresholds
+	Fleets  map[string]Thresholds
+}
+
+func (policy Policy) For(fleetID string) Thresholds {
+	if thresholds, ok := policy.Fleets[fleetID]; ok {
+		return thresholds
+	}
+	return policy.Default
+}
+
+// DRIVING_THRESHOLDS='{"decel_kph_per_s":12}' changes the default, DRIVING_FLEET_THRESHOLDS=
+// '{"fleet-a":{"idle_seconds":600}}' sets fleet overrides. Fields left out keep the default
+func LoadPolicy() (Policy, error) {
+	policy := Policy{Default: DefaultThresholds(), Fleets: map[string]Thresholds{}}
+
+	if raw := os.Getenv("DRIVING_THRESHOLDS"); raw != "" {
+		if err := decodeThresholds(raw, &policy.Default); err != nil {
+			return policy, fmt.Errorf("invalid DRIVING_THRESHOLDS: %w", err)
+		}
+	}
+
+	if raw := os.Getenv("DRIVING_FLEET_THRESHOLDS"); raw != "" {
+		var fleets map[string]json.RawMessage
+		if err := json.Unmarshal([]byte(raw), &fleets); err != nil {
+			return policy, fmt.Errorf("invalid DRIVING_FLEET_THRESHOLDS: %w", err)
+		}
+		for fleetID, fleetRaw := range fleets {
+			thresholds := policy.Default
+			if err := decodeThresholds(string(fleetRaw), &thresholds); err != nil {
+				return policy, fmt.Errorf("invalid DRIVING_FLEET_THRESHOLDS for %s: %w", fleetID, err)
+			}
+			policy.Fleets[fleetID] = thresholds
+		}
+	}
+
+	return policy, nil
+}
+
+// decodes over thresholds, so missing fields keep their value
+func decodeThresholds(raw string, thresholds *Thresholds) error {
+	if err := json.Unmarshal([]byte(raw), thresholds); err != nil {
+		return err
+	}
+	if thresholds.AccelKPHPerSec < 0 || thresholds.DecelKPHPerSec < 0 || thresholds.IdleSeconds < 0 {
+		return fmt.Errorf("thresholds must not be negative")
+	}
+	return nil
+}
@@ -0,0 +1,72 @@
+package driving
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestLoadPolicy(t *testing.T) {
+	defaults := DefaultThresholds()
+
+	tests := []struct {
+		name    string
+		env     map[string]string
+		want    Policy
+		wantErr bool
+	}{
+		{name: "defaults", want: Policy{Default: defaults, Fleets: map[string]Thresholds{}}},
+		{
+			name: "missing fields keep the default",
+			env:  map[string]string{"DRIVING_THRESHOLDS": `{"decel_kph_per_s": 10}`},
+			want: Policy{Default: Thresholds{AccelKPHPerSec: 12, DecelKPHPerSec: 10, IdleSeconds: 300}, Fleets: map[string]Thresholds{}},
+		},
+		{
+			name: "fleet overrides start from the changed default",
+			env: map[string]string{
+				"DRIVING_THRESHOLDS":       `{"decel_kph_per_s": 10}`,
+				"DRIVING_FLEET_THRESHOLDS": `{"fleet-a": {"idle_seconds": 600}, "fleet-b": {"accel_kph_per_s": 0}}`,
+			},
+			want: Policy{
+				Default: Thresholds{AccelKPHPerSec: 12, DecelKPHPerSec: 10, IdleSeconds: 300},
+				Fleets: map[string]Thresholds{
+					"fleet-a": {AccelKPHPerSec: 12, DecelKPHPerSec: 10, IdleSeconds: 600},
+					"fleet-b": {AccelKPHPerSec: 0, DecelKPHPerSec: 10, IdleSeconds: 300},
+				},
+			},
+		},
+		{name: "negative default", env: map[string]string{"DRIVING_THRESHOLDS": `{"idle_seconds": -1}`}, wantErr: true},
+		{name: "negative fleet override", env: map[string]string{"DRIVING_FLEET_THRESHOLDS": `{"fleet-a": {"decel_kph_per_s": -3}}`}, wantErr: true},
+		{name: "not json", env: map[string]string{"DRIVING_FLEET_THRESHOLDS": `fleet-a=600`}, wantErr: true},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			for _, name := range []string{"DRIVING_THRESHOLDS", "DRIVING_FLEET_THRESHOLDS"} {
+				t.Setenv(name, test.env[name])
+			}
+
+			got, err := LoadPolicy()
+			if (err != nil) != test.wantErr {
+				t.Fatalf("LoadPolicy() error = %v, wantErr %v", err, test.wantErr)
+			}
+			if !test.wantErr && !reflect.DeepEqual(got, test.want) {
+				t.Errorf("LoadPolicy() = %+v, want %+v", got, test.want)
+			}
+		})
+	}
+}
+
+func TestPolicyFor(t *testing.T) {
+	fleetA := Thresholds{IdleSeconds: 600}
+	policy := Policy{Default: DefaultThresholds(), Fleets: map[string]Thresholds{"fleet-a": fleetA}}
+
+	if got := policy.For("fleet-a"); got != fleetA {
+		t.Errorf("For(fleet-a) = %+v, want %+v", got, fleetA)
+	}
+	if got := policy.For("fleet-b"); got != DefaultThresholds() {
+		t.Errorf("For(fleet-b) = %+v, want the default", got)
+	}
+	if got := policy.For(""); got != DefaultThresholds() {
+		t.Errorf("For(\"\") = %+v, want the default", got)
+	}
+}
@@ -0,0 +1,24 @@
+package geo
+
+import "math"
+
+const earthRadiusKM = 6371.0088 // mean earth radius
+
+// DistanceKM is the great-circle (haversine) distance between two points
+func DistanceKM(a, b Coord) float64 {
+	lat1, lat2 := toRadians(a.Lat), toRadians(b.Lat)
+	dLat := lat2 - lat1
+	dLon := toRadians(b.Lon - a.Lon)
+
+	h := math.Sin(dLat/2)*math.Sin(dLat/2) + math.Cos(lat1)*math.Cos(lat2)*math.Sin(dLon/2)*math.Sin(dLon/2)
+	return 2 * earthRadiusKM * math.Asin(math.Min(1, math.Sqrt(h)))
+}
+
+// Haversine is DistanceKM in meters
+func Haversine(a, b Coord) float64 {
+	return DistanceKM(a, b) * 1000
+}
+
+func toRadians(degrees float64) float64 {
+	return degrees * math.Pi / 180
+}
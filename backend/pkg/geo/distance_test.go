@@ -0,0 +1,37 @@
+package geo
+
+import (
+	"math"
+	"testing"
+)
+
+func TestDistance(t *testing.T) {
+	quarter := math.Pi / 2 * earthRadiusKM
+
+	tests := []struct {
+		name   string
+		a, b   Coord
+		wantKM float64
+	}{
+		{name: "same point", a: Coord{Lat: 30.0444, Lon: 31.2357}, b: Coord{Lat: 30.0444, Lon: 31.2357}, wantKM: 0},
+		{name: "one degree of latitude", a: Coord{Lat: 0, Lon: 0}, b: Coord{Lat: 1, Lon: 0}, wantKM: quarter / 90},
+		{name: "quarter of the equator", a: Coord{Lat: 0, Lon: 0}, b: Coord{Lat: 0, Lon: 90}, wantKM: quarter},
+		{name: "across the antimeridian", a: Coord{Lat: 0, Lon: 179.5}, b: Coord{Lat: 0, Lon: -179.5}, wantKM: quarter / 90},
+		{name: "antipodes", a: Coord{Lat: 0, Lon: 0}, b: Coord{Lat: 0, Lon: 180}, wantKM: 2 * quarter},
+		{name: "pole to pole", a: Coord{Lat: 90, Lon: 0}, b: Coord{Lat: -90, Lon: 45}, wantKM: 2 * quarter},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if got := DistanceKM(test.a, test.b); math.Abs(got-test.wantKM) > 1e-6 {
+				t.Errorf("DistanceKM() = %v, want %v", got, test.wantKM)
+			}
+			if got := Haversine(test.a, test.b); math.Abs(got-test.wantKM*1000) > 1e-3 {
+				t.Errorf("Haversine() = %v m, want %v m", got, test.wantKM*1000)
+			}
+			if forward, back := DistanceKM(test.a, test.b), DistanceKM(test.b, test.a); math.Abs(forward-back) > 1e-9 {
+				t.Errorf("DistanceKM() is %v one way and %v back", forward, back)
+			}
+		})
+	}
+}
@@ -0,0 +1,65 @@
+package geo
+
+import "math"
+
+type Coord struct {
+	Lat float64 `json:"lat" dynamodbav:"lat"`
+	Lon float64 `json:"lon" dynamodbav:"lon"`
+}
+
+// keep-in area a device is expected to stay inside
+type Geofence struct {
+	ID      string  `json:"geofence_id"`
+	Polygon []Coord `json:"polygon"`
+}
+
+func (fence Geofence) Contains(point Coord) bool {
+	return PointInPolygon(point.Lat, point.Lon, fence.Polygon)
+}
+
+// ValidCoord rejects NaN and out of range values so bad gps fixes are skipped, not evaluated
+func ValidCoord(lat, lon float64) bool {
+	return !math.IsNaN(lat) && !math.IsNaN(lon) &&
+		lat >= -90 && lat <= 90 && lon >= -180 && lon <= 180
+}
+
+// PointInPolygon is a planar ray-casting test on lat/lon. Polygons crossing the antimeridian are
+// unwrapped to 0..360 first; polygons enclosing a pole are not supported by planar casting
+func PointInPolygon(lat, lon float64, polygon []Coord) bool {
+	if len(polygon) < 3 || !ValidCoord(lat, lon) {
+		return false
+	}
+
+	unwrap := crossesAntimeridian(polygon)
+	x := normalizeLon(lon, unwrap)
+
+	inside := false
+	for i, j := 0, len(polygon)-1; i < len(polygon); j, i = i, i+1 {
+		xi, yi := normalizeLon(polygon[i].Lon, unwrap), polygon[i].Lat
+		xj, yj := normalizeLon(polygon[j].Lon, unwrap), polygon[j].Lat
+
+		// the first check guarantees yi != yj so the division is safe
+		if (yi > lat) != (yj > lat) && x < (xj-xi)*(lat-yi)/(yj-yi)+xi {
+			inside = !inside
+		}
+	}
+	return inside
+}
+
+// an edge jumping more than 180 degrees of longitude is really the short way across ±180
+func crossesAntimeridian(polygon []Coord) bool {
+	for i := range polygon {
+		next := polygon[(i+1)%len(polygon)]
+		if math.Abs(next.Lon-polygon[i].Lon) > 180 {
+			return true
+		}
+	}
+	return false
+}
+
+func normalizeLon(lon float64, unwrap bool) float64 {
+	if unwrap && lon < 0 {
+		return lon + 360
+	}
+	return lon
+}
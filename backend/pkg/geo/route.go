@@ -0,0 +1,34 @@
+package geo
+
+import (
+	"math"
+	"time"
+)
+
+// BearingBetween is the initial compass bearing from a to b in degrees, 0 is north and 90 east.
+// The bearing between identical points is undefined and returned as 0
+func BearingBetween(a, b Coord) float64 {
+	if a == b {
+		return 0
+	}
+
+	lat1, lat2 := toRadians(a.Lat), toRadians(b.Lat)
+	dLon := toRadians(b.Lon - a.Lon)
+
+	y := math.Sin(dLon) * math.Cos(lat2)
+	x := math.Cos(lat1)*math.Sin(lat2) - math.Sin(lat1)*math.Cos(lat2)*math.Cos(dLon)
+
+	degrees := math.Atan2(y, x) * 180 / math.Pi
+	return math.Mod(degrees+360, 360)
+}
+
+// ETA is the time to cover the distance at the average speed, a non-positive speed (parked
+// vehicle) has no meaningful eta and returns 0
+func ETA(distanceMeters, avgSpeedKPH float64) time.Duration {
+	if avgSpeedKPH <= 0 || distanceMeters <= 0 {
+		return 0
+	}
+
+	hours := distanceMeters / 1000 / avgSpeedKPH
+	return time.Duration(hours * float64(time.Hour))
+}
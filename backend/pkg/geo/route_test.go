@@ -0,0 +1,53 @@
+package geo
+
+import (
+	"math"
+	"testing"
+	"time"
+)
+
+func TestBearingBetween(t *testing.T) {
+	tests := []struct {
+		name string
+		a, b Coord
+		want float64
+	}{
+		{name: "north", a: Coord{Lat: 0, Lon: 0}, b: Coord{Lat: 1, Lon: 0}, want: 0},
+		{name: "east", a: Coord{Lat: 0, Lon: 0}, b: Coord{Lat: 0, Lon: 1}, want: 90},
+		{name: "south", a: Coord{Lat: 0, Lon: 0}, b: Coord{Lat: -1, Lon: 0}, want: 180},
+		{name: "west is never negative", a: Coord{Lat: 0, Lon: 0}, b: Coord{Lat: 0, Lon: -1}, want: 270},
+		{name: "east across the antimeridian", a: Coord{Lat: 0, Lon: 179.5}, b: Coord{Lat: 0, Lon: -179.5}, want: 90},
+		{name: "identical points", a: Coord{Lat: 30.0444, Lon: 31.2357}, b: Coord{Lat: 30.0444, Lon: 31.2357}, want: 0},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if got := BearingBetween(test.a, test.b); math.Abs(got-test.want) > 1e-9 {
+				t.Errorf("BearingBetween() = %v, want %v", got, test.want)
+			}
+		})
+	}
+}
+
+func TestETA(t *testing.T) {
+	tests := []struct {
+		name           string
+		distanceMeters float64
+		avgSpeedKPH    float64
+		want           time.Duration
+	}{
+		{name: "an hour", distanceMeters: 60000, avgSpeedKPH: 60, want: time.Hour},
+		{name: "minutes", distanceMeters: 500, avgSpeedKPH: 30, want: time.Minute},
+		{name: "parked", distanceMeters: 60000, avgSpeedKPH: 0, want: 0},
+		{name: "negative speed", distanceMeters: 60000, avgSpeedKPH: -10, want: 0},
+		{name: "already there", distanceMeters: 0, avgSpeedKPH: 60, want: 0},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if got := ETA(test.distanceMeters, test.avgSpeedKPH); got != test.want {
+				t.Errorf("ETA(%v, %v) = %s, want %s", test.distanceMeters, test.avgSpeedKPH, got, test.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,123 @@
+package httpreq
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"reflect"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/binding"
+	"github.com/go-playground/validator/v10"
+
+	"github.com/Fleexa-Graduation-Project/Backend/pkg/apierr"
+	"github.com/Fleexa-Graduation-Project/Backend/pkg/httpresp"
+)
+
+// DecodeBody strictly decodes the json body into target and runs its binding tags.
+// On failure it writes 400 (naming the offending field), 422 listing every field that failed
+// its binding tags, or 413, and returns false
+func DecodeBody(c *gin.Context, target any) bool {
+	decoder := json.NewDecoder(c.Request.Body)
+	decoder.DisallowUnknownFields()
+
+	err := decoder.Decode(target)
+	if err == nil && decoder.More() {
+		err = errors.New("body must contain a single json object")
+	}
+	if err == nil {
+		err = binding.Validator.ValidateStruct(target)
+	}
+	if err == nil {
+		return true
+	}
+
+	var tooLarge *http.MaxBytesError
+	if errors.As(err, &tooLarge) {
+		httpresp.Error(c, http.StatusRequestEntityTooLarge, fmt.Sprintf("request body exceeds %d bytes", tooLarge.Limit))
+		return false
+	}
+
+	var validationErrs validator.ValidationErrors
+	if errors.As(err, &validationErrs) && len(validationErrs) > 0 {
+		verr := fieldErrors(validationErrs, target)
+		httpresp.ErrorFields(c, http.StatusUnprocessableEntity, "validation", verr.Message(), verr.Fields)
+		return false
+	}
+
+	httpresp.Error(c, http.StatusBadRequest, describe(err, target))
+	return false
+}
+
+// Validate runs the binding tags of target, nil or an *apierr.ValidationError listing every field
+// that failed. For requests without a body, DecodeBody already validates the ones with one
+func Validate(target any) error {
+	err := binding.Validator.ValidateStruct(target)
+	var validationErrs validator.ValidationErrors
+	if errors.As(err, &validationErrs) && len(validationErrs) > 0 {
+		return fieldErrors(validationErrs, target)
+	}
+	return err
+}
+
+// one field per failed binding tag, named by their json tag
+func fieldErrors(validationErrs validator.ValidationErrors, target any) *apierr.ValidationError {
+	verr := &apierr.ValidationError{}
+	for _, field := range validationErrs {
+		reason := "failed " + field.Tag() + " validation"
+		if field.Tag() == "required" {
+			reason = "required"
+		}
+		verr.Add(jsonName(target, field.StructField()), reason)
+	}
+	return verr
+}
+
+// turns decoder and validator errors into a message a client can act on
+func describe(err error, target any) string {
+	var syntaxErr *json.SyntaxError
+	var typeErr *json.UnmarshalTypeError
+
+	switch {
+	case errors.Is(err, io.EOF):
+		return "request body is empty"
+	case errors.Is(err, io.ErrUnexpectedEOF):
+		return "malformed json: unexpected end of body"
+	case errors.As(err, &syntaxErr):
+		return fmt.Sprintf("malformed json at offset %d", syntaxErr.Offset)
+	case errors.As(err, &typeErr):
+		return fmt.Sprintf("%s must be a %s", typeErr.Field, typeErr.Type.String())
+	case strings.HasPrefix(err.Error(), "json: unknown field "):
+		return fmt.Sprintf("unknown field %s", strings.TrimPrefix(err.Error(), "json: unknown field "))
+	default:
+		return err.Error()
+	}
+}
+
+// json tag of the struct field, then its path (uri) or query (form) tag, falls back to the go name
+func jsonName(target any, structField string) string {
+	t := reflect.TypeOf(target)
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	if t.Kind() != reflect.Struct {
+		return structField
+	}
+
+	field, ok := t.FieldByName(structField)
+	if !ok {
+		return structField
+	}
+	if name, _, _ := strings.Cut(field.Tag.Get("json"), ","); name != "" && name != "-" {
+		return name
+	}
+	for _, tag := range []string{"uri", "form"} {
+		if name, _, _ := strings.Cut(field.Tag.Get(tag), ","); name != "" && name != "-" {
+			return name
+		}
+	}
+	return structField
+}
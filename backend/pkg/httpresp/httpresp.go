@@ -0,0 +1,50 @@
+package httpresp
+
+import (
+	"encoding/json"
+	"log/slog"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+const contentTypeJSON = "application/json; charset=utf-8"
+
+type errorBody struct {
+	Error errorDetail `json:"error"`
+}
+
+type errorDetail struct {
+	Code    string `json:"code,omitempty"`
+	Message string `json:"message"`
+	Fields  any    `json:"fields,omitempty"` // the bad fields of a validation error
+}
+
+// JSON marshals the payload before writing so a marshal failure becomes a clean 500
+func JSON(c *gin.Context, statusCode int, payload any) {
+	body, err := json.Marshal(payload)
+	if err != nil {
+		slog.Error("failed to marshal response", "path", c.FullPath(), "error", err)
+		Error(c, http.StatusInternalServerError, "Internal server error")
+		return
+	}
+
+	c.Data(statusCode, contentTypeJSON, body)
+}
+
+// Error writes the shared {"error": {"message": ...}} envelope and aborts the chain
+func Error(c *gin.Context, statusCode int, message string) {
+	ErrorCode(c, statusCode, "", message)
+}
+
+// ErrorCode is Error with a machine readable code, {"error": {"code": ..., "message": ...}}
+func ErrorCode(c *gin.Context, statusCode int, code, message string) {
+	ErrorFields(c, statusCode, code, message, nil)
+}
+
+// ErrorFields is ErrorCode with the list of fields that failed validation
+func ErrorFields(c *gin.Context, statusCode int, code, message string, fields any) {
+	body, _ := json.Marshal(errorBody{Error: errorDetail{Code: code, Message: message, Fields: fields}})
+	c.Abort()
+	c.Data(statusCode, contentTypeJSON, body)
+}
@@ -0,0 +1,96 @@
+package logger
+
+import (
+	"context"
+	"log/slog"
+)
+
+type attrsKey struct{}
+
+// WithAttrs stores attributes in ctx, every record logged with that ctx (slog.InfoContext and
+// friends) carries them. A key set again replaces the earlier value
+func WithAttrs(ctx context.Context, args ...any) context.Context {
+	added := slog.Group("", args...).Value.Group()
+	if len(added) == 0 {
+		return ctx
+	}
+
+	existing := AttrsFromContext(ctx)
+	merged := make([]slog.Attr, 0, len(existing)+len(added))
+	for _, attr := range existing {
+		if !hasKey(added, attr.Key) {
+			merged = append(merged, attr)
+		}
+	}
+	merged = append(merged, added...)
+	return context.WithValue(ctx, attrsKey{}, merged)
+}
+
+// WithRequestIDAttr stores the invocation's request id (see RequestID) as a context attribute
+func WithRequestIDAttr(ctx context.Context) context.Context {
+	if requestID := RequestID(ctx); requestID != "" {
+		return WithAttrs(ctx, "request_id", requestID)
+	}
+	return ctx
+}
+
+// AttrsFromContext returns the attributes stored by WithAttrs, nil when there are none
+func AttrsFromContext(ctx context.Context) []slog.Attr {
+	if ctx == nil {
+		return nil
+	}
+	attrs, _ := ctx.Value(attrsKey{}).([]slog.Attr)
+	return attrs
+}
+
+func hasKey(attrs []slog.Attr, key string) bool {
+	for _, attr := range attrs {
+		if attr.Key == key {
+			return true
+		}
+	}
+	return false
+}
+
+// contextHandler adds the context attributes to every record. Keys the logger already carries
+// (logger.With("request_id", ...)) or the call passes are not repeated
+type contextHandler struct {
+	slog.Handler
+	bound map[string]bool
+}
+
+func (h contextHandler) Handle(ctx context.Context, record slog.Record) error {
+	attrs := AttrsFromContext(ctx)
+	if len(attrs) == 0 {
+		return h.Handler.Handle(ctx, record)
+	}
+
+	passed := map[string]bool{}
+	record.Attrs(func(attr slog.Attr) bool {
+		passed[attr.Key] = true
+		return true
+	})
+
+	record = record.Clone()
+	for _, attr := range attrs {
+		if !h.bound[attr.Key] && !passed[attr.Key] {
+			record.AddAttrs(attr)
+		}
+	}
+	return h.Handler.Handle(ctx, record)
+}
+
+func (h contextHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	bound := make(map[string]bool, len(h.bound)+len(attrs))
+	for key := range h.bound {
+		bound[key] = true
+	}
+	for _, attr := range attrs {
+		bound[attr.Key] = true
+	}
+	return contextHandler{h.Handler.WithAttrs(attrs), bound}
+}
+
+func (h contextHandler) WithGroup(name string) slog.Handler {
+	return contextHandler{h.Handler.WithGroup(name), h.bound}
+}
@@ -0,0 +1,46 @@
+package logger
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+)
+
+// implemented by *awsreq.CallError. Matched by interface so the logger doesn't import awsreq,
+// whose circuit breaker emits metrics through this package
+type attrsError interface {
+	LogAttrs() []any
+}
+
+// awsHandler adds aws_request_id, aws_service and aws_operation to every record whose error
+// came from an sdk call, so a failed store call can be traced in an aws support case
+type awsHandler struct {
+	slog.Handler
+}
+
+func (h awsHandler) Handle(ctx context.Context, record slog.Record) error {
+	var extra []any
+	record.Attrs(func(attr slog.Attr) bool {
+		if err, ok := attr.Value.Any().(error); ok {
+			var callErr attrsError
+			if errors.As(err, &callErr) {
+				extra = callErr.LogAttrs()
+			}
+		}
+		return extra == nil
+	})
+
+	if extra != nil {
+		record = record.Clone()
+		record.Add(extra...)
+	}
+	return h.Handler.Handle(ctx, record)
+}
+
+func (h awsHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return awsHandler{h.Handler.WithAttrs(attrs)}
+}
+
+func (h awsHandler) WithGroup(name string) slog.Handler {
+	return awsHandler{h.Handler.WithGroup(name)}
+}
@@ -1,15 +1,89 @@
 package logger
 
 import (
+	"io"
 	"log/slog"
 	"os"
+	"strings"
 )
 
+// Writer is the stream every log line goes to, shared with the emf metrics. LOG_OUTPUT=stderr
+// moves both, stdout by default
+func Writer() io.Writer {
+	writer, _ := parseOutput(os.Getenv("LOG_OUTPUT"))
+	return writer
+}
+
+// LOG_FORMAT=text switches to slog's text handler for reading logs locally, deployments keep the
+// json default that CloudWatch Logs Insights parses
 func InitLogger() *slog.Logger {
+	level, levelOK := parseLevel(os.Getenv("LOG_LEVEL"))
+	text, formatOK := parseFormat(os.Getenv("LOG_FORMAT"))
+	_, outputOK := parseOutput(os.Getenv("LOG_OUTPUT"))
+
+	options := &slog.HandlerOptions{
+		Level:       level,
+		ReplaceAttr: ReplaceAttr(sensitiveKeys()),
+	}
+	var handler slog.Handler = slog.NewJSONHandler(Writer(), options)
+	if text {
+		handler = slog.NewTextHandler(Writer(), options)
+	}
+
+	// context attributes are added first so the aws and redaction handlers see them too
+	logger := slog.New(contextHandler{Handler: awsHandler{handler}})
 
-	logger := slog.New(slog.NewJSONHandler(os.Stdout, nil))
-	
 	slog.SetDefault(logger)
-	
+
+	if !levelOK {
+		logger.Warn("invalid LOG_LEVEL, falling back to info", "log_level", os.Getenv("LOG_LEVEL"))
+	}
+	if !formatOK {
+		logger.Warn("invalid LOG_FORMAT, falling back to json", "log_format", os.Getenv("LOG_FORMAT"))
+	}
+	if !outputOK {
+		logger.Warn("invalid LOG_OUTPUT, falling back to stdout", "log_output", os.Getenv("LOG_OUTPUT"))
+	}
+
 	return logger
-}
\ No newline at end of file
+}
+
+// true for the text format, unset means json and ok=false means the value was not recognised
+func parseFormat(value string) (bool, bool) {
+	switch strings.ToLower(strings.TrimSpace(value)) {
+	case "", "json":
+		return false, true
+	case "text":
+		return true, true
+	default:
+		return false, false
+	}
+}
+
+// maps LOG_OUTPUT to a stream, unset means stdout and ok=false means the value was not recognised
+func parseOutput(value string) (io.Writer, bool) {
+	switch strings.ToLower(strings.TrimSpace(value)) {
+	case "", "stdout":
+		return os.Stdout, true
+	case "stderr":
+		return os.Stderr, true
+	default:
+		return os.Stdout, false
+	}
+}
+
+// maps LOG_LEVEL to a slog level, unset means info and ok=false means the value was not recognised
+func parseLevel(value string) (slog.Level, bool) {
+	switch strings.ToLower(strings.TrimSpace(value)) {
+	case "debug":
+		return slog.LevelDebug, true
+	case "", "info":
+		return slog.LevelInfo, true
+	case "warn", "warning":
+		return slog.LevelWarn, true
+	case "error":
+		return slog.LevelError, true
+	default:
+		return slog.LevelInfo, false
+	}
+}
@@ -0,0 +1,58 @@
+package logger
+
+import (
+	"log/slog"
+	"os"
+	"strings"
+)
+
+const redacted = "***"
+
+// keys whose values never reach the logs, LOG_REDACT_KEYS="token,password" replaces the list
+var defaultSensitiveKeys = []string{"token", "password", "apikey", "api_key", "secret", "authorization"}
+
+func sensitiveKeys() map[string]bool {
+	list := defaultSensitiveKeys
+	if raw := os.Getenv("LOG_REDACT_KEYS"); raw != "" {
+		list = strings.Split(raw, ",")
+	}
+
+	keys := make(map[string]bool, len(list))
+	for _, key := range list {
+		if key = strings.ToLower(strings.TrimSpace(key)); key != "" {
+			keys[key] = true
+		}
+	}
+	return keys
+}
+
+// ReplaceAttr masks sensitive keys (case-insensitive), including ones nested in logged maps such as device payloads
+func ReplaceAttr(keys map[string]bool) func(groups []string, attr slog.Attr) slog.Attr {
+	return func(groups []string, attr slog.Attr) slog.Attr {
+		if keys[strings.ToLower(attr.Key)] {
+			return slog.String(attr.Key, redacted)
+		}
+		if attr.Value.Kind() == slog.KindAny {
+			if m, ok := attr.Value.Any().(map[string]interface{}); ok {
+				return slog.Any(attr.Key, redactMap(m, keys))
+			}
+		}
+		return attr
+	}
+}
+
+// copies the map, the caller's payload is still stored unmasked
+func redactMap(m map[string]interface{}, keys map[string]bool) map[string]interface{} {
+	out := make(map[string]interface{}, len(m))
+	for key, value := range m {
+		if keys[strings.ToLower(key)] {
+			out[key] = redacted
+			continue
+		}
+		if nested, ok := value.(map[string]interface{}); ok {
+			value = redactMap(nested, keys)
+		}
+		out[key] = value
+	}
+	return out
+}
@@ -0,0 +1,46 @@
+package logger
+
+import (
+	"context"
+	"log/slog"
+
+	"github.com/aws/aws-lambda-go/lambdacontext"
+	"github.com/awslabs/aws-lambda-go-api-proxy/core"
+)
+
+type ctxKey struct{}
+
+// WithRequestID returns a logger that stamps every line with the invocation request id
+func WithRequestID(ctx context.Context, logger *slog.Logger) *slog.Logger {
+	if requestID := RequestID(ctx); requestID != "" {
+		return logger.With("request_id", requestID)
+	}
+	return logger
+}
+
+// RequestID prefers the api gateway request id (rest or http api) and falls back to the lambda one
+func RequestID(ctx context.Context) string {
+	if gatewayCtx, ok := core.GetAPIGatewayContextFromContext(ctx); ok && gatewayCtx.RequestID != "" {
+		return gatewayCtx.RequestID
+	}
+	if gatewayCtx, ok := core.GetAPIGatewayV2ContextFromContext(ctx); ok && gatewayCtx.RequestID != "" {
+		return gatewayCtx.RequestID
+	}
+	if lambdaCtx, ok := lambdacontext.FromContext(ctx); ok {
+		return lambdaCtx.AwsRequestID
+	}
+	return ""
+}
+
+// NewContext stores a request scoped logger in ctx
+func NewContext(ctx context.Context, logger *slog.Logger) context.Context {
+	return context.WithValue(ctx, ctxKey{}, logger)
+}
+
+// FromContext returns the request scoped logger or the default one
+func FromContext(ctx context.Context) *slog.Logger {
+	if logger, ok := ctx.Value(ctxKey{}).(*slog.Logger); ok {
+		return logger
+	}
+	return slog.Default()
+}
@@ -0,0 +1,67 @@
+package logger
+
+import (
+	"context"
+	"log/slog"
+	"math"
+	"os"
+	"strconv"
+	"sync/atomic"
+)
+
+// SampleRate is LOG_SAMPLE_RATE, the share of debug and info records a sampled logger keeps.
+// 1 (keep all) when unset or not between 0 and 1
+func SampleRate() float64 {
+	rate, err := strconv.ParseFloat(os.Getenv("LOG_SAMPLE_RATE"), 64)
+	if err != nil || rate < 0 || rate > 1 {
+		return 1
+	}
+	return rate
+}
+
+// Sampled keeps one in every 1/rate debug and info records of log, counted in order so 0.01
+// keeps exactly every hundredth. Warnings and errors always pass. The decision is made in
+// Enabled, so a dropped record is never built. A rate of 1 returns log as it is
+func Sampled(log *slog.Logger, rate float64) *slog.Logger {
+	if rate >= 1 {
+		return log
+	}
+	var every uint64
+	if rate > 0 {
+		every = uint64(math.Round(1 / rate))
+	}
+	return slog.New(&sampleHandler{Handler: log.Handler(), every: every, seen: new(atomic.Uint64)})
+}
+
+// Unsampled is the logger Sampled wrapped, with the attributes added since. For the few info
+// records that must always be written, like the summary of an invocation
+func Unsampled(log *slog.Logger) *slog.Logger {
+	if sampled, ok := log.Handler().(*sampleHandler); ok {
+		return slog.New(sampled.Handler)
+	}
+	return log
+}
+
+type sampleHandler struct {
+	slog.Handler
+	every uint64         // keep one in every, 0 keeps none
+	seen  *atomic.Uint64 // shared with the handlers derived by With, the rate holds across them
+}
+
+func (h *sampleHandler) Enabled(ctx context.Context, level slog.Level) bool {
+	if level >= slog.LevelWarn {
+		return h.Handler.Enabled(ctx, level)
+	}
+	if h.every == 0 || !h.Handler.Enabled(ctx, level) {
+		return false
+	}
+	return (h.seen.Add(1)-1)%h.every == 0
+}
+
+func (h *sampleHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return &sampleHandler{Handler: h.Handler.WithAttrs(attrs), every: h.every, seen: h.seen}
+}
+
+func (h *sampleHandler) WithGroup(name string) slog.Handler {
+	return &sampleHandler{Handler: h.Handler.WithGroup(name), every: h.every, seen: h.seen}
+}
@@ -0,0 +1,192 @@
+package metrics
+
+import (
+	"context"
+	"encoding/json"
+	"log/slog"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Fleexa-Graduation-Project/Backend/pkg/logger"
+)
+
+const (
+	defaultNamespace  = "Fleexa/Ingestion"
+	maxBufferedSeries = 100 // flushed early past this, a batch only touches a handful of series
+	maxSeriesValues   = 100 // EMF limit of values in one metric line
+)
+
+// CloudWatch Embedded Metric Format, see
+// https://docs.aws.amazon.com/AmazonCloudWatch/latest/monitoring/CloudWatch_Embedded_Metric_Format_Specification.html
+type emfMetadata struct {
+	Timestamp         int64                `json:"Timestamp"`
+	CloudWatchMetrics []emfMetricDirective `json:"CloudWatchMetrics"`
+}
+
+type emfMetricDirective struct {
+	Namespace  string          `json:"Namespace"`
+	Dimensions [][]string      `json:"Dimensions"`
+	Metrics    []emfDefinition `json:"Metrics"`
+}
+
+type emfDefinition struct {
+	Name string `json:"Name"`
+	Unit string `json:"Unit"`
+}
+
+var binaryNamespace = defaultNamespace
+
+// SetNamespace sets the namespace of the binary's metrics, METRICS_NAMESPACE still overrides it.
+// Call it from main before anything is recorded
+func SetNamespace(ns string) {
+	binaryNamespace = ns
+}
+
+func namespace() string {
+	if ns := os.Getenv("METRICS_NAMESPACE"); ns != "" {
+		return ns
+	}
+	return binaryNamespace
+}
+
+type series struct {
+	name   string
+	unit   string
+	dims   map[string]string
+	value  float64
+	values []float64 // timings keep every sample so CloudWatch can compute percentiles
+}
+
+var (
+	mu     sync.Mutex
+	buffer = map[string]*series{}
+)
+
+// Count adds to a Count metric buffered for the current invocation, Flush writes it out.
+// Counts with the same name and dimensions are summed into one EMF line
+func Count(name string, value float64, dims map[string]string) {
+	add(name, "Count", value, dims)
+}
+
+// Timing buffers one duration sample in milliseconds. Samples are not summed, each EMF line
+// carries the list of them (up to 100) so p50/p99 stay available
+func Timing(name string, duration time.Duration, dims map[string]string) {
+	key := seriesKey(name, "Milliseconds", dims)
+	ms := float64(duration.Microseconds()) / 1000
+
+	mu.Lock()
+	entry, ok := buffer[key]
+	if !ok {
+		entry = &series{name: name, unit: "Milliseconds", dims: copyDims(dims)}
+		buffer[key] = entry
+	}
+	entry.values = append(entry.values, ms)
+	full := len(buffer) >= maxBufferedSeries || len(entry.values) >= maxSeriesValues
+	mu.Unlock()
+
+	if full {
+		Flush(context.Background())
+	}
+}
+
+func add(name, unit string, value float64, dims map[string]string) {
+	key := seriesKey(name, unit, dims)
+
+	mu.Lock()
+	if existing, ok := buffer[key]; ok {
+		existing.value += value
+		mu.Unlock()
+		return
+	}
+
+	buffer[key] = &series{name: name, unit: unit, dims: copyDims(dims), value: value}
+	full := len(buffer) >= maxBufferedSeries
+	mu.Unlock()
+
+	if full {
+		Flush(context.Background())
+	}
+}
+
+// Flush writes every buffered metric to stdout and empties the buffer. Handlers run through
+// recovery.Wrap, which defers Flush so metrics are out before control goes back to the lambda
+// runtime, including on errors and panics
+func Flush(ctx context.Context) {
+	mu.Lock()
+	pending := buffer
+	buffer = map[string]*series{}
+	mu.Unlock()
+
+	keys := make([]string, 0, len(pending))
+	for key := range pending {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	for _, key := range keys {
+		entry := pending[key]
+		if entry.values != nil {
+			emit(entry.name, entry.unit, entry.values, entry.dims)
+			continue
+		}
+		emit(entry.name, entry.unit, entry.value, entry.dims)
+	}
+}
+
+func copyDims(dims map[string]string) map[string]string {
+	copied := make(map[string]string, len(dims))
+	for k, v := range dims {
+		copied[k] = v
+	}
+	return copied
+}
+
+func seriesKey(name, unit string, dims map[string]string) string {
+	keys := make([]string, 0, len(dims))
+	for key := range dims {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	var b strings.Builder
+	b.WriteString(name + "|" + unit)
+	for _, key := range keys {
+		b.WriteString("|" + key + "=" + dims[key])
+	}
+	return b.String()
+}
+
+// value is a float64 or, for timings, a []float64 of samples
+func emit(name, unit string, value interface{}, dims map[string]string) {
+	keys := make([]string, 0, len(dims))
+	for key := range dims {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	record := make(map[string]interface{}, len(dims)+2)
+	for key, val := range dims {
+		record[key] = val
+	}
+	record[name] = value
+	record["_aws"] = emfMetadata{
+		Timestamp: time.Now().UnixMilli(),
+		CloudWatchMetrics: []emfMetricDirective{{
+			Namespace:  namespace(),
+			Dimensions: [][]string{keys},
+			Metrics:    []emfDefinition{{Name: name, Unit: unit}},
+		}},
+	}
+
+	line, err := json.Marshal(record)
+	if err != nil {
+		slog.Warn("failed to marshal emf metric", "metric", name, "error", err)
+		return
+	}
+
+	// same writer as the logger so metric lines land in the same log stream
+	logger.Writer().Write(append(line, '\n'))
+}
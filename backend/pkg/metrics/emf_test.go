@@ -0,0 +1,168 @@
+package metrics
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"reflect"
+	"testing"
+	"time"
+)
+
+// runs record, flushes and returns the emf lines written to stdout
+func flushed(t *testing.T, record func()) []map[string]interface{} {
+	t.Helper()
+	reader, writer, err := os.Pipe()
+	if err != nil {
+		t.Fatalf("os.Pipe: %v", err)
+	}
+	stdout := os.Stdout
+	os.Stdout = writer
+	defer func() { os.Stdout = stdout }()
+
+	record()
+	Flush(context.Background())
+	writer.Close()
+
+	var lines []map[string]interface{}
+	scanner := bufio.NewScanner(reader)
+	for scanner.Scan() {
+		var line map[string]interface{}
+		if err := json.Unmarshal(scanner.Bytes(), &line); err != nil {
+			t.Fatalf("emf line %q is not json: %v", scanner.Text(), err)
+		}
+		lines = append(lines, line)
+	}
+	return lines
+}
+
+func TestFlush(t *testing.T) {
+	t.Setenv("METRICS_NAMESPACE", "Fleexa/Test")
+
+	tests := []struct {
+		name   string
+		record func()
+		want   []map[string]interface{} // the metric and dimension fields of each line, in flush order
+	}{
+		{
+			name: "counts of one series are summed",
+			record: func() {
+				Count("MessagesProcessed", 1, map[string]string{"FleetID": "fleet-a"})
+				Count("MessagesProcessed", 2, map[string]string{"FleetID": "fleet-a"})
+			},
+			want: []map[string]interface{}{{"MessagesProcessed": 3.0, "FleetID": "fleet-a"}},
+		},
+		{
+			name: "other dimensions are another series",
+			record: func() {
+				Count("MessagesProcessed", 1, map[string]string{"FleetID": "fleet-b"})
+				Count("MessagesProcessed", 1, map[string]string{"FleetID": "fleet-a"})
+			},
+			want: []map[string]interface{}{
+				{"MessagesProcessed": 1.0, "FleetID": "fleet-a"},
+				{"MessagesProcessed": 1.0, "FleetID": "fleet-b"},
+			},
+		},
+		{
+			name: "timings keep every sample",
+			record: func() {
+				Timing("HandlerLatency", 12*time.Millisecond, nil)
+				Timing("HandlerLatency", 1500*time.Microsecond, nil)
+			},
+			want: []map[string]interface{}{{"HandlerLatency": []interface{}{12.0, 1.5}}},
+		},
+		{name: "nothing buffered writes nothing", record: func() {}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			lines := flushed(t, test.record)
+			if len(lines) != len(test.want) {
+				t.Fatalf("got %d lines %v, want %d", len(lines), lines, len(test.want))
+			}
+			for i, line := range lines {
+				metadata := line["_aws"].(map[string]interface{})
+				delete(line, "_aws")
+				if !reflect.DeepEqual(line, test.want[i]) {
+					t.Errorf("line %d = %v, want %v", i, line, test.want[i])
+				}
+
+				directive := metadata["CloudWatchMetrics"].([]interface{})[0].(map[string]interface{})
+				if directive["Namespace"] != "Fleexa/Test" {
+					t.Errorf("line %d namespace = %v, want Fleexa/Test", i, directive["Namespace"])
+				}
+				wantDims := "[]"
+				if _, ok := line["FleetID"]; ok {
+					wantDims = "[FleetID]"
+				}
+				if dims := fmt.Sprint(directive["Dimensions"].([]interface{})[0]); dims != wantDims {
+					t.Errorf("line %d dimensions = %s, want %s", i, dims, wantDims)
+				}
+			}
+		})
+	}
+}
+
+func TestFlushEmptiesTheBuffer(t *testing.T) {
+	flushed(t, func() { Count("RateLimited", 1, nil) })
+	if lines := flushed(t, func() {}); len(lines) != 0 {
+		t.Errorf("second flush wrote %v, want nothing", lines)
+	}
+}
+
+func TestFullBufferFlushesEarly(t *testing.T) {
+	lines := flushed(t, func() {
+		for i := 0; i < maxSeriesValues+1; i++ {
+			Timing("HandlerLatency", time.Millisecond, nil)
+		}
+		// the early flush already wrote the first 100 samples
+		mu.Lock()
+		pending := len(buffer)
+		mu.Unlock()
+		if pending != 1 {
+			t.Errorf("%d series still buffered, want the one with the last sample", pending)
+		}
+	})
+	if len(lines) != 2 {
+		t.Fatalf("got %d lines, want the early flush and the final one", len(lines))
+	}
+	if samples := lines[0]["HandlerLatency"].([]interface{}); len(samples) != maxSeriesValues {
+		t.Errorf("early flush carried %d samples, want %d", len(samples), maxSeriesValues)
+	}
+}
+
+func TestNamespace(t *testing.T) {
+	defer SetNamespace(defaultNamespace)
+
+	tests := []struct {
+		name string
+		set  string
+		env  string
+		want string
+	}{
+		{name: "default", want: "Fleexa/Ingestion"},
+		{name: "set by the binary", set: "Fleexa/API", want: "Fleexa/API"},
+		{name: "env overrides the binary", set: "Fleexa/API", env: "Fleexa/Test", want: "Fleexa/Test"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			SetNamespace(defaultNamespace)
+			if test.set != "" {
+				SetNamespace(test.set)
+			}
+			t.Setenv("METRICS_NAMESPACE", test.env)
+
+			lines := flushed(t, func() { Count("RequestsServed", 1, nil) })
+			if len(lines) != 1 {
+				t.Fatalf("flushed %d lines, want 1", len(lines))
+			}
+			directive := lines[0]["_aws"].(map[string]interface{})["CloudWatchMetrics"].([]interface{})[0].(map[string]interface{})
+			if directive["Namespace"] != test.want {
+				t.Errorf("Namespace = %v, want %s", directive["Namespace"], test.want)
+			}
+		})
+	}
+}
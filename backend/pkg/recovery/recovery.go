@@ -0,0 +1,63 @@
+package recovery
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"runtime"
+
+	"github.com/Fleexa-Graduation-Project/Backend/pkg/logger"
+	"github.com/Fleexa-Graduation-Project/Backend/pkg/metrics"
+)
+
+const maxStackBytes = 16 << 10
+
+// ErrPanic is wrapped by the errors Handle returns
+var ErrPanic = errors.New("panic")
+
+// Handle logs a recovered panic with its stack at error level, counts it as a Panics metric
+// and turns it into an error. Call it from the deferred function that called recover()
+func Handle(ctx context.Context, component string, recovered any) error {
+	stack := make([]byte, maxStackBytes)
+	stack = stack[:runtime.Stack(stack, false)]
+
+	logger.FromContext(ctx).Error("panic recovered",
+		"component", component,
+		"panic", fmt.Sprint(recovered),
+		"stack", string(stack),
+	)
+	metrics.Count("Panics", 1, map[string]string{"Component": component})
+
+	return fmt.Errorf("%w in %s: %v", ErrPanic, component, recovered)
+}
+
+// Wrap guards a lambda handler, a panic becomes a logged error instead of a crashed runtime.
+// The request id is put on the context for slog's *Context calls.
+// Deferred calls run last in first out, so the recover below (and its Panics count) runs
+// before the metrics flush and both happen before the runtime gets the response
+func Wrap[Event, Response any](component string, handler func(context.Context, Event) (Response, error)) func(context.Context, Event) (Response, error) {
+	return func(ctx context.Context, event Event) (response Response, err error) {
+		ctx = logger.WithRequestIDAttr(ctx)
+		defer metrics.Flush(ctx)
+		defer func() {
+			if r := recover(); r != nil {
+				err = Handle(ctx, component, r)
+			}
+		}()
+		return handler(ctx, event)
+	}
+}
+
+// WrapEvent is Wrap for handlers that only return an error, e.g. scheduled lambdas
+func WrapEvent[Event any](component string, handler func(context.Context, Event) error) func(context.Context, Event) error {
+	return func(ctx context.Context, event Event) (err error) {
+		ctx = logger.WithRequestIDAttr(ctx)
+		defer metrics.Flush(ctx)
+		defer func() {
+			if r := recover(); r != nil {
+				err = Handle(ctx, component, r)
+			}
+		}()
+		return handler(ctx, event)
+	}
+}
@@ -0,0 +1,125 @@
+package recovery
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"log/slog"
+	"os"
+	"testing"
+
+	"github.com/Fleexa-Graduation-Project/Backend/pkg/logger"
+	"github.com/Fleexa-Graduation-Project/Backend/pkg/metrics"
+)
+
+// runs call and returns the emf lines it wrote to stdout
+func emfLines(t *testing.T, call func()) []map[string]interface{} {
+	t.Helper()
+	reader, writer, err := os.Pipe()
+	if err != nil {
+		t.Fatalf("os.Pipe: %v", err)
+	}
+	stdout := os.Stdout
+	os.Stdout = writer
+	defer func() { os.Stdout = stdout }()
+
+	call()
+	writer.Close()
+
+	var lines []map[string]interface{}
+	scanner := bufio.NewScanner(reader)
+	for scanner.Scan() {
+		var line map[string]interface{}
+		if err := json.Unmarshal(scanner.Bytes(), &line); err == nil && line["_aws"] != nil {
+			lines = append(lines, line)
+		}
+	}
+	return lines
+}
+
+func TestWrapFlushesMetrics(t *testing.T) {
+	errFailed := errors.New("store unavailable")
+
+	tests := []struct {
+		name       string
+		handler    func(context.Context, string) (string, error)
+		wantErr    error
+		wantPanics bool
+	}{
+		{
+			name: "handler error",
+			handler: func(ctx context.Context, event string) (string, error) {
+				metrics.Count("RecordsFailed", 1, nil)
+				return "", errFailed
+			},
+			wantErr: errFailed,
+		},
+		{
+			name: "handler panic",
+			handler: func(ctx context.Context, event string) (string, error) {
+				metrics.Count("RecordsFailed", 1, nil)
+				panic("nil map")
+			},
+			wantErr:    ErrPanic,
+			wantPanics: true,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			ctx := logger.NewContext(context.Background(), slog.New(slog.NewTextHandler(io.Discard, nil)))
+
+			var err error
+			lines := emfLines(t, func() {
+				_, err = Wrap("test", test.handler)(ctx, "event")
+			})
+			if !errors.Is(err, test.wantErr) {
+				t.Fatalf("Wrap() error = %v, want %v", err, test.wantErr)
+			}
+
+			found := map[string]interface{}{}
+			for _, line := range lines {
+				for _, name := range []string{"RecordsFailed", "Panics"} {
+					if value, ok := line[name]; ok {
+						found[name] = value
+						if name == "Panics" && line["Component"] != "test" {
+							t.Errorf("Panics Component = %v, want test", line["Component"])
+						}
+					}
+				}
+			}
+			if found["RecordsFailed"] != 1.0 {
+				t.Errorf("RecordsFailed = %v, want the handler's metric flushed", found["RecordsFailed"])
+			}
+			if _, ok := found["Panics"]; ok != test.wantPanics {
+				t.Errorf("Panics flushed = %v, want %v", ok, test.wantPanics)
+			}
+		})
+	}
+}
+
+func TestWrapEventFlushesMetrics(t *testing.T) {
+	ctx := logger.NewContext(context.Background(), slog.New(slog.NewTextHandler(io.Discard, nil)))
+
+	var err error
+	lines := emfLines(t, func() {
+		err = WrapEvent("scheduler", func(ctx context.Context, event string) error {
+			metrics.Count("DevicesChecked", 3, nil)
+			panic("boom")
+		})(ctx, "tick")
+	})
+	if !errors.Is(err, ErrPanic) {
+		t.Fatalf("WrapEvent() error = %v, want ErrPanic", err)
+	}
+
+	var checked, panics bool
+	for _, line := range lines {
+		checked = checked || line["DevicesChecked"] == 3.0
+		panics = panics || (line["Panics"] == 1.0 && line["Component"] == "scheduler")
+	}
+	if !checked || !panics {
+		t.Errorf("flushed DevicesChecked = %v, Panics = %v, want both", checked, panics)
+	}
+}
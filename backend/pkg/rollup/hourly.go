@@ -0,0 +1,102 @@
+package rollup
+
+import (
+	"cmp"
+	"math"
+	"slices"
+	"time"
+
+	"github.com/Fleexa-Graduation-Project/Backend/pkg/geo"
+)
+
+// payload fields that are numeric but not measurements, lat/lon go into the distance instead
+var skippedFields = map[string]bool{"seq": true, "lat": true, "lon": true}
+
+// Reading is one stored reading as the rollup sees it, the timestamp is unix seconds
+type Reading struct {
+	Timestamp int64
+	Payload   map[string]interface{}
+}
+
+type MetricStats struct {
+	Min   float64 `json:"min" dynamodbav:"min"`
+	Max   float64 `json:"max" dynamodbav:"max"`
+	Avg   float64 `json:"avg" dynamodbav:"avg"`
+	Count int     `json:"count" dynamodbav:"count"`
+}
+
+// Hourly aggregates one device's readings of one hour, keyed device_id (HASH) + hour_start (RANGE)
+type Hourly struct {
+	DeviceID   string                 `json:"device_id" dynamodbav:"device_id"`
+	HourStart  int64                  `json:"hour_start" dynamodbav:"hour_start"`
+	Readings   int                    `json:"readings" dynamodbav:"readings"`
+	Metrics    map[string]MetricStats `json:"metrics" dynamodbav:"metrics"`
+	DistanceKM float64                `json:"distance_km" dynamodbav:"distance_km"`
+}
+
+// HourStart is the start of the hour t falls in, in UTC
+func HourStart(t time.Time) time.Time {
+	return t.UTC().Truncate(time.Hour)
+}
+
+// Aggregate computes min/max/avg/count of every numeric payload field and the distance between
+// consecutive gps positions, over the readings with hour <= timestamp < hour+1h. Readings outside
+// the hour are ignored, false means no reading was in it
+func Aggregate(deviceID string, hour time.Time, readings []Reading) (Hourly, bool) {
+	start := HourStart(hour).Unix()
+	end := start + int64(time.Hour/time.Second)
+
+	inHour := make([]Reading, 0, len(readings))
+	for _, reading := range readings {
+		if reading.Timestamp >= start && reading.Timestamp < end {
+			inHour = append(inHour, reading)
+		}
+	}
+	if len(inHour) == 0 {
+		return Hourly{}, false
+	}
+	slices.SortStableFunc(inHour, func(a, b Reading) int { return cmp.Compare(a.Timestamp, b.Timestamp) })
+
+	hourly := Hourly{DeviceID: deviceID, HourStart: start, Readings: len(inHour), Metrics: map[string]MetricStats{}}
+	sums := map[string]float64{}
+
+	var last geo.Coord
+	hasLast := false
+	for _, reading := range inHour {
+		for field, raw := range reading.Payload {
+			value, ok := raw.(float64)
+			if !ok || skippedFields[field] || math.IsNaN(value) || math.IsInf(value, 0) {
+				continue
+			}
+			stats, seen := hourly.Metrics[field]
+			if !seen || value < stats.Min {
+				stats.Min = value
+			}
+			if !seen || value > stats.Max {
+				stats.Max = value
+			}
+			stats.Count++
+			sums[field] += value
+			hourly.Metrics[field] = stats
+		}
+
+		lat, latOK := reading.Payload["lat"].(float64)
+		lon, lonOK := reading.Payload["lon"].(float64)
+		if !latOK || !lonOK || !geo.ValidCoord(lat, lon) {
+			continue
+		}
+		position := geo.Coord{Lat: lat, Lon: lon}
+		if hasLast {
+			hourly.DistanceKM += geo.DistanceKM(last, position)
+		}
+		last, hasLast = position, true
+	}
+
+	for field, stats := range hourly.Metrics {
+		stats.Avg = sums[field] / float64(stats.Count)
+		hourly.Metrics[field] = stats
+	}
+	hourly.DistanceKM = math.Round(hourly.DistanceKM*1000) / 1000
+
+	return hourly, true
+}
@@ -0,0 +1,99 @@
+package rollup
+
+import (
+	"math"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/Fleexa-Graduation-Project/Backend/pkg/geo"
+)
+
+func TestHourStart(t *testing.T) {
+	cairo := time.FixedZone("EET", 2*60*60)
+	tests := []struct {
+		name string
+		in   time.Time
+		want time.Time
+	}{
+		{name: "mid hour", in: time.Date(2026, 3, 1, 10, 42, 7, 0, time.UTC), want: time.Date(2026, 3, 1, 10, 0, 0, 0, time.UTC)},
+		{name: "on the hour", in: time.Date(2026, 3, 1, 10, 0, 0, 0, time.UTC), want: time.Date(2026, 3, 1, 10, 0, 0, 0, time.UTC)},
+		{name: "other zone is utc", in: time.Date(2026, 3, 1, 1, 30, 0, 0, cairo), want: time.Date(2026, 2, 28, 23, 0, 0, 0, time.UTC)},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if got := HourStart(test.in); !got.Equal(test.want) || got.Location() != time.UTC {
+				t.Errorf("HourStart(%s) = %s, want %s", test.in, got, test.want)
+			}
+		})
+	}
+}
+
+func TestAggregate(t *testing.T) {
+	hour := time.Date(2026, 3, 1, 10, 0, 0, 0, time.UTC)
+	start := hour.Unix()
+	// one degree of latitude apart
+	leg := geo.DistanceKM(geo.Coord{Lat: 30, Lon: 31}, geo.Coord{Lat: 31, Lon: 31})
+
+	tests := []struct {
+		name         string
+		readings     []Reading
+		want         Hourly
+		wantReadings bool
+	}{
+		{
+			name: "stats of the numeric fields",
+			readings: []Reading{
+				{Timestamp: start + 60, Payload: map[string]interface{}{"temp": 4.0, "seq": 1.0, "door": "open"}},
+				{Timestamp: start + 120, Payload: map[string]interface{}{"temp": 8.0, "humidity": 40.0, "seq": 2.0}},
+				{Timestamp: start + 180, Payload: map[string]interface{}{"temp": 6.0, "humidity": math.NaN()}},
+			},
+			want: Hourly{DeviceID: "truck-1", HourStart: start, Readings: 3, Metrics: map[string]MetricStats{
+				"temp":     {Min: 4, Max: 8, Avg: 6, Count: 3},
+				"humidity": {Min: 40, Max: 40, Avg: 40, Count: 1},
+			}},
+			wantReadings: true,
+		},
+		{
+			name: "distance follows the timestamps, not the input order",
+			readings: []Reading{
+				{Timestamp: start + 120, Payload: map[string]interface{}{"lat": 31.0, "lon": 31.0}},
+				{Timestamp: start + 60, Payload: map[string]interface{}{"lat": 30.0, "lon": 31.0}},
+				{Timestamp: start + 150, Payload: map[string]interface{}{"lat": 91.0, "lon": 31.0}}, // bad fix, skipped
+				{Timestamp: start + 180, Payload: map[string]interface{}{"lat": 30.0, "lon": 31.0}},
+			},
+			want:         Hourly{DeviceID: "truck-1", HourStart: start, Readings: 4, Metrics: map[string]MetricStats{}, DistanceKM: math.Round(2*leg*1000) / 1000},
+			wantReadings: true,
+		},
+		{
+			name: "readings of other hours are left out",
+			readings: []Reading{
+				{Timestamp: start - 1, Payload: map[string]interface{}{"temp": 100.0}},
+				{Timestamp: start, Payload: map[string]interface{}{"temp": 5.0}},
+				{Timestamp: start + 3600, Payload: map[string]interface{}{"temp": -100.0}},
+			},
+			want: Hourly{DeviceID: "truck-1", HourStart: start, Readings: 1, Metrics: map[string]MetricStats{
+				"temp": {Min: 5, Max: 5, Avg: 5, Count: 1},
+			}},
+			wantReadings: true,
+		},
+		{
+			name:     "nothing in the hour",
+			readings: []Reading{{Timestamp: start + 7200, Payload: map[string]interface{}{"temp": 5.0}}},
+		},
+		{name: "no readings"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			// any time in the hour picks the same window
+			got, ok := Aggregate("truck-1", hour.Add(25*time.Minute), test.readings)
+			if ok != test.wantReadings {
+				t.Fatalf("Aggregate() ok = %v, want %v", ok, test.wantReadings)
+			}
+			if !reflect.DeepEqual(got, test.want) {
+				t.Errorf("Aggregate() = %+v, want %+v", got, test.want)
+			}
+		})
+	}
+}
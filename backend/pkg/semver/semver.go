@@ -0,0 +1,89 @@
+package semver
+
+import (
+	"cmp"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+var ErrInvalidVersion = errors.New("invalid semantic version")
+
+// Version is MAJOR.MINOR.PATCH with an optional pre-release, build metadata is ignored
+type Version struct {
+	Major, Minor, Patch int
+	PreRelease          string
+}
+
+// Parse accepts "1.10.0", "v2.0.1-rc.1" and "1.4" (missing patch is 0)
+func Parse(raw string) (Version, error) {
+	value := strings.TrimPrefix(strings.TrimSpace(raw), "v")
+	value, _, _ = strings.Cut(value, "+")
+	core, preRelease, _ := strings.Cut(value, "-")
+
+	parts := strings.Split(core, ".")
+	if len(parts) < 2 || len(parts) > 3 {
+		return Version{}, fmt.Errorf("%w: %q", ErrInvalidVersion, raw)
+	}
+
+	numbers := [3]int{}
+	for i, part := range parts {
+		n, err := strconv.Atoi(part)
+		if err != nil || n < 0 || (len(part) > 1 && part[0] == '0') {
+			return Version{}, fmt.Errorf("%w: %q", ErrInvalidVersion, raw)
+		}
+		numbers[i] = n
+	}
+
+	return Version{Major: numbers[0], Minor: numbers[1], Patch: numbers[2], PreRelease: preRelease}, nil
+}
+
+func (v Version) String() string {
+	if v.PreRelease != "" {
+		return fmt.Sprintf("%d.%d.%d-%s", v.Major, v.Minor, v.Patch, v.PreRelease)
+	}
+	return fmt.Sprintf("%d.%d.%d", v.Major, v.Minor, v.Patch)
+}
+
+// Compare returns -1, 0 or 1, numerically per component so 1.10.0 > 1.9.0, and a
+// pre-release sorts before its release (1.0.0-rc.1 < 1.0.0)
+func Compare(a, b Version) int {
+	if c := cmp.Or(cmp.Compare(a.Major, b.Major), cmp.Compare(a.Minor, b.Minor), cmp.Compare(a.Patch, b.Patch)); c != 0 {
+		return c
+	}
+	return comparePreRelease(a.PreRelease, b.PreRelease)
+}
+
+func comparePreRelease(a, b string) int {
+	switch {
+	case a == b:
+		return 0
+	case a == "":
+		return 1
+	case b == "":
+		return -1
+	}
+
+	left, right := strings.Split(a, "."), strings.Split(b, ".")
+	for i := 0; i < len(left) && i < len(right); i++ {
+		leftNum, leftErr := strconv.Atoi(left[i])
+		rightNum, rightErr := strconv.Atoi(right[i])
+
+		var c int
+		switch {
+		case leftErr == nil && rightErr == nil:
+			c = cmp.Compare(leftNum, rightNum)
+		case leftErr == nil:
+			c = -1 // numeric identifiers sort before alphanumeric ones
+		case rightErr == nil:
+			c = 1
+		default:
+			c = strings.Compare(left[i], right[i])
+		}
+		if c != 0 {
+			return c
+		}
+	}
+	return cmp.Compare(len(left), len(right))
+}
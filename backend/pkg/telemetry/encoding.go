@@ -0,0 +1,65 @@
+package telemetry
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+const (
+	EncodingJSON     = "json"
+	EncodingProtobuf = "protobuf"
+)
+
+var ErrUnsupportedEncoding = errors.New("unsupported payload encoding")
+
+// ParseEncoding maps an encoding marker or content type to EncodingJSON or EncodingProtobuf,
+// empty means json
+func ParseEncoding(marker string) (string, error) {
+	switch strings.ToLower(strings.TrimSpace(marker)) {
+	case "", "json", "application/json":
+		return EncodingJSON, nil
+	case "protobuf", "proto", "application/protobuf", "application/x-protobuf":
+		return EncodingProtobuf, nil
+	default:
+		return "", fmt.Errorf("%w %q, expected json or protobuf", ErrUnsupportedEncoding, marker)
+	}
+}
+
+// Decode returns the payload as the json envelope the ingestion validators take. Json passes
+// through unchanged, a protobuf Reading becomes the v1 envelope with the same fields, so
+// everything after decoding can't tell the two apart
+func Decode(encoding string, payload []byte) (json.RawMessage, error) {
+	switch encoding {
+	case EncodingJSON:
+		return payload, nil
+	case EncodingProtobuf:
+		reading, err := UnmarshalReading(payload)
+		if err != nil {
+			return nil, err
+		}
+		return reading.envelope()
+	default:
+		return nil, fmt.Errorf("%w %q", ErrUnsupportedEncoding, encoding)
+	}
+}
+
+// the schema version 1 envelope: {"schema_version", "device_id", "timestamp", "type", "payload"}
+func (reading Reading) envelope() (json.RawMessage, error) {
+	envelope := map[string]interface{}{
+		"schema_version": 1,
+		"device_id":      reading.DeviceID,
+		"timestamp":      reading.Timestamp,
+		"type":           reading.Type,
+	}
+	if reading.Payload != nil {
+		envelope["payload"] = reading.Payload
+	}
+
+	raw, err := json.Marshal(envelope)
+	if err != nil {
+		return nil, fmt.Errorf("failed to convert protobuf reading to json: %w", err)
+	}
+	return raw, nil
+}
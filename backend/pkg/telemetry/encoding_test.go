@@ -0,0 +1,90 @@
+package telemetry
+
+import (
+	"encoding/json"
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestParseEncoding(t *testing.T) {
+	tests := []struct {
+		marker  string
+		want    string
+		wantErr bool
+	}{
+		{marker: "", want: EncodingJSON},
+		{marker: "application/json", want: EncodingJSON},
+		{marker: " Protobuf ", want: EncodingProtobuf},
+		{marker: "application/x-protobuf", want: EncodingProtobuf},
+		{marker: "proto", want: EncodingProtobuf},
+		{marker: "cbor", wantErr: true},
+	}
+	for _, test := range tests {
+		got, err := ParseEncoding(test.marker)
+		if test.wantErr {
+			if !errors.Is(err, ErrUnsupportedEncoding) {
+				t.Errorf("ParseEncoding(%q) error = %v, want ErrUnsupportedEncoding", test.marker, err)
+			}
+			continue
+		}
+		if err != nil || got != test.want {
+			t.Errorf("ParseEncoding(%q) = %q, %v, want %q", test.marker, got, err, test.want)
+		}
+	}
+}
+
+func TestDecode(t *testing.T) {
+	jsonEnvelope := `{"schema_version": 1, "device_id": "truck-1", "timestamp": 1700000000, "type": "temp-sensor", "payload": {"temp": 4.5}}`
+	reading := Reading{DeviceID: "truck-1", Timestamp: 1700000000, Type: "temp-sensor", Payload: map[string]interface{}{"temp": 4.5}}
+
+	tests := []struct {
+		name     string
+		encoding string
+		payload  []byte
+		want     string // compared as json
+		wantErr  bool
+		// errors.Is target when the error has one
+		wantErrIs error
+	}{
+		{name: "json passes through", encoding: EncodingJSON, payload: []byte(jsonEnvelope), want: jsonEnvelope},
+		{name: "protobuf becomes the same json", encoding: EncodingProtobuf, payload: encodeReading(t, reading, nil), want: jsonEnvelope},
+		{
+			name:     "protobuf without payload leaves it out",
+			encoding: EncodingProtobuf,
+			payload:  encodeReading(t, Reading{DeviceID: "truck-1", Timestamp: 1700000000, Type: "heartbeat"}, nil),
+			want:     `{"schema_version": 1, "device_id": "truck-1", "timestamp": 1700000000, "type": "heartbeat"}`,
+		},
+		{name: "bad protobuf", encoding: EncodingProtobuf, payload: []byte{0x0a, 0x20}, wantErr: true},
+		{name: "unknown encoding", encoding: "cbor", payload: []byte{0x01}, wantErr: true, wantErrIs: ErrUnsupportedEncoding},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got, err := Decode(test.encoding, test.payload)
+			if test.wantErr {
+				if err == nil {
+					t.Fatalf("Decode() = %s, want an error", got)
+				}
+				if test.wantErrIs != nil && !errors.Is(err, test.wantErrIs) {
+					t.Errorf("Decode() error = %v, want %v", err, test.wantErrIs)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Decode() error = %v", err)
+			}
+
+			var gotValue, wantValue interface{}
+			if err := json.Unmarshal(got, &gotValue); err != nil {
+				t.Fatalf("Decode() returned invalid json %s: %v", got, err)
+			}
+			if err := json.Unmarshal([]byte(test.want), &wantValue); err != nil {
+				t.Fatalf("bad want: %v", err)
+			}
+			if !reflect.DeepEqual(gotValue, wantValue) {
+				t.Errorf("Decode() = %s, want %s", got, test.want)
+			}
+		})
+	}
+}
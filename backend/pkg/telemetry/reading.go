@@ -0,0 +1,70 @@
+package telemetry
+
+import (
+	"fmt"
+
+	"google.golang.org/protobuf/encoding/protowire"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/structpb"
+)
+
+// field numbers of the Reading message in reading.proto
+const (
+	fieldDeviceID  protowire.Number = 1
+	fieldTimestamp protowire.Number = 2
+	fieldType      protowire.Number = 3
+	fieldPayload   protowire.Number = 4
+)
+
+// Reading is the Reading message of reading.proto. The message is small and stable, so it is
+// read field by field with protowire instead of a protoc generated type
+type Reading struct {
+	DeviceID  string
+	Timestamp int64
+	Type      string
+	Payload   map[string]interface{}
+}
+
+// UnmarshalReading decodes a protobuf Reading. Unknown fields are skipped so newer firmware can
+// add fields before the backend knows them
+func UnmarshalReading(data []byte) (Reading, error) {
+	var reading Reading
+	for len(data) > 0 {
+		number, wireType, n := protowire.ConsumeTag(data)
+		if n < 0 {
+			return Reading{}, fmt.Errorf("invalid protobuf reading: %w", protowire.ParseError(n))
+		}
+		data = data[n:]
+
+		switch {
+		case number == fieldDeviceID && wireType == protowire.BytesType:
+			var value []byte
+			value, n = protowire.ConsumeBytes(data)
+			reading.DeviceID = string(value)
+		case number == fieldTimestamp && wireType == protowire.VarintType:
+			var value uint64
+			value, n = protowire.ConsumeVarint(data)
+			reading.Timestamp = int64(value)
+		case number == fieldType && wireType == protowire.BytesType:
+			var value []byte
+			value, n = protowire.ConsumeBytes(data)
+			reading.Type = string(value)
+		case number == fieldPayload && wireType == protowire.BytesType:
+			var value []byte
+			if value, n = protowire.ConsumeBytes(data); n >= 0 {
+				payload := &structpb.Struct{}
+				if err := proto.Unmarshal(value, payload); err != nil {
+					return Reading{}, fmt.Errorf("invalid protobuf reading payload: %w", err)
+				}
+				reading.Payload = payload.AsMap()
+			}
+		default:
+			n = protowire.ConsumeFieldValue(number, wireType, data)
+		}
+		if n < 0 {
+			return Reading{}, fmt.Errorf("invalid protobuf reading field %d: %w", number, protowire.ParseError(n))
+		}
+		data = data[n:]
+	}
+	return reading, nil
+}
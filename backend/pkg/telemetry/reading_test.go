@@ -0,0 +1,76 @@
+package telemetry
+
+import (
+	"reflect"
+	"testing"
+
+	"google.golang.org/protobuf/encoding/protowire"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/structpb"
+)
+
+// the bytes a device sends for reading, with extra appended as it is
+func encodeReading(t *testing.T, reading Reading, extra []byte) []byte {
+	t.Helper()
+	var data []byte
+	data = protowire.AppendTag(data, fieldDeviceID, protowire.BytesType)
+	data = protowire.AppendString(data, reading.DeviceID)
+	data = protowire.AppendTag(data, fieldTimestamp, protowire.VarintType)
+	data = protowire.AppendVarint(data, uint64(reading.Timestamp))
+	data = protowire.AppendTag(data, fieldType, protowire.BytesType)
+	data = protowire.AppendString(data, reading.Type)
+	if reading.Payload != nil {
+		payload, err := structpb.NewStruct(reading.Payload)
+		if err != nil {
+			t.Fatalf("structpb.NewStruct: %v", err)
+		}
+		raw, err := proto.Marshal(payload)
+		if err != nil {
+			t.Fatalf("proto.Marshal: %v", err)
+		}
+		data = protowire.AppendTag(data, fieldPayload, protowire.BytesType)
+		data = protowire.AppendBytes(data, raw)
+	}
+	return append(data, extra...)
+}
+
+func TestUnmarshalReading(t *testing.T) {
+	reading := Reading{
+		DeviceID:  "truck-1",
+		Timestamp: 1700000000,
+		Type:      "temp-sensor",
+		Payload:   map[string]interface{}{"temp": 4.5, "door_open": false, "label": "rear"},
+	}
+	// a field newer firmware might add: 9 = "v2"
+	unknown := protowire.AppendString(protowire.AppendTag(nil, 9, protowire.BytesType), "v2")
+	// payload field claiming more bytes than follow
+	cutPayload := protowire.AppendVarint(protowire.AppendTag(nil, fieldPayload, protowire.BytesType), 50)
+
+	tests := []struct {
+		name    string
+		data    []byte
+		want    Reading
+		wantErr bool
+	}{
+		{name: "all fields", data: encodeReading(t, reading, nil), want: reading},
+		{name: "unknown fields are skipped", data: encodeReading(t, reading, unknown), want: reading},
+		{name: "no payload", data: encodeReading(t, Reading{DeviceID: "truck-1", Timestamp: 1700000000, Type: "heartbeat"}, nil), want: Reading{DeviceID: "truck-1", Timestamp: 1700000000, Type: "heartbeat"}},
+		{name: "empty message", data: nil, want: Reading{}},
+		{name: "cut short", data: encodeReading(t, reading, nil)[:10], wantErr: true},
+		{name: "payload longer than the message", data: encodeReading(t, Reading{DeviceID: "truck-1"}, cutPayload), wantErr: true},
+		{name: "payload is not a struct", data: protowire.AppendBytes(protowire.AppendTag(nil, fieldPayload, protowire.BytesType), []byte{0xff}), wantErr: true},
+		{name: "not protobuf", data: []byte(`{"device_id": "truck-1"}`), wantErr: true},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got, err := UnmarshalReading(test.data)
+			if (err != nil) != test.wantErr {
+				t.Fatalf("UnmarshalReading() error = %v, wantErr %v", err, test.wantErr)
+			}
+			if !test.wantErr && !reflect.DeepEqual(got, test.want) {
+				t.Errorf("UnmarshalReading() = %+v, want %+v", got, test.want)
+			}
+		})
+	}
+}
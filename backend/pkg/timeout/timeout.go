@@ -0,0 +1,91 @@
+package timeout
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"sync"
+	"time"
+)
+
+const (
+	DefaultCallTimeout = 3 * time.Second
+	// left for logging and writing the response once the lambda deadline is near
+	DefaultMargin = 500 * time.Millisecond
+	// long operations stop with this much left, enough to save where they are and hand the rest on
+	DefaultYieldMargin = 10 * time.Second
+)
+
+// ErrDependencyTimeout marks a dynamodb, iot or fcm call that ran out of time
+var ErrDependencyTimeout = errors.New("dependency_timeout")
+
+var (
+	callTimeout time.Duration
+	margin      time.Duration
+	yieldMargin time.Duration
+	loadOnce    sync.Once
+)
+
+// AWS_CALL_TIMEOUT bounds a single sdk call, HANDLER_DEADLINE_MARGIN is kept free before the lambda deadline,
+// DEADLINE_YIELD_MARGIN is where long operations stop and continue in another invocation
+func load() {
+	callTimeout = parseEnv("AWS_CALL_TIMEOUT", DefaultCallTimeout)
+	margin = parseEnv("HANDLER_DEADLINE_MARGIN", DefaultMargin)
+	yieldMargin = parseEnv("DEADLINE_YIELD_MARGIN", DefaultYieldMargin)
+}
+
+func parseEnv(name string, fallback time.Duration) time.Duration {
+	raw := os.Getenv(name)
+	if raw == "" {
+		return fallback
+	}
+	value, err := time.ParseDuration(raw)
+	if err != nil || value <= 0 {
+		slog.Warn("invalid duration, falling back to default", "env", name, "value", raw, "default", fallback.String())
+		return fallback
+	}
+	return value
+}
+
+// Budget derives the handler context, ending slightly before the lambda deadline in ctx.
+// Without a deadline (local runs) only cancellation is added
+func Budget(ctx context.Context) (context.Context, context.CancelFunc) {
+	loadOnce.Do(load)
+
+	deadline, ok := ctx.Deadline()
+	if !ok {
+		return context.WithCancel(ctx)
+	}
+	return context.WithDeadline(ctx, deadline.Add(-margin))
+}
+
+// Call bounds a single sdk call, it never outlives the handler budget
+func Call(ctx context.Context) (context.Context, context.CancelFunc) {
+	loadOnce.Do(load)
+	return context.WithTimeout(ctx, callTimeout)
+}
+
+// RunningLow reports whether less than DEADLINE_YIELD_MARGIN is left before the deadline of ctx.
+// Long operations check it between steps, and once it is true they save a cursor and hand the
+// rest to a new invocation instead of being killed half way. False without a deadline
+func RunningLow(ctx context.Context) bool {
+	loadOnce.Do(load)
+
+	deadline, ok := ctx.Deadline()
+	return ok && time.Until(deadline) < yieldMargin
+}
+
+// IsTimeout reports whether err comes from a blown call or handler deadline
+func IsTimeout(err error) bool {
+	return errors.Is(err, ErrDependencyTimeout) || errors.Is(err, context.DeadlineExceeded)
+}
+
+// Wrap tags deadline errors with ErrDependencyTimeout, other errors are returned untouched
+func Wrap(err error) error {
+	if err == nil || errors.Is(err, ErrDependencyTimeout) || !errors.Is(err, context.DeadlineExceeded) {
+		return err
+	}
+	return fmt.Errorf("%w: %w", ErrDependencyTimeout, err)
+}
@@ -0,0 +1,26 @@
+package trip
+
+import "github.com/Fleexa-Graduation-Project/Backend/pkg/geo"
+
+// PointFromPayload reads lat/lon, speed (km/h after ingestion normalized it) and ignition
+func PointFromPayload(timestamp int64, payload map[string]interface{}) Point {
+	point := Point{Timestamp: timestamp}
+
+	lat, latOK := payload["lat"].(float64)
+	lon, lonOK := payload["lon"].(float64)
+	if latOK && lonOK && geo.ValidCoord(lat, lon) {
+		point.Position = geo.Coord{Lat: lat, Lon: lon}
+		point.HasPosition = true
+	}
+
+	if speed, ok := payload["speed"].(float64); ok && speed >= 0 {
+		point.SpeedKPH = speed
+		point.HasSpeed = true
+	}
+
+	if ignition, ok := payload["ignition"].(bool); ok {
+		point.Ignition = &ignition
+	}
+
+	return point
+}
@@ -0,0 +1,158 @@
+package trip
+
+import (
+	"time"
+
+	"github.com/Fleexa-Graduation-Project/Backend/pkg/geo"
+)
+
+const (
+	DefaultMaxGap = 10 * time.Minute
+	// below this a vehicle with the ignition on counts as idling
+	DefaultIdleSpeedKPH = 3.0
+)
+
+// Point is one reading as the builder sees it, timestamps are unix seconds
+type Point struct {
+	Timestamp   int64
+	Position    geo.Coord
+	HasPosition bool
+	SpeedKPH    float64
+	HasSpeed    bool
+	Ignition    *bool // nil when the device doesn't report it
+}
+
+// Trip runs from ignition-on to ignition-off, or across readings no further apart than the max gap
+type Trip struct {
+	DeviceID    string  `json:"device_id" dynamodbav:"device_id"`
+	StartTime   int64   `json:"start_time" dynamodbav:"start_time"`
+	EndTime     int64   `json:"end_time" dynamodbav:"end_time"`
+	DistanceKM  float64 `json:"distance_km" dynamodbav:"distance_km"`
+	MaxSpeedKPH float64 `json:"max_speed_kph" dynamodbav:"max_speed_kph"`
+	AvgSpeedKPH float64 `json:"avg_speed_kph" dynamodbav:"avg_speed_kph"`
+	IdleSeconds int64   `json:"idle_seconds" dynamodbav:"idle_seconds"`
+	Points      int     `json:"points" dynamodbav:"points"`
+}
+
+// SessionBuilder turns one device's readings, oldest first, into trips
+type SessionBuilder struct {
+	DeviceID     string
+	MaxGap       time.Duration
+	IdleSpeedKPH float64
+
+	current *Trip
+	last    Point
+	lastPos *geo.Coord
+	trips   []Trip
+}
+
+func NewSessionBuilder(deviceID string, maxGap time.Duration) *SessionBuilder {
+	if maxGap <= 0 {
+		maxGap = DefaultMaxGap
+	}
+	return &SessionBuilder{DeviceID: deviceID, MaxGap: maxGap, IdleSpeedKPH: DefaultIdleSpeedKPH}
+}
+
+// Add consumes the next reading, out of order readings are ignored
+func (builder *SessionBuilder) Add(point Point) {
+	if builder.current != nil {
+		if point.Timestamp < builder.last.Timestamp {
+			return
+		}
+		if time.Duration(point.Timestamp-builder.last.Timestamp)*time.Second > builder.MaxGap {
+			builder.close()
+		}
+	}
+
+	ignitionOff := point.Ignition != nil && !*point.Ignition
+
+	if builder.current == nil {
+		if ignitionOff {
+			return // parked, nothing to record
+		}
+		builder.current = &Trip{DeviceID: builder.DeviceID, StartTime: point.Timestamp, EndTime: point.Timestamp}
+		builder.lastPos = nil
+	} else {
+		builder.extend(point)
+	}
+
+	builder.record(point)
+
+	if ignitionOff {
+		builder.close()
+	}
+}
+
+// the interval since the previous reading belongs to the trip
+func (builder *SessionBuilder) extend(point Point) {
+	trip := builder.current
+	elapsed := point.Timestamp - builder.last.Timestamp
+
+	if builder.last.HasSpeed && builder.last.SpeedKPH < builder.IdleSpeedKPH {
+		trip.IdleSeconds += elapsed
+	}
+
+	if point.HasPosition && builder.lastPos != nil {
+		segment := geo.DistanceKM(*builder.lastPos, point.Position)
+		trip.DistanceKM += segment
+
+		// devices without a speed sensor still get a max speed from their gps track
+		if !point.HasSpeed && elapsed > 0 {
+			if speed := segment / (float64(elapsed) / 3600); speed > trip.MaxSpeedKPH {
+				trip.MaxSpeedKPH = speed
+			}
+		}
+	}
+
+	trip.EndTime = point.Timestamp
+}
+
+func (builder *SessionBuilder) record(point Point) {
+	builder.current.Points++
+	if point.HasSpeed && point.SpeedKPH > builder.current.MaxSpeedKPH {
+		builder.current.MaxSpeedKPH = point.SpeedKPH
+	}
+	if point.HasPosition {
+		position := point.Position
+		builder.lastPos = &position
+	}
+	builder.last = point
+}
+
+func (builder *SessionBuilder) close() {
+	trip := *builder.current
+	if duration := trip.EndTime - trip.StartTime; duration > 0 {
+		trip.AvgSpeedKPH = trip.DistanceKM / (float64(duration) / 3600)
+	}
+
+	builder.trips = append(builder.trips, trip)
+	builder.current = nil
+	builder.lastPos = nil
+}
+
+// Trips returns the closed trips so far
+func (builder *SessionBuilder) Trips() []Trip {
+	return builder.trips
+}
+
+// Open is the trip still in progress, nil when the device is parked
+func (builder *SessionBuilder) Open() *Trip {
+	return builder.current
+}
+
+// Flush closes the trip in progress and returns every trip
+func (builder *SessionBuilder) Flush() []Trip {
+	if builder.current != nil {
+		builder.close()
+	}
+	return builder.trips
+}
+
+// Build is the one-shot form: every trip in points, the last one closed even if still open
+func Build(deviceID string, points []Point, maxGap time.Duration) []Trip {
+	builder := NewSessionBuilder(deviceID, maxGap)
+	for _, point := range points {
+		builder.Add(point)
+	}
+	return builder.Flush()
+}
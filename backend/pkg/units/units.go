@@ -0,0 +1,65 @@
+package units
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+var ErrUnknownUnit = errors.New("unknown unit")
+
+// SpeedToKPH converts a speed reported in kph, mph or m/s to km/h
+func SpeedToKPH(value float64, unit string) (float64, error) {
+	switch strings.ToLower(strings.TrimSpace(unit)) {
+	case "", "kph", "km/h", "kmh":
+		return value, nil
+	case "mph":
+		return value * 1.609344, nil
+	case "m/s", "mps":
+		return value * 3.6, nil
+	default:
+		return 0, fmt.Errorf("%w: speed unit %q", ErrUnknownUnit, unit)
+	}
+}
+
+// TempToCelsius converts a temperature reported in C, F or K to Celsius
+func TempToCelsius(value float64, unit string) (float64, error) {
+	switch strings.ToLower(strings.TrimSpace(unit)) {
+	case "", "c", "celsius":
+		return value, nil
+	case "f", "fahrenheit":
+		return (value - 32) * 5 / 9, nil
+	case "k", "kelvin":
+		return value - 273.15, nil
+	default:
+		return 0, fmt.Errorf("%w: temperature unit %q", ErrUnknownUnit, unit)
+	}
+}
+
+// KPHTo converts a speed in km/h to kph, mph or m/s, the inverse of SpeedToKPH
+func KPHTo(value float64, unit string) (float64, error) {
+	switch strings.ToLower(strings.TrimSpace(unit)) {
+	case "", "kph", "km/h", "kmh":
+		return value, nil
+	case "mph":
+		return value / 1.609344, nil
+	case "m/s", "mps":
+		return value / 3.6, nil
+	default:
+		return 0, fmt.Errorf("%w: speed unit %q", ErrUnknownUnit, unit)
+	}
+}
+
+// CelsiusTo converts a temperature in Celsius to C, F or K, the inverse of TempToCelsius
+func CelsiusTo(value float64, unit string) (float64, error) {
+	switch strings.ToLower(strings.TrimSpace(unit)) {
+	case "", "c", "celsius":
+		return value, nil
+	case "f", "fahrenheit":
+		return value*9/5 + 32, nil
+	case "k", "kelvin":
+		return value + 273.15, nil
+	default:
+		return 0, fmt.Errorf("%w: temperature unit %q", ErrUnknownUnit, unit)
+	}
+}
@@ -0,0 +1,117 @@
+package units
+
+import (
+	"errors"
+	"math"
+	"testing"
+)
+
+func TestSpeedToKPH(t *testing.T) {
+	tests := []struct {
+		unit    string
+		value   float64
+		want    float64
+		wantErr bool
+	}{
+		{unit: "", value: 80, want: 80},
+		{unit: "kph", value: 80, want: 80},
+		{unit: " KM/H ", value: 80, want: 80},
+		{unit: "kmh", value: 80, want: 80},
+		{unit: "mph", value: 50, want: 80.4672},
+		{unit: "m/s", value: 10, want: 36},
+		{unit: "mps", value: 10, want: 36},
+		{unit: "knots", value: 10, wantErr: true},
+	}
+
+	for _, test := range tests {
+		t.Run(test.unit, func(t *testing.T) {
+			got, err := SpeedToKPH(test.value, test.unit)
+			if test.wantErr {
+				if !errors.Is(err, ErrUnknownUnit) {
+					t.Fatalf("SpeedToKPH(%v, %q) error = %v, want ErrUnknownUnit", test.value, test.unit, err)
+				}
+				return
+			}
+			if err != nil || math.Abs(got-test.want) > 1e-9 {
+				t.Errorf("SpeedToKPH(%v, %q) = %v, %v, want %v", test.value, test.unit, got, err, test.want)
+			}
+		})
+	}
+}
+
+func TestTempToCelsius(t *testing.T) {
+	tests := []struct {
+		unit    string
+		value   float64
+		want    float64
+		wantErr bool
+	}{
+		{unit: "", value: 4, want: 4},
+		{unit: "C", value: 4, want: 4},
+		{unit: "celsius", value: -18, want: -18},
+		{unit: "F", value: 212, want: 100},
+		{unit: "fahrenheit", value: -40, want: -40},
+		{unit: "K", value: 273.15, want: 0},
+		{unit: "kelvin", value: 0, want: -273.15},
+		{unit: "rankine", value: 500, wantErr: true},
+	}
+
+	for _, test := range tests {
+		t.Run(test.unit, func(t *testing.T) {
+			got, err := TempToCelsius(test.value, test.unit)
+			if test.wantErr {
+				if !errors.Is(err, ErrUnknownUnit) {
+					t.Fatalf("TempToCelsius(%v, %q) error = %v, want ErrUnknownUnit", test.value, test.unit, err)
+				}
+				return
+			}
+			if err != nil || math.Abs(got-test.want) > 1e-9 {
+				t.Errorf("TempToCelsius(%v, %q) = %v, %v, want %v", test.value, test.unit, got, err, test.want)
+			}
+		})
+	}
+}
+
+// converting to the canonical unit and back gives the reading the device sent, and the other way
+func TestRoundTrip(t *testing.T) {
+	tests := []struct {
+		name  string
+		to    func(float64, string) (float64, error)
+		from  func(float64, string) (float64, error)
+		unit  string
+		value float64
+	}{
+		{name: "kph", to: SpeedToKPH, from: KPHTo, unit: "kph", value: 80},
+		{name: "mph", to: SpeedToKPH, from: KPHTo, unit: "mph", value: 62.5},
+		{name: "m/s", to: SpeedToKPH, from: KPHTo, unit: "m/s", value: 27.7},
+		{name: "celsius", to: TempToCelsius, from: CelsiusTo, unit: "C", value: -18},
+		{name: "fahrenheit", to: TempToCelsius, from: CelsiusTo, unit: "F", value: 98.6},
+		{name: "kelvin", to: TempToCelsius, from: CelsiusTo, unit: "K", value: 255.37},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			canonical, err := test.to(test.value, test.unit)
+			if err != nil {
+				t.Fatalf("to(%v, %q) error = %v", test.value, test.unit, err)
+			}
+			back, err := test.from(canonical, test.unit)
+			if err != nil {
+				t.Fatalf("from(%v, %q) error = %v", canonical, test.unit, err)
+			}
+			again, err := test.to(back, test.unit)
+			if err != nil {
+				t.Fatalf("to(%v, %q) error = %v", back, test.unit, err)
+			}
+			if math.Abs(back-test.value) > 1e-9 || math.Abs(again-canonical) > 1e-9 {
+				t.Errorf("%v %s -> %v -> %v -> %v, want the values back", test.value, test.unit, canonical, back, again)
+			}
+		})
+	}
+
+	for name, from := range map[string]func(float64, string) (float64, error){"KPHTo": KPHTo, "CelsiusTo": CelsiusTo} {
+		if _, err := from(1, "furlongs"); !errors.Is(err, ErrUnknownUnit) {
+			t.Errorf("%s(1, %q) error = %v, want ErrUnknownUnit", name, "furlongs", err)
+		}
+	}
+}
@@ -0,0 +1,137 @@
+#!/usr/bin/env bash
+# Starts amazon/dynamodb-local and creates the tables the stores use, so the
+# api and ingestion code can be run against real DynamoDB calls:
+#
+#   ./scripts/dynamodb-local.sh up
+#   export DYNAMODB_ENDPOINT=http://localhost:8000
+#   ./scripts/dynamodb-local.sh down
+set -euo pipefail
+
+CONTAINER=fleexa-dynamodb-local
+ENDPOINT=${DYNAMODB_ENDPOINT:-http://localhost:8000}
+export AWS_ACCESS_KEY_ID=${AWS_ACCESS_KEY_ID:-local}
+export AWS_SECRET_ACCESS_KEY=${AWS_SECRET_ACCESS_KEY:-local}
+export AWS_REGION=${AWS_REGION:-us-east-1}
+
+ddb() {
+  aws dynamodb --endpoint-url "$ENDPOINT" "$@" >/dev/null
+}
+
+up() {
+  docker run -d --rm --name "$CONTAINER" -p 8000:8000 amazon/dynamodb-local -jar DynamoDBLocal.jar -inMemory
+
+  until aws dynamodb --endpoint-url "$ENDPOINT" list-tables >/dev/null 2>&1; do sleep 1; done
+
+  ddb create-table --table-name "${RESOURCE_PREFIX:-}${DYNAMODB_TABLE_NAME:-Fleexa_Telemetry}" \
+    --attribute-definitions AttributeName=device_id,AttributeType=S AttributeName=timestamp,AttributeType=N \
+    --key-schema AttributeName=device_id,KeyType=HASH AttributeName=timestamp,KeyType=RANGE \
+    --billing-mode PAY_PER_REQUEST
+
+  ddb create-table --table-name "${RESOURCE_PREFIX:-}${DYNAMODB_DEVICE_STATE_TABLE:-Fleexa_Devices}" \
+    --attribute-definitions AttributeName=device_id,AttributeType=S \
+    --key-schema AttributeName=device_id,KeyType=HASH \
+    --billing-mode PAY_PER_REQUEST
+
+  ddb create-table --table-name "${RESOURCE_PREFIX:-}${DYNAMODB_DEVICES_TABLE:-Fleexa_DeviceRegistry}" \
+    --attribute-definitions AttributeName=device_id,AttributeType=S AttributeName=fleet_id,AttributeType=S AttributeName=created_at,AttributeType=N \
+    --key-schema AttributeName=device_id,KeyType=HASH \
+    --global-secondary-indexes 'IndexName=FleetIndex,KeySchema=[{AttributeName=fleet_id,KeyType=HASH},{AttributeName=created_at,KeyType=RANGE}],Projection={ProjectionType=ALL}' \
+    --billing-mode PAY_PER_REQUEST
+
+  ddb create-table --table-name "${RESOURCE_PREFIX:-}${DYNAMODB_ALERTS_TABLE:-Fleexa_Alerts}" \
+    --attribute-definitions AttributeName=device_id,AttributeType=S AttributeName=timestamp,AttributeType=N AttributeName=severity,AttributeType=S \
+    --key-schema AttributeName=device_id,KeyType=HASH AttributeName=timestamp,KeyType=RANGE \
+    --global-secondary-indexes 'IndexName=SeverityIndex,KeySchema=[{AttributeName=severity,KeyType=HASH},{AttributeName=timestamp,KeyType=RANGE}],Projection={ProjectionType=ALL}' \
+    --billing-mode PAY_PER_REQUEST
+
+  ddb create-table --table-name "${RESOURCE_PREFIX:-}${DYNAMODB_COMMANDS_TABLE:-Fleexa_Commands}" \
+    --attribute-definitions AttributeName=request_id,AttributeType=S AttributeName=device_id,AttributeType=S AttributeName=pending_since,AttributeType=N \
+    --key-schema AttributeName=request_id,KeyType=HASH \
+    --global-secondary-indexes 'IndexName=PendingIndex,KeySchema=[{AttributeName=device_id,KeyType=HASH},{AttributeName=pending_since,KeyType=RANGE}],Projection={ProjectionType=ALL}' \
+    --billing-mode PAY_PER_REQUEST
+
+  ddb create-table --table-name "${RESOURCE_PREFIX:-}${DYNAMODB_TRIPS_TABLE:-Fleexa_Trips}" \
+    --attribute-definitions AttributeName=device_id,AttributeType=S AttributeName=start_time,AttributeType=N \
+    --key-schema AttributeName=device_id,KeyType=HASH AttributeName=start_time,KeyType=RANGE \
+    --billing-mode PAY_PER_REQUEST
+
+  ddb create-table --table-name "${RESOURCE_PREFIX:-}${DYNAMODB_DRIVING_EVENTS_TABLE:-Fleexa_DrivingEvents}" \
+    --attribute-definitions AttributeName=device_id,AttributeType=S AttributeName=event_id,AttributeType=S \
+    --key-schema AttributeName=device_id,KeyType=HASH AttributeName=event_id,KeyType=RANGE \
+    --billing-mode PAY_PER_REQUEST
+
+  ddb create-table --table-name "${RESOURCE_PREFIX:-}${DYNAMODB_HOURLY_AGGREGATES_TABLE:-Fleexa_HourlyAggregates}" \
+    --attribute-definitions AttributeName=device_id,AttributeType=S AttributeName=hour_start,AttributeType=N \
+    --key-schema AttributeName=device_id,KeyType=HASH AttributeName=hour_start,KeyType=RANGE \
+    --billing-mode PAY_PER_REQUEST
+
+  ddb create-table --table-name "${RESOURCE_PREFIX:-}${DYNAMODB_SHADOWS_TABLE:-Fleexa_Shadows}" \
+    --attribute-definitions AttributeName=device_id,AttributeType=S \
+    --key-schema AttributeName=device_id,KeyType=HASH \
+    --billing-mode PAY_PER_REQUEST
+
+  ddb create-table --table-name "${RESOURCE_PREFIX:-}${DYNAMODB_LATEST_STATE_TABLE:-Fleexa_LatestState}" \
+    --attribute-definitions AttributeName=device_id,AttributeType=S AttributeName=fleet_id,AttributeType=S \
+    --key-schema AttributeName=device_id,KeyType=HASH \
+    --global-secondary-indexes 'IndexName=FleetIndex,KeySchema=[{AttributeName=fleet_id,KeyType=HASH},{AttributeName=device_id,KeyType=RANGE}],Projection={ProjectionType=ALL}' \
+    --billing-mode PAY_PER_REQUEST
+
+  ddb create-table --table-name "${RESOURCE_PREFIX:-}${DYNAMODB_CONNECTIONS_TABLE:-Fleexa_Connections}" \
+    --attribute-definitions AttributeName=connection_id,AttributeType=S AttributeName=fleet_id,AttributeType=S \
+    --key-schema AttributeName=connection_id,KeyType=HASH \
+    --global-secondary-indexes 'IndexName=FleetIndex,KeySchema=[{AttributeName=fleet_id,KeyType=HASH}],Projection={ProjectionType=ALL}' \
+    --billing-mode PAY_PER_REQUEST
+
+  ddb create-table --table-name "${RESOURCE_PREFIX:-}${DYNAMODB_RATE_LIMITS_TABLE:-Fleexa_RateLimits}" \
+    --attribute-definitions AttributeName=bucket_key,AttributeType=S \
+    --key-schema AttributeName=bucket_key,KeyType=HASH \
+    --billing-mode PAY_PER_REQUEST
+
+  ddb create-table --table-name "${RESOURCE_PREFIX:-}${DYNAMODB_IDEMPOTENCY_TABLE:-Fleexa_Idempotency}" \
+    --attribute-definitions AttributeName=idempotency_key,AttributeType=S \
+    --key-schema AttributeName=idempotency_key,KeyType=HASH \
+    --billing-mode PAY_PER_REQUEST
+
+  ddb create-table --table-name "${RESOURCE_PREFIX:-}${DYNAMODB_ALERT_THROTTLE_TABLE:-Fleexa_AlertThrottle}" \
+    --attribute-definitions AttributeName=throttle_key,AttributeType=S \
+    --key-schema AttributeName=throttle_key,KeyType=HASH \
+    --billing-mode PAY_PER_REQUEST
+
+  ddb create-table --table-name "${RESOURCE_PREFIX:-}${DYNAMODB_PENDING_ALERTS_TABLE:-Fleexa_PendingAlerts}" \
+    --attribute-definitions AttributeName=alert_id,AttributeType=S \
+    --key-schema AttributeName=alert_id,KeyType=HASH \
+    --billing-mode PAY_PER_REQUEST
+
+  ddb create-table --table-name "${RESOURCE_PREFIX:-}${DYNAMODB_AUDIT_TABLE:-Fleexa_AuditLog}" \
+    --attribute-definitions AttributeName=resource,AttributeType=S AttributeName=event_key,AttributeType=S \
+    --key-schema AttributeName=resource,KeyType=HASH AttributeName=event_key,KeyType=RANGE \
+    --billing-mode PAY_PER_REQUEST
+
+  ddb create-table --table-name "${RESOURCE_PREFIX:-}${DYNAMODB_CONTROL_TABLE:-Fleexa_Control}" \
+    --attribute-definitions AttributeName=control_key,AttributeType=S \
+    --key-schema AttributeName=control_key,KeyType=HASH \
+    --billing-mode PAY_PER_REQUEST
+
+  ddb create-table --table-name "${RESOURCE_PREFIX:-}${DYNAMODB_BREACHES_TABLE:-Fleexa_GeofenceBreaches}" \
+    --attribute-definitions AttributeName=device_id,AttributeType=S AttributeName=geofence_id,AttributeType=S AttributeName=fleet_id,AttributeType=S AttributeName=started_at,AttributeType=N \
+    --key-schema AttributeName=device_id,KeyType=HASH AttributeName=geofence_id,KeyType=RANGE \
+    --global-secondary-indexes 'IndexName=FleetIndex,KeySchema=[{AttributeName=fleet_id,KeyType=HASH},{AttributeName=started_at,KeyType=RANGE}],Projection={ProjectionType=ALL}' \
+    --billing-mode PAY_PER_REQUEST
+
+  ddb create-table --table-name "${RESOURCE_PREFIX:-}${DYNAMODB_PROVISIONING_TOKENS_TABLE:-Fleexa_ProvisioningTokens}" \
+    --attribute-definitions AttributeName=token_hash,AttributeType=S \
+    --key-schema AttributeName=token_hash,KeyType=HASH \
+    --billing-mode PAY_PER_REQUEST
+
+  echo "dynamodb-local ready on $ENDPOINT"
+}
+
+down() {
+  docker stop "$CONTAINER" >/dev/null
+}
+
+case "${1:-}" in
+  up) up ;;
+  down) down ;;
+  *) echo "usage: $0 up|down" >&2; exit 1 ;;
+esac
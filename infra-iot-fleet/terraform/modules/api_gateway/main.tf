@@ -59,6 +59,9 @@ resource "aws_api_gateway_rest_api" "api" {
   name             = "${var.project_name}-${var.environment}-api"
   fail_on_warnings = true
 
+  # lets the gateway decode the base64 bodies of gzipped responses
+  binary_media_types = ["*/*"]
+
   endpoint_configuration {
     types = ["REGIONAL"]
   }